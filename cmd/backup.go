@@ -5,9 +5,14 @@ import (
 	"os"
 
 	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/dumpster"
+	"github.com/hibare/stashly/internal/manifest"
 	"github.com/spf13/cobra"
 )
 
+// backupLabels holds key=value labels recorded in the backup's manifest.
+var backupLabels []string
+
 var backupCmd = &cobra.Command{
 	Use:   "backup",
 	Short: "Trigger a backup run immediately",
@@ -21,15 +26,28 @@ var backupCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		slog.InfoContext(ctx, "Starting immediate backup")
-		if bErr := doBackup(ctx, cfg); bErr != nil {
+		labels, err := manifest.ParseLabels(backupLabels)
+		if err != nil {
+			slog.ErrorContext(ctx, "Invalid label", "error", err)
+			os.Exit(1)
+		}
+
+		notify, err := newNotifier(cfg)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize notifiers", "error", err)
+			os.Exit(1)
+		}
+
+		slog.InfoContext(ctx, "Starting immediate backup", "fleet_hosts", len(cfg.Postgres.Fleet))
+		if _, bErr := runBackups(ctx, cmd.OutOrStdout(), cfg, notify, dumpster.DumpOptions{Labels: labels}); bErr != nil {
 			slog.ErrorContext(ctx, "Backup failed", "error", bErr)
-			return
+		} else {
+			slog.InfoContext(ctx, "Backup completed successfully")
 		}
-		slog.InfoContext(ctx, "Backup completed successfully")
 	},
 }
 
 func init() {
+	backupCmd.Flags().StringArrayVar(&backupLabels, "label", nil, "label to attach to the backup as key=value (repeatable)")
 	rootCmd.AddCommand(backupCmd)
 }
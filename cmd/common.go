@@ -2,52 +2,348 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/hibare/GoCommon/v2/pkg/os/exec"
 	"github.com/hibare/stashly/internal/config"
 	"github.com/hibare/stashly/internal/dumpster"
+	"github.com/hibare/stashly/internal/kubejob"
+	"github.com/hibare/stashly/internal/metrics"
 	"github.com/hibare/stashly/internal/notifiers"
-	"github.com/hibare/stashly/internal/storage/s3"
+	"github.com/hibare/stashly/internal/notifiers/events"
+	"github.com/hibare/stashly/internal/remediation"
+	"github.com/hibare/stashly/internal/scheduler"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/hibare/stashly/internal/webhooks"
+
+	// Storage backends register themselves with the storage registry.
+	_ "github.com/hibare/stashly/internal/storage/azblob"
+	_ "github.com/hibare/stashly/internal/storage/ftp"
+	_ "github.com/hibare/stashly/internal/storage/gcs"
+	_ "github.com/hibare/stashly/internal/storage/rclone"
+	_ "github.com/hibare/stashly/internal/storage/s3"
+	_ "github.com/hibare/stashly/internal/storage/webdav"
 )
 
-func doBackup(ctx context.Context, cfg *config.Config) error {
-	store := s3.NewS3Storage(cfg)
-	if err := store.Init(ctx); err != nil {
-		return err
+// newNotifier creates and initialises the notifier store.
+func newNotifier(cfg *config.Config) (notifiers.NotifierStoreIface, error) {
+	notify := notifiers.NewNotifier(cfg)
+	if err := notify.InitStore(); err != nil {
+		return nil, err
 	}
+	return notify, nil
+}
 
-	exec := exec.NewExec()
-	dump := dumpster.NewDumpster(cfg, store, exec)
-	notify := notifiers.NewNotifier(cfg)
-	err := notify.InitStore()
+func doBackup(ctx context.Context, cfg *config.Config, notify notifiers.NotifierStoreIface, opts dumpster.DumpOptions) (*dumpster.DumpResponse, error) {
+	// Webhook deliveries are logged by the dispatcher when they fail and never fail the backup. Failures
+	// are sent without ctx's cancellation, so a cancelled run is still reported.
+	hooks, err := webhooks.New(cfg)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	_ = hooks.Send(ctx, webhooks.Event{Type: webhooks.EventStarted})
+
+	store, err := newStore(ctx, cfg)
+	if err != nil {
+		_ = hooks.Send(context.WithoutCancel(ctx), webhooks.Event{Type: webhooks.EventFailed, Stage: "backup", Error: err.Error()})
+		return nil, err
+	}
+
+	dump, err := dumpster.NewDumpster(cfg, store, exec.NewExec())
+	if err != nil {
+		_ = hooks.Send(context.WithoutCancel(ctx), webhooks.Event{Type: webhooks.EventFailed, Stage: "backup", Error: err.Error()})
+		return nil, err
+	}
+
+	// A new instance has no backups yet; its first one starts its backup coverage.
+	exists, lErr := dump.HasDumps(ctx, dumpster.DumpFilter{})
+	if lErr != nil {
+		slog.WarnContext(ctx, "Failed to list existing backups", "error", lErr)
 	}
+	opts.FirstBackup = lErr == nil && !exists
 
 	// Add new backup
-	dumpResp, err := dump.CreateDump(ctx)
+	dumpResp, err := dump.CreateDump(ctx, opts)
 	if err != nil {
+		_ = hooks.Send(context.WithoutCancel(ctx), webhooks.Event{Type: webhooks.EventFailed, Stage: "backup", Error: err.Error()})
 		if nErr := notify.NotifyBackupFailure(ctx, err); nErr != nil {
 			slog.ErrorContext(ctx, "Failed to send NotifyBackupFailure", "error", nErr)
 		}
-		return err
+		return nil, err
 	}
+	// The index follows whatever the run changed, even when purging or tiering fails.
+	defer dump.UpdateIndex(ctx)
 
-	databases := dumpResp.ExportedDatabases
-	key := dumpResp.StorageKey
+	_ = hooks.Send(ctx, webhooks.Event{
+		Type:            webhooks.EventUploaded,
+		Key:             dumpResp.StorageKey,
+		Timestamp:       dumpResp.Timestamp,
+		Databases:       dumpResp.Databases,
+		Size:            dumpResp.Size,
+		DurationSeconds: dumpResp.Duration.Seconds(),
+		FailedOver:      dumpResp.FailedOver,
+	})
+
+	evt := events.BackupSuccess{
+		Databases:  dumpResp.ExportedDatabases,
+		Key:        dumpResp.StorageKey,
+		Skipped:    dumpResp.SkippedDatabases,
+		Size:       dumpResp.Size,
+		Duration:   dumpResp.Duration,
+		Results:    databaseResults(dumpResp),
+		FailedOver: dumpResp.FailedOver,
+	}
 
-	if nErr := notify.NotifyBackupSuccess(ctx, databases, key); nErr != nil {
+	if nErr := notify.NotifyBackupSuccess(ctx, evt); nErr != nil {
 		slog.ErrorContext(ctx, "Failed to send NotifyBackupSuccess", "error", nErr)
 	}
 
+	if opts.FirstBackup && cfg.Notifiers.FirstBackup {
+		slog.InfoContext(ctx, "First backup of this instance", "instance_id", cfg.App.InstanceID)
+		coverage := events.CoverageStarted{InstanceID: cfg.App.InstanceID, Key: dumpResp.StorageKey, Databases: dumpResp.Databases}
+		if nErr := notify.NotifyCoverageStarted(ctx, coverage); nErr != nil {
+			slog.ErrorContext(ctx, "Failed to send NotifyCoverageStarted", "error", nErr)
+		}
+	}
+
+	metrics.BackupDuration.Observe(dumpResp.Duration.Seconds())
+	if c := dumpResp.Compression; c != nil {
+		metrics.BackupCompressionRatio.Set(c.Ratio)
+		metrics.DatabaseCompressionRatio.Reset()
+		for db, dc := range c.Databases {
+			if dc.Ratio > 0 {
+				metrics.DatabaseCompressionRatio.WithLabelValues(db).Set(dc.Ratio)
+			}
+		}
+	}
+	checkKeyExpiry(ctx, cfg, notify, dumpResp.KeyExpiresAt)
+	if threshold := cfg.Backup.DurationWarning; threshold > 0 && dumpResp.Duration > threshold {
+		slog.WarnContext(ctx, "Backup exceeded duration warning threshold", "duration", dumpResp.Duration, "threshold", threshold)
+		metrics.BackupSlowRuns.Inc()
+
+		slow := events.BackupSlow{Key: dumpResp.StorageKey, Duration: dumpResp.Duration, Threshold: threshold}
+		if nErr := notify.NotifyBackupSlow(ctx, slow); nErr != nil {
+			slog.ErrorContext(ctx, "Failed to send NotifyBackupSlow", "error", nErr)
+		}
+	}
+
 	// Purge old backups
-	if pErr := dump.PurgeDumps(ctx); pErr != nil {
+	purged, pErr := dump.PurgeDumps(ctx, dumpster.PurgeOptions{})
+	if len(purged) > 0 {
+		_ = hooks.Send(ctx, webhooks.Event{Type: webhooks.EventPurged, Purged: purged})
+	}
+	if pErr != nil {
+		_ = hooks.Send(context.WithoutCancel(ctx), webhooks.Event{Type: webhooks.EventFailed, Stage: "purge", Error: pErr.Error()})
 		if nErr := notify.NotifyBackupDeleteFailure(ctx, pErr); nErr != nil {
 			slog.ErrorContext(ctx, "Failed to send NotifyBackupDeleteFailure", "error", nErr)
 		}
-		return pErr
+		return dumpResp, pErr
+	}
+
+	// Move old backups to the cold tier; like purging, a failure leaves this run's backup in place.
+	if tErr := dump.TierDumps(ctx); tErr != nil {
+		return dumpResp, tErr
+	}
+	return dumpResp, nil
+}
+
+// checkKeyExpiry exports the GPG key expiry and warns when the key expires within
+// encryption.gpg.expiry-warning-days.
+func checkKeyExpiry(ctx context.Context, cfg *config.Config, notify notifiers.NotifierStoreIface, expiresAt time.Time) {
+	if !cfg.Backup.Encrypt {
+		return
+	}
+	if expiresAt.IsZero() {
+		metrics.EncryptionKeyExpiry.Set(0)
+		return
+	}
+	metrics.EncryptionKeyExpiry.Set(float64(expiresAt.Unix()))
+
+	days := cfg.Encryption.GPG.ExpiryWarningDays
+	if days <= 0 || time.Until(expiresAt) > time.Duration(days)*24*time.Hour {
+		return
+	}
+	slog.WarnContext(ctx, "GPG encryption key expires soon", "key_id", cfg.Encryption.GPG.KeyID, "expires_at", expiresAt)
+	evt := events.KeyExpiring{KeyID: cfg.Encryption.GPG.KeyID, ExpiresAt: expiresAt}
+	if nErr := notify.NotifyKeyExpiring(ctx, evt); nErr != nil {
+		slog.ErrorContext(ctx, "Failed to send NotifyKeyExpiring", "error", nErr)
+	}
+}
+
+// confirm asks a yes/no question and reports whether the answer was yes.
+func confirm(in io.Reader, out io.Writer, prompt string) bool {
+	_, _ = fmt.Fprintf(out, "%s [y/N]: ", prompt)
+
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && answer == "" {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// newBackend creates the configured storage backend, failing over to storage.secondary if one is
+// set, instrumented but not yet initialised.
+func newBackend(cfg *config.Config) (storage.StorageIface, error) {
+	primary, err := storage.New(cfg, cfg.Storage.Backend)
+	if err != nil || cfg.Storage.Secondary == "" {
+		return primary, err
+	}
+	secondary, err := storage.New(cfg, cfg.Storage.Secondary)
+	if err != nil {
+		return nil, fmt.Errorf("storage.secondary: %w", err)
+	}
+	return storage.NewFailover(primary, secondary, cfg.Storage.UploadAttempts, cfg.Storage.UploadRetryDelay), nil
+}
+
+// newStore creates and initialises the configured storage backend.
+func newStore(ctx context.Context, cfg *config.Config) (storage.StorageIface, error) {
+	store, err := newBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Init(ctx); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// newDumpster creates a dumpster backed by the initialised storage backend.
+func newDumpster(ctx context.Context, cfg *config.Config) (*dumpster.Dumpster, error) {
+	store, err := newStore(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return dumpster.NewDumpster(cfg, store, exec.NewExec())
+}
+
+// notifyRestore reports the outcome of a restore through notify.
+func notifyRestore(ctx context.Context, notify notifiers.NotifierStoreIface, resp *dumpster.RestoreResponse, err error) {
+	if err != nil {
+		if nErr := notify.NotifyRestoreFailure(ctx, err); nErr != nil {
+			slog.ErrorContext(ctx, "Failed to send NotifyRestoreFailure", "error", nErr)
+		}
+		return
+	}
+
+	evt := events.RestoreSuccess{
+		Timestamp: resp.Timestamp,
+		Key:       resp.StorageKey,
+		Databases: resp.Databases,
+		Duration:  resp.Duration,
+	}
+	if nErr := notify.NotifyRestoreSuccess(ctx, evt); nErr != nil {
+		slog.ErrorContext(ctx, "Failed to send NotifyRestoreSuccess", "error", nErr)
+	}
+}
+
+// doRestore restores the backup at timestamp, or the latest backup if timestamp is empty, and reports
+// the outcome through notify.
+func doRestore(ctx context.Context, cfg *config.Config, notify notifiers.NotifierStoreIface, timestamp string, opts dumpster.RestoreOptions) (*dumpster.RestoreResponse, error) {
+	resp, err := restoreBackup(ctx, cfg, timestamp, opts)
+	notifyRestore(ctx, notify, resp, err)
+	return resp, err
+}
+
+// restoreBackup restores the backup at timestamp, or the latest backup if timestamp is empty.
+func restoreBackup(ctx context.Context, cfg *config.Config, timestamp string, opts dumpster.RestoreOptions) (*dumpster.RestoreResponse, error) {
+	dump, err := newDumpster(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if timestamp == "" {
+		if timestamp, err = dump.LatestDump(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	slog.InfoContext(ctx, "Restoring backup", "timestamp", timestamp)
+	return dump.Restore(ctx, timestamp, opts)
+}
+
+// doBootstrapRestore restores the latest backup if the database cluster is empty.
+// It is a no-op when the cluster already holds data or no backup exists yet.
+func doBootstrapRestore(ctx context.Context, cfg *config.Config, notify notifiers.NotifierStoreIface) error {
+	dump, err := newDumpster(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	empty, err := dump.IsClusterEmpty(ctx)
+	if err != nil {
+		return err
 	}
+	if !empty {
+		slog.InfoContext(ctx, "Database cluster is not empty; skipping bootstrap restore")
+		return nil
+	}
+
+	timestamp, err := dump.LatestDump(ctx)
+	if errors.Is(err, dumpster.ErrNoDumps) {
+		slog.InfoContext(ctx, "No backups found; skipping bootstrap restore")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Database cluster is empty; restoring latest backup", "timestamp", timestamp)
+	resp, err := dump.Restore(ctx, timestamp, dumpster.RestoreOptions{})
+	notifyRestore(ctx, notify, resp, err)
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Bootstrap restore completed", "timestamp", resp.Timestamp, "databases", resp.Databases, "duration", resp.Duration)
 	return nil
 }
+
+// doBackupJob runs a backup as a Kubernetes Job. The job sends its own notifications;
+// the scheduler additionally reports jobs that failed, since a pod that was evicted or
+// OOM-killed never gets the chance to.
+func doBackupJob(ctx context.Context, runner kubejob.RunnerIface, notify notifiers.NotifierStoreIface) error {
+	if name, err := runner.Run(ctx); err != nil {
+		// Run only stops waiting when cancelled; delete the Job so the backup itself stops too.
+		if name != "" && errors.Is(context.Cause(ctx), scheduler.ErrJobCancelled) {
+			if dErr := runner.Delete(context.WithoutCancel(ctx), name); dErr != nil {
+				slog.ErrorContext(ctx, "Failed to delete cancelled backup job", "job", name, "error", dErr)
+			}
+		}
+		if nErr := notify.NotifyBackupFailure(ctx, err); nErr != nil {
+			slog.ErrorContext(ctx, "Failed to send NotifyBackupFailure", "error", nErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// logHint logs the remediation hint for err, if its failure class is known.
+func logHint(ctx context.Context, err error) {
+	if hint, ok := remediation.For(err); ok {
+		slog.InfoContext(ctx, "Remediation hint", "class", hint.Class, "hint", hint.Text)
+	}
+}
+
+// databaseResults lists every database the backup run found with its outcome, sorted by name.
+func databaseResults(resp *dumpster.DumpResponse) []events.DatabaseResult {
+	results := []events.DatabaseResult{}
+	for _, db := range resp.Databases {
+		results = append(results, events.DatabaseResult{Name: db, Status: events.DatabaseOK, Size: resp.DatabaseSizes[db]})
+	}
+	for db, reason := range resp.SkippedDatabases {
+		results = append(results, events.DatabaseResult{Name: db, Status: events.DatabaseSkipped, Reason: reason})
+	}
+	for db, reason := range resp.FailedDatabases {
+		results = append(results, events.DatabaseResult{Name: db, Status: events.DatabaseFailed, Reason: reason})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/constants"
+	"github.com/hibare/stashly/internal/dumpster"
+	"github.com/hibare/stashly/internal/notifiers"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// memStore keeps objects in memory under <instance-id>/, laid out like the real backends.
+type memStore struct {
+	base string
+
+	mu      sync.Mutex
+	objects map[string][]byte
+
+	// initErr is returned by Init; limits records the Limit of every ListPage call.
+	initErr error
+	limits  []int
+}
+
+func (m *memStore) Init(context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.initErr
+}
+
+func (m *memStore) Name() string { return "memory" }
+
+func (m *memStore) Upload(_ context.Context, timestamp, localPath string) (string, error) {
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return "", err
+	}
+	key := storage.BuildKey(m.base, timestamp) + filepath.Base(localPath)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = data
+	return key, nil
+}
+
+func (m *memStore) Download(_ context.Context, key, localPath string) error {
+	m.mu.Lock()
+	data, ok := m.objects[key]
+	m.mu.Unlock()
+	if !ok {
+		return os.ErrNotExist
+	}
+	return os.WriteFile(localPath, data, 0600)
+}
+
+// List returns the backup directories and the files directly under the instance prefix, as S3 does.
+func (m *memStore) List(context.Context) ([]string, error) {
+	base := storage.BuildKey(m.base)
+	seen := map[string]bool{}
+	m.mu.Lock()
+	for key := range m.objects {
+		rest := strings.TrimPrefix(key, base)
+		if dir, _, ok := strings.Cut(rest, "/"); ok {
+			seen[base+dir+"/"] = true
+		} else {
+			seen[key] = true
+		}
+	}
+	m.mu.Unlock()
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (m *memStore) ListPage(ctx context.Context, opts storage.ListOptions) (storage.Page, error) {
+	m.mu.Lock()
+	m.limits = append(m.limits, opts.Limit)
+	m.mu.Unlock()
+	keys, err := m.List(ctx)
+	if err != nil {
+		return storage.Page{}, err
+	}
+	return storage.PageKeys(keys, storage.BuildKey(m.base), opts), nil
+}
+
+func (m *memStore) ListFiles(_ context.Context, timestamp string) ([]string, error) {
+	prefix := storage.BuildKey(m.base, timestamp)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := []string{}
+	for key := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (m *memStore) Stats(context.Context) (storage.Usage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sizes := map[string]int64{}
+	for key, data := range m.objects {
+		sizes[key] = int64(len(data))
+	}
+	return storage.UsageOf(storage.BuildKey(m.base), sizes), nil
+}
+
+func (m *memStore) Delete(_ context.Context, timestamp string) error {
+	prefix := storage.BuildKey(m.base, timestamp)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			delete(m.objects, key)
+		}
+	}
+	return nil
+}
+
+func (m *memStore) SetStorageClass(context.Context, string, string) error { return nil }
+
+func (m *memStore) TrimPrefix(keys []string) []string {
+	trimmed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		trimmed = append(trimmed, strings.TrimSuffix(strings.TrimPrefix(key, storage.BuildKey(m.base)), "/"))
+	}
+	return trimmed
+}
+
+// testStore is what the "memory" backend returns; each test sets its own.
+var testStore *memStore
+
+func init() {
+	storage.Register("memory", storage.Registration{
+		New:   func(*config.Config) storage.StorageIface { return testStore },
+		Retry: func(*config.Config) config.RetryConfig { return config.RetryConfig{} },
+	})
+}
+
+// mockPostgres makes the engine see one database, db1, and dump it.
+func mockPostgres(t *testing.T) {
+	t.Helper()
+	mockExec := exec.NewMockExecIface(t)
+	mockCmd := exec.NewMockCmdIface(t)
+	original := exec.NewExec
+	exec.NewExec = func() exec.ExecIface { return mockExec }
+	t.Cleanup(func() { exec.NewExec = original })
+
+	dir := filepath.Join(os.TempDir(), constants.ExportDir)
+	mockExec.On("LookPath", mock.Anything).Return("/usr/bin/true", nil)
+	mockExec.On("Command", mock.Anything, mock.Anything, mock.Anything).Return(mockCmd)
+	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithDir", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithStderr", mock.Anything).Return(mockCmd)
+	mockCmd.On("Output").Return([]byte("db1\n"), nil)
+	mockCmd.On("CombinedOutput").Run(func(mock.Arguments) {
+		content := "CREATE TABLE t ();\n\n--\n-- PostgreSQL database dump complete\n--\n"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "db1.sql"), []byte(content), 0600))
+	}).Return([]byte(""), nil)
+}
+
+func TestDoBackup_UpdatesIndex(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	mockPostgres(t)
+	testStore = &memStore{base: "db-1", objects: map[string][]byte{}}
+
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := "app:\n  instance-id: db-1\nstorage:\n  backend: memory\n  index: true\ncatalog:\n  path: " +
+		filepath.Join(t.TempDir(), "catalog.json") + "\n"
+	require.NoError(t, os.WriteFile(cfgPath, []byte(yaml), 0600))
+	cfg, err := config.LoadConfig(context.Background(), cfgPath)
+	require.NoError(t, err)
+
+	resp, err := doBackup(context.Background(), cfg, notifiers.NewNotifier(cfg), dumpster.DumpOptions{})
+	require.NoError(t, err)
+
+	data, ok := testStore.objects[path.Join("db-1", dumpster.IndexFileName)]
+	require.True(t, ok, "index.json wasn't uploaded")
+	var index dumpster.BackupIndex
+	require.NoError(t, json.Unmarshal(data, &index))
+	require.Len(t, index.Backups, 1)
+	assert.Equal(t, resp.Timestamp, index.Backups[0].Timestamp)
+	assert.Equal(t, path.Join(resp.Timestamp, path.Base(resp.StorageKey)), index.Backups[0].Key)
+	assert.NotEmpty(t, index.Backups[0].SHA256)
+}
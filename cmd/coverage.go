@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/dumpster"
+	"github.com/spf13/cobra"
+)
+
+// coverageRuns is how many of the newest backups the coverage report inspects.
+var coverageRuns int
+
+var coverageCmd = &cobra.Command{
+	Use:   "coverage",
+	Short: "Report databases found on the server that recent backups didn't cover",
+	Long: `Report databases that recent backups found on the server but didn't back up, e.g. because
+the backup user can't read them or their dump keeps failing.
+
+A database is reported when none of the inspected backups contains it. Backups taken before
+coverage was recorded in the manifest are ignored. Exits non-zero if any database is not covered.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+
+		// Load config
+		cfg, err := config.LoadConfig(ctx, cfgFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load config", "error", err)
+			os.Exit(1)
+		}
+
+		dump, err := newDumpster(ctx, cfg)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize storage", "error", err)
+			os.Exit(1)
+		}
+
+		backups, err := dump.ListBackups(ctx, nil)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to list backups", "error", err)
+			os.Exit(1)
+		}
+		if coverageRuns > 0 && len(backups) > coverageRuns {
+			backups = backups[:coverageRuns]
+		}
+
+		notCovered := dumpster.Coverage(backups)
+		if len(notCovered) == 0 {
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "All databases found by the last %d backups are covered\n", len(backups))
+			return
+		}
+
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Not covered by the last %d backups:\n", len(backups))
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "DATABASE\tRUNS\tLAST SEEN\tREASON")
+		for _, u := range notCovered {
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", u.Name, u.Runs, u.LastSeen, u.Reason)
+		}
+		_ = w.Flush()
+		os.Exit(1)
+	},
+}
+
+func init() {
+	coverageCmd.Flags().IntVar(&coverageRuns, "runs", 7, "number of most recent backups to inspect (0 inspects all)")
+	rootCmd.AddCommand(coverageCmd)
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/dumpster"
+	"github.com/hibare/stashly/internal/units"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// deleteYes skips the confirmation prompt.
+	deleteYes bool
+
+	// deleteAllowLast allows deleting the only remaining backup.
+	deleteAllowLast bool
+)
+
+// describeBackup prints what deleting b removes: its age, size and databases where the manifest
+// records them.
+func describeBackup(out io.Writer, b dumpster.BackupInfo) {
+	if b.Manifest == nil {
+		taken, err := dumpster.ParseTimestamp(b.Timestamp)
+		if err != nil {
+			_, _ = fmt.Fprintf(out, "Backup %s has no manifest; its age and size are unknown\n", b.Timestamp)
+			return
+		}
+		_, _ = fmt.Fprintf(out, "Backup %s (taken %s ago, no manifest; size unknown)\n", b.Timestamp, time.Since(taken).Round(time.Minute))
+		return
+	}
+
+	m := b.Manifest
+	_, _ = fmt.Fprintf(out, "Backup %s (taken %s, %s ago, %s)\n", b.Timestamp,
+		m.CreatedAt.Format("2006-01-02 15:04:05 MST"), time.Since(m.CreatedAt).Round(time.Minute), units.FormatBytes(m.Size))
+	if len(m.Databases) > 0 {
+		_, _ = fmt.Fprintf(out, "Databases: %s\n", strings.Join(m.Databases, ", "))
+	}
+}
+
+var deleteCmd = &cobra.Command{
+	Use:   "delete <key>",
+	Short: "Delete a backup from storage",
+	Long: `Delete the backup at <key>, its timestamp as printed by "stashly list", with all its files.
+The backup's age and size are shown and confirmation is asked for unless --yes is passed. The only
+remaining backup is never deleted without --allow-last.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		// Load config
+		cfg, err := config.LoadConfig(ctx, cfgFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load config", "error", err)
+			os.Exit(1)
+		}
+
+		dump, err := newDumpster(ctx, cfg)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize storage", "error", err)
+			os.Exit(1)
+		}
+
+		out := cmd.OutOrStdout()
+		opts := dumpster.DeleteOptions{AllowLast: deleteAllowLast}
+		if !deleteYes {
+			opts.Confirm = func(b dumpster.BackupInfo) bool {
+				describeBackup(out, b)
+				return confirm(cmd.InOrStdin(), out, "Delete this backup?")
+			}
+		}
+
+		deleted, err := dump.DeleteDump(ctx, args[0], opts)
+		if err != nil {
+			slog.ErrorContext(ctx, "Delete failed", "key", args[0], "error", err)
+			os.Exit(1)
+		}
+		if !deleted {
+			_, _ = fmt.Fprintln(out, "Delete aborted")
+			return
+		}
+		dump.UpdateIndex(ctx)
+		slog.InfoContext(ctx, "Backup deleted", "key", args[0])
+	},
+}
+
+func init() {
+	deleteCmd.Flags().BoolVarP(&deleteYes, "yes", "y", false, "skip the confirmation prompt")
+	deleteCmd.Flags().BoolVar(&deleteAllowLast, "allow-last", false, "allow deleting the only remaining backup")
+	rootCmd.AddCommand(deleteCmd)
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/hibare/stashly/internal/constants"
+	"github.com/hibare/stashly/internal/dumpster"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribeBackup_NoManifest(t *testing.T) {
+	// The age of a backup without a manifest comes from its key, named after local time.
+	var out bytes.Buffer
+	timestamp := time.Now().Add(-2 * time.Hour).Format(constants.DefaultDateTimeLayout)
+	describeBackup(&out, dumpster.BackupInfo{Timestamp: timestamp})
+	assert.Equal(t, "Backup "+timestamp+" (taken 2h0m0s ago, no manifest; size unknown)\n", out.String())
+
+	out.Reset()
+	describeBackup(&out, dumpster.BackupInfo{Timestamp: "latest"})
+	assert.Equal(t, "Backup latest has no manifest; its age and size are unknown\n", out.String())
+}
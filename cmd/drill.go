@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/hibare/stashly/internal/catalog"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/maintenance"
+	"github.com/hibare/stashly/internal/metrics"
+	"github.com/hibare/stashly/internal/notifiers"
+	"github.com/hibare/stashly/internal/notifiers/events"
+	"github.com/hibare/stashly/internal/scheduler"
+	"github.com/spf13/cobra"
+)
+
+// drillCheckInterval is how often the daemon checks whether restore drills are overdue between drills.
+const drillCheckInterval = time.Hour
+
+var (
+	// errNoDrillHost is returned when a drill is run without a scratch server to restore into.
+	errNoDrillHost = errors.New("restore.drill.host is not set")
+
+	// errDrillFleet is returned when a drill is run for a fleet, whose hosts drills don't cover yet.
+	errDrillFleet = errors.New("restore drills don't support fleets")
+)
+
+// doDrill runs a restore drill into the scratch server of restore.drill and reports a failure through notify.
+func doDrill(ctx context.Context, cfg *config.Config, notify notifiers.NotifierStoreIface) (*catalog.Drill, error) {
+	dump, err := newDumpster(ctx, cfg.ForDrill())
+	if err != nil {
+		return nil, err
+	}
+
+	drill, err := dump.Drill(ctx)
+	if err != nil {
+		metrics.DrillRuns.WithLabelValues("failed").Inc()
+		if nErr := notify.NotifyRestoreFailure(ctx, fmt.Errorf("restore drill: %w", err)); nErr != nil {
+			slog.ErrorContext(ctx, "Failed to send NotifyRestoreFailure", "error", nErr)
+		}
+		return drill, err
+	}
+
+	metrics.DrillRuns.WithLabelValues("passed").Inc()
+	metrics.DrillLastSuccess.Set(float64(drill.StartedAt.Unix()))
+	slog.InfoContext(ctx, "Restore drill passed", "timestamp", drill.Backup, "tables", drill.Tables, "duration", drill.Duration)
+	return drill, nil
+}
+
+// drillOverdue reports whether no restore drill has passed within restore.drill.window, counting from
+// since when none ever has, and returns the event to alert with.
+func drillOverdue(cfg *config.Config, since time.Time) (bool, events.DrillOverdue, error) {
+	evt := events.DrillOverdue{Window: cfg.Restore.Drill.Window}
+	c, err := catalog.Load(cfg.Catalog.Path, cfg.App.InstanceID)
+	if err != nil {
+		return false, evt, err
+	}
+
+	if last, ok := c.LastPassedDrill(); ok {
+		evt.LastPassed = last.StartedAt
+		if last.StartedAt.After(since) {
+			since = last.StartedAt
+		}
+	}
+	if n := len(c.Drills); n > 0 {
+		evt.LastError = c.Drills[n-1].Error
+	}
+	return evt.Window > 0 && time.Since(since) > evt.Window, evt, nil
+}
+
+// runDrills runs restore drills on restore.drill.cron until ctx is done, skipping them in maintenance
+// mode. It alerts once whenever drills become overdue, and again only after one has passed.
+func runDrills(ctx context.Context, cfg *config.Config, notify notifiers.NotifierStoreIface) {
+	started := time.Now()
+	if c, err := catalog.Load(cfg.Catalog.Path, cfg.App.InstanceID); err == nil {
+		if last, ok := c.LastPassedDrill(); ok {
+			metrics.DrillLastSuccess.Set(float64(last.StartedAt.Unix()))
+		}
+	}
+
+	alerted := false
+	check := func() {
+		overdue, evt, err := drillOverdue(cfg, started)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to read restore drills", "path", cfg.Catalog.Path, "error", err)
+			return
+		}
+		if overdue && !alerted {
+			slog.WarnContext(ctx, "Restore drills are overdue", "last_passed", evt.LastPassed, "window", evt.Window)
+			if nErr := notify.NotifyDrillOverdue(ctx, evt); nErr != nil {
+				slog.ErrorContext(ctx, "Failed to send NotifyDrillOverdue", "error", nErr)
+			}
+		}
+		alerted = overdue
+	}
+
+	ticker := time.NewTicker(drillCheckInterval)
+	defer ticker.Stop()
+	for {
+		next, err := scheduler.Next(cfg.Restore.Drill.Cron, time.Now().UTC())
+		if err != nil {
+			slog.ErrorContext(ctx, "Invalid restore drill schedule; drills are disabled", "cron", cfg.Restore.Drill.Cron, "error", err)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-ticker.C:
+			timer.Stop()
+			check()
+			continue
+		case <-timer.C:
+		}
+
+		if maintenance.Enabled(ctx, cfg.Server.MaintenanceFile) {
+			slog.InfoContext(ctx, "Skipping scheduled restore drill; maintenance mode is on")
+			continue
+		}
+		if _, err := doDrill(ctx, cfg, notify); err != nil {
+			slog.ErrorContext(ctx, "Restore drill failed", "error", err)
+		}
+		check()
+	}
+}
+
+var drillCmd = &cobra.Command{
+	Use:   "drill",
+	Short: "Restore the latest backup into the scratch server and check it",
+	Long: `Run a restore drill: restore the latest backup into the scratch server set in restore.drill,
+check that every database the backup lists was restored, and drop the restored databases again.
+
+Databases on the scratch server that the backup holds are replaced. The outcome is recorded in the
+catalog, where the daemon looks for the last drill that passed. Exits non-zero if the drill fails.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+
+		// Load config
+		cfg, err := config.LoadConfig(ctx, cfgFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load config", "error", err)
+			os.Exit(1)
+		}
+		switch {
+		case cfg.Restore.Drill.Host == "":
+			slog.ErrorContext(ctx, "Restore drill failed", "error", errNoDrillHost)
+			os.Exit(1)
+		case isFleet(cfg):
+			slog.ErrorContext(ctx, "Restore drill failed", "error", errDrillFleet)
+			os.Exit(1)
+		}
+
+		notify, err := newNotifier(cfg)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize notifiers", "error", err)
+			os.Exit(1)
+		}
+
+		drill, err := doDrill(ctx, cfg, notify)
+		if err != nil {
+			slog.ErrorContext(ctx, "Restore drill failed", "error", err)
+			os.Exit(1)
+		}
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Restore drill passed: backup %s, %d databases in %s\n",
+			drill.Backup, len(drill.Tables), drill.Duration.Round(time.Second))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(drillCmd)
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/dumpster"
+	"github.com/hibare/stashly/internal/framed"
+	"github.com/hibare/stashly/internal/keyproof"
+	"github.com/spf13/cobra"
+)
+
+var encryptionCmd = &cobra.Command{
+	Use:   "encryption",
+	Short: "Manage backup encryption",
+}
+
+var encryptionProveCmd = &cobra.Command{
+	Use:   "prove",
+	Short: "Prove that the private key for the encryption key is available",
+	Long: `Encrypt a random code to the configured GPG key and ask for it back. Decrypt the printed message
+with the private key (e.g. "gpg --decrypt") and enter the code. If encryption.gpg.private-key-file
+is set, the code is decrypted with it instead of asking.
+
+On success the key's fingerprints are recorded in encryption.gpg.key-proof-file. With
+encryption.gpg.require-key-proof, encrypted backups refuse to run until the current key is proven.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+
+		// Load config
+		cfg, err := config.LoadConfig(ctx, cfgFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load config", "error", err)
+			os.Exit(1)
+		}
+		if cfg.Encryption.GPG.KeyServer == "" || cfg.Encryption.GPG.KeyID == "" {
+			slog.ErrorContext(ctx, "Encryption requires encryption.gpg.key-server and key-id")
+			os.Exit(1)
+		}
+
+		// Proving doesn't touch storage.
+		dump, err := dumpster.NewDumpster(cfg, nil, exec.NewExec())
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize dumpster", "error", err)
+			os.Exit(1)
+		}
+
+		publicKey, err := dump.EncryptionPublicKey()
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to fetch GPG key", "error", err)
+			os.Exit(1)
+		}
+		fingerprints, err := keyproof.Fingerprints(publicKey)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to read GPG key", "error", err)
+			os.Exit(1)
+		}
+		code, challenge, err := keyproof.NewChallenge(publicKey)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to create challenge", "error", err)
+			os.Exit(1)
+		}
+
+		out := cmd.OutOrStdout()
+		var answer string
+		if path := cfg.Encryption.GPG.PrivateKeyFile; path != "" {
+			privateKey, rErr := os.ReadFile(path)
+			if rErr != nil {
+				slog.ErrorContext(ctx, "Failed to read private key file", "error", rErr)
+				os.Exit(1)
+			}
+			if answer, err = keyproof.Decrypt(challenge, string(privateKey), cfg.Encryption.GPG.Passphrase); err != nil {
+				slog.ErrorContext(ctx, "Failed to decrypt challenge with encryption.gpg.private-key-file", "error", err)
+				os.Exit(1)
+			}
+		} else {
+			_, _ = fmt.Fprintln(out, "Decrypt this message with the private key (e.g. save it and run gpg --decrypt):")
+			_, _ = fmt.Fprintln(out)
+			_, _ = fmt.Fprintln(out, challenge)
+			_, _ = fmt.Fprint(out, "Decrypted code: ")
+			answer, _ = bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+		}
+
+		if err := keyproof.Check(code, answer); err != nil {
+			slog.ErrorContext(ctx, "Key proof failed", "error", err)
+			os.Exit(1)
+		}
+
+		proof := &keyproof.Proof{Fingerprints: fingerprints, ProvedAt: time.Now().UTC()}
+		if err := proof.Save(cfg.Encryption.GPG.KeyProofFile); err != nil {
+			slog.ErrorContext(ctx, "Failed to save key proof", "error", err)
+			os.Exit(1)
+		}
+		_, _ = fmt.Fprintf(out, "Private key proven for %v; recorded in %s\n", fingerprints, cfg.Encryption.GPG.KeyProofFile)
+	},
+}
+
+// decryptPrivateKeyFile overrides encryption.gpg.private-key-file for encryption decrypt.
+var decryptPrivateKeyFile string
+
+var encryptionDecryptCmd = &cobra.Command{
+	Use:   "decrypt",
+	Short: "Decrypt a framed dump stream from stdin to stdout",
+	Long: `Decrypt a stream written by "stashly dump --encrypt --framed" from stdin to stdout, verifying
+each frame before it is written, so the dump can be piped straight into a restore:
+
+  ssh backup-host 'cat app.sql.gz.enc' | stashly encryption decrypt | gunzip | psql app
+
+The stream key is unwrapped with encryption.gpg.private-key-file (or --private-key-file) and
+encryption.gpg.passphrase. A tampered or truncated stream fails at the first bad frame; output
+already written up to that point must be discarded. Logs are written to stderr.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+
+		// Logs go to stderr so they don't mix with the plaintext on stdout.
+		stdout := os.Stdout
+		os.Stdout = os.Stderr
+
+		// Load config
+		cfg, err := config.LoadConfig(ctx, cfgFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load config", "error", err)
+			os.Exit(1)
+		}
+
+		path := cmp.Or(decryptPrivateKeyFile, cfg.Encryption.GPG.PrivateKeyFile)
+		if path == "" {
+			slog.ErrorContext(ctx, "Decrypting requires encryption.gpg.private-key-file or --private-key-file")
+			os.Exit(1)
+		}
+		privateKey, err := os.ReadFile(path)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to read private key file", "error", err)
+			os.Exit(1)
+		}
+
+		r, err := framed.NewReader(cmd.InOrStdin(), string(privateKey), cfg.Encryption.GPG.Passphrase)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to open framed stream", "error", err)
+			os.Exit(1)
+		}
+		if _, err := io.Copy(stdout, r); err != nil {
+			slog.ErrorContext(ctx, "Decryption failed", "error", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	encryptionDecryptCmd.Flags().StringVar(&decryptPrivateKeyFile, "private-key-file", "", "armored GPG private key (defaults to encryption.gpg.private-key-file)")
+	encryptionCmd.AddCommand(encryptionDecryptCmd)
+	encryptionCmd.AddCommand(encryptionProveCmd)
+	rootCmd.AddCommand(encryptionCmd)
+}
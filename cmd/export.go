@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/dumpster"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// exportSince selects backups taken on or after this date.
+	exportSince string
+
+	// exportOut is the directory backups are exported to.
+	exportOut string
+)
+
+// parseSince parses a date (2006-01-02, local time) or an RFC 3339 time.
+func parseSince(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Copy backups and their manifests to a local directory, e.g. for air-gapped transfer",
+	Long: `Copy the backups taken since --since (all backups by default), with their manifests, to
+--out as <timestamp>/<file>. A ` + dumpster.ChecksumsFile + ` file lists the SHA-256 of every file, so the copy
+can be checked with "sha256sum -c ` + dumpster.ChecksumsFile + `" and is verified again by "stashly import-dir".
+Encrypted backups stay encrypted.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+
+		// Load config
+		cfg, err := config.LoadConfig(ctx, cfgFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load config", "error", err)
+			os.Exit(1)
+		}
+
+		since, err := parseSince(exportSince)
+		if err != nil {
+			slog.ErrorContext(ctx, "Invalid --since, expected 2006-01-02 or RFC 3339", "error", err)
+			os.Exit(1)
+		}
+		if err := os.MkdirAll(exportOut, 0750); err != nil {
+			slog.ErrorContext(ctx, "Failed to create output directory", "error", err)
+			os.Exit(1)
+		}
+
+		dump, err := newDumpster(ctx, cfg)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize storage", "error", err)
+			os.Exit(1)
+		}
+
+		exported, err := dump.ExportBackups(ctx, exportOut, since)
+		if err != nil {
+			slog.ErrorContext(ctx, "Export failed", "error", err)
+			os.Exit(1)
+		}
+		for _, ts := range exported {
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), ts)
+		}
+		slog.InfoContext(ctx, "Export completed successfully", "backups", len(exported), "dir", exportOut)
+	},
+}
+
+var importDirCmd = &cobra.Command{
+	Use:   "import-dir <dir>",
+	Short: "Upload backups exported with stashly export",
+	Long: `Upload the backups in a directory written by "stashly export", after verifying every file against
+its ` + dumpster.ChecksumsFile + `. Backups already in storage are skipped. The imported backups are listed,
+restorable and subject to retention like any other.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		// Load config
+		cfg, err := config.LoadConfig(ctx, cfgFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load config", "error", err)
+			os.Exit(1)
+		}
+
+		dump, err := newDumpster(ctx, cfg)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize storage", "error", err)
+			os.Exit(1)
+		}
+
+		imported, err := dump.ImportBackups(ctx, args[0])
+		// Backups imported before a failure stay in storage.
+		dump.UpdateIndex(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Import failed", "error", err)
+			os.Exit(1)
+		}
+		for _, ts := range imported {
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), ts)
+		}
+		slog.InfoContext(ctx, "Import completed successfully", "backups", len(imported))
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringVar(&exportSince, "since", "", "only export backups taken on or after this date (2006-01-02 or RFC 3339)")
+	exportCmd.Flags().StringVar(&exportOut, "out", "", "directory to export backups to")
+	_ = exportCmd.MarkFlagRequired("out")
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importDirCmd)
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/discovery"
+	"github.com/hibare/stashly/internal/dumpster"
+	"github.com/hibare/stashly/internal/notifiers"
+)
+
+// fleetResult is the outcome of backing up one host of the fleet.
+type fleetResult struct {
+	Host    string
+	Resp    *dumpster.DumpResponse
+	Err     error
+	Elapsed time.Duration
+}
+
+// isFleet reports whether cfg backs up a fleet, configured or discovered, rather than a single host.
+func isFleet(cfg *config.Config) bool {
+	return len(cfg.Postgres.Fleet) > 0 || cfg.Discovery.URL != ""
+}
+
+// newDiscovery returns the configured discovery provider, or nil if discovery is disabled. With watch
+// set, a provider that can watch its inventory is kept current in the background until ctx is done,
+// for the daemon's runs to use.
+func newDiscovery(ctx context.Context, cfg *config.Config, watch bool) (discovery.ProviderIface, error) {
+	provider, err := discovery.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring discovery: %w", err)
+	}
+	watcher, ok := provider.(discovery.WatcherIface)
+	if !watch || !ok {
+		return provider, nil
+	}
+	slog.InfoContext(ctx, "Watching the discovery inventory", "provider", cfg.Discovery.Provider)
+	cache := discovery.NewCache(watcher)
+	go cache.Run(ctx)
+	return cache, nil
+}
+
+// withDiscovered returns cfg with the servers found by provider added to postgres.fleet. If the
+// inventory can't be read the configured fleet is still backed up; with no configured fleet that is
+// an error.
+func withDiscovered(ctx context.Context, cfg *config.Config, provider discovery.ProviderIface) (*config.Config, error) {
+	if provider == nil {
+		return cfg, nil
+	}
+
+	hosts, err := provider.Discover(ctx)
+	if err != nil {
+		if len(cfg.Postgres.Fleet) == 0 {
+			return nil, fmt.Errorf("error discovering databases: %w", err)
+		}
+		slog.WarnContext(ctx, "Database discovery failed; backing up the configured fleet", "error", err)
+		return cfg, nil
+	}
+
+	c := *cfg
+	c.Postgres.Fleet = discovery.Merge(cfg.Postgres.Fleet, hosts)
+	slog.InfoContext(ctx, "Discovered databases", "discovered", len(hosts), "fleet_hosts", len(c.Postgres.Fleet))
+	return &c, nil
+}
+
+// doFleetBackup backs up every host in postgres.fleet and the discovery inventory,
+// backup.fleet-concurrency at a time. Each host is backed up as its own instance, so a failing host
+// doesn't stop the others; the returned error joins the failures. Results are in fleet order.
+func doFleetBackup(ctx context.Context, cfg *config.Config, provider discovery.ProviderIface, notify notifiers.NotifierStoreIface, opts dumpster.DumpOptions) ([]fleetResult, error) {
+	cfg, err := withDiscovered(ctx, cfg, provider)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to discover databases", "error", err)
+		if nErr := notify.NotifyBackupFailure(ctx, err); nErr != nil {
+			slog.ErrorContext(ctx, "Failed to send NotifyBackupFailure", "error", nErr)
+		}
+		return nil, err
+	}
+
+	results := make([]fleetResult, len(cfg.Postgres.Fleet))
+	sem := make(chan struct{}, max(cfg.Backup.FleetConcurrency, 1))
+
+	var wg sync.WaitGroup
+	for i, host := range cfg.Postgres.Fleet {
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			hostCfg := cfg.ForHost(host)
+			slog.InfoContext(ctx, "Backing up fleet host", "host", host.Name, "instance_id", hostCfg.App.InstanceID)
+			start := time.Now()
+			resp, err := doBackup(ctx, hostCfg, notify, opts)
+			if err != nil {
+				slog.ErrorContext(ctx, "Fleet host backup failed", "host", host.Name, "error", err)
+			}
+			results[i] = fleetResult{Host: host.Name, Resp: resp, Err: err, Elapsed: time.Since(start)}
+		})
+	}
+	wg.Wait()
+
+	errs := []error{}
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.Host, r.Err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// runBackups backs up the fleet if one is configured or discovered and the single configured host otherwise,
+// printing the summary to out. It reports whether at least one backup was stored.
+func runBackups(ctx context.Context, out io.Writer, cfg *config.Config, notify notifiers.NotifierStoreIface, opts dumpster.DumpOptions) (bool, error) {
+	start := time.Now()
+	if !isFleet(cfg) {
+		resp, err := doBackup(ctx, cfg, notify, opts)
+		logHint(ctx, err)
+		printSummary(out, resp, err, time.Since(start))
+		return resp != nil, err
+	}
+
+	provider, err := newDiscovery(ctx, cfg, false)
+	if err != nil {
+		printFleetSummary(out, nil, time.Since(start))
+		return false, err
+	}
+	results, err := doFleetBackup(ctx, cfg, provider, notify, opts)
+	stored := false
+	for _, r := range results {
+		logHint(ctx, r.Err)
+		stored = stored || r.Resp != nil
+	}
+	printFleetSummary(out, results, time.Since(start))
+	return stored, err
+}
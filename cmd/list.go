@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/cost"
+	"github.com/hibare/stashly/internal/dumpster"
+	"github.com/hibare/stashly/internal/manifest"
+	"github.com/hibare/stashly/internal/scheduler"
+	"github.com/hibare/stashly/internal/units"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// listLabels holds key=value labels backups must carry to be listed.
+	listLabels []string
+
+	// listDetails adds estimated storage costs to the listing.
+	listDetails bool
+
+	// listRefresh reconciles the local catalog before listing.
+	listRefresh bool
+)
+
+// backupObjects returns how many objects a backup is stored as: its archive (or volumes) plus the manifest,
+// and the encrypted manifest if it has one.
+func backupObjects(m *manifest.Manifest) int64 {
+	objects := int64(max(len(m.Volumes), 1)) + 1
+	if m.Sealed != nil {
+		objects++
+	}
+	return objects
+}
+
+// engineFormat describes what produced a backup, e.g. "postgres/plain"; manifests that predate the
+// format field hold plain dumps.
+func engineFormat(m *manifest.Manifest) string {
+	format := m.Format
+	if format == "" {
+		format = "plain"
+	}
+	return m.Engine + "/" + format
+}
+
+// printCostEstimate writes the estimated monthly cost of keeping backups stored and of taking new
+// ones on the configured schedule.
+func printCostEstimate(out io.Writer, cfg *config.Config, backups []dumpster.BackupInfo) {
+	prices := cost.Prices{StoragePerGB: cfg.S3.StoragePricePerGB, RequestsPer1000: cfg.S3.RequestPricePer1000}
+
+	var size int64
+	requestsPerRun := int64(2)
+	for i, b := range backups {
+		if b.Manifest == nil {
+			continue
+		}
+		size += b.Manifest.Size
+		if i == 0 {
+			requestsPerRun = backupObjects(b.Manifest)
+		}
+	}
+
+	// An invalid schedule only costs us the request estimate.
+	runs, _ := scheduler.RunsPerMonth(cfg.Backup.Cron, time.Now())
+	est := prices.Monthly(size, runs, requestsPerRun)
+
+	_, _ = fmt.Fprintf(out, "\n%d backups, %s stored\n", len(backups), units.FormatBytes(size))
+	_, _ = fmt.Fprintf(out, "Estimated monthly cost: %s storage + %s requests (%d runs) = %s\n",
+		cost.Format(est.Storage), cost.Format(est.Requests), runs, cost.Format(est.Total()))
+	_, _ = fmt.Fprintf(out, "Prices: %g per GB-month, %g per 1000 requests (s3.storage-price-per-gb, s3.request-price-per-1000)\n",
+		prices.StoragePerGB, prices.RequestsPer1000)
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List backups in storage, newest first",
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+
+		// Load config
+		cfg, err := config.LoadConfig(ctx, cfgFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load config", "error", err)
+			os.Exit(1)
+		}
+
+		filter, err := manifest.ParseLabels(listLabels)
+		if err != nil {
+			slog.ErrorContext(ctx, "Invalid label", "error", err)
+			os.Exit(1)
+		}
+
+		dump, err := newDumpster(ctx, cfg)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize storage", "error", err)
+			os.Exit(1)
+		}
+
+		if listRefresh && cfg.Catalog.Enabled {
+			if err := dump.ReconcileCatalog(ctx); err != nil {
+				slog.ErrorContext(ctx, "Failed to reconcile catalog", "error", err)
+				os.Exit(1)
+			}
+		}
+
+		backups, err := dump.ListBackups(ctx, filter)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to list backups", "error", err)
+			os.Exit(1)
+		}
+
+		prices := cost.Prices{StoragePerGB: cfg.S3.StoragePricePerGB, RequestsPer1000: cfg.S3.RequestPricePer1000}
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		if listDetails {
+			_, _ = fmt.Fprintln(w, "TIMESTAMP\tSIZE\tDATABASES\tENGINE\tOBJECTS\tCOST/MONTH\tLABELS")
+		} else {
+			_, _ = fmt.Fprintln(w, "TIMESTAMP\tSIZE\tDATABASES\tLABELS")
+		}
+		for _, b := range backups {
+			switch {
+			case b.Manifest == nil && listDetails:
+				_, _ = fmt.Fprintf(w, "%s\t-\t-\t-\t-\t-\t-\n", b.Timestamp)
+			case b.Manifest == nil:
+				_, _ = fmt.Fprintf(w, "%s\t-\t-\t-\n", b.Timestamp)
+			case listDetails:
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d\t%s\t%s\n", b.Timestamp, units.FormatBytes(b.Manifest.Size),
+					len(b.Manifest.Databases), engineFormat(b.Manifest), backupObjects(b.Manifest), cost.Format(prices.Storage(b.Manifest.Size)),
+					manifest.FormatLabels(b.Manifest.Labels))
+			default:
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", b.Timestamp, units.FormatBytes(b.Manifest.Size),
+					len(b.Manifest.Databases), manifest.FormatLabels(b.Manifest.Labels))
+			}
+		}
+		_ = w.Flush()
+
+		if listDetails {
+			printCostEstimate(cmd.OutOrStdout(), cfg, backups)
+		}
+	},
+}
+
+func init() {
+	listCmd.Flags().StringArrayVar(&listLabels, "label", nil, "only list backups carrying this key=value label (repeatable)")
+	listCmd.Flags().BoolVar(&listDetails, "details", false, "show engine, dump format, object counts and estimated monthly storage costs")
+	listCmd.Flags().BoolVar(&listRefresh, "refresh", false, "reconcile the local catalog with storage before listing")
+	rootCmd.AddCommand(listCmd)
+}
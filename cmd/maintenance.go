@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/maintenance"
+	"github.com/spf13/cobra"
+)
+
+// maintenanceReason is the note recorded when maintenance mode is enabled.
+var maintenanceReason string
+
+var maintenanceCmd = &cobra.Command{
+	Use:   "maintenance",
+	Short: "Pause and resume scheduled backups",
+	Long: `Maintenance mode makes the daemon skip scheduled backups, e.g. while storage is being migrated.
+It is persisted in server.maintenance-file, so it survives restarts and is shared with a daemon
+using the same file. With the HTTP server enabled it can also be toggled with PUT and DELETE
+on /maintenance.`,
+}
+
+// setMaintenance switches maintenance mode and prints the new state.
+func setMaintenance(cmd *cobra.Command, enabled bool) {
+	ctx := cmd.Context()
+
+	cfg, err := config.LoadConfig(ctx, cfgFile)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to load config", "error", err)
+		os.Exit(1)
+	}
+
+	state, err := maintenance.Set(cfg.Server.MaintenanceFile, enabled, maintenanceReason)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to save maintenance state", "error", err)
+		os.Exit(1)
+	}
+	printMaintenance(cmd, state)
+}
+
+func printMaintenance(cmd *cobra.Command, state maintenance.State) {
+	enc := json.NewEncoder(cmd.OutOrStdout())
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(state)
+}
+
+var maintenanceEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Enable maintenance mode, skipping scheduled backups",
+	Run: func(cmd *cobra.Command, _ []string) {
+		setMaintenance(cmd, true)
+	},
+}
+
+var maintenanceDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Disable maintenance mode, resuming scheduled backups",
+	Run: func(cmd *cobra.Command, _ []string) {
+		setMaintenance(cmd, false)
+	},
+}
+
+var maintenanceStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether maintenance mode is on",
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+
+		cfg, err := config.LoadConfig(ctx, cfgFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load config", "error", err)
+			os.Exit(1)
+		}
+
+		state, err := maintenance.Load(cfg.Server.MaintenanceFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to read maintenance state", "error", err)
+			os.Exit(1)
+		}
+		printMaintenance(cmd, state)
+	},
+}
+
+func init() {
+	maintenanceEnableCmd.Flags().StringVar(&maintenanceReason, "reason", "", "note recorded with the maintenance state")
+	maintenanceCmd.AddCommand(maintenanceEnableCmd, maintenanceDisableCmd, maintenanceStatusCmd)
+	rootCmd.AddCommand(maintenanceCmd)
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+// manDir is where the man pages are written.
+var manDir string
+
+var manCmd = &cobra.Command{
+	Use:   "man",
+	Short: "Generate man pages for stashly and its subcommands",
+	Long: `Generate a man page for every stashly command from the command tree, e.g.
+
+  stashly man --dir /usr/local/share/man/man1 && mandb`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+
+		if err := os.MkdirAll(manDir, 0750); err != nil {
+			slog.ErrorContext(ctx, "Failed to create man page directory", "dir", manDir, "error", err)
+			os.Exit(1)
+		}
+
+		header := &doc.GenManHeader{Title: "STASHLY", Section: "1", Source: "Stashly"}
+		if err := doc.GenManTree(rootCmd, header, manDir); err != nil {
+			slog.ErrorContext(ctx, "Failed to generate man pages", "error", err)
+			os.Exit(1)
+		}
+		slog.InfoContext(ctx, "Man pages written", "dir", manDir)
+	},
+}
+
+func init() {
+	manCmd.Flags().StringVar(&manDir, "dir", "man", "directory to write the man pages to")
+	rootCmd.AddCommand(manCmd)
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/dumpster"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// migrateFrom is the location backups are copied from.
+	migrateFrom string
+
+	// migrateTo is the location backups are copied to.
+	migrateTo string
+
+	// migrateBackend is the backend both locations are on.
+	migrateBackend string
+
+	// migrateDryRun lists the backups that would be copied without copying them.
+	migrateDryRun bool
+)
+
+// errNestedLocations is returned when one of --from and --to lies within the other.
+var errNestedLocations = errors.New("--from and --to must not contain each other")
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate-prefix",
+	Short: "Copy backups to a new prefix or instance layout on the same storage backend",
+	Long: `Copy backups, with their manifests, from the --from location to the --to location of a storage
+backend (storage.backend by default), e.g. after changing s3.prefix or app.instance-id. A location is
+the full path backups are stored under, the backend's prefix and the instance ID joined, such as
+"backups/db-1". Backends that can copy objects themselves (S3) do so without downloading them; the
+others, and S3 objects over 5 GiB, are downloaded and uploaded again. Backups the target already has
+are skipped, and each backup's manifest is copied last, so an interrupted run can simply be run again.
+
+After copying, every backup found at both locations is checked to have the same number of files and
+bytes. The copied timestamps are printed, oldest first. The source backups are left in place; delete
+them once the new layout is in use.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+
+		// Load config
+		cfg, err := config.LoadConfig(ctx, cfgFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load config", "error", err)
+			os.Exit(1)
+		}
+
+		from, to := storage.BuildKey(migrateFrom), storage.BuildKey(migrateTo)
+		if strings.HasPrefix(from, to) || strings.HasPrefix(to, from) {
+			slog.ErrorContext(ctx, "Invalid locations", "from", from, "to", to, "error", errNestedLocations)
+			os.Exit(1)
+		}
+
+		backend := migrateBackend
+		if backend == "" {
+			backend = cfg.Storage.Backend
+		}
+		src, err := sourceDumpster(ctx, cfg.AtLocation(migrateFrom), backend)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize source storage", "backend", backend, "location", from, "error", err)
+			os.Exit(1)
+		}
+		dst, err := replicaDumpster(ctx, cfg.AtLocation(migrateTo), backend)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize target storage", "backend", backend, "location", to, "error", err)
+			os.Exit(1)
+		}
+
+		migrated, err := src.MigrateBackups(ctx, dst, dumpster.MigrateOptions{DryRun: migrateDryRun})
+		for _, ts := range migrated {
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), ts)
+		}
+		if !migrateDryRun {
+			dst.UpdateIndex(ctx)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Migration failed", "migrated", len(migrated), "error", err)
+			os.Exit(1)
+		}
+		if migrateDryRun {
+			slog.InfoContext(ctx, "Dry run; nothing copied", "backups", len(migrated))
+			return
+		}
+		slog.InfoContext(ctx, "Migration completed successfully", "backups", len(migrated), "from", from, "to", to)
+	},
+}
+
+func init() {
+	migrateCmd.Flags().StringVar(&migrateFrom, "from", "", "location to copy backups from, e.g. old-prefix/old-instance")
+	migrateCmd.Flags().StringVar(&migrateTo, "to", "", "location to copy backups to, e.g. new-prefix/new-instance")
+	migrateCmd.Flags().StringVar(&migrateBackend, "backend", "", "backend the locations are on (default storage.backend)")
+	migrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "list the backups that would be copied without copying them")
+	_ = migrateCmd.MarkFlagRequired("from")
+	_ = migrateCmd.MarkFlagRequired("to")
+	rootCmd.AddCommand(migrateCmd)
+}
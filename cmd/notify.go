@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/notifiers"
+	"github.com/spf13/cobra"
+)
+
+// notifyTestEvent is the event the test notification is sent for.
+var notifyTestEvent string
+
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "Manage notifiers",
+}
+
+var notifyTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Send a sample notification through every enabled notifier",
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+
+		// Load config
+		cfg, err := config.LoadConfig(ctx, cfgFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load config", "error", err)
+			os.Exit(1)
+		}
+
+		notify, err := newNotifier(cfg)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize notifiers", "error", err)
+			os.Exit(1)
+		}
+
+		results, err := notify.Test(ctx, notifyTestEvent)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to send test notification", "error", err)
+			os.Exit(1)
+		}
+
+		out := cmd.OutOrStdout()
+		delivered, failed := 0, 0
+		for _, r := range results {
+			switch {
+			case !r.Enabled:
+				_, _ = fmt.Fprintf(out, "%s: disabled\n", r.Notifier)
+			case r.Err != nil:
+				failed++
+				_, _ = fmt.Fprintf(out, "%s: FAILED: %v\n", r.Notifier, r.Err)
+			default:
+				delivered++
+				_, _ = fmt.Fprintf(out, "%s: delivered\n", r.Notifier)
+			}
+		}
+
+		if failed > 0 || delivered == 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	notifyTestCmd.Flags().StringVar(&notifyTestEvent, "event", "success",
+		"event to send a sample of ("+strings.Join(notifiers.TestEvents, ", ")+")")
+	notifyCmd.AddCommand(notifyTestCmd)
+	rootCmd.AddCommand(notifyCmd)
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/dumpster"
+	"github.com/spf13/cobra"
+)
+
+// purgeForce allows a purge over backup.purge-max-percent.
+var purgeForce bool
+
+var purgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Apply the retention policy now, deleting expired backups",
+	Long: `Delete the backups the retention policy expires, as every backup run does afterwards, and print
+their timestamps. A purge that would delete more than backup.purge-max-percent of the backups at once
+is refused unless --force is passed, e.g. after lowering backup.retention-count. The newest backup is
+never deleted, with or without --force.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+
+		// Load config
+		cfg, err := config.LoadConfig(ctx, cfgFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load config", "error", err)
+			os.Exit(1)
+		}
+
+		dump, err := newDumpster(ctx, cfg)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize storage", "error", err)
+			os.Exit(1)
+		}
+
+		purged, err := dump.PurgeDumps(ctx, dumpster.PurgeOptions{Force: purgeForce})
+		if len(purged) > 0 {
+			dump.UpdateIndex(ctx)
+		}
+		for _, ts := range purged {
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), ts)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Purge failed", "deleted", len(purged), "error", err)
+			os.Exit(1)
+		}
+		slog.InfoContext(ctx, "Purge completed successfully", "deleted", len(purged))
+	},
+}
+
+func init() {
+	purgeCmd.Flags().BoolVar(&purgeForce, "force", false, "allow deleting more than backup.purge-max-percent of the backups")
+	rootCmd.AddCommand(purgeCmd)
+}
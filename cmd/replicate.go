@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/dumpster"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// replicateFrom is the backend backups are copied from.
+	replicateFrom string
+
+	// replicateTo is the backend backups are copied to.
+	replicateTo string
+
+	// replicateLatest limits replication to the newest N backups.
+	replicateLatest int
+
+	// replicateDryRun lists the backups that would be copied without copying them.
+	replicateDryRun bool
+)
+
+// errSameBackend is returned when --from and --to name the same backend.
+var errSameBackend = errors.New("--from and --to must name different backends")
+
+// replicaDumpster creates a dumpster on the named, initialised backend. Only the configured backend
+// keeps the local catalog up to date; it describes that backend's backups and no other's.
+func replicaDumpster(ctx context.Context, cfg *config.Config, name string) (*dumpster.Dumpster, error) {
+	store, err := storage.New(cfg, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Init(ctx); err != nil {
+		return nil, err
+	}
+
+	c := *cfg
+	c.Catalog.Enabled = cfg.Catalog.Enabled && name == cfg.Storage.Backend
+	return dumpster.NewDumpster(&c, store, exec.NewExec())
+}
+
+// sourceDumpster creates a dumpster to copy backups from, on the named, initialised backend. The
+// configured backend includes the backups that failed over to storage.secondary, so they are copied
+// too.
+func sourceDumpster(ctx context.Context, cfg *config.Config, name string) (*dumpster.Dumpster, error) {
+	if name != cfg.Storage.Backend || cfg.Storage.Secondary == "" {
+		return replicaDumpster(ctx, cfg, name)
+	}
+	return newDumpster(ctx, cfg)
+}
+
+var replicateCmd = &cobra.Command{
+	Use:   "replicate",
+	Short: "Copy backups from one configured storage backend to another",
+	Long: `Copy backups, with their manifests, from the --from backend (storage.backend by default) to the
+--to backend, e.g. to seed a new bucket or mirror S3 to local storage. Both backends are configured as
+usual in the config file. Backups the target already has are skipped, and each backup's manifest is
+copied last, so an interrupted run can simply be run again. The copied timestamps are printed, oldest
+first. Encrypted backups stay encrypted.
+
+To copy backups to a local directory instead, use "stashly export".`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+
+		// Load config
+		cfg, err := config.LoadConfig(ctx, cfgFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load config", "error", err)
+			os.Exit(1)
+		}
+
+		from := replicateFrom
+		if from == "" {
+			from = cfg.Storage.Backend
+		}
+		if from == replicateTo {
+			slog.ErrorContext(ctx, "Invalid backends", "error", errSameBackend)
+			os.Exit(1)
+		}
+
+		src, err := sourceDumpster(ctx, cfg, from)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize source storage", "backend", from, "error", err)
+			os.Exit(1)
+		}
+		dst, err := replicaDumpster(ctx, cfg, replicateTo)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize target storage", "backend", replicateTo, "error", err)
+			os.Exit(1)
+		}
+
+		replicated, err := src.ReplicateBackups(ctx, dst, dumpster.ReplicateOptions{
+			Latest: replicateLatest,
+			DryRun: replicateDryRun,
+		})
+		for _, ts := range replicated {
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), ts)
+		}
+		if !replicateDryRun {
+			dst.UpdateIndex(ctx)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Replication failed", "replicated", len(replicated), "error", err)
+			os.Exit(1)
+		}
+		if replicateDryRun {
+			slog.InfoContext(ctx, "Dry run; nothing copied", "backups", len(replicated))
+			return
+		}
+		slog.InfoContext(ctx, "Replication completed successfully", "backups", len(replicated), "from", from, "to", replicateTo)
+	},
+}
+
+func init() {
+	replicateCmd.Flags().StringVar(&replicateFrom, "from", "", "backend to copy backups from (default storage.backend)")
+	replicateCmd.Flags().StringVar(&replicateTo, "to", "", "backend to copy backups to (s3, gcs, azblob, webdav, ftp or rclone)")
+	replicateCmd.Flags().IntVar(&replicateLatest, "latest", 0, "only copy the newest N backups (0 copies all)")
+	replicateCmd.Flags().BoolVar(&replicateDryRun, "dry-run", false, "list the backups that would be copied without copying them")
+	_ = replicateCmd.MarkFlagRequired("to")
+	rootCmd.AddCommand(replicateCmd)
+}
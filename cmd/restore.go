@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/dumpster"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// restoreForce loads dumps into databases that already hold tables.
+	restoreForce bool
+
+	// restoreDropExisting drops databases that already exist before restoring them.
+	restoreDropExisting bool
+
+	// restorePrivateKeyFile overrides encryption.gpg.private-key-file for this restore.
+	restorePrivateKeyFile string
+
+	// restoreGPGAgent decrypts with the local gpg keyring and agent for this restore.
+	restoreGPGAgent bool
+)
+
+// printNonEmptyTargets writes the tables a restore would overwrite.
+func printNonEmptyTargets(out io.Writer, e *dumpster.TargetNotEmptyError) {
+	databases := make([]string, 0, len(e.Tables))
+	for db := range e.Tables {
+		databases = append(databases, db)
+	}
+	sort.Strings(databases)
+
+	_, _ = fmt.Fprintln(out, "Refusing to restore over databases that already hold data:")
+	for _, db := range databases {
+		var rows int64
+		for _, t := range e.Tables[db] {
+			rows += t.Rows
+		}
+		_, _ = fmt.Fprintf(out, "  %s: %d tables, ~%d rows\n", db, len(e.Tables[db]), rows)
+		for _, t := range e.Tables[db] {
+			_, _ = fmt.Fprintf(out, "    %s (~%d rows)\n", t.Name, t.Rows)
+		}
+	}
+	_, _ = fmt.Fprintln(out, "Re-run with --drop-existing to replace these databases, or --force to load the backup into them as they are.")
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore [timestamp]",
+	Short: "Restore a backup (the latest one if no timestamp is given)",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		// Load config
+		cfg, err := config.LoadConfig(ctx, cfgFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load config", "error", err)
+			os.Exit(1)
+		}
+
+		switch {
+		case restorePrivateKeyFile != "":
+			cfg.Encryption.GPG.PrivateKeyFile, cfg.Encryption.GPG.Agent = restorePrivateKeyFile, false
+		case restoreGPGAgent:
+			cfg.Encryption.GPG.PrivateKeyFile, cfg.Encryption.GPG.Agent = "", true
+		}
+
+		timestamp := ""
+		if len(args) > 0 {
+			timestamp = args[0]
+		}
+
+		notify, err := newNotifier(cfg)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize notifiers", "error", err)
+			os.Exit(1)
+		}
+
+		opts := dumpster.RestoreOptions{Force: restoreForce, DropExisting: restoreDropExisting}
+		resp, err := doRestore(ctx, cfg, notify, timestamp, opts)
+		if err != nil {
+			var nonEmpty *dumpster.TargetNotEmptyError
+			if errors.As(err, &nonEmpty) {
+				printNonEmptyTargets(cmd.ErrOrStderr(), nonEmpty)
+			}
+			slog.ErrorContext(ctx, "Restore failed", "error", err)
+			os.Exit(1)
+		}
+		slog.InfoContext(ctx, "Restore completed successfully", "timestamp", resp.Timestamp, "databases", resp.Databases, "duration", resp.Duration)
+	},
+}
+
+func init() {
+	restoreCmd.Flags().BoolVar(&restoreForce, "force", false, "load the backup into databases that already hold tables")
+	restoreCmd.Flags().BoolVar(&restoreDropExisting, "drop-existing", false, "drop existing databases before restoring them")
+	restoreCmd.MarkFlagsMutuallyExclusive("force", "drop-existing")
+	restoreCmd.Flags().StringVar(&restorePrivateKeyFile, "private-key-file", "", "decrypt with this armored GPG private key instead of encryption.gpg.private-key-file")
+	restoreCmd.Flags().BoolVar(&restoreGPGAgent, "gpg-agent", false, "decrypt with the local gpg keyring and gpg-agent")
+	restoreCmd.MarkFlagsMutuallyExclusive("private-key-file", "gpg-agent")
+	rootCmd.AddCommand(restoreCmd)
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/dumpster"
+	"github.com/hibare/stashly/internal/manifest"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// rollbackLabel holds the deploy ID of the snapshot to roll back to.
+	rollbackLabel string
+
+	// rollbackYes skips the confirmation prompt.
+	rollbackYes bool
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Restore the snapshot taken for a deploy",
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+
+		// Load config
+		cfg, err := config.LoadConfig(ctx, cfgFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load config", "error", err)
+			os.Exit(1)
+		}
+
+		dump, err := newDumpster(ctx, cfg)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize storage", "error", err)
+			os.Exit(1)
+		}
+
+		notify, err := newNotifier(cfg)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize notifiers", "error", err)
+			os.Exit(1)
+		}
+
+		backups, err := dump.ListBackups(ctx, map[string]string{manifest.SnapshotLabel: rollbackLabel})
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to list backups", "error", err)
+			os.Exit(1)
+		}
+		if len(backups) == 0 {
+			slog.ErrorContext(ctx, "No snapshot found for label", "label", rollbackLabel)
+			os.Exit(1)
+		}
+
+		// Newest first; a deploy ID retried in CI may have several snapshots.
+		target := backups[0]
+		out := cmd.OutOrStdout()
+		_, _ = fmt.Fprintf(out, "Rolling back to snapshot %s (label %s, taken %s)\n",
+			target.Timestamp, rollbackLabel, target.Manifest.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+		_, _ = fmt.Fprintf(out, "Databases that will be dropped and restored: %s\n", strings.Join(target.Manifest.Databases, ", "))
+
+		if !rollbackYes && !confirm(cmd.InOrStdin(), out, "Proceed with rollback?") {
+			_, _ = fmt.Fprintln(out, "Rollback aborted")
+			return
+		}
+
+		resp, err := dump.Restore(ctx, target.Timestamp, dumpster.RestoreOptions{DropExisting: true})
+		notifyRestore(ctx, notify, resp, err)
+		if err != nil {
+			slog.ErrorContext(ctx, "Rollback failed", "error", err)
+			os.Exit(1)
+		}
+		slog.InfoContext(ctx, "Rollback completed successfully", "timestamp", resp.Timestamp, "databases", resp.Databases, "duration", resp.Duration)
+	},
+}
+
+func init() {
+	rollbackCmd.Flags().StringVar(&rollbackLabel, "label", "", "deploy ID of the snapshot to restore")
+	rollbackCmd.Flags().BoolVarP(&rollbackYes, "yes", "y", false, "skip the confirmation prompt")
+	_ = rollbackCmd.MarkFlagRequired("label")
+	rootCmd.AddCommand(rollbackCmd)
+}
@@ -5,18 +5,23 @@ import (
 	"context"
 	"log/slog"
 	"os"
-	"time"
 
-	"github.com/go-co-op/gocron"
 	"github.com/spf13/cobra"
 
 	commonLogger "github.com/hibare/GoCommon/v2/pkg/logger"
 	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/dumpster"
+	"github.com/hibare/stashly/internal/kubejob"
+	"github.com/hibare/stashly/internal/maintenance"
+	"github.com/hibare/stashly/internal/scheduler"
 )
 
 // cfgFile holds the path to the config file.
 var cfgFile string
 
+// bootstrapRestore restores the latest backup into an empty cluster before scheduling starts.
+var bootstrapRestore bool
+
 // rootCmd represents the base command when called without any subcommands.
 var rootCmd = &cobra.Command{
 	Use:   "stashly",
@@ -31,7 +36,7 @@ With Stashly, you can:
   - Get notified of backup failures through integrated notifiers
   - Run in the background as a long-lived process.`,
 	Run: func(cmd *cobra.Command, _ []string) {
-		// start cron job that runs Dump according to config.
+		// start cron job that runs backups according to config, or run a single backup in run-once mode.
 		// cron runs in background; block forever.
 		ctx := cmd.Context()
 
@@ -42,19 +47,98 @@ With Stashly, you can:
 			os.Exit(1)
 		}
 
-		slog.InfoContext(ctx, "Starting scheduled backup", "cron", cfg.Backup.Cron)
-		scheduler := gocron.NewScheduler(time.UTC)
-		_, err = scheduler.Cron(cfg.Backup.Cron).Do(func() {
-			if bErr := doBackup(ctx, cfg); bErr != nil {
-				slog.ErrorContext(ctx, "Scheduled backup failed", "error", bErr)
-			} else {
-				slog.InfoContext(ctx, "Scheduled backup completed successfully")
+		if cfg.App.UpdateCheck {
+			go warnIfOutdated(ctx, cfg)
+		}
+
+		// Notifiers are shared across runs so duplicate failures can be suppressed.
+		notify, err := newNotifier(cfg)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize notifiers", "error", err)
+			os.Exit(1)
+		}
+
+		if bootstrapRestore {
+			if bErr := doBootstrapRestore(ctx, cfg, notify); bErr != nil {
+				slog.ErrorContext(ctx, "Bootstrap restore failed", "error", bErr)
+				os.Exit(1)
 			}
-		})
+		}
+
+		if cfg.Backup.Mode == config.ModeOnce {
+			slog.InfoContext(ctx, "Running a single backup", "mode", cfg.Backup.Mode, "fleet_hosts", len(cfg.Postgres.Fleet))
+			if stored, _ := runBackups(ctx, cmd.OutOrStdout(), cfg, notify, dumpster.DumpOptions{}); !stored {
+				os.Exit(1)
+			}
+			return
+		}
+
+		// Backup Jobs discover the fleet themselves; only the daemon's own runs use the watched inventory.
+		provider, err := newDiscovery(ctx, cfg, !cfg.Kubernetes.Enabled)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize discovery", "error", err)
+			os.Exit(1)
+		}
+
+		runBackup := func(ctx context.Context) error {
+			if isFleet(cfg) {
+				_, bErr := doFleetBackup(ctx, cfg, provider, notify, dumpster.DumpOptions{})
+				return bErr
+			}
+			_, bErr := doBackup(ctx, cfg, notify, dumpster.DumpOptions{})
+			return bErr
+		}
+
+		if cfg.Kubernetes.Enabled {
+			runner, rErr := kubejob.NewInClusterRunner(&cfg.Kubernetes)
+			if rErr != nil {
+				slog.ErrorContext(ctx, "Failed to initialize kubernetes job runner", "error", rErr)
+				os.Exit(1)
+			}
+			runBackup = func(ctx context.Context) error { return doBackupJob(ctx, runner, notify) }
+		}
+
+		slog.InfoContext(ctx, "Starting scheduled backup", "cron", cfg.Backup.Cron, "kubernetes", cfg.Kubernetes.Enabled)
+		sched, err := scheduler.New(ctx, cfg.Backup.Cron, runBackup)
 		if err != nil {
 			slog.ErrorContext(ctx, "Failed to schedule backup", "error", err)
+			os.Exit(1)
+		}
+
+		sched.PauseWhen(func(ctx context.Context) bool {
+			return maintenance.Enabled(ctx, cfg.Server.MaintenanceFile)
+		})
+		if maintenance.Enabled(ctx, cfg.Server.MaintenanceFile) {
+			slog.WarnContext(ctx, "Maintenance mode is on; scheduled backups are skipped until it is disabled")
 		}
-		scheduler.StartBlocking()
+
+		if cfg.Backup.MaxRunDuration > 0 {
+			go sched.Watch(ctx, cfg.Backup.MaxRunDuration, cfg.Backup.WatchdogRestart)
+		}
+
+		if cfg.Server.Enabled || cfg.Backup.RPOThreshold > 0 || cfg.Restore.RTOThreshold > 0 {
+			go watchObjectives(ctx, cfg, provider, notify)
+		}
+
+		switch {
+		case cfg.Restore.Drill.Cron == "":
+		case isFleet(cfg):
+			slog.WarnContext(ctx, "Restore drills don't support fleets; not scheduling them")
+		default:
+			slog.InfoContext(ctx, "Scheduling restore drills", "cron", cfg.Restore.Drill.Cron, "host", cfg.Restore.Drill.Host)
+			go runDrills(ctx, cfg, notify)
+		}
+
+		if cfg.Server.Enabled {
+			srv := newServer(ctx, cfg, sched)
+			go func() {
+				if sErr := srv.ListenAndServe(ctx); sErr != nil {
+					slog.ErrorContext(ctx, "HTTP server stopped", "error", sErr)
+				}
+			}()
+		}
+
+		sched.StartBlocking()
 	},
 }
 
@@ -71,5 +155,6 @@ func init() {
 	ctx := context.Background()
 	rootCmd.SetContext(ctx)
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is /etc/stashly/config.yaml)")
+	rootCmd.Flags().BoolVar(&bootstrapRestore, "bootstrap-restore", false, "restore the latest backup if the database cluster is empty before starting the schedule")
 	cobra.OnInitialize(commonLogger.InitDefaultLogger)
 }
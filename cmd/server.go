@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/hibare/stashly/internal/audit"
+	"github.com/hibare/stashly/internal/catalog"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/maintenance"
+	"github.com/hibare/stashly/internal/metrics"
+	"github.com/hibare/stashly/internal/scheduler"
+	"github.com/hibare/stashly/internal/server"
+	"github.com/hibare/stashly/internal/storage"
+)
+
+// storageProbeTimeout bounds each storage readiness probe, initialisation included.
+const storageProbeTimeout = 5 * time.Second
+
+var errSchedulerNotRunning = errors.New("scheduler is not running")
+
+// recordCancellation records a backup job cancelled through the API in the catalog file, whether or
+// not catalog.enabled is set, as drills are. In encryption compliance mode it is also appended to the
+// audit log.
+func recordCancellation(ctx context.Context, cfg *config.Config, id uint64, remoteAddr string) {
+	now := time.Now().UTC()
+	c, err := catalog.Load(cfg.Catalog.Path, cfg.App.InstanceID)
+	if err == nil {
+		c.RecordCancellation(catalog.Cancellation{JobID: id, CancelledAt: now, RemoteAddr: remoteAddr})
+		err = c.Save()
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to record cancelled backup job", "job_id", id, "path", cfg.Catalog.Path, "error", err)
+	}
+
+	if !cfg.Encryption.Compliance {
+		return
+	}
+	err = audit.Append(cfg.Encryption.AuditLog, audit.Entry{
+		Time:       now,
+		Instance:   cfg.App.InstanceID,
+		Event:      audit.EventCancelled,
+		JobID:      id,
+		RemoteAddr: remoteAddr,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to record cancelled backup job in the audit log", "job_id", id, "path", cfg.Encryption.AuditLog, "error", err)
+	}
+}
+
+// newServer creates the daemon HTTP server with readiness checks for config, storage and the scheduler.
+func newServer(ctx context.Context, cfg *config.Config, sched *scheduler.Scheduler) *server.Server {
+	srv := server.New(cfg.Server.ListenAddr)
+	srv.Handle("GET /metrics", metrics.Handler())
+	srv.Handle("/maintenance", maintenance.NewHandler(cfg.Server.MaintenanceFile, cfg.Server.AdminToken))
+	srv.Handle("DELETE /jobs/{id}", server.RequireToken(cfg.Server.AdminToken, scheduler.NewCancelHandler(sched,
+		func(ctx context.Context, id uint64, remoteAddr string) { recordCancellation(ctx, cfg, id, remoteAddr) })))
+
+	// Config was loaded and validated before the server started; report what's in effect.
+	srv.AddReadinessCheck("config", func(context.Context) (any, error) {
+		return map[string]any{
+			"instance_id": cfg.App.InstanceID,
+			"cron":        cfg.Backup.Cron,
+			"encrypt":     cfg.Backup.Encrypt,
+			"kubernetes":  cfg.Kubernetes.Enabled,
+		}, nil
+	})
+
+	// Storage is initialised by the first probe that manages to, so readiness recovers once the
+	// backend becomes reachable. Probes list a single key and aren't retried, to stay cheap and fail fast.
+	store, backendErr := newBackend(cfg)
+	var (
+		initMu      sync.Mutex
+		initialized bool
+	)
+	probe := func(ctx context.Context) error {
+		if backendErr != nil {
+			return backendErr
+		}
+		ctx, cancel := context.WithTimeout(storage.WithoutRetries(ctx), storageProbeTimeout)
+		defer cancel()
+
+		initMu.Lock()
+		if !initialized {
+			if err := store.Init(ctx); err != nil {
+				initMu.Unlock()
+				return err
+			}
+			initialized = true
+		}
+		initMu.Unlock()
+
+		_, err := store.ListPage(ctx, storage.ListOptions{Limit: 1})
+		return err
+	}
+	if err := probe(ctx); err != nil {
+		slog.WarnContext(ctx, "Storage is not ready", "error", err)
+	}
+	srv.AddReadinessCheck("storage", func(ctx context.Context) (any, error) {
+		if err := probe(ctx); err != nil {
+			return nil, err
+		}
+		return map[string]string{"backend": store.Name()}, nil
+	})
+
+	srv.AddReadinessCheck("scheduler", func(context.Context) (any, error) {
+		status := sched.Status()
+		if !status.Running {
+			return status, errSchedulerNotRunning
+		}
+		return status, nil
+	})
+
+	return srv
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hibare/stashly/internal/audit"
+	"github.com/hibare/stashly/internal/catalog"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/scheduler"
+	"github.com/hibare/stashly/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func storageReadiness(t *testing.T, srv *server.Server) server.CheckResult {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var body struct {
+		Checks map[string]server.CheckResult `json:"checks"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return body.Checks["storage"]
+}
+
+func TestNewServer_StorageReadinessRetriesInit(t *testing.T) {
+	ctx := context.Background()
+	testStore = &memStore{base: "db-1", objects: map[string][]byte{}, initErr: errors.New("connection refused")}
+
+	cfg := &config.Config{App: config.AppConfig{InstanceID: "db-1"}, Storage: config.StorageConfig{Backend: "memory"}}
+	sched, err := scheduler.New(ctx, "0 0 * * *", func(context.Context) error { return nil })
+	require.NoError(t, err)
+
+	srv := newServer(ctx, cfg, sched)
+
+	result := storageReadiness(t, srv)
+	assert.Equal(t, "fail", result.Status)
+	assert.Equal(t, "connection refused", result.Error)
+
+	// The backend comes up after the daemon started.
+	testStore.mu.Lock()
+	testStore.initErr = nil
+	testStore.mu.Unlock()
+
+	result = storageReadiness(t, srv)
+	assert.Equal(t, "ok", result.Status)
+	assert.Equal(t, []int{1}, testStore.limits)
+}
+
+func TestRecordCancellation(t *testing.T) {
+	cfg := &config.Config{
+		App:     config.AppConfig{InstanceID: "db-1"},
+		Catalog: config.CatalogConfig{Path: filepath.Join(t.TempDir(), "catalog.json")},
+	}
+
+	recordCancellation(context.Background(), cfg, 7, "10.0.0.1:4321")
+
+	c, err := catalog.Load(cfg.Catalog.Path, "db-1")
+	require.NoError(t, err)
+	require.Len(t, c.Cancellations, 1)
+	assert.Equal(t, uint64(7), c.Cancellations[0].JobID)
+	assert.Equal(t, "10.0.0.1:4321", c.Cancellations[0].RemoteAddr)
+	assert.False(t, c.Cancellations[0].CancelledAt.IsZero())
+}
+
+func TestRecordCancellation_Audit(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{
+		App:        config.AppConfig{InstanceID: "db-1"},
+		Catalog:    config.CatalogConfig{Path: filepath.Join(dir, "catalog.json")},
+		Encryption: config.Encryption{Compliance: true, AuditLog: filepath.Join(dir, "audit.log")},
+	}
+
+	recordCancellation(context.Background(), cfg, 7, "10.0.0.1:4321")
+
+	data, err := os.ReadFile(cfg.Encryption.AuditLog)
+	require.NoError(t, err)
+	var e audit.Entry
+	require.NoError(t, json.Unmarshal(data, &e))
+	assert.Equal(t, audit.EventCancelled, e.Event)
+	assert.Equal(t, "db-1", e.Instance)
+	assert.Equal(t, uint64(7), e.JobID)
+	assert.Equal(t, "10.0.0.1:4321", e.RemoteAddr)
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/dumpster"
+	"github.com/hibare/stashly/internal/manifest"
+	"github.com/hibare/stashly/internal/notifiers"
+	"github.com/spf13/cobra"
+)
+
+// snapshotLabel holds the deploy ID the snapshot is labeled with.
+var snapshotLabel string
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Take a pre-deploy snapshot kept outside the normal retention count",
+	Long: `Take a backup labeled with a deploy ID, intended to be called from CI before deployments.
+
+Snapshots don't count towards backup.retention-count and are purged once older than
+backup.snapshot-ttl. On success the backup's timestamp is printed to stdout, for use
+with "stashly restore". With postgres.fleet or discovery.url, every host is snapshotted
+and each stored snapshot is printed as "<host> <timestamp>".`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+
+		// Load config
+		cfg, err := config.LoadConfig(ctx, cfgFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load config", "error", err)
+			os.Exit(1)
+		}
+
+		notify, err := newNotifier(cfg)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize notifiers", "error", err)
+			os.Exit(1)
+		}
+
+		opts := dumpster.DumpOptions{
+			Labels:   map[string]string{manifest.SnapshotLabel: snapshotLabel},
+			Snapshot: true,
+		}
+
+		slog.InfoContext(ctx, "Taking snapshot", "label", snapshotLabel)
+		if isFleet(cfg) {
+			snapshotFleet(cmd, cfg, notify, opts)
+			return
+		}
+		resp, err := doBackup(ctx, cfg, notify, opts)
+		if resp == nil {
+			slog.ErrorContext(ctx, "Snapshot failed", "error", err)
+			os.Exit(1)
+		}
+		if err != nil {
+			// The snapshot itself was stored; only purging old backups failed.
+			slog.WarnContext(ctx, "Snapshot stored but purging old backups failed", "error", err)
+		}
+
+		slog.InfoContext(ctx, "Snapshot completed successfully", "key", resp.StorageKey, "timestamp", resp.Timestamp)
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), resp.Timestamp)
+	},
+}
+
+func init() {
+	snapshotCmd.Flags().StringVar(&snapshotLabel, "label", "", "deploy ID to label the snapshot with")
+	_ = snapshotCmd.MarkFlagRequired("label")
+	rootCmd.AddCommand(snapshotCmd)
+}
+
+// snapshotFleet snapshots every fleet host, printing each stored snapshot as "<host> <timestamp>". It
+// exits non-zero unless every host was snapshotted.
+func snapshotFleet(cmd *cobra.Command, cfg *config.Config, notify notifiers.NotifierStoreIface, opts dumpster.DumpOptions) {
+	ctx := cmd.Context()
+	provider, err := newDiscovery(ctx, cfg, false)
+	if err != nil {
+		slog.ErrorContext(ctx, "Snapshot failed", "error", err)
+		os.Exit(1)
+	}
+	results, err := doFleetBackup(ctx, cfg, provider, notify, opts)
+	failed := false
+	for _, r := range results {
+		if r.Resp == nil {
+			failed = true
+			continue
+		}
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), r.Host, r.Resp.Timestamp)
+	}
+	if failed || len(results) == 0 {
+		slog.ErrorContext(ctx, "Snapshot failed", "error", err)
+		os.Exit(1)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Snapshots stored but purging old backups failed", "error", err)
+	}
+	slog.InfoContext(ctx, "Snapshot completed successfully", "hosts", len(results))
+}
@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/discovery"
+	"github.com/hibare/stashly/internal/dumpster"
+	"github.com/hibare/stashly/internal/metrics"
+	"github.com/hibare/stashly/internal/notifiers"
+	"github.com/hibare/stashly/internal/notifiers/events"
+	"github.com/spf13/cobra"
+)
+
+// objectiveCheckInterval is how often the daemon measures the recovery objectives.
+const objectiveCheckInterval = 15 * time.Minute
+
+// instanceObjectives are the recovery objectives measured for one instance.
+type instanceObjectives struct {
+	Instance   string
+	Objectives *dumpster.Objectives
+	Err        error
+}
+
+// objectiveTargets returns the configs of the instances whose objectives are measured: each host of
+// the fleet, or cfg itself.
+func objectiveTargets(ctx context.Context, cfg *config.Config, provider discovery.ProviderIface) ([]*config.Config, error) {
+	if !isFleet(cfg) {
+		return []*config.Config{cfg}, nil
+	}
+	cfg, err := withDiscovered(ctx, cfg, provider)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]*config.Config, 0, len(cfg.Postgres.Fleet))
+	for _, host := range cfg.Postgres.Fleet {
+		targets = append(targets, cfg.ForHost(host))
+	}
+	return targets, nil
+}
+
+// measureObjectives measures the recovery objectives of every target and exports them as metrics.
+func measureObjectives(ctx context.Context, targets []*config.Config) []instanceObjectives {
+	results := make([]instanceObjectives, 0, len(targets))
+	for _, target := range targets {
+		result := instanceObjectives{Instance: target.App.InstanceID}
+		dump, err := newDumpster(ctx, target)
+		if err == nil {
+			result.Objectives, err = dump.Objectives(ctx)
+		}
+		result.Err = err
+		if o := result.Objectives; o != nil {
+			if o.LatestBackup != "" {
+				metrics.BackupRPO.WithLabelValues(result.Instance).Set(o.RPO.Seconds())
+			}
+			if o.RTO > 0 {
+				metrics.RestoreRTO.WithLabelValues(result.Instance).Set(o.RTO.Seconds())
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// violations returns the objectives of r that exceed their thresholds. An instance without backups
+// only violates its RPO once the threshold has passed since since.
+func violations(cfg *config.Config, r instanceObjectives, since time.Time) []events.ObjectiveViolated {
+	o := r.Objectives
+	if o == nil {
+		return nil
+	}
+
+	var out []events.ObjectiveViolated
+	if threshold := cfg.Backup.RPOThreshold; threshold > 0 {
+		if (o.LatestBackup != "" && o.RPO > threshold) || (o.LatestBackup == "" && time.Since(since) > threshold) {
+			out = append(out, events.ObjectiveViolated{Objective: events.ObjectiveRPO, Instance: r.Instance, Value: o.RPO, Threshold: threshold})
+		}
+	}
+	if threshold := cfg.Restore.RTOThreshold; threshold > 0 && o.RTO > threshold {
+		out = append(out, events.ObjectiveViolated{Objective: events.ObjectiveRTO, Instance: r.Instance, Value: o.RTO, Threshold: threshold})
+	}
+	return out
+}
+
+// watchObjectives measures the recovery objectives every objectiveCheckInterval until ctx is done. It
+// alerts once when an objective starts exceeding its threshold, and again only after it recovered.
+func watchObjectives(ctx context.Context, cfg *config.Config, provider discovery.ProviderIface, notify notifiers.NotifierStoreIface) {
+	started := time.Now()
+	alerted := map[string]bool{}
+	ticker := time.NewTicker(objectiveCheckInterval)
+	defer ticker.Stop()
+	for {
+		targets, err := objectiveTargets(ctx, cfg, provider)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to measure recovery objectives", "error", err)
+		}
+
+		violated := map[string]bool{}
+		for _, r := range measureObjectives(ctx, targets) {
+			if r.Err != nil {
+				slog.WarnContext(ctx, "Failed to measure recovery objectives", "instance", r.Instance, "error", r.Err)
+				continue
+			}
+			for _, v := range violations(cfg, r, started) {
+				key := v.Instance + "/" + v.Objective
+				violated[key] = true
+				if alerted[key] {
+					continue
+				}
+				slog.WarnContext(ctx, "Recovery objective exceeded", "objective", v.Objective, "instance", v.Instance, "value", v.Value, "threshold", v.Threshold)
+				if nErr := notify.NotifyObjectiveViolated(ctx, v); nErr != nil {
+					slog.ErrorContext(ctx, "Failed to send NotifyObjectiveViolated", "error", nErr)
+				}
+			}
+		}
+		alerted = violated
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// formatObjective renders a measured objective for the status table; "-" when there is none.
+func formatObjective(d time.Duration, measured bool) string {
+	if !measured {
+		return "-"
+	}
+	return d.Round(time.Minute).String()
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Report the recovery point and recovery time each instance achieves",
+	Long: `Report the recovery objectives of each instance, or of each fleet host:
+
+  RPO  the age of the newest backup, the most data a restore would lose
+  RTO  how long the last restore drill that passed took
+
+Objectives over backup.rpo-threshold or restore.rto-threshold are flagged. An instance without
+backups exceeds any RPO threshold. Exits non-zero if any objective is exceeded or can't be measured.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+
+		// Load config
+		cfg, err := config.LoadConfig(ctx, cfgFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load config", "error", err)
+			os.Exit(1)
+		}
+
+		provider, err := newDiscovery(ctx, cfg, false)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize discovery", "error", err)
+			os.Exit(1)
+		}
+		targets, err := objectiveTargets(ctx, cfg, provider)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to discover databases", "error", err)
+			os.Exit(1)
+		}
+
+		healthy := true
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "INSTANCE\tLATEST BACKUP\tRPO\tRTO\tSTATUS")
+		for _, r := range measureObjectives(ctx, targets) {
+			if r.Err != nil {
+				healthy = false
+				_, _ = fmt.Fprintf(w, "%s\t-\t-\t-\terror: %s\n", r.Instance, r.Err)
+				continue
+			}
+
+			o := r.Objectives
+			status := []string{}
+			for _, v := range violations(cfg, r, time.Time{}) {
+				status = append(status, fmt.Sprintf("%s over %s", v.Objective, v.Threshold))
+			}
+			if len(status) == 0 {
+				status = append(status, "ok")
+			} else {
+				healthy = false
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Instance, cmp.Or(o.LatestBackup, "-"),
+				formatObjective(o.RPO, o.LatestBackup != ""), formatObjective(o.RTO, o.RTO > 0), strings.Join(status, ", "))
+		}
+		_ = w.Flush()
+
+		if !healthy {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/remediation"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/hibare/stashly/internal/units"
+	"github.com/spf13/cobra"
+)
+
+var storageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Manage storage backends",
+}
+
+var storageTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Round-trip a small test object through the storage backend",
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+
+		// Load config
+		cfg, err := config.LoadConfig(ctx, cfgFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load config", "error", err)
+			os.Exit(1)
+		}
+
+		store, err := newStore(ctx, cfg)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize storage", "error", err)
+			os.Exit(1)
+		}
+
+		steps := storage.Check(ctx, store)
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "BACKEND\tOPERATION\tLATENCY\tRESULT")
+		granted, denied, hints := []string{}, []string{}, []string{}
+		failed := false
+		for _, s := range steps {
+			result, latency := "ok", s.Latency.Round(time.Millisecond).String()
+			switch {
+			case errors.Is(s.Err, storage.ErrCheckSkipped):
+				result, latency = "skipped", "-"
+			case s.Err != nil:
+				result, failed = "FAILED: "+s.Err.Error(), true
+				if s.Permission != "" {
+					denied = append(denied, s.Permission)
+				}
+				if hint, ok := remediation.For(s.Err); ok && !slices.Contains(hints, hint.Text) {
+					hints = append(hints, hint.Text)
+				}
+			case s.Permission != "":
+				granted = append(granted, s.Permission)
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", store.Name(), s.Operation, latency, result)
+		}
+		_ = w.Flush()
+
+		out := cmd.OutOrStdout()
+		_, _ = fmt.Fprintf(out, "\nPermissions granted: %s\n", joinOrNone(granted))
+		if len(denied) > 0 {
+			_, _ = fmt.Fprintf(out, "Permissions missing: %s\n", strings.Join(denied, ", "))
+		}
+		for _, hint := range hints {
+			_, _ = fmt.Fprintf(out, "Hint: %s\n", hint)
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+var storageUsageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Report the space backups take up in storage",
+	Long: `Report the space the backups of this instance take up in storage, as stored: each backup's size
+and file count, oldest first, then their total. Sizes are summed from a listing of the storage, so
+they include files no manifest records, such as those of backups that failed part-way.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+
+		// Load config
+		cfg, err := config.LoadConfig(ctx, cfgFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load config", "error", err)
+			os.Exit(1)
+		}
+
+		store, err := newStore(ctx, cfg)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to initialize storage", "error", err)
+			os.Exit(1)
+		}
+
+		usage, err := store.Stats(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to read storage usage", "error", err)
+			os.Exit(1)
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "TIMESTAMP\tSIZE\tFILES")
+		for _, b := range usage.Backups {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%d\n", b.Timestamp, units.FormatBytes(b.Bytes), b.Files)
+		}
+		_ = w.Flush()
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "\n%d backups, %s stored in %s\n", usage.Count, units.FormatBytes(usage.Bytes), store.Name())
+	},
+}
+
+// joinOrNone joins items with commas, or returns "none" if there are none.
+func joinOrNone(items []string) string {
+	if len(items) == 0 {
+		return "none"
+	}
+	return strings.Join(items, ", ")
+}
+
+func init() {
+	storageCmd.AddCommand(storageTestCmd)
+	storageCmd.AddCommand(storageUsageCmd)
+	rootCmd.AddCommand(storageCmd)
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hibare/stashly/internal/dumpster"
+)
+
+// printSummary writes a single machine-parsable key=value line describing a backup run.
+// It goes straight to out rather than through the logger so it is printed regardless of log level.
+func printSummary(out io.Writer, resp *dumpster.DumpResponse, err error, elapsed time.Duration) {
+	_, _ = fmt.Fprintln(out, "stashly: "+strings.Join(summaryFields(resp, err, elapsed), " "))
+}
+
+// printFleetSummary writes a summary line per fleet host, qualified with host=<name>, followed by a
+// line totalling the run.
+func printFleetSummary(out io.Writer, results []fleetResult, elapsed time.Duration) {
+	failed := 0
+	for _, r := range results {
+		if r.Resp == nil {
+			failed++
+		}
+		fields := append([]string{"host=" + strconv.Quote(r.Host)}, summaryFields(r.Resp, r.Err, r.Elapsed)...)
+		_, _ = fmt.Fprintln(out, "stashly: "+strings.Join(fields, " "))
+	}
+
+	status := "success"
+	switch {
+	case failed == len(results):
+		status = "failure"
+	case failed > 0:
+		status = "partial"
+	}
+	_, _ = fmt.Fprintf(out, "stashly: fleet status=%s hosts=%d failed=%d duration=%s\n",
+		status, len(results), failed, elapsed.Round(time.Second))
+}
+
+// summaryFields returns the key=value fields describing a backup run.
+func summaryFields(resp *dumpster.DumpResponse, err error, elapsed time.Duration) []string {
+	status := "success"
+	if resp == nil {
+		status = "failure"
+	} else if err != nil {
+		status = "partial"
+	}
+
+	fields := []string{"status=" + status}
+	if resp != nil {
+		fields = append(fields,
+			"databases="+strconv.Itoa(resp.ExportedDatabases),
+			"key="+strconv.Quote(resp.StorageKey),
+			"size="+strconv.FormatInt(resp.Size, 10),
+		)
+	}
+	fields = append(fields, "duration="+elapsed.Round(time.Second).String())
+	if err != nil {
+		fields = append(fields, "error="+strconv.Quote(err.Error()))
+	}
+	return fields
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/httpclient"
+	"github.com/hibare/stashly/internal/version"
+	"github.com/spf13/cobra"
+)
+
+// versionCheckTimeout bounds the release lookup so it never holds up a backup.
+const versionCheckTimeout = 10 * time.Second
+
+// versionCheck queries the latest release after printing build info.
+var versionCheck bool
+
+// latestRelease looks up the latest published release through the configured proxy and TLS settings.
+func latestRelease(ctx context.Context, cfg *config.Config) (*version.Release, error) {
+	client, err := httpclient.New(cfg, versionCheckTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return version.Latest(ctx, client, version.ReleasesURL)
+}
+
+// warnIfOutdated logs a warning when the running version is a minor or major release behind the latest one.
+func warnIfOutdated(ctx context.Context, cfg *config.Config) {
+	latest, err := latestRelease(ctx, cfg)
+	if err != nil {
+		slog.DebugContext(ctx, "Failed to check for a newer version", "error", err)
+		return
+	}
+	// Dev and other unversioned builds fail to parse and are never reported.
+	if outdated, oErr := version.Outdated(version.Version, latest.Version); oErr == nil && outdated {
+		slog.WarnContext(ctx, "Stashly is outdated; please upgrade", "version", version.Version, "latest", latest.Version, "url", latest.URL)
+	}
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print build information and optionally check for a newer release",
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+		out := cmd.OutOrStdout()
+
+		info := version.Info()
+		_, _ = fmt.Fprintf(out, "Version:    %s\n", info.Version)
+		_, _ = fmt.Fprintf(out, "Commit:     %s\n", info.Commit)
+		_, _ = fmt.Fprintf(out, "Built:      %s\n", info.Date)
+		_, _ = fmt.Fprintf(out, "Go version: %s\n", info.GoVersion)
+
+		if !versionCheck {
+			return
+		}
+
+		// Load config for the proxy and TLS settings.
+		cfg, err := config.LoadConfig(ctx, cfgFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load config", "error", err)
+			os.Exit(1)
+		}
+
+		latest, err := latestRelease(ctx, cfg)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to check for a newer version", "error", err)
+			os.Exit(1)
+		}
+
+		newer, err := version.Newer(info.Version, latest.Version)
+		switch {
+		case err != nil:
+			_, _ = fmt.Fprintf(out, "\nLatest release is %s; %s is not a release build (%s)\n", latest.Version, info.Version, latest.URL)
+		case newer:
+			_, _ = fmt.Fprintf(out, "\nA newer version is available: %s (%s)\n", latest.Version, latest.URL)
+		default:
+			_, _ = fmt.Fprintf(out, "\nStashly is up to date\n")
+		}
+	},
+}
+
+func init() {
+	versionCmd.Flags().BoolVar(&versionCheck, "check", false, "check GitHub for a newer release")
+	rootCmd.AddCommand(versionCmd)
+}
@@ -0,0 +1,68 @@
+// Package audit keeps an append-only log of the objects uploaded in encryption compliance mode, so
+// reviewers can check how every stored object was encrypted, and of the backup jobs cancelled.
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// EventCancelled marks an entry recording a backup job cancelled through the daemon's API.
+const EventCancelled = "job.cancelled"
+
+// Entry records one uploaded object, or with Event set, another event.
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Instance string    `json:"instance"`
+
+	// Event is empty for uploaded objects.
+	Event string `json:"event,omitempty"`
+
+	// Backup is the timestamp of the backup the object belongs to, Key where it was stored.
+	Backup  string `json:"backup,omitempty"`
+	Key     string `json:"key,omitempty"`
+	Storage string `json:"storage,omitempty"`
+	Size    int64  `json:"size,omitempty"`
+
+	// Algorithm is how the object is encrypted, e.g. "OpenPGP RSA-4096".
+	Algorithm string `json:"algorithm,omitempty"`
+
+	// KeyID is the ID of the key the object is encrypted to, Fingerprint the fingerprint of its
+	// primary key. The fingerprint is only known for the configured key.
+	KeyID       string `json:"key_id,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	// JobID is the scheduler's ID of a cancelled job, RemoteAddr the address of the caller that
+	// cancelled it.
+	JobID      uint64 `json:"job_id,omitempty"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+}
+
+// mu serialises appends, as fleet hosts are backed up side by side into the same log.
+var mu sync.Mutex
+
+// Append adds e to the log at path as one line of JSON, creating the log if it doesn't exist.
+func Append(path string, e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
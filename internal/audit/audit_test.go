@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stashly", "audit.log")
+	first := Entry{
+		Time:        time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Instance:    "prod",
+		Backup:      "20250101000000",
+		Key:         "prefix/prod/20250101000000/postgres-plain.zip.gpg",
+		Storage:     "s3 (bucket)",
+		Size:        42,
+		Algorithm:   "OpenPGP RSA-4096",
+		KeyID:       "0123456789ABCDEF",
+		Fingerprint: "FEDCBA98765432100123456789ABCDEF01234567",
+	}
+	second := first
+	second.Key = "prefix/prod/20250101000000/manifest.json.gpg"
+	second.Fingerprint = ""
+
+	cancelled := Entry{
+		Time:       time.Date(2025, 1, 1, 0, 5, 0, 0, time.UTC),
+		Instance:   "prod",
+		Event:      EventCancelled,
+		JobID:      3,
+		RemoteAddr: "10.0.0.1:4321",
+	}
+
+	require.NoError(t, Append(path, first))
+	require.NoError(t, Append(path, second))
+	require.NoError(t, Append(path, cancelled))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		entries = append(entries, e)
+	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, []Entry{first, second, cancelled}, entries)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
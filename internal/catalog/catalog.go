@@ -0,0 +1,197 @@
+// Package catalog keeps a local copy of the backup listing and manifests, so backups can be listed
+// without listing storage and downloading every manifest.
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hibare/GoCommon/v2/pkg/datetime"
+	"github.com/hibare/stashly/internal/manifest"
+)
+
+// Catalog is the local record of the backups in storage for one instance.
+type Catalog struct {
+	// Instance is the instance ID the catalog was built for; a catalog for another instance is discarded.
+	Instance string `json:"instance"`
+
+	// ReconciledAt is when the catalog was last checked against storage; zero if it never was.
+	ReconciledAt time.Time `json:"reconciled_at"`
+
+	// Backups maps backup timestamps to their manifests; nil for backups that predate manifests.
+	Backups map[string]*manifest.Manifest `json:"backups"`
+
+	// Drills holds the latest restore drills, oldest first.
+	Drills []Drill `json:"drills,omitempty"`
+
+	// Cancellations holds the latest backup jobs cancelled through the API, oldest first.
+	Cancellations []Cancellation `json:"cancellations,omitempty"`
+
+	path string
+}
+
+// maxDrills is how many restore drills the catalog keeps.
+const maxDrills = 100
+
+// Drill is the outcome of a restore drill.
+type Drill struct {
+	// Backup is the timestamp of the backup that was restored; empty if the drill failed before picking one.
+	Backup string `json:"backup,omitempty"`
+
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+	Passed    bool          `json:"passed"`
+
+	// Tables maps the restored databases to the number of tables found in them.
+	Tables map[string]int `json:"tables,omitempty"`
+
+	// Error is why the drill failed.
+	Error string `json:"error,omitempty"`
+}
+
+// maxCancellations is how many job cancellations the catalog keeps.
+const maxCancellations = 100
+
+// Cancellation records a backup job cancelled through the daemon's API.
+type Cancellation struct {
+	// JobID is the scheduler's ID of the run that was cancelled.
+	JobID       uint64    `json:"job_id"`
+	CancelledAt time.Time `json:"cancelled_at"`
+
+	// RemoteAddr is the address of the caller that cancelled it.
+	RemoteAddr string `json:"remote_addr"`
+}
+
+// Load reads the catalog at path. A missing catalog, or one built for another instance, loads empty.
+func Load(path, instance string) (*Catalog, error) {
+	empty := &Catalog{Instance: instance, Backups: map[string]*manifest.Manifest{}, path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return empty, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var c Catalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("error parsing catalog %s: %w", path, err)
+	}
+	if c.Instance != instance {
+		return empty, nil
+	}
+	if c.Backups == nil {
+		c.Backups = map[string]*manifest.Manifest{}
+	}
+	c.path = path
+	return &c, nil
+}
+
+// Save writes the catalog back to the path it was loaded from, replacing it atomically.
+func (c *Catalog) Save() error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0750); err != nil {
+		return err
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// Stale reports whether the catalog is due for reconciliation. A zero interval always reconciles.
+func (c *Catalog) Stale(interval time.Duration) bool {
+	return c.ReconciledAt.IsZero() || time.Since(c.ReconciledAt) >= interval
+}
+
+// Invalidate forces the next read to reconcile, for changes made to storage outside the catalog.
+func (c *Catalog) Invalidate() {
+	c.ReconciledAt = time.Time{}
+}
+
+// Put records a backup and its manifest.
+func (c *Catalog) Put(timestamp string, m *manifest.Manifest) {
+	c.Backups[timestamp] = m
+}
+
+// Remove forgets a backup.
+func (c *Catalog) Remove(timestamp string) {
+	delete(c.Backups, timestamp)
+}
+
+// RecordDrill records a restore drill, forgetting the oldest ones beyond the last maxDrills.
+func (c *Catalog) RecordDrill(d Drill) {
+	c.Drills = append(c.Drills, d)
+	if len(c.Drills) > maxDrills {
+		c.Drills = c.Drills[len(c.Drills)-maxDrills:]
+	}
+}
+
+// RecordCancellation records a cancelled backup job, forgetting the oldest ones beyond the last
+// maxCancellations.
+func (c *Catalog) RecordCancellation(x Cancellation) {
+	c.Cancellations = append(c.Cancellations, x)
+	if len(c.Cancellations) > maxCancellations {
+		c.Cancellations = c.Cancellations[len(c.Cancellations)-maxCancellations:]
+	}
+}
+
+// LastPassedDrill returns the latest restore drill that passed, if any.
+func (c *Catalog) LastPassedDrill() (Drill, bool) {
+	for i := len(c.Drills) - 1; i >= 0; i-- {
+		if c.Drills[i].Passed {
+			return c.Drills[i], true
+		}
+	}
+	return Drill{}, false
+}
+
+// Timestamps returns the recorded backup timestamps, sorted by date.
+func (c *Catalog) Timestamps() []string {
+	timestamps := make([]string, 0, len(c.Backups))
+	for ts := range c.Backups {
+		timestamps = append(timestamps, ts)
+	}
+	return datetime.SortDateTimes(timestamps)
+}
+
+// Reconcile makes the catalog match the timestamps found in storage: backups no longer in storage are
+// dropped and new ones are added with the manifest returned by read, or nil when read returns
+// manifest.ErrNotFound. It returns how many backups were added and removed.
+func (c *Catalog) Reconcile(timestamps []string, read func(timestamp string) (*manifest.Manifest, error)) (int, int, error) {
+	inStorage := make(map[string]bool, len(timestamps))
+	added := 0
+	for _, ts := range timestamps {
+		inStorage[ts] = true
+		if _, ok := c.Backups[ts]; ok {
+			continue
+		}
+		m, err := read(ts)
+		if err != nil && !errors.Is(err, manifest.ErrNotFound) {
+			return 0, 0, err
+		}
+		c.Backups[ts] = m
+		added++
+	}
+
+	removed := 0
+	for ts := range c.Backups {
+		if !inStorage[ts] {
+			delete(c.Backups, ts)
+			removed++
+		}
+	}
+
+	c.ReconciledAt = time.Now()
+	return added, removed, nil
+}
@@ -0,0 +1,114 @@
+package catalog
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hibare/stashly/internal/manifest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalog_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "catalog.json")
+
+	c, err := Load(path, "db-1")
+	require.NoError(t, err)
+	assert.Empty(t, c.Backups)
+	assert.True(t, c.Stale(time.Hour))
+
+	c.Put("20240101000000", &manifest.Manifest{Size: 42})
+	c.Put("20230101000000", nil)
+	c.ReconciledAt = time.Now()
+	require.NoError(t, c.Save())
+
+	loaded, err := Load(path, "db-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20240101000000", "20230101000000"}, loaded.Timestamps())
+	assert.Equal(t, int64(42), loaded.Backups["20240101000000"].Size)
+	assert.Nil(t, loaded.Backups["20230101000000"])
+	assert.False(t, loaded.Stale(time.Hour))
+
+	loaded.Invalidate()
+	assert.True(t, loaded.Stale(time.Hour))
+
+	// A catalog built for another instance is discarded.
+	other, err := Load(path, "db-2")
+	require.NoError(t, err)
+	assert.Empty(t, other.Backups)
+}
+
+func TestCatalog_Reconcile(t *testing.T) {
+	c, err := Load(filepath.Join(t.TempDir(), "catalog.json"), "db-1")
+	require.NoError(t, err)
+	c.Put("20240101000000", &manifest.Manifest{Size: 1})
+	c.Put("20240102000000", &manifest.Manifest{Size: 2})
+
+	var read []string
+	added, removed, err := c.Reconcile([]string{"20240102000000", "20240103000000", "20240104000000"},
+		func(ts string) (*manifest.Manifest, error) {
+			read = append(read, ts)
+			if ts == "20240104000000" {
+				return nil, manifest.ErrNotFound
+			}
+			return &manifest.Manifest{Size: 3}, nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, 2, added)
+	assert.Equal(t, 1, removed)
+	assert.Equal(t, []string{"20240103000000", "20240104000000"}, read)
+	assert.Equal(t, []string{"20240104000000", "20240103000000", "20240102000000"}, c.Timestamps())
+	assert.Nil(t, c.Backups["20240104000000"])
+	assert.False(t, c.Stale(time.Hour))
+
+	_, _, err = c.Reconcile([]string{"20240105000000"}, func(string) (*manifest.Manifest, error) {
+		return nil, errors.New("download failed")
+	})
+	require.Error(t, err)
+}
+
+func TestCatalog_Drills(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	c, err := Load(path, "db-1")
+	require.NoError(t, err)
+	_, ok := c.LastPassedDrill()
+	assert.False(t, ok)
+
+	start := time.Now().Add(-time.Hour)
+	c.RecordDrill(Drill{Backup: "20240101000000", StartedAt: start, Passed: true, Tables: map[string]int{"app": 3}})
+	for i := range maxDrills {
+		c.RecordDrill(Drill{StartedAt: start.Add(time.Duration(i) * time.Second), Error: "no backups found"})
+	}
+	require.NoError(t, c.Save())
+
+	loaded, err := Load(path, "db-1")
+	require.NoError(t, err)
+	assert.Len(t, loaded.Drills, maxDrills)
+	_, ok = loaded.LastPassedDrill()
+	assert.False(t, ok, "the passed drill is the oldest and was forgotten")
+
+	loaded.RecordDrill(Drill{Backup: "20240102000000", Passed: true})
+	last, ok := loaded.LastPassedDrill()
+	require.True(t, ok)
+	assert.Equal(t, "20240102000000", last.Backup)
+}
+
+func TestCatalog_Cancellations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	c, err := Load(path, "db-1")
+	require.NoError(t, err)
+
+	at := time.Now().UTC().Truncate(time.Second)
+	for i := range maxCancellations + 1 {
+		c.RecordCancellation(Cancellation{JobID: uint64(i + 1), CancelledAt: at, RemoteAddr: "10.0.0.1:4321"})
+	}
+	require.NoError(t, c.Save())
+
+	loaded, err := Load(path, "db-1")
+	require.NoError(t, err)
+	require.Len(t, loaded.Cancellations, maxCancellations)
+	assert.Equal(t, Cancellation{JobID: 2, CancelledAt: at, RemoteAddr: "10.0.0.1:4321"}, loaded.Cancellations[0])
+	assert.Equal(t, uint64(maxCancellations+1), loaded.Cancellations[maxCancellations-1].JobID)
+}
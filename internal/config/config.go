@@ -2,10 +2,16 @@
 package config
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	commonLogger "github.com/hibare/GoCommon/v2/pkg/logger"
 	commonUtils "github.com/hibare/GoCommon/v2/pkg/utils"
@@ -22,6 +28,9 @@ const (
 // AppConfig holds application-level configuration.
 type AppConfig struct {
 	InstanceID string `mapstructure:"instance-id"`
+
+	// UpdateCheck logs a warning at daemon startup when a newer minor or major release is available.
+	UpdateCheck bool `mapstructure:"update-check"`
 }
 
 // LoggerConfig holds logging configuration.
@@ -36,6 +45,47 @@ type PostgresConfig struct {
 	Port     string `mapstructure:"port"`
 	User     string `mapstructure:"user"`
 	Password string `mapstructure:"password"`
+
+	// DumpHost and DumpPort, when set, are used for pg_dump instead of Host and Port,
+	// e.g. to bypass a transaction-pooling PgBouncer that pg_dump cannot work through.
+	DumpHost string `mapstructure:"dump-host"`
+	DumpPort string `mapstructure:"dump-port"`
+
+	// Nodes lists the "host:port" members of a replicated cluster, e.g. Patroni. When set, every run
+	// connects to the one that is not in recovery instead of Host and Port; a missing port means Port.
+	Nodes []string `mapstructure:"nodes"`
+
+	// Citus makes dumps of Citus coordinators carry the table distribution so restores recreate it.
+	Citus bool `mapstructure:"citus"`
+
+	// PreserveOwners keeps object owners and privileges in dumps and saves the cluster's roles
+	// alongside them, so a restore into a fresh server recreates both.
+	PreserveOwners bool `mapstructure:"preserve-owners"`
+
+	// ReplicationSlotSnapshot dumps each database from the snapshot exported by a temporary logical
+	// replication slot, recording the slot's LSN so CDC pipelines can continue from the dump.
+	ReplicationSlotSnapshot bool `mapstructure:"replication-slot-snapshot"`
+
+	// ListTimeout bounds the query listing the databases on the server, separately from the dumps, so
+	// a wedged server fails the run instead of hanging it; 0 disables it.
+	ListTimeout time.Duration `mapstructure:"list-timeout"`
+
+	// Fleet lists servers backed up in the same run instead of Host and Port, each stored under its
+	// own "<instance-id>/<name>" prefix. Settings a host leaves empty are taken from above.
+	Fleet []PostgresHost `mapstructure:"fleet"`
+
+	// Name is the fleet host this configuration was derived for by ForHost; empty otherwise.
+	Name string `mapstructure:"-"`
+}
+
+// PostgresHost is one server of a fleet.
+type PostgresHost struct {
+	// Name identifies the host in storage keys and reports; it must be unique within the fleet.
+	Name     string `mapstructure:"name"`
+	Host     string `mapstructure:"host"`
+	Port     string `mapstructure:"port"`
+	User     string `mapstructure:"user"`
+	Password string `mapstructure:"password"`
 }
 
 // S3Config holds S3 storage configuration.
@@ -46,48 +96,620 @@ type S3Config struct {
 	SecretKey string `mapstructure:"secret-key"`
 	Bucket    string `mapstructure:"bucket"`
 	Prefix    string `mapstructure:"prefix"`
+
+	// AccessKeyFile and SecretKeyFile read the keys from files instead, e.g. mounted secrets. They take
+	// precedence over AccessKey and SecretKey and are re-read every CredentialsRefresh, so rotated keys
+	// are picked up without a restart.
+	AccessKeyFile      string        `mapstructure:"access-key-file"`
+	SecretKeyFile      string        `mapstructure:"secret-key-file"`
+	CredentialsRefresh time.Duration `mapstructure:"credentials-refresh"`
+
+	// AuthMode selects where credentials come from: "static" for the keys above, "default-chain" for
+	// the AWS SDK's default credential chain (IRSA, instance profiles, SSO, ...), or "auto", the
+	// default, for static keys when they are set and the chain otherwise.
+	AuthMode string `mapstructure:"auth-mode"`
+
+	// UserAgent is appended to the SDK user agent of every request; defaults to "stashly/<version> instance/<instance-id>".
+	UserAgent string `mapstructure:"user-agent"`
+
+	// Headers are extra HTTP headers sent with every request, e.g. for proxies or gateways that attribute traffic.
+	Headers map[string]string `mapstructure:"headers"`
+
+	// Tags are object tags set on every uploaded object, e.g. for cost allocation.
+	Tags map[string]string `mapstructure:"tags"`
+
+	// ServerSideEncryption asks S3 to encrypt uploaded objects at rest: "AES256" with S3-managed keys
+	// (SSE-S3), "aws:kms" with KMSKeyID (SSE-KMS), or the account's aws/s3 key when KMSKeyID is empty.
+	// Empty leaves it to the bucket's default encryption.
+	ServerSideEncryption string `mapstructure:"server-side-encryption"`
+	KMSKeyID             string `mapstructure:"kms-key-id"`
+
+	// Files larger than PartSizeMB MiB are uploaded in parts of that size, UploadConcurrency at a
+	// time. S3 allows at most 10000 parts, so the part size grows for files too large for it.
+	PartSizeMB        int64 `mapstructure:"part-size-mb"`
+	UploadConcurrency int   `mapstructure:"upload-concurrency"`
+
+	// StoragePricePerGB and RequestPricePer1000 are the provider's prices, used to estimate
+	// monthly costs in `stashly list --details`. They default to AWS S3 Standard list prices.
+	StoragePricePerGB   float64 `mapstructure:"storage-price-per-gb"`
+	RequestPricePer1000 float64 `mapstructure:"request-price-per-1000"`
+
+	// Retry is the policy for retrying calls that fail with transient errors.
+	Retry RetryConfig `mapstructure:"retry"`
+}
+
+// RetryConfig is a storage backend's policy for retrying calls that fail with transient errors.
+type RetryConfig struct {
+	// MaxAttempts is how many times a call is tried; 1 disables retries.
+	MaxAttempts int `mapstructure:"max-attempts"`
+
+	// Backoff is the wait before the second attempt. It doubles with each attempt up to MaxBackoff,
+	// and each wait is cut by a random amount of up to half so clients don't retry in lockstep.
+	Backoff    time.Duration `mapstructure:"backoff"`
+	MaxBackoff time.Duration `mapstructure:"max-backoff"`
+
+	// RetryOn lists the error classes retried: "throttling", "server" and "network". Empty retries all
+	// of them.
+	RetryOn []string `mapstructure:"retry-on"`
+}
+
+// storageBackends are the storage backends, each configured in the section of the same name.
+var storageBackends = []string{"s3", "gcs", "azblob", "webdav", "ftp", "rclone"}
+
+// StorageConfig selects the storage backend.
+type StorageConfig struct {
+	// Backend is "s3", "gcs", "azblob", "webdav", "ftp" or "rclone".
+	Backend string `mapstructure:"backend"`
+
+	// Secondary is another backend uploads fail over to when the primary keeps failing; it is
+	// configured through its own section. Empty disables failover.
+	Secondary string `mapstructure:"secondary"`
+
+	// UploadAttempts is how many times an upload to the primary is tried before failing over, and
+	// UploadRetryDelay the wait before the second attempt, growing with each attempt. Both only
+	// apply with a secondary.
+	UploadAttempts   int           `mapstructure:"upload-attempts"`
+	UploadRetryDelay time.Duration `mapstructure:"upload-retry-delay"`
+
+	// Index keeps an index.json summarizing every backup at the root of the backup prefix, rewritten
+	// after each backup run, so dashboards and other tools can list backups with a single GET.
+	Index bool `mapstructure:"index"`
+}
+
+// GCSConfig holds Google Cloud Storage configuration.
+type GCSConfig struct {
+	Bucket string `mapstructure:"bucket"`
+	Prefix string `mapstructure:"prefix"`
+
+	// CredentialsFile is a service account key or workload identity federation config. When empty,
+	// Application Default Credentials are used, e.g. GKE workload identity or the metadata server.
+	CredentialsFile string `mapstructure:"credentials-file"`
+
+	// Endpoint overrides the GCS API endpoint, e.g. for private service connect or an emulator.
+	Endpoint string `mapstructure:"endpoint"`
+
+	// Retry is the policy for retrying calls that fail with transient errors.
+	Retry RetryConfig `mapstructure:"retry"`
+}
+
+// AzblobConfig holds Azure Blob Storage configuration.
+type AzblobConfig struct {
+	// Account is the storage account name.
+	Account   string `mapstructure:"account"`
+	Container string `mapstructure:"container"`
+	Prefix    string `mapstructure:"prefix"`
+
+	// SASToken authenticates with a shared access signature. When empty, the managed identity is used.
+	SASToken string `mapstructure:"sas-token"`
+
+	// ManagedIdentityClientID selects a user-assigned managed identity; empty uses the system-assigned one.
+	ManagedIdentityClientID string `mapstructure:"managed-identity-client-id"`
+
+	// Endpoint overrides the blob service endpoint, https://<account>.blob.core.windows.net by default.
+	Endpoint string `mapstructure:"endpoint"`
+
+	// Retry is the policy for retrying calls that fail with transient errors.
+	Retry RetryConfig `mapstructure:"retry"`
+}
+
+// WebDAVConfig holds WebDAV storage configuration, e.g. for Nextcloud.
+type WebDAVConfig struct {
+	// URL is the collection backups are stored below, e.g.
+	// https://cloud.example.com/remote.php/dav/files/<user>/.
+	URL    string `mapstructure:"url"`
+	Prefix string `mapstructure:"prefix"`
+
+	// Username and Password authenticate with basic auth; for Nextcloud, use an app password.
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+
+	// Token authenticates with a bearer token instead of basic auth.
+	Token string `mapstructure:"token"`
+
+	// Retry is the policy for retrying calls that fail with transient errors.
+	Retry RetryConfig `mapstructure:"retry"`
+}
+
+// FTPConfig holds FTP/FTPS storage configuration.
+type FTPConfig struct {
+	// Host is host[:port]; the port defaults to 21, or 990 with implicit TLS.
+	Host     string `mapstructure:"host"`
+	Prefix   string `mapstructure:"prefix"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+
+	// TLS is "" for plain FTP, "explicit" for AUTH TLS (FTPES) or "implicit" for FTPS. Certificates
+	// are verified with the tls settings.
+	TLS string `mapstructure:"tls"`
+
+	// DisableEPSV uses PASV instead of EPSV for passive data connections, for old servers and NATs.
+	DisableEPSV bool `mapstructure:"disable-epsv"`
+
+	// Timeout bounds connecting and each response; 0 disables it.
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// Retry is the policy for retrying calls that fail with transient errors.
+	Retry RetryConfig `mapstructure:"retry"`
+}
+
+// RcloneConfig holds configuration for storing backups through an rclone remote.
+type RcloneConfig struct {
+	// Remote is the rclone remote and path backups are stored below, e.g. "b2:my-bucket/stashly".
+	Remote string `mapstructure:"remote"`
+	Prefix string `mapstructure:"prefix"`
+
+	// ConfigFile is the rclone config defining the remote; empty uses rclone's default location.
+	ConfigFile string `mapstructure:"config-file"`
+
+	// Flags are extra flags passed to every rclone command, e.g. "--s3-chunk-size=64M".
+	Flags []string `mapstructure:"flags"`
+
+	// Retry is the policy for retrying calls that fail with transient errors.
+	Retry RetryConfig `mapstructure:"retry"`
 }
 
 // BackupConfig holds backup-related configuration.
 type BackupConfig struct {
+	// Engine selects the registered dump engine (e.g. "postgres").
+	Engine string `mapstructure:"engine"`
+
 	RetentionCount int    `mapstructure:"retention-count"`
 	DateTimeLayout string `mapstructure:"date-time-layout"`
 	Cron           string `mapstructure:"cron"`
 	Encrypt        bool   `mapstructure:"encrypt"`
+
+	// RetentionMinCount is the fewest backups retention leaves, whatever the other policies say.
+	RetentionMinCount int `mapstructure:"retention-min-count"`
+
+	// PurgeMaxPercent refuses purges that would delete more than this share of the backups in one
+	// run, unless forced with `stashly purge --force` (0 disables).
+	PurgeMaxPercent int `mapstructure:"purge-max-percent"`
+
+	// FleetConcurrency is how many hosts of postgres.fleet are backed up at once.
+	FleetConcurrency int `mapstructure:"fleet-concurrency"`
+
+	// EncryptManifest also encrypts each backup's manifest, leaving only a minimal plaintext index for
+	// listing and retention. It needs Encrypt.
+	EncryptManifest bool `mapstructure:"encrypt-manifest"`
+
+	// EncryptFramed encrypts archives in authenticated frames (see package framed) instead of as one
+	// OpenPGP message, so restores verify them frame by frame. It needs Encrypt.
+	EncryptFramed bool `mapstructure:"encrypt-framed"`
+
+	// Mode selects what running stashly without a subcommand does: ModeSchedule runs backups on Cron,
+	// ModeOnce runs one backup and exits, and ModeAuto schedules only when a cron is configured.
+	// LoadConfig resolves ModeAuto, so Mode is always ModeSchedule or ModeOnce afterwards.
+	Mode string `mapstructure:"mode"`
+
+	// SnapshotTTL is how long snapshots taken with `stashly snapshot` are kept (0 keeps them forever).
+	SnapshotTTL time.Duration `mapstructure:"snapshot-ttl"`
+
+	// DurationWarning sends a warning when a successful run takes longer than this (0 disables).
+	DurationWarning time.Duration `mapstructure:"duration-warning"`
+
+	// RPOThreshold sends an alert when the newest backup is older than this (0 disables).
+	RPOThreshold time.Duration `mapstructure:"rpo-threshold"`
+
+	// VolumeSizeMB splits archives larger than this many MiB into volumes of that size (0 disables).
+	VolumeSizeMB int64 `mapstructure:"volume-size-mb"`
+
+	// MaxRunDuration is a hard cap on a scheduled run: the daemon's watchdog logs runs still going
+	// after it and, with WatchdogRestart, cancels them so later runs start fresh (0 disables).
+	MaxRunDuration  time.Duration `mapstructure:"max-run-duration"`
+	WatchdogRestart bool          `mapstructure:"watchdog-restart"`
+
+	// Compressor selects how archives are compressed: "" or "zip" compresses in-process, "pigz", "igzip"
+	// or "zstdmt" use that multi-core compressor, and "auto" uses the first one installed. Missing or
+	// failing compressors fall back to in-process compression.
+	Compressor string `mapstructure:"compressor"`
+
+	// TierAfter moves backups older than this to TierStorageClass instead of keeping them in the
+	// default class (0 disables). Retention still deletes them once they fall out of retention-count.
+	TierAfter        time.Duration `mapstructure:"tier-after"`
+	TierStorageClass string        `mapstructure:"tier-storage-class"`
+
+	// PurgeRate caps how many backups retention deletes per second, keeping large purges under the
+	// backend's request limits (0 is unlimited).
+	PurgeRate float64 `mapstructure:"purge-rate"`
+
+	// DatabaseRetries is how many more times databases whose dump failed with a transient error, such
+	// as a reset connection or a lock timeout, are dumped again at the end of the run. Each round waits
+	// DatabaseRetryDelay times its number first.
+	DatabaseRetries    int           `mapstructure:"database-retries"`
+	DatabaseRetryDelay time.Duration `mapstructure:"database-retry-delay"`
+
+	// Order is OrderLargestFirst or OrderSmallestFirst to dump databases by size; empty keeps the
+	// server's order.
+	Order string `mapstructure:"order"`
+
+	// Priority lists databases dumped before all others, in this order, so the most critical ones are
+	// captured first if the run is cut short. Order applies to the rest.
+	Priority []string `mapstructure:"priority"`
+}
+
+// Database dump orders, see BackupConfig.Order.
+const (
+	OrderLargestFirst  = "largest-first"
+	OrderSmallestFirst = "smallest-first"
+)
+
+// RestoreConfig holds restore-related configuration.
+type RestoreConfig struct {
+	// DownloadConcurrency is the number of parallel ranged GETs used to fetch an archive.
+	DownloadConcurrency int `mapstructure:"download-concurrency"`
+
+	// DownloadPartSizeMB is the size of each ranged GET in MiB.
+	DownloadPartSizeMB int64 `mapstructure:"download-part-size-mb"`
+
+	// Template is the template databases are created from. When empty, template0 is used if the
+	// backup recorded the database's encoding and collation, and the server default otherwise.
+	Template string `mapstructure:"template"`
+
+	// RTOThreshold sends an alert when the last restore drill that passed took longer than this (0 disables).
+	RTOThreshold time.Duration `mapstructure:"rto-threshold"`
+
+	// Drill schedules restore drills in daemon mode.
+	Drill DrillConfig `mapstructure:"drill"`
+}
+
+// DrillConfig holds restore drill configuration. A drill restores the latest backup into a scratch
+// server, checks it and drops the restored databases again.
+type DrillConfig struct {
+	// Cron schedules drills; drills are off when empty.
+	Cron string `mapstructure:"cron"`
+
+	// Host, Port, User and Password locate the scratch server. Its databases are replaced by each
+	// drill, so it must not be the server being backed up. Port, User and Password left empty are
+	// taken from postgres.
+	Host     string `mapstructure:"host"`
+	Port     string `mapstructure:"port"`
+	User     string `mapstructure:"user"`
+	Password string `mapstructure:"password"`
+
+	// Window is how long drills may go without passing before an alert is sent (0 disables).
+	Window time.Duration `mapstructure:"window"`
 }
 
 // GPGConfig holds GPG encryption configuration.
 type GPGConfig struct {
 	KeyServer string `mapstructure:"key-server"`
 	KeyID     string `mapstructure:"key-id"`
+
+	// PrivateKeyFile and Passphrase are only needed to decrypt backups on restore.
+	PrivateKeyFile string `mapstructure:"private-key-file"`
+	Passphrase     string `mapstructure:"passphrase"`
+
+	// Agent decrypts backups on restore with the local gpg binary instead of PrivateKeyFile, so keys
+	// held in the gpg keyring, gpg-agent or a smartcard can be used. It is ignored when
+	// PrivateKeyFile is set.
+	Agent bool `mapstructure:"agent"`
+
+	// ExpiryWarningDays warns when the key expires within this many days (0 disables the warning).
+	ExpiryWarningDays int `mapstructure:"expiry-warning-days"`
+
+	// RequireKeyProof refuses to encrypt backups until `stashly encryption prove` has shown that the
+	// private key for the current key is available.
+	RequireKeyProof bool `mapstructure:"require-key-proof"`
+
+	// KeyProofFile is where the proof of possession is kept.
+	KeyProofFile string `mapstructure:"key-proof-file"`
 }
 
 // Encryption holds encryption-related configuration.
 type Encryption struct {
 	GPG GPGConfig `mapstructure:"gpg"`
+
+	// Compliance refuses to upload anything but encrypted backups: runs fail instead of falling back
+	// to plaintext, and every encrypted object uploaded is recorded in AuditLog.
+	Compliance bool `mapstructure:"compliance"`
+
+	// AuditLog is the file compliance mode records uploaded objects in, one JSON object per line.
+	AuditLog string `mapstructure:"audit-log"`
 }
 
 // DiscordNotifierConfig holds configuration for the Discord notifier.
 type DiscordNotifierConfig struct {
 	Enabled bool   `mapstructure:"enabled"`
 	Webhook string `mapstructure:"webhook"`
+
+	// FailureThreadID, when set, posts failure notifications into this thread instead of the channel.
+	FailureThreadID string `mapstructure:"failure-thread-id"`
+
+	// MentionRole and MentionUser are IDs mentioned in failure notifications.
+	MentionRole string `mapstructure:"mention-role"`
+	MentionUser string `mapstructure:"mention-user"`
+
+	// MaxPerHour caps the messages sent per hour; extra events are collapsed into a summary (0 is unlimited).
+	MaxPerHour int `mapstructure:"max-per-hour"`
 }
 
 // NotifiersConfig holds configuration for all notifiers.
 type NotifiersConfig struct {
 	Enabled bool                  `mapstructure:"enabled"`
 	Discord DiscordNotifierConfig `mapstructure:"discord"`
+
+	// DedupWindow suppresses repeated failure notifications of the same error class within this window (0 disables).
+	DedupWindow time.Duration `mapstructure:"dedup-window"`
+
+	// TimeFormat is "24h", "12h", "rfc3339" or a Go time layout used for timestamps in notifications.
+	TimeFormat string `mapstructure:"time-format"`
+
+	// Timezone is the IANA timezone timestamps are displayed in (e.g. "Europe/Berlin"); empty means local time.
+	Timezone string `mapstructure:"timezone"`
+
+	// Locale is the language month and weekday names are written in, one of NotifierLocales; empty
+	// means English.
+	Locale string `mapstructure:"locale"`
+
+	// FirstBackup sends a "coverage started" notification when an instance produces its first backup.
+	FirstBackup bool `mapstructure:"first-backup"`
+
+	// Redact is RedactHash or RedactMask to hide database names and storage keys in notifications;
+	// empty sends them as they are.
+	Redact string `mapstructure:"redact"`
 }
 
+// NotifierLocales are the languages notification timestamps can be written in, see NotifiersConfig.Locale.
+var NotifierLocales = []string{"en", "de", "es", "fr", "it", "nl", "pt"}
+
+// Notification redaction modes, see NotifiersConfig.Redact.
+const (
+	RedactHash = "hash"
+	RedactMask = "mask"
+)
+
+// KubernetesResources holds container resource requests and limits (e.g. cpu: "500m", memory: "1Gi").
+type KubernetesResources struct {
+	Requests map[string]string `mapstructure:"requests"`
+	Limits   map[string]string `mapstructure:"limits"`
+}
+
+// KubernetesConfig holds configuration for running scheduled backups as Kubernetes Jobs.
+type KubernetesConfig struct {
+	Enabled          bool                `mapstructure:"enabled"`
+	Namespace        string              `mapstructure:"namespace"`
+	Image            string              `mapstructure:"image"`
+	ServiceAccount   string              `mapstructure:"service-account"`
+	NodeSelector     map[string]string   `mapstructure:"node-selector"`
+	Resources        KubernetesResources `mapstructure:"resources"`
+	EnvFromSecret    string              `mapstructure:"env-from-secret"`
+	ConfigMap        string              `mapstructure:"config-map"`
+	Timeout          time.Duration       `mapstructure:"timeout"`
+	TTLAfterFinished time.Duration       `mapstructure:"ttl-after-finished"`
+}
+
+// ServerConfig holds configuration for the daemon's HTTP server.
+type ServerConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	ListenAddr string `mapstructure:"listen-addr"`
+
+	// AdminToken is the bearer token required by endpoints that change state, e.g. /maintenance.
+	// Those endpoints are disabled while it is empty.
+	AdminToken string `mapstructure:"admin-token"`
+
+	// MaintenanceFile persists maintenance mode, during which scheduled backups are skipped.
+	MaintenanceFile string `mapstructure:"maintenance-file"`
+}
+
+// ProxyConfig holds the proxy used for storage, key server and webhook requests.
+type ProxyConfig struct {
+	// URL is an http, https, socks5 or socks5h proxy URL. When empty, the HTTP_PROXY, HTTPS_PROXY and
+	// NO_PROXY environment variables are honored.
+	URL string `mapstructure:"url"`
+
+	// NoProxy lists hosts, domains (matching their subdomains) and CIDRs reached directly.
+	NoProxy []string `mapstructure:"no-proxy"`
+}
+
+// TLSConfig holds TLS settings for storage, key server and webhook requests, e.g. for internal
+// endpoints with self-signed certificates.
+type TLSConfig struct {
+	// CAFile is a PEM bundle of CAs trusted in addition to the system roots.
+	CAFile string `mapstructure:"ca-file"`
+
+	// CertFile and KeyFile are a PEM client certificate and key for mutual TLS.
+	CertFile string `mapstructure:"cert-file"`
+	KeyFile  string `mapstructure:"key-file"`
+
+	// InsecureSkipVerify disables certificate verification. Only meant for testing.
+	InsecureSkipVerify bool `mapstructure:"insecure-skip-verify"`
+}
+
+// CatalogConfig holds the local catalog that serves backup listings without listing storage.
+type CatalogConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Path    string `mapstructure:"path"`
+
+	// ReconcileInterval is how long the catalog is trusted before it is checked against storage again.
+	ReconcileInterval time.Duration `mapstructure:"reconcile-interval"`
+}
+
+// WebhookConfig holds an outbound webhook receiving backup lifecycle events.
+type WebhookConfig struct {
+	URL string `mapstructure:"url"`
+
+	// Secret signs each payload with HMAC-SHA256; empty sends payloads unsigned.
+	Secret string `mapstructure:"secret"`
+
+	// Events lists the events delivered to this webhook; empty means all of them.
+	Events []string `mapstructure:"events"`
+}
+
+// DiscoveryConfig holds the inventory queried each run for the Postgres servers to back up.
+type DiscoveryConfig struct {
+	// Provider is the kind of inventory: "http" for an endpoint listing DSNs, the default, "consul"
+	// or "etcd".
+	Provider string `mapstructure:"provider"`
+
+	// URL is the inventory endpoint, or the Consul or etcd address; empty disables discovery.
+	URL string `mapstructure:"url"`
+
+	// Token is sent as a bearer token to the endpoint, or as the Consul ACL token.
+	Token string `mapstructure:"token"`
+
+	Consul ConsulDiscoveryConfig `mapstructure:"consul"`
+	Etcd   EtcdDiscoveryConfig   `mapstructure:"etcd"`
+}
+
+// ConsulDiscoveryConfig selects the Consul service whose healthy instances are backed up.
+type ConsulDiscoveryConfig struct {
+	Service string `mapstructure:"service"`
+
+	// Tag keeps only instances with this tag; empty keeps all.
+	Tag string `mapstructure:"tag"`
+
+	// Datacenter queries another datacenter than the agent's.
+	Datacenter string `mapstructure:"datacenter"`
+}
+
+// EtcdDiscoveryConfig selects the etcd keys holding the DSNs of the servers to back up.
+type EtcdDiscoveryConfig struct {
+	Prefix string `mapstructure:"prefix"`
+
+	// Username and Password authenticate with etcd when it has auth enabled.
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+}
+
+// Backup modes, see BackupConfig.Mode.
+const (
+	ModeSchedule = "schedule"
+	ModeOnce     = "once"
+	ModeAuto     = "auto"
+)
+
 // Config is the main configuration struct that holds all configuration sections.
 type Config struct {
-	App        AppConfig       `mapstructure:"app"`
-	Postgres   PostgresConfig  `mapstructure:"postgres"`
-	S3         S3Config        `mapstructure:"s3"`
-	Backup     BackupConfig    `mapstructure:"backup"`
-	Encryption Encryption      `mapstructure:"encryption"`
-	Notifiers  NotifiersConfig `mapstructure:"notifiers"`
-	Logger     LoggerConfig    `mapstructure:"logger"`
+	App        AppConfig        `mapstructure:"app"`
+	Postgres   PostgresConfig   `mapstructure:"postgres"`
+	Storage    StorageConfig    `mapstructure:"storage"`
+	S3         S3Config         `mapstructure:"s3"`
+	GCS        GCSConfig        `mapstructure:"gcs"`
+	Azblob     AzblobConfig     `mapstructure:"azblob"`
+	WebDAV     WebDAVConfig     `mapstructure:"webdav"`
+	FTP        FTPConfig        `mapstructure:"ftp"`
+	Rclone     RcloneConfig     `mapstructure:"rclone"`
+	Backup     BackupConfig     `mapstructure:"backup"`
+	Restore    RestoreConfig    `mapstructure:"restore"`
+	Encryption Encryption       `mapstructure:"encryption"`
+	Notifiers  NotifiersConfig  `mapstructure:"notifiers"`
+	Logger     LoggerConfig     `mapstructure:"logger"`
+	Kubernetes KubernetesConfig `mapstructure:"kubernetes"`
+	Server     ServerConfig     `mapstructure:"server"`
+	Proxy      ProxyConfig      `mapstructure:"proxy"`
+	TLS        TLSConfig        `mapstructure:"tls"`
+	Catalog    CatalogConfig    `mapstructure:"catalog"`
+	Webhooks   []WebhookConfig  `mapstructure:"webhooks"`
+	Discovery  DiscoveryConfig  `mapstructure:"discovery"`
+}
+
+// Redacted returns a copy of the config with credentials, tokens and URLs that may embed them blanked,
+// safe to record or fingerprint.
+func (c *Config) Redacted() *Config {
+	r := *c
+	r.Postgres.Password = ""
+	r.Restore.Drill.Password = ""
+	if c.Postgres.Fleet != nil {
+		r.Postgres.Fleet = make([]PostgresHost, len(c.Postgres.Fleet))
+		for i, host := range c.Postgres.Fleet {
+			host.Password = ""
+			r.Postgres.Fleet[i] = host
+		}
+	}
+	r.S3.AccessKey, r.S3.SecretKey = "", ""
+	r.Azblob.SASToken = ""
+	r.WebDAV.Password, r.WebDAV.Token = "", ""
+	r.FTP.Password = ""
+	r.Rclone.Flags = nil
+	r.Notifiers.Discord.Webhook = ""
+	r.Server.AdminToken = ""
+	r.Proxy.URL = ""
+	r.Discovery.URL, r.Discovery.Token = "", ""
+	r.Discovery.Etcd.Password = ""
+
+	r.Webhooks = make([]WebhookConfig, len(c.Webhooks))
+	for i, hook := range c.Webhooks {
+		hook.URL, hook.Secret = "", ""
+		r.Webhooks[i] = hook
+	}
+	return &r
+}
+
+// ForHost returns a copy of the config for backing up one host of the fleet: connecting to it, and
+// storing its backups, work files and catalog apart from the other hosts'.
+func (c *Config) ForHost(host PostgresHost) *Config {
+	h := *c
+	h.Postgres.Name = host.Name
+	h.Postgres.Host = host.Host
+	if host.Port != "" {
+		h.Postgres.Port = host.Port
+	}
+	if host.User != "" {
+		h.Postgres.User = host.User
+	}
+	if host.Password != "" {
+		h.Postgres.Password = host.Password
+	}
+	h.Postgres.DumpHost, h.Postgres.DumpPort = "", ""
+	h.Postgres.Nodes = nil
+	h.Postgres.Fleet = nil
+
+	h.App.InstanceID = path.Join(c.App.InstanceID, host.Name)
+	if c.Catalog.Path != "" {
+		ext := filepath.Ext(c.Catalog.Path)
+		h.Catalog.Path = strings.TrimSuffix(c.Catalog.Path, ext) + "-" + host.Name + ext
+	}
+	return &h
+}
+
+// ForDrill returns a copy of the config for restore drills: restoring into the scratch server of
+// restore.drill instead of the server being backed up.
+func (c *Config) ForDrill() *Config {
+	d := *c
+	drill := c.Restore.Drill
+	d.Postgres.Host = drill.Host
+	d.Postgres.Port = cmp.Or(drill.Port, c.Postgres.Port)
+	d.Postgres.User = cmp.Or(drill.User, c.Postgres.User)
+	d.Postgres.Password = cmp.Or(drill.Password, c.Postgres.Password)
+	d.Postgres.DumpHost, d.Postgres.DumpPort = "", ""
+	d.Postgres.Nodes = nil
+	return &d
+}
+
+// AtLocation returns a copy of the config whose storage backends keep backups directly under location
+// instead of under their prefix and the instance ID, e.g. to reach backups stored under an earlier
+// layout. The local catalog describes the configured location only and is disabled.
+func (c *Config) AtLocation(location string) *Config {
+	l := *c
+	l.S3.Prefix = location
+	l.GCS.Prefix = location
+	l.Azblob.Prefix = location
+	l.WebDAV.Prefix = location
+	l.FTP.Prefix = location
+	l.Rclone.Prefix = location
+	l.App.InstanceID = ""
+	l.Catalog.Enabled = false
+	return &l
 }
 
 // LoadConfig loads config from viper.
@@ -112,28 +734,164 @@ func LoadConfig(ctx context.Context, configPath string) (*Config, error) {
 
 	// Bind all configuration fields to environment variables
 	envBindings := map[string]string{
-		"postgres.host":             "STASHLY_POSTGRES_HOST",
-		"postgres.port":             "STASHLY_POSTGRES_PORT",
-		"postgres.user":             "STASHLY_POSTGRES_USER",
-		"postgres.password":         "STASHLY_POSTGRES_PASSWORD",
-		"s3.endpoint":               "STASHLY_S3_ENDPOINT",
-		"s3.region":                 "STASHLY_S3_REGION",
-		"s3.access-key":             "STASHLY_S3_ACCESS_KEY",
-		"s3.secret-key":             "STASHLY_S3_SECRET_KEY",
-		"s3.bucket":                 "STASHLY_S3_BUCKET",
-		"s3.prefix":                 "STASHLY_S3_PREFIX",
-		"backup.retention-count":    "STASHLY_BACKUP_RETENTION_COUNT",
-		"backup.date-time-layout":   "STASHLY_BACKUP_DATE_TIME_LAYOUT",
-		"backup.cron":               "STASHLY_BACKUP_CRON",
-		"backup.encrypt":            "STASHLY_BACKUP_ENCRYPT",
-		"encryption.gpg.key-server": "STASHLY_ENCRYPTION_GPG_KEY_SERVER",
-		"encryption.gpg.key-id":     "STASHLY_ENCRYPTION_GPG_KEY_ID",
-		"notifiers.enabled":         "STASHLY_NOTIFIERS_ENABLED",
-		"notifiers.discord.enabled": "STASHLY_NOTIFIERS_DISCORD_ENABLED",
-		"notifiers.discord.webhook": "STASHLY_NOTIFIERS_DISCORD_WEBHOOK",
-		"logger.level":              "STASHLY_LOGGER_LEVEL",
-		"logger.mode":               "STASHLY_LOGGER_MODE",
-		"app.instance-id":           "STASHLY_APP_INSTANCE_ID",
+		"postgres.host":                       "STASHLY_POSTGRES_HOST",
+		"postgres.port":                       "STASHLY_POSTGRES_PORT",
+		"postgres.user":                       "STASHLY_POSTGRES_USER",
+		"postgres.password":                   "STASHLY_POSTGRES_PASSWORD",
+		"postgres.dump-host":                  "STASHLY_POSTGRES_DUMP_HOST",
+		"postgres.dump-port":                  "STASHLY_POSTGRES_DUMP_PORT",
+		"postgres.nodes":                      "STASHLY_POSTGRES_NODES",
+		"postgres.citus":                      "STASHLY_POSTGRES_CITUS",
+		"postgres.replication-slot-snapshot":  "STASHLY_POSTGRES_REPLICATION_SLOT_SNAPSHOT",
+		"postgres.preserve-owners":            "STASHLY_POSTGRES_PRESERVE_OWNERS",
+		"postgres.list-timeout":               "STASHLY_POSTGRES_LIST_TIMEOUT",
+		"s3.endpoint":                         "STASHLY_S3_ENDPOINT",
+		"s3.region":                           "STASHLY_S3_REGION",
+		"s3.access-key":                       "STASHLY_S3_ACCESS_KEY",
+		"s3.secret-key":                       "STASHLY_S3_SECRET_KEY",
+		"s3.access-key-file":                  "STASHLY_S3_ACCESS_KEY_FILE",
+		"s3.secret-key-file":                  "STASHLY_S3_SECRET_KEY_FILE",
+		"s3.credentials-refresh":              "STASHLY_S3_CREDENTIALS_REFRESH",
+		"s3.auth-mode":                        "STASHLY_S3_AUTH_MODE",
+		"s3.bucket":                           "STASHLY_S3_BUCKET",
+		"s3.prefix":                           "STASHLY_S3_PREFIX",
+		"s3.user-agent":                       "STASHLY_S3_USER_AGENT",
+		"s3.server-side-encryption":           "STASHLY_S3_SERVER_SIDE_ENCRYPTION",
+		"s3.kms-key-id":                       "STASHLY_S3_KMS_KEY_ID",
+		"s3.part-size-mb":                     "STASHLY_S3_PART_SIZE_MB",
+		"s3.upload-concurrency":               "STASHLY_S3_UPLOAD_CONCURRENCY",
+		"s3.storage-price-per-gb":             "STASHLY_S3_STORAGE_PRICE_PER_GB",
+		"s3.request-price-per-1000":           "STASHLY_S3_REQUEST_PRICE_PER_1000",
+		"storage.backend":                     "STASHLY_STORAGE_BACKEND",
+		"storage.secondary":                   "STASHLY_STORAGE_SECONDARY",
+		"storage.upload-attempts":             "STASHLY_STORAGE_UPLOAD_ATTEMPTS",
+		"storage.upload-retry-delay":          "STASHLY_STORAGE_UPLOAD_RETRY_DELAY",
+		"storage.index":                       "STASHLY_STORAGE_INDEX",
+		"gcs.bucket":                          "STASHLY_GCS_BUCKET",
+		"gcs.prefix":                          "STASHLY_GCS_PREFIX",
+		"gcs.credentials-file":                "STASHLY_GCS_CREDENTIALS_FILE",
+		"gcs.endpoint":                        "STASHLY_GCS_ENDPOINT",
+		"azblob.account":                      "STASHLY_AZBLOB_ACCOUNT",
+		"azblob.container":                    "STASHLY_AZBLOB_CONTAINER",
+		"azblob.prefix":                       "STASHLY_AZBLOB_PREFIX",
+		"azblob.sas-token":                    "STASHLY_AZBLOB_SAS_TOKEN",
+		"azblob.managed-identity-client-id":   "STASHLY_AZBLOB_MANAGED_IDENTITY_CLIENT_ID",
+		"azblob.endpoint":                     "STASHLY_AZBLOB_ENDPOINT",
+		"webdav.url":                          "STASHLY_WEBDAV_URL",
+		"webdav.prefix":                       "STASHLY_WEBDAV_PREFIX",
+		"webdav.username":                     "STASHLY_WEBDAV_USERNAME",
+		"webdav.password":                     "STASHLY_WEBDAV_PASSWORD",
+		"webdav.token":                        "STASHLY_WEBDAV_TOKEN",
+		"ftp.host":                            "STASHLY_FTP_HOST",
+		"ftp.prefix":                          "STASHLY_FTP_PREFIX",
+		"ftp.username":                        "STASHLY_FTP_USERNAME",
+		"ftp.password":                        "STASHLY_FTP_PASSWORD",
+		"ftp.tls":                             "STASHLY_FTP_TLS",
+		"ftp.disable-epsv":                    "STASHLY_FTP_DISABLE_EPSV",
+		"ftp.timeout":                         "STASHLY_FTP_TIMEOUT",
+		"rclone.remote":                       "STASHLY_RCLONE_REMOTE",
+		"rclone.prefix":                       "STASHLY_RCLONE_PREFIX",
+		"rclone.config-file":                  "STASHLY_RCLONE_CONFIG_FILE",
+		"rclone.flags":                        "STASHLY_RCLONE_FLAGS",
+		"backup.engine":                       "STASHLY_BACKUP_ENGINE",
+		"backup.retention-count":              "STASHLY_BACKUP_RETENTION_COUNT",
+		"backup.retention-min-count":          "STASHLY_BACKUP_RETENTION_MIN_COUNT",
+		"backup.purge-max-percent":            "STASHLY_BACKUP_PURGE_MAX_PERCENT",
+		"backup.fleet-concurrency":            "STASHLY_BACKUP_FLEET_CONCURRENCY",
+		"backup.date-time-layout":             "STASHLY_BACKUP_DATE_TIME_LAYOUT",
+		"backup.cron":                         "STASHLY_BACKUP_CRON",
+		"backup.encrypt":                      "STASHLY_BACKUP_ENCRYPT",
+		"backup.encrypt-manifest":             "STASHLY_BACKUP_ENCRYPT_MANIFEST",
+		"backup.encrypt-framed":               "STASHLY_BACKUP_ENCRYPT_FRAMED",
+		"backup.mode":                         "STASHLY_BACKUP_MODE",
+		"backup.snapshot-ttl":                 "STASHLY_BACKUP_SNAPSHOT_TTL",
+		"backup.duration-warning":             "STASHLY_BACKUP_DURATION_WARNING",
+		"backup.rpo-threshold":                "STASHLY_BACKUP_RPO_THRESHOLD",
+		"backup.volume-size-mb":               "STASHLY_BACKUP_VOLUME_SIZE_MB",
+		"backup.max-run-duration":             "STASHLY_BACKUP_MAX_RUN_DURATION",
+		"backup.watchdog-restart":             "STASHLY_BACKUP_WATCHDOG_RESTART",
+		"backup.compressor":                   "STASHLY_BACKUP_COMPRESSOR",
+		"backup.tier-after":                   "STASHLY_BACKUP_TIER_AFTER",
+		"backup.tier-storage-class":           "STASHLY_BACKUP_TIER_STORAGE_CLASS",
+		"backup.purge-rate":                   "STASHLY_BACKUP_PURGE_RATE",
+		"backup.database-retries":             "STASHLY_BACKUP_DATABASE_RETRIES",
+		"backup.database-retry-delay":         "STASHLY_BACKUP_DATABASE_RETRY_DELAY",
+		"backup.order":                        "STASHLY_BACKUP_ORDER",
+		"backup.priority":                     "STASHLY_BACKUP_PRIORITY",
+		"restore.download-concurrency":        "STASHLY_RESTORE_DOWNLOAD_CONCURRENCY",
+		"restore.download-part-size-mb":       "STASHLY_RESTORE_DOWNLOAD_PART_SIZE_MB",
+		"restore.template":                    "STASHLY_RESTORE_TEMPLATE",
+		"restore.rto-threshold":               "STASHLY_RESTORE_RTO_THRESHOLD",
+		"restore.drill.cron":                  "STASHLY_RESTORE_DRILL_CRON",
+		"restore.drill.host":                  "STASHLY_RESTORE_DRILL_HOST",
+		"restore.drill.port":                  "STASHLY_RESTORE_DRILL_PORT",
+		"restore.drill.user":                  "STASHLY_RESTORE_DRILL_USER",
+		"restore.drill.password":              "STASHLY_RESTORE_DRILL_PASSWORD",
+		"restore.drill.window":                "STASHLY_RESTORE_DRILL_WINDOW",
+		"encryption.gpg.key-server":           "STASHLY_ENCRYPTION_GPG_KEY_SERVER",
+		"encryption.gpg.key-id":               "STASHLY_ENCRYPTION_GPG_KEY_ID",
+		"encryption.gpg.private-key-file":     "STASHLY_ENCRYPTION_GPG_PRIVATE_KEY_FILE",
+		"encryption.gpg.passphrase":           "STASHLY_ENCRYPTION_GPG_PASSPHRASE",
+		"encryption.gpg.agent":                "STASHLY_ENCRYPTION_GPG_AGENT",
+		"encryption.gpg.expiry-warning-days":  "STASHLY_ENCRYPTION_GPG_EXPIRY_WARNING_DAYS",
+		"encryption.gpg.require-key-proof":    "STASHLY_ENCRYPTION_GPG_REQUIRE_KEY_PROOF",
+		"encryption.gpg.key-proof-file":       "STASHLY_ENCRYPTION_GPG_KEY_PROOF_FILE",
+		"encryption.compliance":               "STASHLY_ENCRYPTION_COMPLIANCE",
+		"encryption.audit-log":                "STASHLY_ENCRYPTION_AUDIT_LOG",
+		"notifiers.enabled":                   "STASHLY_NOTIFIERS_ENABLED",
+		"notifiers.dedup-window":              "STASHLY_NOTIFIERS_DEDUP_WINDOW",
+		"notifiers.time-format":               "STASHLY_NOTIFIERS_TIME_FORMAT",
+		"notifiers.timezone":                  "STASHLY_NOTIFIERS_TIMEZONE",
+		"notifiers.locale":                    "STASHLY_NOTIFIERS_LOCALE",
+		"notifiers.first-backup":              "STASHLY_NOTIFIERS_FIRST_BACKUP",
+		"notifiers.redact":                    "STASHLY_NOTIFIERS_REDACT",
+		"notifiers.discord.enabled":           "STASHLY_NOTIFIERS_DISCORD_ENABLED",
+		"notifiers.discord.webhook":           "STASHLY_NOTIFIERS_DISCORD_WEBHOOK",
+		"notifiers.discord.failure-thread-id": "STASHLY_NOTIFIERS_DISCORD_FAILURE_THREAD_ID",
+		"notifiers.discord.mention-role":      "STASHLY_NOTIFIERS_DISCORD_MENTION_ROLE",
+		"notifiers.discord.mention-user":      "STASHLY_NOTIFIERS_DISCORD_MENTION_USER",
+		"notifiers.discord.max-per-hour":      "STASHLY_NOTIFIERS_DISCORD_MAX_PER_HOUR",
+		"logger.level":                        "STASHLY_LOGGER_LEVEL",
+		"logger.mode":                         "STASHLY_LOGGER_MODE",
+		"app.instance-id":                     "STASHLY_APP_INSTANCE_ID",
+		"app.update-check":                    "STASHLY_APP_UPDATE_CHECK",
+		"server.enabled":                      "STASHLY_SERVER_ENABLED",
+		"server.listen-addr":                  "STASHLY_SERVER_LISTEN_ADDR",
+		"server.admin-token":                  "STASHLY_SERVER_ADMIN_TOKEN",
+		"server.maintenance-file":             "STASHLY_SERVER_MAINTENANCE_FILE",
+		"proxy.url":                           "STASHLY_PROXY_URL",
+		"proxy.no-proxy":                      "STASHLY_PROXY_NO_PROXY",
+		"tls.ca-file":                         "STASHLY_TLS_CA_FILE",
+		"tls.cert-file":                       "STASHLY_TLS_CERT_FILE",
+		"tls.key-file":                        "STASHLY_TLS_KEY_FILE",
+		"tls.insecure-skip-verify":            "STASHLY_TLS_INSECURE_SKIP_VERIFY",
+		"catalog.enabled":                     "STASHLY_CATALOG_ENABLED",
+		"catalog.path":                        "STASHLY_CATALOG_PATH",
+		"catalog.reconcile-interval":          "STASHLY_CATALOG_RECONCILE_INTERVAL",
+		"kubernetes.enabled":                  "STASHLY_KUBERNETES_ENABLED",
+		"kubernetes.namespace":                "STASHLY_KUBERNETES_NAMESPACE",
+		"kubernetes.image":                    "STASHLY_KUBERNETES_IMAGE",
+		"kubernetes.service-account":          "STASHLY_KUBERNETES_SERVICE_ACCOUNT",
+		"kubernetes.env-from-secret":          "STASHLY_KUBERNETES_ENV_FROM_SECRET",
+		"kubernetes.config-map":               "STASHLY_KUBERNETES_CONFIG_MAP",
+		"kubernetes.timeout":                  "STASHLY_KUBERNETES_TIMEOUT",
+		"kubernetes.ttl-after-finished":       "STASHLY_KUBERNETES_TTL_AFTER_FINISHED",
+		"discovery.provider":                  "STASHLY_DISCOVERY_PROVIDER",
+		"discovery.url":                       "STASHLY_DISCOVERY_URL",
+		"discovery.token":                     "STASHLY_DISCOVERY_TOKEN",
+		"discovery.consul.service":            "STASHLY_DISCOVERY_CONSUL_SERVICE",
+		"discovery.consul.tag":                "STASHLY_DISCOVERY_CONSUL_TAG",
+		"discovery.consul.datacenter":         "STASHLY_DISCOVERY_CONSUL_DATACENTER",
+		"discovery.etcd.prefix":               "STASHLY_DISCOVERY_ETCD_PREFIX",
+		"discovery.etcd.username":             "STASHLY_DISCOVERY_ETCD_USERNAME",
+		"discovery.etcd.password":             "STASHLY_DISCOVERY_ETCD_PASSWORD",
+	}
+
+	for _, backend := range storageBackends {
+		for _, key := range []string{"max-attempts", "backoff", "max-backoff", "retry-on"} {
+			configKey := backend + ".retry." + key
+			envBindings[configKey] = "STASHLY_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(configKey))
+		}
 	}
 
 	for configKey, envVar := range envBindings {
@@ -161,9 +919,47 @@ func LoadConfig(ctx context.Context, configPath string) (*Config, error) {
 	v.SetDefault("postgres.host", constants.DefaultPostgresHost)
 	v.SetDefault("postgres.port", constants.DefaultPostgresPort)
 	v.SetDefault("postgres.port", "5432")
+	v.SetDefault("postgres.list-timeout", constants.DefaultListTimeout)
+	v.SetDefault("s3.credentials-refresh", constants.DefaultCredentialsRefresh)
+	v.SetDefault("s3.storage-price-per-gb", constants.DefaultStoragePricePerGB)
+	v.SetDefault("s3.request-price-per-1000", constants.DefaultRequestPricePer1000)
+	v.SetDefault("s3.part-size-mb", constants.DefaultUploadPartSizeMB)
+	v.SetDefault("s3.upload-concurrency", constants.DefaultUploadConcurrency)
+	v.SetDefault("ftp.timeout", constants.DefaultFTPTimeout)
+	v.SetDefault("storage.backend", constants.DefaultStorageBackend)
+	v.SetDefault("storage.upload-attempts", constants.DefaultUploadAttempts)
+	v.SetDefault("storage.upload-retry-delay", constants.DefaultUploadRetryDelay)
+	for _, backend := range storageBackends {
+		v.SetDefault(backend+".retry.max-attempts", constants.DefaultRetryAttempts)
+		v.SetDefault(backend+".retry.backoff", constants.DefaultRetryBackoff)
+		v.SetDefault(backend+".retry.max-backoff", constants.DefaultRetryMaxBackoff)
+	}
+	v.SetDefault("backup.engine", constants.DefaultEngine)
 	v.SetDefault("backup.retention-count", constants.DefaultRetentionCount)
+	v.SetDefault("backup.retention-min-count", constants.DefaultRetentionMinCount)
+	v.SetDefault("backup.purge-max-percent", constants.DefaultPurgeMaxPercent)
+	v.SetDefault("backup.fleet-concurrency", constants.DefaultFleetConcurrency)
 	v.SetDefault("backup.date-time-layout", constants.DefaultDateTimeLayout)
 	v.SetDefault("backup.cron", constants.DefaultCron)
+	v.SetDefault("backup.mode", ModeSchedule)
+	v.SetDefault("backup.snapshot-ttl", constants.DefaultSnapshotTTL)
+	v.SetDefault("backup.tier-storage-class", constants.DefaultTierStorageClass)
+	v.SetDefault("backup.database-retries", constants.DefaultDatabaseRetries)
+	v.SetDefault("backup.database-retry-delay", constants.DefaultDatabaseRetryDelay)
+	v.SetDefault("encryption.gpg.expiry-warning-days", constants.DefaultKeyExpiryWarningDays)
+	v.SetDefault("encryption.gpg.key-proof-file", constants.DefaultKeyProofPath)
+	v.SetDefault("encryption.audit-log", constants.DefaultAuditLogPath)
+	v.SetDefault("restore.download-concurrency", constants.DefaultDownloadConcurrency)
+	v.SetDefault("restore.download-part-size-mb", constants.DefaultDownloadPartSizeMB)
+	v.SetDefault("restore.drill.window", constants.DefaultDrillWindow)
+	v.SetDefault("notifiers.time-format", constants.DefaultNotifierTimeFormat)
+	v.SetDefault("notifiers.first-backup", true)
+	v.SetDefault("server.listen-addr", constants.DefaultServerListenAddr)
+	v.SetDefault("server.maintenance-file", constants.DefaultMaintenancePath)
+	v.SetDefault("catalog.path", constants.DefaultCatalogPath)
+	v.SetDefault("catalog.reconcile-interval", constants.DefaultCatalogReconcileInterval)
+	v.SetDefault("kubernetes.timeout", constants.DefaultKubernetesJobTimeout)
+	v.SetDefault("kubernetes.ttl-after-finished", constants.DefaultKubernetesJobTTL)
 	v.SetDefault("logger.level", commonLogger.DefaultLoggerLevel)
 	v.SetDefault("logger.mode", commonLogger.DefaultLoggerMode)
 	v.SetDefault("app.instance-id", commonUtils.GetHostname())
@@ -173,16 +969,59 @@ func LoadConfig(ctx context.Context, configPath string) (*Config, error) {
 		return nil, err
 	}
 
+	// In auto mode a cron set in the config file or environment opts into scheduling; the default cron doesn't.
+	cronConfigured := v.InConfig("backup.cron") || os.Getenv("STASHLY_BACKUP_CRON") != ""
+
 	// Initialize logger
 	commonLogger.InitLogger(&cfg.Logger.Level, &cfg.Logger.Mode)
 
 	// Encryption sanity check
 	if cfg.Backup.Encrypt {
-		if cfg.Encryption.GPG.KeyServer == "" || cfg.Encryption.GPG.KeyID == "" {
+		switch {
+		case cfg.Encryption.GPG.KeyServer != "" && cfg.Encryption.GPG.KeyID != "":
+		case cfg.Encryption.Compliance:
+			// Compliance mode never falls back to plaintext; backups fail until the key is set.
+			slog.ErrorContext(ctx, "GPG encryption enabled but key-server/key-id not set; backups will fail in compliance mode")
+		default:
 			slog.WarnContext(ctx, "GPG encryption enabled but key-server/key-id not set; disabling encryption")
 			cfg.Backup.Encrypt = false
 		}
 	}
+	if cfg.Encryption.Compliance && !cfg.Backup.Encrypt {
+		slog.ErrorContext(ctx, "Encryption compliance mode is on but backup.encrypt is off; backups will fail")
+	}
+	if cfg.Backup.EncryptManifest && !cfg.Backup.Encrypt {
+		slog.WarnContext(ctx, "Manifest encryption needs backup encryption; storing manifests in plaintext")
+		cfg.Backup.EncryptManifest = false
+	}
+	switch {
+	case cfg.Backup.EncryptFramed && !cfg.Backup.Encrypt:
+		slog.WarnContext(ctx, "Framed encryption needs backup encryption; ignoring backup.encrypt-framed")
+		cfg.Backup.EncryptFramed = false
+	case cfg.Backup.EncryptFramed && cfg.Encryption.Compliance:
+		slog.WarnContext(ctx, "Compliance mode audits OpenPGP messages only; encrypting archives as OpenPGP messages")
+		cfg.Backup.EncryptFramed = false
+	}
+
+	// Kubernetes sanity check
+	if cfg.Kubernetes.Enabled && cfg.Kubernetes.Image == "" {
+		slog.WarnContext(ctx, "Kubernetes job execution enabled but image not set; running backups in-process")
+		cfg.Kubernetes.Enabled = false
+	}
+
+	// Database retries sanity check
+	if cfg.Backup.DatabaseRetries < 0 {
+		slog.WarnContext(ctx, "Negative backup.database-retries; not retrying failed databases", "retries", cfg.Backup.DatabaseRetries)
+		cfg.Backup.DatabaseRetries = 0
+	}
+
+	// Dump order sanity check
+	switch cfg.Backup.Order {
+	case "", OrderLargestFirst, OrderSmallestFirst:
+	default:
+		slog.WarnContext(ctx, "Unknown backup order; dumping databases in server order", "order", cfg.Backup.Order)
+		cfg.Backup.Order = ""
+	}
 
 	// Notifiers sanity check
 	if cfg.Notifiers.Discord.Enabled {
@@ -192,5 +1031,94 @@ func LoadConfig(ctx context.Context, configPath string) (*Config, error) {
 		}
 	}
 
+	// Storage failover sanity check
+	if cfg.Storage.Secondary == cfg.Storage.Backend {
+		slog.WarnContext(ctx, "storage.secondary is the primary backend; disabling failover")
+		cfg.Storage.Secondary = ""
+	}
+	if cfg.Storage.UploadAttempts < 1 {
+		cfg.Storage.UploadAttempts = 1
+	}
+
+	// Webhooks sanity check
+	webhooks := cfg.Webhooks[:0]
+	for _, hook := range cfg.Webhooks {
+		if hook.URL == "" {
+			slog.WarnContext(ctx, "Webhook missing url; ignoring it")
+			continue
+		}
+		webhooks = append(webhooks, hook)
+	}
+	cfg.Webhooks = webhooks
+
+	// Fleet sanity check
+	fleet := cfg.Postgres.Fleet[:0]
+	names := map[string]bool{}
+	for _, host := range cfg.Postgres.Fleet {
+		switch {
+		case host.Name == "" || host.Host == "":
+			slog.WarnContext(ctx, "Fleet host missing name or host; ignoring it", "name", host.Name, "host", host.Host)
+			continue
+		case strings.ContainsAny(host.Name, "/\\") || host.Name == "." || host.Name == "..":
+			slog.WarnContext(ctx, "Fleet host name can't be used in storage keys; ignoring it", "name", host.Name)
+			continue
+		case names[host.Name]:
+			slog.WarnContext(ctx, "Duplicate fleet host name; ignoring it", "name", host.Name)
+			continue
+		}
+		names[host.Name] = true
+		fleet = append(fleet, host)
+	}
+	cfg.Postgres.Fleet = fleet
+	if cfg.Backup.FleetConcurrency < 1 {
+		cfg.Backup.FleetConcurrency = 1
+	}
+
+	// Restore drill sanity check
+	drill := cfg.Restore.Drill
+	if drill.Host != "" && drill.Host == cfg.Postgres.Host && cmp.Or(drill.Port, cfg.Postgres.Port) == cfg.Postgres.Port {
+		slog.WarnContext(ctx, "restore.drill.host is the server being backed up; disabling drills")
+		cfg.Restore.Drill.Host = ""
+	}
+	if cfg.Restore.Drill.Cron != "" && cfg.Restore.Drill.Host == "" {
+		slog.WarnContext(ctx, "Restore drills scheduled without a scratch server in restore.drill.host; disabling drills")
+		cfg.Restore.Drill.Cron = ""
+	}
+
+	// Backup mode sanity check
+	switch cfg.Backup.Mode {
+	case ModeSchedule, ModeOnce:
+	case ModeAuto:
+		cfg.Backup.Mode = ModeOnce
+		if cronConfigured {
+			cfg.Backup.Mode = ModeSchedule
+		}
+	default:
+		slog.WarnContext(ctx, "Unknown backup mode; running scheduled backups", "mode", cfg.Backup.Mode)
+		cfg.Backup.Mode = ModeSchedule
+	}
+
+	if cfg.TLS.InsecureSkipVerify {
+		slog.WarnContext(ctx, "TLS certificate verification is DISABLED for storage, key server and webhook requests; "+
+			"anyone on the network path can read and tamper with backups. Set tls.ca-file instead of tls.insecure-skip-verify")
+	}
+
+	if _, err := time.LoadLocation(cfg.Notifiers.Timezone); err != nil {
+		slog.WarnContext(ctx, "Invalid notifier timezone; falling back to local time", "timezone", cfg.Notifiers.Timezone, "error", err)
+		cfg.Notifiers.Timezone = ""
+	}
+	if l := cfg.Notifiers.Locale; l != "" && !slices.Contains(NotifierLocales, l) {
+		slog.WarnContext(ctx, "Unknown notifier locale; falling back to English", "locale", l, "available", NotifierLocales)
+		cfg.Notifiers.Locale = ""
+	}
+
+	// Redaction sanity check; an unknown mode hashes rather than leaking names.
+	switch cfg.Notifiers.Redact {
+	case "", RedactHash, RedactMask:
+	default:
+		slog.WarnContext(ctx, "Unknown notifier redaction mode; hashing names", "redact", cfg.Notifiers.Redact)
+		cfg.Notifiers.Redact = RedactHash
+	}
+
 	return cfg, nil
 }
@@ -5,7 +5,9 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/hibare/stashly/internal/constants"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v3"
@@ -105,6 +107,74 @@ func TestLoadConfig_EncryptSanityCheck(t *testing.T) {
 	assert.False(t, cfg.Backup.Encrypt)
 }
 
+func TestLoadConfig_EncryptComplianceKeepsEncrypt(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	// Encryption enabled but no key-server/key-id, in compliance mode
+	content := map[string]interface{}{
+		"backup": map[string]interface{}{
+			"encrypt": true,
+		},
+		"encryption": map[string]interface{}{
+			"compliance": true,
+		},
+	}
+
+	//nolint:gosec // Safe in tests - using t.TempDir()
+	f, err := os.Create(configFile)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+	_ = yaml.NewEncoder(f).Encode(content)
+
+	cfg, err := LoadConfig(t.Context(), configFile)
+	require.NoError(t, err)
+
+	// Compliance mode never falls back to plaintext
+	assert.True(t, cfg.Backup.Encrypt)
+	assert.Equal(t, constants.DefaultAuditLogPath, cfg.Encryption.AuditLog)
+}
+
+func TestLoadConfig_EncryptFramedSanityCheck(t *testing.T) {
+	gpg := map[string]string{"key-server": "hkp://keyserver.ubuntu.com", "key-id": "VALID123"}
+	tests := []struct {
+		name       string
+		encrypt    bool
+		compliance bool
+		want       bool
+	}{
+		{name: "with encryption", encrypt: true, want: true},
+		{name: "without encryption"},
+		{name: "compliance mode", encrypt: true, compliance: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			content := map[string]interface{}{
+				"backup": map[string]interface{}{
+					"encrypt":        tt.encrypt,
+					"encrypt-framed": true,
+				},
+				"encryption": map[string]interface{}{
+					"compliance": tt.compliance,
+					"gpg":        gpg,
+				},
+			}
+
+			//nolint:gosec // Safe in tests - using t.TempDir()
+			f, err := os.Create(configFile)
+			require.NoError(t, err)
+			defer func() { _ = f.Close() }()
+			require.NoError(t, yaml.NewEncoder(f).Encode(content))
+
+			cfg, err := LoadConfig(t.Context(), configFile)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, cfg.Backup.EncryptFramed)
+		})
+	}
+}
+
 func TestLoadConfig_DiscordSanityCheck(t *testing.T) {
 	tmpDir := t.TempDir()
 	configFile := filepath.Join(tmpDir, "config.yaml")
@@ -132,6 +202,26 @@ func TestLoadConfig_DiscordSanityCheck(t *testing.T) {
 	assert.False(t, cfg.Notifiers.Discord.Enabled)
 }
 
+func TestLoadConfig_AutoMode(t *testing.T) {
+	t.Setenv("STASHLY_BACKUP_MODE", ModeAuto)
+
+	// No cron configured: run once.
+	cfg, err := LoadConfig(t.Context(), "")
+	require.NoError(t, err)
+	assert.Equal(t, ModeOnce, cfg.Backup.Mode)
+
+	// A configured cron schedules backups.
+	t.Setenv("STASHLY_BACKUP_CRON", "0 3 * * *")
+	cfg, err = LoadConfig(t.Context(), "")
+	require.NoError(t, err)
+	assert.Equal(t, ModeSchedule, cfg.Backup.Mode)
+
+	t.Setenv("STASHLY_BACKUP_MODE", "sometimes")
+	cfg, err = LoadConfig(t.Context(), "")
+	require.NoError(t, err)
+	assert.Equal(t, ModeSchedule, cfg.Backup.Mode)
+}
+
 func TestLoadConfig_InvalidFile(t *testing.T) {
 	tmpDir := t.TempDir()
 	configFile := filepath.Join(tmpDir, "config.yaml")
@@ -466,3 +556,181 @@ func TestLoadConfig_EnvironmentVariablePriority(t *testing.T) {
 	assert.Equal(t, "5434", cfg.Postgres.Port)
 	assert.Equal(t, 15, cfg.Backup.RetentionCount)
 }
+
+func TestConfig_Redacted(t *testing.T) {
+	cfg := &Config{
+		Postgres:  PostgresConfig{Host: "db", Password: "pg-secret", Fleet: []PostgresHost{{Name: "eu", Host: "db-eu", Password: "eu-secret"}}},
+		S3:        S3Config{AccessKey: "ak", SecretKey: "sk"},
+		Server:    ServerConfig{AdminToken: "admin"},
+		Webhooks:  []WebhookConfig{{URL: "https://example.com/hook?token=x", Secret: "hmac", Events: []string{"backup.failed"}}},
+		Discovery: DiscoveryConfig{URL: "https://inventory.internal/dbs", Token: "inventory", Etcd: EtcdDiscoveryConfig{Prefix: "/dbs/", Password: "etcd"}},
+		Restore:   RestoreConfig{Drill: DrillConfig{Host: "scratch", Password: "drill"}},
+	}
+
+	r := cfg.Redacted()
+	assert.Equal(t, "db", r.Postgres.Host)
+	assert.Empty(t, r.Postgres.Password)
+	assert.Equal(t, "db-eu", r.Postgres.Fleet[0].Host)
+	assert.Empty(t, r.Postgres.Fleet[0].Password)
+	assert.Empty(t, r.S3.AccessKey)
+	assert.Empty(t, r.S3.SecretKey)
+	assert.Empty(t, r.Server.AdminToken)
+	assert.Empty(t, r.Webhooks[0].URL)
+	assert.Empty(t, r.Webhooks[0].Secret)
+	assert.Equal(t, []string{"backup.failed"}, r.Webhooks[0].Events)
+	assert.Empty(t, r.Discovery.Token)
+	assert.Empty(t, r.Discovery.Etcd.Password)
+	assert.Equal(t, "/dbs/", r.Discovery.Etcd.Prefix)
+	assert.Empty(t, r.Restore.Drill.Password)
+
+	// The original is left untouched.
+	assert.Equal(t, "pg-secret", cfg.Postgres.Password)
+	assert.Equal(t, "hmac", cfg.Webhooks[0].Secret)
+	assert.Equal(t, "eu-secret", cfg.Postgres.Fleet[0].Password)
+}
+
+func TestConfig_ForHost(t *testing.T) {
+	cfg := &Config{
+		App:      AppConfig{InstanceID: "prod"},
+		Postgres: PostgresConfig{Host: "db", Port: "5432", User: "backup", Password: "shared", DumpHost: "pgbouncer", Nodes: []string{"a", "b"}},
+		Catalog:  CatalogConfig{Path: "/var/lib/stashly/catalog.json"},
+	}
+	cfg.Postgres.Fleet = []PostgresHost{{Name: "eu"}}
+
+	h := cfg.ForHost(PostgresHost{Name: "eu", Host: "db-eu", Port: "6432"})
+	assert.Equal(t, "prod/eu", h.App.InstanceID)
+	assert.Equal(t, "eu", h.Postgres.Name)
+	assert.Equal(t, "db-eu", h.Postgres.Host)
+	assert.Equal(t, "6432", h.Postgres.Port)
+	assert.Equal(t, "backup", h.Postgres.User)
+	assert.Equal(t, "shared", h.Postgres.Password)
+	assert.Empty(t, h.Postgres.DumpHost)
+	assert.Nil(t, h.Postgres.Nodes)
+	assert.Nil(t, h.Postgres.Fleet)
+	assert.Equal(t, "/var/lib/stashly/catalog-eu.json", h.Catalog.Path)
+
+	// The original is left untouched.
+	assert.Equal(t, "prod", cfg.App.InstanceID)
+	assert.Equal(t, "db", cfg.Postgres.Host)
+}
+
+func TestConfig_ForDrill(t *testing.T) {
+	cfg := &Config{
+		Postgres: PostgresConfig{Host: "db", Port: "5432", User: "backup", Password: "shared", DumpHost: "pgbouncer", Nodes: []string{"a", "b"}},
+		Restore:  RestoreConfig{Drill: DrillConfig{Host: "scratch", User: "drill"}},
+	}
+
+	d := cfg.ForDrill()
+	assert.Equal(t, "scratch", d.Postgres.Host)
+	assert.Equal(t, "5432", d.Postgres.Port)
+	assert.Equal(t, "drill", d.Postgres.User)
+	assert.Equal(t, "shared", d.Postgres.Password)
+	assert.Empty(t, d.Postgres.DumpHost)
+	assert.Nil(t, d.Postgres.Nodes)
+	assert.Equal(t, "db", cfg.Postgres.Host)
+}
+
+func TestConfig_AtLocation(t *testing.T) {
+	cfg := &Config{
+		S3:      S3Config{Prefix: "backups"},
+		GCS:     GCSConfig{Prefix: "backups"},
+		App:     AppConfig{InstanceID: "db-1"},
+		Catalog: CatalogConfig{Enabled: true},
+	}
+
+	l := cfg.AtLocation("old/host-1")
+	assert.Equal(t, "old/host-1", l.S3.Prefix)
+	assert.Equal(t, "old/host-1", l.GCS.Prefix)
+	assert.Equal(t, "old/host-1", l.Rclone.Prefix)
+	assert.Empty(t, l.App.InstanceID)
+	assert.False(t, l.Catalog.Enabled)
+	assert.Equal(t, "backups", cfg.S3.Prefix)
+	assert.Equal(t, "db-1", cfg.App.InstanceID)
+}
+
+func TestLoadConfig_DrillSanityCheck(t *testing.T) {
+	t.Setenv("STASHLY_RESTORE_DRILL_CRON", "0 3 * * 0")
+	cfg, err := LoadConfig(t.Context(), "")
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Restore.Drill.Cron, "drills need a scratch server")
+	assert.Equal(t, 192*time.Hour, cfg.Restore.Drill.Window)
+
+	t.Setenv("STASHLY_POSTGRES_HOST", "db")
+	t.Setenv("STASHLY_RESTORE_DRILL_HOST", "db")
+	cfg, err = LoadConfig(t.Context(), "")
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Restore.Drill.Host, "drills must not restore into the backed up server")
+	assert.Empty(t, cfg.Restore.Drill.Cron)
+
+	t.Setenv("STASHLY_RESTORE_DRILL_HOST", "scratch")
+	cfg, err = LoadConfig(t.Context(), "")
+	require.NoError(t, err)
+	assert.Equal(t, "0 3 * * 0", cfg.Restore.Drill.Cron)
+}
+
+func TestLoadConfig_Objectives(t *testing.T) {
+	t.Setenv("STASHLY_BACKUP_RPO_THRESHOLD", "26h")
+	t.Setenv("STASHLY_RESTORE_RTO_THRESHOLD", "4h")
+
+	cfg, err := LoadConfig(t.Context(), "")
+	require.NoError(t, err)
+	assert.Equal(t, 26*time.Hour, cfg.Backup.RPOThreshold)
+	assert.Equal(t, 4*time.Hour, cfg.Restore.RTOThreshold)
+}
+
+func TestLoadConfig_FleetSanityCheck(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	content := map[string]interface{}{
+		"postgres": map[string]interface{}{
+			"fleet": []map[string]interface{}{
+				{"name": "eu", "host": "db-eu"},
+				{"name": "us", "host": "db-us", "port": "6432"},
+				{"name": "eu", "host": "db-eu-2"},
+				{"name": "no-host"},
+				{"name": "a/b", "host": "db-ab"},
+			},
+		},
+	}
+	data, err := yaml.Marshal(content)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(configFile, data, 0o600))
+
+	cfg, err := LoadConfig(t.Context(), configFile)
+	require.NoError(t, err)
+	assert.Equal(t, []PostgresHost{{Name: "eu", Host: "db-eu"}, {Name: "us", Host: "db-us", Port: "6432"}}, cfg.Postgres.Fleet)
+	assert.Equal(t, 1, cfg.Backup.FleetConcurrency)
+}
+
+func TestLoadConfig_StorageRetry(t *testing.T) {
+	t.Setenv("STASHLY_GCS_RETRY_MAX_ATTEMPTS", "5")
+	t.Setenv("STASHLY_GCS_RETRY_RETRY_ON", "throttling,network")
+
+	cfg, err := LoadConfig(t.Context(), "")
+	require.NoError(t, err)
+	assert.Equal(t, RetryConfig{MaxAttempts: 3, Backoff: time.Second, MaxBackoff: 30 * time.Second}, cfg.S3.Retry)
+	assert.Equal(t, 5, cfg.GCS.Retry.MaxAttempts)
+	assert.Equal(t, []string{"throttling", "network"}, cfg.GCS.Retry.RetryOn)
+}
+
+func TestLoadConfig_NotifierLocale(t *testing.T) {
+	for locale, want := range map[string]string{"de": "de", "klingon": ""} {
+		t.Run(locale, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			content := map[string]interface{}{
+				"notifiers": map[string]interface{}{"locale": locale},
+			}
+
+			//nolint:gosec // Safe in tests - using t.TempDir()
+			f, err := os.Create(configFile)
+			require.NoError(t, err)
+			defer func() { _ = f.Close() }()
+			_ = yaml.NewEncoder(f).Encode(content)
+
+			cfg, err := LoadConfig(t.Context(), configFile)
+			require.NoError(t, err)
+
+			// Unknown locales fall back to English
+			assert.Equal(t, want, cfg.Notifiers.Locale)
+		})
+	}
+}
@@ -8,18 +8,126 @@ const (
 	// ExportDir is the directory where database exports are temporarily stored.
 	ExportDir = "db_exports"
 
+	// RestoreDir is the directory where downloaded backups are temporarily stored during a restore.
+	RestoreDir = "db_restore"
+
+	// DefaultEngine is the dump engine used when none is configured.
+	DefaultEngine = "postgres"
+
+	// DefaultStorageBackend is the storage backend used when none is configured.
+	DefaultStorageBackend = "s3"
+
 	// DefaultDateTimeLayout is the default layout for datetime strings in backup filenames.
 	DefaultDateTimeLayout = "20060102150405"
 
 	// DefaultRetentionCount is the default number of backups to retain.
 	DefaultRetentionCount = 30
 
+	// DefaultRetentionMinCount is the default number of backups retention never goes below.
+	DefaultRetentionMinCount = 1
+
+	// DefaultPurgeMaxPercent is the default largest share of backups one purge may delete unforced.
+	DefaultPurgeMaxPercent = 50
+
+	// DefaultFleetConcurrency is the default number of fleet hosts backed up at once.
+	DefaultFleetConcurrency = 1
+
 	//  DefaultCron is the default cron schedule for backups (daily at midnight).
 	DefaultCron = "0 0 * * *"
 
+	// DefaultSnapshotTTL is the default time snapshots are kept before being purged.
+	DefaultSnapshotTTL = "168h"
+
+	// DefaultCredentialsRefresh is how often storage credentials read from files are re-read.
+	DefaultCredentialsRefresh = "5m"
+
+	// DefaultDatabaseRetries is how many more times a database whose dump failed with a transient error
+	// is dumped at the end of the run.
+	DefaultDatabaseRetries = 2
+
+	// DefaultDatabaseRetryDelay is the wait before the first retry round; it grows with each round.
+	DefaultDatabaseRetryDelay = "30s"
+
+	// DefaultUploadAttempts is how many times an upload to the primary backend is tried before it
+	// fails over to storage.secondary.
+	DefaultUploadAttempts = 3
+
+	// DefaultUploadRetryDelay is the wait before the second upload attempt; it grows with each attempt.
+	DefaultUploadRetryDelay = "5s"
+
+	// DefaultRetryAttempts is how many times a storage call failing with a transient error is tried.
+	DefaultRetryAttempts = 3
+
+	// DefaultRetryBackoff is the wait before retrying a storage call; it doubles with each attempt.
+	DefaultRetryBackoff = "1s"
+
+	// DefaultRetryMaxBackoff caps the wait between storage call attempts.
+	DefaultRetryMaxBackoff = "30s"
+
+	// DefaultListTimeout bounds listing the databases on the server.
+	DefaultListTimeout = "30s"
+
+	// DefaultFTPTimeout bounds connecting to the FTP server and each of its responses.
+	DefaultFTPTimeout = "30s"
+
+	// DefaultTierStorageClass is the storage class old backups are tiered to. Glacier Instant Retrieval
+	// keeps them restorable without a separate restore request.
+	DefaultTierStorageClass = "GLACIER_IR"
+
+	// DefaultKeyExpiryWarningDays is how many days before the GPG key expires warnings start.
+	DefaultKeyExpiryWarningDays = 30
+
 	// DefaultPostgresHost is the default host for the postgres database.
 	DefaultPostgresHost = "127.0.0.1"
 
 	// DefaultPostgresPort is the default port for the postgres database.
 	DefaultPostgresPort = "5432"
+
+	// DefaultServerListenAddr is the default listen address for the daemon's HTTP server.
+	DefaultServerListenAddr = ":8080"
+
+	// DefaultCatalogPath is where the local backup catalog is kept.
+	DefaultCatalogPath = "/var/lib/stashly/catalog.json"
+
+	// DefaultKeyProofPath is where the proof of possession of the GPG private key is kept.
+	DefaultKeyProofPath = "/var/lib/stashly/key-proof.json"
+
+	// DefaultAuditLogPath is where encryption compliance mode records uploaded objects.
+	DefaultAuditLogPath = "/var/lib/stashly/audit.log"
+
+	// DefaultMaintenancePath is where maintenance mode is persisted across restarts.
+	DefaultMaintenancePath = "/var/lib/stashly/maintenance.json"
+
+	// DefaultCatalogReconcileInterval is how long the local catalog is trusted before it is checked against storage.
+	DefaultCatalogReconcileInterval = "1h"
+
+	// DefaultKubernetesJobTimeout is how long the scheduler waits for a backup Job to finish.
+	DefaultKubernetesJobTimeout = "6h"
+
+	// DefaultKubernetesJobTTL is how long finished backup Jobs are kept before Kubernetes deletes them.
+	DefaultKubernetesJobTTL = "24h"
+
+	// DefaultDownloadConcurrency is the default number of parallel ranged GETs used when downloading a backup.
+	DefaultDownloadConcurrency = 4
+
+	// DefaultDownloadPartSizeMB is the default size in MiB of each ranged GET when downloading a backup.
+	DefaultDownloadPartSizeMB = 16
+
+	// DefaultDrillWindow is how long restore drills may go without passing before an alert is sent.
+	DefaultDrillWindow = "192h"
+
+	// DefaultUploadConcurrency is the default number of parts uploaded in parallel to S3.
+	DefaultUploadConcurrency = 4
+
+	// DefaultUploadPartSizeMB is the default size in MiB of each part of a multipart upload to S3.
+	DefaultUploadPartSizeMB = 64
+
+	// DefaultStoragePricePerGB is the default monthly price per GB stored (AWS S3 Standard, us-east-1).
+	DefaultStoragePricePerGB = 0.023
+
+	// DefaultRequestPricePer1000 is the default price per 1000 PUT/LIST requests (AWS S3 Standard, us-east-1).
+	DefaultRequestPricePer1000 = 0.005
+
+	// DefaultNotifierTimeFormat is the default timestamp format used in notifications.
+	DefaultNotifierTimeFormat = "24h"
 )
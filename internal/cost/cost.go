@@ -0,0 +1,54 @@
+// Package cost estimates what storing backups costs per month.
+package cost
+
+import "fmt"
+
+// gb is the unit storage providers bill in (1 GB-month = 2^30 bytes stored for a month).
+const gb = 1 << 30
+
+// Prices are the storage provider's list prices, in whatever currency they are configured in.
+type Prices struct {
+	// StoragePerGB is the price of storing one GB for a month.
+	StoragePerGB float64
+
+	// RequestsPer1000 is the price of 1000 write (PUT/LIST) requests.
+	RequestsPer1000 float64
+}
+
+// Estimate is an estimated monthly cost.
+type Estimate struct {
+	Storage  float64
+	Requests float64
+}
+
+// Total returns the storage and request cost combined.
+func (e Estimate) Total() float64 {
+	return e.Storage + e.Requests
+}
+
+// Storage returns the monthly cost of keeping size bytes stored.
+func (p Prices) Storage(size int64) float64 {
+	return float64(size) / gb * p.StoragePerGB
+}
+
+// Requests returns the cost of n write requests.
+func (p Prices) Requests(n int64) float64 {
+	return float64(n) / 1000 * p.RequestsPer1000
+}
+
+// Monthly estimates the monthly cost of keeping size bytes stored while making
+// requestsPerRun write requests runsPerMonth times.
+func (p Prices) Monthly(size int64, runsPerMonth int, requestsPerRun int64) Estimate {
+	return Estimate{
+		Storage:  p.Storage(size),
+		Requests: p.Requests(int64(runsPerMonth) * requestsPerRun),
+	}
+}
+
+// Format formats an amount with two decimals, showing "<0.01" for amounts too small to round to a cent.
+func Format(amount float64) string {
+	if amount > 0 && amount < 0.005 {
+		return "<0.01"
+	}
+	return fmt.Sprintf("%.2f", amount)
+}
@@ -0,0 +1,23 @@
+package cost
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrices_Monthly(t *testing.T) {
+	p := Prices{StoragePerGB: 0.023, RequestsPer1000: 0.005}
+
+	e := p.Monthly(10*gb, 30, 2)
+	assert.InDelta(t, 0.23, e.Storage, 1e-9)
+	assert.InDelta(t, 0.0003, e.Requests, 1e-9)
+	assert.InDelta(t, 0.2303, e.Total(), 1e-9)
+}
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, "0.00", Format(0))
+	assert.Equal(t, "<0.01", Format(0.0003))
+	assert.Equal(t, "0.23", Format(0.2303))
+	assert.Equal(t, "12.50", Format(12.5))
+}
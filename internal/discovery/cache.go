@@ -0,0 +1,108 @@
+package discovery
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/hibare/stashly/internal/config"
+)
+
+const (
+	// minWatchDelay and maxWatchDelay bound the wait before restarting a failed watch.
+	minWatchDelay = time.Second
+	maxWatchDelay = 5 * time.Minute
+
+	// stableWatch is how long a watch must have run for a failure to restart it after minWatchDelay again.
+	stableWatch = time.Minute
+)
+
+// Cache keeps the inventory of a watching provider in memory, so Discover answers without querying
+// it. Until the watch has delivered the inventory, and while it is being restarted after a failure,
+// Discover queries the provider instead.
+type Cache struct {
+	watcher WatcherIface
+
+	mu    sync.Mutex
+	hosts []config.PostgresHost
+	fresh bool
+}
+
+// Discover returns the watched inventory, or queries the provider if the watch isn't running.
+func (c *Cache) Discover(ctx context.Context) ([]config.PostgresHost, error) {
+	c.mu.Lock()
+	if c.fresh {
+		hosts := slices.Clone(c.hosts)
+		c.mu.Unlock()
+		return hosts, nil
+	}
+	c.mu.Unlock()
+	return c.watcher.Discover(ctx)
+}
+
+// Run watches the inventory until ctx is done, restarting the watch with a growing delay when it fails.
+func (c *Cache) Run(ctx context.Context) {
+	delay := minWatchDelay
+	for {
+		start := time.Now()
+		err := c.watcher.Watch(ctx, func(hosts []config.PostgresHost) { c.update(ctx, hosts) })
+		c.mu.Lock()
+		c.fresh = false
+		c.mu.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(start) > stableWatch {
+			delay = minWatchDelay
+		}
+		slog.WarnContext(ctx, "Watching the discovery inventory failed; restarting", "retry_in", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxWatchDelay)
+	}
+}
+
+// update stores the watched inventory, logging the servers that joined or left it.
+func (c *Cache) update(ctx context.Context, hosts []config.PostgresHost) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := func(hosts []config.PostgresHost) map[string]bool {
+		m := make(map[string]bool, len(hosts))
+		for _, h := range hosts {
+			m[h.Name] = true
+		}
+		return m
+	}
+	before, after := names(c.hosts), names(hosts)
+	added, removed := []string{}, []string{}
+	for name := range after {
+		if !before[name] {
+			added = append(added, name)
+		}
+	}
+	for name := range before {
+		if !after[name] {
+			removed = append(removed, name)
+		}
+	}
+	if len(added) > 0 || len(removed) > 0 {
+		slices.Sort(added)
+		slices.Sort(removed)
+		slog.InfoContext(ctx, "Discovery inventory changed", "added", added, "removed", removed, "hosts", len(hosts))
+	}
+
+	c.hosts = hosts
+	c.fresh = true
+}
+
+// NewCache creates a cache for watcher's inventory; it is kept current while Run runs.
+func NewCache(watcher WatcherIface) *Cache {
+	return &Cache{watcher: watcher}
+}
@@ -0,0 +1,55 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWatcher delivers the inventories sent on updates to Watch and counts Discover calls.
+type fakeWatcher struct {
+	updates   chan []config.PostgresHost
+	discovers int
+}
+
+func (f *fakeWatcher) Discover(context.Context) ([]config.PostgresHost, error) {
+	f.discovers++
+	return []config.PostgresHost{{Name: "queried"}}, nil
+}
+
+func (f *fakeWatcher) Watch(ctx context.Context, update func([]config.PostgresHost)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case hosts := <-f.updates:
+			update(hosts)
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &fakeWatcher{updates: make(chan []config.PostgresHost)}
+	c := NewCache(w)
+
+	// Until the watch delivers the inventory, the provider is queried.
+	hosts, err := c.Discover(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []config.PostgresHost{{Name: "queried"}}, hosts)
+
+	go c.Run(ctx)
+	w.updates <- []config.PostgresHost{{Name: "eu"}}
+	w.updates <- []config.PostgresHost{{Name: "eu"}, {Name: "us"}}
+
+	require.Eventually(t, func() bool {
+		hosts, _ := c.Discover(ctx)
+		return len(hosts) == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, w.discovers)
+}
@@ -0,0 +1,204 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	commonHTTP "github.com/hibare/GoCommon/v2/pkg/http"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/httpclient"
+)
+
+const (
+	// consulWait is how long a blocking query waits for a change before Consul answers anyway.
+	consulWait = "5m"
+
+	// consulNameMeta is the service meta key naming an instance.
+	consulNameMeta = "stashly-name"
+)
+
+var (
+	// ErrNoConsulService is returned when the consul provider has no discovery.consul.service.
+	ErrNoConsulService = errors.New("discovery.consul.service is not set")
+
+	// ErrNoConsulIndex is returned when Consul answers without the X-Consul-Index header blocking
+	// queries need.
+	ErrNoConsulIndex = errors.New("consul answered without an X-Consul-Index header")
+
+	// ErrNoAddress is returned for Consul service instances without a service or node address.
+	ErrNoAddress = errors.New("service instance has no address")
+)
+
+// consulEntry is the subset of a Consul health API entry Stashly uses.
+type consulEntry struct {
+	Node struct {
+		Node    string `json:"Node"`
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		ID      string            `json:"ID"`
+		Address string            `json:"Address"`
+		Port    int               `json:"Port"`
+		Meta    map[string]string `json:"Meta"`
+	} `json:"Service"`
+}
+
+// host returns the server an entry registers: the service address, or the node's if the service has
+// none, at the service port. It is named by the stashly-name service meta, or "<node>-<service ID>".
+func (e consulEntry) host() (config.PostgresHost, error) {
+	host := config.PostgresHost{
+		Name: e.Service.Meta[consulNameMeta],
+		Host: e.Service.Address,
+	}
+	if host.Host == "" {
+		host.Host = e.Node.Address
+	}
+	if host.Host == "" {
+		return config.PostgresHost{}, ErrNoAddress
+	}
+	if e.Service.Port > 0 {
+		host.Port = strconv.Itoa(e.Service.Port)
+	}
+	if host.Name == "" {
+		host.Name = sanitizeName(e.Node.Node + "-" + e.Service.ID)
+	}
+	if !validName(host.Name) {
+		return config.PostgresHost{}, fmt.Errorf("%w: %q", ErrInvalidName, host.Name)
+	}
+	return host, nil
+}
+
+// ConsulProvider lists the instances of a Consul service whose health checks pass. Credentials are
+// taken from the postgres section.
+type ConsulProvider struct {
+	url   string
+	token string
+	cfg   config.ConsulDiscoveryConfig
+
+	client *http.Client
+
+	// watchClient has no timeout, as blocking queries take up to consulWait.
+	watchClient *http.Client
+}
+
+// NewConsulProvider creates a provider for the Consul agent at discovery.url, using the configured
+// proxy and TLS settings.
+func NewConsulProvider(cfg *config.Config) (*ConsulProvider, error) {
+	if cfg.Discovery.Consul.Service == "" {
+		return nil, ErrNoConsulService
+	}
+	client, err := httpclient.New(cfg, commonHTTP.DefaultHTTPClientTimeout)
+	if err != nil {
+		return nil, err
+	}
+	watchClient, err := httpclient.New(cfg, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &ConsulProvider{
+		url:         cfg.Discovery.URL,
+		token:       cfg.Discovery.Token,
+		cfg:         cfg.Discovery.Consul,
+		client:      client,
+		watchClient: watchClient,
+	}, nil
+}
+
+// Discover lists the service's healthy instances.
+func (p *ConsulProvider) Discover(ctx context.Context) ([]config.PostgresHost, error) {
+	hosts, _, err := p.query(ctx, p.client, 0)
+	return hosts, err
+}
+
+// Watch follows the service's healthy instances with blocking queries, calling update whenever they
+// change.
+func (p *ConsulProvider) Watch(ctx context.Context, update func([]config.PostgresHost)) error {
+	var (
+		index uint64
+		last  []config.PostgresHost
+	)
+	for {
+		hosts, next, err := p.query(ctx, p.watchClient, index)
+		if err != nil {
+			return err
+		}
+		if index == 0 || !slices.Equal(hosts, last) {
+			update(hosts)
+			last = hosts
+		}
+		switch {
+		case next == 0:
+			return ErrNoConsulIndex
+		case next < index:
+			// An index going backwards means Consul's state was reset; start over.
+			index = 0
+		default:
+			index = next
+		}
+	}
+}
+
+// query lists the service's healthy instances. With a non-zero index it blocks until they change
+// past it or consulWait passes. It also returns Consul's index for the answer.
+func (p *ConsulProvider) query(ctx context.Context, client *http.Client, index uint64) ([]config.PostgresHost, uint64, error) {
+	endpoint, err := url.JoinPath(p.url, "v1/health/service", p.cfg.Service)
+	if err != nil {
+		return nil, 0, err
+	}
+	q := url.Values{"passing": {"true"}}
+	if p.cfg.Tag != "" {
+		q.Set("tag", p.cfg.Tag)
+	}
+	if p.cfg.Datacenter != "" {
+		q.Set("dc", p.cfg.Datacenter)
+	}
+	if index > 0 {
+		q.Set("index", strconv.FormatUint(index, 10))
+		q.Set("wait", consulWait)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if p.token != "" {
+		req.Header.Set("X-Consul-Token", p.token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		return nil, 0, fmt.Errorf("%w: consul answered %d for service %q", ErrInventoryFailed, resp.StatusCode, p.cfg.Service)
+	}
+
+	var entries []consulEntry
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxInventorySize)).Decode(&entries); err != nil {
+		return nil, 0, fmt.Errorf("error decoding consul service %q: %w", p.cfg.Service, err)
+	}
+	next, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+
+	hosts := make([]config.PostgresHost, 0, len(entries))
+	for _, entry := range entries {
+		host, err := entry.host()
+		if err != nil {
+			slog.WarnContext(ctx, "Ignoring invalid consul service instance", "service", p.cfg.Service, "id", entry.Service.ID, "error", err)
+			continue
+		}
+		hosts = append(hosts, host)
+	}
+	slog.DebugContext(ctx, "Discovered Postgres servers", "service", p.cfg.Service, "hosts", len(hosts))
+	return hosts, next, nil
+}
@@ -0,0 +1,92 @@
+package discovery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const consulEntries = `[
+	{"Node": {"Node": "node-1", "Address": "10.0.0.1"}, "Service": {"ID": "pg", "Address": "", "Port": 5432}},
+	{"Node": {"Node": "node-2", "Address": "10.0.0.2"}, "Service": {"ID": "pg", "Address": "10.0.1.2", "Port": 6432, "Meta": {"stashly-name": "billing"}}}
+]`
+
+func newConsulProvider(t *testing.T, url string) *ConsulProvider {
+	t.Helper()
+	cfg := &config.Config{Discovery: config.DiscoveryConfig{
+		Provider: ProviderConsul,
+		URL:      url,
+		Token:    "acl",
+		Consul:   config.ConsulDiscoveryConfig{Service: "postgres", Tag: "primary"},
+	}}
+	p, err := NewConsulProvider(cfg)
+	require.NoError(t, err)
+	return p
+}
+
+func TestConsulProvider_Discover(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/health/service/postgres", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("passing"))
+		assert.Equal(t, "primary", r.URL.Query().Get("tag"))
+		assert.Equal(t, "acl", r.Header.Get("X-Consul-Token"))
+		w.Header().Set("X-Consul-Index", "5")
+		_, _ = w.Write([]byte(consulEntries))
+	}))
+	t.Cleanup(srv.Close)
+
+	hosts, err := newConsulProvider(t, srv.URL).Discover(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []config.PostgresHost{
+		{Name: "node-1-pg", Host: "10.0.0.1", Port: "5432"},
+		{Name: "billing", Host: "10.0.1.2", Port: "6432"},
+	}, hosts)
+}
+
+func TestConsulProvider_Watch(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			assert.Empty(t, r.URL.Query().Get("index"))
+			w.Header().Set("X-Consul-Index", "5")
+			_, _ = w.Write([]byte(consulEntries))
+		case 2:
+			// Consul answers unchanged when the wait times out.
+			assert.Equal(t, "5", r.URL.Query().Get("index"))
+			w.Header().Set("X-Consul-Index", "5")
+			_, _ = w.Write([]byte(consulEntries))
+		case 3:
+			w.Header().Set("X-Consul-Index", "6")
+			_, _ = w.Write([]byte(`[]`))
+		default:
+			<-r.Context().Done()
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var updates [][]config.PostgresHost
+	err := newConsulProvider(t, srv.URL).Watch(ctx, func(hosts []config.PostgresHost) {
+		updates = append(updates, hosts)
+		if len(updates) == 2 {
+			cancel()
+		}
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Len(t, updates, 2)
+	assert.Len(t, updates[0], 2)
+	assert.Empty(t, updates[1])
+}
+
+func TestNewConsulProvider_NoService(t *testing.T) {
+	_, err := New(&config.Config{Discovery: config.DiscoveryConfig{Provider: ProviderConsul, URL: "http://127.0.0.1:8500"}})
+	require.ErrorIs(t, err, ErrNoConsulService)
+}
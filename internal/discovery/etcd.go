@@ -0,0 +1,250 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	commonHTTP "github.com/hibare/GoCommon/v2/pkg/http"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/httpclient"
+)
+
+var (
+	// ErrNoEtcdPrefix is returned when the etcd provider has no discovery.etcd.prefix.
+	ErrNoEtcdPrefix = errors.New("discovery.etcd.prefix is not set")
+
+	// ErrEtcdWatchCanceled is returned when etcd cancels a watch, e.g. because its revision was compacted.
+	ErrEtcdWatchCanceled = errors.New("etcd canceled the watch")
+)
+
+// etcdKV is a key-value pair of the etcd v3 JSON API, which encodes keys and values in base64.
+type etcdKV struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+type etcdRangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end"`
+}
+
+type etcdRangeResponse struct {
+	Header struct {
+		Revision int64 `json:"revision,string"`
+	} `json:"header"`
+	Kvs []etcdKV `json:"kvs"`
+}
+
+type etcdWatchRequest struct {
+	CreateRequest struct {
+		Key           []byte `json:"key"`
+		RangeEnd      []byte `json:"range_end"`
+		StartRevision int64  `json:"start_revision,string"`
+	} `json:"create_request"`
+}
+
+type etcdWatchResponse struct {
+	Result *struct {
+		Events   []json.RawMessage `json:"events"`
+		Canceled bool              `json:"canceled"`
+		Reason   string            `json:"cancel_reason"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// prefixEnd returns the end of the key range holding every key starting with prefix.
+func prefixEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// Every byte is 0xff; the range runs to the last key.
+	return []byte{0}
+}
+
+// EtcdProvider reads the servers to back up from the keys under an etcd prefix, through etcd's v3
+// JSON API. Each value is a DSN, or an object with a dsn and an optional name; servers without a
+// name are named after their key below the prefix.
+type EtcdProvider struct {
+	url string
+	cfg config.EtcdDiscoveryConfig
+
+	client *http.Client
+
+	// watchClient has no timeout, as watches stream for as long as they run.
+	watchClient *http.Client
+}
+
+// NewEtcdProvider creates a provider for the etcd cluster at discovery.url, using the configured
+// proxy and TLS settings.
+func NewEtcdProvider(cfg *config.Config) (*EtcdProvider, error) {
+	if cfg.Discovery.Etcd.Prefix == "" {
+		return nil, ErrNoEtcdPrefix
+	}
+	client, err := httpclient.New(cfg, commonHTTP.DefaultHTTPClientTimeout)
+	if err != nil {
+		return nil, err
+	}
+	watchClient, err := httpclient.New(cfg, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &EtcdProvider{url: cfg.Discovery.URL, cfg: cfg.Discovery.Etcd, client: client, watchClient: watchClient}, nil
+}
+
+// Discover reads the servers under the prefix.
+func (p *EtcdProvider) Discover(ctx context.Context) ([]config.PostgresHost, error) {
+	hosts, _, err := p.rangeHosts(ctx)
+	return hosts, err
+}
+
+// Watch watches the keys under the prefix, reading the servers again whenever they change.
+func (p *EtcdProvider) Watch(ctx context.Context, update func([]config.PostgresHost)) error {
+	hosts, revision, err := p.rangeHosts(ctx)
+	if err != nil {
+		return err
+	}
+	update(hosts)
+
+	var req etcdWatchRequest
+	req.CreateRequest.Key = []byte(p.cfg.Prefix)
+	req.CreateRequest.RangeEnd = prefixEnd(p.cfg.Prefix)
+	req.CreateRequest.StartRevision = revision + 1
+	resp, err := p.post(ctx, p.watchClient, "v3/watch", req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	last := hosts
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg etcdWatchResponse
+		if err := dec.Decode(&msg); err != nil {
+			return fmt.Errorf("etcd watch ended: %w", err)
+		}
+		switch {
+		case msg.Error != nil:
+			return fmt.Errorf("etcd watch failed: %s", msg.Error.Message)
+		case msg.Result == nil:
+			continue
+		case msg.Result.Canceled:
+			return fmt.Errorf("%w: %s", ErrEtcdWatchCanceled, msg.Result.Reason)
+		case len(msg.Result.Events) == 0:
+			continue
+		}
+
+		hosts, _, err := p.rangeHosts(ctx)
+		if err != nil {
+			return err
+		}
+		if !slices.Equal(hosts, last) {
+			update(hosts)
+			last = hosts
+		}
+	}
+}
+
+// rangeHosts reads the servers under the prefix, returning them with the revision they were read at.
+func (p *EtcdProvider) rangeHosts(ctx context.Context) ([]config.PostgresHost, int64, error) {
+	resp, err := p.post(ctx, p.client, "v3/kv/range", etcdRangeRequest{Key: []byte(p.cfg.Prefix), RangeEnd: prefixEnd(p.cfg.Prefix)})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var out etcdRangeResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxInventorySize)).Decode(&out); err != nil {
+		return nil, 0, fmt.Errorf("error decoding etcd keys under %q: %w", p.cfg.Prefix, err)
+	}
+
+	hosts := make([]config.PostgresHost, 0, len(out.Kvs))
+	for _, kv := range out.Kvs {
+		host, err := p.parseKV(kv)
+		if err != nil {
+			slog.WarnContext(ctx, "Ignoring invalid etcd discovery key", "key", string(kv.Key), "error", err)
+			continue
+		}
+		hosts = append(hosts, host)
+	}
+	slog.DebugContext(ctx, "Discovered Postgres servers", "prefix", p.cfg.Prefix, "hosts", len(hosts))
+	return hosts, out.Header.Revision, nil
+}
+
+// parseKV parses a key's value, a DSN or an inventoryEntry.
+func (p *EtcdProvider) parseKV(kv etcdKV) (config.PostgresHost, error) {
+	var entry inventoryEntry
+	if err := json.Unmarshal(kv.Value, &entry); err != nil || entry.DSN == "" {
+		entry = inventoryEntry{DSN: strings.TrimSpace(string(kv.Value))}
+	}
+	if entry.Name == "" {
+		entry.Name = sanitizeName(strings.TrimPrefix(string(kv.Key), p.cfg.Prefix))
+	}
+	return entry.host()
+}
+
+// post sends body as JSON to the etcd API at path, authenticating first if a username is configured.
+// The caller closes the response body.
+func (p *EtcdProvider) post(ctx context.Context, client *http.Client, path string, body any) (*http.Response, error) {
+	token := ""
+	if p.cfg.Username != "" {
+		resp, err := p.do(ctx, p.client, "v3/auth/authenticate", map[string]string{"name": p.cfg.Username, "password": p.cfg.Password}, "")
+		if err != nil {
+			return nil, err
+		}
+		var auth struct {
+			Token string `json:"token"`
+		}
+		err = json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&auth)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error decoding etcd authentication: %w", err)
+		}
+		token = auth.Token
+	}
+	return p.do(ctx, client, path, body, token)
+}
+
+func (p *EtcdProvider) do(ctx context.Context, client *http.Client, path string, body any, token string) (*http.Response, error) {
+	endpoint, err := url.JoinPath(p.url, path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("%w: etcd answered %d for %s", ErrInventoryFailed, resp.StatusCode, path)
+	}
+	return resp, nil
+}
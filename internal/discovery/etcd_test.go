@@ -0,0 +1,111 @@
+package discovery
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// etcdRange renders a range response holding kvs, alternating keys and values.
+func etcdRange(revision int, kvs ...string) string {
+	entries := []string{}
+	for i := 0; i < len(kvs); i += 2 {
+		entries = append(entries, fmt.Sprintf(`{"key": %q, "value": %q}`,
+			base64.StdEncoding.EncodeToString([]byte(kvs[i])), base64.StdEncoding.EncodeToString([]byte(kvs[i+1]))))
+	}
+	return fmt.Sprintf(`{"header": {"revision": "%d"}, "kvs": [%s]}`, revision, strings.Join(entries, ","))
+}
+
+func newEtcdProvider(t *testing.T, url string, username string) *EtcdProvider {
+	t.Helper()
+	p, err := NewEtcdProvider(&config.Config{Discovery: config.DiscoveryConfig{
+		Provider: ProviderEtcd,
+		URL:      url,
+		Etcd:     config.EtcdDiscoveryConfig{Prefix: "/stashly/dbs/", Username: username, Password: "secret"},
+	}})
+	require.NoError(t, err)
+	return p
+}
+
+func TestEtcdProvider_Discover(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/auth/authenticate":
+			_, _ = w.Write([]byte(`{"token": "etcd-token"}`))
+		case "/v3/kv/range":
+			assert.Equal(t, "etcd-token", r.Header.Get("Authorization"))
+			var req etcdRangeRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, "/stashly/dbs/", string(req.Key))
+			assert.Equal(t, "/stashly/dbs0", string(req.RangeEnd))
+			_, _ = w.Write([]byte(etcdRange(7,
+				"/stashly/dbs/eu/orders", "postgres://backup:pw@db-eu.internal:5432/",
+				"/stashly/dbs/billing", `{"name": "billing-primary", "dsn": "postgres://db-billing.internal/"}`,
+				"/stashly/dbs/broken", "not a dsn",
+			)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	hosts, err := newEtcdProvider(t, srv.URL, "stashly").Discover(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []config.PostgresHost{
+		{Name: "eu-orders", Host: "db-eu.internal", Port: "5432", User: "backup", Password: "pw"},
+		{Name: "billing-primary", Host: "db-billing.internal"},
+	}, hosts)
+}
+
+func TestEtcdProvider_Watch(t *testing.T) {
+	var ranges atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/kv/range":
+			if ranges.Add(1) == 1 {
+				_, _ = w.Write([]byte(etcdRange(7, "/stashly/dbs/eu", "postgres://db-eu.internal/")))
+				return
+			}
+			_, _ = w.Write([]byte(etcdRange(8, "/stashly/dbs/eu", "postgres://db-eu.internal/", "/stashly/dbs/us", "postgres://db-us.internal/")))
+		case "/v3/watch":
+			var req etcdWatchRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, int64(8), req.CreateRequest.StartRevision)
+			_, _ = w.Write([]byte(`{"result": {"created": true}}` + "\n"))
+			_, _ = w.Write([]byte(`{"result": {"events": [{"kv": {}}]}}` + "\n"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var updates [][]config.PostgresHost
+	err := newEtcdProvider(t, srv.URL, "").Watch(ctx, func(hosts []config.PostgresHost) {
+		updates = append(updates, hosts)
+		if len(updates) == 2 {
+			cancel()
+		}
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Len(t, updates, 2)
+	assert.Len(t, updates[0], 1)
+	assert.Equal(t, "us", updates[1][1].Name)
+}
+
+func TestPrefixEnd(t *testing.T) {
+	assert.Equal(t, []byte("/dbs0"), prefixEnd("/dbs/"))
+	assert.Equal(t, []byte("b"), prefixEnd("a\xff"))
+	assert.Equal(t, []byte{0}, prefixEnd("\xff"))
+}
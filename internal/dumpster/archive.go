@@ -0,0 +1,205 @@
+package dumpster
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// archiveExtensions are the archive formats a backup can be stored in: zip archives written
+// in-process and tarballs compressed by an external compressor.
+var archiveExtensions = []string{".zip", ".tar.gz", ".tar.zst"}
+
+// copyBufferSize is the size of the buffers files are streamed through into archives, so archiving
+// needs the same memory however large the dumps are.
+const copyBufferSize = 1 << 20
+
+var copyBuffers = sync.Pool{
+	New: func() any {
+		b := make([]byte, copyBufferSize)
+		return &b
+	},
+}
+
+// copyFile streams the file at path into w through a pooled buffer.
+func copyFile(w io.Writer, path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	buf := copyBuffers.Get().(*[]byte) //nolint:errcheck // reason: the pool only holds *[]byte
+	defer copyBuffers.Put(buf)
+
+	// Hide in's WriterTo so the copy goes through buf.
+	_, err = io.CopyBuffer(w, struct{ io.Reader }{in}, *buf)
+	return err
+}
+
+// walkFiles calls fn with the slash-separated path relative to dir of every regular file under dir.
+func walkFiles(dir string, fn func(path, name string, info os.FileInfo) error) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		return fn(path, filepath.ToSlash(rel), info)
+	})
+}
+
+// writeZip streams the regular files in dir into a deflate-compressed zip archive at zipPath.
+// Unlike file.ArchiveDir it fails rather than leaving out files it cannot read.
+func writeZip(dir, zipPath string) error {
+	f, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(f)
+
+	err = walkFiles(dir, func(path, name string, info os.FileInfo) error {
+		hdr, hErr := zip.FileInfoHeader(info)
+		if hErr != nil {
+			return hErr
+		}
+		hdr.Name = name
+		hdr.Method = zip.Deflate
+
+		w, hErr := zw.CreateHeader(hdr)
+		if hErr != nil {
+			return hErr
+		}
+		return copyFile(w, path)
+	})
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := zw.Close(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeTar streams the regular files in dir, uncompressed, into a tarball at tarPath.
+func writeTar(dir, tarPath string) error {
+	f, err := os.Create(tarPath)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(f)
+
+	err = walkFiles(dir, func(path, name string, info os.FileInfo) error {
+		hdr, hErr := tar.FileInfoHeader(info, "")
+		if hErr != nil {
+			return hErr
+		}
+		hdr.Name = name
+		if hErr := tw.WriteHeader(hdr); hErr != nil {
+			return hErr
+		}
+		return copyFile(tw, path)
+	})
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// extractTar extracts a tarball compressed with compressor ("gzip" or "zstd") into dest and returns the
+// dumps with extension ext keyed by database name.
+func extractTar(archivePath, compressor, dest, ext string) (map[string]string, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var r io.Reader
+	switch compressor {
+	case "gzip":
+		gz, gErr := gzip.NewReader(f)
+		if gErr != nil {
+			return nil, gErr
+		}
+		defer func() {
+			_ = gz.Close()
+		}()
+		r = gz
+	case "zstd":
+		zr, zErr := zstd.NewReader(f)
+		if zErr != nil {
+			return nil, zErr
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return nil, fmt.Errorf("unsupported archive compression %q: %s", compressor, filepath.Base(archivePath))
+	}
+
+	if err := os.MkdirAll(dest, 0750); err != nil {
+		return nil, err
+	}
+
+	dumps := map[string]string{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, tErr := tr.Next()
+		if tErr == io.EOF {
+			break
+		}
+		if tErr != nil {
+			return nil, tErr
+		}
+		if !filepath.IsLocal(hdr.Name) {
+			return nil, fmt.Errorf("refusing to extract %q outside of %s", hdr.Name, dest)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		outPath := filepath.Join(dest, filepath.Base(hdr.Name))
+		out, oErr := os.OpenFile(outPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if oErr != nil {
+			return nil, oErr
+		}
+		if _, cErr := io.Copy(out, tr); cErr != nil {
+			_ = out.Close()
+			return nil, cErr
+		}
+		if cErr := out.Close(); cErr != nil {
+			return nil, cErr
+		}
+		if filepath.Ext(hdr.Name) == ext {
+			dumps[strings.TrimSuffix(filepath.Base(hdr.Name), ext)] = outPath
+		}
+	}
+	return dumps, nil
+}
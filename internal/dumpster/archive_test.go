@@ -0,0 +1,118 @@
+package dumpster
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// peakRSSLimit bounds the resident memory archiving may reach, whatever the size of the export.
+const peakRSSLimit = 64 << 20
+
+// writeSparseDump creates a dump of size bytes that takes no disk space.
+func writeSparseDump(t testing.TB, dir, name string, size int64) {
+	t.Helper()
+	f, err := os.Create(filepath.Join(dir, name))
+	require.NoError(t, err)
+	require.NoError(t, f.Truncate(size))
+	require.NoError(t, f.Close())
+}
+
+// peakRSS returns the process' peak resident set size in bytes (VmHWM), or 0 if it is unavailable.
+func peakRSS(t *testing.T) int64 {
+	t.Helper()
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if kb, ok := strings.CutPrefix(s.Text(), "VmHWM:"); ok {
+			n, pErr := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(kb, "kB")), 10, 64)
+			require.NoError(t, pErr)
+			return n << 10
+		}
+	}
+	return 0
+}
+
+func TestWriteZip(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "db_exports")
+	require.NoError(t, os.MkdirAll(src, 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "db1.sql"), []byte("SELECT 1;"), 0600))
+
+	archive := filepath.Join(dir, "db_exports.zip")
+	require.NoError(t, writeZip(src, archive))
+
+	dumps, err := extractArchive(archive, "", filepath.Join(dir, "out"), ".sql")
+	require.NoError(t, err)
+	content, err := os.ReadFile(dumps["db1"])
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT 1;", string(content))
+}
+
+func TestWriteZip_PeakRSS(t *testing.T) {
+	if testing.Short() {
+		t.Skip("archives a multi-GB export")
+	}
+	if runtime.GOOS != "linux" {
+		t.Skip("peak RSS is read from /proc")
+	}
+	// Reset the peak RSS so earlier tests don't count.
+	if err := os.WriteFile("/proc/self/clear_refs", []byte("5"), 0); err != nil {
+		t.Skipf("cannot reset peak RSS: %v", err)
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "db_exports")
+	require.NoError(t, os.MkdirAll(src, 0750))
+	writeSparseDump(t, src, "db1.sql", 2<<30)
+	writeSparseDump(t, src, "db2.sql", 1<<30)
+
+	require.NoError(t, writeZip(src, filepath.Join(dir, "db_exports.zip")))
+
+	peak := peakRSS(t)
+	if peak == 0 {
+		t.Skip("peak RSS unavailable")
+	}
+	t.Logf("peak RSS: %d MiB", peak>>20)
+	assert.Less(t, peak, int64(peakRSSLimit), "archiving 3 GiB of dumps peaked at %d MiB", peak>>20)
+}
+
+func BenchmarkWriteZip(b *testing.B) {
+	dir := b.TempDir()
+	src := filepath.Join(dir, "db_exports")
+	require.NoError(b, os.MkdirAll(src, 0750))
+	writeSparseDump(b, src, "db1.sql", 256<<20)
+
+	b.SetBytes(256 << 20)
+	b.ReportAllocs()
+	for b.Loop() {
+		require.NoError(b, writeZip(src, filepath.Join(dir, "db_exports.zip")))
+	}
+}
+
+func BenchmarkWriteTar(b *testing.B) {
+	dir := b.TempDir()
+	src := filepath.Join(dir, "db_exports")
+	require.NoError(b, os.MkdirAll(src, 0750))
+	writeSparseDump(b, src, "db1.sql", 256<<20)
+
+	b.SetBytes(256 << 20)
+	b.ReportAllocs()
+	for b.Loop() {
+		require.NoError(b, writeTar(src, filepath.Join(dir, "db_exports.tar")))
+	}
+}
@@ -0,0 +1,83 @@
+package dumpster
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/hibare/stashly/internal/catalog"
+	"github.com/hibare/stashly/internal/manifest"
+)
+
+// loadCatalog loads the local catalog for this instance.
+func (d *Dumpster) loadCatalog() (*catalog.Catalog, error) {
+	return catalog.Load(d.cfg.Catalog.Path, d.cfg.App.InstanceID)
+}
+
+// ReconcileCatalog checks the local catalog against storage, downloading the manifests of backups it doesn't
+// know yet and dropping the ones no longer in storage.
+func (d *Dumpster) ReconcileCatalog(ctx context.Context) error {
+	c, err := d.loadCatalog()
+	if err != nil {
+		return err
+	}
+	if err := d.reconcileCatalog(ctx, c); err != nil {
+		return err
+	}
+	return c.Save()
+}
+
+func (d *Dumpster) reconcileCatalog(ctx context.Context, c *catalog.Catalog) error {
+	timestamps, err := d.ListDumps(ctx)
+	if err != nil {
+		return err
+	}
+
+	added, removed, err := c.Reconcile(timestamps, func(ts string) (*manifest.Manifest, error) {
+		return d.ReadManifest(ctx, ts)
+	})
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Catalog reconciled", "backups", len(c.Backups), "added", added, "removed", removed)
+	return nil
+}
+
+// catalogBackups returns every backup recorded in the local catalog, reconciling it with storage first when stale.
+func (d *Dumpster) catalogBackups(ctx context.Context) ([]BackupInfo, error) {
+	c, err := d.loadCatalog()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Stale(d.cfg.Catalog.ReconcileInterval) {
+		if err := d.reconcileCatalog(ctx, c); err != nil {
+			return nil, err
+		}
+		if err := c.Save(); err != nil {
+			slog.WarnContext(ctx, "Failed to save catalog", "path", d.cfg.Catalog.Path, "error", err)
+		}
+	}
+
+	backups := []BackupInfo{}
+	for _, ts := range c.Timestamps() {
+		backups = append(backups, BackupInfo{Timestamp: ts, Manifest: c.Backups[ts]})
+	}
+	return backups, nil
+}
+
+// updateCatalog applies a change made to storage to the local catalog, when it is enabled. Failures are only
+// logged: the next reconciliation brings the catalog back in line with storage.
+func (d *Dumpster) updateCatalog(ctx context.Context, fn func(c *catalog.Catalog)) {
+	if !d.cfg.Catalog.Enabled {
+		return
+	}
+
+	c, err := d.loadCatalog()
+	if err == nil {
+		fn(c)
+		err = c.Save()
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to update catalog", "path", d.cfg.Catalog.Path, "error", err)
+	}
+}
@@ -0,0 +1,60 @@
+package dumpster
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/catalog"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/manifest"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpster_ListBackups_Catalog(t *testing.T) {
+	cfg := &config.Config{
+		App:     config.AppConfig{InstanceID: "db-1"},
+		Catalog: config.CatalogConfig{Enabled: true, Path: filepath.Join(t.TempDir(), "catalog.json"), ReconcileInterval: time.Hour},
+	}
+	mockStore := storage.NewMockStorageIface(t)
+	dumpster, err := NewDumpster(cfg, mockStore, exec.NewMockExecIface(t))
+	require.NoError(t, err)
+
+	// The first listing reconciles the empty catalog with storage, once.
+	timestamps := []string{"20250102000000", "20250101000000"}
+	mockStore.On("List").Return(timestamps, nil).Once()
+	mockStore.On("TrimPrefix", timestamps).Return(timestamps).Once()
+	for _, ts := range timestamps {
+		key := "p/i/" + ts + "/manifest.json"
+		mockStore.On("ListFiles", ts).Return([]string{key}, nil).Once()
+		m := &manifest.Manifest{Timestamp: ts, Labels: map[string]string{"ts": ts}}
+		mockStore.On("Download", key, mock.Anything).Run(func(args mock.Arguments) {
+			require.NoError(t, m.Write(args.String(1)))
+		}).Return(nil).Once()
+	}
+
+	backups, err := dumpster.ListBackups(context.Background(), nil)
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+
+	// Later listings are served from the catalog without touching storage.
+	backups, err = dumpster.ListBackups(context.Background(), map[string]string{"ts": "20250101000000"})
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.Equal(t, "20250101000000", backups[0].Timestamp)
+
+	// Purged backups are dropped from the catalog.
+	cfg.Backup.RetentionCount = 1
+	mockStore.On("Delete", "20250101000000").Return(nil).Once()
+	_, err = dumpster.PurgeDumps(context.Background(), PurgeOptions{})
+	require.NoError(t, err)
+
+	c, err := catalog.Load(cfg.Catalog.Path, "db-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20250102000000"}, c.Timestamps())
+}
@@ -0,0 +1,55 @@
+package dumpster
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// citusDistributionQuery renders the Citus calls recreating each distributed and reference table.
+// Tables are ordered by colocation group so every create_distributed_table call colocates with a
+// table that has already been distributed. Citus local tables are left alone.
+const citusDistributionQuery = `SELECT CASE
+  WHEN p.partmethod = 'n' THEN format('SELECT create_reference_table(%L);', p.logicalrelid::regclass::text)
+  ELSE format('SELECT create_distributed_table(%L, %L, colocate_with => %L);',
+    p.logicalrelid::regclass::text,
+    column_to_column_name(p.logicalrelid, p.partkey),
+    CASE WHEN first_value(p.logicalrelid) OVER w = p.logicalrelid THEN 'default'
+         ELSE (first_value(p.logicalrelid) OVER w)::regclass::text END)
+END
+FROM pg_dist_partition p
+WHERE NOT (p.partmethod = 'n' AND p.repmodel <> 't')
+WINDOW w AS (PARTITION BY p.colocationid ORDER BY p.logicalrelid)
+ORDER BY p.colocationid, p.logicalrelid;`
+
+// appendCitusDistribution appends the distribution of db's Citus tables to its dump.
+// pg_dump on the coordinator reads distributed table data through COPY but restores it
+// into plain tables; replaying these calls afterwards moves the data back into shards.
+// Databases without the citus extension are left untouched.
+func (p *Postgres) appendCitusDistribution(ctx context.Context, db, dumpFile string, envVars []string, dir string) error {
+	installed, err := p.hasExtension(ctx, db, "citus", envVars, dir)
+	if err != nil || !installed {
+		return err
+	}
+
+	calls, err := p.psqlQuery(ctx, db, citusDistributionQuery, envVars, dir)
+	if err != nil {
+		return fmt.Errorf("error reading Citus distribution: %w", err)
+	}
+	if calls == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(dumpFile, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "\n-- Citus table distribution\n%s\n", calls); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	slog.DebugContext(ctx, "Appended Citus table distribution to dump", "database", db)
+	return f.Close()
+}
@@ -0,0 +1,61 @@
+package dumpster
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgres_appendCitusDistribution(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed string
+		calls     string
+		want      string
+	}{
+		{
+			name:      "citus installed",
+			installed: "1\n",
+			calls:     "SELECT create_reference_table('countries');\nSELECT create_distributed_table('events', 'tenant_id', colocate_with => 'default');\n",
+			want: "-- dump\n\n-- Citus table distribution\n" +
+				"SELECT create_reference_table('countries');\nSELECT create_distributed_table('events', 'tenant_id', colocate_with => 'default');\n",
+		},
+		{
+			name:      "citus not installed",
+			installed: "\n",
+			want:      "-- dump\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pg, mockExec := newTestPostgres(t, &config.Config{})
+			mockCmd := exec.NewMockCmdIface(t)
+
+			dumpFile := filepath.Join(t.TempDir(), "db1.sql")
+			require.NoError(t, os.WriteFile(dumpFile, []byte("-- dump\n"), 0600))
+
+			mockExec.On("Command", mock.Anything, "psql", mock.Anything).Return(mockCmd)
+			mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
+			mockCmd.On("WithDir", "/tmp/work").Return(mockCmd)
+			mockCmd.On("Output").Return([]byte(tt.installed), nil).Once()
+			if tt.calls != "" {
+				mockCmd.On("Output").Return([]byte(tt.calls), nil).Once()
+			}
+
+			err := pg.appendCitusDistribution(context.Background(), "db1", dumpFile, nil, "/tmp/work")
+			require.NoError(t, err)
+
+			got, err := os.ReadFile(dumpFile)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package dumpster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/hibare/stashly/internal/audit"
+	"github.com/hibare/stashly/internal/manifest"
+)
+
+// pgpMessageType is the armor block type of an encrypted OpenPGP message.
+const pgpMessageType = "PGP MESSAGE"
+
+// ErrNotEncrypted is returned in encryption compliance mode for a backup that would be stored
+// unencrypted.
+var ErrNotEncrypted = errors.New("refusing to upload an unencrypted backup in compliance mode")
+
+// sealing describes how an uploaded file is encrypted, for the audit log.
+type sealing struct {
+	Algorithm   string
+	KeyID       string
+	Fingerprint string
+}
+
+// algorithmName names an OpenPGP public-key encryption algorithm.
+func algorithmName(algo packet.PublicKeyAlgorithm) string {
+	switch algo {
+	case packet.PubKeyAlgoRSA, packet.PubKeyAlgoRSAEncryptOnly:
+		return "RSA"
+	case packet.PubKeyAlgoElGamal:
+		return "ElGamal"
+	case packet.PubKeyAlgoECDH:
+		return "ECDH"
+	case packet.PubKeyAlgoX25519:
+		return "X25519"
+	case packet.PubKeyAlgoX448:
+		return "X448"
+	default:
+		return fmt.Sprintf("algorithm %d", algo)
+	}
+}
+
+// checkCompliance fails a backup up front when compliance mode is on but backups aren't encrypted.
+func (d *Dumpster) checkCompliance() error {
+	if d.cfg.Encryption.Compliance && !d.cfg.Backup.Encrypt {
+		return fmt.Errorf("%w: backup.encrypt is off", ErrNotEncrypted)
+	}
+	return nil
+}
+
+// sealedWith checks that the file at path is a complete, ASCII-armored OpenPGP message encrypted to
+// a public key, and returns how. The whole file is read, so a truncated or corrupted message fails
+// too. keyring, if given, resolves the key's fingerprint and size.
+func sealedWith(path string, keyring openpgp.EntityList) (*sealing, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	block, err := armor.Decode(f)
+	if err != nil || block.Type != pgpMessageType {
+		return nil, fmt.Errorf("%w: %s is not an OpenPGP message", ErrNotEncrypted, path)
+	}
+	p, err := packet.Read(block.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrNotEncrypted, path, err)
+	}
+	ek, ok := p.(*packet.EncryptedKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not encrypted to a public key", ErrNotEncrypted, path)
+	}
+	if _, err := io.Copy(io.Discard, block.Body); err != nil {
+		return nil, fmt.Errorf("%w: %s is incomplete: %w", ErrNotEncrypted, path, err)
+	}
+
+	s := &sealing{Algorithm: "OpenPGP " + algorithmName(ek.Algo), KeyID: fmt.Sprintf("%016X", ek.KeyId)}
+	if keys := keyring.KeysById(ek.KeyId); len(keys) > 0 {
+		s.Fingerprint = fmt.Sprintf("%X", keys[0].Entity.PrimaryKey.Fingerprint)
+		if bits, err := keys[0].PublicKey.BitLength(); err == nil {
+			s.Algorithm = fmt.Sprintf("%s-%d", s.Algorithm, bits)
+		}
+	}
+	return s, nil
+}
+
+// complianceKeyring returns the configured public key, to name the key uploads are encrypted to. It
+// is nil if the key can't be read; the audit log then records key IDs only.
+func (d *Dumpster) complianceKeyring(ctx context.Context) openpgp.EntityList {
+	publicKey, err := d.gpg.ReadPublicKeyFromFile()
+	if err != nil {
+		publicKey, err = d.EncryptionPublicKey()
+	}
+	if err == nil {
+		keyring, kErr := openpgp.ReadArmoredKeyRing(strings.NewReader(publicKey))
+		if kErr == nil {
+			return keyring
+		}
+		err = kErr
+	}
+	slog.WarnContext(ctx, "Failed to read the encryption key for the audit log", "error", err)
+	return nil
+}
+
+// checkSealed checks, in compliance mode, that the file at path is encrypted before it is uploaded.
+// It returns nil outside compliance mode.
+func (d *Dumpster) checkSealed(ctx context.Context, path string) (*sealing, error) {
+	if !d.cfg.Encryption.Compliance {
+		return nil, nil //nolint:nilnil // reason: nothing to check outside compliance mode
+	}
+	return sealedWith(path, d.complianceKeyring(ctx))
+}
+
+// recordUpload appends an uploaded object to the audit log. A nil sealing, outside compliance mode,
+// records nothing.
+func (d *Dumpster) recordUpload(timestamp, key, path string, s *sealing) error {
+	if s == nil {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	err = audit.Append(d.cfg.Encryption.AuditLog, audit.Entry{
+		Time:        time.Now().UTC(),
+		Instance:    d.cfg.App.InstanceID,
+		Backup:      timestamp,
+		Key:         key,
+		Storage:     d.store.Name(),
+		Size:        info.Size(),
+		Algorithm:   s.Algorithm,
+		KeyID:       s.KeyID,
+		Fingerprint: s.Fingerprint,
+	})
+	if err != nil {
+		return fmt.Errorf("error recording %s in the audit log: %w", key, err)
+	}
+	return nil
+}
+
+// uploadSealed uploads the file at path into the backup at timestamp. In compliance mode it must be
+// encrypted, and the upload is recorded in the audit log.
+func (d *Dumpster) uploadSealed(ctx context.Context, timestamp, path string) (string, error) {
+	s, err := d.checkSealed(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	key, err := d.store.Upload(ctx, timestamp, path)
+	if err != nil {
+		return "", err
+	}
+	return key, d.recordUpload(timestamp, key, path, s)
+}
+
+// uploadCopy uploads a file of a backup copied from another storage or an export. Plaintext manifests
+// go up as they are; everything else must pass uploadSealed.
+func (d *Dumpster) uploadCopy(ctx context.Context, timestamp, path string) (string, error) {
+	if filepath.Base(path) == manifest.FileName {
+		return d.store.Upload(ctx, timestamp, path)
+	}
+	return d.uploadSealed(ctx, timestamp, path)
+}
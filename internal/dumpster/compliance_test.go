@@ -0,0 +1,159 @@
+package dumpster
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/audit"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// keyGPG serves an armored public key like gpg.GPG does once the key has been downloaded.
+type keyGPG struct {
+	fakeGPG
+	publicKey string
+}
+
+func (k *keyGPG) ReadPublicKeyFromFile() (string, error) {
+	return k.publicKey, nil
+}
+
+// sealFile writes data to path as an armored OpenPGP message encrypted to entity.
+func sealFile(t *testing.T, path string, entity *openpgp.Entity, data []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, pgpMessageType, nil)
+	require.NoError(t, err)
+	pt, err := openpgp.Encrypt(w, []*openpgp.Entity{entity}, nil, nil, nil)
+	require.NoError(t, err)
+	_, err = pt.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, pt.Close())
+	require.NoError(t, w.Close())
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0600))
+}
+
+// armoredEntity returns the armored public key of entity.
+func armoredEntity(t *testing.T, entity *openpgp.Entity) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+	return buf.String()
+}
+
+func TestSealedWith(t *testing.T) {
+	entity, err := openpgp.NewEntity("stashly", "", "stashly@example.com", nil)
+	require.NoError(t, err)
+	dir := t.TempDir()
+
+	sealed := filepath.Join(dir, "backup.zip.gpg")
+	sealFile(t, sealed, entity, []byte("dump"))
+
+	t.Run("known key", func(t *testing.T) {
+		s, sErr := sealedWith(sealed, openpgp.EntityList{entity})
+		require.NoError(t, sErr)
+		assert.Contains(t, s.Algorithm, "OpenPGP")
+		assert.Len(t, s.KeyID, 16)
+		assert.Len(t, s.Fingerprint, 40)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		s, sErr := sealedWith(sealed, nil)
+		require.NoError(t, sErr)
+		assert.Len(t, s.KeyID, 16)
+		assert.Empty(t, s.Fingerprint)
+	})
+
+	t.Run("plaintext", func(t *testing.T) {
+		plain := filepath.Join(dir, "backup.zip")
+		require.NoError(t, os.WriteFile(plain, []byte("dump"), 0600))
+		_, sErr := sealedWith(plain, nil)
+		require.ErrorIs(t, sErr, ErrNotEncrypted)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		data, rErr := os.ReadFile(sealed)
+		require.NoError(t, rErr)
+		truncated := filepath.Join(dir, "truncated.zip.gpg")
+		require.NoError(t, os.WriteFile(truncated, data[:len(data)/2], 0600))
+		_, sErr := sealedWith(truncated, nil)
+		require.ErrorIs(t, sErr, ErrNotEncrypted)
+	})
+}
+
+func TestDumpster_Compliance(t *testing.T) {
+	entity, err := openpgp.NewEntity("stashly", "", "stashly@example.com", nil)
+	require.NoError(t, err)
+
+	newCompliant := func(t *testing.T, encrypt bool) (*Dumpster, *storage.MockStorageIface) {
+		cfg := &config.Config{Backup: config.BackupConfig{Encrypt: encrypt}}
+		cfg.App.InstanceID = "db-1"
+		cfg.Encryption.Compliance = true
+		cfg.Encryption.AuditLog = filepath.Join(t.TempDir(), "audit.log")
+		mockStore := storage.NewMockStorageIface(t)
+		d, dErr := NewDumpster(cfg, mockStore, exec.NewMockExecIface(t))
+		require.NoError(t, dErr)
+		d.gpg = &keyGPG{fakeGPG: fakeGPG{dir: t.TempDir()}, publicKey: armoredEntity(t, entity)}
+		return d, mockStore
+	}
+
+	t.Run("encryption off", func(t *testing.T) {
+		d, _ := newCompliant(t, false)
+		require.ErrorIs(t, d.checkCompliance(), ErrNotEncrypted)
+	})
+
+	t.Run("refuses plaintext", func(t *testing.T) {
+		d, _ := newCompliant(t, true)
+		plain := filepath.Join(t.TempDir(), "backup.zip")
+		require.NoError(t, os.WriteFile(plain, []byte("dump"), 0600))
+
+		_, uErr := d.uploadSealed(context.Background(), "20250101000000", plain)
+		require.ErrorIs(t, uErr, ErrNotEncrypted)
+		assert.NoFileExists(t, d.cfg.Encryption.AuditLog)
+	})
+
+	t.Run("audits encrypted uploads", func(t *testing.T) {
+		d, mockStore := newCompliant(t, true)
+		sealed := filepath.Join(t.TempDir(), "backup.zip.gpg")
+		sealFile(t, sealed, entity, []byte("dump"))
+		mockStore.On("Upload", "20250101000000", mock.Anything).Return("20250101000000/backup.zip.gpg", nil)
+		mockStore.On("Name").Return("s3")
+
+		key, uErr := d.uploadSealed(context.Background(), "20250101000000", sealed)
+		require.NoError(t, uErr)
+		assert.Equal(t, "20250101000000/backup.zip.gpg", key)
+
+		f, oErr := os.Open(d.cfg.Encryption.AuditLog)
+		require.NoError(t, oErr)
+		defer func() {
+			_ = f.Close()
+		}()
+		scanner := bufio.NewScanner(f)
+		require.True(t, scanner.Scan())
+		var e audit.Entry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		assert.Equal(t, "db-1", e.Instance)
+		assert.Equal(t, "s3", e.Storage)
+		assert.Equal(t, key, e.Key)
+		assert.Equal(t, "20250101000000", e.Backup)
+		assert.Equal(t, strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint)), e.Fingerprint)
+		assert.False(t, scanner.Scan())
+	})
+}
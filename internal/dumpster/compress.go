@@ -0,0 +1,124 @@
+package dumpster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownCompressor is returned when backup.compressor names a compressor Stashly doesn't support.
+var ErrUnknownCompressor = errors.New("unknown compressor")
+
+// compressor is an external multi-core compressor that compresses a file next to itself, keeping the original.
+type compressor struct {
+	ext  string
+	args func(path string) []string
+}
+
+// compressors are the supported external compressors, keyed by binary name.
+var compressors = map[string]compressor{
+	"pigz": {ext: ".gz", args: func(path string) []string {
+		return []string{"--keep", "--force", path}
+	}},
+	"igzip": {ext: ".gz", args: func(path string) []string {
+		return []string{"-k", "-f", "-T", strconv.Itoa(runtime.NumCPU()), path}
+	}},
+	"zstdmt": {ext: ".zst", args: func(path string) []string {
+		return []string{"-q", "-f", path}
+	}},
+}
+
+// autoCompressors is the order compressors are tried in when backup.compressor is "auto".
+var autoCompressors = []string{"pigz", "igzip", "zstdmt"}
+
+// resolveCompressor returns the external compressor to use, or "" to compress in-process.
+// A configured compressor that isn't installed falls back to in-process compression.
+func (d *Dumpster) resolveCompressor(ctx context.Context) (string, error) {
+	name := strings.ToLower(d.cfg.Backup.Compressor)
+	switch name {
+	case "", "zip":
+		return "", nil
+	case "auto":
+		for _, c := range autoCompressors {
+			if _, err := d.exec.LookPath(c); err == nil {
+				return c, nil
+			}
+		}
+		slog.DebugContext(ctx, "No external compressor found, compressing in-process")
+		return "", nil
+	}
+
+	if _, ok := compressors[name]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownCompressor, d.cfg.Backup.Compressor)
+	}
+	if _, err := d.exec.LookPath(name); err != nil {
+		slog.WarnContext(ctx, "Compressor not found in PATH, compressing in-process", "compressor", name)
+		return "", nil
+	}
+	return name, nil
+}
+
+// archiveName returns the archive's name without extension, <engine>-<format> (e.g. "postgres-plain"),
+// so backups of different engines sharing a prefix can be told apart by their keys.
+func (d *Dumpster) archiveName() string {
+	return d.engine.Name() + "-" + d.engine.Format()
+}
+
+// archiveDumps archives the backup location, as a tarball compressed by the named external compressor
+// or, when name is empty or the compressor fails, as an in-process zip archive.
+func (d *Dumpster) archiveDumps(ctx context.Context, name string) (string, error) {
+	if name != "" {
+		archivePath, err := d.compressExternal(ctx, name)
+		if err == nil {
+			return archivePath, nil
+		}
+		slog.WarnContext(ctx, "External compression failed, compressing in-process", "compressor", name, "error", err)
+	}
+
+	archivePath := filepath.Join(os.TempDir(), d.archiveName()+".zip")
+	if err := writeZip(d.backupLocation, archivePath); err != nil {
+		_ = os.Remove(archivePath)
+		return "", err
+	}
+	return archivePath, nil
+}
+
+// compressorOf returns the compressor recorded in the manifest for an archive: "zip" for in-process
+// archives, else the external compressor's format ("gzip" or "zstd").
+func compressorOf(archivePath string) string {
+	switch {
+	case strings.HasSuffix(archivePath, ".tar.gz"):
+		return "gzip"
+	case strings.HasSuffix(archivePath, ".tar.zst"):
+		return "zstd"
+	default:
+		return "zip"
+	}
+}
+
+// compressExternal tars the backup location and compresses the tarball with the named compressor.
+func (d *Dumpster) compressExternal(ctx context.Context, name string) (string, error) {
+	c := compressors[name]
+	tarPath := filepath.Join(os.TempDir(), d.archiveName()+".tar")
+	archivePath := tarPath + c.ext
+	defer func() {
+		_ = os.Remove(tarPath)
+	}()
+
+	if err := writeTar(d.backupLocation, tarPath); err != nil {
+		return "", err
+	}
+
+	slog.DebugContext(ctx, "Compressing archive", "compressor", name, "file", tarPath)
+	if out, err := d.exec.Command(ctx, name, c.args(tarPath)...).CombinedOutput(); err != nil {
+		_ = os.Remove(archivePath)
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return archivePath, nil
+}
@@ -0,0 +1,142 @@
+package dumpster
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpster_resolveCompressor(t *testing.T) {
+	tests := []struct {
+		name       string
+		compressor string
+		installed  []string
+		want       string
+		wantErr    error
+	}{
+		{name: "default", want: ""},
+		{name: "auto picks first installed", compressor: "auto", installed: []string{"igzip", "zstdmt"}, want: "igzip"},
+		{name: "auto without compressors", compressor: "auto", want: ""},
+		{name: "explicit", compressor: "zstdmt", installed: []string{"zstdmt"}, want: "zstdmt"},
+		{name: "explicit but missing", compressor: "pigz", want: ""},
+		{name: "unknown", compressor: "brotli", wantErr: ErrUnknownCompressor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockExec := exec.NewMockExecIface(t)
+			d, err := NewDumpster(&config.Config{Backup: config.BackupConfig{Compressor: tt.compressor}}, nil, mockExec)
+			require.NoError(t, err)
+
+			for _, c := range autoCompressors {
+				if slices.Contains(tt.installed, c) {
+					mockExec.On("LookPath", c).Return("/usr/bin/"+c, nil).Maybe()
+				} else {
+					mockExec.On("LookPath", c).Return("", errors.New("not found")).Maybe()
+				}
+			}
+
+			got, err := d.resolveCompressor(context.Background())
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// gzipFile compresses path into path+".gz", standing in for pigz in tests.
+func gzipFile(t *testing.T, path string) {
+	t.Helper()
+	in, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() {
+		_ = in.Close()
+	}()
+	out, err := os.Create(path + ".gz")
+	require.NoError(t, err)
+	gz := gzip.NewWriter(out)
+	_, err = io.Copy(gz, in)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, out.Close())
+}
+
+func TestDumpster_archiveDumps_External(t *testing.T) {
+	mockExec := exec.NewMockExecIface(t)
+	mockCmd := exec.NewMockCmdIface(t)
+	d, err := NewDumpster(&config.Config{}, nil, mockExec)
+	require.NoError(t, err)
+	d.backupLocation = filepath.Join(t.TempDir(), "db_exports")
+	require.NoError(t, os.MkdirAll(d.backupLocation, 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(d.backupLocation, "db1.sql"), []byte("SELECT 1;"), 0600))
+
+	tarPath := filepath.Join(os.TempDir(), "postgres-plain.tar")
+	mockExec.On("Command", mock.Anything, "pigz", []string{"--keep", "--force", tarPath}).Return(mockCmd)
+	mockCmd.On("CombinedOutput").Run(func(mock.Arguments) { gzipFile(t, tarPath) }).Return([]byte(""), nil)
+
+	archivePath, err := d.archiveDumps(context.Background(), "pigz")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.Remove(archivePath) })
+	assert.Equal(t, tarPath+".gz", archivePath)
+	assert.NoFileExists(t, tarPath)
+	assert.Equal(t, "gzip", compressorOf(archivePath))
+
+	dumps, err := extractArchive(archivePath, "", filepath.Join(t.TempDir(), "out"), ".sql")
+	require.NoError(t, err)
+	require.Contains(t, dumps, "db1")
+	content, err := os.ReadFile(dumps["db1"])
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT 1;", string(content))
+}
+
+func TestDumpster_archiveDumps_FallsBackOnFailure(t *testing.T) {
+	mockExec := exec.NewMockExecIface(t)
+	mockCmd := exec.NewMockCmdIface(t)
+	d, err := NewDumpster(&config.Config{}, nil, mockExec)
+	require.NoError(t, err)
+	d.backupLocation = filepath.Join(t.TempDir(), "db_exports")
+	require.NoError(t, os.MkdirAll(d.backupLocation, 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(d.backupLocation, "db1.sql"), []byte("SELECT 1;"), 0600))
+
+	mockExec.On("Command", mock.Anything, "zstdmt", mock.Anything).Return(mockCmd)
+	mockCmd.On("CombinedOutput").Return([]byte("zstdmt: out of memory"), errors.New("exit status 1"))
+
+	archivePath, err := d.archiveDumps(context.Background(), "zstdmt")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.Remove(archivePath) })
+	assert.Equal(t, "zip", compressorOf(archivePath))
+}
+
+func TestExtractTar_Zstd(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	require.NoError(t, os.MkdirAll(src, 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "db1.sql"), []byte("SELECT 1;"), 0600))
+
+	tarPath := filepath.Join(dir, "db_exports.tar")
+	require.NoError(t, writeTar(src, tarPath))
+	raw, err := os.ReadFile(tarPath)
+	require.NoError(t, err)
+	enc, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(tarPath+".zst", enc.EncodeAll(raw, nil), 0600))
+
+	dumps, err := extractArchive(tarPath+".zst", "", filepath.Join(dir, "out"), ".sql")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"db1": filepath.Join(dir, "out", "db1.sql")}, dumps)
+}
@@ -0,0 +1,62 @@
+package dumpster
+
+import (
+	"maps"
+	"sort"
+)
+
+// UncoveredDatabase is a database that was found on the server but not backed up by any of the inspected runs.
+type UncoveredDatabase struct {
+	Name string
+
+	// Runs is the number of inspected runs that found the database.
+	Runs int
+
+	// LastSeen is the timestamp of the newest backup that found the database.
+	LastSeen string
+
+	// Reason is why the newest run didn't back it up.
+	Reason string
+}
+
+// uncovered returns the databases an export found but didn't back up, with the reason why.
+func uncovered(resp *ExportResult) map[string]string {
+	if len(resp.Skipped)+len(resp.Failed) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(resp.Skipped)+len(resp.Failed))
+	maps.Copy(out, resp.Skipped)
+	maps.Copy(out, resp.Failed)
+	return out
+}
+
+// Coverage returns the databases that every one of the given backups (newest first) found but none backed up.
+// Backups without a manifest are ignored.
+func Coverage(backups []BackupInfo) []UncoveredDatabase {
+	covered := map[string]bool{}
+	found := map[string]*UncoveredDatabase{}
+	for _, b := range backups {
+		if b.Manifest == nil {
+			continue
+		}
+		for _, db := range b.Manifest.Databases {
+			covered[db] = true
+		}
+		for db, reason := range b.Manifest.Uncovered {
+			if u, ok := found[db]; ok {
+				u.Runs++
+				continue
+			}
+			found[db] = &UncoveredDatabase{Name: db, Runs: 1, LastSeen: b.Timestamp, Reason: reason}
+		}
+	}
+
+	result := []UncoveredDatabase{}
+	for db, u := range found {
+		if !covered[db] {
+			result = append(result, *u)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
@@ -0,0 +1,35 @@
+package dumpster
+
+import (
+	"testing"
+
+	"github.com/hibare/stashly/internal/manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUncovered(t *testing.T) {
+	assert.Nil(t, uncovered(&ExportResult{Skipped: map[string]string{}, Failed: map[string]string{}}))
+	assert.Equal(t, map[string]string{"a": "permission denied", "b": "dump truncated"}, uncovered(&ExportResult{
+		Skipped: map[string]string{"a": "permission denied"},
+		Failed:  map[string]string{"b": "dump truncated"},
+	}))
+}
+
+func TestCoverage(t *testing.T) {
+	backups := []BackupInfo{
+		{Timestamp: "t3", Manifest: &manifest.Manifest{
+			Databases: []string{"app"},
+			Uncovered: map[string]string{"billing": "permission denied", "flaky": "connection reset"},
+		}},
+		{Timestamp: "t2"},
+		{Timestamp: "t1", Manifest: &manifest.Manifest{
+			Databases: []string{"app", "flaky"},
+			Uncovered: map[string]string{"billing": "permission denied for database"},
+		}},
+	}
+
+	assert.Equal(t, []UncoveredDatabase{
+		{Name: "billing", Runs: 2, LastSeen: "t3", Reason: "permission denied"},
+	}, Coverage(backups))
+	assert.Empty(t, Coverage(nil))
+}
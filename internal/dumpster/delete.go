@@ -0,0 +1,73 @@
+package dumpster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/hibare/stashly/internal/catalog"
+	"github.com/hibare/stashly/internal/manifest"
+)
+
+var (
+	// ErrBackupNotFound is returned when deleting a backup that isn't in storage.
+	ErrBackupNotFound = errors.New("backup not found")
+
+	// ErrLastBackup is returned when deleting the only remaining backup without DeleteOptions.AllowLast.
+	ErrLastBackup = errors.New("refusing to delete the only remaining backup")
+
+	// ErrBackupRestoring is returned when deleting a backup that is being restored.
+	ErrBackupRestoring = errors.New("backup is being restored")
+)
+
+// DeleteOptions controls DeleteDump.
+type DeleteOptions struct {
+	// AllowLast allows deleting the only remaining backup.
+	AllowLast bool
+
+	// Confirm, when set, is shown the backup before it is deleted; the backup is kept unless it
+	// returns true. Manifest is nil for backups without one.
+	Confirm func(BackupInfo) bool
+}
+
+// DeleteDump deletes the backup at timestamp after checking it exists, isn't the last one left and
+// isn't being restored. It reports whether the backup was deleted, which it isn't when opts.Confirm
+// declines.
+func (d *Dumpster) DeleteDump(ctx context.Context, timestamp string, opts DeleteOptions) (bool, error) {
+	timestamps, err := d.ListDumps(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !slices.Contains(timestamps, timestamp) {
+		return false, fmt.Errorf("%w: %s", ErrBackupNotFound, timestamp)
+	}
+	if len(timestamps) == 1 && !opts.AllowLast {
+		return false, fmt.Errorf("%w: %s", ErrLastBackup, timestamp)
+	}
+	if isRestoring(timestamp) {
+		return false, fmt.Errorf("%w: %s", ErrBackupRestoring, timestamp)
+	}
+
+	if opts.Confirm != nil {
+		info := BackupInfo{Timestamp: timestamp}
+		m, err := d.ReadManifest(ctx, timestamp)
+		switch {
+		case err == nil:
+			info.Manifest = m
+		case !errors.Is(err, manifest.ErrNotFound):
+			return false, err
+		}
+		if !opts.Confirm(info) {
+			return false, nil
+		}
+	}
+
+	slog.InfoContext(ctx, "Deleting backup", "key", timestamp)
+	if err := d.store.Delete(ctx, timestamp); err != nil {
+		return false, fmt.Errorf("error deleting backup %s: %w", timestamp, err)
+	}
+	d.updateCatalog(ctx, func(c *catalog.Catalog) { c.Remove(timestamp) })
+	return true, nil
+}
@@ -0,0 +1,69 @@
+package dumpster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/manifest"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpster_DeleteDump(t *testing.T) {
+	mockStore := storage.NewMockStorageIface(t)
+	dumpster, err := NewDumpster(&config.Config{}, mockStore, exec.NewMockExecIface(t))
+	require.NoError(t, err)
+
+	timestamps := []string{"20250102000000", "20250101000000"}
+	mockStore.On("List").Return(timestamps, nil)
+	mockStore.On("TrimPrefix", timestamps).Return(timestamps)
+
+	_, err = dumpster.DeleteDump(context.Background(), "20241231000000", DeleteOptions{})
+	require.ErrorIs(t, err, ErrBackupNotFound)
+
+	key := "p/i/20250101000000/manifest.json"
+	mockStore.On("ListFiles", "20250101000000").Return([]string{"p/i/20250101000000/db_exports.zip", key}, nil)
+	mockStore.On("Download", key, mock.Anything).Run(func(args mock.Arguments) {
+		m := &manifest.Manifest{Timestamp: "20250101000000", Size: 1024}
+		require.NoError(t, m.Write(args.String(1)))
+	}).Return(nil)
+
+	// Declining keeps the backup.
+	var shown BackupInfo
+	deleted, err := dumpster.DeleteDump(context.Background(), "20250101000000", DeleteOptions{
+		Confirm: func(b BackupInfo) bool { shown = b; return false },
+	})
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	require.NotNil(t, shown.Manifest)
+	assert.Equal(t, int64(1024), shown.Manifest.Size)
+
+	mockStore.On("Delete", "20250101000000").Return(nil).Once()
+	deleted, err = dumpster.DeleteDump(context.Background(), "20250101000000", DeleteOptions{
+		Confirm: func(BackupInfo) bool { return true },
+	})
+	require.NoError(t, err)
+	assert.True(t, deleted)
+}
+
+func TestDumpster_DeleteDump_LastBackup(t *testing.T) {
+	mockStore := storage.NewMockStorageIface(t)
+	dumpster, err := NewDumpster(&config.Config{}, mockStore, exec.NewMockExecIface(t))
+	require.NoError(t, err)
+
+	timestamps := []string{"20250101000000"}
+	mockStore.On("List").Return(timestamps, nil)
+	mockStore.On("TrimPrefix", timestamps).Return(timestamps)
+
+	_, err = dumpster.DeleteDump(context.Background(), "20250101000000", DeleteOptions{})
+	require.ErrorIs(t, err, ErrLastBackup)
+
+	mockStore.On("Delete", "20250101000000").Return(nil).Once()
+	deleted, err := dumpster.DeleteDump(context.Background(), "20250101000000", DeleteOptions{AllowLast: true})
+	require.NoError(t, err)
+	assert.True(t, deleted)
+}
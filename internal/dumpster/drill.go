@@ -0,0 +1,110 @@
+package dumpster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/hibare/stashly/internal/catalog"
+	"github.com/hibare/stashly/internal/manifest"
+)
+
+// ErrDrillIncomplete is returned when a restore drill didn't restore every database the backup lists.
+var ErrDrillIncomplete = errors.New("backup restored without all of its databases")
+
+// Drill restores the latest backup into the server the dumpster is configured for, which must be a
+// scratch server, checks that every database the backup lists was restored and drops them again.
+// The outcome is recorded in the catalog, whether or not the catalog is enabled for listing.
+func (d *Dumpster) Drill(ctx context.Context) (*catalog.Drill, error) {
+	drill := &catalog.Drill{StartedAt: time.Now()}
+	err := d.drill(ctx, drill)
+	drill.Duration = time.Since(drill.StartedAt)
+	drill.Passed = err == nil
+	if err != nil {
+		drill.Error = err.Error()
+	}
+
+	c, cErr := d.loadCatalog()
+	if cErr == nil {
+		c.RecordDrill(*drill)
+		cErr = c.Save()
+	}
+	if cErr != nil {
+		slog.WarnContext(ctx, "Failed to record restore drill", "path", d.cfg.Catalog.Path, "error", cErr)
+	}
+	return drill, err
+}
+
+func (d *Dumpster) drill(ctx context.Context, drill *catalog.Drill) error {
+	timestamp, err := d.LatestDump(ctx)
+	if err != nil {
+		return err
+	}
+	drill.Backup = timestamp
+
+	m, err := d.ReadManifest(ctx, timestamp)
+	if err != nil && !errors.Is(err, manifest.ErrNotFound) {
+		return fmt.Errorf("error reading manifest: %w", err)
+	}
+	if m == nil {
+		m = &manifest.Manifest{}
+	}
+	engine, err := d.restoreEngine(m)
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Starting restore drill", "timestamp", timestamp, "host", d.cfg.Postgres.Host)
+	resp, rErr := d.Restore(ctx, timestamp, RestoreOptions{DropExisting: true})
+
+	// Restore cleans up its working directory; the checks and drops below need one again.
+	if err := os.MkdirAll(d.restoreLocation, 0750); err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(d.restoreLocation)
+	}()
+
+	databases := m.Databases
+	if resp != nil {
+		databases = resp.Databases
+	}
+	defer d.dropDrillDatabases(context.WithoutCancel(ctx), engine, databases)
+	if rErr != nil {
+		return rErr
+	}
+
+	var missing []string
+	for _, db := range m.Databases {
+		if !slices.Contains(resp.Databases, db) {
+			missing = append(missing, db)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %v", ErrDrillIncomplete, missing)
+	}
+
+	drill.Tables = make(map[string]int, len(resp.Databases))
+	for _, db := range resp.Databases {
+		tables, err := engine.TableStats(ctx, db, d.restoreLocation)
+		if err != nil {
+			return fmt.Errorf("error inspecting database %s: %w", db, err)
+		}
+		drill.Tables[db] = len(tables)
+	}
+	return nil
+}
+
+// dropDrillDatabases drops the databases a drill restored, so the scratch server doesn't keep a copy
+// of the data between drills. Failures are only logged; the next drill replaces the databases anyway.
+func (d *Dumpster) dropDrillDatabases(ctx context.Context, engine Engine, databases []string) {
+	for _, db := range databases {
+		if err := engine.DropDatabase(ctx, db, d.restoreLocation); err != nil {
+			slog.WarnContext(ctx, "Failed to drop restore drill database", "database", db, "error", err)
+		}
+	}
+}
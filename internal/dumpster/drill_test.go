@@ -0,0 +1,76 @@
+package dumpster
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/catalog"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newDrillDumpster(t *testing.T) (*Dumpster, *storage.MockStorageIface, *exec.MockExecIface) {
+	t.Helper()
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+	cfg := &config.Config{App: config.AppConfig{InstanceID: "db-1"}, Catalog: config.CatalogConfig{Path: filepath.Join(t.TempDir(), "catalog.json")}}
+	d, err := NewDumpster(cfg, mockStore, mockExec)
+	require.NoError(t, err)
+	d.restoreLocation = filepath.Join(t.TempDir(), "restore")
+	return d, mockStore, mockExec
+}
+
+func TestDumpster_Drill(t *testing.T) {
+	d, mockStore, mockExec := newDrillDumpster(t)
+	mockCmd := exec.NewMockCmdIface(t)
+
+	key := "prefix/db-1/20250101000000/db_exports.zip"
+	mockStore.On("List").Return([]string{"20250101000000"}, nil)
+	mockStore.On("TrimPrefix", []string{"20250101000000"}).Return([]string{"20250101000000"})
+	mockStore.On("ListFiles", "20250101000000").Return([]string{key}, nil)
+	mockStore.On("Name").Return("test-storage")
+	mockStore.On("Download", key, filepath.Join(d.restoreLocation, "db_exports.zip")).
+		Run(func(args mock.Arguments) {
+			writeTestArchive(t, args.String(1), map[string]string{"app.sql": "SELECT 1;"})
+		}).Return(nil)
+	mockExec.On("LookPath", mock.Anything).Return("/usr/bin/psql", nil)
+	mockExec.On("Command", mock.Anything, "psql", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithDir", d.restoreLocation).Return(mockCmd)
+	mockCmd.On("Output").Return([]byte(""), nil)
+	mockCmd.On("CombinedOutput").Return([]byte(""), nil)
+
+	drill, err := d.Drill(context.Background())
+	require.NoError(t, err)
+	assert.True(t, drill.Passed)
+	assert.Equal(t, "20250101000000", drill.Backup)
+	assert.Equal(t, map[string]int{"app": 0}, drill.Tables)
+	assert.NoDirExists(t, d.restoreLocation)
+
+	c, err := catalog.Load(d.cfg.Catalog.Path, "db-1")
+	require.NoError(t, err)
+	last, ok := c.LastPassedDrill()
+	require.True(t, ok)
+	assert.Equal(t, "20250101000000", last.Backup)
+}
+
+func TestDumpster_Drill_NoBackups(t *testing.T) {
+	d, mockStore, _ := newDrillDumpster(t)
+	mockStore.On("List").Return([]string{}, nil)
+
+	drill, err := d.Drill(context.Background())
+	require.ErrorIs(t, err, ErrNoDumps)
+	assert.False(t, drill.Passed)
+	assert.Equal(t, ErrNoDumps.Error(), drill.Error)
+
+	c, err := catalog.Load(d.cfg.Catalog.Path, "db-1")
+	require.NoError(t, err)
+	require.Len(t, c.Drills, 1)
+	_, ok := c.LastPassedDrill()
+	assert.False(t, ok)
+}
@@ -0,0 +1,567 @@
+// Package dumpster provides functionality to create, list, restore and purge database dumps.
+package dumpster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hibare/GoCommon/v2/pkg/crypto/gpg"
+	commonHTTP "github.com/hibare/GoCommon/v2/pkg/http"
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/catalog"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/constants"
+	"github.com/hibare/stashly/internal/httpclient"
+	"github.com/hibare/stashly/internal/manifest"
+	"github.com/hibare/stashly/internal/metrics"
+	"github.com/hibare/stashly/internal/storage"
+)
+
+// DumpsterIface defines the interface for dumpster operations.
+// revive:disable-next-line exported
+type DumpsterIface interface {
+	CreateDump(ctx context.Context, opts DumpOptions) (*DumpResponse, error)
+	ListDumps(ctx context.Context) ([]string, error)
+	PurgeDumps(ctx context.Context, opts PurgeOptions) ([]string, error)
+}
+
+var _ DumpsterIface = (*Dumpster)(nil)
+
+// Dumpster runs the shared backup pipeline (archive, encrypt, upload, purge, restore) around an Engine.
+type Dumpster struct {
+	store           storage.StorageIface
+	cfg             *config.Config
+	exec            exec.ExecIface
+	engine          Engine
+	backupLocation  string
+	restoreLocation string
+	gpg             gpg.GPGIface
+}
+
+func (d *Dumpster) runPreChecks() error {
+	// Remove old backup location if exists
+	if err := os.RemoveAll(d.backupLocation); err != nil {
+		return err
+	}
+
+	// Create backup location
+	if err := os.MkdirAll(d.backupLocation, 0750); err != nil {
+		return err
+	}
+
+	// Check if required binaries are available
+	for _, bin := range d.engine.Binaries() {
+		if _, err := d.exec.LookPath(bin); err != nil {
+			return fmt.Errorf("%s not found in PATH: %w", bin, err)
+		}
+	}
+	return nil
+}
+
+func formatSkipped(skipped map[string]string) string {
+	parts := make([]string, 0, len(skipped))
+	for db, reason := range skipped {
+		parts = append(parts, fmt.Sprintf("%s: %s", db, reason))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "; ")
+}
+
+// DumpResponse holds information about the dump operation.
+type DumpResponse struct {
+	TotalDatabases    int
+	ExportedDatabases int
+	DumpLocation      string
+	ArchiveLocation   string
+	StorageKey        string
+	Timestamp         string
+
+	// Databases lists the exported databases.
+	Databases []string
+
+	// SkippedDatabases maps databases that failed the permission probe to the reason they were skipped.
+	SkippedDatabases map[string]string
+
+	// FailedDatabases maps databases whose dump failed to the error.
+	FailedDatabases map[string]string
+
+	// DatabaseSizes maps exported databases to the size in bytes of their uncompressed dump.
+	DatabaseSizes map[string]int64
+
+	// Size is the size in bytes of the uploaded artifact.
+	Size int64
+
+	// Duration is the time taken from pre-checks to a completed upload.
+	Duration time.Duration
+
+	// Compression records how well the dumps compressed.
+	Compression *manifest.Compression
+
+	// FailedOver names the secondary storage the backup was uploaded to because uploads to the
+	// primary failed; empty when it went to the primary.
+	FailedOver string
+
+	// KeyExpiresAt is when the GPG key the backup was encrypted to expires; zero if it never does or
+	// the backup isn't encrypted.
+	KeyExpiresAt time.Time
+}
+
+// DumpOptions holds per-run options for a dump.
+type DumpOptions struct {
+	// Labels are free-form key/value pairs recorded in the backup's manifest.
+	Labels map[string]string
+
+	// Snapshot marks the backup as a snapshot, kept for backup.snapshot-ttl instead of counting towards retention.
+	Snapshot bool
+
+	// FirstBackup records in the manifest that the instance had no backups before this one.
+	FirstBackup bool
+
+	// CreatedAt dates the backup; zero means now. Imports use it to place external dumps in the timeline.
+	CreatedAt time.Time
+
+	// Import describes the external dump the backup was imported from.
+	Import *manifest.Import
+}
+
+// uploadManifest writes the manifest for an uploaded backup and stores it next to the archive. With
+// backup.encrypt-manifest the manifest is stored encrypted, with a plaintext index in its place. It
+// returns the manifest as stored in plaintext.
+func (d *Dumpster) uploadManifest(ctx context.Context, m *manifest.Manifest) (*manifest.Manifest, error) {
+	path := filepath.Join(d.backupLocation, manifest.FileName)
+	if err := m.Write(path); err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = os.Remove(path)
+	}()
+
+	if !d.cfg.Backup.EncryptManifest {
+		_, err := d.store.Upload(ctx, m.Timestamp, path)
+		return m, err
+	}
+
+	checksum, err := sha256Hex(path)
+	if err != nil {
+		return nil, err
+	}
+	sealedPath, err := d.gpg.EncryptFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting manifest: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(sealedPath)
+	}()
+	if _, err := d.uploadSealed(ctx, m.Timestamp, sealedPath); err != nil {
+		return nil, err
+	}
+
+	index := m.Index(manifest.Sealed{File: filepath.Base(sealedPath), SHA256: checksum})
+	if err := index.Write(path); err != nil {
+		return nil, err
+	}
+	_, err = d.store.Upload(ctx, m.Timestamp, path)
+	return index, err
+}
+
+// CreateDump creates a dump with the configured engine, optionally encrypts it, uploads it to storage, and returns details.
+// A backup an earlier run left mid-upload is finished and returned instead.
+func (d *Dumpster) CreateDump(ctx context.Context, opts DumpOptions) (*DumpResponse, error) {
+	start := time.Now()
+
+	if err := d.checkCompliance(); err != nil {
+		return nil, err
+	}
+
+	// A run that died mid-upload left its backup behind; finish that instead of starting over.
+	if resumed, err := d.resumePending(ctx, start); err != nil || resumed != nil {
+		return resumed, err
+	}
+
+	if err := d.runPreChecks(); err != nil {
+		return nil, err
+	}
+
+	// A key that expired or was revoked would fail the run after the dump; find out before it.
+	var keyExpiresAt time.Time
+	if d.cfg.Backup.Encrypt {
+		expiry, err := d.CheckEncryptionKey(ctx)
+		if err != nil {
+			return nil, err
+		}
+		keyExpiresAt = expiry
+	}
+
+	compressor, err := d.resolveCompressor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.engine.Export(ctx, d.backupLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	dumpResp := &DumpResponse{
+		TotalDatabases:    resp.TotalDatabases,
+		ExportedDatabases: len(resp.Databases),
+		DumpLocation:      d.backupLocation,
+		Databases:         resp.Databases,
+		SkippedDatabases:  resp.Skipped,
+		FailedDatabases:   resp.Failed,
+		KeyExpiresAt:      keyExpiresAt,
+	}
+
+	if len(resp.Databases) == 0 {
+		if len(resp.Skipped) > 0 {
+			return nil, fmt.Errorf("no databases were exported (skipped: %s)", formatSkipped(resp.Skipped))
+		}
+		return nil, errors.New("no databases were exported")
+	}
+
+	return d.storeDump(ctx, start, resp, dumpResp, compressor, opts)
+}
+
+// dumpSizes returns the size of each database's dump in the backup location, before it is archived.
+func (d *Dumpster) dumpSizes(databases []string) map[string]int64 {
+	sizes := make(map[string]int64, len(databases))
+	for _, db := range databases {
+		if info, err := os.Stat(filepath.Join(d.backupLocation, db+d.engine.Extension())); err == nil {
+			sizes[db] = info.Size()
+		}
+	}
+	return sizes
+}
+
+// storeDump archives the dumps in the backup location, optionally encrypts the archive, and uploads it
+// with its manifest.
+func (d *Dumpster) storeDump(
+	ctx context.Context, start time.Time, resp *ExportResult, dumpResp *DumpResponse, compressor string, opts DumpOptions,
+) (*DumpResponse, error) {
+	dumpResp.DatabaseSizes = d.dumpSizes(resp.Databases)
+	raw := d.rawSize()
+
+	archivePath, err := d.archiveDumps(ctx, compressor)
+	if err != nil {
+		return nil, err
+	}
+	dumpResp.Compression = d.compressionStats(archivePath, raw, dumpResp.DatabaseSizes)
+
+	uploadFilePath := archivePath
+
+	if d.cfg.Backup.Encrypt {
+		slog.DebugContext(ctx, "fetching gpg key", "key_id", d.cfg.Encryption.GPG.KeyID, "key_server", d.cfg.Encryption.GPG.KeyServer)
+		_, gErr := d.gpg.FetchGPGPubKeyFromKeyServer(d.cfg.Encryption.GPG.KeyID, d.cfg.Encryption.GPG.KeyServer)
+		if gErr != nil {
+			slog.WarnContext(ctx, "Error downloading gpg key", "error", gErr)
+			return nil, gErr
+		}
+
+		slog.DebugContext(ctx, "Encrypting archive file", "file", archivePath, "framed", d.cfg.Backup.EncryptFramed)
+		encrypt := d.gpg.EncryptFile
+		if d.cfg.Backup.EncryptFramed {
+			encrypt = d.encryptFramed
+		}
+		encryptedFilePath, gErr := encrypt(archivePath)
+		if gErr != nil {
+			slog.WarnContext(ctx, "Error encrypting archive file", "error", gErr)
+			return nil, gErr
+		}
+		slog.DebugContext(ctx, "Encrypted file", "file", encryptedFilePath)
+		uploadFilePath = encryptedFilePath
+	}
+
+	info, err := os.Stat(uploadFilePath)
+	if err != nil {
+		return nil, err
+	}
+	checksum, err := sha256Hex(uploadFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt := opts.CreatedAt
+	var timestamp string
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+		timestamp = d.nextTimestamp(ctx, createdAt)
+	} else {
+		// Imports are placed in the timeline where they were taken, not after the latest backup.
+		timestamp = createdAt.Format(constants.DefaultDateTimeLayout)
+	}
+
+	m := &manifest.Manifest{
+		Version:      manifest.Version,
+		Engine:       d.engine.Name(),
+		Format:       d.engine.Format(),
+		Timestamp:    timestamp,
+		InstanceID:   d.cfg.App.InstanceID,
+		CreatedAt:    createdAt.UTC(),
+		Archive:      filepath.Base(uploadFilePath),
+		Compressor:   compressorOf(archivePath),
+		Encrypted:    d.cfg.Backup.Encrypt,
+		Framed:       d.cfg.Backup.Encrypt && d.cfg.Backup.EncryptFramed,
+		Size:         info.Size(),
+		SHA256:       checksum,
+		Databases:    resp.Databases,
+		Labels:       opts.Labels,
+		Snapshot:     opts.Snapshot,
+		FirstBackup:  opts.FirstBackup,
+		Import:       opts.Import,
+		Uncovered:    uncovered(resp),
+		Extensions:   resp.Extensions,
+		RestoreNotes: resp.RestoreNotes,
+		SnapshotLSN:  resp.SnapshotLSN,
+		Inventory:    resp.Inventory,
+		Provenance:   d.provenance(ctx, compressor, opts.Import != nil),
+		Compression:  dumpResp.Compression,
+	}
+
+	var key string
+	if volumeSize := d.cfg.Backup.VolumeSizeMB * 1024 * 1024; volumeSize > 0 && info.Size() > volumeSize {
+		keys, names, vErr := d.uploadVolumes(ctx, timestamp, uploadFilePath, volumeSize)
+		if vErr != nil {
+			return nil, vErr
+		}
+		key, m.Volumes = keys[0], names
+		if _, mErr := d.storeManifest(ctx, m); mErr != nil {
+			return nil, fmt.Errorf("error uploading manifest: %w", mErr)
+		}
+	} else {
+		key, err = d.uploadBackup(ctx, &pendingUpload{File: uploadFilePath, Manifest: m})
+		if err != nil {
+			return nil, err
+		}
+	}
+	failedOver := storage.FailedOverTo(d.store, timestamp)
+
+	slog.InfoContext(ctx, "Backup uploaded", "location", key)
+	dumpResp.ArchiveLocation = archivePath
+	dumpResp.StorageKey = key
+	dumpResp.Timestamp = timestamp
+	dumpResp.FailedOver = failedOver
+	dumpResp.Size = info.Size()
+	dumpResp.Duration = time.Since(start)
+	return dumpResp, nil
+}
+
+// nextTimestamp returns the timestamp for a backup taken at now. If the clock is behind the latest
+// backup in storage, e.g. after a bad NTP sync or a restore of the host from a snapshot, it returns
+// one second past that backup instead: retention keeps the newest timestamps, so a backup named
+// after the clock would be the first one purged. The manifest still records the clock's time.
+func (d *Dumpster) nextTimestamp(ctx context.Context, now time.Time) string {
+	timestamp := now.Format(constants.DefaultDateTimeLayout)
+
+	latest, err := d.LatestDump(ctx)
+	if errors.Is(err, ErrNoDumps) {
+		return timestamp
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to check the backup timestamp against the latest backup", "timestamp", timestamp, "error", err)
+		return timestamp
+	}
+	if timestamp > latest {
+		return timestamp
+	}
+
+	taken, err := ParseTimestamp(latest)
+	if err != nil {
+		return timestamp
+	}
+	next := taken.Add(time.Second).Format(constants.DefaultDateTimeLayout)
+	slog.WarnContext(ctx, "Clock is behind the latest backup; naming the backup after it instead",
+		"clock", timestamp, "latest", latest, "timestamp", next, "skew", taken.Sub(now).Round(time.Second))
+	metrics.BackupClockSkew.Inc()
+	return next
+}
+
+// ParseTimestamp returns when the backup named timestamp was taken. Backups are named after the
+// local time they were taken at, always in constants.DefaultDateTimeLayout.
+func ParseTimestamp(timestamp string) (time.Time, error) {
+	return time.ParseInLocation(constants.DefaultDateTimeLayout, timestamp, time.Local)
+}
+
+// isTimestamp reports whether key names a backup, i.e. is a timestamp in the layout backups are
+// stored under.
+func isTimestamp(key string) bool {
+	t, err := time.Parse(constants.DefaultDateTimeLayout, key)
+	return err == nil && t.Format(constants.DefaultDateTimeLayout) == key
+}
+
+// splitTimestamps separates backup timestamps, sorted newest first, from keys that aren't backups,
+// such as objects someone else put under the prefix. The backup index is neither.
+func splitTimestamps(keys []string) ([]string, []string) {
+	timestamps := []string{}
+	unknown := []string{}
+	for _, key := range keys {
+		if key == IndexFileName {
+			continue
+		}
+		if isTimestamp(key) {
+			timestamps = append(timestamps, key)
+		} else {
+			unknown = append(unknown, key)
+		}
+	}
+	// The layout sorts chronologically, so newest first is reverse lexical order.
+	sort.Sort(sort.Reverse(sort.StringSlice(timestamps)))
+	sort.Strings(unknown)
+	return timestamps, unknown
+}
+
+// ListDumps lists available dumps in the storage backend, newest first. Entries under the instance
+// prefix that aren't backup timestamps are reported and left out, so they can't be mis-sorted or
+// purged.
+func (d *Dumpster) ListDumps(ctx context.Context) ([]string, error) {
+	keys, err := d.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		slog.InfoContext(ctx, "No backups found")
+		return []string{}, nil
+	}
+
+	keys, unknown := splitTimestamps(d.store.TrimPrefix(keys))
+	if len(unknown) > 0 {
+		slog.WarnContext(ctx, "Ignoring objects that aren't backups in the backup prefix", "count", len(unknown), "keys", unknown)
+	}
+	slog.DebugContext(ctx, "Found backups", "keys", keys)
+	return keys, nil
+}
+
+// PurgeDumps deletes old dumps from storage based on the retention policy and returns the timestamps
+// it deleted. Nothing is deleted if the purge breaks an invariant (see checkPurge). Deletes are paced
+// to backup.purge-rate. A failed delete doesn't stop the others; the failures are reported together,
+// and the backups left behind are deleted by a later purge.
+func (d *Dumpster) PurgeDumps(ctx context.Context, opts PurgeOptions) ([]string, error) {
+	backups, err := d.ListBackups(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Snapshots don't count towards the retention count; they expire on their own TTL instead.
+	regular := 0
+	expired := 0
+	candidates := []string{}
+	for _, b := range backups {
+		if b.Manifest == nil || !b.Manifest.Snapshot {
+			if regular++; regular > d.cfg.Backup.RetentionCount {
+				candidates = append(candidates, b.Timestamp)
+			}
+			continue
+		}
+		if ttl := d.cfg.Backup.SnapshotTTL; ttl > 0 && time.Since(b.Manifest.CreatedAt) > ttl {
+			expired++
+			candidates = append(candidates, b.Timestamp)
+		}
+	}
+
+	// Whatever the policies say, at least retention-min-count backups stay, e.g. when every snapshot
+	// expired during a long outage. Candidates are newest first, so the newest are spared.
+	if spare := min(d.cfg.Backup.RetentionMinCount-(len(backups)-len(candidates)), len(candidates)); spare > 0 {
+		slog.WarnContext(ctx, "Keeping expired backups to stay at the retention floor",
+			"kept", candidates[:spare], "retention_min_count", d.cfg.Backup.RetentionMinCount)
+		candidates = candidates[spare:]
+	}
+
+	// A backup being restored is left for a later pass rather than deleted from under the download.
+	keysToDelete := []string{}
+	for _, key := range candidates {
+		if isRestoring(key) {
+			slog.InfoContext(ctx, "Skipping backup being restored", "key", key)
+			continue
+		}
+		keysToDelete = append(keysToDelete, key)
+	}
+
+	if len(keysToDelete) == 0 {
+		slog.InfoContext(ctx, "No backups to delete")
+		return []string{}, nil
+	}
+
+	slog.InfoContext(ctx, "Found backups to delete", "count", len(keysToDelete), "retention", d.cfg.Backup.RetentionCount, "expired_snapshots", expired)
+	if err := d.checkPurge(backups, keysToDelete, opts); err != nil {
+		slog.ErrorContext(ctx, "Refusing to purge", "count", len(keysToDelete), "backups", len(backups), "error", err)
+		return []string{}, err
+	}
+
+	var pace <-chan time.Time
+	if rate := d.cfg.Backup.PurgeRate; rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+		pace = ticker.C
+	}
+
+	deleted := []string{}
+	errs := []error{}
+	for i, key := range keysToDelete {
+		if pace != nil && i > 0 {
+			select {
+			case <-ctx.Done():
+			case <-pace:
+			}
+		}
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+
+		slog.InfoContext(ctx, "Deleting backup", "key", key)
+		if sErr := d.store.Delete(ctx, key); sErr != nil {
+			slog.ErrorContext(ctx, "Error deleting backup", "key", key, "error", sErr)
+			errs = append(errs, fmt.Errorf("error deleting backup %s: %w", key, sErr))
+			continue
+		}
+		d.updateCatalog(ctx, func(c *catalog.Catalog) { c.Remove(key) })
+		deleted = append(deleted, key)
+	}
+	if len(errs) > 0 {
+		return deleted, fmt.Errorf("%d of %d backups not deleted: %w", len(keysToDelete)-len(deleted), len(keysToDelete), errors.Join(errs...))
+	}
+	slog.InfoContext(ctx, "Deletion completed successfully", "count", len(deleted))
+	return deleted, nil
+}
+
+// NewDumpster creates a new Dumpster for the configured engine, storage backend, and executor.
+func NewDumpster(cfg *config.Config, store storage.StorageIface, exec exec.ExecIface) (*Dumpster, error) {
+	name := cfg.Backup.Engine
+	if name == "" {
+		name = constants.DefaultEngine
+	}
+
+	engine, err := NewEngine(name, cfg, exec)
+	if err != nil {
+		return nil, err
+	}
+
+	// Key server requests use the configured proxy and TLS settings.
+	httpClient, err := httpclient.New(cfg, commonHTTP.DefaultHTTPClientTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fleet hosts are backed up side by side, each in its own work directories.
+	exportDir, restoreDir := constants.ExportDir, constants.RestoreDir
+	if name := cfg.Postgres.Name; name != "" {
+		exportDir, restoreDir = exportDir+"-"+name, restoreDir+"-"+name
+	}
+
+	return &Dumpster{
+		store:           store,
+		cfg:             cfg,
+		exec:            exec,
+		engine:          engine,
+		backupLocation:  filepath.Join(os.TempDir(), exportDir),
+		restoreLocation: filepath.Join(os.TempDir(), restoreDir),
+		gpg:             gpg.NewGPG(gpg.Options{HTTPClient: httpClient}),
+	}, nil
+}
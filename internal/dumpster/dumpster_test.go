@@ -0,0 +1,460 @@
+package dumpster
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/metrics"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDumpster(t *testing.T) {
+	cfg := &config.Config{}
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+
+	dumpster, err := NewDumpster(cfg, mockStore, mockExec)
+	require.NoError(t, err)
+
+	assert.NotNil(t, dumpster)
+	assert.Equal(t, cfg, dumpster.cfg)
+	assert.Equal(t, mockStore, dumpster.store)
+	assert.Equal(t, mockExec, dumpster.exec)
+	assert.Contains(t, dumpster.backupLocation, "export")
+	assert.Equal(t, "postgres", dumpster.engine.Name())
+}
+
+func TestNewDumpster_UnknownEngine(t *testing.T) {
+	cfg := &config.Config{Backup: config.BackupConfig{Engine: "oracle"}}
+
+	_, err := NewDumpster(cfg, storage.NewMockStorageIface(t), exec.NewMockExecIface(t))
+
+	require.ErrorIs(t, err, ErrUnknownEngine)
+}
+
+func TestDumpster_runPreChecks_Success(t *testing.T) {
+	cfg := &config.Config{}
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+
+	dumpster, err := NewDumpster(cfg, mockStore, mockExec)
+	require.NoError(t, err)
+
+	// Mock successful binary lookups
+	mockExec.On("LookPath", "psql").Return("/usr/bin/psql", nil)
+	mockExec.On("LookPath", "pg_dump").Return("/usr/bin/pg_dump", nil)
+
+	err = dumpster.runPreChecks()
+
+	require.NoError(t, err)
+	mockExec.AssertExpectations(t)
+
+	// Cleanup
+	_ = os.RemoveAll(dumpster.backupLocation)
+}
+
+// writeCompleteDump returns a mock Run func standing in for pg_dump writing a complete dump of db.
+func writeCompleteDump(t *testing.T, dir, db string) func(mock.Arguments) {
+	t.Helper()
+	return func(mock.Arguments) {
+		content := "CREATE TABLE t ();\n\n--\n-- PostgreSQL database dump complete\n--\n"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, db+".sql"), []byte(content), 0600))
+	}
+}
+
+func TestDumpster_runPreChecks_BinaryNotFound(t *testing.T) {
+	cfg := &config.Config{}
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+
+	dumpster, err := NewDumpster(cfg, mockStore, mockExec)
+	require.NoError(t, err)
+
+	// Mock failed binary lookup
+	mockExec.On("LookPath", "psql").Return("", errors.New("binary not found"))
+
+	err = dumpster.runPreChecks()
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "psql not found in PATH")
+	mockExec.AssertExpectations(t)
+}
+
+func TestDumpster_CreateDump_Success(t *testing.T) {
+	cfg := &config.Config{
+		Backup: config.BackupConfig{
+			Encrypt: false,
+		},
+	}
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+	mockCmd := exec.NewMockCmdIface(t)
+
+	dumpster, err := NewDumpster(cfg, mockStore, mockExec)
+	require.NoError(t, err)
+
+	// Mock successful pre-checks
+	mockExec.On("LookPath", "psql").Return("/usr/bin/psql", nil)
+	mockExec.On("LookPath", "pg_dump").Return("/usr/bin/pg_dump", nil)
+
+	// Mock successful database listing
+	mockExec.On("Command", mock.Anything, "psql", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithDir", dumpster.backupLocation).Return(mockCmd)
+	mockCmd.On("WithStderr", os.Stderr).Return(mockCmd)
+	mockCmd.On("Output").Return([]byte("db1\n"), nil)
+
+	// Mock successful pg_dump
+	mockExec.On("Command", mock.Anything, "pg_dump", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithDir", dumpster.backupLocation).Return(mockCmd)
+	mockCmd.On("WithStderr", os.Stderr).Return(mockCmd)
+	mockCmd.On("CombinedOutput").Run(writeCompleteDump(t, dumpster.backupLocation, "db1")).Return([]byte(""), nil)
+
+	// Mock successful storage upload, the first for this instance
+	mockStore.On("List").Return([]string{}, nil)
+	mockStore.On("Name").Return("test-storage")
+	mockStore.On("Upload", mock.Anything, mock.Anything).Return("backup-2024-01-01.tar.gz", nil)
+
+	resp, err := dumpster.CreateDump(context.Background(), DumpOptions{})
+
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, 1, resp.TotalDatabases)
+	assert.Equal(t, 1, resp.ExportedDatabases)
+	assert.Equal(t, dumpster.backupLocation, resp.DumpLocation)
+	assert.Equal(t, "backup-2024-01-01.tar.gz", resp.StorageKey)
+
+	mockExec.AssertExpectations(t)
+	mockCmd.AssertExpectations(t)
+	mockStore.AssertExpectations(t)
+
+	// Cleanup
+	_ = os.RemoveAll(dumpster.backupLocation)
+}
+
+func TestDumpster_CreateDump_NoDatabasesExported(t *testing.T) {
+	cfg := &config.Config{}
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+	mockCmd := exec.NewMockCmdIface(t)
+
+	dumpster, err := NewDumpster(cfg, mockStore, mockExec)
+	require.NoError(t, err)
+
+	// Mock successful pre-checks
+	mockExec.On("LookPath", "psql").Return("/usr/bin/psql", nil)
+	mockExec.On("LookPath", "pg_dump").Return("/usr/bin/pg_dump", nil)
+
+	// Mock successful database listing but no databases
+	mockExec.On("Command", mock.Anything, "psql", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithDir", dumpster.backupLocation).Return(mockCmd)
+	mockCmd.On("WithStderr", os.Stderr).Return(mockCmd)
+	mockCmd.On("Output").Return([]byte(""), nil)
+
+	resp, err := dumpster.CreateDump(context.Background(), DumpOptions{})
+
+	require.Error(t, err)
+	require.Nil(t, resp)
+	assert.Contains(t, err.Error(), "no databases were exported")
+
+	mockExec.AssertExpectations(t)
+	mockCmd.AssertExpectations(t)
+}
+
+func TestDumpster_CreateDump_PgDumpError(t *testing.T) {
+	cfg := &config.Config{
+		Backup: config.BackupConfig{
+			Encrypt: false,
+		},
+	}
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+	mockCmd := exec.NewMockCmdIface(t)
+
+	dumpster, err := NewDumpster(cfg, mockStore, mockExec)
+	require.NoError(t, err)
+
+	// Mock successful pre-checks
+	mockExec.On("LookPath", "psql").Return("/usr/bin/psql", nil)
+	mockExec.On("LookPath", "pg_dump").Return("/usr/bin/pg_dump", nil)
+
+	// Mock successful database listing
+	mockExec.On("Command", mock.Anything, "psql", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithDir", dumpster.backupLocation).Return(mockCmd)
+	mockCmd.On("WithStderr", os.Stderr).Return(mockCmd)
+	mockCmd.On("Output").Return([]byte("db1\n"), nil)
+
+	// Mock failed pg_dump
+	mockExec.On("Command", mock.Anything, "pg_dump", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithDir", dumpster.backupLocation).Return(mockCmd)
+	mockCmd.On("WithStderr", os.Stderr).Return(mockCmd)
+	mockCmd.On("CombinedOutput").Return([]byte("permission denied"), errors.New("access denied"))
+
+	resp, err := dumpster.CreateDump(context.Background(), DumpOptions{})
+
+	require.Error(t, err)
+	require.Nil(t, resp)
+	assert.Contains(t, err.Error(), "no databases were exported")
+
+	mockExec.AssertExpectations(t)
+	mockCmd.AssertExpectations(t)
+}
+
+func TestDumpster_ListDumps_Success(t *testing.T) {
+	cfg := &config.Config{}
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+
+	dumpster, err := NewDumpster(cfg, mockStore, mockExec)
+	require.NoError(t, err)
+
+	// Mock successful storage listing, with objects that aren't backups mixed in
+	keys := []string{"20240101000000", "README.txt", "20240102000000", ".stashly-sse-check", "2024-01-03"}
+	mockStore.On("List").Return(keys, nil)
+	mockStore.On("TrimPrefix", keys).Return(keys)
+
+	dumps, err := dumpster.ListDumps(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20240102000000", "20240101000000"}, dumps)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestDumpster_ListDumps_Empty(t *testing.T) {
+	cfg := &config.Config{}
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+
+	dumpster, err := NewDumpster(cfg, mockStore, mockExec)
+	require.NoError(t, err)
+
+	// Mock empty storage listing
+	mockStore.On("List").Return([]string{}, nil)
+
+	dumps, err := dumpster.ListDumps(context.Background())
+
+	require.NoError(t, err)
+	assert.Empty(t, dumps)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestDumpster_ListDumps_StorageError(t *testing.T) {
+	cfg := &config.Config{}
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+
+	dumpster, err := NewDumpster(cfg, mockStore, mockExec)
+	require.NoError(t, err)
+
+	// Mock storage error
+	mockStore.On("List").Return(nil, errors.New("storage connection failed"))
+
+	dumps, err := dumpster.ListDumps(context.Background())
+
+	require.Error(t, err)
+	require.Nil(t, dumps)
+	assert.Contains(t, err.Error(), "storage connection failed")
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestDumpster_PurgeDumps_Success(t *testing.T) {
+	cfg := &config.Config{
+		Backup: config.BackupConfig{
+			RetentionCount: 2,
+		},
+	}
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+
+	dumpster, err := NewDumpster(cfg, mockStore, mockExec)
+	require.NoError(t, err)
+
+	// Mock successful storage listing
+	keys := []string{"20240101000000", "20240102000000", "20240103000000"}
+	mockStore.On("List").Return(keys, nil)
+	mockStore.On("TrimPrefix", keys).Return(keys)
+	mockStore.On("ListFiles", mock.Anything).Return([]string{}, nil)
+
+	// Mock successful deletion of old backup
+	// Note: The actual key will be transformed by datetime.SortDateTimes
+	mockStore.On("Delete", mock.Anything).Return(nil)
+
+	deleted, err := dumpster.PurgeDumps(context.Background(), PurgeOptions{})
+
+	require.NoError(t, err)
+	assert.Len(t, deleted, 1)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestDumpster_PurgeDumps_NoDeletionNeeded(t *testing.T) {
+	cfg := &config.Config{
+		Backup: config.BackupConfig{
+			RetentionCount: 3,
+		},
+	}
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+
+	dumpster, err := NewDumpster(cfg, mockStore, mockExec)
+	require.NoError(t, err)
+
+	// Mock storage listing with fewer keys than retention count
+	keys := []string{"20240101000000", "20240102000000"}
+	mockStore.On("List").Return(keys, nil)
+	mockStore.On("TrimPrefix", keys).Return(keys)
+	mockStore.On("ListFiles", mock.Anything).Return([]string{}, nil)
+
+	_, err = dumpster.PurgeDumps(context.Background(), PurgeOptions{})
+
+	require.NoError(t, err)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestDumpster_PurgeDumps_DeleteError(t *testing.T) {
+	cfg := &config.Config{
+		Backup: config.BackupConfig{
+			RetentionCount: 2,
+		},
+	}
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+
+	dumpster, err := NewDumpster(cfg, mockStore, mockExec)
+	require.NoError(t, err)
+
+	// Mock successful storage listing
+	keys := []string{"20240101000000", "20240102000000", "20240103000000"}
+	mockStore.On("List").Return(keys, nil)
+	mockStore.On("TrimPrefix", keys).Return(keys)
+	mockStore.On("ListFiles", mock.Anything).Return([]string{}, nil)
+
+	// Mock failed deletion
+	// Note: The actual key will be transformed by datetime.SortDateTimes
+	mockStore.On("Delete", mock.Anything).Return(errors.New("delete failed"))
+
+	_, err = dumpster.PurgeDumps(context.Background(), PurgeOptions{})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error deleting backup")
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestDumpster_PurgeDumps_ContinuesAfterError(t *testing.T) {
+	cfg := &config.Config{
+		Backup: config.BackupConfig{
+			RetentionCount: 1,
+			PurgeRate:      1000,
+		},
+	}
+	mockStore := storage.NewMockStorageIface(t)
+
+	dumpster, err := NewDumpster(cfg, mockStore, exec.NewMockExecIface(t))
+	require.NoError(t, err)
+
+	keys := []string{"20240101000000", "20240102000000", "20240103000000", "20240104000000"}
+	mockStore.On("List").Return(keys, nil)
+	mockStore.On("TrimPrefix", keys).Return(keys)
+	mockStore.On("ListFiles", mock.Anything).Return([]string{}, nil)
+	mockStore.On("Delete", "20240102000000").Return(errors.New("slow down"))
+	mockStore.On("Delete", mock.Anything).Return(nil)
+
+	deleted, err := dumpster.PurgeDumps(context.Background(), PurgeOptions{})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 of 3 backups not deleted")
+	assert.Contains(t, err.Error(), "error deleting backup 20240102000000: slow down")
+	assert.Equal(t, []string{"20240103000000", "20240101000000"}, deleted)
+}
+
+func TestDumpster_PurgeDumps_SkipsBackupBeingRestored(t *testing.T) {
+	cfg := &config.Config{
+		Backup: config.BackupConfig{
+			RetentionCount: 1,
+		},
+	}
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+
+	dumpster, err := NewDumpster(cfg, mockStore, mockExec)
+	require.NoError(t, err)
+
+	keys := []string{"20240103000000", "20240102000000", "20240101000000"}
+	mockStore.On("List").Return(keys, nil)
+	mockStore.On("TrimPrefix", keys).Return(keys)
+	mockStore.On("ListFiles", mock.Anything).Return([]string{}, nil)
+	mockStore.On("Delete", "20240101000000").Return(nil).Twice()
+
+	unlock := lockForRestore("20240102000000")
+	_, err = dumpster.PurgeDumps(context.Background(), PurgeOptions{})
+	require.NoError(t, err)
+	mockStore.AssertNotCalled(t, "Delete", "20240102000000")
+
+	// Once the restore finishes the backup is purged as usual.
+	unlock()
+	unlock()
+	assert.False(t, isRestoring("20240102000000"))
+	mockStore.On("Delete", "20240102000000").Return(nil).Once()
+	_, err = dumpster.PurgeDumps(context.Background(), PurgeOptions{})
+	require.NoError(t, err)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestDumpster_nextTimestamp(t *testing.T) {
+	now := time.Date(2025, 1, 2, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name   string
+		latest []string
+		err    error
+		want   string
+		skewed bool
+	}{
+		{name: "first backup", latest: []string{}, want: "20250102120000"},
+		{name: "clock ahead", latest: []string{"20250101120000"}, want: "20250102120000"},
+		{name: "clock behind", latest: []string{"20250103080000", "20250101120000"}, want: "20250103080001", skewed: true},
+		{name: "same second", latest: []string{"20250102120000"}, want: "20250102120001", skewed: true},
+		{name: "listing fails", err: errors.New("list failed"), want: "20250102120000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := storage.NewMockStorageIface(t)
+			dumpster, err := NewDumpster(&config.Config{}, mockStore, exec.NewMockExecIface(t))
+			require.NoError(t, err)
+
+			mockStore.On("List").Return(tt.latest, tt.err).Once()
+			if len(tt.latest) > 0 {
+				mockStore.On("TrimPrefix", tt.latest).Return(tt.latest).Once()
+			}
+
+			before := testutil.ToFloat64(metrics.BackupClockSkew)
+			assert.Equal(t, tt.want, dumpster.nextTimestamp(context.Background(), now))
+			skews := testutil.ToFloat64(metrics.BackupClockSkew) - before
+			if tt.skewed {
+				assert.InDelta(t, 1, skews, 0)
+			} else {
+				assert.InDelta(t, 0, skews, 0)
+			}
+		})
+	}
+}
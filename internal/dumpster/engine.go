@@ -0,0 +1,147 @@
+package dumpster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/manifest"
+)
+
+// ErrUnknownEngine is returned when the configured engine has not been registered.
+var ErrUnknownEngine = errors.New("unknown dump engine")
+
+// ExportResult holds the outcome of an engine export.
+type ExportResult struct {
+	// TotalDatabases is the number of databases found on the server.
+	TotalDatabases int
+
+	// Databases lists the databases that were exported successfully.
+	Databases []string
+
+	// Skipped maps databases that failed the permission probe to the reason why.
+	Skipped map[string]string
+
+	// Failed maps databases whose dump failed to the error.
+	Failed map[string]string
+
+	// Extensions maps exported databases to the extensions they use that need special handling on restore.
+	Extensions map[string][]string
+
+	// Inventory maps exported databases to the settings they were created with.
+	Inventory map[string]manifest.DatabaseInfo
+
+	// SnapshotLSN maps databases dumped from a replication slot snapshot to the slot's consistent point.
+	SnapshotLSN map[string]string
+
+	// RestoreNotes are human-readable instructions for restoring the dumps by hand.
+	RestoreNotes []string
+}
+
+// TableStat describes an existing table in a restore target.
+type TableStat struct {
+	Name string
+
+	// Rows is the server's estimate of the number of live rows.
+	Rows int64
+}
+
+// RestoreTarget describes one database dump to restore.
+type RestoreTarget struct {
+	Database string
+	DumpFile string
+
+	// Extensions are the database's entries in ExportResult.Extensions, as recorded in the manifest.
+	Extensions []string
+
+	// Info holds the settings to create the database with; fields left empty use the server defaults.
+	Info manifest.DatabaseInfo
+}
+
+// Engine dumps and restores the databases of one kind of database server.
+// The shared pipeline (archive, encrypt, upload, purge, download) lives in Dumpster.
+type Engine interface {
+	// Name returns the name the engine is registered under (e.g. "postgres").
+	Name() string
+
+	// Binaries returns the executables that must be available in PATH.
+	Binaries() []string
+
+	// Extension returns the file extension of per-database dump files (e.g. ".sql").
+	Extension() string
+
+	// Format returns the dump format Export writes (e.g. "plain"), recorded in the archive name and manifest.
+	Format() string
+
+	// Export dumps every database into dir, one file per database named <db><Extension>.
+	// Any other files it writes are restored through RestoreGlobals.
+	Export(ctx context.Context, dir string) (*ExportResult, error)
+
+	// StreamDatabase writes a dump of db in the engine's format to w; dir is the working directory for tools.
+	StreamDatabase(ctx context.Context, db string, w *os.File, dir string) error
+
+	// IsEmpty reports whether the server holds no user data; dir is the working directory for tools.
+	IsEmpty(ctx context.Context, dir string) (bool, error)
+
+	// TableStats lists the user tables in db; it returns no tables if db doesn't exist.
+	TableStats(ctx context.Context, db, dir string) ([]TableStat, error)
+
+	// DropDatabase drops db if it exists.
+	DropDatabase(ctx context.Context, db, dir string) error
+
+	// RestoreGlobals loads the cluster-wide objects (e.g. roles) Export saved in dumpDir, if any,
+	// before any database is restored.
+	RestoreGlobals(ctx context.Context, dumpDir, dir string) error
+
+	// RestoreDatabase loads the target's dump into its database, creating it if needed; dir is the working directory for tools.
+	RestoreDatabase(ctx context.Context, target RestoreTarget, dir string) error
+}
+
+// Factory creates an engine from the configuration.
+type Factory func(cfg *config.Config, exec exec.ExecIface) Engine
+
+var (
+	enginesMu sync.RWMutex
+	engines   = map[string]Factory{}
+)
+
+// Register makes an engine available under name. It panics if name is registered twice.
+func Register(name string, factory Factory) {
+	enginesMu.Lock()
+	defer enginesMu.Unlock()
+
+	if _, dup := engines[name]; dup {
+		panic("dumpster: Register called twice for engine " + name)
+	}
+	engines[name] = factory
+}
+
+// Engines returns the sorted names of all registered engines.
+func Engines() []string {
+	enginesMu.RLock()
+	defer enginesMu.RUnlock()
+
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewEngine creates the engine registered under name.
+func NewEngine(name string, cfg *config.Config, exec exec.ExecIface) (Engine, error) {
+	enginesMu.RLock()
+	factory, ok := engines[name]
+	enginesMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w %q (available: %v)", ErrUnknownEngine, name, Engines())
+	}
+	return factory(cfg, exec), nil
+}
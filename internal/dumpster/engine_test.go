@@ -0,0 +1,29 @@
+package dumpster
+
+import (
+	"testing"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEngine(t *testing.T) {
+	engine, err := NewEngine("postgres", &config.Config{}, exec.NewMockExecIface(t))
+	require.NoError(t, err)
+	assert.Equal(t, "postgres", engine.Name())
+
+	_, err = NewEngine("oracle", &config.Config{}, exec.NewMockExecIface(t))
+	require.ErrorIs(t, err, ErrUnknownEngine)
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	assert.Panics(t, func() {
+		Register("postgres", NewPostgres)
+	})
+}
+
+func TestEngines(t *testing.T) {
+	assert.Contains(t, Engines(), "postgres")
+}
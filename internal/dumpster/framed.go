@@ -0,0 +1,105 @@
+package dumpster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hibare/stashly/internal/framed"
+)
+
+// framedExt is appended to archives encrypted in frames, as ".gpg" is to OpenPGP messages.
+const framedExt = ".framed"
+
+// plainName returns the name of an archive before encryption.
+func plainName(name string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(name, ".gpg"), framedExt)
+}
+
+// encryptFramed encrypts the archive at path in frames for the configured GPG key and returns the
+// encrypted path.
+func (d *Dumpster) encryptFramed(path string) (string, error) {
+	publicKey, err := d.gpg.ReadPublicKeyFromFile()
+	if err != nil {
+		return "", fmt.Errorf("failed to read public key: %w", err)
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	outPath := filepath.Join(os.TempDir(), filepath.Base(path)+framedExt)
+	out, err := os.OpenFile(outPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+	w, err := framed.NewWriter(out, publicKey)
+	if err == nil {
+		if _, err = io.Copy(w, in); err == nil {
+			err = w.Close()
+		}
+	}
+	if cErr := out.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		_ = os.Remove(outPath)
+		return "", err
+	}
+	return outPath, nil
+}
+
+// decryptFramed decrypts an archive encrypted in frames with the configured private key and returns
+// the decrypted path. Each frame is verified before it is written, so a tampered or truncated archive
+// fails at the first bad frame. gpg-agent can't unwrap the frame key, so the key file is required.
+func (d *Dumpster) decryptFramed(ctx context.Context, path string) (string, error) {
+	switch {
+	case d.cfg.Encryption.GPG.PrivateKeyFile != "":
+	case d.cfg.Encryption.GPG.Agent:
+		return "", errors.New("framed archives can't be decrypted with encryption.gpg.agent; set encryption.gpg.private-key-file")
+	default:
+		return "", ErrNoPrivateKey
+	}
+	privateKey, err := os.ReadFile(d.cfg.Encryption.GPG.PrivateKeyFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	slog.DebugContext(ctx, "Decrypting framed archive file", "file", path)
+	r, err := framed.NewReader(in, string(privateKey), d.cfg.Encryption.GPG.Passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	plainPath := filepath.Join(os.TempDir(), plainName(filepath.Base(path)))
+	out, err := os.OpenFile(plainPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(out, r)
+	if cErr := out.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		_ = os.Remove(plainPath)
+		return "", fmt.Errorf("error decrypting %s: %w", path, err)
+	}
+	return plainPath, nil
+}
@@ -0,0 +1,82 @@
+package dumpster
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/framed"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePrivateKey writes entity's armored private key to a file in dir and returns its path.
+func writePrivateKey(t *testing.T, dir string, entity *openpgp.Entity) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PrivateKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.SerializePrivate(w, nil))
+	require.NoError(t, w.Close())
+
+	path := filepath.Join(dir, "private.asc")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0600))
+	return path
+}
+
+func TestDumpster_Framed_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	entity, err := openpgp.NewEntity("stashly", "", "stashly@example.com", nil)
+	require.NoError(t, err)
+
+	cfg := &config.Config{Backup: config.BackupConfig{Encrypt: true, EncryptFramed: true}}
+	cfg.Encryption.GPG.PrivateKeyFile = writePrivateKey(t, dir, entity)
+	d, err := NewDumpster(cfg, storage.NewMockStorageIface(t), exec.NewMockExecIface(t))
+	require.NoError(t, err)
+	d.gpg = &keyGPG{fakeGPG: fakeGPG{dir: dir}, publicKey: armoredEntity(t, entity)}
+
+	archive := filepath.Join(dir, "db_exports.zip")
+	content := bytes.Repeat([]byte("dump "), framed.ChunkSize/2)
+	require.NoError(t, os.WriteFile(archive, content, 0600))
+
+	encPath, err := d.encryptFramed(archive)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.Remove(encPath) })
+	assert.Equal(t, "db_exports.zip.framed", filepath.Base(encPath))
+
+	plainPath, err := d.decryptFramed(context.Background(), encPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.Remove(plainPath) })
+	assert.Equal(t, "db_exports.zip", filepath.Base(plainPath))
+	got, err := os.ReadFile(plainPath)
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+
+	// A tampered frame fails verification and leaves nothing behind.
+	sealed, err := os.ReadFile(encPath)
+	require.NoError(t, err)
+	sealed[len(sealed)-1] ^= 0xff
+	require.NoError(t, os.WriteFile(encPath, sealed, 0600))
+	require.NoError(t, os.Remove(plainPath))
+	_, err = d.decryptFramed(context.Background(), encPath)
+	require.ErrorIs(t, err, framed.ErrCorrupt)
+	assert.NoFileExists(t, plainPath)
+}
+
+func TestDumpster_DecryptFramed_NoKeyFile(t *testing.T) {
+	d, err := NewDumpster(&config.Config{}, storage.NewMockStorageIface(t), exec.NewMockExecIface(t))
+	require.NoError(t, err)
+	_, err = d.decryptFramed(context.Background(), "db_exports.zip.framed")
+	require.ErrorIs(t, err, ErrNoPrivateKey)
+
+	d.cfg.Encryption.GPG.Agent = true
+	_, err = d.decryptFramed(context.Background(), "db_exports.zip.framed")
+	require.ErrorContains(t, err, "encryption.gpg.agent")
+}
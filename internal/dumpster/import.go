@@ -0,0 +1,162 @@
+package dumpster
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hibare/stashly/internal/constants"
+	"github.com/hibare/stashly/internal/manifest"
+)
+
+var (
+	// ErrEncryptedImport is returned for encrypted files, which can't be checked before importing.
+	ErrEncryptedImport = errors.New("encrypted dumps can't be imported; decrypt them first")
+
+	// ErrBackupExists is returned when a backup already exists at the import's timestamp.
+	ErrBackupExists = errors.New("a backup already exists at this timestamp")
+)
+
+// ImportOptions holds options for importing an external dump.
+type ImportOptions struct {
+	// Database names the database of a single dump file; it defaults to the file name without extension.
+	Database string
+
+	// CreatedAt dates the imported backup; zero uses the file's modification time.
+	CreatedAt time.Time
+
+	// Labels are recorded in the backup's manifest.
+	Labels map[string]string
+}
+
+// sha256Hex returns the hex-encoded SHA-256 of the file at path.
+func sha256Hex(path string) (string, error) {
+	h := sha256.New()
+	if err := copyFile(h, path); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// stageImport places the dumps of the file at path in the backup location: a single dump file is copied
+// as <database><ext>, an archive is extracted. It returns the staged databases.
+func (d *Dumpster) stageImport(path string, opts ImportOptions) ([]string, error) {
+	ext := d.engine.Extension()
+	name := filepath.Base(path)
+
+	if strings.HasSuffix(name, ext) {
+		db := cmp.Or(opts.Database, strings.TrimSuffix(name, ext))
+		staged := filepath.Join(d.backupLocation, db+ext)
+		if err := copyToFile(staged, path); err != nil {
+			return nil, err
+		}
+		return []string{db}, nil
+	}
+
+	if !slices.ContainsFunc(archiveExtensions, func(e string) bool { return strings.HasSuffix(name, e) }) {
+		return nil, fmt.Errorf("unsupported file %s: expected a %s dump or a %s archive", name, ext, strings.Join(archiveExtensions, ", "))
+	}
+	dumps, err := extractArchive(path, "", d.backupLocation, ext)
+	if err != nil {
+		return nil, fmt.Errorf("error extracting %s: %w", name, err)
+	}
+	databases := make([]string, 0, len(dumps))
+	for db := range dumps {
+		databases = append(databases, db)
+	}
+	sort.Strings(databases)
+	return databases, nil
+}
+
+// copyToFile copies the file at src to dst.
+func copyToFile(dst, src string) error {
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if err := copyFile(out, src); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// ImportDump takes a dump or archive produced outside Stashly, checks its dumps, and stores it as a
+// regular backup with a manifest, so it is listed, restorable and subject to retention like any other.
+func (d *Dumpster) ImportDump(ctx context.Context, path string, opts ImportOptions) (*DumpResponse, error) {
+	start := time.Now()
+
+	if strings.HasSuffix(path, ".gpg") {
+		return nil, ErrEncryptedImport
+	}
+	if err := d.checkCompliance(); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt := opts.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = info.ModTime()
+	}
+	timestamp := createdAt.Format(constants.DefaultDateTimeLayout)
+	existing, err := d.FindDumps(ctx, DumpFilter{Prefix: timestamp})
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(existing, timestamp) {
+		return nil, fmt.Errorf("%w: %s", ErrBackupExists, timestamp)
+	}
+
+	checksum, err := sha256Hex(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.runPreChecks(); err != nil {
+		return nil, err
+	}
+	databases, err := d.stageImport(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(databases) == 0 {
+		return nil, fmt.Errorf("no %s dumps found in %s", d.engine.Extension(), filepath.Base(path))
+	}
+	for _, db := range databases {
+		if vErr := validatePlainDump(filepath.Join(d.backupLocation, db+d.engine.Extension())); vErr != nil {
+			return nil, fmt.Errorf("invalid dump of %s: %w", db, vErr)
+		}
+	}
+
+	slog.InfoContext(ctx, "Importing dump", "file", path, "databases", databases, "timestamp", timestamp)
+	compressor, err := d.resolveCompressor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ExportResult{TotalDatabases: len(databases), Databases: databases}
+	dumpResp := &DumpResponse{
+		TotalDatabases:    len(databases),
+		ExportedDatabases: len(databases),
+		DumpLocation:      d.backupLocation,
+		Databases:         databases,
+	}
+	return d.storeDump(ctx, start, resp, dumpResp, compressor, DumpOptions{
+		Labels:    opts.Labels,
+		CreatedAt: createdAt,
+		Import:    &manifest.Import{Source: filepath.Base(path), SHA256: checksum},
+	})
+}
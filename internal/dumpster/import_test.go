@@ -0,0 +1,101 @@
+package dumpster
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/manifest"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpster_ImportDump(t *testing.T) {
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+	d, err := NewDumpster(&config.Config{}, mockStore, mockExec)
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(d.backupLocation)
+	}()
+
+	src := filepath.Join(t.TempDir(), "legacy.sql")
+	require.NoError(t, os.WriteFile(src, []byte("CREATE TABLE t ();\n\n--\n-- PostgreSQL database dump complete\n--\n"), 0600))
+	createdAt := time.Date(2024, 3, 1, 2, 0, 0, 0, time.Local)
+
+	var uploaded *manifest.Manifest
+	mockExec.On("LookPath", mock.Anything).Return("/usr/bin/true", nil)
+	mockStore.On("ListPage", storage.ListOptions{Prefix: "20240301020000", Limit: listPageSize}).Return(storage.Page{}, nil)
+	mockStore.On("Name").Return("test-storage")
+	mockStore.On("Upload", "20240301020000", mock.Anything).Run(func(args mock.Arguments) {
+		if path := args.String(1); filepath.Base(path) == manifest.FileName {
+			m, rErr := manifest.Read(path)
+			require.NoError(t, rErr)
+			uploaded = m
+		}
+	}).Return("20240301020000/postgres-plain.zip", nil)
+
+	resp, err := d.ImportDump(context.Background(), src, ImportOptions{Database: "orders", CreatedAt: createdAt})
+	require.NoError(t, err)
+	assert.Equal(t, "20240301020000", resp.Timestamp)
+	assert.Equal(t, []string{"orders"}, resp.Databases)
+	srcInfo, err := os.Stat(src)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"orders": srcInfo.Size()}, resp.DatabaseSizes)
+
+	require.NotNil(t, uploaded)
+	assert.Equal(t, []string{"orders"}, uploaded.Databases)
+	require.NotNil(t, uploaded.Import)
+	assert.Equal(t, "legacy.sql", uploaded.Import.Source)
+	assert.Len(t, uploaded.Import.SHA256, 64)
+	assert.True(t, uploaded.CreatedAt.Equal(createdAt))
+}
+
+func TestDumpster_ImportDump_Rejects(t *testing.T) {
+	dir := t.TempDir()
+	truncated := filepath.Join(dir, "app.sql")
+	require.NoError(t, os.WriteFile(truncated, []byte("CREATE TABLE t ();\nCOPY t FROM stdin;\n"), 0600))
+	existing := filepath.Join(dir, "old.sql")
+	require.NoError(t, os.WriteFile(existing, []byte("--\n"), 0600))
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	require.NoError(t, os.Chtimes(existing, modTime, modTime))
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr error
+		wantMsg string
+	}{
+		{name: "encrypted", path: filepath.Join(dir, "app.zip.gpg"), wantErr: ErrEncryptedImport},
+		{name: "timestamp taken", path: existing, wantErr: ErrBackupExists},
+		{name: "truncated dump", path: truncated, wantMsg: "invalid dump of app"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := storage.NewMockStorageIface(t)
+			mockExec := exec.NewMockExecIface(t)
+			d, err := NewDumpster(&config.Config{}, mockStore, mockExec)
+			require.NoError(t, err)
+			defer func() {
+				_ = os.RemoveAll(d.backupLocation)
+			}()
+
+			mockStore.On("ListPage", mock.Anything).Return(storage.Page{Keys: []string{"20240101000000"}}, nil).Maybe()
+			mockStore.On("TrimPrefix", mock.Anything).Return([]string{"20240101000000"}).Maybe()
+			mockExec.On("LookPath", mock.Anything).Return("/usr/bin/true", nil).Maybe()
+
+			_, err = d.ImportDump(context.Background(), tt.path, ImportOptions{})
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.ErrorContains(t, err, tt.wantMsg)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package dumpster
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// IndexFileName is the name of the index kept at the root of the backup prefix with storage.index.
+const IndexFileName = "index.json"
+
+// IndexVersion is the current index format version.
+const IndexVersion = 1
+
+// BackupIndex summarizes every backup under the prefix it is stored at, for tools that list backups
+// without listing storage and downloading each manifest.
+type BackupIndex struct {
+	Version    int       `json:"version"`
+	InstanceID string    `json:"instance_id"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	// Backups holds an entry per backup, newest first.
+	Backups []IndexEntry `json:"backups"`
+}
+
+// IndexEntry summarizes one backup. Keys are relative to the index, i.e. <timestamp>/<file>. Only
+// Timestamp is set for backups that predate manifests.
+type IndexEntry struct {
+	Timestamp string    `json:"timestamp"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+
+	// Key is the archive; empty if it was split into Volumes.
+	Key     string   `json:"key,omitempty"`
+	Volumes []string `json:"volumes,omitempty"`
+
+	// Size is the size in bytes of the archive and SHA256 its hex-encoded checksum, as uploaded.
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+
+	Labels    map[string]string `json:"labels,omitempty"`
+	Encrypted bool              `json:"encrypted,omitempty"`
+	Snapshot  bool              `json:"snapshot,omitempty"`
+}
+
+// buildIndex summarizes the backups in storage, served from the local catalog when it is enabled.
+func (d *Dumpster) buildIndex(ctx context.Context) (*BackupIndex, error) {
+	backups, err := d.ListBackups(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &BackupIndex{
+		Version:    IndexVersion,
+		InstanceID: d.cfg.App.InstanceID,
+		UpdatedAt:  time.Now().UTC(),
+		Backups:    make([]IndexEntry, 0, len(backups)),
+	}
+	for _, b := range backups {
+		e := IndexEntry{Timestamp: b.Timestamp}
+		if m := b.Manifest; m != nil {
+			e.CreatedAt = m.CreatedAt
+			e.Size = m.Size
+			e.SHA256 = m.SHA256
+			e.Labels = m.Labels
+			e.Encrypted = m.Encrypted
+			e.Snapshot = m.Snapshot
+			if len(m.Volumes) == 0 {
+				e.Key = path.Join(b.Timestamp, m.Archive)
+			}
+			for _, v := range m.Volumes {
+				e.Volumes = append(e.Volumes, path.Join(b.Timestamp, v))
+			}
+		}
+		index.Backups = append(index.Backups, e)
+	}
+	// The timestamp layout sorts chronologically.
+	sort.Slice(index.Backups, func(i, j int) bool { return index.Backups[i].Timestamp > index.Backups[j].Timestamp })
+	return index, nil
+}
+
+// writeIndex rebuilds the index and uploads it in place of the previous one. It is uploaded whole,
+// so on object stores readers get either the old index or the new one.
+func (d *Dumpster) writeIndex(ctx context.Context) (string, error) {
+	index, err := d.buildIndex(ctx)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.MkdirTemp("", "stashly-index-")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	localPath := filepath.Join(tmp, IndexFileName)
+	if err := os.WriteFile(localPath, data, 0600); err != nil {
+		return "", err
+	}
+	// No timestamp puts it at the root of the prefix, next to the backups.
+	key, err := d.store.Upload(ctx, "", localPath)
+	if err != nil {
+		return "", err
+	}
+	slog.DebugContext(ctx, "Backup index updated", "key", key, "backups", len(index.Backups))
+	return key, nil
+}
+
+// UpdateIndex rewrites the index when storage.index is enabled. Call it after anything that adds or
+// removes backups. Failures are only logged: the index is rebuilt in full by the next update.
+func (d *Dumpster) UpdateIndex(ctx context.Context) {
+	if !d.cfg.Storage.Index {
+		return
+	}
+	if _, err := d.writeIndex(ctx); err != nil {
+		slog.WarnContext(ctx, "Failed to update backup index", "error", err)
+	}
+}
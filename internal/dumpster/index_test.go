@@ -0,0 +1,84 @@
+package dumpster
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/manifest"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpster_writeIndex(t *testing.T) {
+	cfg := &config.Config{App: config.AppConfig{InstanceID: "db-1"}}
+	mockStore := storage.NewMockStorageIface(t)
+	dumpster, err := NewDumpster(cfg, mockStore, exec.NewMockExecIface(t))
+	require.NoError(t, err)
+
+	// The previous index sits next to the backups and isn't one of them.
+	keys := []string{"20250101000000", IndexFileName, "20250102000000", "20250103000000"}
+	mockStore.On("List").Return(keys, nil).Once()
+	mockStore.On("TrimPrefix", keys).Return(keys).Once()
+
+	createdAt := time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)
+	manifests := map[string]*manifest.Manifest{
+		"20250103000000": {
+			Timestamp: "20250103000000", CreatedAt: createdAt, Archive: "postgres.zip.gpg", Size: 2048,
+			SHA256: "abc123", Encrypted: true, Labels: map[string]string{"env": "prod"},
+		},
+		"20250102000000": {
+			Timestamp: "20250102000000", Archive: "postgres.zip", Size: 4096, Snapshot: true,
+			Volumes: []string{"postgres.zip.001", "postgres.zip.002"},
+		},
+	}
+	for ts, m := range manifests {
+		key := "p/db-1/" + ts + "/" + manifest.FileName
+		mockStore.On("ListFiles", ts).Return([]string{key}, nil).Once()
+		mockStore.On("Download", key, mock.Anything).Run(func(args mock.Arguments) {
+			require.NoError(t, m.Write(args.String(1)))
+		}).Return(nil).Once()
+	}
+	mockStore.On("ListFiles", "20250101000000").Return([]string{"p/db-1/20250101000000/postgres.zip"}, nil).Once()
+
+	var index BackupIndex
+	mockStore.On("Upload", "", mock.Anything).Run(func(args mock.Arguments) {
+		localPath := args.String(1)
+		assert.Equal(t, IndexFileName, filepath.Base(localPath))
+		data, rErr := os.ReadFile(localPath)
+		require.NoError(t, rErr)
+		require.NoError(t, json.Unmarshal(data, &index))
+	}).Return("p/db-1/"+IndexFileName, nil).Once()
+
+	key, err := dumpster.writeIndex(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "p/db-1/"+IndexFileName, key)
+
+	assert.Equal(t, IndexVersion, index.Version)
+	assert.Equal(t, "db-1", index.InstanceID)
+	assert.Equal(t, []IndexEntry{
+		{
+			Timestamp: "20250103000000", CreatedAt: createdAt, Key: "20250103000000/postgres.zip.gpg", Size: 2048,
+			SHA256: "abc123", Encrypted: true, Labels: map[string]string{"env": "prod"},
+		},
+		{
+			Timestamp: "20250102000000", Size: 4096, Snapshot: true,
+			Volumes: []string{"20250102000000/postgres.zip.001", "20250102000000/postgres.zip.002"},
+		},
+		{Timestamp: "20250101000000"},
+	}, index.Backups)
+}
+
+func TestDumpster_UpdateIndex_Disabled(t *testing.T) {
+	// Nothing is listed or uploaded unless storage.index is set.
+	dumpster, err := NewDumpster(&config.Config{}, storage.NewMockStorageIface(t), exec.NewMockExecIface(t))
+	require.NoError(t, err)
+	dumpster.UpdateIndex(context.Background())
+}
@@ -0,0 +1,95 @@
+package dumpster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/hibare/stashly/internal/keyproof"
+)
+
+// ErrKeyUnusable is returned when the configured GPG key has no encryption key that is valid now,
+// e.g. because it expired or was revoked.
+var ErrKeyUnusable = errors.New("gpg key has no valid encryption key (expired or revoked)")
+
+// lifetimeEnd returns when a key created at created stops being valid under sig, or zero if never.
+func lifetimeEnd(created time.Time, sig *packet.Signature) time.Time {
+	var end time.Time
+	if sig.KeyLifetimeSecs != nil && *sig.KeyLifetimeSecs > 0 {
+		end = created.Add(time.Duration(*sig.KeyLifetimeSecs) * time.Second)
+	}
+	if sig.SigLifetimeSecs != nil && *sig.SigLifetimeSecs > 0 {
+		sigEnd := sig.CreationTime.Add(time.Duration(*sig.SigLifetimeSecs) * time.Second)
+		if end.IsZero() || sigEnd.Before(end) {
+			end = sigEnd
+		}
+	}
+	return end
+}
+
+// keyExpiry returns when the key backups would be encrypted to at now stops being usable: the earlier
+// of the primary key's and the encryption subkey's expiry, or zero if neither expires.
+func keyExpiry(publicKey string, now time.Time) (time.Time, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(publicKey))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read armored key ring: %w", err)
+	}
+
+	var expiry time.Time
+	for _, e := range entities {
+		key, ok := e.EncryptionKey(now)
+		if !ok {
+			return time.Time{}, fmt.Errorf("%w: %X", ErrKeyUnusable, e.PrimaryKey.Fingerprint)
+		}
+
+		ends := []time.Time{lifetimeEnd(key.PublicKey.CreationTime, key.SelfSignature)}
+		if sig, _ := e.PrimarySelfSignature(); sig != nil {
+			ends = append(ends, lifetimeEnd(e.PrimaryKey.CreationTime, sig))
+		}
+		for _, end := range ends {
+			if !end.IsZero() && (expiry.IsZero() || end.Before(expiry)) {
+				expiry = end
+			}
+		}
+	}
+	return expiry, nil
+}
+
+// EncryptionPublicKey fetches the configured GPG key from the key server and returns it armored.
+func (d *Dumpster) EncryptionPublicKey() (string, error) {
+	if _, err := d.gpg.FetchGPGPubKeyFromKeyServer(d.cfg.Encryption.GPG.KeyID, d.cfg.Encryption.GPG.KeyServer); err != nil {
+		return "", err
+	}
+	return d.gpg.ReadPublicKeyFromFile()
+}
+
+// CheckEncryptionKey fetches the configured GPG key and returns when it expires, or zero if it never
+// does. It fails with ErrKeyUnusable if the key can no longer be encrypted to, and with
+// keyproof.ErrNoProof if encryption.gpg.require-key-proof is set and the key's private key hasn't
+// been proven to be available.
+func (d *Dumpster) CheckEncryptionKey(ctx context.Context) (time.Time, error) {
+	publicKey, err := d.EncryptionPublicKey()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	expiry, err := keyExpiry(publicKey, time.Now())
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !expiry.IsZero() {
+		slog.DebugContext(ctx, "GPG key expiry", "key_id", d.cfg.Encryption.GPG.KeyID, "expires_at", expiry)
+	}
+
+	if d.cfg.Encryption.GPG.RequireKeyProof {
+		if err := keyproof.Verify(d.cfg.Encryption.GPG.KeyProofFile, publicKey); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return expiry, nil
+}
@@ -0,0 +1,46 @@
+package dumpster
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// armoredPublicKey generates a key valid for lifetime (0 never expires) and returns its armored public key.
+func armoredPublicKey(t *testing.T, lifetime time.Duration) (string, time.Time) {
+	t.Helper()
+	entity, err := openpgp.NewEntity("stashly", "", "stashly@example.com", &packet.Config{KeyLifetimeSecs: uint32(lifetime.Seconds())})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+	return buf.String(), entity.PrimaryKey.CreationTime
+}
+
+func TestKeyExpiry(t *testing.T) {
+	key, created := armoredPublicKey(t, 10*24*time.Hour)
+
+	expiry, err := keyExpiry(key, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, created.Add(10*24*time.Hour), expiry)
+
+	_, err = keyExpiry(key, created.Add(11*24*time.Hour))
+	require.ErrorIs(t, err, ErrKeyUnusable)
+}
+
+func TestKeyExpiry_NeverExpires(t *testing.T) {
+	key, _ := armoredPublicKey(t, 0)
+
+	expiry, err := keyExpiry(key, time.Now())
+	require.NoError(t, err)
+	assert.True(t, expiry.IsZero())
+}
@@ -0,0 +1,190 @@
+package dumpster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/hibare/stashly/internal/catalog"
+	"github.com/hibare/stashly/internal/manifest"
+)
+
+// BackupInfo describes a backup in storage. Manifest is nil for backups that predate manifests.
+type BackupInfo struct {
+	Timestamp string
+	Manifest  *manifest.Manifest
+}
+
+// ReadManifest downloads and parses the manifest of the backup at timestamp.
+// It returns manifest.ErrNotFound if the backup has no manifest.
+func (d *Dumpster) ReadManifest(ctx context.Context, timestamp string) (*manifest.Manifest, error) {
+	files, err := d.store.ListFiles(ctx, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	return d.downloadManifest(ctx, files)
+}
+
+// downloadManifest downloads and parses the manifest among a backup's files.
+func (d *Dumpster) downloadManifest(ctx context.Context, files []string) (*manifest.Manifest, error) {
+	for _, key := range files {
+		if path.Base(key) != manifest.FileName {
+			continue
+		}
+
+		tmp, err := os.MkdirTemp("", "stashly-manifest-")
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = os.RemoveAll(tmp)
+		}()
+
+		localPath := filepath.Join(tmp, manifest.FileName)
+		if err := d.store.Download(ctx, key, localPath); err != nil {
+			return nil, err
+		}
+		return manifest.Read(localPath)
+	}
+	return nil, manifest.ErrNotFound
+}
+
+// openSealedManifest downloads and decrypts the full manifest index points to, checking it against the
+// checksum in the index.
+func (d *Dumpster) openSealedManifest(ctx context.Context, files []string, index *manifest.Manifest) (*manifest.Manifest, error) {
+	var key string
+	for _, f := range files {
+		if path.Base(f) == index.Sealed.File {
+			key = f
+		}
+	}
+	if key == "" {
+		return nil, fmt.Errorf("%w: %s", manifest.ErrNotFound, index.Sealed.File)
+	}
+
+	tmp, err := os.MkdirTemp("", "stashly-manifest-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	localPath := filepath.Join(tmp, index.Sealed.File)
+	if err := d.store.Download(ctx, key, localPath); err != nil {
+		return nil, err
+	}
+	plainPath, err := d.decryptArchive(ctx, localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = os.Remove(plainPath)
+	}()
+
+	checksum, err := sha256Hex(plainPath)
+	if err != nil {
+		return nil, err
+	}
+	if checksum != index.Sealed.SHA256 {
+		return nil, fmt.Errorf("%w: %s", manifest.ErrChecksumMismatch, key)
+	}
+	return manifest.Read(plainPath)
+}
+
+// ListBackups lists backups newest first, keeping only those whose manifest carries every label in filter.
+// Backups without a manifest are only listed when no filter is given. With the catalog enabled, backups are
+// served from the local catalog instead of storage.
+func (d *Dumpster) ListBackups(ctx context.Context, filter map[string]string) ([]BackupInfo, error) {
+	var all []BackupInfo
+	var err error
+	if d.cfg.Catalog.Enabled {
+		all, err = d.catalogBackups(ctx)
+	} else {
+		all, err = d.storageBackups(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []BackupInfo{}
+	for _, b := range all {
+		if b.Manifest == nil {
+			slog.DebugContext(ctx, "Backup has no manifest", "timestamp", b.Timestamp)
+			if len(filter) > 0 {
+				continue
+			}
+		} else if !b.Manifest.MatchLabels(filter) {
+			continue
+		}
+		backups = append(backups, b)
+	}
+	return backups, nil
+}
+
+// storageBackups lists every backup in storage along with its manifest. Manifests don't change once
+// uploaded, so with the catalog disabled the catalog file still serves as a cache of them: only the
+// manifests of backups it doesn't know yet are downloaded. The listing itself is always live.
+func (d *Dumpster) storageBackups(ctx context.Context) ([]BackupInfo, error) {
+	timestamps, err := d.ListDumps(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := d.loadManifestCache(ctx)
+	known := map[string]*manifest.Manifest{}
+	if cache != nil {
+		known = cache.Backups
+	}
+
+	backups := []BackupInfo{}
+	fetched := 0
+	for _, ts := range timestamps {
+		// Backups without a manifest are read again: theirs may not have been uploaded yet.
+		m := known[ts]
+		if m == nil {
+			var mErr error
+			m, mErr = d.ReadManifest(ctx, ts)
+			if mErr != nil && !errors.Is(mErr, manifest.ErrNotFound) {
+				return nil, mErr
+			}
+			if m != nil {
+				fetched++
+			}
+		}
+		backups = append(backups, BackupInfo{Timestamp: ts, Manifest: m})
+	}
+
+	if cache != nil && (fetched > 0 || len(cache.Backups) != len(backups)) {
+		cache.Backups = map[string]*manifest.Manifest{}
+		for _, b := range backups {
+			if b.Manifest != nil {
+				cache.Put(b.Timestamp, b.Manifest)
+			}
+		}
+		// The file isn't kept up to date as a catalog would be; enabling the catalog reconciles it first.
+		cache.Invalidate()
+		if sErr := cache.Save(); sErr != nil {
+			slog.DebugContext(ctx, "Failed to save manifest cache", "path", d.cfg.Catalog.Path, "error", sErr)
+		}
+	}
+	return backups, nil
+}
+
+// loadManifestCache loads the catalog file to look manifests up in, or returns nil if there is none
+// to use.
+func (d *Dumpster) loadManifestCache(ctx context.Context) *catalog.Catalog {
+	if d.cfg.Catalog.Path == "" {
+		return nil
+	}
+	c, err := d.loadCatalog()
+	if err != nil {
+		slog.DebugContext(ctx, "Failed to load manifest cache", "path", d.cfg.Catalog.Path, "error", err)
+		return nil
+	}
+	return c
+}
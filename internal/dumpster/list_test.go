@@ -0,0 +1,146 @@
+package dumpster
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/manifest"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpster_ListBackups_LabelFilter(t *testing.T) {
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+	dumpster, err := NewDumpster(&config.Config{}, mockStore, mockExec)
+	require.NoError(t, err)
+
+	timestamps := []string{"20250102000000", "20250101000000", "20241231000000"}
+	mockStore.On("List").Return(timestamps, nil)
+	mockStore.On("TrimPrefix", timestamps).Return(timestamps)
+
+	labels := map[string]map[string]string{
+		"20250102000000": {"release": "v2.4"},
+		"20250101000000": {"release": "v2.3"},
+	}
+	for ts, l := range labels {
+		key := "p/i/" + ts + "/manifest.json"
+		mockStore.On("ListFiles", ts).Return([]string{"p/i/" + ts + "/db_exports.zip", key}, nil)
+		m := &manifest.Manifest{Timestamp: ts, Labels: l}
+		mockStore.On("Download", key, mock.Anything).Run(func(args mock.Arguments) {
+			require.NoError(t, m.Write(args.String(1)))
+		}).Return(nil)
+	}
+	// Predates manifests.
+	mockStore.On("ListFiles", "20241231000000").Return([]string{"p/i/20241231000000/db_exports.zip"}, nil)
+
+	backups, err := dumpster.ListBackups(context.Background(), map[string]string{"release": "v2.3"})
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.Equal(t, "20250101000000", backups[0].Timestamp)
+
+	backups, err = dumpster.ListBackups(context.Background(), nil)
+	require.NoError(t, err)
+	require.Len(t, backups, 3)
+	assert.Nil(t, backups[2].Manifest)
+}
+
+func TestDumpster_ListBackups_ManifestCache(t *testing.T) {
+	// With the catalog disabled, manifests already downloaded are taken from the catalog file.
+	cfg := &config.Config{
+		App:     config.AppConfig{InstanceID: "db-1"},
+		Catalog: config.CatalogConfig{Path: filepath.Join(t.TempDir(), "catalog.json")},
+	}
+	mockStore := storage.NewMockStorageIface(t)
+	dumpster, err := NewDumpster(cfg, mockStore, exec.NewMockExecIface(t))
+	require.NoError(t, err)
+
+	timestamps := []string{"20250102000000", "20250101000000"}
+	mockStore.On("List").Return(timestamps, nil).Twice()
+	mockStore.On("TrimPrefix", timestamps).Return(timestamps).Twice()
+
+	key := "p/i/20250102000000/manifest.json"
+	mockStore.On("ListFiles", "20250102000000").Return([]string{key}, nil).Once()
+	mockStore.On("Download", key, mock.Anything).Run(func(args mock.Arguments) {
+		require.NoError(t, (&manifest.Manifest{Timestamp: "20250102000000"}).Write(args.String(1)))
+	}).Return(nil).Once()
+	// A backup without a manifest is checked again each time, as its manifest may still come.
+	mockStore.On("ListFiles", "20250101000000").Return([]string{"p/i/20250101000000/db_exports.zip"}, nil).Twice()
+
+	for range 2 {
+		backups, lErr := dumpster.ListBackups(context.Background(), nil)
+		require.NoError(t, lErr)
+		require.Len(t, backups, 2)
+		require.NotNil(t, backups[0].Manifest)
+		assert.Equal(t, "20250102000000", backups[0].Manifest.Timestamp)
+		assert.Nil(t, backups[1].Manifest)
+	}
+
+	c, err := dumpster.loadCatalog()
+	require.NoError(t, err)
+	assert.True(t, c.Stale(time.Hour), "the cache must not pass for a reconciled catalog")
+}
+
+func TestDumpster_PurgeDumps_Snapshots(t *testing.T) {
+	cfg := &config.Config{Backup: config.BackupConfig{RetentionCount: 1, SnapshotTTL: 24 * time.Hour}}
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+	dumpster, err := NewDumpster(cfg, mockStore, mockExec)
+	require.NoError(t, err)
+
+	timestamps := []string{"20250104000000", "20250103000000", "20250102000000", "20250101000000"}
+	mockStore.On("List").Return(timestamps, nil)
+	mockStore.On("TrimPrefix", timestamps).Return(timestamps)
+
+	manifests := map[string]*manifest.Manifest{
+		"20250104000000": {Snapshot: true, CreatedAt: time.Now()},                      // fresh snapshot, kept
+		"20250103000000": {},                                                           // newest regular, kept
+		"20250102000000": {Snapshot: true, CreatedAt: time.Now().Add(-48 * time.Hour)}, // expired snapshot
+		"20250101000000": {},                                                           // beyond retention
+	}
+	for ts, m := range manifests {
+		key := "p/i/" + ts + "/manifest.json"
+		mockStore.On("ListFiles", ts).Return([]string{key}, nil)
+		mockStore.On("Download", key, mock.Anything).Run(func(args mock.Arguments) {
+			require.NoError(t, m.Write(args.String(1)))
+		}).Return(nil)
+	}
+
+	mockStore.On("Delete", "20250102000000").Return(nil).Once()
+	mockStore.On("Delete", "20250101000000").Return(nil).Once()
+
+	_, err = dumpster.PurgeDumps(context.Background(), PurgeOptions{})
+	require.NoError(t, err)
+}
+
+func TestDumpster_PurgeDumps_RetentionFloor(t *testing.T) {
+	cfg := &config.Config{Backup: config.BackupConfig{RetentionCount: 5, RetentionMinCount: 2, SnapshotTTL: 24 * time.Hour}}
+	mockStore := storage.NewMockStorageIface(t)
+	dumpster, err := NewDumpster(cfg, mockStore, exec.NewMockExecIface(t))
+	require.NoError(t, err)
+
+	// Only snapshots, all expired after a month without backups.
+	timestamps := []string{"20250103000000", "20250102000000", "20250101000000"}
+	mockStore.On("List").Return(timestamps, nil)
+	mockStore.On("TrimPrefix", timestamps).Return(timestamps)
+	for _, ts := range timestamps {
+		key := "p/i/" + ts + "/manifest.json"
+		m := &manifest.Manifest{Snapshot: true, CreatedAt: time.Now().Add(-30 * 24 * time.Hour)}
+		mockStore.On("ListFiles", ts).Return([]string{key}, nil)
+		mockStore.On("Download", key, mock.Anything).Run(func(args mock.Arguments) {
+			require.NoError(t, m.Write(args.String(1)))
+		}).Return(nil)
+	}
+
+	mockStore.On("Delete", "20250101000000").Return(nil).Once()
+
+	deleted, err := dumpster.PurgeDumps(context.Background(), PurgeOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20250101000000"}, deleted)
+}
@@ -0,0 +1,49 @@
+package dumpster
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Objectives are the recovery point and recovery time the backups currently achieve.
+type Objectives struct {
+	// LatestBackup is the timestamp of the newest backup; empty if there is none.
+	LatestBackup string
+
+	// RPO is the age of the newest backup, the most data a restore would lose; 0 if there is none.
+	RPO time.Duration
+
+	// RTO is how long the last restore drill that passed took; 0 if none has.
+	RTO time.Duration
+
+	// DrilledAt is when that drill started.
+	DrilledAt time.Time
+}
+
+// Objectives measures the recovery point from the newest backup in storage and the recovery time from
+// the restore drills recorded in the catalog.
+func (d *Dumpster) Objectives(ctx context.Context) (*Objectives, error) {
+	o := &Objectives{}
+	timestamp, err := d.LatestDump(ctx)
+	switch {
+	case errors.Is(err, ErrNoDumps):
+	case err != nil:
+		return nil, err
+	default:
+		taken, pErr := ParseTimestamp(timestamp)
+		if pErr != nil {
+			return nil, pErr
+		}
+		o.LatestBackup, o.RPO = timestamp, time.Since(taken)
+	}
+
+	c, err := d.loadCatalog()
+	if err != nil {
+		return nil, err
+	}
+	if drill, ok := c.LastPassedDrill(); ok {
+		o.RTO, o.DrilledAt = drill.Duration, drill.StartedAt
+	}
+	return o, nil
+}
@@ -0,0 +1,39 @@
+package dumpster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hibare/stashly/internal/catalog"
+	"github.com/hibare/stashly/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpster_Objectives(t *testing.T) {
+	d, mockStore, _ := newDrillDumpster(t)
+
+	// Nothing to measure yet.
+	mockStore.On("List").Return([]string{}, nil).Once()
+	o, err := d.Objectives(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &Objectives{}, o)
+
+	latest := time.Now().Add(-3 * time.Hour).Format(constants.DefaultDateTimeLayout)
+	mockStore.On("List").Return([]string{latest}, nil)
+	mockStore.On("TrimPrefix", []string{latest}).Return([]string{latest})
+	c, err := d.loadCatalog()
+	require.NoError(t, err)
+	drilled := time.Now().Add(-time.Hour)
+	c.RecordDrill(catalog.Drill{StartedAt: drilled, Duration: 20 * time.Minute, Passed: true})
+	c.RecordDrill(catalog.Drill{Duration: time.Minute, Error: "no backups found"})
+	require.NoError(t, c.Save())
+
+	o, err = d.Objectives(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, latest, o.LatestBackup)
+	assert.InDelta(t, 3*time.Hour, o.RPO, float64(time.Minute))
+	assert.Equal(t, 20*time.Minute, o.RTO)
+	assert.True(t, drilled.Equal(o.DrilledAt))
+}
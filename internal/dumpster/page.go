@@ -0,0 +1,112 @@
+package dumpster
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/hibare/GoCommon/v2/pkg/datetime"
+	"github.com/hibare/stashly/internal/constants"
+	"github.com/hibare/stashly/internal/storage"
+)
+
+// listPageSize is how many backups are fetched per storage call while walking dumps.
+const listPageSize = 1000
+
+// errStopWalk stops WalkDumps early without reporting an error.
+var errStopWalk = errors.New("stop walk")
+
+// DumpFilter narrows the backups visited by WalkDumps. Zero fields don't filter.
+type DumpFilter struct {
+	// Prefix keeps backups whose timestamp starts with it, e.g. "202401" for January 2024.
+	Prefix string
+
+	// From keeps backups taken at or after it.
+	From time.Time
+
+	// To keeps backups taken before it.
+	To time.Time
+}
+
+// match reports whether the backup at timestamp passes the date range.
+func (d *Dumpster) match(ctx context.Context, filter DumpFilter, timestamp string) bool {
+	if filter.From.IsZero() && filter.To.IsZero() {
+		return true
+	}
+	taken, err := ParseTimestamp(timestamp)
+	if err != nil {
+		slog.WarnContext(ctx, "Skipping backup with unparsable timestamp", "timestamp", timestamp, "error", err)
+		return false
+	}
+	if !filter.From.IsZero() && taken.Before(filter.From) {
+		return false
+	}
+	return filter.To.IsZero() || taken.Before(filter.To)
+}
+
+// WalkDumps calls fn with each page of backup timestamps matching filter, oldest first. Only one page
+// is held in memory at a time.
+func (d *Dumpster) WalkDumps(ctx context.Context, filter DumpFilter, fn func(timestamps []string) error) error {
+	opts := storage.ListOptions{Prefix: filter.Prefix, Limit: listPageSize}
+	// Keys are local times in a layout that sorts chronologically, so storage can skip older backups.
+	if !filter.From.IsZero() {
+		opts.StartAfter = filter.From.In(time.Local).Add(-time.Second).Format(constants.DefaultDateTimeLayout)
+	}
+
+	for {
+		page, err := d.store.ListPage(ctx, opts)
+		if err != nil {
+			return err
+		}
+
+		timestamps := []string{}
+		if len(page.Keys) > 0 {
+			for _, ts := range d.store.TrimPrefix(page.Keys) {
+				if !isTimestamp(ts) {
+					slog.DebugContext(ctx, "Skipping object that isn't a backup", "key", ts)
+					continue
+				}
+				if d.match(ctx, filter, ts) {
+					timestamps = append(timestamps, ts)
+				}
+			}
+		}
+		if len(timestamps) > 0 {
+			if err := fn(timestamps); err != nil {
+				if errors.Is(err, errStopWalk) {
+					return nil
+				}
+				return err
+			}
+		}
+
+		if page.NextToken == "" {
+			return nil
+		}
+		opts.Token = page.NextToken
+	}
+}
+
+// FindDumps returns the timestamps of the backups matching filter, newest first.
+func (d *Dumpster) FindDumps(ctx context.Context, filter DumpFilter) ([]string, error) {
+	found := []string{}
+	err := d.WalkDumps(ctx, filter, func(timestamps []string) error {
+		found = append(found, timestamps...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return datetime.SortDateTimes(found), nil
+}
+
+// HasDumps reports whether any backup matches filter, stopping at the first page that has one.
+func (d *Dumpster) HasDumps(ctx context.Context, filter DumpFilter) (bool, error) {
+	found := false
+	err := d.WalkDumps(ctx, filter, func([]string) error {
+		found = true
+		return errStopWalk
+	})
+	return found, err
+}
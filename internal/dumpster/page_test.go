@@ -0,0 +1,76 @@
+package dumpster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpster_WalkDumps_Pages(t *testing.T) {
+	mockStore := storage.NewMockStorageIface(t)
+	cfg := &config.Config{Backup: config.BackupConfig{DateTimeLayout: "20060102150405"}}
+	d, err := NewDumpster(cfg, mockStore, exec.NewMockExecIface(t))
+	require.NoError(t, err)
+
+	first := []string{"20240101000000", "20240110000000"}
+	second := []string{"20240120000000", "20240201000000"}
+	mockStore.On("ListPage", storage.ListOptions{StartAfter: "20240104235959", Limit: listPageSize}).
+		Return(storage.Page{Keys: first, NextToken: "next"}, nil).Once()
+	mockStore.On("ListPage", storage.ListOptions{StartAfter: "20240104235959", Limit: listPageSize, Token: "next"}).
+		Return(storage.Page{Keys: second}, nil).Once()
+	mockStore.On("TrimPrefix", first).Return(first)
+	mockStore.On("TrimPrefix", second).Return(second)
+
+	filter := DumpFilter{
+		From: time.Date(2024, 1, 5, 0, 0, 0, 0, time.Local),
+		To:   time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local),
+	}
+	var pages [][]string
+	err = d.WalkDumps(context.Background(), filter, func(timestamps []string) error {
+		pages = append(pages, timestamps)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"20240110000000"}, {"20240120000000"}}, pages)
+}
+
+func TestDumpster_FindDumps_LocalTime(t *testing.T) {
+	// Backups are named after local time. West of UTC, one taken early on the first day matches.
+	original := time.Local
+	time.Local = time.FixedZone("TEST", -5*60*60)
+	t.Cleanup(func() { time.Local = original })
+
+	mockStore := storage.NewMockStorageIface(t)
+	d, err := NewDumpster(&config.Config{}, mockStore, exec.NewMockExecIface(t))
+	require.NoError(t, err)
+
+	keys := []string{"20240105010000", "20240106010000"}
+	mockStore.On("ListPage", storage.ListOptions{StartAfter: "20240104235959", Limit: listPageSize}).
+		Return(storage.Page{Keys: keys}, nil).Once()
+	mockStore.On("TrimPrefix", keys).Return(keys)
+
+	found, err := d.FindDumps(context.Background(), DumpFilter{From: time.Date(2024, 1, 5, 0, 0, 0, 0, time.Local)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20240106010000", "20240105010000"}, found)
+}
+
+func TestDumpster_HasDumps_StopsEarly(t *testing.T) {
+	mockStore := storage.NewMockStorageIface(t)
+	d, err := NewDumpster(&config.Config{}, mockStore, exec.NewMockExecIface(t))
+	require.NoError(t, err)
+
+	keys := []string{"20240101000000"}
+	mockStore.On("ListPage", storage.ListOptions{Limit: listPageSize}).
+		Return(storage.Page{Keys: keys, NextToken: "next"}, nil).Once()
+	mockStore.On("TrimPrefix", keys).Return(keys)
+
+	found, err := d.HasDumps(context.Background(), DumpFilter{})
+	require.NoError(t, err)
+	assert.True(t, found)
+}
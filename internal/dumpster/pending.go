@@ -0,0 +1,129 @@
+package dumpster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hibare/stashly/internal/catalog"
+	"github.com/hibare/stashly/internal/manifest"
+	"github.com/hibare/stashly/internal/storage"
+)
+
+// pendingFile records, in the backup location, the backup being uploaded. It is removed once the
+// backup's manifest is stored, so finding it means the last run died mid-upload.
+const pendingFile = "pending.json"
+
+// pendingUpload is a backup whose archive is ready but not yet stored.
+type pendingUpload struct {
+	// File is the archive to upload.
+	File string `json:"file"`
+
+	// Manifest is the backup's manifest, uploaded after the archive.
+	Manifest *manifest.Manifest `json:"manifest"`
+}
+
+func (d *Dumpster) pendingPath() string {
+	return filepath.Join(d.backupLocation, pendingFile)
+}
+
+func (d *Dumpster) savePending(p *pendingUpload) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(d.pendingPath(), data, 0o600)
+}
+
+// loadPending returns the backup an earlier run left mid-upload, or nil if there is none or its
+// archive is gone.
+func (d *Dumpster) loadPending() *pendingUpload {
+	data, err := os.ReadFile(d.pendingPath())
+	if err != nil {
+		return nil
+	}
+	var p pendingUpload
+	if err := json.Unmarshal(data, &p); err != nil || p.Manifest == nil {
+		return nil
+	}
+	if _, err := os.Stat(p.File); err != nil {
+		return nil
+	}
+	return &p
+}
+
+// clearPending forgets the pending backup.
+func (d *Dumpster) clearPending(ctx context.Context) {
+	if err := os.Remove(d.pendingPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.WarnContext(ctx, "Failed to remove pending upload record", "file", d.pendingPath(), "error", err)
+	}
+}
+
+// uploadBackup uploads a backup's archive, then its manifest, recording it as pending in between so a
+// run that dies part way can finish it. Backends that checkpoint uploads, like S3 multipart uploads,
+// then only send what is missing.
+func (d *Dumpster) uploadBackup(ctx context.Context, p *pendingUpload) (string, error) {
+	if err := d.savePending(p); err != nil {
+		slog.WarnContext(ctx, "Failed to record pending upload", "file", d.pendingPath(), "error", err)
+	}
+
+	slog.InfoContext(ctx, "Uploading backup", "file", p.File, "storage", d.store.Name())
+	key, err := d.uploadSealed(ctx, p.Manifest.Timestamp, p.File)
+	if err != nil {
+		return "", err
+	}
+	if _, err := d.storeManifest(ctx, p.Manifest); err != nil {
+		return "", fmt.Errorf("error uploading manifest: %w", err)
+	}
+	d.clearPending(ctx)
+	return key, nil
+}
+
+// storeManifest uploads a backup's manifest and, unless the backup failed over, adds it to the catalog.
+func (d *Dumpster) storeManifest(ctx context.Context, m *manifest.Manifest) (*manifest.Manifest, error) {
+	stored, err := d.uploadManifest(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	// The catalog mirrors the primary; a backup that failed over isn't there.
+	if storage.FailedOverTo(d.store, m.Timestamp) == "" {
+		d.updateCatalog(ctx, func(c *catalog.Catalog) { c.Put(m.Timestamp, stored) })
+	}
+	return stored, nil
+}
+
+// resumePending finishes uploading the backup an earlier run left mid-upload and returns it in place of
+// a new one. It returns nil if there is nothing to resume.
+func (d *Dumpster) resumePending(ctx context.Context, start time.Time) (*DumpResponse, error) {
+	p := d.loadPending()
+	if p == nil {
+		return nil, nil //nolint:nilnil // reason: nothing to resume isn't an error
+	}
+
+	m := p.Manifest
+	slog.InfoContext(ctx, "Resuming interrupted backup upload", "timestamp", m.Timestamp, "file", p.File)
+	key, err := d.uploadBackup(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Backup uploaded", "location", key)
+	return &DumpResponse{
+		TotalDatabases:    len(m.Databases),
+		ExportedDatabases: len(m.Databases),
+		DumpLocation:      d.backupLocation,
+		ArchiveLocation:   p.File,
+		StorageKey:        key,
+		Timestamp:         m.Timestamp,
+		Databases:         m.Databases,
+		Size:              m.Size,
+		Duration:          time.Since(start),
+		Compression:       m.Compression,
+		FailedOver:        storage.FailedOverTo(d.store, m.Timestamp),
+	}, nil
+}
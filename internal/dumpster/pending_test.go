@@ -0,0 +1,50 @@
+package dumpster
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/manifest"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpster_CreateDump_ResumesPendingUpload(t *testing.T) {
+	mockStore := storage.NewMockStorageIface(t)
+	d, err := NewDumpster(&config.Config{}, mockStore, exec.NewMockExecIface(t))
+	require.NoError(t, err)
+	d.backupLocation = t.TempDir()
+
+	archive := filepath.Join(d.backupLocation, "20240101000000.zip")
+	require.NoError(t, os.WriteFile(archive, []byte("archive"), 0o600))
+	p := &pendingUpload{File: archive, Manifest: &manifest.Manifest{Timestamp: "20240101000000", Databases: []string{"db1"}, Size: 7}}
+
+	mockStore.On("Name").Return("test-storage")
+	mockStore.On("Upload", "20240101000000", archive).Return("", errors.New("connection reset")).Once()
+	_, err = d.uploadBackup(context.Background(), p)
+	require.Error(t, err)
+	assert.FileExists(t, d.pendingPath())
+
+	// The next run finishes the interrupted backup instead of taking a new one.
+	mockStore.On("Upload", "20240101000000", archive).Return("prefix/20240101000000/20240101000000.zip", nil).Once()
+	mockStore.On("Upload", "20240101000000", filepath.Join(d.backupLocation, manifest.FileName)).Return("prefix/20240101000000/manifest.json", nil).Once()
+
+	resp, err := d.CreateDump(context.Background(), DumpOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "20240101000000", resp.Timestamp)
+	assert.Equal(t, "prefix/20240101000000/20240101000000.zip", resp.StorageKey)
+	assert.Equal(t, []string{"db1"}, resp.Databases)
+	assert.NoFileExists(t, d.pendingPath())
+}
+
+func TestDumpster_loadPending_ArchiveGone(t *testing.T) {
+	d := &Dumpster{backupLocation: t.TempDir()}
+	require.NoError(t, d.savePending(&pendingUpload{File: filepath.Join(d.backupLocation, "gone.zip"), Manifest: &manifest.Manifest{}}))
+	assert.Nil(t, d.loadPending())
+}
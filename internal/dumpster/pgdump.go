@@ -1,96 +1,165 @@
-// Package dumpster provides functionality to create, list, and purge PostgreSQL database dumps.
 package dumpster
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	osExec "os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/hibare/GoCommon/v2/pkg/crypto/gpg"
-	"github.com/hibare/GoCommon/v2/pkg/datetime"
-	"github.com/hibare/GoCommon/v2/pkg/file"
 	"github.com/hibare/GoCommon/v2/pkg/os/exec"
 	"github.com/hibare/stashly/internal/config"
-	"github.com/hibare/stashly/internal/constants"
-	"github.com/hibare/stashly/internal/storage"
+	"github.com/hibare/stashly/internal/manifest"
+	"github.com/hibare/stashly/internal/metrics"
 )
 
-// DumpsterIface defines the interface for dumpster operations.
-// revive:disable-next-line exported
-type DumpsterIface interface {
-	Dump(ctx context.Context) (int, string, error)
-	ListDumps(ctx context.Context) ([]string, error)
-	PurgeDumps(ctx context.Context) error
+func init() {
+	Register("postgres", NewPostgres)
 }
 
-// Dumpster handles PostgreSQL database dumps and interactions with storage backends.
-type Dumpster struct {
-	store          storage.StorageIface
-	cfg            *config.Config
-	exec           exec.ExecIface
-	backupLocation string
-	gpg            gpg.GPGIface
+// ErrListTimeout is returned when listing the databases doesn't finish within postgres.list-timeout.
+var ErrListTimeout = errors.New("timed out listing databases")
+
+// Postgres dumps PostgreSQL databases with pg_dump and restores them with psql.
+type Postgres struct {
+	cfg  *config.Config
+	exec exec.ExecIface
+
+	// host and port are the server to connect to: postgres.host/port, or the primary of postgres.nodes.
+	host string
+	port string
+}
+
+// Name returns the engine name.
+func (p *Postgres) Name() string {
+	return "postgres"
+}
+
+// rolesFile holds the cluster's roles when owners are preserved. Its extension keeps it
+// from being mistaken for a database dump.
+const rolesFile = "roles.globals"
+
+// Binaries returns the PostgreSQL client tools the engine needs.
+func (p *Postgres) Binaries() []string {
+	if p.cfg.Postgres.PreserveOwners {
+		return []string{"psql", "pg_dump", "pg_dumpall"}
+	}
+	return []string{"psql", "pg_dump"}
 }
 
-func (d *Dumpster) getEnvVars() []string {
+// Extension returns the extension of plain-format SQL dumps.
+func (p *Postgres) Extension() string {
+	return ".sql"
+}
+
+// Format returns the pg_dump output format: plain SQL scripts restored with psql.
+func (p *Postgres) Format() string {
+	return "plain"
+}
+
+// connEnvVars returns the environment connecting the client tools to host and port.
+func (p *Postgres) connEnvVars(host, port string) []string {
 	return []string{
-		fmt.Sprintf("PGUSER=%s", d.cfg.Postgres.User),
-		fmt.Sprintf("PGPASSWORD=%s", d.cfg.Postgres.Password),
-		fmt.Sprintf("PGHOST=%s", d.cfg.Postgres.Host),
-		fmt.Sprintf("PGPORT=%s", d.cfg.Postgres.Port),
+		fmt.Sprintf("PGUSER=%s", p.cfg.Postgres.User),
+		fmt.Sprintf("PGPASSWORD=%s", p.cfg.Postgres.Password),
+		fmt.Sprintf("PGHOST=%s", host),
+		fmt.Sprintf("PGPORT=%s", port),
 	}
 }
 
-func (d *Dumpster) runPreChecks() error {
-	// Remove old backup location if exists
-	if err := os.RemoveAll(d.backupLocation); err != nil {
-		return err
+func (p *Postgres) getEnvVars() []string {
+	return p.connEnvVars(p.host, p.port)
+}
+
+// dumpEnvVars returns the environment for pg_dump, pointing it at the direct host/port if configured.
+func (p *Postgres) dumpEnvVars() []string {
+	host, port := p.host, p.port
+	if p.cfg.Postgres.DumpHost != "" {
+		host = p.cfg.Postgres.DumpHost
 	}
+	if p.cfg.Postgres.DumpPort != "" {
+		port = p.cfg.Postgres.DumpPort
+	}
+	return p.connEnvVars(host, port)
+}
 
-	// Create backup location
-	if err := os.MkdirAll(d.backupLocation, 0750); err != nil {
-		return err
+// poolerReason inspects the output of two separate "SELECT inet_server_port(), pg_backend_pid();"
+// transactions and returns why the connection looks pooled, or "" if it doesn't.
+func poolerReason(output, configuredPort string) string {
+	lines := strings.Fields(output)
+	if len(lines) != 2 {
+		return ""
 	}
 
-	// Check if required binaries are available
-	binaries := []string{"psql", "pg_dump"}
+	port, pid1, _ := strings.Cut(lines[0], "|")
+	_, pid2, _ := strings.Cut(lines[1], "|")
 
-	for _, bin := range binaries {
-		if _, err := d.exec.LookPath(bin); err != nil {
-			return fmt.Errorf("%s not found in PATH: %w", bin, err)
-		}
+	switch {
+	case pid1 != pid2:
+		return "consecutive transactions ran on different server backends (transaction pooling)"
+	case port != "" && configuredPort != "" && port != configuredPort:
+		return fmt.Sprintf("server reports port %s but port %s is configured (connection is proxied)", port, configuredPort)
 	}
-	return nil
+	return ""
 }
 
-type exportResponse struct {
-	totalDatabases    int
-	exportedDatabases int
-	exportLocation    string
+// checkPooler warns when the configured connection appears to go through a pooler such as PgBouncer,
+// which breaks pg_dump in transaction pooling mode. It is skipped when a direct dump host is configured.
+func (p *Postgres) checkPooler(ctx context.Context, envVars []string, dir string) {
+	if p.cfg.Postgres.DumpHost != "" || p.cfg.Postgres.DumpPort != "" {
+		return
+	}
+
+	query := "SELECT inet_server_port(), pg_backend_pid();"
+	out, err := p.exec.Command(ctx, "psql", "-At", "--dbname=postgres", "-c", query, "-c", query).
+		WithEnv(envVars).
+		WithDir(dir).
+		Output()
+	if err != nil {
+		slog.DebugContext(ctx, "Connection pooler check failed", "error", err)
+		return
+	}
+
+	if reason := poolerReason(string(out), p.port); reason != "" {
+		slog.WarnContext(ctx, "Connection appears to go through a pooler such as PgBouncer; pg_dump may fail or produce inconsistent dumps. Set postgres.dump-host/dump-port to dump over a direct connection",
+			"reason", reason)
+	}
 }
 
-func (d *Dumpster) export(ctx context.Context) (*exportResponse, error) {
-	totalDatabases := 0
-	exportedDatabases := 0
+// listDatabases returns the non-template databases on the server, excluding maintenance databases.
+func (p *Postgres) listDatabases(ctx context.Context, envVars []string, dir string) ([]string, error) {
 	databases := []string{}
 
-	envVars := d.getEnvVars()
-
 	// Get list of non-template databases using psql machine output
 	query := "SELECT datname FROM pg_database WHERE datistemplate = false AND datname NOT IN ('postgres','defaultdb');"
 
-	output, err := d.exec.Command(ctx, "psql", "-At", "-c", query).
+	// The listing is a catalog query that returns at once on a healthy server; unlike the dumps it
+	// gets a short timeout, so a wedged server fails the run instead of hanging it.
+	listCtx := ctx
+	timeout := p.cfg.Postgres.ListTimeout
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		listCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	output, err := p.exec.Command(listCtx, "psql", "-At", "-c", query).
 		WithEnv(envVars).
-		WithDir(d.backupLocation).
+		WithDir(dir).
 		WithStderr(os.Stderr).
 		Output()
-
 	if err != nil {
-		return nil, fmt.Errorf("error getting list of databases: %w", err)
+		if ctx.Err() == nil && errors.Is(listCtx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: %s:%s didn't answer within %s", ErrListTimeout, p.host, p.port, timeout)
+		}
+		return nil, err
 	}
 
 	for _, line := range strings.Split(string(output), "\n") {
@@ -99,167 +168,617 @@ func (d *Dumpster) export(ctx context.Context) (*exportResponse, error) {
 			continue
 		}
 		databases = append(databases, line)
-		totalDatabases++
 	}
+	return databases, nil
+}
 
-	slog.DebugContext(ctx, "Databases to be dumped", "databases", databases, "location", d.backupLocation)
+// databaseSizes returns the size in bytes of each database on the server.
+func (p *Postgres) databaseSizes(ctx context.Context, envVars []string, dir string) (map[string]int64, error) {
+	query := "SELECT datname, pg_database_size(datname) FROM pg_database WHERE datistemplate = false;"
 
-	for _, db := range databases {
-		slog.InfoContext(ctx, "Processing database", "database", db)
+	output, err := p.exec.Command(ctx, "psql", "-At", "-c", query).
+		WithEnv(envVars).
+		WithDir(dir).
+		Output()
+	if err != nil {
+		return nil, err
+	}
 
-		outFile := filepath.Join(d.backupLocation, db+".sql")
-		out, cErr := d.exec.Command(ctx, "pg_dump", "--no-owner", "--no-acl", "--dbname="+db, "--file="+outFile).
-			WithEnv(envVars).
-			WithDir(d.backupLocation).
-			CombinedOutput()
-		if cErr != nil {
-			slog.WarnContext(ctx, "Error dumping database", "database", db, "error", cErr, "output", string(out))
+	sizes := map[string]int64{}
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
 			continue
 		}
-		exportedDatabases++
-		slog.InfoContext(ctx, "Successfully dumped database", "database", db)
+		// Names may contain "|"; the size is always last.
+		i := strings.LastIndex(line, "|")
+		if i < 0 {
+			return nil, fmt.Errorf("unexpected database size %q", line)
+		}
+		size, pErr := strconv.ParseInt(line[i+1:], 10, 64)
+		if pErr != nil {
+			return nil, fmt.Errorf("unexpected database size %q: %w", line, pErr)
+		}
+		sizes[line[:i]] = size
+	}
+	return sizes, nil
+}
+
+// orderDatabases returns databases in the order they are dumped: backup.priority first, then the rest
+// by size if backup.order asks for it. If the sizes can't be read, the rest keep the server's order.
+func (p *Postgres) orderDatabases(ctx context.Context, databases []string, envVars []string, dir string) []string {
+	rest := slices.Clone(databases)
+	if order := p.cfg.Backup.Order; order != "" {
+		sizes, err := p.databaseSizes(ctx, envVars, dir)
+		if err != nil {
+			slog.WarnContext(ctx, "Error reading database sizes; dumping in server order", "error", err)
+		} else {
+			slices.SortStableFunc(rest, func(a, b string) int {
+				if order == config.OrderLargestFirst {
+					return cmp.Compare(sizes[b], sizes[a])
+				}
+				return cmp.Compare(sizes[a], sizes[b])
+			})
+		}
 	}
 
-	return &exportResponse{
-		totalDatabases:    totalDatabases,
-		exportedDatabases: exportedDatabases,
-		exportLocation:    d.backupLocation,
-	}, nil
+	ordered := make([]string, 0, len(databases))
+	for _, db := range p.cfg.Backup.Priority {
+		if !slices.Contains(databases, db) {
+			slog.WarnContext(ctx, "Priority database not found on the server", "database", db)
+			continue
+		}
+		if !slices.Contains(ordered, db) {
+			ordered = append(ordered, db)
+		}
+	}
+	for _, db := range rest {
+		if !slices.Contains(ordered, db) {
+			ordered = append(ordered, db)
+		}
+	}
+	return ordered
 }
 
-// DumpResponse holds information about the dump operation.
-type DumpResponse struct {
-	TotalDatabases    int
-	ExportedDatabases int
-	DumpLocation      string
-	ArchiveLocation   string
-	StorageKey        string
+// probeDatabase checks that the database accepts connections and that the configured user can read pg_class.
+func (p *Postgres) probeDatabase(ctx context.Context, db string, envVars []string, dir string) error {
+	query := "SELECT 1 FROM pg_catalog.pg_class LIMIT 1;"
+
+	_, err := p.exec.Command(ctx, "psql", "-At", "--dbname="+db, "-c", query).
+		WithEnv(envVars).
+		WithDir(dir).
+		Output()
+	if err != nil {
+		var exitErr *osExec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return errors.New(strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return err
+	}
+	return nil
 }
 
-// CreateDump creates a PostgreSQL dump, optionally encrypts it, uploads it to storage, and returns details.
-func (d *Dumpster) CreateDump(ctx context.Context) (*DumpResponse, error) {
-	if err := d.runPreChecks(); err != nil {
+// Export dumps every readable database into dir as <db>.sql.
+func (p *Postgres) Export(ctx context.Context, dir string) (*ExportResult, error) {
+	if err := p.findPrimary(ctx, dir); err != nil {
 		return nil, err
 	}
 
-	resp, err := d.export(ctx)
+	envVars := p.getEnvVars()
+	p.checkPooler(ctx, envVars, dir)
+
+	databases, err := p.listDatabases(ctx, envVars, dir)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error getting list of databases: %w", err)
+	}
+	databases = p.orderDatabases(ctx, databases, envVars, dir)
+
+	dumpEnvVars := p.dumpEnvVars()
+	if p.cfg.Postgres.PreserveOwners {
+		if gErr := p.dumpRoles(ctx, dumpEnvVars, dir); gErr != nil {
+			return nil, fmt.Errorf("error dumping roles: %w", gErr)
+		}
+	}
+
+	result := &ExportResult{
+		TotalDatabases: len(databases),
+		Databases:      []string{},
+		Skipped:        map[string]string{},
+		Failed:         map[string]string{},
+		Extensions:     map[string][]string{},
+		SnapshotLSN:    map[string]string{},
+		Inventory:      map[string]manifest.DatabaseInfo{},
+	}
+
+	slog.DebugContext(ctx, "Databases to be dumped", "databases", databases, "location", dir)
+
+	failed := map[string]error{}
+	for _, db := range databases {
+		slog.InfoContext(ctx, "Processing database", "database", db)
+		if eErr := p.exportDatabase(ctx, db, dir, envVars, dumpEnvVars, result); eErr != nil {
+			slog.WarnContext(ctx, "Error dumping database", "database", db, "error", eErr)
+			failed[db] = eErr
+		}
 	}
+	p.retryFailed(ctx, databases, failed, dir, envVars, dumpEnvVars, result)
 
-	dumpResp := &DumpResponse{
-		TotalDatabases:    resp.totalDatabases,
-		ExportedDatabases: resp.exportedDatabases,
-		DumpLocation:      resp.exportLocation,
+	for db, fErr := range failed {
+		result.Failed[db] = fErr.Error()
 	}
+	// Retried databases are listed where the server lists them, not after the rest.
+	exported := result.Databases
+	result.Databases = slices.DeleteFunc(slices.Clone(databases), func(db string) bool { return !slices.Contains(exported, db) })
 
-	if resp.exportedDatabases <= 0 {
-		return nil, errors.New("no databases were exported")
+	if p.cfg.Postgres.PreserveOwners {
+		result.RestoreNotes = append(result.RestoreNotes,
+			"Dumps keep object owners and privileges: load "+rolesFile+" with psql (errors for roles that already exist are expected) before the database dumps")
+	}
+	return result, nil
+}
+
+// exportDatabase dumps db into dir and records it in result. A database failing the permission probe is
+// recorded as skipped; a failed dump is removed and returned.
+func (p *Postgres) exportDatabase(ctx context.Context, db, dir string, envVars, dumpEnvVars []string, result *ExportResult) error {
+	if pErr := p.probeDatabase(ctx, db, envVars, dir); pErr != nil {
+		slog.WarnContext(ctx, "Skipping database, permission probe failed", "database", db, "error", pErr)
+		result.Skipped[db] = pErr.Error()
+		return nil
 	}
 
-	archiveResp, err := file.ArchiveDir(resp.exportLocation, nil)
+	outFile := filepath.Join(dir, db+p.Extension())
+	lsn, err := p.dumpDatabase(ctx, db, outFile, dumpEnvVars, dir)
+	if err == nil {
+		err = validatePlainDump(outFile)
+	}
 	if err != nil {
-		return nil, err
+		// Don't archive a partial dump.
+		_ = os.Remove(outFile)
+		return err
+	}
+	if lsn != "" {
+		result.SnapshotLSN[db] = lsn
+	}
+	if p.cfg.Postgres.Citus {
+		if cErr := p.appendCitusDistribution(ctx, db, outFile, envVars, dir); cErr != nil {
+			return fmt.Errorf("error dumping Citus table distribution: %w", cErr)
+		}
+	}
+	if hasTimescale, tErr := p.hasExtension(ctx, db, "timescaledb", envVars, dir); tErr != nil {
+		slog.WarnContext(ctx, "Error checking for TimescaleDB", "database", db, "error", tErr)
+	} else if hasTimescale {
+		result.Extensions[db] = append(result.Extensions[db], "timescaledb")
+		result.RestoreNotes = append(result.RestoreNotes, fmt.Sprintf(
+			"%s uses TimescaleDB: restore into a server running the same TimescaleDB version, "+
+				"run SELECT timescaledb_pre_restore(); before loading %s%s and SELECT timescaledb_post_restore(); after",
+			db, db, p.Extension()))
 	}
 
-	archivePath := archiveResp.ArchivePath
+	if info, iErr := p.databaseInfo(ctx, db, envVars, dir); iErr != nil {
+		slog.WarnContext(ctx, "Error reading database settings", "database", db, "error", iErr)
+	} else {
+		result.Inventory[db] = info
+	}
+
+	result.Databases = append(result.Databases, db)
+	slog.InfoContext(ctx, "Successfully dumped database", "database", db)
+	return nil
+}
+
+// transientDumpErrors are substrings of pg_dump and server errors that a later attempt can get past.
+var transientDumpErrors = []string{
+	"connection reset",
+	"server closed the connection unexpectedly",
+	"terminating connection due to administrator command",
+	"connection to server was lost",
+	"the database system is starting up",
+	"the database system is shutting down",
+	"too many clients",
+	"remaining connection slots are reserved",
+	"lock timeout",
+	"could not obtain lock",
+	"deadlock detected",
+	"conflict with recovery",
+	"timeout expired",
+}
+
+// isTransientDumpError reports whether a database dump that failed with err may succeed when retried.
+func isTransientDumpError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return slices.ContainsFunc(transientDumpErrors, func(s string) bool { return strings.Contains(msg, s) })
+}
 
-	uploadFilePath := archivePath
+// retryFailed dumps the databases in failed that failed with a transient error again, up to
+// backup.database-retries rounds, waiting longer before each. Databases that succeed, or are skipped by
+// their permission probe, are removed from failed; the others keep their latest error.
+func (p *Postgres) retryFailed(ctx context.Context, databases []string, failed map[string]error, dir string, envVars, dumpEnvVars []string, result *ExportResult) {
+	for round := 1; round <= p.cfg.Backup.DatabaseRetries; round++ {
+		retry := slices.DeleteFunc(slices.Clone(databases), func(db string) bool {
+			fErr, ok := failed[db]
+			return !ok || !isTransientDumpError(fErr)
+		})
+		if len(retry) == 0 {
+			return
+		}
 
-	if d.cfg.Backup.Encrypt {
-		slog.DebugContext(ctx, "fetching gpg key", "key_id", d.cfg.Encryption.GPG.KeyID, "key_server", d.cfg.Encryption.GPG.KeyServer)
-		_, gErr := d.gpg.FetchGPGPubKeyFromKeyServer(d.cfg.Encryption.GPG.KeyID, d.cfg.Encryption.GPG.KeyServer)
-		if gErr != nil {
-			slog.WarnContext(ctx, "Error downloading gpg key", "error", gErr)
-			return nil, gErr
+		delay := p.cfg.Backup.DatabaseRetryDelay * time.Duration(round)
+		slog.InfoContext(ctx, "Retrying databases that failed with transient errors", "databases", retry, "round", round, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
 		}
 
-		slog.DebugContext(ctx, "Encrypting archive file", "file", archivePath)
-		encryptedFilePath, gErr := d.gpg.EncryptFile(archivePath)
-		if gErr != nil {
-			slog.WarnContext(ctx, "Error encrypting archive file", "error", gErr)
-			return nil, gErr
+		for _, db := range retry {
+			err := p.exportDatabase(ctx, db, dir, envVars, dumpEnvVars, result)
+			if err != nil {
+				slog.WarnContext(ctx, "Error dumping database again", "database", db, "round", round, "error", err)
+				metrics.DatabaseRetries.WithLabelValues("failed").Inc()
+				failed[db] = err
+				continue
+			}
+			metrics.DatabaseRetries.WithLabelValues("recovered").Inc()
+			delete(failed, db)
 		}
-		slog.DebugContext(ctx, "Encrypted file", "file", encryptedFilePath)
-		uploadFilePath = encryptedFilePath
 	}
+}
+
+// dumpRoles saves the cluster's roles to rolesFile in dir. Managed services often don't allow
+// reading role passwords, so it falls back to dumping roles without them.
+func (p *Postgres) dumpRoles(ctx context.Context, envVars []string, dir string) error {
+	outFile := filepath.Join(dir, rolesFile)
+
+	out, err := p.exec.Command(ctx, "pg_dumpall", "--roles-only", "--file="+outFile).
+		WithEnv(envVars).
+		WithDir(dir).
+		CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	slog.WarnContext(ctx, "Error dumping roles, retrying without passwords", "error", err, "output", strings.TrimSpace(string(out)))
 
-	slog.InfoContext(ctx, "Uploading backup", "file", uploadFilePath, "storage", d.store.Name())
-	key, err := d.store.Upload(ctx, uploadFilePath)
+	out, err = p.exec.Command(ctx, "pg_dumpall", "--roles-only", "--no-role-passwords", "--file="+outFile).
+		WithEnv(envVars).
+		WithDir(dir).
+		CombinedOutput()
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// RestoreGlobals recreates the roles saved by dumpRoles. Roles that already exist
+// (e.g. the restoring superuser) make psql report errors, which are expected and ignored.
+func (p *Postgres) RestoreGlobals(ctx context.Context, dumpDir, dir string) error {
+	path := filepath.Join(dumpDir, rolesFile)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err := p.findPrimary(ctx, dir); err != nil {
+		return err
 	}
 
-	slog.InfoContext(ctx, "Backup uploaded", "location", key)
-	dumpResp.ArchiveLocation = archivePath
-	dumpResp.StorageKey = key
-	return dumpResp, nil
+	slog.InfoContext(ctx, "Restoring roles")
+	out, err := p.exec.Command(ctx, "psql", "--dbname=postgres", "--file="+path).
+		WithEnv(p.getEnvVars()).
+		WithDir(dir).
+		CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	slog.DebugContext(ctx, "Restored roles", "output", string(out))
+	return nil
+}
+
+// dumpArgs returns the pg_dump arguments shared by every dump of db.
+func (p *Postgres) dumpArgs(db string) []string {
+	args := []string{"--dbname=" + db}
+	if !p.cfg.Postgres.PreserveOwners {
+		args = append([]string{"--no-owner", "--no-acl"}, args...)
+	}
+	return args
+}
+
+// StreamDatabase runs pg_dump for db, writing the plain dump to w.
+func (p *Postgres) StreamDatabase(ctx context.Context, db string, w *os.File, dir string) error {
+	if err := p.findPrimary(ctx, dir); err != nil {
+		return err
+	}
+	return p.exec.Command(ctx, "pg_dump", p.dumpArgs(db)...).
+		WithEnv(p.dumpEnvVars()).
+		WithDir(dir).
+		WithStdout(w).
+		WithStderr(os.Stderr).
+		Run()
 }
 
-// ListDumps lists available dumps in the storage backend, sorted by date.
-func (d *Dumpster) ListDumps(ctx context.Context) ([]string, error) {
-	keys, err := d.store.List(ctx)
+// dumpDatabase runs pg_dump for db into outFile. In replication slot snapshot mode the dump reads the
+// snapshot exported by a temporary logical replication slot and the slot's consistent point is returned.
+func (p *Postgres) dumpDatabase(ctx context.Context, db, outFile string, envVars []string, dir string) (string, error) {
+	args := append(p.dumpArgs(db), "--file="+outFile)
+
+	var lsn string
+	if p.cfg.Postgres.ReplicationSlotSnapshot {
+		slot, err := p.openReplicationSlot(ctx, db, envVars, dir)
+		if err != nil {
+			return "", err
+		}
+		defer func() {
+			if cErr := slot.Close(); cErr != nil {
+				slog.WarnContext(ctx, "Error closing replication slot", "database", db, "slot", slot.Name, "error", cErr)
+			}
+		}()
+		args = append(args, "--snapshot="+slot.Snapshot)
+		lsn = slot.LSN
+	}
+
+	out, err := p.exec.Command(ctx, "pg_dump", args...).
+		WithEnv(envVars).
+		WithDir(dir).
+		CombinedOutput()
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
 	}
+	return lsn, nil
+}
 
-	if len(keys) == 0 {
-		slog.InfoContext(ctx, "No backups found")
-		return []string{}, nil
+// plainDumpTrailer is the last line pg_dump writes to a complete plain-format dump.
+const plainDumpTrailer = "-- PostgreSQL database dump complete"
+
+// validatePlainDump checks that a plain-format dump is non-empty and ends with pg_dump's completion trailer.
+func validatePlainDump(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
 	}
+	defer func() {
+		_ = f.Close()
+	}()
 
-	keys = d.store.TrimPrefix(keys)
-	keys = datetime.SortDateTimes(keys)
-	slog.DebugContext(ctx, "Found backups", "keys", keys)
-	return keys, nil
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return errors.New("dump is empty")
+	}
+
+	// The trailer is followed by a few blank lines; the last KiB is plenty.
+	tail := make([]byte, min(info.Size(), 1024))
+	if _, err := f.ReadAt(tail, info.Size()-int64(len(tail))); err != nil {
+		return err
+	}
+	if !strings.Contains(string(tail), plainDumpTrailer) {
+		return fmt.Errorf("dump is truncated: missing %q trailer", plainDumpTrailer)
+	}
+	return nil
+}
+
+// psqlQuery runs a single query against db and returns its unaligned, tuples-only output.
+func (p *Postgres) psqlQuery(ctx context.Context, db, query string, envVars []string, dir string) (string, error) {
+	out, err := p.exec.Command(ctx, "psql", "-At", "--dbname="+db, "-c", query).
+		WithEnv(envVars).
+		WithDir(dir).
+		Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// databaseExists reports whether db exists on the server.
+func (p *Postgres) databaseExists(ctx context.Context, db string, envVars []string, dir string) (bool, error) {
+	out, err := p.psqlQuery(ctx, "postgres", "SELECT 1 FROM pg_database WHERE datname = "+quoteLiteral(db)+";", envVars, dir)
+	if err != nil {
+		return false, err
+	}
+	return out == "1", nil
 }
 
-// PurgeDumps deletes old dumps from storage based on the retention policy.
-func (d *Dumpster) PurgeDumps(ctx context.Context) error {
-	keys, err := d.ListDumps(ctx)
+// TableStats lists the user tables in db with their estimated live row counts.
+func (p *Postgres) TableStats(ctx context.Context, db, dir string) ([]TableStat, error) {
+	if err := p.findPrimary(ctx, dir); err != nil {
+		return nil, err
+	}
+
+	envVars := p.getEnvVars()
+
+	exists, err := p.databaseExists(ctx, db, envVars, dir)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	query := "SELECT schemaname || '.' || relname, n_live_tup FROM pg_catalog.pg_stat_user_tables ORDER BY 1;"
+	out, err := p.psqlQuery(ctx, db, query, envVars, dir)
 	if err != nil {
+		return nil, err
+	}
+
+	tables := []TableStat{}
+	for _, line := range strings.Split(out, "\n") {
+		name, rows, ok := strings.Cut(line, "|")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(rows, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing row count of %s: %w", name, err)
+		}
+		tables = append(tables, TableStat{Name: name, Rows: n})
+	}
+	return tables, nil
+}
+
+// DropDatabase drops db if it exists. It fails if other sessions are connected to db.
+func (p *Postgres) DropDatabase(ctx context.Context, db, dir string) error {
+	if err := p.findPrimary(ctx, dir); err != nil {
 		return err
 	}
+	_, err := p.psqlQuery(ctx, "postgres", "DROP DATABASE IF EXISTS "+quoteIdent(db)+";", p.getEnvVars(), dir)
+	return err
+}
 
-	if len(keys) <= d.cfg.Backup.RetentionCount {
-		slog.InfoContext(ctx, "No backups to delete")
-		return nil
+// databaseInfo reads the owner, encoding and collation db was created with.
+func (p *Postgres) databaseInfo(ctx context.Context, db string, envVars []string, dir string) (manifest.DatabaseInfo, error) {
+	query := "SELECT pg_catalog.pg_get_userbyid(datdba), pg_catalog.pg_encoding_to_char(encoding), datcollate, datctype " +
+		"FROM pg_catalog.pg_database WHERE datname = " + quoteLiteral(db) + ";"
+	out, err := p.psqlQuery(ctx, db, query, envVars, dir)
+	if err != nil {
+		return manifest.DatabaseInfo{}, err
+	}
+
+	fields := strings.Split(out, "|")
+	if len(fields) != 4 {
+		return manifest.DatabaseInfo{}, fmt.Errorf("unexpected database settings %q", out)
 	}
+	return manifest.DatabaseInfo{Owner: fields[0], Encoding: fields[1], Collate: fields[2], CType: fields[3]}, nil
+}
 
-	keysToDelete := keys[d.cfg.Backup.RetentionCount:]
-	slog.InfoContext(ctx, "Found backups to delete", "count", len(keysToDelete), "retention", d.cfg.Backup.RetentionCount)
+// createDatabaseSQL builds the CREATE DATABASE statement for a restore target. An owner
+// that doesn't exist on the server is left out, so the database is owned by the restoring user.
+func (p *Postgres) createDatabaseSQL(ctx context.Context, target RestoreTarget, envVars []string, dir string) (string, error) {
+	var b strings.Builder
+	b.WriteString("CREATE DATABASE " + quoteIdent(target.Database))
+
+	info := target.Info
+	template := p.cfg.Restore.Template
+	if template == "" && (info.Encoding != "" || info.Collate != "" || info.CType != "") {
+		// Only template0 accepts an encoding or locale different from its own.
+		template = "template0"
+	}
+	if template != "" {
+		b.WriteString(" TEMPLATE " + quoteIdent(template))
+	}
 
-	for _, key := range keysToDelete {
-		slog.InfoContext(ctx, "Deleting backup", "key", key)
-		if sErr := d.store.Delete(ctx, key); sErr != nil {
-			slog.ErrorContext(ctx, "Error deleting backup", "key", key, "error", sErr)
-			return fmt.Errorf("error deleting backup %s: %w", key, sErr)
+	if info.Owner != "" {
+		out, err := p.psqlQuery(ctx, "postgres", "SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = "+quoteLiteral(info.Owner)+";", envVars, dir)
+		if err != nil {
+			return "", err
+		}
+		if out == "1" {
+			b.WriteString(" OWNER " + quoteIdent(info.Owner))
+		} else {
+			slog.WarnContext(ctx, "Database owner does not exist; keeping the restoring user as owner", "database", target.Database, "owner", info.Owner)
 		}
 	}
-	slog.InfoContext(ctx, "Deletion completed successfully")
-	return nil
+
+	if info.Encoding != "" {
+		b.WriteString(" ENCODING " + quoteLiteral(info.Encoding))
+	}
+	if info.Collate != "" {
+		b.WriteString(" LC_COLLATE " + quoteLiteral(info.Collate))
+	}
+	if info.CType != "" {
+		b.WriteString(" LC_CTYPE " + quoteLiteral(info.CType))
+	}
+	return b.String() + ";", nil
 }
 
-// Dump creates a dump and purges old dumps based on retention policy.
-func (d *Dumpster) Dump(ctx context.Context) (*DumpResponse, error) {
-	resp, err := d.CreateDump(ctx)
+// hasExtension reports whether the extension name is installed in db.
+func (p *Postgres) hasExtension(ctx context.Context, db, name string, envVars []string, dir string) (bool, error) {
+	out, err := p.psqlQuery(ctx, db, "SELECT 1 FROM pg_extension WHERE extname = "+quoteLiteral(name)+";", envVars, dir)
 	if err != nil {
-		return nil, err
+		return false, err
+	}
+	return out == "1", nil
+}
+
+// countUserTables returns the number of tables outside the system schemas in db.
+func (p *Postgres) countUserTables(ctx context.Context, db string, envVars []string, dir string) (int, error) {
+	query := "SELECT count(*) FROM pg_catalog.pg_tables WHERE schemaname NOT IN ('pg_catalog','information_schema');"
+	out, err := p.psqlQuery(ctx, db, query, envVars, dir)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(out)
+}
+
+// IsEmpty reports whether no user database on the server contains any tables.
+// Databases that exist but are empty (e.g. created by the container entrypoint) count as empty.
+func (p *Postgres) IsEmpty(ctx context.Context, dir string) (bool, error) {
+	if err := p.findPrimary(ctx, dir); err != nil {
+		return false, err
+	}
+
+	envVars := p.getEnvVars()
+
+	databases, err := p.listDatabases(ctx, envVars, dir)
+	if err != nil {
+		return false, fmt.Errorf("error getting list of databases: %w", err)
 	}
 
-	if pErr := d.PurgeDumps(ctx); pErr != nil {
-		return nil, pErr
+	for _, db := range databases {
+		tables, cErr := p.countUserTables(ctx, db, envVars, dir)
+		if cErr != nil {
+			return false, fmt.Errorf("error inspecting database %s: %w", db, cErr)
+		}
+		if tables > 0 {
+			slog.DebugContext(ctx, "Database is not empty", "database", db, "tables", tables)
+			return false, nil
+		}
 	}
-	return resp, nil
+	return true, nil
 }
 
-// NewDumpster creates a new Dumpster instance with the provided configuration, storage backend, and executor.
-func NewDumpster(cfg *config.Config, store storage.StorageIface, exec exec.ExecIface) *Dumpster {
-	return &Dumpster{
-		store:          store,
-		cfg:            cfg,
-		exec:           exec,
-		backupLocation: filepath.Join(os.TempDir(), constants.ExportDir),
-		gpg:            gpg.NewGPG(gpg.Options{}),
+// RestoreDatabase creates db if it doesn't exist and loads the plain SQL dump into it.
+// TimescaleDB databases are loaded between timescaledb_pre_restore() and timescaledb_post_restore()
+// so hypertables come back intact.
+func (p *Postgres) RestoreDatabase(ctx context.Context, target RestoreTarget, dir string) error {
+	if err := p.findPrimary(ctx, dir); err != nil {
+		return err
+	}
+
+	envVars := p.getEnvVars()
+	db, dumpFile := target.Database, target.DumpFile
+
+	exists, err := p.databaseExists(ctx, db, envVars, dir)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		create, cErr := p.createDatabaseSQL(ctx, target, envVars, dir)
+		if cErr != nil {
+			return cErr
+		}
+		slog.InfoContext(ctx, "Creating database", "database", db, "statement", create)
+		if _, cErr := p.psqlQuery(ctx, "postgres", create, envVars, dir); cErr != nil {
+			return cErr
+		}
+	}
+
+	args := []string{"-v", "ON_ERROR_STOP=1", "--dbname=" + db}
+	if slices.Contains(target.Extensions, "timescaledb") {
+		slog.InfoContext(ctx, "Restoring TimescaleDB database", "database", db)
+		args = append(args,
+			"-c", "CREATE EXTENSION IF NOT EXISTS timescaledb;",
+			"-c", "SELECT timescaledb_pre_restore();",
+			"--file="+dumpFile,
+			"-c", "SELECT timescaledb_post_restore();")
+	} else {
+		args = append(args, "--file="+dumpFile)
+	}
+
+	out, err := p.exec.Command(ctx, "psql", args...).
+		WithEnv(envVars).
+		WithDir(dir).
+		CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
 	}
+	return nil
+}
+
+// quoteIdent quotes a PostgreSQL identifier.
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// quoteLiteral quotes a PostgreSQL string literal.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// NewPostgres creates the PostgreSQL engine.
+func NewPostgres(cfg *config.Config, exec exec.ExecIface) Engine {
+	return &Postgres{cfg: cfg, exec: exec, host: cfg.Postgres.Host, port: cfg.Postgres.Port}
 }
@@ -1,34 +1,32 @@
 package dumpster
 
 import (
+	"bufio"
 	"context"
 	"errors"
+	"fmt"
 	"os"
+	osExec "os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hibare/GoCommon/v2/pkg/os/exec"
 	"github.com/hibare/stashly/internal/config"
-	"github.com/hibare/stashly/internal/storage"
+	"github.com/hibare/stashly/internal/manifest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
-func TestNewDumpster(t *testing.T) {
-	cfg := &config.Config{}
-	mockStore := storage.NewMockStorageIface(t)
+func newTestPostgres(t *testing.T, cfg *config.Config) (*Postgres, *exec.MockExecIface) {
+	t.Helper()
 	mockExec := exec.NewMockExecIface(t)
-
-	dumpster := NewDumpster(cfg, mockStore, mockExec)
-
-	assert.NotNil(t, dumpster)
-	assert.Equal(t, cfg, dumpster.cfg)
-	assert.Equal(t, mockStore, dumpster.store)
-	assert.Equal(t, mockExec, dumpster.exec)
-	assert.Contains(t, dumpster.backupLocation, "export")
+	return NewPostgres(cfg, mockExec).(*Postgres), mockExec //nolint:errcheck // reason: NewPostgres always returns *Postgres
 }
 
-func TestDumpster_getEnvVars(t *testing.T) {
+func TestPostgres_getEnvVars(t *testing.T) {
 	cfg := &config.Config{
 		Postgres: config.PostgresConfig{
 			User:     "testuser",
@@ -37,11 +35,7 @@ func TestDumpster_getEnvVars(t *testing.T) {
 			Port:     "5432",
 		},
 	}
-	mockStore := storage.NewMockStorageIface(t)
-	mockExec := exec.NewMockExecIface(t)
-
-	dumpster := NewDumpster(cfg, mockStore, mockExec)
-	envVars := dumpster.getEnvVars()
+	pg, _ := newTestPostgres(t, cfg)
 
 	expected := []string{
 		"PGUSER=testuser",
@@ -50,423 +44,476 @@ func TestDumpster_getEnvVars(t *testing.T) {
 		"PGPORT=5432",
 	}
 
-	assert.Equal(t, expected, envVars)
+	assert.Equal(t, expected, pg.getEnvVars())
 }
 
-func TestDumpster_runPreChecks_Success(t *testing.T) {
-	cfg := &config.Config{}
-	mockStore := storage.NewMockStorageIface(t)
-	mockExec := exec.NewMockExecIface(t)
-
-	dumpster := NewDumpster(cfg, mockStore, mockExec)
+func TestPostgres_probeDatabase_Success(t *testing.T) {
+	pg, mockExec := newTestPostgres(t, &config.Config{})
+	mockCmd := exec.NewMockCmdIface(t)
 
-	// Mock successful binary lookups
-	mockExec.On("LookPath", "psql").Return("/usr/bin/psql", nil)
-	mockExec.On("LookPath", "pg_dump").Return("/usr/bin/pg_dump", nil)
+	mockExec.On("Command", mock.Anything, "psql", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithDir", "/tmp/work").Return(mockCmd)
+	mockCmd.On("Output").Return([]byte("1\n"), nil)
 
-	err := dumpster.runPreChecks()
+	err := pg.probeDatabase(context.Background(), "db1", nil, "/tmp/work")
 
 	require.NoError(t, err)
 	mockExec.AssertExpectations(t)
-
-	// Cleanup
-	_ = os.RemoveAll(dumpster.backupLocation)
-}
-
-func TestDumpster_runPreChecks_BinaryNotFound(t *testing.T) {
-	cfg := &config.Config{}
-	mockStore := storage.NewMockStorageIface(t)
-	mockExec := exec.NewMockExecIface(t)
-
-	dumpster := NewDumpster(cfg, mockStore, mockExec)
-
-	// Mock failed binary lookup
-	mockExec.On("LookPath", "psql").Return("", errors.New("binary not found"))
-
-	err := dumpster.runPreChecks()
-
-	require.Error(t, err)
-	assert.Contains(t, err.Error(), "psql not found in PATH")
-	mockExec.AssertExpectations(t)
+	mockCmd.AssertExpectations(t)
 }
 
-func TestDumpster_CreateDump_Success(t *testing.T) {
-	cfg := &config.Config{
-		Backup: config.BackupConfig{
-			Encrypt: false,
-		},
-	}
-	mockStore := storage.NewMockStorageIface(t)
-	mockExec := exec.NewMockExecIface(t)
+func TestPostgres_probeDatabase_PermissionDenied(t *testing.T) {
+	pg, mockExec := newTestPostgres(t, &config.Config{})
 	mockCmd := exec.NewMockCmdIface(t)
 
-	dumpster := NewDumpster(cfg, mockStore, mockExec)
-
-	// Mock successful pre-checks
-	mockExec.On("LookPath", "psql").Return("/usr/bin/psql", nil)
-	mockExec.On("LookPath", "pg_dump").Return("/usr/bin/pg_dump", nil)
-
-	// Mock successful database listing
+	// Mock psql exiting with a permission error on stderr
+	exitErr := &osExec.ExitError{Stderr: []byte("FATAL:  permission denied for database \"db1\"\n")}
 	mockExec.On("Command", mock.Anything, "psql", mock.Anything).Return(mockCmd)
 	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
-	mockCmd.On("WithDir", dumpster.backupLocation).Return(mockCmd)
-	mockCmd.On("WithStderr", os.Stderr).Return(mockCmd)
-	mockCmd.On("Output").Return([]byte("db1\n"), nil)
+	mockCmd.On("WithDir", "/tmp/work").Return(mockCmd)
+	mockCmd.On("Output").Return([]byte(""), exitErr)
 
-	// Mock successful pg_dump
-	mockExec.On("Command", mock.Anything, "pg_dump", mock.Anything).Return(mockCmd)
-	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
-	mockCmd.On("WithDir", dumpster.backupLocation).Return(mockCmd)
-	mockCmd.On("WithStderr", os.Stderr).Return(mockCmd)
-	mockCmd.On("CombinedOutput").Return([]byte(""), nil)
-
-	// Mock successful storage upload
-	mockStore.On("Name").Return("test-storage")
-	mockStore.On("Upload", mock.Anything).Return("backup-2024-01-01.tar.gz", nil)
-
-	resp, err := dumpster.CreateDump(context.Background())
-
-	require.NoError(t, err)
-	require.NotNil(t, resp)
-	assert.Equal(t, 1, resp.TotalDatabases)
-	assert.Equal(t, 1, resp.ExportedDatabases)
-	assert.Equal(t, dumpster.backupLocation, resp.DumpLocation)
-	assert.Equal(t, "backup-2024-01-01.tar.gz", resp.StorageKey)
+	err := pg.probeDatabase(context.Background(), "db1", nil, "/tmp/work")
 
+	require.Error(t, err)
+	assert.Equal(t, `FATAL:  permission denied for database "db1"`, err.Error())
 	mockExec.AssertExpectations(t)
 	mockCmd.AssertExpectations(t)
-	mockStore.AssertExpectations(t)
+}
+
+func TestPostgres_IsEmpty(t *testing.T) {
+	tests := []struct {
+		name   string
+		tables string
+		want   bool
+	}{
+		{name: "empty", tables: "0\n", want: true},
+		{name: "has tables", tables: "3\n", want: false},
+	}
 
-	// Cleanup
-	_ = os.RemoveAll(dumpster.backupLocation)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pg, mockExec := newTestPostgres(t, &config.Config{})
+			mockCmd := exec.NewMockCmdIface(t)
+
+			mockExec.On("Command", mock.Anything, "psql", mock.Anything).Return(mockCmd)
+			mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
+			mockCmd.On("WithDir", "/tmp/work").Return(mockCmd)
+			mockCmd.On("WithStderr", os.Stderr).Return(mockCmd)
+			mockCmd.On("Output").Return([]byte("db1\n"), nil).Once()
+			mockCmd.On("Output").Return([]byte(tt.tables), nil).Once()
+
+			empty, err := pg.IsEmpty(context.Background(), "/tmp/work")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, empty)
+		})
+	}
 }
 
-func TestDumpster_CreateDump_NoDatabasesExported(t *testing.T) {
+func TestPostgres_listDatabases_Timeout(t *testing.T) {
 	cfg := &config.Config{}
-	mockStore := storage.NewMockStorageIface(t)
-	mockExec := exec.NewMockExecIface(t)
+	cfg.Postgres.ListTimeout = 10 * time.Millisecond
+	pg, mockExec := newTestPostgres(t, cfg)
+	pg.host, pg.port = "db.internal", "5432"
 	mockCmd := exec.NewMockCmdIface(t)
 
-	dumpster := NewDumpster(cfg, mockStore, mockExec)
-
-	// Mock successful pre-checks
-	mockExec.On("LookPath", "psql").Return("/usr/bin/psql", nil)
-	mockExec.On("LookPath", "pg_dump").Return("/usr/bin/pg_dump", nil)
-
-	// Mock successful database listing but no databases
-	mockExec.On("Command", mock.Anything, "psql", mock.Anything).Return(mockCmd)
+	// psql is killed with its context on a wedged server.
+	var cmdCtx context.Context
+	mockExec.On("Command", mock.Anything, "psql", mock.Anything).Run(func(args mock.Arguments) {
+		cmdCtx = args.Get(0).(context.Context) //nolint:errcheck // reason: Command always gets a context
+	}).Return(mockCmd)
 	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
-	mockCmd.On("WithDir", dumpster.backupLocation).Return(mockCmd)
+	mockCmd.On("WithDir", "/tmp/work").Return(mockCmd)
 	mockCmd.On("WithStderr", os.Stderr).Return(mockCmd)
-	mockCmd.On("Output").Return([]byte(""), nil)
+	mockCmd.On("Output").Run(func(mock.Arguments) { <-cmdCtx.Done() }).Return([]byte(nil), errors.New("signal: killed"))
 
-	resp, err := dumpster.CreateDump(context.Background())
+	_, err := pg.listDatabases(context.Background(), nil, "/tmp/work")
+	require.ErrorIs(t, err, ErrListTimeout)
+	assert.Contains(t, err.Error(), "db.internal:5432")
+}
 
-	require.Error(t, err)
-	require.Nil(t, resp)
-	assert.Contains(t, err.Error(), "no databases were exported")
+func TestPostgres_dumpEnvVars(t *testing.T) {
+	cfg := &config.Config{
+		Postgres: config.PostgresConfig{
+			User:     "u",
+			Password: "p",
+			Host:     "pgbouncer",
+			Port:     "6432",
+			DumpHost: "primary",
+			DumpPort: "5432",
+		},
+	}
+	pg, _ := newTestPostgres(t, cfg)
 
-	mockExec.AssertExpectations(t)
-	mockCmd.AssertExpectations(t)
+	assert.Equal(t, []string{"PGUSER=u", "PGPASSWORD=p", "PGHOST=primary", "PGPORT=5432"}, pg.dumpEnvVars())
+	assert.Contains(t, pg.getEnvVars(), "PGHOST=pgbouncer")
 }
 
-func TestDumpster_CreateDump_PgDumpError(t *testing.T) {
+func TestPostgres_findPrimary(t *testing.T) {
 	cfg := &config.Config{
-		Backup: config.BackupConfig{
-			Encrypt: false,
+		Postgres: config.PostgresConfig{
+			Host:  "lb",
+			Port:  "5432",
+			Nodes: []string{"pg-0:5433", "pg-1", "pg-2:5432"},
 		},
 	}
-	mockStore := storage.NewMockStorageIface(t)
-	mockExec := exec.NewMockExecIface(t)
+	pg, mockExec := newTestPostgres(t, cfg)
 	mockCmd := exec.NewMockCmdIface(t)
 
-	dumpster := NewDumpster(cfg, mockStore, mockExec)
-
-	// Mock successful pre-checks
-	mockExec.On("LookPath", "psql").Return("/usr/bin/psql", nil)
-	mockExec.On("LookPath", "pg_dump").Return("/usr/bin/pg_dump", nil)
-
-	// Mock successful database listing
 	mockExec.On("Command", mock.Anything, "psql", mock.Anything).Return(mockCmd)
 	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
-	mockCmd.On("WithDir", dumpster.backupLocation).Return(mockCmd)
-	mockCmd.On("WithStderr", os.Stderr).Return(mockCmd)
-	mockCmd.On("Output").Return([]byte("db1\n"), nil)
-
-	// Mock failed pg_dump
-	mockExec.On("Command", mock.Anything, "pg_dump", mock.Anything).Return(mockCmd)
-	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
-	mockCmd.On("WithDir", dumpster.backupLocation).Return(mockCmd)
-	mockCmd.On("WithStderr", os.Stderr).Return(mockCmd)
-	mockCmd.On("CombinedOutput").Return([]byte("permission denied"), errors.New("access denied"))
+	mockCmd.On("WithDir", "/tmp/work").Return(mockCmd)
+	mockCmd.On("Output").Return([]byte(""), errors.New("connection refused")).Once()
+	mockCmd.On("Output").Return([]byte("f\n"), nil).Once()
+
+	require.NoError(t, pg.findPrimary(context.Background(), "/tmp/work"))
+	assert.Contains(t, pg.getEnvVars(), "PGHOST=pg-1")
+	assert.Contains(t, pg.getEnvVars(), "PGPORT=5432")
+	mockCmd.AssertNumberOfCalls(t, "Output", 2)
+}
 
-	resp, err := dumpster.CreateDump(context.Background())
+func TestPostgres_findPrimary_NoPrimary(t *testing.T) {
+	cfg := &config.Config{Postgres: config.PostgresConfig{Port: "5432", Nodes: []string{"pg-0", "pg-1"}}}
+	pg, mockExec := newTestPostgres(t, cfg)
+	mockCmd := exec.NewMockCmdIface(t)
 
-	require.Error(t, err)
-	require.Nil(t, resp)
-	assert.Contains(t, err.Error(), "no databases were exported")
+	mockExec.On("Command", mock.Anything, "psql", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithDir", "/tmp/work").Return(mockCmd)
+	mockCmd.On("Output").Return([]byte("t\n"), nil)
 
-	mockExec.AssertExpectations(t)
-	mockCmd.AssertExpectations(t)
+	err := pg.findPrimary(context.Background(), "/tmp/work")
+	require.ErrorIs(t, err, ErrNoPrimary)
+	assert.Contains(t, err.Error(), "pg-1: in recovery")
 }
 
-func TestDumpster_ListDumps_Success(t *testing.T) {
-	cfg := &config.Config{}
-	mockStore := storage.NewMockStorageIface(t)
-	mockExec := exec.NewMockExecIface(t)
+func TestPoolerReason(t *testing.T) {
+	assert.Empty(t, poolerReason("5432|100\n5432|100\n", "5432"))
+	assert.Contains(t, poolerReason("5432|100\n5432|101\n", "5432"), "transaction pooling")
+	assert.Contains(t, poolerReason("5432|100\n5432|100\n", "6432"), "proxied")
+	assert.Empty(t, poolerReason("garbage", "5432"))
+}
 
-	dumpster := NewDumpster(cfg, mockStore, mockExec)
+func TestPostgres_RestoreDatabase_Timescale(t *testing.T) {
+	pg, mockExec := newTestPostgres(t, &config.Config{})
+	mockCmd := exec.NewMockCmdIface(t)
 
-	// Mock successful storage listing
-	keys := []string{"backup-2024-01-01.tar.gz", "backup-2024-01-02.tar.gz"}
-	mockStore.On("List").Return(keys, nil)
-	mockStore.On("TrimPrefix", keys).Return(keys)
+	mockExec.On("Command", mock.Anything, "psql", mock.Anything).Return(mockCmd).Once()
+	mockExec.On("Command", mock.Anything, "psql", []string{
+		"-v", "ON_ERROR_STOP=1", "--dbname=metrics",
+		"-c", "CREATE EXTENSION IF NOT EXISTS timescaledb;",
+		"-c", "SELECT timescaledb_pre_restore();",
+		"--file=/tmp/work/metrics.sql",
+		"-c", "SELECT timescaledb_post_restore();",
+	}).Return(mockCmd).Once()
+	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithDir", "/tmp/work").Return(mockCmd)
+	mockCmd.On("Output").Return([]byte("1\n"), nil)
+	mockCmd.On("CombinedOutput").Return([]byte(""), nil)
 
-	dumps, err := dumpster.ListDumps(context.Background())
+	target := RestoreTarget{Database: "metrics", DumpFile: "/tmp/work/metrics.sql", Extensions: []string{"timescaledb"}}
+	err := pg.RestoreDatabase(context.Background(), target, "/tmp/work")
 
 	require.NoError(t, err)
-	// Note: The actual result will be transformed by datetime.SortDateTimes
-	// So we just check that we get some result
-	assert.NotEmpty(t, dumps)
-
-	mockStore.AssertExpectations(t)
+	mockExec.AssertExpectations(t)
 }
 
-func TestDumpster_ListDumps_Empty(t *testing.T) {
-	cfg := &config.Config{}
-	mockStore := storage.NewMockStorageIface(t)
-	mockExec := exec.NewMockExecIface(t)
-
-	dumpster := NewDumpster(cfg, mockStore, mockExec)
-
-	// Mock empty storage listing
-	mockStore.On("List").Return([]string{}, nil)
-
-	dumps, err := dumpster.ListDumps(context.Background())
-
+func TestParseSlotOutput(t *testing.T) {
+	name, lsn, snapshot, err := parseSlotOutput("stashly_abc|0/16B1970|00000003-00000002-1|pgoutput\n")
 	require.NoError(t, err)
-	assert.Empty(t, dumps)
+	assert.Equal(t, "stashly_abc", name)
+	assert.Equal(t, "0/16B1970", lsn)
+	assert.Equal(t, "00000003-00000002-1", snapshot)
 
-	mockStore.AssertExpectations(t)
+	_, _, _, err = parseSlotOutput("ERROR: permission denied\n")
+	require.Error(t, err)
 }
 
-func TestDumpster_ListDumps_StorageError(t *testing.T) {
-	cfg := &config.Config{}
-	mockStore := storage.NewMockStorageIface(t)
-	mockExec := exec.NewMockExecIface(t)
-
-	dumpster := NewDumpster(cfg, mockStore, mockExec)
-
-	// Mock storage error
-	mockStore.On("List").Return(nil, errors.New("storage connection failed"))
-
-	dumps, err := dumpster.ListDumps(context.Background())
-
-	require.Error(t, err)
-	require.Nil(t, dumps)
-	assert.Contains(t, err.Error(), "storage connection failed")
+// mockSlotSession expects the psql replication session of openReplicationSlot and runs session on
+// its input FIFO, stdout and stderr in place of psql. psql then exits with runErr.
+func mockSlotSession(t *testing.T, mockExec *exec.MockExecIface, dir string, runErr error, session func(input, stdout, stderr *os.File)) {
+	t.Helper()
+	mockCmd := exec.NewMockCmdIface(t)
 
-	mockStore.AssertExpectations(t)
+	var argv []string
+	var stdout, stderr *os.File
+	mockExec.On("Command", mock.Anything, "psql", mock.Anything).Run(func(args mock.Arguments) {
+		argv = args.Get(2).([]string) //nolint:errcheck // reason: Command is always called with []string args
+	}).Return(mockCmd).Once()
+	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithDir", dir).Return(mockCmd)
+	mockCmd.On("WithStdout", mock.Anything).Run(func(args mock.Arguments) {
+		stdout = args.Get(0).(*os.File) //nolint:errcheck // reason: WithStdout always takes *os.File
+	}).Return(mockCmd)
+	mockCmd.On("WithStderr", mock.Anything).Run(func(args mock.Arguments) {
+		stderr = args.Get(0).(*os.File) //nolint:errcheck // reason: WithStderr always takes *os.File
+	}).Return(mockCmd)
+
+	mockCmd.On("Run").Run(func(mock.Arguments) {
+		assert.Contains(t, argv, "--dbname=dbname='app' replication=database")
+		input, err := os.Open(strings.TrimPrefix(argv[3], "--file="))
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer func() {
+			_ = input.Close()
+		}()
+		session(input, stdout, stderr)
+	}).Return(runErr).Once()
 }
 
-func TestDumpster_PurgeDumps_Success(t *testing.T) {
-	cfg := &config.Config{
-		Backup: config.BackupConfig{
-			RetentionCount: 2,
-		},
+func TestPostgres_openReplicationSlot(t *testing.T) {
+	dir := t.TempDir()
+	pg, mockExec := newTestPostgres(t, &config.Config{})
+
+	var commands []string
+	ended := make(chan struct{})
+	mockSlotSession(t, mockExec, dir, nil, func(input, stdout, _ *os.File) {
+		sc := bufio.NewScanner(input)
+		if sc.Scan() {
+			commands = append(commands, sc.Text())
+			_, _ = fmt.Fprintln(stdout, "stashly_abc|0/16B1970|00000003-00000002-1|pgoutput")
+		}
+		// psql holds the session, and with it the slot, until its input ends.
+		for sc.Scan() {
+			commands = append(commands, sc.Text())
+		}
+		close(ended)
+	})
+
+	slot, err := pg.openReplicationSlot(context.Background(), "app", nil, dir)
+	require.NoError(t, err)
+	assert.Equal(t, "stashly_abc", slot.Name)
+	assert.Equal(t, "0/16B1970", slot.LSN)
+	assert.Equal(t, "00000003-00000002-1", slot.Snapshot)
+	select {
+	case <-ended:
+		t.Fatal("replication session ended before the slot was closed")
+	default:
 	}
-	mockStore := storage.NewMockStorageIface(t)
-	mockExec := exec.NewMockExecIface(t)
-
-	dumpster := NewDumpster(cfg, mockStore, mockExec)
-
-	// Mock successful storage listing
-	keys := []string{"backup-2024-01-01.tar.gz", "backup-2024-01-02.tar.gz", "backup-2024-01-03.tar.gz"}
-	mockStore.On("List").Return(keys, nil)
-	mockStore.On("TrimPrefix", keys).Return(keys)
-
-	// Mock successful deletion of old backup
-	// Note: The actual key will be transformed by datetime.SortDateTimes
-	mockStore.On("Delete", mock.Anything).Return(nil)
-
-	err := dumpster.PurgeDumps(context.Background())
 
+	require.NoError(t, slot.Close())
+	<-ended
+	require.Len(t, commands, 1)
+	assert.Regexp(t, `^CREATE_REPLICATION_SLOT stashly_\w+ TEMPORARY LOGICAL pgoutput EXPORT_SNAPSHOT;$`, commands[0])
+	assert.NoDirExists(t, slot.dir)
+	entries, err := os.ReadDir(dir)
 	require.NoError(t, err)
-
-	mockStore.AssertExpectations(t)
+	assert.Empty(t, entries)
+	mockExec.AssertExpectations(t)
 }
 
-func TestDumpster_PurgeDumps_NoDeletionNeeded(t *testing.T) {
-	cfg := &config.Config{
-		Backup: config.BackupConfig{
-			RetentionCount: 3,
-		},
-	}
-	mockStore := storage.NewMockStorageIface(t)
-	mockExec := exec.NewMockExecIface(t)
-
-	dumpster := NewDumpster(cfg, mockStore, mockExec)
+func TestPostgres_openReplicationSlot_Error(t *testing.T) {
+	dir := t.TempDir()
+	pg, mockExec := newTestPostgres(t, &config.Config{})
 
-	// Mock storage listing with fewer keys than retention count
-	keys := []string{"backup-2024-01-01.tar.gz", "backup-2024-01-02.tar.gz"}
-	mockStore.On("List").Return(keys, nil)
-	mockStore.On("TrimPrefix", keys).Return(keys)
+	mockSlotSession(t, mockExec, dir, errors.New("exit status 3"), func(_, _, stderr *os.File) {
+		_, _ = fmt.Fprintln(stderr, "psql: ERROR:  permission denied to create replication slot")
+	})
 
-	err := dumpster.PurgeDumps(context.Background())
-
-	require.NoError(t, err)
-
-	mockStore.AssertExpectations(t)
+	slot, err := pg.openReplicationSlot(context.Background(), "app", nil, dir)
+	require.Error(t, err)
+	assert.Nil(t, slot)
+	assert.ErrorContains(t, err, "exit status 3: psql: ERROR:  permission denied to create replication slot")
+	mockExec.AssertExpectations(t)
 }
 
-func TestDumpster_PurgeDumps_DeleteError(t *testing.T) {
-	cfg := &config.Config{
-		Backup: config.BackupConfig{
-			RetentionCount: 2,
-		},
+func TestValidatePlainDump(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "complete", content: "CREATE TABLE t ();\n\n--\n-- PostgreSQL database dump complete\n--\n\n"},
+		{name: "empty", content: "", wantErr: "empty"},
+		{name: "truncated", content: "CREATE TABLE t ();\nCOPY t FROM stdin;\n1\n", wantErr: "truncated"},
 	}
-	mockStore := storage.NewMockStorageIface(t)
-	mockExec := exec.NewMockExecIface(t)
-
-	dumpster := NewDumpster(cfg, mockStore, mockExec)
-
-	// Mock successful storage listing
-	keys := []string{"backup-2024-01-01.tar.gz", "backup-2024-01-02.tar.gz", "backup-2024-01-03.tar.gz"}
-	mockStore.On("List").Return(keys, nil)
-	mockStore.On("TrimPrefix", keys).Return(keys)
-
-	// Mock failed deletion
-	// Note: The actual key will be transformed by datetime.SortDateTimes
-	mockStore.On("Delete", mock.Anything).Return(errors.New("delete failed"))
 
-	err := dumpster.PurgeDumps(context.Background())
-
-	require.Error(t, err)
-	assert.Contains(t, err.Error(), "error deleting backup")
-
-	mockStore.AssertExpectations(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "db1.sql")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0600))
+
+			err := validatePlainDump(path)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
 }
 
-func TestDumpster_Dump_Success(t *testing.T) {
-	cfg := &config.Config{
-		Backup: config.BackupConfig{
-			Encrypt: false,
+func TestPostgres_createDatabaseSQL(t *testing.T) {
+	info := manifest.DatabaseInfo{Owner: "app", Encoding: "UTF8", Collate: "en_US.UTF-8", CType: "en_US.UTF-8"}
+
+	tests := []struct {
+		name       string
+		template   string
+		info       manifest.DatabaseInfo
+		ownerFound string
+		want       string
+	}{
+		{
+			name: "no inventory",
+			want: `CREATE DATABASE "db1";`,
+		},
+		{
+			name:       "inventory",
+			info:       info,
+			ownerFound: "1\n",
+			want:       `CREATE DATABASE "db1" TEMPLATE "template0" OWNER "app" ENCODING 'UTF8' LC_COLLATE 'en_US.UTF-8' LC_CTYPE 'en_US.UTF-8';`,
+		},
+		{
+			name:       "configured template, missing owner",
+			template:   "base",
+			info:       info,
+			ownerFound: "\n",
+			want:       `CREATE DATABASE "db1" TEMPLATE "base" ENCODING 'UTF8' LC_COLLATE 'en_US.UTF-8' LC_CTYPE 'en_US.UTF-8';`,
 		},
 	}
-	mockStore := storage.NewMockStorageIface(t)
-	mockExec := exec.NewMockExecIface(t)
-	mockCmd := exec.NewMockCmdIface(t)
-
-	dumpster := NewDumpster(cfg, mockStore, mockExec)
 
-	// Mock successful pre-checks
-	mockExec.On("LookPath", "psql").Return("/usr/bin/psql", nil)
-	mockExec.On("LookPath", "pg_dump").Return("/usr/bin/pg_dump", nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pg, mockExec := newTestPostgres(t, &config.Config{Restore: config.RestoreConfig{Template: tt.template}})
+			if tt.ownerFound != "" {
+				mockCmd := exec.NewMockCmdIface(t)
+				mockExec.On("Command", mock.Anything, "psql", mock.Anything).Return(mockCmd)
+				mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
+				mockCmd.On("WithDir", "/tmp/work").Return(mockCmd)
+				mockCmd.On("Output").Return([]byte(tt.ownerFound), nil)
+			}
+
+			got, err := pg.createDatabaseSQL(context.Background(), RestoreTarget{Database: "db1", Info: tt.info}, nil, "/tmp/work")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
 
-	// Mock successful database listing
-	mockExec.On("Command", mock.Anything, "psql", mock.Anything).Return(mockCmd)
-	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
-	mockCmd.On("WithDir", dumpster.backupLocation).Return(mockCmd)
-	mockCmd.On("WithStderr", os.Stderr).Return(mockCmd)
-	mockCmd.On("Output").Return([]byte("db1\n"), nil)
+func TestPostgres_dumpRoles_FallsBackWithoutPasswords(t *testing.T) {
+	pg, mockExec := newTestPostgres(t, &config.Config{Postgres: config.PostgresConfig{PreserveOwners: true}})
+	mockCmd := exec.NewMockCmdIface(t)
 
-	// Mock successful pg_dump
-	mockExec.On("Command", mock.Anything, "pg_dump", mock.Anything).Return(mockCmd)
+	outFile := filepath.Join("/tmp/work", rolesFile)
+	mockExec.On("Command", mock.Anything, "pg_dumpall", []string{"--roles-only", "--file=" + outFile}).Return(mockCmd).Once()
+	mockExec.On("Command", mock.Anything, "pg_dumpall", []string{"--roles-only", "--no-role-passwords", "--file=" + outFile}).Return(mockCmd).Once()
 	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
-	mockCmd.On("WithDir", dumpster.backupLocation).Return(mockCmd)
-	mockCmd.On("WithStderr", os.Stderr).Return(mockCmd)
-	mockCmd.On("CombinedOutput").Return([]byte(""), nil)
+	mockCmd.On("WithDir", "/tmp/work").Return(mockCmd)
+	mockCmd.On("CombinedOutput").Return([]byte("permission denied for table pg_authid"), errors.New("exit status 1")).Once()
+	mockCmd.On("CombinedOutput").Return([]byte(""), nil).Once()
 
-	// Mock successful storage upload
-	mockStore.On("Name").Return("test-storage")
-	mockStore.On("Upload", mock.Anything).Return("backup-2024-01-01.tar.gz", nil)
+	require.NoError(t, pg.dumpRoles(context.Background(), nil, "/tmp/work"))
+	mockExec.AssertExpectations(t)
+}
 
-	// Mock successful purge
-	keys := []string{"backup-2024-01-01.tar.gz"}
-	mockStore.On("List").Return(keys, nil)
-	mockStore.On("TrimPrefix", keys).Return(keys)
-	mockStore.On("Delete", mock.Anything).Return(nil)
+func TestPostgres_RestoreGlobals(t *testing.T) {
+	dumpDir := t.TempDir()
 
-	resp, err := dumpster.Dump(context.Background())
+	// Without a roles file there is nothing to do.
+	pg, _ := newTestPostgres(t, &config.Config{})
+	require.NoError(t, pg.RestoreGlobals(context.Background(), dumpDir, "/tmp/work"))
 
-	require.NoError(t, err)
-	require.NotNil(t, resp)
-	assert.Equal(t, 1, resp.TotalDatabases)
-	assert.Equal(t, 1, resp.ExportedDatabases)
+	require.NoError(t, os.WriteFile(filepath.Join(dumpDir, rolesFile), []byte("CREATE ROLE app;\n"), 0600))
+	pg, mockExec := newTestPostgres(t, &config.Config{})
+	mockCmd := exec.NewMockCmdIface(t)
+	mockExec.On("Command", mock.Anything, "psql", []string{"--dbname=postgres", "--file=" + filepath.Join(dumpDir, rolesFile)}).Return(mockCmd)
+	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithDir", "/tmp/work").Return(mockCmd)
+	mockCmd.On("CombinedOutput").Return([]byte("CREATE ROLE\n"), nil)
 
+	require.NoError(t, pg.RestoreGlobals(context.Background(), dumpDir, "/tmp/work"))
 	mockExec.AssertExpectations(t)
-	mockCmd.AssertExpectations(t)
-	mockStore.AssertExpectations(t)
-
-	// Cleanup
-	_ = os.RemoveAll(dumpster.backupLocation)
 }
 
-func TestDumpster_Dump_CreateDumpError(t *testing.T) {
-	cfg := &config.Config{}
-	mockStore := storage.NewMockStorageIface(t)
-	mockExec := exec.NewMockExecIface(t)
-
-	dumpster := NewDumpster(cfg, mockStore, mockExec)
+func TestIsTransientDumpError(t *testing.T) {
+	assert.True(t, isTransientDumpError(errors.New("exit status 1: pg_dump: error: Dumping the contents of table \"orders\" failed: server closed the connection unexpectedly")))
+	assert.True(t, isTransientDumpError(errors.New("exit status 1: ERROR:  canceling statement due to lock timeout")))
+	assert.False(t, isTransientDumpError(errors.New("exit status 1: ERROR:  permission denied for table orders")))
+	assert.False(t, isTransientDumpError(errors.New("exit status 1: connection to server at \"db\" failed: FATAL:  password authentication failed")))
+	assert.False(t, isTransientDumpError(errors.New("dump is empty")))
+}
 
-	// Mock failed pre-checks
-	mockExec.On("LookPath", "psql").Return("", errors.New("binary not found"))
+func TestPostgres_retryFailed(t *testing.T) {
+	dir := t.TempDir()
+	pg, mockExec := newTestPostgres(t, &config.Config{Backup: config.BackupConfig{DatabaseRetries: 2}})
+	psqlCmd := exec.NewMockCmdIface(t)
+	dumpCmd := exec.NewMockCmdIface(t)
+
+	// Only app is retried: its dump now succeeds. billing failed for good.
+	mockExec.On("Command", mock.Anything, "psql", mock.Anything).Return(psqlCmd)
+	psqlCmd.On("WithEnv", mock.Anything).Return(psqlCmd)
+	psqlCmd.On("WithDir", dir).Return(psqlCmd)
+	psqlCmd.On("Output").Return([]byte(""), nil)
+	mockExec.On("Command", mock.Anything, "pg_dump", mock.Anything).Return(dumpCmd).Once()
+	dumpCmd.On("WithEnv", mock.Anything).Return(dumpCmd)
+	dumpCmd.On("WithDir", dir).Return(dumpCmd)
+	dumpCmd.On("CombinedOutput").Run(func(mock.Arguments) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "app"+pg.Extension()), []byte("CREATE TABLE t();\n"+plainDumpTrailer+"\n"), 0600))
+	}).Return([]byte(""), nil).Once()
+
+	permanent := errors.New("exit status 1: ERROR:  permission denied for table orders")
+	failed := map[string]error{
+		"app":     errors.New("exit status 1: pg_dump: error: connection to server was lost: connection reset by peer"),
+		"billing": permanent,
+	}
+	result := &ExportResult{
+		Databases:   []string{"crm"},
+		Skipped:     map[string]string{},
+		Extensions:  map[string][]string{},
+		SnapshotLSN: map[string]string{},
+		Inventory:   map[string]manifest.DatabaseInfo{},
+	}
 
-	resp, err := dumpster.Dump(context.Background())
-
-	require.Error(t, err)
-	require.Nil(t, resp)
-	assert.Contains(t, err.Error(), "psql not found in PATH")
+	pg.retryFailed(context.Background(), []string{"app", "billing", "crm"}, failed, dir, nil, nil, result)
 
+	assert.Equal(t, map[string]error{"billing": permanent}, failed)
+	assert.Equal(t, []string{"crm", "app"}, result.Databases)
 	mockExec.AssertExpectations(t)
 }
 
-func TestDumpster_Dump_PurgeError(t *testing.T) {
-	cfg := &config.Config{
-		Backup: config.BackupConfig{
-			Encrypt: false,
-		},
+func TestPostgres_orderDatabases(t *testing.T) {
+	databases := []string{"app", "audit", "billing", "crm"}
+	sizes := "app|300\naudit|100\nbilling|400\ncrm|200\n"
+
+	tests := []struct {
+		name     string
+		order    string
+		priority []string
+		want     []string
+	}{
+		{name: "server order", want: databases},
+		{name: "largest first", order: config.OrderLargestFirst, want: []string{"billing", "app", "crm", "audit"}},
+		{name: "smallest first", order: config.OrderSmallestFirst, want: []string{"audit", "crm", "app", "billing"}},
+		{name: "priority", priority: []string{"crm", "missing", "audit"}, want: []string{"crm", "audit", "app", "billing"}},
+		{name: "priority then size", order: config.OrderLargestFirst, priority: []string{"audit"}, want: []string{"audit", "billing", "app", "crm"}},
 	}
-	mockStore := storage.NewMockStorageIface(t)
-	mockExec := exec.NewMockExecIface(t)
-	mockCmd := exec.NewMockCmdIface(t)
-
-	dumpster := NewDumpster(cfg, mockStore, mockExec)
 
-	// Mock successful pre-checks
-	mockExec.On("LookPath", "psql").Return("/usr/bin/psql", nil)
-	mockExec.On("LookPath", "pg_dump").Return("/usr/bin/pg_dump", nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pg, mockExec := newTestPostgres(t, &config.Config{Backup: config.BackupConfig{Order: tt.order, Priority: tt.priority}})
+			if tt.order != "" {
+				mockCmd := exec.NewMockCmdIface(t)
+				mockExec.On("Command", mock.Anything, "psql", mock.Anything).Return(mockCmd)
+				mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
+				mockCmd.On("WithDir", "/tmp/work").Return(mockCmd)
+				mockCmd.On("Output").Return([]byte(sizes), nil)
+			}
+
+			assert.Equal(t, tt.want, pg.orderDatabases(context.Background(), databases, nil, "/tmp/work"))
+		})
+	}
+}
 
-	// Mock successful database listing
+func TestPostgres_orderDatabases_SizesFail(t *testing.T) {
+	pg, mockExec := newTestPostgres(t, &config.Config{Backup: config.BackupConfig{Order: config.OrderLargestFirst}})
+	mockCmd := exec.NewMockCmdIface(t)
 	mockExec.On("Command", mock.Anything, "psql", mock.Anything).Return(mockCmd)
 	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
-	mockCmd.On("WithDir", dumpster.backupLocation).Return(mockCmd)
-	mockCmd.On("WithStderr", os.Stderr).Return(mockCmd)
-	mockCmd.On("Output").Return([]byte("db1\n"), nil)
-
-	// Mock successful pg_dump
-	mockExec.On("Command", mock.Anything, "pg_dump", mock.Anything).Return(mockCmd)
-	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
-	mockCmd.On("WithDir", dumpster.backupLocation).Return(mockCmd)
-	mockCmd.On("CombinedOutput").Return([]byte(""), nil)
-
-	// Mock successful storage upload
-	mockStore.On("Name").Return("test-storage")
-	mockStore.On("Upload", mock.Anything).Return("backup-2024-01-01.tar.gz", nil)
-
-	// Mock failed purge
-	mockStore.On("List").Return(nil, errors.New("storage error"))
-
-	resp, err := dumpster.Dump(context.Background())
-
-	require.Error(t, err)
-	require.Nil(t, resp)
-	assert.Contains(t, err.Error(), "storage error")
-
-	mockExec.AssertExpectations(t)
-	mockCmd.AssertExpectations(t)
-	mockStore.AssertExpectations(t)
+	mockCmd.On("WithDir", "/tmp/work").Return(mockCmd)
+	mockCmd.On("Output").Return([]byte(nil), errors.New("exit status 2"))
 
-	// Cleanup
-	_ = os.RemoveAll(dumpster.backupLocation)
+	databases := []string{"app", "billing"}
+	assert.Equal(t, databases, pg.orderDatabases(context.Background(), databases, nil, "/tmp/work"))
 }
@@ -0,0 +1,54 @@
+package dumpster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+)
+
+// ErrNoPrimary is returned when none of postgres.nodes is a reachable primary.
+var ErrNoPrimary = errors.New("no primary found among postgres.nodes")
+
+// primaryProbeTimeout bounds, in seconds, how long the primary check waits for each node, so a node
+// that is down doesn't stall the run.
+const primaryProbeTimeout = "5"
+
+// splitNode splits a "host:port" node; the port defaults to postgres.port.
+func splitNode(node, defaultPort string) (string, string) {
+	host, port, err := net.SplitHostPort(node)
+	if err != nil {
+		return node, defaultPort
+	}
+	return host, port
+}
+
+// findPrimary points the engine at the node of postgres.nodes that is not in recovery. Nodes are
+// checked in order on every call, so a failover between runs is followed. It is a no-op without nodes.
+func (p *Postgres) findPrimary(ctx context.Context, dir string) error {
+	if len(p.cfg.Postgres.Nodes) == 0 {
+		return nil
+	}
+
+	failures := []string{}
+	for _, node := range p.cfg.Postgres.Nodes {
+		host, port := splitNode(node, p.cfg.Postgres.Port)
+		envVars := append(p.connEnvVars(host, port), "PGCONNECT_TIMEOUT="+primaryProbeTimeout)
+
+		out, err := p.psqlQuery(ctx, "postgres", "SELECT pg_is_in_recovery();", envVars, dir)
+		if err != nil {
+			slog.WarnContext(ctx, "Postgres node unreachable", "node", node, "error", err)
+			failures = append(failures, fmt.Sprintf("%s: %v", node, err))
+			continue
+		}
+		if out == "f" {
+			slog.DebugContext(ctx, "Using primary postgres node", "node", node)
+			p.host, p.port = host, port
+			return nil
+		}
+		failures = append(failures, node+": in recovery")
+	}
+	return fmt.Errorf("%w (%s)", ErrNoPrimary, strings.Join(failures, "; "))
+}
@@ -0,0 +1,92 @@
+package dumpster
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/hibare/stashly/internal/manifest"
+	"github.com/hibare/stashly/internal/version"
+)
+
+// openPGPModule is the library archives are encrypted with; GPG itself isn't run.
+const openPGPModule = "github.com/ProtonMail/go-crypto"
+
+// unknownVersion is recorded for a tool that was used but didn't report its version.
+const unknownVersion = "unknown"
+
+// toolVersion returns the first line name prints for --version.
+func (d *Dumpster) toolVersion(ctx context.Context, name string) string {
+	out, err := d.exec.Command(ctx, name, "--version").Output()
+	if err != nil {
+		slog.DebugContext(ctx, "Failed to read tool version", "tool", name, "error", err)
+		return unknownVersion
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	if line == "" {
+		return unknownVersion
+	}
+	return line
+}
+
+// moduleVersion returns the version of module linked into the binary.
+func moduleVersion(module string) string {
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range bi.Deps {
+			if dep.Path == module {
+				return dep.Version
+			}
+		}
+	}
+	return unknownVersion
+}
+
+// configHash returns the hex SHA-256 of the redacted configuration.
+func (d *Dumpster) configHash() string {
+	data, err := json.Marshal(d.cfg.Redacted())
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// provenance describes the binary, tools, host and configuration taking this backup. compressor is
+// the external compressor used, or "" for in-process zip. The engine's tools are left out of imported
+// backups, whose dumps were made elsewhere.
+func (d *Dumpster) provenance(ctx context.Context, compressor string, imported bool) *manifest.Provenance {
+	info := version.Info()
+	p := &manifest.Provenance{
+		Stashly:    info.Version,
+		Commit:     info.Commit,
+		GoVersion:  info.GoVersion,
+		Tools:      map[string]string{},
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		ConfigHash: d.configHash(),
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		p.Hostname = hostname
+	}
+
+	if !imported {
+		for _, bin := range d.engine.Binaries() {
+			p.Tools[bin] = d.toolVersion(ctx, bin)
+		}
+	}
+	if compressor != "" {
+		p.Tools[compressor] = d.toolVersion(ctx, compressor)
+	} else {
+		p.Tools["zip"] = "archive/zip " + info.GoVersion
+	}
+	if d.cfg.Backup.Encrypt {
+		p.Tools["openpgp"] = openPGPModule + " " + moduleVersion(openPGPModule)
+	}
+	return p
+}
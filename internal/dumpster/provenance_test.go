@@ -0,0 +1,60 @@
+package dumpster
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpster_provenance(t *testing.T) {
+	cfg := &config.Config{Backup: config.BackupConfig{Encrypt: true}}
+	mockExec := exec.NewMockExecIface(t)
+	d, err := NewDumpster(cfg, storage.NewMockStorageIface(t), mockExec)
+	require.NoError(t, err)
+
+	psql := exec.NewMockCmdIface(t)
+	psql.On("Output").Return([]byte("psql (PostgreSQL) 16.2\n"), nil)
+	pgDump := exec.NewMockCmdIface(t)
+	pgDump.On("Output").Return([]byte(""), errors.New("exit status 1"))
+	pigz := exec.NewMockCmdIface(t)
+	pigz.On("Output").Return([]byte("pigz 2.8\n"), nil)
+	mockExec.On("Command", mock.Anything, "psql", []string{"--version"}).Return(psql)
+	mockExec.On("Command", mock.Anything, "pg_dump", []string{"--version"}).Return(pgDump)
+	mockExec.On("Command", mock.Anything, "pigz", []string{"--version"}).Return(pigz)
+
+	p := d.provenance(context.Background(), "pigz", false)
+	assert.Equal(t, runtime.GOOS, p.OS)
+	assert.Equal(t, runtime.Version(), p.GoVersion)
+	assert.Equal(t, "psql (PostgreSQL) 16.2", p.Tools["psql"])
+	assert.Equal(t, unknownVersion, p.Tools["pg_dump"])
+	assert.Equal(t, "pigz 2.8", p.Tools["pigz"])
+	assert.Contains(t, p.Tools["openpgp"], openPGPModule)
+	assert.Len(t, p.ConfigHash, 64)
+
+	// Imported dumps weren't made by the engine's tools, so only the compressor is recorded.
+	p = d.provenance(context.Background(), "", true)
+	assert.NotContains(t, p.Tools, "pg_dump")
+	assert.Contains(t, p.Tools, "zip")
+}
+
+func TestDumpster_configHash_IgnoresCredentials(t *testing.T) {
+	cfg := &config.Config{Backup: config.BackupConfig{RetentionCount: 7}}
+	cfg.Postgres.Password = "one"
+	d, err := NewDumpster(cfg, storage.NewMockStorageIface(t), exec.NewMockExecIface(t))
+	require.NoError(t, err)
+	before := d.configHash()
+
+	cfg.Postgres.Password = "two"
+	assert.Equal(t, before, d.configHash())
+
+	cfg.Backup.RetentionCount = 8
+	assert.NotEqual(t, before, d.configHash())
+}
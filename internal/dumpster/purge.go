@@ -0,0 +1,53 @@
+package dumpster
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var (
+	// ErrPurgeNewest is returned when a purge would delete the newest complete backup. Retention never
+	// picks it, so this points at a bug such as mis-sorted timestamps or a wrong prefix.
+	ErrPurgeNewest = errors.New("purge would delete the newest backup")
+
+	// ErrPurgeTooMany is returned when a purge would delete a larger share of the backups than
+	// backup.purge-max-percent without PurgeOptions.Force.
+	ErrPurgeTooMany = errors.New("purge would delete too many backups; run stashly purge --force if this is intended")
+)
+
+// PurgeOptions controls PurgeDumps.
+type PurgeOptions struct {
+	// Force allows deleting more than backup.purge-max-percent of the backups in one run, e.g. after
+	// lowering retention-count. The newest backup is protected regardless.
+	Force bool
+}
+
+// newestComplete returns the timestamp of the newest backup with a manifest, or of the newest backup
+// if none has one (e.g. they all predate manifests). The manifest is uploaded last, so a backup
+// without one may be incomplete.
+func newestComplete(backups []BackupInfo) string {
+	for _, b := range backups {
+		if b.Manifest != nil {
+			return b.Timestamp
+		}
+	}
+	if len(backups) > 0 {
+		return backups[0].Timestamp
+	}
+	return ""
+}
+
+// checkPurge checks a purge of keys out of backups, newest first, against invariants retention
+// should never break, as a last line of defence against bugs in choosing what to delete.
+func (d *Dumpster) checkPurge(backups []BackupInfo, keys []string, opts PurgeOptions) error {
+	if newest := newestComplete(backups); slices.Contains(keys, newest) {
+		return fmt.Errorf("%w: %s", ErrPurgeNewest, newest)
+	}
+
+	limit := d.cfg.Backup.PurgeMaxPercent
+	if !opts.Force && limit > 0 && len(keys)*100 > limit*len(backups) {
+		return fmt.Errorf("%w: %d of %d backups, over backup.purge-max-percent (%d%%)", ErrPurgeTooMany, len(keys), len(backups), limit)
+	}
+	return nil
+}
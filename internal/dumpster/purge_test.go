@@ -0,0 +1,60 @@
+package dumpster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/manifest"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewestComplete(t *testing.T) {
+	assert.Empty(t, newestComplete(nil))
+	assert.Equal(t, "20250102000000", newestComplete([]BackupInfo{{Timestamp: "20250102000000"}, {Timestamp: "20250101000000"}}))
+	// A newer backup without a manifest may be incomplete.
+	assert.Equal(t, "20250101000000", newestComplete([]BackupInfo{
+		{Timestamp: "20250102000000"},
+		{Timestamp: "20250101000000", Manifest: &manifest.Manifest{}},
+	}))
+}
+
+func TestDumpster_CheckPurge(t *testing.T) {
+	d := &Dumpster{cfg: &config.Config{Backup: config.BackupConfig{PurgeMaxPercent: 50}}}
+	backups := []BackupInfo{
+		{Timestamp: "20250104000000"}, {Timestamp: "20250103000000"},
+		{Timestamp: "20250102000000"}, {Timestamp: "20250101000000"},
+	}
+
+	require.NoError(t, d.checkPurge(backups, []string{"20250102000000", "20250101000000"}, PurgeOptions{}))
+	require.ErrorIs(t, d.checkPurge(backups, []string{"20250103000000", "20250102000000", "20250101000000"}, PurgeOptions{}), ErrPurgeTooMany)
+	require.NoError(t, d.checkPurge(backups, []string{"20250103000000", "20250102000000", "20250101000000"}, PurgeOptions{Force: true}))
+
+	// The newest backup is protected even when forced.
+	require.ErrorIs(t, d.checkPurge(backups, []string{"20250104000000"}, PurgeOptions{Force: true}), ErrPurgeNewest)
+
+	d.cfg.Backup.PurgeMaxPercent = 0
+	require.NoError(t, d.checkPurge(backups, []string{"20250103000000", "20250102000000", "20250101000000"}, PurgeOptions{}))
+}
+
+func TestDumpster_PurgeDumps_IgnoresUnknownKeys(t *testing.T) {
+	cfg := &config.Config{Backup: config.BackupConfig{RetentionCount: 1}}
+	mockStore := storage.NewMockStorageIface(t)
+	dumpster, err := NewDumpster(cfg, mockStore, exec.NewMockExecIface(t))
+	require.NoError(t, err)
+
+	// Keys that aren't timestamps used to sort as the zero time and be purged as the oldest.
+	keys := []string{"backup-a", "20250101000000", "backup-b", "20250102000000"}
+	mockStore.On("List").Return(keys, nil)
+	mockStore.On("TrimPrefix", keys).Return(keys)
+	mockStore.On("ListFiles", mock.Anything).Return([]string{}, nil)
+	mockStore.On("Delete", "20250101000000").Return(nil).Once()
+
+	deleted, err := dumpster.PurgeDumps(context.Background(), PurgeOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20250101000000"}, deleted)
+}
@@ -0,0 +1,66 @@
+package dumpster
+
+import (
+	"archive/zip"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/hibare/stashly/internal/manifest"
+)
+
+// compressionRatio returns raw divided by compressed, rounded to two decimals; 0 if nothing was compressed.
+func compressionRatio(raw, compressed int64) float64 {
+	if raw <= 0 || compressed <= 0 {
+		return 0
+	}
+	return math.Round(float64(raw)/float64(compressed)*100) / 100
+}
+
+// rawSize returns the total size of the files in the backup location, before they are archived.
+func (d *Dumpster) rawSize() int64 {
+	var total int64
+	_ = walkFiles(d.backupLocation, func(_, _ string, info os.FileInfo) error {
+		total += info.Size()
+		return nil
+	})
+	return total
+}
+
+// compressionStats measures how well raw bytes of dumps, sizes of them per database, compressed into
+// the archive at archivePath.
+func (d *Dumpster) compressionStats(archivePath string, raw int64, sizes map[string]int64) *manifest.Compression {
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return nil
+	}
+	c := &manifest.Compression{
+		RawSize:        raw,
+		CompressedSize: info.Size(),
+		Ratio:          compressionRatio(raw, info.Size()),
+		Databases:      make(map[string]manifest.DatabaseCompression, len(sizes)),
+	}
+	for db, size := range sizes {
+		c.Databases[db] = manifest.DatabaseCompression{RawSize: size}
+	}
+
+	if !strings.HasSuffix(archivePath, ".zip") {
+		return c
+	}
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return c
+	}
+	defer func() {
+		_ = zr.Close()
+	}()
+	for _, f := range zr.File {
+		db, ok := strings.CutSuffix(f.Name, d.engine.Extension())
+		if dc, found := c.Databases[db]; ok && found {
+			dc.CompressedSize = int64(f.CompressedSize64) //nolint:gosec // reason: archive entries are far below 2^63 bytes
+			dc.Ratio = compressionRatio(dc.RawSize, dc.CompressedSize)
+			c.Databases[db] = dc
+		}
+	}
+	return c
+}
@@ -0,0 +1,57 @@
+package dumpster
+
+import (
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressionRatio(t *testing.T) {
+	assert.InDelta(t, 4.0, compressionRatio(400, 100), 0)
+	assert.InDelta(t, 1.33, compressionRatio(4, 3), 0)
+	assert.Zero(t, compressionRatio(0, 100))
+	assert.Zero(t, compressionRatio(100, 0))
+}
+
+func TestDumpster_CompressionStats(t *testing.T) {
+	d, err := NewDumpster(&config.Config{}, storage.NewMockStorageIface(t), exec.NewMockExecIface(t))
+	require.NoError(t, err)
+	d.backupLocation = t.TempDir()
+
+	// Repetitive text compresses well; random bytes, like data encrypted at the source, don't.
+	text := []byte(strings.Repeat("INSERT INTO t VALUES (1, 'stashly');\n", 1000))
+	random := make([]byte, 32<<10)
+	_, _ = rand.Read(random)
+	require.NoError(t, os.WriteFile(filepath.Join(d.backupLocation, "app.sql"), text, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(d.backupLocation, "vault.sql"), random, 0o600))
+
+	sizes := d.dumpSizes([]string{"app", "vault"})
+	raw := d.rawSize()
+	assert.Equal(t, int64(len(text)+len(random)), raw)
+
+	archive := filepath.Join(t.TempDir(), "db_exports.zip")
+	require.NoError(t, writeZip(d.backupLocation, archive))
+
+	c := d.compressionStats(archive, raw, sizes)
+	require.NotNil(t, c)
+	assert.Equal(t, raw, c.RawSize)
+	assert.Greater(t, c.Ratio, 1.0)
+	assert.Greater(t, c.Databases["app"].Ratio, 10.0)
+	assert.Less(t, c.Databases["vault"].Ratio, 1.1)
+	assert.Equal(t, int64(len(random)), c.Databases["vault"].RawSize)
+
+	// Tarballs are compressed as a whole, so databases only get raw sizes.
+	tarball := filepath.Join(t.TempDir(), "db_exports.tar.gz")
+	require.NoError(t, os.WriteFile(tarball, []byte("compressed"), 0o600))
+	c = d.compressionStats(tarball, raw, sizes)
+	assert.Equal(t, int64(len(text)), c.Databases["app"].RawSize)
+	assert.Zero(t, c.Databases["app"].CompressedSize)
+}
@@ -0,0 +1,389 @@
+package dumpster
+
+import (
+	"archive/zip"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hibare/stashly/internal/manifest"
+)
+
+var (
+	// ErrNoDumps is returned when no backups exist in storage.
+	ErrNoDumps = errors.New("no backups found")
+
+	// ErrNoArchive is returned when a backup contains no archive to restore from.
+	ErrNoArchive = errors.New("no backup archive found")
+
+	// ErrTargetNotEmpty is returned when a restore would overwrite existing tables and neither
+	// RestoreOptions.Force nor RestoreOptions.DropExisting is set.
+	ErrTargetNotEmpty = errors.New("restore target is not empty")
+
+	// ErrNoPrivateKey is returned when restoring an encrypted backup without a private key configured.
+	ErrNoPrivateKey = errors.New("backup is encrypted but neither encryption.gpg.private-key-file nor encryption.gpg.agent is set")
+
+	// ErrUnsupportedFormat is returned when a backup's dump format cannot be restored by its engine.
+	ErrUnsupportedFormat = errors.New("unsupported dump format")
+)
+
+// RestoreResponse holds information about the restore operation.
+type RestoreResponse struct {
+	Timestamp  string
+	StorageKey string
+	Databases  []string
+	Duration   time.Duration
+}
+
+// RestoreOptions controls how a restore treats databases that already hold tables.
+type RestoreOptions struct {
+	// Force loads dumps into existing databases as they are.
+	Force bool
+
+	// DropExisting drops existing databases before loading their dumps.
+	DropExisting bool
+}
+
+// TargetNotEmptyError lists the existing tables a restore would overwrite, keyed by database.
+type TargetNotEmptyError struct {
+	Tables map[string][]TableStat
+}
+
+func (e *TargetNotEmptyError) Error() string {
+	databases := make([]string, 0, len(e.Tables))
+	for db := range e.Tables {
+		databases = append(databases, db)
+	}
+	sort.Strings(databases)
+	return fmt.Sprintf("%s: %s", ErrTargetNotEmpty, strings.Join(databases, ", "))
+}
+
+func (e *TargetNotEmptyError) Unwrap() error {
+	return ErrTargetNotEmpty
+}
+
+// checkTargets returns a *TargetNotEmptyError if any of databases already holds tables, unless opts allow overwriting them.
+func (d *Dumpster) checkTargets(ctx context.Context, engine Engine, databases []string, opts RestoreOptions) error {
+	if opts.Force || opts.DropExisting {
+		return nil
+	}
+
+	nonEmpty := map[string][]TableStat{}
+	for _, db := range databases {
+		tables, err := engine.TableStats(ctx, db, d.restoreLocation)
+		if err != nil {
+			return fmt.Errorf("error inspecting database %s: %w", db, err)
+		}
+		if len(tables) > 0 {
+			nonEmpty[db] = tables
+		}
+	}
+
+	if len(nonEmpty) > 0 {
+		return &TargetNotEmptyError{Tables: nonEmpty}
+	}
+	return nil
+}
+
+// IsClusterEmpty reports whether the target server holds no user data.
+func (d *Dumpster) IsClusterEmpty(ctx context.Context) (bool, error) {
+	if err := os.MkdirAll(d.restoreLocation, 0750); err != nil {
+		return false, err
+	}
+	return d.engine.IsEmpty(ctx, d.restoreLocation)
+}
+
+// LatestDump returns the timestamp of the most recent backup in storage.
+func (d *Dumpster) LatestDump(ctx context.Context) (string, error) {
+	keys, err := d.ListDumps(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(keys) == 0 {
+		return "", ErrNoDumps
+	}
+	return keys[0], nil
+}
+
+// findArchive returns the key of the (possibly encrypted) archive among a backup's files.
+func findArchive(keys []string) (string, error) {
+	for _, key := range keys {
+		name := plainName(key)
+		for _, ext := range archiveExtensions {
+			if strings.HasSuffix(name, ext) {
+				return key, nil
+			}
+		}
+	}
+	return "", ErrNoArchive
+}
+
+// restoreEngine returns the engine that restores a backup: the one recorded in its manifest, which may
+// differ from the configured engine, or the configured engine for backups without a manifest.
+func (d *Dumpster) restoreEngine(m *manifest.Manifest) (Engine, error) {
+	engine := d.engine
+	if m.Engine != "" && m.Engine != engine.Name() {
+		e, err := NewEngine(m.Engine, d.cfg, d.exec)
+		if err != nil {
+			return nil, err
+		}
+		engine = e
+	}
+
+	if m.Format != "" && m.Format != engine.Format() {
+		return nil, fmt.Errorf("%w: %s backups in %s format cannot be restored, %s restores %s dumps",
+			ErrUnsupportedFormat, m.Engine, m.Format, engine.Name(), engine.Format())
+	}
+	return engine, nil
+}
+
+// extractArchive extracts a zip archive or compressed tarball into dest and returns the dumps with
+// extension ext keyed by database name. compressor is the manifest's Compressor; when empty the format
+// is taken from the file name.
+func extractArchive(archivePath, compressor, dest, ext string) (map[string]string, error) {
+	if compressor == "" {
+		compressor = compressorOf(archivePath)
+	}
+	if compressor != "zip" {
+		return extractTar(archivePath, compressor, dest, ext)
+	}
+
+	r, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	if err := os.MkdirAll(dest, 0750); err != nil {
+		return nil, err
+	}
+
+	dumps := map[string]string{}
+	for _, f := range r.File {
+		if !filepath.IsLocal(f.Name) {
+			return nil, fmt.Errorf("refusing to extract %q outside of %s", f.Name, dest)
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		outPath := filepath.Join(dest, filepath.Base(f.Name))
+		if err := extractFile(f, outPath); err != nil {
+			return nil, err
+		}
+		if filepath.Ext(f.Name) == ext {
+			dumps[strings.TrimSuffix(filepath.Base(f.Name), ext)] = outPath
+		}
+	}
+	return dumps, nil
+}
+
+func extractFile(f *zip.File, outPath string) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+
+	out, err := os.OpenFile(outPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// decryptArchive decrypts an encrypted archive using the configured private key, or the local gpg
+// agent, and returns the decrypted path.
+func (d *Dumpster) decryptArchive(ctx context.Context, path string) (string, error) {
+	switch {
+	case d.cfg.Encryption.GPG.PrivateKeyFile != "":
+	case d.cfg.Encryption.GPG.Agent:
+		return d.decryptWithAgent(ctx, path)
+	default:
+		return "", ErrNoPrivateKey
+	}
+
+	slog.DebugContext(ctx, "Decrypting archive file", "file", path)
+	d.gpg.SetPrivateKey(d.cfg.Encryption.GPG.PrivateKeyFile)
+	return d.gpg.DecryptFile(path, d.cfg.Encryption.GPG.Passphrase)
+}
+
+// decryptWithAgent decrypts an encrypted archive with the local gpg binary, which finds the key in its
+// keyring and asks gpg-agent to unlock it, and returns the decrypted path.
+func (d *Dumpster) decryptWithAgent(ctx context.Context, path string) (string, error) {
+	if _, err := d.exec.LookPath("gpg"); err != nil {
+		return "", fmt.Errorf("encryption.gpg.agent needs gpg: %w", err)
+	}
+
+	plainPath := filepath.Join(os.TempDir(), strings.TrimSuffix(filepath.Base(path), ".gpg"))
+	slog.DebugContext(ctx, "Decrypting archive file with gpg-agent", "file", path)
+	out, err := d.exec.Command(ctx, "gpg", "--batch", "--yes", "--quiet", "--output", plainPath, "--decrypt", path).CombinedOutput()
+	if err != nil {
+		_ = os.Remove(plainPath)
+		return "", fmt.Errorf("gpg --decrypt: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return plainPath, nil
+}
+
+// Restore downloads the backup at timestamp, decrypts it if needed and loads every database dump it contains.
+// It refuses to touch databases that already hold tables unless opts say otherwise. The backup is
+// excluded from purges in this process while the restore runs.
+func (d *Dumpster) Restore(ctx context.Context, timestamp string, opts RestoreOptions) (*RestoreResponse, error) {
+	start := time.Now()
+	unlock := lockForRestore(timestamp)
+	defer unlock()
+
+	if err := os.RemoveAll(d.restoreLocation); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(d.restoreLocation, 0750); err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = os.RemoveAll(d.restoreLocation)
+	}()
+
+	files, err := d.store.ListFiles(ctx, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	m, err := d.downloadManifest(ctx, files)
+	if err != nil && !errors.Is(err, manifest.ErrNotFound) {
+		return nil, fmt.Errorf("error reading manifest: %w", err)
+	}
+	if m == nil {
+		slog.DebugContext(ctx, "Backup has no manifest", "timestamp", timestamp)
+		m = &manifest.Manifest{}
+	}
+	if m.Sealed != nil {
+		m, err = d.openSealedManifest(ctx, files, m)
+		if err != nil {
+			return nil, fmt.Errorf("error reading encrypted manifest: %w", err)
+		}
+	}
+
+	// The manifest says how the backup was produced, so the operator doesn't have to.
+	engine, err := d.restoreEngine(m)
+	if err != nil {
+		return nil, err
+	}
+	for _, bin := range engine.Binaries() {
+		if _, err := d.exec.LookPath(bin); err != nil {
+			return nil, fmt.Errorf("%s not found in PATH: %w", bin, err)
+		}
+	}
+
+	// Check the targets before downloading when the manifest says which databases the backup holds.
+	if len(m.Databases) > 0 {
+		if cErr := d.checkTargets(ctx, engine, m.Databases, opts); cErr != nil {
+			return nil, cErr
+		}
+	}
+
+	var key, localPath string
+	if len(m.Volumes) > 0 {
+		localPath = filepath.Join(d.restoreLocation, filepath.Base(m.Archive))
+		keys, vErr := d.downloadVolumes(ctx, files, m.Volumes, localPath)
+		if vErr != nil {
+			return nil, vErr
+		}
+		key = keys[0]
+	} else {
+		key, err = findArchive(files)
+		if err != nil {
+			return nil, fmt.Errorf("%w in backup %s", err, timestamp)
+		}
+
+		localPath = filepath.Join(d.restoreLocation, filepath.Base(key))
+		slog.InfoContext(ctx, "Downloading backup", "key", key, "storage", d.store.Name())
+		if dErr := d.store.Download(ctx, key, localPath); dErr != nil {
+			return nil, dErr
+		}
+	}
+
+	// Backups without a manifest are recognised as encrypted by their extension.
+	isFramed := m.Framed || strings.HasSuffix(localPath, framedExt)
+	encrypted := m.Encrypted || isFramed || strings.HasSuffix(localPath, ".gpg")
+	slog.InfoContext(ctx, "Restoring backup", "timestamp", timestamp, "engine", engine.Name(), "format", engine.Format(),
+		"compressor", cmp.Or(m.Compressor, compressorOf(plainName(localPath))), "encrypted", encrypted, "framed", isFramed)
+
+	archivePath := localPath
+	if encrypted {
+		decrypt := d.decryptArchive
+		if isFramed {
+			decrypt = d.decryptFramed
+		}
+		archivePath, err = decrypt(ctx, localPath)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = os.Remove(archivePath)
+		}()
+	}
+
+	dumpDir := filepath.Join(d.restoreLocation, "dumps")
+	dumps, err := extractArchive(archivePath, m.Compressor, dumpDir, engine.Extension())
+	if err != nil {
+		return nil, err
+	}
+
+	databases := make([]string, 0, len(dumps))
+	for db := range dumps {
+		databases = append(databases, db)
+	}
+	sort.Strings(databases)
+
+	if len(m.Databases) == 0 {
+		if cErr := d.checkTargets(ctx, engine, databases, opts); cErr != nil {
+			return nil, cErr
+		}
+	}
+
+	if gErr := engine.RestoreGlobals(ctx, dumpDir, d.restoreLocation); gErr != nil {
+		return nil, fmt.Errorf("error restoring globals: %w", gErr)
+	}
+
+	for _, db := range databases {
+		if opts.DropExisting {
+			slog.InfoContext(ctx, "Dropping existing database", "database", db)
+			if dErr := engine.DropDatabase(ctx, db, d.restoreLocation); dErr != nil {
+				return nil, fmt.Errorf("error dropping database %s: %w", db, dErr)
+			}
+		}
+
+		slog.InfoContext(ctx, "Restoring database", "database", db)
+		target := RestoreTarget{
+			Database:   db,
+			DumpFile:   dumps[db],
+			Extensions: m.Extensions[db],
+			Info:       m.Inventory[db],
+		}
+		if rErr := engine.RestoreDatabase(ctx, target, d.restoreLocation); rErr != nil {
+			return nil, fmt.Errorf("error restoring database %s: %w", db, rErr)
+		}
+	}
+
+	return &RestoreResponse{
+		Timestamp:  timestamp,
+		StorageKey: key,
+		Databases:  databases,
+		Duration:   time.Since(start),
+	}, nil
+}
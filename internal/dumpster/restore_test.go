@@ -0,0 +1,340 @@
+package dumpster
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/framed"
+	"github.com/hibare/stashly/internal/manifest"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestArchive(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, zErr := zw.Create(name)
+		require.NoError(t, zErr)
+		_, zErr = w.Write([]byte(content))
+		require.NoError(t, zErr)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+}
+
+func TestFindArchive(t *testing.T) {
+	key, err := findArchive([]string{"p/i/ts/manifest.json", "p/i/ts/db_exports.zip.gpg"})
+	require.NoError(t, err)
+	assert.Equal(t, "p/i/ts/db_exports.zip.gpg", key)
+
+	key, err = findArchive([]string{"p/i/ts/manifest.json", "p/i/ts/db_exports.tar.zst"})
+	require.NoError(t, err)
+	assert.Equal(t, "p/i/ts/db_exports.tar.zst", key)
+
+	key, err = findArchive([]string{"p/i/ts/manifest.json", "p/i/ts/db_exports.tar.gz.framed"})
+	require.NoError(t, err)
+	assert.Equal(t, "p/i/ts/db_exports.tar.gz.framed", key)
+
+	_, err = findArchive([]string{"p/i/ts/other.txt"})
+	require.ErrorIs(t, err, ErrNoArchive)
+}
+
+func TestExtractArchive(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "db_exports.zip")
+	writeTestArchive(t, archive, map[string]string{"db1.sql": "SELECT 1;", "notes.txt": "x"})
+
+	dumps, err := extractArchive(archive, "", filepath.Join(dir, "out"), ".sql")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"db1": filepath.Join(dir, "out", "db1.sql")}, dumps)
+}
+
+func TestExtractArchive_RejectsTraversal(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "db_exports.zip")
+	writeTestArchive(t, archive, map[string]string{"../evil.sql": "DROP TABLE x;"})
+
+	_, err := extractArchive(archive, "", filepath.Join(dir, "out"), ".sql")
+	require.Error(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "evil.sql"))
+}
+
+func TestDumpster_LatestDump_NoDumps(t *testing.T) {
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+	dumpster, err := NewDumpster(&config.Config{}, mockStore, mockExec)
+	require.NoError(t, err)
+
+	mockStore.On("List").Return([]string{}, nil)
+
+	_, err = dumpster.LatestDump(context.Background())
+	require.ErrorIs(t, err, ErrNoDumps)
+}
+
+func TestDumpster_Restore_Success(t *testing.T) {
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+	mockCmd := exec.NewMockCmdIface(t)
+
+	dumpster, err := NewDumpster(&config.Config{}, mockStore, mockExec)
+	require.NoError(t, err)
+	dumpster.restoreLocation = filepath.Join(t.TempDir(), "restore")
+
+	key := "prefix/instance/20250101000000/db_exports.zip"
+	mockExec.On("LookPath", "psql").Return("/usr/bin/psql", nil)
+	mockExec.On("LookPath", "pg_dump").Return("/usr/bin/pg_dump", nil)
+	mockStore.On("ListFiles", "20250101000000").Return([]string{key}, nil)
+	mockStore.On("Name").Return("test-storage")
+	mockStore.On("Download", key, filepath.Join(dumpster.restoreLocation, "db_exports.zip")).
+		Run(func(args mock.Arguments) {
+			writeTestArchive(t, args.String(1), map[string]string{"db1.sql": "SELECT 1;"})
+		}).Return(nil)
+
+	mockExec.On("Command", mock.Anything, "psql", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithDir", dumpster.restoreLocation).Return(mockCmd)
+	// The target doesn't exist yet: nothing to inspect, create it, then load the dump.
+	mockCmd.On("Output").Return([]byte(""), nil)
+	mockCmd.On("CombinedOutput").Return([]byte(""), nil).Once()
+
+	resp, err := dumpster.Restore(context.Background(), "20250101000000", RestoreOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, key, resp.StorageKey)
+	assert.Equal(t, []string{"db1"}, resp.Databases)
+	assert.NoDirExists(t, dumpster.restoreLocation)
+}
+
+func TestDumpster_Restore_EncryptedWithoutKey(t *testing.T) {
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+
+	dumpster, err := NewDumpster(&config.Config{}, mockStore, mockExec)
+	require.NoError(t, err)
+	dumpster.restoreLocation = filepath.Join(t.TempDir(), "restore")
+
+	key := "prefix/instance/20250101000000/db_exports.zip.gpg"
+	mockExec.On("LookPath", "psql").Return("/usr/bin/psql", nil)
+	mockExec.On("LookPath", "pg_dump").Return("/usr/bin/pg_dump", nil)
+	mockStore.On("ListFiles", "20250101000000").Return([]string{key}, nil)
+	mockStore.On("Name").Return("test-storage")
+	mockStore.On("Download", key, mock.Anything).Return(nil)
+
+	_, err = dumpster.Restore(context.Background(), "20250101000000", RestoreOptions{})
+	require.ErrorIs(t, err, ErrNoPrivateKey)
+}
+
+func TestDumpster_Restore_Framed(t *testing.T) {
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+	mockCmd := exec.NewMockCmdIface(t)
+
+	entity, err := openpgp.NewEntity("stashly", "", "stashly@example.com", nil)
+	require.NoError(t, err)
+	cfg := &config.Config{}
+	cfg.Encryption.GPG.PrivateKeyFile = writePrivateKey(t, t.TempDir(), entity)
+	dumpster, err := NewDumpster(cfg, mockStore, mockExec)
+	require.NoError(t, err)
+	dumpster.restoreLocation = filepath.Join(t.TempDir(), "restore")
+
+	// The archive is stored framed; restore verifies and decrypts it before extracting.
+	key := "prefix/instance/20250101000000/db_exports.zip.framed"
+	mockExec.On("LookPath", mock.Anything).Return("/usr/bin/tool", nil)
+	mockStore.On("ListFiles", "20250101000000").Return([]string{key}, nil)
+	mockStore.On("Name").Return("test-storage")
+	mockStore.On("Download", key, filepath.Join(dumpster.restoreLocation, "db_exports.zip.framed")).
+		Run(func(args mock.Arguments) {
+			plain := filepath.Join(t.TempDir(), "db_exports.zip")
+			writeTestArchive(t, plain, map[string]string{"db1.sql": "SELECT 1;"})
+			data, rErr := os.ReadFile(plain)
+			require.NoError(t, rErr)
+
+			out, cErr := os.Create(args.String(1))
+			require.NoError(t, cErr)
+			w, fErr := framed.NewWriter(out, armoredEntity(t, entity))
+			require.NoError(t, fErr)
+			_, fErr = w.Write(data)
+			require.NoError(t, fErr)
+			require.NoError(t, w.Close())
+			require.NoError(t, out.Close())
+		}).Return(nil)
+
+	mockExec.On("Command", mock.Anything, "psql", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithDir", dumpster.restoreLocation).Return(mockCmd)
+	mockCmd.On("Output").Return([]byte(""), nil)
+	mockCmd.On("CombinedOutput").Return([]byte(""), nil)
+
+	resp, err := dumpster.Restore(context.Background(), "20250101000000", RestoreOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db1"}, resp.Databases)
+	assert.NoFileExists(t, filepath.Join(os.TempDir(), "db_exports.zip"))
+}
+
+func TestDumpster_DecryptArchive_Agent(t *testing.T) {
+	mockExec := exec.NewMockExecIface(t)
+	mockCmd := exec.NewMockCmdIface(t)
+
+	cfg := &config.Config{Encryption: config.Encryption{GPG: config.GPGConfig{Agent: true}}}
+	dumpster, err := NewDumpster(cfg, storage.NewMockStorageIface(t), mockExec)
+	require.NoError(t, err)
+
+	encPath := filepath.Join(t.TempDir(), "db_exports.zip.gpg")
+	plainPath := filepath.Join(os.TempDir(), "db_exports.zip")
+	mockExec.On("LookPath", "gpg").Return("/usr/bin/gpg", nil)
+	mockExec.On("Command", mock.Anything, "gpg",
+		[]string{"--batch", "--yes", "--quiet", "--output", plainPath, "--decrypt", encPath}).Return(mockCmd)
+	mockCmd.On("CombinedOutput").Return([]byte(""), nil).Once()
+
+	got, err := dumpster.decryptArchive(context.Background(), encPath)
+	require.NoError(t, err)
+	assert.Equal(t, plainPath, got)
+
+	mockCmd.On("CombinedOutput").Return([]byte("gpg: decryption failed: No secret key"), errors.New("exit status 2")).Once()
+	_, err = dumpster.decryptArchive(context.Background(), encPath)
+	require.ErrorContains(t, err, "No secret key")
+}
+
+func TestDumpster_Restore_Volumes(t *testing.T) {
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+	mockCmd := exec.NewMockCmdIface(t)
+
+	dumpster, err := NewDumpster(&config.Config{}, mockStore, mockExec)
+	require.NoError(t, err)
+	dumpster.restoreLocation = filepath.Join(t.TempDir(), "restore")
+
+	// Build the archive up front and split it the way a backup would have.
+	src := t.TempDir()
+	archive := filepath.Join(src, "db_exports.zip")
+	writeTestArchive(t, archive, map[string]string{"db1.sql": "SELECT 1;"})
+	volumes, err := splitFile(archive, src, 64)
+	require.NoError(t, err)
+	require.Greater(t, len(volumes), 1)
+
+	prefix := "prefix/instance/20250101000000/"
+	files := []string{prefix + manifest.FileName}
+	names := []string{}
+	for _, v := range volumes {
+		files = append(files, prefix+filepath.Base(v))
+		names = append(names, filepath.Base(v))
+	}
+
+	mockExec.On("LookPath", "psql").Return("/usr/bin/psql", nil)
+	mockExec.On("LookPath", "pg_dump").Return("/usr/bin/pg_dump", nil)
+	mockStore.On("ListFiles", "20250101000000").Return(files, nil)
+	mockStore.On("Name").Return("test-storage")
+	mockStore.On("Download", prefix+manifest.FileName, mock.Anything).
+		Run(func(args mock.Arguments) {
+			m := &manifest.Manifest{Archive: "db_exports.zip", Volumes: names}
+			require.NoError(t, m.Write(args.String(1)))
+		}).Return(nil)
+	for _, v := range volumes {
+		mockStore.On("Download", prefix+filepath.Base(v), mock.Anything).
+			Run(func(args mock.Arguments) {
+				data, rErr := os.ReadFile(v)
+				require.NoError(t, rErr)
+				require.NoError(t, os.WriteFile(args.String(1), data, 0600))
+			}).Return(nil)
+	}
+
+	mockExec.On("Command", mock.Anything, "psql", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithDir", dumpster.restoreLocation).Return(mockCmd)
+	// The target doesn't exist yet: nothing to inspect, create it, then load the dump.
+	mockCmd.On("Output").Return([]byte(""), nil)
+	mockCmd.On("CombinedOutput").Return([]byte(""), nil).Once()
+
+	resp, err := dumpster.Restore(context.Background(), "20250101000000", RestoreOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, prefix+names[0], resp.StorageKey)
+	assert.Equal(t, []string{"db1"}, resp.Databases)
+}
+
+func TestDumpster_Restore_TargetNotEmpty(t *testing.T) {
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+	mockCmd := exec.NewMockCmdIface(t)
+
+	dumpster, err := NewDumpster(&config.Config{}, mockStore, mockExec)
+	require.NoError(t, err)
+	dumpster.restoreLocation = filepath.Join(t.TempDir(), "restore")
+
+	prefix := "prefix/instance/20250101000000/"
+	mockExec.On("LookPath", "psql").Return("/usr/bin/psql", nil)
+	mockExec.On("LookPath", "pg_dump").Return("/usr/bin/pg_dump", nil)
+	mockStore.On("ListFiles", "20250101000000").Return([]string{prefix + manifest.FileName, prefix + "db_exports.zip"}, nil)
+	mockStore.On("Download", prefix+manifest.FileName, mock.Anything).
+		Run(func(args mock.Arguments) {
+			m := &manifest.Manifest{Archive: "db_exports.zip", Databases: []string{"db1"}}
+			require.NoError(t, m.Write(args.String(1)))
+		}).Return(nil)
+
+	mockExec.On("Command", mock.Anything, "psql", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithDir", dumpster.restoreLocation).Return(mockCmd)
+	mockCmd.On("Output").Return([]byte("1\n"), nil).Once()
+	mockCmd.On("Output").Return([]byte("public.users|42\n"), nil).Once()
+
+	// The archive is never downloaded.
+	_, err = dumpster.Restore(context.Background(), "20250101000000", RestoreOptions{})
+
+	var nonEmpty *TargetNotEmptyError
+	require.ErrorAs(t, err, &nonEmpty)
+	require.ErrorIs(t, err, ErrTargetNotEmpty)
+	assert.Equal(t, map[string][]TableStat{"db1": {{Name: "public.users", Rows: 42}}}, nonEmpty.Tables)
+}
+
+// restoreWithManifest runs a restore of a backup whose manifest is m, with its archive stored as archive.
+func restoreWithManifest(t *testing.T, m *manifest.Manifest, archive string) (*storage.MockStorageIface, *exec.MockExecIface, error) {
+	t.Helper()
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+
+	dumpster, err := NewDumpster(&config.Config{}, mockStore, mockExec)
+	require.NoError(t, err)
+	dumpster.restoreLocation = filepath.Join(t.TempDir(), "restore")
+
+	prefix := "prefix/instance/20250101000000/"
+	mockExec.On("LookPath", mock.Anything).Return("/usr/bin/tool", nil).Maybe()
+	mockStore.On("ListFiles", "20250101000000").Return([]string{prefix + manifest.FileName, prefix + archive}, nil)
+	mockStore.On("Name").Return("test-storage").Maybe()
+	mockStore.On("Download", prefix+manifest.FileName, mock.Anything).
+		Run(func(args mock.Arguments) {
+			require.NoError(t, m.Write(args.String(1)))
+		}).Return(nil)
+	mockStore.On("Download", prefix+archive, mock.Anything).Return(nil).Maybe()
+
+	_, err = dumpster.Restore(context.Background(), "20250101000000", RestoreOptions{Force: true})
+	return mockStore, mockExec, err
+}
+
+func TestDumpster_Restore_UnknownEngine(t *testing.T) {
+	_, mockExec, err := restoreWithManifest(t, &manifest.Manifest{Engine: "mysql", Format: "plain"}, "mysql-plain.zip")
+	require.ErrorIs(t, err, ErrUnknownEngine)
+	mockExec.AssertNotCalled(t, "LookPath", mock.Anything)
+}
+
+func TestDumpster_Restore_UnsupportedFormat(t *testing.T) {
+	_, _, err := restoreWithManifest(t, &manifest.Manifest{Engine: "postgres", Format: "custom"}, "postgres-custom.zip")
+	require.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestDumpster_Restore_EncryptedPerManifest(t *testing.T) {
+	// The manifest, not the file name, says the archive is encrypted.
+	m := &manifest.Manifest{Engine: "postgres", Format: "plain", Encrypted: true, Compressor: "zip"}
+	_, _, err := restoreWithManifest(t, m, "postgres-plain.zip")
+	require.ErrorIs(t, err, ErrNoPrivateKey)
+}
@@ -0,0 +1,32 @@
+package dumpster
+
+import "sync"
+
+// restoring counts the restores in progress per backup timestamp across every Dumpster in the process,
+// so a retention pass running alongside a restore (e.g. from the scheduler) leaves its source alone.
+var restoring = struct {
+	sync.Mutex
+	counts map[string]int
+}{counts: map[string]int{}}
+
+// lockForRestore excludes the backup at timestamp from purges until the returned func is called.
+func lockForRestore(timestamp string) func() {
+	restoring.Lock()
+	defer restoring.Unlock()
+	restoring.counts[timestamp]++
+
+	return sync.OnceFunc(func() {
+		restoring.Lock()
+		defer restoring.Unlock()
+		if restoring.counts[timestamp]--; restoring.counts[timestamp] <= 0 {
+			delete(restoring.counts, timestamp)
+		}
+	})
+}
+
+// isRestoring reports whether the backup at timestamp is being restored.
+func isRestoring(timestamp string) bool {
+	restoring.Lock()
+	defer restoring.Unlock()
+	return restoring.counts[timestamp] > 0
+}
@@ -0,0 +1,98 @@
+package dumpster
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hibare/GoCommon/v2/pkg/crypto/gpg"
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/manifest"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGPG "encrypts" by prefixing files with a marker, writing its output to dir like gpg.GPG does to
+// the temp dir.
+type fakeGPG struct {
+	gpg.GPGIface
+	dir string
+}
+
+var fakeCipherMarker = []byte("sealed:")
+
+func (f *fakeGPG) EncryptFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	out := filepath.Join(f.dir, filepath.Base(path)+".gpg")
+	return out, os.WriteFile(out, append(append([]byte{}, fakeCipherMarker...), data...), 0600)
+}
+
+func (f *fakeGPG) DecryptFile(path, _ string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	out := filepath.Join(f.dir, strings.TrimSuffix(filepath.Base(path), ".gpg"))
+	return out, os.WriteFile(out, bytes.TrimPrefix(data, fakeCipherMarker), 0600)
+}
+
+func (f *fakeGPG) SetPrivateKey(string) {}
+
+func TestDumpster_SealedManifest(t *testing.T) {
+	cfg := &config.Config{Backup: config.BackupConfig{Encrypt: true, EncryptManifest: true}}
+	cfg.Encryption.GPG.PrivateKeyFile = "private.asc"
+	mockStore := storage.NewMockStorageIface(t)
+	d, err := NewDumpster(cfg, mockStore, exec.NewMockExecIface(t))
+	require.NoError(t, err)
+	d.gpg = &fakeGPG{dir: t.TempDir()}
+	d.backupLocation = t.TempDir()
+
+	stored := map[string][]byte{}
+	mockStore.On("Upload", "20250101000000", mock.Anything).Run(func(args mock.Arguments) {
+		data, rErr := os.ReadFile(args.String(1))
+		require.NoError(t, rErr)
+		stored[filepath.Base(args.String(1))] = data
+	}).Return("", nil)
+
+	m := &manifest.Manifest{
+		Timestamp: "20250101000000",
+		Archive:   "postgres-plain.zip.gpg",
+		Encrypted: true,
+		Size:      42,
+		Databases: []string{"payroll"},
+		Labels:    map[string]string{"release": "v1"},
+	}
+	index, err := d.uploadManifest(context.Background(), m)
+	require.NoError(t, err)
+
+	// The plaintext index keeps what listing needs and none of the database names.
+	require.Contains(t, stored, manifest.SealedFileName)
+	assert.NotContains(t, string(stored[manifest.FileName]), "payroll")
+	assert.Empty(t, index.Databases)
+	assert.Equal(t, int64(42), index.Size)
+	assert.Equal(t, map[string]string{"release": "v1"}, index.Labels)
+	require.NotNil(t, index.Sealed)
+	assert.Equal(t, manifest.SealedFileName, index.Sealed.File)
+
+	files := []string{"p/20250101000000/" + manifest.FileName, "p/20250101000000/" + manifest.SealedFileName}
+	mockStore.On("Download", files[1], mock.Anything).Run(func(args mock.Arguments) {
+		require.NoError(t, os.WriteFile(args.String(1), stored[manifest.SealedFileName], 0600))
+	}).Return(nil)
+
+	full, err := d.openSealedManifest(context.Background(), files, index)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"payroll"}, full.Databases)
+
+	index.Sealed.SHA256 = strings.Repeat("0", 64)
+	_, err = d.openSealedManifest(context.Background(), files, index)
+	require.ErrorIs(t, err, manifest.ErrChecksumMismatch)
+}
@@ -0,0 +1,136 @@
+package dumpster
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// replicationSlot is a temporary logical replication slot whose exported snapshot stays
+// valid for as long as the replication session that created it is open.
+type replicationSlot struct {
+	Name     string
+	LSN      string
+	Snapshot string
+
+	dir    string
+	input  *os.File
+	stdout *os.File
+	stderr *os.File
+	done   chan error
+}
+
+// parseSlotOutput parses the unaligned output of CREATE_REPLICATION_SLOT ... EXPORT_SNAPSHOT:
+// slot_name|consistent_point|snapshot_name|output_plugin.
+func parseSlotOutput(line string) (name, lsn, snapshot string, err error) {
+	fields := strings.Split(strings.TrimSpace(line), "|")
+	if len(fields) != 4 || fields[2] == "" {
+		return "", "", "", fmt.Errorf("unexpected CREATE_REPLICATION_SLOT output %q", line)
+	}
+	return fields[0], fields[1], fields[2], nil
+}
+
+// openReplicationSlot creates a temporary logical replication slot in db and exports its snapshot.
+// The replication session is held open by a psql process reading its commands from a FIFO, since
+// the exec wrapper can't hand a running process its stdin. Close drops the slot.
+func (p *Postgres) openReplicationSlot(ctx context.Context, db string, envVars []string, dir string) (*replicationSlot, error) {
+	name := "stashly_" + strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	// The FIFO and psql's stderr live outside dir so they never end up in the archive.
+	tmp, err := os.MkdirTemp("", name+"-")
+	if err != nil {
+		return nil, err
+	}
+	slot := &replicationSlot{dir: tmp, done: make(chan error, 1)}
+	input := filepath.Join(tmp, "session.sql")
+	if err := syscall.Mkfifo(input, 0600); err != nil {
+		_ = os.RemoveAll(tmp)
+		return nil, err
+	}
+	// Opening read-write doesn't wait for psql; closing it is the end of input that ends the session.
+	if slot.input, err = os.OpenFile(input, os.O_RDWR, 0); err != nil {
+		_ = os.RemoveAll(tmp)
+		return nil, err
+	}
+	if slot.stderr, err = os.Create(filepath.Join(tmp, "stderr")); err != nil {
+		_ = slot.input.Close()
+		_ = os.RemoveAll(tmp)
+		return nil, err
+	}
+	stdout, w, err := os.Pipe()
+	if err != nil {
+		_ = slot.input.Close()
+		_ = slot.stderr.Close()
+		_ = os.RemoveAll(tmp)
+		return nil, err
+	}
+	slot.stdout = stdout
+
+	cmd := p.exec.Command(ctx, "psql", "-At", "-v", "ON_ERROR_STOP=1", "--file="+input,
+		"--dbname=dbname="+quoteConnValue(db)+" replication=database").
+		WithEnv(envVars).
+		WithDir(dir).
+		WithStdout(w).
+		WithStderr(slot.stderr)
+	go func() {
+		err := cmd.Run()
+		// Once psql is gone nothing else writes to stdout, so a pending read sees EOF.
+		_ = w.Close()
+		slot.done <- err
+	}()
+
+	if _, err := fmt.Fprintf(slot.input, "CREATE_REPLICATION_SLOT %s TEMPORARY LOGICAL pgoutput EXPORT_SNAPSHOT;\n", name); err != nil {
+		_ = slot.Close()
+		return nil, err
+	}
+
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		cErr := slot.Close()
+		return nil, fmt.Errorf("error creating replication slot: %w", errors.Join(err, cErr))
+	}
+
+	slot.Name, slot.LSN, slot.Snapshot, err = parseSlotOutput(line)
+	if err != nil {
+		_ = slot.Close()
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "Created replication slot", "database", db, "slot", slot.Name, "lsn", slot.LSN, "snapshot", slot.Snapshot)
+	return slot, nil
+}
+
+// Close ends the replication session, which drops the temporary slot and its snapshot.
+func (s *replicationSlot) Close() error {
+	_ = s.input.Close()
+	err := <-s.done
+	_ = s.stdout.Close()
+	_ = s.stderr.Close()
+
+	var msg []byte
+	if err != nil {
+		msg, _ = os.ReadFile(s.stderr.Name())
+	}
+	_ = os.RemoveAll(s.dir)
+
+	if err != nil {
+		if m := strings.TrimSpace(string(msg)); m != "" {
+			return fmt.Errorf("%w: %s", err, m)
+		}
+		return err
+	}
+	return nil
+}
+
+// quoteConnValue quotes a value for a libpq connection string.
+func quoteConnValue(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
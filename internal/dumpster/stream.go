@@ -0,0 +1,174 @@
+package dumpster
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/hibare/GoCommon/v2/pkg/crypto/gpg"
+	"github.com/hibare/stashly/internal/framed"
+	"github.com/hibare/stashly/internal/keyproof"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrUnknownCompression is returned for stream compressions other than "none", "gzip" and "zstd".
+var ErrUnknownCompression = errors.New("unknown compression")
+
+// StreamOptions controls how StreamDump writes a dump.
+type StreamOptions struct {
+	// Compression is "none", "gzip" or "zstd".
+	Compression string
+
+	// Encrypt encrypts the stream for the configured GPG key, as an ASCII-armored message.
+	Encrypt bool
+
+	// Framed encrypts in authenticated frames instead (see package framed), so the stream can be
+	// decrypted and verified as it is read.
+	Framed bool
+}
+
+// compressWriter wraps w in the named compression.
+func compressWriter(w io.Writer, compression string) (io.WriteCloser, error) {
+	switch compression {
+	case "", "none":
+		return nopWriteCloser{w}, nil
+	case "gzip":
+		return gzip.NewWriter(w), nil
+	case "zstd":
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownCompression, compression)
+	}
+}
+
+// nopWriteCloser adds a no-op Close to a writer.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// encryptedWriter is an OpenPGP message encrypted for the armored public key, written to w.
+type encryptedWriter struct {
+	plaintext io.WriteCloser
+	armored   io.WriteCloser
+}
+
+func newEncryptedWriter(w io.Writer, publicKey string) (*encryptedWriter, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read armored key ring: %w", err)
+	}
+
+	armored, err := armor.Encode(w, gpg.GPGEncodeBlockType, nil)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := openpgp.Encrypt(armored, entities, nil, nil, nil)
+	if err != nil {
+		_ = armored.Close()
+		return nil, err
+	}
+	return &encryptedWriter{plaintext: plaintext, armored: armored}, nil
+}
+
+func (e *encryptedWriter) Write(p []byte) (int, error) { return e.plaintext.Write(p) }
+
+// Close finishes the message and its armor.
+func (e *encryptedWriter) Close() error {
+	return errors.Join(e.plaintext.Close(), e.armored.Close())
+}
+
+// streamWriter returns the writer a dump is copied into: compression, then encryption, then out.
+// Closing it flushes every layer.
+func (d *Dumpster) streamWriter(out io.Writer, opts StreamOptions) (io.WriteCloser, error) {
+	layers := []io.Closer{}
+	w := out
+
+	if opts.Encrypt {
+		publicKey, err := d.EncryptionPublicKey()
+		if err != nil {
+			return nil, err
+		}
+		if d.cfg.Encryption.GPG.RequireKeyProof {
+			if err := keyproof.Verify(d.cfg.Encryption.GPG.KeyProofFile, publicKey); err != nil {
+				return nil, err
+			}
+		}
+		var enc io.WriteCloser
+		if opts.Framed {
+			enc, err = framed.NewWriter(w, publicKey)
+		} else {
+			enc, err = newEncryptedWriter(w, publicKey)
+		}
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, enc)
+		w = enc
+	}
+
+	compressed, err := compressWriter(w, opts.Compression)
+	if err != nil {
+		return nil, err
+	}
+	layers = append(layers, compressed)
+
+	return &layeredWriter{Writer: compressed, layers: layers}, nil
+}
+
+// layeredWriter writes to the first layer data flows through and closes the layers in that order.
+type layeredWriter struct {
+	io.Writer
+	layers []io.Closer
+}
+
+func (l *layeredWriter) Close() error {
+	var errs []error
+	for i := len(l.layers) - 1; i >= 0; i-- {
+		errs = append(errs, l.layers[i].Close())
+	}
+	return errors.Join(errs...)
+}
+
+// StreamDump writes a dump of a single database to out, compressed and optionally encrypted,
+// without archiving it or touching storage.
+func (d *Dumpster) StreamDump(ctx context.Context, db string, out io.Writer, opts StreamOptions) error {
+	if err := d.runPreChecks(); err != nil {
+		return err
+	}
+
+	w, err := d.streamWriter(out, opts)
+	if err != nil {
+		return err
+	}
+
+	// The engine writes to a pipe so its output can be compressed and encrypted on the way.
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+
+	dumpErr := make(chan error, 1)
+	go func() {
+		dumpErr <- d.engine.StreamDatabase(ctx, db, pw, d.backupLocation)
+		_ = pw.Close()
+	}()
+
+	_, copyErr := io.Copy(w, pr)
+	// Unblock the engine if copying stopped early.
+	_ = pr.Close()
+	if err := <-dumpErr; err != nil {
+		return fmt.Errorf("error dumping %s: %w", db, err)
+	}
+	if copyErr != nil {
+		return copyErr
+	}
+	return w.Close()
+}
@@ -0,0 +1,81 @@
+package dumpster
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpster_StreamDump(t *testing.T) {
+	mockExec := exec.NewMockExecIface(t)
+	mockCmd := exec.NewMockCmdIface(t)
+	d, err := NewDumpster(&config.Config{}, storage.NewMockStorageIface(t), mockExec)
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(d.backupLocation)
+	}()
+
+	dump := "CREATE TABLE t ();\n\n--\n-- PostgreSQL database dump complete\n--\n"
+	mockExec.On("LookPath", mock.Anything).Return("/usr/bin/true", nil)
+	mockExec.On("Command", mock.Anything, "pg_dump", []string{"--no-owner", "--no-acl", "--dbname=app"}).Return(mockCmd)
+	mockCmd.On("WithEnv", mock.Anything).Return(mockCmd)
+	mockCmd.On("WithDir", d.backupLocation).Return(mockCmd)
+	mockCmd.On("WithStdout", mock.Anything).Run(func(args mock.Arguments) {
+		_, wErr := args.Get(0).(*os.File).WriteString(dump)
+		require.NoError(t, wErr)
+	}).Return(mockCmd)
+	mockCmd.On("WithStderr", os.Stderr).Return(mockCmd)
+	mockCmd.On("Run").Return(nil)
+
+	var out bytes.Buffer
+	require.NoError(t, d.StreamDump(context.Background(), "app", &out, StreamOptions{Compression: "gzip"}))
+
+	gz, err := gzip.NewReader(&out)
+	require.NoError(t, err)
+	got, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, dump, string(got))
+}
+
+func TestCompressWriter_Unknown(t *testing.T) {
+	_, err := compressWriter(io.Discard, "brotli")
+	require.ErrorIs(t, err, ErrUnknownCompression)
+}
+
+func TestEncryptedWriter(t *testing.T) {
+	entity, err := openpgp.NewEntity("stashly", "", "stashly@example.com", nil)
+	require.NoError(t, err)
+
+	var publicKey bytes.Buffer
+	aw, err := armor.Encode(&publicKey, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(aw))
+	require.NoError(t, aw.Close())
+
+	var out bytes.Buffer
+	w, err := newEncryptedWriter(&out, publicKey.String())
+	require.NoError(t, err)
+	_, err = w.Write([]byte("secret dump"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	block, err := armor.Decode(&out)
+	require.NoError(t, err)
+	md, err := openpgp.ReadMessage(block.Body, openpgp.EntityList{entity}, nil, nil)
+	require.NoError(t, err)
+	got, err := io.ReadAll(md.UnverifiedBody)
+	require.NoError(t, err)
+	assert.Equal(t, "secret dump", string(got))
+}
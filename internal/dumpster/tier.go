@@ -0,0 +1,58 @@
+package dumpster
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"time"
+
+	"github.com/hibare/stashly/internal/constants"
+	"github.com/hibare/stashly/internal/manifest"
+)
+
+// TierDumps moves backups older than the configured tier-after age to the colder tier storage class.
+// Manifests stay in their original class so backups can still be listed cheaply; retention keeps
+// deleting tiered backups as usual.
+func (d *Dumpster) TierDumps(ctx context.Context) error {
+	if d.cfg.Backup.TierAfter <= 0 {
+		return nil
+	}
+
+	class := d.cfg.Backup.TierStorageClass
+	if class == "" {
+		class = constants.DefaultTierStorageClass
+	}
+
+	// Keys are the times backups were taken, so the listing is all that's needed to age them.
+	timestamps, err := d.ListDumps(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, ts := range timestamps {
+		created, tErr := ParseTimestamp(ts)
+		if tErr != nil {
+			slog.WarnContext(ctx, "Cannot tell backup age, not tiering it", "timestamp", ts, "error", tErr)
+			continue
+		}
+		if time.Since(created) < d.cfg.Backup.TierAfter {
+			continue
+		}
+
+		files, lErr := d.store.ListFiles(ctx, ts)
+		if lErr != nil {
+			return lErr
+		}
+		for _, key := range files {
+			if name := path.Base(key); name == manifest.FileName || name == manifest.SealedFileName {
+				continue
+			}
+			if sErr := d.store.SetStorageClass(ctx, key, class); sErr != nil {
+				return fmt.Errorf("error moving backup %s to %s: %w", ts, class, sErr)
+			}
+		}
+		slog.DebugContext(ctx, "Backup tiered", "timestamp", ts, "class", class)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package dumpster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/constants"
+	"github.com/hibare/stashly/internal/manifest"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpster_TierDumps(t *testing.T) {
+	cfg := &config.Config{Backup: config.BackupConfig{
+		// Keys are always written in the default layout, whatever this says.
+		DateTimeLayout:   "2006-01-02",
+		TierAfter:        30 * 24 * time.Hour,
+		TierStorageClass: "DEEP_ARCHIVE",
+	}}
+	mockStore := storage.NewMockStorageIface(t)
+	mockExec := exec.NewMockExecIface(t)
+	dumpster, err := NewDumpster(cfg, mockStore, mockExec)
+	require.NoError(t, err)
+
+	fresh := time.Now().Format(constants.DefaultDateTimeLayout)
+	timestamps := []string{fresh, "20250101000000"}
+	mockStore.On("List").Return(timestamps, nil)
+	mockStore.On("TrimPrefix", timestamps).Return(timestamps)
+
+	// Backups are aged by their keys; no manifest is downloaded.
+	mockStore.On("ListFiles", "20250101000000").Return([]string{"p/i/20250101000000/db_exports.zip", "p/i/20250101000000/" + manifest.FileName}, nil)
+
+	mockStore.On("SetStorageClass", "p/i/20250101000000/db_exports.zip", "DEEP_ARCHIVE").Return(nil).Once()
+
+	require.NoError(t, dumpster.TierDumps(context.Background()))
+	mockStore.AssertExpectations(t)
+}
+
+func TestDumpster_TierDumps_Disabled(t *testing.T) {
+	mockStore := storage.NewMockStorageIface(t)
+	dumpster, err := NewDumpster(&config.Config{}, mockStore, exec.NewMockExecIface(t))
+	require.NoError(t, err)
+
+	require.NoError(t, dumpster.TierDumps(context.Background()))
+	mockStore.AssertNotCalled(t, "List")
+}
+
+func TestParseTimestamp(t *testing.T) {
+	// Keys are named after local time, so a zone west of UTC must not shift them.
+	original := time.Local
+	time.Local = time.FixedZone("TEST", -5*60*60)
+	t.Cleanup(func() { time.Local = original })
+
+	taken, err := ParseTimestamp("20250101000000")
+	require.NoError(t, err)
+	require.True(t, taken.Equal(time.Date(2025, 1, 1, 5, 0, 0, 0, time.UTC)), "got %s", taken)
+
+	_, err = ParseTimestamp("2025-01-01")
+	require.Error(t, err)
+}
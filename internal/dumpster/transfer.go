@@ -0,0 +1,336 @@
+package dumpster
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hibare/stashly/internal/catalog"
+	"github.com/hibare/stashly/internal/manifest"
+	"github.com/hibare/stashly/internal/storage"
+)
+
+// ChecksumsFile lists the SHA-256 of every file in an exported directory, in sha256sum format,
+// so the copy can be checked with `sha256sum -c` before it is imported.
+const ChecksumsFile = "SHA256SUMS"
+
+// ErrChecksumMismatch is returned when an exported file doesn't match its recorded checksum.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ExportBackups copies the backups taken at or after since, with their manifests, to dir as
+// <timestamp>/<file> and writes ChecksumsFile. It returns the exported timestamps.
+func (d *Dumpster) ExportBackups(ctx context.Context, dir string, since time.Time) ([]string, error) {
+	timestamps, err := d.FindDumps(ctx, DumpFilter{From: since})
+	if err != nil {
+		return nil, err
+	}
+
+	var sums []string
+	exported := []string{}
+	for _, ts := range timestamps {
+		files, lErr := d.store.ListFiles(ctx, ts)
+		if lErr != nil {
+			return nil, lErr
+		}
+		if err := os.MkdirAll(filepath.Join(dir, ts), 0750); err != nil {
+			return nil, err
+		}
+		for _, key := range files {
+			name := path.Base(key)
+			localPath := filepath.Join(dir, ts, name)
+			slog.InfoContext(ctx, "Exporting", "key", key, "path", localPath)
+			if err := d.store.Download(ctx, key, localPath); err != nil {
+				return nil, fmt.Errorf("error downloading %s: %w", key, err)
+			}
+			sum, sErr := sha256Hex(localPath)
+			if sErr != nil {
+				return nil, sErr
+			}
+			sums = append(sums, sum+"  "+ts+"/"+name)
+		}
+		exported = append(exported, ts)
+	}
+
+	sort.Strings(sums)
+	content := strings.Join(sums, "\n")
+	if len(sums) > 0 {
+		content += "\n"
+	}
+	if err := os.WriteFile(filepath.Join(dir, ChecksumsFile), []byte(content), 0600); err != nil {
+		return nil, err
+	}
+	return exported, nil
+}
+
+// readChecksums parses ChecksumsFile in dir into relative paths and their SHA-256.
+func readChecksums(dir string) (map[string]string, error) {
+	f, err := os.Open(filepath.Join(dir, ChecksumsFile))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, "  ")
+		if !ok || !filepath.IsLocal(name) {
+			return nil, fmt.Errorf("invalid %s line: %q", ChecksumsFile, line)
+		}
+		sums[name] = sum
+	}
+	return sums, scanner.Err()
+}
+
+// manifestLast sorts the files of a backup, names or keys, for uploading: the manifest last, so a
+// backup is only listed once it is complete, and the rest in name order so volumes go up in sequence.
+func manifestLast(files []string) {
+	sort.Slice(files, func(i, j int) bool {
+		iManifest, jManifest := path.Base(files[i]) == manifest.FileName, path.Base(files[j]) == manifest.FileName
+		if iManifest != jManifest {
+			return jManifest
+		}
+		return files[i] < files[j]
+	})
+}
+
+// ImportBackups uploads the backups in a directory written by ExportBackups, after checking every
+// file against ChecksumsFile. Backups whose timestamp already exists in storage are skipped. Each
+// backup's manifest is uploaded last, so an interrupted import never lists a backup with missing files.
+// It returns the imported timestamps.
+func (d *Dumpster) ImportBackups(ctx context.Context, dir string) ([]string, error) {
+	sums, err := readChecksums(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	backups := map[string][]string{}
+	for name, sum := range sums {
+		got, sErr := sha256Hex(filepath.Join(dir, name))
+		if sErr != nil {
+			return nil, sErr
+		}
+		if got != sum {
+			return nil, fmt.Errorf("%w: %s", ErrChecksumMismatch, name)
+		}
+		ts, file, ok := strings.Cut(filepath.ToSlash(name), "/")
+		if !ok || strings.Contains(file, "/") {
+			return nil, fmt.Errorf("unexpected file %s, expected <timestamp>/<file>", name)
+		}
+		backups[ts] = append(backups[ts], file)
+	}
+
+	existing, err := d.ListDumps(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	imported := []string{}
+	for _, ts := range slices.Sorted(maps.Keys(backups)) {
+		if slices.Contains(existing, ts) {
+			slog.WarnContext(ctx, "Backup already in storage; skipping", "timestamp", ts)
+			continue
+		}
+
+		files := backups[ts]
+		manifestLast(files)
+		for _, file := range files {
+			localPath := filepath.Join(dir, ts, file)
+			slog.InfoContext(ctx, "Importing", "path", localPath, "storage", d.store.Name())
+			if _, err := d.uploadCopy(ctx, ts, localPath); err != nil {
+				return nil, fmt.Errorf("error uploading %s: %w", localPath, err)
+			}
+		}
+		imported = append(imported, ts)
+	}
+	if len(imported) > 0 {
+		d.updateCatalog(ctx, func(c *catalog.Catalog) { c.Invalidate() })
+	}
+	return imported, nil
+}
+
+// ReplicateOptions controls which backups ReplicateBackups copies.
+type ReplicateOptions struct {
+	// Latest copies only the newest Latest backups; 0 copies all of them.
+	Latest int
+
+	// DryRun returns the backups that would be copied without copying them.
+	DryRun bool
+}
+
+// ReplicateBackups copies backups from this dumpster's storage to dst's, skipping those dst already
+// has. Each backup's files pass through a temporary directory and the manifest is uploaded last, so an
+// interrupted copy never lists a backup with missing files. It returns the copied timestamps, oldest
+// first.
+func (d *Dumpster) ReplicateBackups(ctx context.Context, dst *Dumpster, opts ReplicateOptions) ([]string, error) {
+	pending, err := d.missingFrom(ctx, dst, opts.Latest)
+	if err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		return pending, nil
+	}
+
+	replicated := []string{}
+	for _, ts := range pending {
+		if err := d.copyBackup(ctx, dst, ts, false); err != nil {
+			return replicated, fmt.Errorf("error replicating %s: %w", ts, err)
+		}
+		replicated = append(replicated, ts)
+	}
+	if len(replicated) > 0 {
+		dst.updateCatalog(ctx, func(c *catalog.Catalog) { c.Invalidate() })
+	}
+	return replicated, nil
+}
+
+// missingFrom returns the timestamps of this dumpster's backups that dst doesn't have, oldest first.
+// latest, if positive, only considers the newest latest backups.
+func (d *Dumpster) missingFrom(ctx context.Context, dst *Dumpster, latest int) ([]string, error) {
+	timestamps, err := d.ListDumps(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if latest > 0 && len(timestamps) > latest {
+		timestamps = timestamps[:latest]
+	}
+	existing, err := dst.ListDumps(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []string{}
+	for _, ts := range slices.Backward(timestamps) {
+		if slices.Contains(existing, ts) {
+			slog.DebugContext(ctx, "Backup already copied; skipping", "timestamp", ts)
+			continue
+		}
+		pending = append(pending, ts)
+	}
+	return pending, nil
+}
+
+// MigrateOptions controls MigrateBackups.
+type MigrateOptions struct {
+	// DryRun returns the backups that would be copied without copying them.
+	DryRun bool
+}
+
+// ErrMigrationMismatch is returned when a migrated backup doesn't have the files of its source.
+var ErrMigrationMismatch = errors.New("migrated backup doesn't match its source")
+
+// MigrateBackups copies backups from this dumpster's location to dst's, on the same backend, skipping
+// those dst already has. Objects are copied server-side where the backend can and re-uploaded
+// otherwise; in encryption compliance mode they are always re-uploaded, so each is checked and
+// audited. Afterwards every backup dst shares with this dumpster is verified to have as many files
+// and bytes as its source. The source backups are left in place. It returns the migrated timestamps,
+// oldest first.
+func (d *Dumpster) MigrateBackups(ctx context.Context, dst *Dumpster, opts MigrateOptions) ([]string, error) {
+	pending, err := d.missingFrom(ctx, dst, 0)
+	if err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		return pending, nil
+	}
+
+	migrated := []string{}
+	for _, ts := range pending {
+		if err := d.copyBackup(ctx, dst, ts, !dst.cfg.Encryption.Compliance); err != nil {
+			return migrated, fmt.Errorf("error migrating %s: %w", ts, err)
+		}
+		migrated = append(migrated, ts)
+	}
+	if len(migrated) > 0 {
+		dst.updateCatalog(ctx, func(c *catalog.Catalog) { c.Invalidate() })
+	}
+	return migrated, d.verifyCopies(ctx, dst)
+}
+
+// verifyCopies checks that every backup dst has in common with this dumpster has the same number of
+// files and bytes.
+func (d *Dumpster) verifyCopies(ctx context.Context, dst *Dumpster) error {
+	src, err := d.store.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	copied, err := dst.store.Stats(ctx)
+	if err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for _, want := range src.Backups {
+		i := slices.IndexFunc(copied.Backups, func(b storage.BackupUsage) bool { return b.Timestamp == want.Timestamp })
+		if i < 0 {
+			continue
+		}
+		if got := copied.Backups[i]; got.Files != want.Files || got.Bytes != want.Bytes {
+			errs = append(errs, fmt.Errorf("%w: %s has %d files of %d bytes, the source %d files of %d bytes",
+				ErrMigrationMismatch, want.Timestamp, got.Files, got.Bytes, want.Files, want.Bytes))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// copyBackup copies the files of the backup at timestamp to dst, the manifest last. With serverSide,
+// files are copied within the backend where it can; otherwise, and where that fails, they pass through
+// a temporary directory.
+func (d *Dumpster) copyBackup(ctx context.Context, dst *Dumpster, timestamp string, serverSide bool) error {
+	files, err := d.store.ListFiles(ctx, timestamp)
+	if err != nil {
+		return err
+	}
+	manifestLast(files)
+
+	tmp, err := os.MkdirTemp("", "stashly-replicate-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	for _, key := range files {
+		if serverSide {
+			_, cErr := storage.Copy(ctx, dst.store, key, timestamp)
+			if cErr == nil {
+				slog.InfoContext(ctx, "Copied server-side", "key", key, "backend", dst.store.Name())
+				continue
+			}
+			if errors.Is(cErr, storage.ErrCopyUnsupported) {
+				serverSide = false
+			} else {
+				slog.WarnContext(ctx, "Server-side copy failed; copying through this host", "key", key, "error", cErr)
+			}
+		}
+
+		localPath := filepath.Join(tmp, path.Base(key))
+		slog.InfoContext(ctx, "Replicating", "key", key, "from", d.store.Name(), "to", dst.store.Name())
+		if err := d.store.Download(ctx, key, localPath); err != nil {
+			return fmt.Errorf("error downloading %s: %w", key, err)
+		}
+		if _, err := dst.uploadCopy(ctx, timestamp, localPath); err != nil {
+			return fmt.Errorf("error uploading %s: %w", key, err)
+		}
+		// Free the space before the next file; archives can be large.
+		_ = os.Remove(localPath)
+	}
+	return nil
+}
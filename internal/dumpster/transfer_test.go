@@ -0,0 +1,175 @@
+package dumpster
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/manifest"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpster_ExportImportBackups(t *testing.T) {
+	cfg := &config.Config{Backup: config.BackupConfig{DateTimeLayout: "20060102150405"}}
+	dir := t.TempDir()
+
+	// Export the newer of two backups.
+	source := storage.NewMockStorageIface(t)
+	d, err := NewDumpster(cfg, source, exec.NewMockExecIface(t))
+	require.NoError(t, err)
+
+	timestamps := []string{"20240101000000", "20240201000000"}
+	source.On("ListPage", storage.ListOptions{StartAfter: "20240114235959", Limit: listPageSize}).Return(storage.Page{Keys: timestamps}, nil)
+	source.On("TrimPrefix", timestamps).Return(timestamps)
+	source.On("ListFiles", "20240201000000").Return([]string{
+		"prefix/20240201000000/postgres-plain.zip",
+		"prefix/20240201000000/manifest.json",
+	}, nil)
+	source.On("Download", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		require.NoError(t, os.WriteFile(args.String(1), []byte(args.String(0)), 0600))
+	}).Return(nil)
+
+	exported, err := d.ExportBackups(context.Background(), dir, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20240201000000"}, exported)
+
+	sums, err := readChecksums(dir)
+	require.NoError(t, err)
+	assert.Len(t, sums, 2)
+	assert.Contains(t, sums, "20240201000000/manifest.json")
+
+	// Import on the other side; the manifest goes up last.
+	target := storage.NewMockStorageIface(t)
+	d, err = NewDumpster(cfg, target, exec.NewMockExecIface(t))
+	require.NoError(t, err)
+
+	var uploaded []string
+	target.On("List").Return([]string{}, nil)
+	target.On("Name").Return("test-storage")
+	target.On("Upload", "20240201000000", mock.Anything).Run(func(args mock.Arguments) {
+		uploaded = append(uploaded, filepath.Base(args.String(1)))
+	}).Return("key", nil)
+
+	imported, err := d.ImportBackups(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20240201000000"}, imported)
+	assert.Equal(t, []string{"postgres-plain.zip", manifest.FileName}, uploaded)
+}
+
+func TestDumpster_ImportBackups_ChecksumMismatch(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "20240201000000"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "20240201000000", "postgres-plain.zip"), []byte("tampered"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ChecksumsFile),
+		[]byte("0000000000000000000000000000000000000000000000000000000000000000  20240201000000/postgres-plain.zip\n"), 0600))
+
+	d, err := NewDumpster(&config.Config{}, storage.NewMockStorageIface(t), exec.NewMockExecIface(t))
+	require.NoError(t, err)
+
+	_, err = d.ImportBackups(context.Background(), dir)
+	require.ErrorIs(t, err, ErrChecksumMismatch)
+}
+
+func TestDumpster_ReplicateBackups(t *testing.T) {
+	cfg := &config.Config{}
+	source := storage.NewMockStorageIface(t)
+	src, err := NewDumpster(cfg, source, exec.NewMockExecIface(t))
+	require.NoError(t, err)
+	target := storage.NewMockStorageIface(t)
+	dst, err := NewDumpster(cfg, target, exec.NewMockExecIface(t))
+	require.NoError(t, err)
+
+	timestamps := []string{"20240101000000", "20240201000000", "20240301000000"}
+	source.On("List").Return(timestamps, nil)
+	source.On("TrimPrefix", timestamps).Return(timestamps)
+	target.On("List").Return([]string{"20240301000000"}, nil)
+	target.On("TrimPrefix", []string{"20240301000000"}).Return([]string{"20240301000000"})
+
+	// The newest is already there; a dry run of the latest two copies nothing.
+	pending, err := src.ReplicateBackups(context.Background(), dst, ReplicateOptions{Latest: 2, DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20240201000000"}, pending)
+
+	source.On("Name").Return("source")
+	target.On("Name").Return("target")
+	for _, ts := range timestamps[:2] {
+		source.On("ListFiles", ts).Return([]string{
+			"prefix/" + ts + "/manifest.json",
+			"prefix/" + ts + "/postgres-plain.zip",
+		}, nil)
+	}
+	source.On("Download", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		require.NoError(t, os.WriteFile(args.String(1), []byte(args.String(0)), 0600))
+	}).Return(nil)
+
+	var uploaded []string
+	target.On("Upload", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		uploaded = append(uploaded, args.String(0)+"/"+filepath.Base(args.String(1)))
+	}).Return("key", nil)
+
+	replicated, err := src.ReplicateBackups(context.Background(), dst, ReplicateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20240101000000", "20240201000000"}, replicated)
+	assert.Equal(t, []string{
+		"20240101000000/postgres-plain.zip", "20240101000000/manifest.json",
+		"20240201000000/postgres-plain.zip", "20240201000000/manifest.json",
+	}, uploaded)
+}
+
+// copyingStorage is a backend that copies objects server-side.
+type copyingStorage struct {
+	*storage.MockStorageIface
+	copied []string
+}
+
+func (c *copyingStorage) Copy(_ context.Context, key, timestamp string) (string, error) {
+	c.copied = append(c.copied, timestamp+"/"+path.Base(key))
+	return "new/" + timestamp + "/" + path.Base(key), nil
+}
+
+func TestDumpster_MigrateBackups(t *testing.T) {
+	cfg := &config.Config{}
+	source := storage.NewMockStorageIface(t)
+	src, err := NewDumpster(cfg, source, exec.NewMockExecIface(t))
+	require.NoError(t, err)
+	target := &copyingStorage{MockStorageIface: storage.NewMockStorageIface(t)}
+	dst, err := NewDumpster(cfg, target, exec.NewMockExecIface(t))
+	require.NoError(t, err)
+
+	source.On("List").Return([]string{"20240101000000"}, nil)
+	source.On("TrimPrefix", []string{"20240101000000"}).Return([]string{"20240101000000"})
+	target.On("List").Return([]string{}, nil)
+	target.On("TrimPrefix", []string{}).Return([]string{})
+	target.On("Name").Return("s3")
+	source.On("ListFiles", "20240101000000").Return([]string{
+		"old/20240101000000/manifest.json",
+		"old/20240101000000/postgres-plain.zip",
+	}, nil)
+
+	usage := storage.Usage{Bytes: 42, Count: 1, Backups: []storage.BackupUsage{{Timestamp: "20240101000000", Bytes: 42, Files: 2}}}
+	source.On("Stats").Return(usage, nil)
+	target.On("Stats").Return(usage, nil).Once()
+
+	migrated, err := src.MigrateBackups(context.Background(), dst, MigrateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20240101000000"}, migrated)
+	assert.Equal(t, []string{"20240101000000/postgres-plain.zip", "20240101000000/manifest.json"}, target.copied)
+
+	// A copy missing a file fails verification.
+	target.On("Stats").Return(storage.Usage{Backups: []storage.BackupUsage{{Timestamp: "20240101000000", Bytes: 10, Files: 1}}}, nil).Once()
+	target.On("List").Unset()
+	target.On("List").Return([]string{"20240101000000"}, nil)
+	target.On("TrimPrefix", []string{"20240101000000"}).Return([]string{"20240101000000"})
+
+	migrated, err = src.MigrateBackups(context.Background(), dst, MigrateOptions{})
+	require.ErrorIs(t, err, ErrMigrationMismatch)
+	assert.Empty(t, migrated)
+}
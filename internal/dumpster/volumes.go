@@ -0,0 +1,158 @@
+package dumpster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// splitFile splits path into volumes of at most size bytes named <base>.000, <base>.001, ... in dir.
+func splitFile(path, dir string, size int64) ([]string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	info, err := in.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := []string{}
+	for offset := int64(0); offset < info.Size(); offset += size {
+		volume := filepath.Join(dir, fmt.Sprintf("%s.%03d", filepath.Base(path), len(volumes)))
+		if err := writeVolume(volume, io.NewSectionReader(in, offset, size)); err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, volume)
+	}
+	return volumes, nil
+}
+
+func writeVolume(path string, r io.Reader) error {
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// joinFiles concatenates volumes, in order, into dest.
+func joinFiles(volumes []string, dest string) error {
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	for _, volume := range volumes {
+		if err := appendFile(out, volume); err != nil {
+			_ = out.Close()
+			return err
+		}
+	}
+	return out.Close()
+}
+
+func appendFile(out io.Writer, path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// uploadVolumes splits the archive at path into volumes of at most size bytes, uploads them and
+// returns their keys and file names in order. In compliance mode the archive is checked to be encrypted
+// before it is split, and each volume is recorded in the audit log.
+func (d *Dumpster) uploadVolumes(ctx context.Context, timestamp, path string, size int64) ([]string, []string, error) {
+	sealed, err := d.checkSealed(ctx, path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "stashly-volumes-")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	volumes, err := splitFile(path, dir, size)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error splitting archive: %w", err)
+	}
+
+	keys := make([]string, 0, len(volumes))
+	names := make([]string, 0, len(volumes))
+	for _, volume := range volumes {
+		slog.InfoContext(ctx, "Uploading backup volume", "file", volume, "storage", d.store.Name())
+		key, uErr := d.store.Upload(ctx, timestamp, volume)
+		if uErr != nil {
+			return nil, nil, uErr
+		}
+		if aErr := d.recordUpload(timestamp, key, volume, sealed); aErr != nil {
+			return nil, nil, aErr
+		}
+		keys = append(keys, key)
+		names = append(names, filepath.Base(volume))
+
+		// Free the disk space as we go; volumes exist to handle archives too big to keep twice.
+		_ = os.Remove(volume)
+	}
+	return keys, names, nil
+}
+
+// downloadVolumes downloads the named volumes among a backup's files, reassembles them into dest and
+// returns their keys in order.
+func (d *Dumpster) downloadVolumes(ctx context.Context, files, names []string, dest string) ([]string, error) {
+	byName := map[string]string{}
+	for _, key := range files {
+		byName[filepath.Base(key)] = key
+	}
+
+	dir := filepath.Join(filepath.Dir(dest), "volumes")
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	keys := make([]string, 0, len(names))
+	volumes := make([]string, 0, len(names))
+	for _, name := range names {
+		key, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("backup volume %s is missing from storage", name)
+		}
+
+		localPath := filepath.Join(dir, name)
+		slog.InfoContext(ctx, "Downloading backup volume", "key", key, "storage", d.store.Name())
+		if err := d.store.Download(ctx, key, localPath); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+		volumes = append(volumes, localPath)
+	}
+
+	if err := joinFiles(volumes, dest); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
@@ -0,0 +1,34 @@
+package dumpster
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitAndJoinFiles(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "db_exports.zip")
+	require.NoError(t, os.WriteFile(archive, []byte("0123456789"), 0600))
+
+	volumes, err := splitFile(archive, dir, 4)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "db_exports.zip.000"),
+		filepath.Join(dir, "db_exports.zip.001"),
+		filepath.Join(dir, "db_exports.zip.002"),
+	}, volumes)
+
+	last, err := os.ReadFile(volumes[2])
+	require.NoError(t, err)
+	assert.Equal(t, "89", string(last))
+
+	joined := filepath.Join(dir, "joined.zip")
+	require.NoError(t, joinFiles(volumes, joined))
+	got, err := os.ReadFile(joined)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(got))
+}
@@ -0,0 +1,266 @@
+// Package framed encrypts streams in authenticated frames, so they can be decrypted and verified as
+// they are read instead of only once the whole stream has arrived.
+//
+// A stream starts with Magic and a random AES-256 key encrypted to the GPG key as a binary OpenPGP
+// message, prefixed with its length. The data follows in frames of at most ChunkSize bytes, each a
+// flag byte (1 for the final frame), the big-endian uint32 length of the sealed frame, and the frame
+// sealed with AES-256-GCM. Nonces are the frame number followed by the flag, so frames can't be
+// reordered, dropped or truncated without failing authentication.
+package framed
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// Magic starts every framed stream.
+const Magic = "STASHLY-FRAMED-1\n"
+
+// ChunkSize is the most plaintext a frame holds.
+const ChunkSize = 64 << 10
+
+const (
+	keySize = 32
+
+	// maxWrappedKey bounds the wrapped key read from a stream; OpenPGP messages for a handful of
+	// recipients are far smaller.
+	maxWrappedKey = 64 << 10
+
+	flagMore  = 0
+	flagFinal = 1
+)
+
+var (
+	// ErrNotFramed is returned when a stream doesn't start with Magic.
+	ErrNotFramed = errors.New("not a framed stream")
+
+	// ErrCorrupt is returned when a frame fails authentication or is malformed.
+	ErrCorrupt = errors.New("framed stream is corrupt")
+
+	// ErrTruncated is returned when a stream ends before its final frame.
+	ErrTruncated = errors.New("framed stream is truncated")
+)
+
+// nonce returns the nonce of frame n.
+func nonce(n uint64, flag byte) []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint64(b[3:11], n)
+	b[11] = flag
+	return b
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Writer encrypts what is written to it into frames.
+type Writer struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	buf    []byte
+	frame  uint64
+	closed bool
+}
+
+// NewWriter writes a framed stream encrypted for the armored public key to w. Close writes the final
+// frame; without it the stream is truncated.
+func NewWriter(w io.Writer, publicKey string) (*Writer, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read armored key ring: %w", err)
+	}
+
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	var wrapped bytes.Buffer
+	plaintext, err := openpgp.Encrypt(&wrapped, entities, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := plaintext.Write(key); err != nil {
+		return nil, err
+	}
+	if err := plaintext.Close(); err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, len(Magic)+4+wrapped.Len())
+	header = append(header, Magic...)
+	header = binary.BigEndian.AppendUint32(header, uint32(wrapped.Len())) //nolint:gosec // reason: bounded by maxWrappedKey in practice
+	header = append(header, wrapped.Bytes()...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, aead: aead, buf: make([]byte, 0, ChunkSize)}, nil
+}
+
+// Write buffers p, writing a frame each time ChunkSize bytes are buffered and more follow.
+func (fw *Writer) Write(p []byte) (int, error) {
+	if fw.closed {
+		return 0, io.ErrClosedPipe
+	}
+	n := 0
+	for len(p) > 0 {
+		if len(fw.buf) == ChunkSize {
+			if err := fw.flush(flagMore); err != nil {
+				return n, err
+			}
+		}
+		c := copy(fw.buf[len(fw.buf):ChunkSize], p)
+		fw.buf = fw.buf[:len(fw.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// flush seals the buffered plaintext into a frame.
+func (fw *Writer) flush(flag byte) error {
+	sealed := fw.aead.Seal(nil, nonce(fw.frame, flag), fw.buf, nil)
+	frame := make([]byte, 0, 5+len(sealed))
+	frame = append(frame, flag)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(sealed))) //nolint:gosec // reason: at most ChunkSize plus the tag
+	frame = append(frame, sealed...)
+	if _, err := fw.w.Write(frame); err != nil {
+		return err
+	}
+	fw.frame++
+	fw.buf = fw.buf[:0]
+	return nil
+}
+
+// Close writes the final frame. It doesn't close the underlying writer.
+func (fw *Writer) Close() error {
+	if fw.closed {
+		return nil
+	}
+	fw.closed = true
+	return fw.flush(flagFinal)
+}
+
+// Reader decrypts and verifies a framed stream frame by frame.
+type Reader struct {
+	r     *bufio.Reader
+	aead  cipher.AEAD
+	buf   []byte
+	frame uint64
+	done  bool
+}
+
+// NewReader reads the header of a framed stream from r and unwraps its key with the armored private
+// key, unlocked with passphrase if it is protected.
+func NewReader(r io.Reader, privateKey, passphrase string) (*Reader, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(privateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	for _, e := range entities {
+		if e.PrivateKey != nil && e.PrivateKey.Encrypted {
+			if err := e.DecryptPrivateKeys([]byte(passphrase)); err != nil {
+				return nil, fmt.Errorf("failed to unlock private key: %w", err)
+			}
+		}
+	}
+
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(Magic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != Magic {
+		return nil, ErrNotFramed
+	}
+	var size uint32
+	if err := binary.Read(br, binary.BigEndian, &size); err != nil {
+		return nil, ErrTruncated
+	}
+	if size > maxWrappedKey {
+		return nil, fmt.Errorf("%w: wrapped key of %d bytes", ErrCorrupt, size)
+	}
+	wrapped := make([]byte, size)
+	if _, err := io.ReadFull(br, wrapped); err != nil {
+		return nil, ErrTruncated
+	}
+
+	md, err := openpgp.ReadMessage(bytes.NewReader(wrapped), entities, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt stream key: %w", err)
+	}
+	key, err := io.ReadAll(io.LimitReader(md.UnverifiedBody, keySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt stream key: %w", err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("%w: stream key of %d bytes", ErrCorrupt, len(key))
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{r: br, aead: aead}, nil
+}
+
+// Read returns verified plaintext. Frames are only returned once they have been authenticated.
+func (fr *Reader) Read(p []byte) (int, error) {
+	for len(fr.buf) == 0 {
+		if fr.done {
+			return 0, io.EOF
+		}
+		if err := fr.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, fr.buf)
+	fr.buf = fr.buf[n:]
+	return n, nil
+}
+
+// next reads and opens the next frame.
+func (fr *Reader) next() error {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(fr.r, header); err != nil {
+		return ErrTruncated
+	}
+	flag, size := header[0], binary.BigEndian.Uint32(header[1:])
+	if flag > flagFinal || size > ChunkSize+uint32(fr.aead.Overhead()) {
+		return fmt.Errorf("%w: malformed frame %d", ErrCorrupt, fr.frame)
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(fr.r, sealed); err != nil {
+		return ErrTruncated
+	}
+
+	plain, err := fr.aead.Open(sealed[:0], nonce(fr.frame, flag), sealed, nil)
+	if err != nil {
+		return fmt.Errorf("%w: frame %d failed authentication", ErrCorrupt, fr.frame)
+	}
+	fr.frame++
+	fr.buf = plain
+
+	if flag == flagFinal {
+		if _, err := fr.r.ReadByte(); !errors.Is(err, io.EOF) {
+			return fmt.Errorf("%w: data after the final frame", ErrCorrupt)
+		}
+		fr.done = true
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package framed
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newKey generates a key pair and returns the armored public and private keys.
+func newKey(t *testing.T) (string, string) {
+	t.Helper()
+	entity, err := openpgp.NewEntity("stashly", "", "stashly@example.com", nil)
+	require.NoError(t, err)
+
+	var public, private bytes.Buffer
+	w, err := armor.Encode(&public, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+
+	w, err = armor.Encode(&private, openpgp.PrivateKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.SerializePrivate(w, nil))
+	require.NoError(t, w.Close())
+	return public.String(), private.String()
+}
+
+// seal encrypts plaintext into a framed stream, written in small pieces.
+func seal(t *testing.T, public string, plaintext []byte) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := NewWriter(&out, public)
+	require.NoError(t, err)
+	for p := plaintext; len(p) > 0; {
+		n := min(len(p), 1000)
+		_, err = w.Write(p[:n])
+		require.NoError(t, err)
+		p = p[n:]
+	}
+	require.NoError(t, w.Close())
+	return out.Bytes()
+}
+
+func open(data []byte, private string) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(data), private, "")
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestRoundTrip(t *testing.T) {
+	public, private := newKey(t)
+
+	for _, size := range []int{0, 1, ChunkSize, 3*ChunkSize + 17} {
+		plaintext := make([]byte, size)
+		_, _ = rand.Read(plaintext)
+
+		sealed := seal(t, public, plaintext)
+		assert.True(t, bytes.HasPrefix(sealed, []byte(Magic)))
+
+		got, err := open(sealed, private)
+		require.NoError(t, err, "size %d", size)
+		assert.Equal(t, plaintext, got, "size %d", size)
+	}
+}
+
+func TestReader_Tampering(t *testing.T) {
+	public, private := newKey(t)
+	plaintext := bytes.Repeat([]byte("stashly"), ChunkSize)
+	sealed := seal(t, public, plaintext)
+
+	flipped := bytes.Clone(sealed)
+	flipped[len(flipped)-20] ^= 0xff
+	_, err := open(flipped, private)
+	require.ErrorIs(t, err, ErrCorrupt)
+
+	_, err = open(sealed[:len(sealed)-ChunkSize], private)
+	require.ErrorIs(t, err, ErrTruncated)
+
+	_, err = open(append(bytes.Clone(sealed), 0), private)
+	require.ErrorIs(t, err, ErrCorrupt)
+
+	_, err = open([]byte("-----BEGIN PGP MESSAGE-----"), private)
+	require.ErrorIs(t, err, ErrNotFramed)
+}
+
+func TestReader_WrongKey(t *testing.T) {
+	public, _ := newKey(t)
+	_, otherPrivate := newKey(t)
+
+	_, err := open(seal(t, public, []byte("data")), otherPrivate)
+	require.Error(t, err)
+}
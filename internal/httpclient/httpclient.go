@@ -0,0 +1,93 @@
+// Package httpclient builds the HTTP clients used for storage, key server and webhook requests,
+// applying the configured proxy and TLS settings.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/proxy"
+)
+
+var (
+	// ErrInvalidCABundle is returned when the CA file holds no PEM certificates.
+	ErrInvalidCABundle = errors.New("no certificates found in CA file")
+
+	// ErrIncompleteClientCert is returned when only one of the client certificate and key is set.
+	ErrIncompleteClientCert = errors.New("client certificate and key must be set together")
+)
+
+// TLSConfig returns the TLS configuration for cfg: the system roots plus the CA file, an optional client
+// certificate, and certificate verification disabled if requested. It returns nil when nothing is configured.
+func TLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	if cfg.CAFile == "" && cfg.CertFile == "" && cfg.KeyFile == "" && !cfg.InsecureSkipVerify {
+		return nil, nil //nolint:nilnil // reason: a nil config keeps the transport's defaults
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify, //nolint:gosec // reason: opt-in for self-signed endpoints, warned about on load
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidCABundle, cfg.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, ErrIncompleteClientCert
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
+
+// NewTransport returns a copy of http.DefaultTransport that uses the configured proxy and TLS settings.
+func NewTransport(cfg *config.Config) (*http.Transport, error) {
+	proxyFunc, err := proxy.Func(cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	tlsCfg, err := TLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:errcheck // reason: DefaultTransport is always *http.Transport
+	transport.Proxy = proxyFunc
+	if tlsCfg != nil {
+		transport.TLSClientConfig = tlsCfg
+	}
+	return transport, nil
+}
+
+// New returns an HTTP client with the given timeout that uses the configured proxy and TLS settings.
+func New(cfg *config.Config, timeout time.Duration) (*http.Client, error) {
+	transport, err := NewTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
@@ -0,0 +1,76 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, client *http.Client, url string) error {
+	t.Helper()
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func TestNew_TLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, caPEM, 0600))
+
+	tests := []struct {
+		name    string
+		tls     config.TLSConfig
+		wantErr bool
+	}{
+		{name: "untrusted", wantErr: true},
+		{name: "ca file", tls: config.TLSConfig{CAFile: caFile}},
+		{name: "insecure", tls: config.TLSConfig{InsecureSkipVerify: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := New(&config.Config{TLS: tt.tls}, 5*time.Second)
+			require.NoError(t, err)
+
+			err = get(t, client, server.URL)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestTLSConfig_Errors(t *testing.T) {
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0600))
+
+	_, err := TLSConfig(config.TLSConfig{CAFile: notPEM})
+	require.ErrorIs(t, err, ErrInvalidCABundle)
+
+	_, err = TLSConfig(config.TLSConfig{CertFile: "client.pem"})
+	require.ErrorIs(t, err, ErrIncompleteClientCert)
+
+	tlsCfg, err := TLSConfig(config.TLSConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, tlsCfg)
+}
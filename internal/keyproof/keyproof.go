@@ -0,0 +1,185 @@
+// Package keyproof records that the operator holds the private key backups are encrypted to, so
+// encryption can refuse to run for a key nobody can decrypt with.
+package keyproof
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/hibare/GoCommon/v2/pkg/crypto/gpg"
+)
+
+var (
+	// ErrNoProof is returned when no proof of possession exists for the current encryption key.
+	ErrNoProof = errors.New("no proof of possession of the gpg private key; run `stashly encryption prove`")
+
+	// ErrWrongAnswer is returned when the answer to a challenge doesn't match its code.
+	ErrWrongAnswer = errors.New("challenge answer does not match")
+)
+
+// codeBytes is the amount of randomness in a challenge code.
+const codeBytes = 16
+
+// Proof records the encryption keys whose private keys were shown to be available.
+type Proof struct {
+	// Fingerprints are the hex fingerprints of the proven encryption (sub)keys.
+	Fingerprints []string `json:"fingerprints"`
+
+	// ProvedAt is when the challenge was answered.
+	ProvedAt time.Time `json:"proved_at"`
+}
+
+// Fingerprints returns the fingerprints of the keys in publicKey that messages are encrypted to now.
+func Fingerprints(publicKey string) ([]string, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read armored key ring: %w", err)
+	}
+
+	fingerprints := make([]string, 0, len(entities))
+	for _, e := range entities {
+		key, ok := e.EncryptionKey(time.Now())
+		if !ok {
+			return nil, fmt.Errorf("key %X has no valid encryption key", e.PrimaryKey.Fingerprint)
+		}
+		fingerprints = append(fingerprints, fmt.Sprintf("%X", key.PublicKey.Fingerprint))
+	}
+	slices.Sort(fingerprints)
+	return fingerprints, nil
+}
+
+// NewChallenge encrypts a random code to publicKey and returns the code and the ASCII-armored message.
+func NewChallenge(publicKey string) (string, string, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(publicKey))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read armored key ring: %w", err)
+	}
+
+	raw := make([]byte, codeBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	code := hex.EncodeToString(raw)
+
+	var buf bytes.Buffer
+	armored, err := armor.Encode(&buf, gpg.GPGEncodeBlockType, nil)
+	if err != nil {
+		return "", "", err
+	}
+	plaintext, err := openpgp.Encrypt(armored, entities, nil, nil, nil)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := io.WriteString(plaintext, code+"\n"); err != nil {
+		return "", "", err
+	}
+	if err := plaintext.Close(); err != nil {
+		return "", "", err
+	}
+	if err := armored.Close(); err != nil {
+		return "", "", err
+	}
+	return code, buf.String(), nil
+}
+
+// Decrypt answers a challenge with an armored private key, unlocked with passphrase if it is protected.
+func Decrypt(challenge, privateKey, passphrase string) (string, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(privateKey))
+	if err != nil {
+		return "", fmt.Errorf("failed to read private key: %w", err)
+	}
+	for _, e := range entities {
+		if e.PrivateKey != nil && e.PrivateKey.Encrypted {
+			if err := e.DecryptPrivateKeys([]byte(passphrase)); err != nil {
+				return "", fmt.Errorf("failed to unlock private key: %w", err)
+			}
+		}
+	}
+
+	block, err := armor.Decode(strings.NewReader(challenge))
+	if err != nil {
+		return "", err
+	}
+	md, err := openpgp.ReadMessage(block.Body, entities, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	answer, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(answer)), nil
+}
+
+// Check compares an answer with the challenge code.
+func Check(code, answer string) error {
+	if strings.TrimSpace(strings.ToLower(answer)) != code {
+		return ErrWrongAnswer
+	}
+	return nil
+}
+
+// Load reads the proof at path. A missing file is ErrNoProof.
+func Load(path string) (*Proof, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoProof
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var p Proof
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("error parsing key proof %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+// Save writes the proof to path, replacing it atomically.
+func (p *Proof) Save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Verify checks that the proof at path covers every key messages to publicKey are encrypted to.
+// A key that was rotated since the proof needs a new one.
+func Verify(path, publicKey string) error {
+	p, err := Load(path)
+	if err != nil {
+		return err
+	}
+	fingerprints, err := Fingerprints(publicKey)
+	if err != nil {
+		return err
+	}
+	for _, fp := range fingerprints {
+		if !slices.Contains(p.Fingerprints, fp) {
+			return fmt.Errorf("%w (key %s is not proven)", ErrNoProof, fp)
+		}
+	}
+	return nil
+}
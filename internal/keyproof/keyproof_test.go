@@ -0,0 +1,72 @@
+package keyproof
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newKey generates a key pair and returns the armored public and private keys.
+func newKey(t *testing.T) (string, string) {
+	t.Helper()
+	entity, err := openpgp.NewEntity("stashly", "", "stashly@example.com", nil)
+	require.NoError(t, err)
+
+	var public, private bytes.Buffer
+	w, err := armor.Encode(&public, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+
+	w, err = armor.Encode(&private, openpgp.PrivateKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.SerializePrivate(w, nil))
+	require.NoError(t, w.Close())
+	return public.String(), private.String()
+}
+
+func TestChallenge_RoundTrip(t *testing.T) {
+	public, private := newKey(t)
+
+	code, challenge, err := NewChallenge(public)
+	require.NoError(t, err)
+	assert.Contains(t, challenge, "BEGIN PGP MESSAGE")
+	assert.NotContains(t, challenge, code)
+
+	answer, err := Decrypt(challenge, private, "")
+	require.NoError(t, err)
+	require.NoError(t, Check(code, answer))
+	require.ErrorIs(t, Check(code, "not-the-code"), ErrWrongAnswer)
+}
+
+func TestChallenge_OtherKeyCannotAnswer(t *testing.T) {
+	public, _ := newKey(t)
+	_, otherPrivate := newKey(t)
+
+	_, challenge, err := NewChallenge(public)
+	require.NoError(t, err)
+
+	_, err = Decrypt(challenge, otherPrivate, "")
+	require.Error(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	public, _ := newKey(t)
+	rotated, _ := newKey(t)
+	path := filepath.Join(t.TempDir(), "key-proof.json")
+
+	require.ErrorIs(t, Verify(path, public), ErrNoProof)
+
+	fingerprints, err := Fingerprints(public)
+	require.NoError(t, err)
+	require.NoError(t, (&Proof{Fingerprints: fingerprints, ProvedAt: time.Now()}).Save(path))
+
+	require.NoError(t, Verify(path, public))
+	require.ErrorIs(t, Verify(path, rotated), ErrNoProof)
+}
@@ -0,0 +1,320 @@
+// Package kubejob runs backups as one-shot Kubernetes Jobs using the in-cluster API.
+package kubejob
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hibare/stashly/internal/config"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	jobNamePrefix     = "stashly-backup-"
+	configMountPath   = "/etc/stashly"
+	pollInterval      = 5 * time.Second
+
+	// deleteTimeout bounds deleting a Job that outlived kubernetes.timeout.
+	deleteTimeout = 30 * time.Second
+)
+
+var (
+	// ErrNotInCluster is returned when the in-cluster service account environment is not available.
+	ErrNotInCluster = errors.New("not running inside a kubernetes cluster")
+
+	// ErrJobFailed is returned when the backup job finishes unsuccessfully.
+	ErrJobFailed = errors.New("backup job failed")
+)
+
+// RunnerIface runs a single backup to completion.
+type RunnerIface interface {
+	Run(ctx context.Context) (string, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// Runner creates backup Jobs and waits for them to complete.
+type Runner struct {
+	cfg          *config.KubernetesConfig
+	apiServer    string
+	tokenFile    string
+	namespace    string
+	httpClient   *http.Client
+	pollInterval time.Duration
+}
+
+// apiError is a non-2xx response from the API server.
+type apiError struct {
+	method, path string
+	status       int
+	msg          string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("kubernetes api %s %s: unexpected status %d: %s", e.method, e.path, e.status, e.msg)
+}
+
+// transient reports whether err may clear up by itself: a network error, a truncated response,
+// throttling or a server error.
+func transient(err error) bool {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr.status == http.StatusTooManyRequests || apiErr.status >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+type jobStatus struct {
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Active    int `json:"active"`
+}
+
+type job struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Status jobStatus `json:"status"`
+}
+
+// buildJob returns the Job manifest for a single backup run.
+func (r *Runner) buildJob() map[string]any {
+	container := map[string]any{
+		"name":  "stashly",
+		"image": r.cfg.Image,
+		"args":  []string{"backup"},
+	}
+
+	if len(r.cfg.Resources.Requests) > 0 || len(r.cfg.Resources.Limits) > 0 {
+		container["resources"] = map[string]any{
+			"requests": r.cfg.Resources.Requests,
+			"limits":   r.cfg.Resources.Limits,
+		}
+	}
+
+	if r.cfg.EnvFromSecret != "" {
+		container["envFrom"] = []any{
+			map[string]any{"secretRef": map[string]any{"name": r.cfg.EnvFromSecret}},
+		}
+	}
+
+	podSpec := map[string]any{
+		"restartPolicy": "Never",
+		"containers":    []any{container},
+	}
+
+	if r.cfg.ConfigMap != "" {
+		container["volumeMounts"] = []any{
+			map[string]any{"name": "config", "mountPath": configMountPath, "readOnly": true},
+		}
+		podSpec["volumes"] = []any{
+			map[string]any{"name": "config", "configMap": map[string]any{"name": r.cfg.ConfigMap}},
+		}
+	}
+
+	if len(r.cfg.NodeSelector) > 0 {
+		podSpec["nodeSelector"] = r.cfg.NodeSelector
+	}
+
+	if r.cfg.ServiceAccount != "" {
+		podSpec["serviceAccountName"] = r.cfg.ServiceAccount
+	}
+
+	spec := map[string]any{
+		"backoffLimit": 0,
+		"template": map[string]any{
+			"metadata": map[string]any{
+				"labels": map[string]string{"app.kubernetes.io/name": "stashly", "app.kubernetes.io/component": "backup"},
+			},
+			"spec": podSpec,
+		},
+	}
+
+	if r.cfg.TTLAfterFinished > 0 {
+		spec["ttlSecondsAfterFinished"] = int(r.cfg.TTLAfterFinished.Seconds())
+	}
+
+	return map[string]any{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata": map[string]any{
+			"generateName": jobNamePrefix,
+			"namespace":    r.namespace,
+			"labels":       map[string]string{"app.kubernetes.io/name": "stashly", "app.kubernetes.io/component": "backup"},
+		},
+		"spec": spec,
+	}
+}
+
+func (r *Runner) do(ctx context.Context, method, path string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, r.apiServer+path, reader)
+	if err != nil {
+		return err
+	}
+
+	// Projected service account tokens are rotated by the kubelet, so read it on every request.
+	token, err := os.ReadFile(r.tokenFile)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &apiError{method: method, path: path, status: resp.StatusCode, msg: strings.TrimSpace(string(msg))}
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (r *Runner) jobsPath() string {
+	return fmt.Sprintf("/apis/batch/v1/namespaces/%s/jobs", r.namespace)
+}
+
+// Run creates a backup Job and blocks until it succeeds, fails or times out. It returns the Job name.
+// Transient errors while polling are logged and polling goes on; a Job still running at
+// kubernetes.timeout is deleted.
+func (r *Runner) Run(ctx context.Context) (string, error) {
+	var created job
+	if err := r.do(ctx, http.MethodPost, r.jobsPath(), r.buildJob(), &created); err != nil {
+		return "", fmt.Errorf("error creating backup job: %w", err)
+	}
+
+	name := created.Metadata.Name
+	slog.InfoContext(ctx, "Created backup job", "job", name, "namespace", r.namespace)
+
+	waitCtx := ctx
+	if r.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, r.cfg.Timeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		var current job
+		err := r.do(waitCtx, http.MethodGet, r.jobsPath()+"/"+name, nil, &current)
+
+		switch {
+		case waitCtx.Err() != nil:
+			// The wait is over; handled below.
+		case err != nil && !transient(err):
+			return name, fmt.Errorf("error polling backup job %s: %w", name, err)
+		case err != nil:
+			slog.WarnContext(ctx, "Failed to poll backup job, retrying", "job", name, "error", err)
+		case current.Status.Succeeded > 0:
+			slog.InfoContext(ctx, "Backup job completed", "job", name)
+			return name, nil
+		case current.Status.Failed > 0:
+			return name, fmt.Errorf("%w: %s", ErrJobFailed, name)
+		}
+
+		select {
+		case <-waitCtx.Done():
+			// Only the timeout is handled here; when ctx itself is cancelled, the caller decides.
+			if ctx.Err() == nil {
+				r.deleteTimedOut(ctx, name)
+			}
+			return name, fmt.Errorf("waiting for backup job %s: %w", name, waitCtx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// deleteTimedOut deletes the Job name after the wait for it timed out, so it doesn't keep running
+// unwatched.
+func (r *Runner) deleteTimedOut(ctx context.Context, name string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), deleteTimeout)
+	defer cancel()
+	if err := r.Delete(ctx, name); err != nil {
+		slog.ErrorContext(ctx, "Failed to delete timed out backup job", "job", name, "error", err)
+	}
+}
+
+// Delete deletes the Job name along with its pods, stopping a backup that is still running.
+func (r *Runner) Delete(ctx context.Context, name string) error {
+	body := map[string]string{"propagationPolicy": "Background"}
+	if err := r.do(ctx, http.MethodDelete, r.jobsPath()+"/"+name, body, nil); err != nil {
+		return fmt.Errorf("error deleting backup job %s: %w", name, err)
+	}
+	slog.InfoContext(ctx, "Deleted backup job", "job", name, "namespace", r.namespace)
+	return nil
+}
+
+// NewInClusterRunner creates a Runner authenticated with the pod's service account.
+func NewInClusterRunner(cfg *config.KubernetesConfig) (*Runner, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, ErrNotInCluster
+	}
+
+	tokenFile := filepath.Join(serviceAccountDir, "token")
+	if _, err := os.Stat(tokenFile); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotInCluster, err)
+	}
+
+	caCert, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotInCluster, err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, errors.New("failed to parse kubernetes CA certificate")
+	}
+
+	namespace := cfg.Namespace
+	if namespace == "" {
+		ns, nErr := os.ReadFile(filepath.Join(serviceAccountDir, "namespace"))
+		if nErr != nil {
+			return nil, fmt.Errorf("namespace not configured and not discoverable: %w", nErr)
+		}
+		namespace = strings.TrimSpace(string(ns))
+	}
+
+	return &Runner{
+		cfg:       cfg,
+		apiServer: "https://" + net.JoinHostPort(host, port),
+		tokenFile: tokenFile,
+		namespace: namespace,
+		httpClient: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}},
+		},
+		pollInterval: pollInterval,
+	}, nil
+}
@@ -0,0 +1,281 @@
+package kubejob
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRunner(t *testing.T, srv *httptest.Server, cfg *config.KubernetesConfig) *Runner {
+	t.Helper()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("test-token\n"), 0o600))
+
+	return &Runner{
+		cfg:          cfg,
+		apiServer:    srv.URL,
+		tokenFile:    tokenFile,
+		namespace:    "backups",
+		httpClient:   srv.Client(),
+		pollInterval: time.Millisecond,
+	}
+}
+
+func TestRunner_buildJob(t *testing.T) {
+	r := &Runner{
+		namespace: "backups",
+		cfg: &config.KubernetesConfig{
+			Image:          "hibare/stashly:latest",
+			ServiceAccount: "stashly",
+			NodeSelector:   map[string]string{"pool": "batch"},
+			Resources: config.KubernetesResources{
+				Requests: map[string]string{"memory": "1Gi"},
+			},
+			EnvFromSecret:    "stashly-env",
+			ConfigMap:        "stashly-config",
+			TTLAfterFinished: time.Hour,
+		},
+	}
+
+	payload, err := json.Marshal(r.buildJob())
+	require.NoError(t, err)
+
+	var got struct {
+		Metadata struct {
+			GenerateName string `json:"generateName"`
+			Namespace    string `json:"namespace"`
+		} `json:"metadata"`
+		Spec struct {
+			TTL      int `json:"ttlSecondsAfterFinished"`
+			Template struct {
+				Spec struct {
+					ServiceAccountName string            `json:"serviceAccountName"`
+					NodeSelector       map[string]string `json:"nodeSelector"`
+					Containers         []struct {
+						Image     string   `json:"image"`
+						Args      []string `json:"args"`
+						Resources struct {
+							Requests map[string]string `json:"requests"`
+						} `json:"resources"`
+					} `json:"containers"`
+				} `json:"spec"`
+			} `json:"template"`
+		} `json:"spec"`
+	}
+	require.NoError(t, json.Unmarshal(payload, &got))
+
+	assert.Equal(t, jobNamePrefix, got.Metadata.GenerateName)
+	assert.Equal(t, "backups", got.Metadata.Namespace)
+	assert.Equal(t, 3600, got.Spec.TTL)
+	assert.Equal(t, "stashly", got.Spec.Template.Spec.ServiceAccountName)
+	assert.Equal(t, map[string]string{"pool": "batch"}, got.Spec.Template.Spec.NodeSelector)
+	require.Len(t, got.Spec.Template.Spec.Containers, 1)
+	assert.Equal(t, "hibare/stashly:latest", got.Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, []string{"backup"}, got.Spec.Template.Spec.Containers[0].Args)
+	assert.Equal(t, "1Gi", got.Spec.Template.Spec.Containers[0].Resources.Requests["memory"])
+}
+
+func TestRunner_Run_Succeeded(t *testing.T) {
+	var polls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/apis/batch/v1/namespaces/backups/jobs":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"metadata":{"name":"stashly-backup-abc"}}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/apis/batch/v1/namespaces/backups/jobs/stashly-backup-abc":
+			if polls.Add(1) < 3 {
+				_, _ = w.Write([]byte(`{"status":{"active":1}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"status":{"succeeded":1}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	r := newTestRunner(t, srv, &config.KubernetesConfig{Image: "stashly"})
+
+	name, err := r.Run(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, "stashly-backup-abc", name)
+	assert.Equal(t, int32(3), polls.Load())
+}
+
+func TestRunner_Run_Failed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"metadata":{"name":"stashly-backup-abc"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":{"failed":1}}`))
+	}))
+	defer srv.Close()
+
+	r := newTestRunner(t, srv, &config.KubernetesConfig{Image: "stashly"})
+
+	name, err := r.Run(context.Background())
+
+	require.ErrorIs(t, err, ErrJobFailed)
+	assert.Equal(t, "stashly-backup-abc", name)
+}
+
+func TestRunner_Run_TransientPollError(t *testing.T) {
+	var polls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"metadata":{"name":"stashly-backup-abc"}}`))
+			return
+		}
+		// The first GET fails as if the API server were briefly unavailable.
+		if polls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`etcdserver: leader changed`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":{"succeeded":1}}`))
+	}))
+	defer srv.Close()
+
+	r := newTestRunner(t, srv, &config.KubernetesConfig{Image: "stashly", Timeout: time.Minute})
+
+	name, err := r.Run(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, "stashly-backup-abc", name)
+	assert.Equal(t, int32(2), polls.Load())
+}
+
+func TestRunner_Run_PollForbidden(t *testing.T) {
+	var polls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"metadata":{"name":"stashly-backup-abc"}}`))
+			return
+		}
+		polls.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`jobs.batch "stashly-backup-abc" is forbidden`))
+	}))
+	defer srv.Close()
+
+	r := newTestRunner(t, srv, &config.KubernetesConfig{Image: "stashly"})
+
+	_, err := r.Run(context.Background())
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "403")
+	assert.Equal(t, int32(1), polls.Load())
+}
+
+func TestRunner_Run_TimeoutDeletesJob(t *testing.T) {
+	var deleted atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"metadata":{"name":"stashly-backup-abc"}}`))
+		case http.MethodDelete:
+			assert.Equal(t, "/apis/batch/v1/namespaces/backups/jobs/stashly-backup-abc", r.URL.Path)
+			deleted.Store(true)
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			_, _ = w.Write([]byte(`{"status":{"active":1}}`))
+		}
+	}))
+	defer srv.Close()
+
+	r := newTestRunner(t, srv, &config.KubernetesConfig{Image: "stashly", Timeout: 20 * time.Millisecond})
+
+	name, err := r.Run(context.Background())
+
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, "stashly-backup-abc", name)
+	assert.True(t, deleted.Load(), "timed out job wasn't deleted")
+}
+
+func TestRunner_Run_CancelledKeepsJob(t *testing.T) {
+	// Cancellation is left to the caller, which deletes the Job itself.
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"metadata":{"name":"stashly-backup-abc"}}`))
+		case http.MethodDelete:
+			t.Error("job deleted on cancellation")
+		default:
+			cancel()
+			_, _ = w.Write([]byte(`{"status":{"active":1}}`))
+		}
+	}))
+	defer srv.Close()
+
+	r := newTestRunner(t, srv, &config.KubernetesConfig{Image: "stashly", Timeout: time.Minute})
+
+	_, err := r.Run(ctx)
+
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRunner_Run_CreateForbidden(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`jobs.batch is forbidden`))
+	}))
+	defer srv.Close()
+
+	r := newTestRunner(t, srv, &config.KubernetesConfig{Image: "stashly"})
+
+	name, err := r.Run(context.Background())
+
+	require.Error(t, err)
+	assert.Empty(t, name)
+	assert.Contains(t, err.Error(), "403")
+	assert.Contains(t, err.Error(), "forbidden")
+}
+
+func TestRunner_Delete(t *testing.T) {
+	var body map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Path != "/apis/batch/v1/namespaces/backups/jobs/stashly-backup-abc" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	r := newTestRunner(t, srv, &config.KubernetesConfig{Image: "stashly"})
+
+	require.NoError(t, r.Delete(context.Background(), "stashly-backup-abc"))
+	assert.Equal(t, "Background", body["propagationPolicy"])
+	require.Error(t, r.Delete(context.Background(), "stashly-backup-missing"))
+}
+
+func TestNewInClusterRunner_NotInCluster(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "")
+
+	_, err := NewInClusterRunner(&config.KubernetesConfig{})
+
+	require.ErrorIs(t, err, ErrNotInCluster)
+}
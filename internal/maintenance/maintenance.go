@@ -0,0 +1,147 @@
+// Package maintenance persists maintenance mode, during which scheduled backups are skipped so
+// operators can work on storage without racing the scheduler.
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hibare/stashly/internal/server"
+)
+
+// State is the persisted maintenance mode.
+type State struct {
+	Enabled bool `json:"enabled"`
+
+	// Reason is a free-form note from whoever enabled maintenance mode.
+	Reason string `json:"reason,omitempty"`
+
+	// Since is when maintenance mode was last switched on or off.
+	Since time.Time `json:"since,omitzero"`
+}
+
+// Load reads the state at path. A missing file means maintenance mode is off.
+func Load(path string) (State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return State{}, nil
+	}
+	if err != nil {
+		return State{}, err
+	}
+
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return State{}, fmt.Errorf("error parsing maintenance state %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Save writes the state to path, replacing it atomically.
+func Save(path string, s State) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Set switches maintenance mode on or off, recording reason and the time of the change.
+func Set(path string, enabled bool, reason string) (State, error) {
+	s := State{Enabled: enabled, Since: time.Now().UTC()}
+	if enabled {
+		s.Reason = reason
+	}
+	return s, Save(path, s)
+}
+
+// Enabled reports whether maintenance mode is on. A state that can't be read counts as off, so a
+// broken file doesn't silently stop backups.
+func Enabled(ctx context.Context, path string) bool {
+	s, err := Load(path)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to read maintenance state; assuming maintenance mode is off", "path", path, "error", err)
+		return false
+	}
+	return s.Enabled
+}
+
+// handler serves the maintenance state; see NewHandler.
+type handler struct {
+	path   string
+	change http.Handler
+}
+
+// NewHandler returns a handler reporting the state at path on GET, enabling maintenance mode on PUT
+// with an optional {"reason": "..."} body, and disabling it on DELETE. Changes need token as a bearer
+// token and are refused if token is empty.
+func NewHandler(path, token string) http.Handler {
+	h := &handler{path: path}
+	h.change = server.RequireToken(token, http.HandlerFunc(h.set))
+	return h
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		s, err := Load(h.path)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, s)
+		return
+	}
+
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	h.change.ServeHTTP(w, r)
+}
+
+// set enables maintenance mode on PUT and disables it on DELETE.
+func (h *handler) set(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if r.Method == http.MethodPut && r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid body: "+err.Error())
+			return
+		}
+	}
+
+	s, err := Set(h.path, r.Method == http.MethodPut, body.Reason)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	slog.InfoContext(r.Context(), "Maintenance mode changed", "enabled", s.Enabled, "reason", s.Reason)
+	writeJSON(w, http.StatusOK, s)
+}
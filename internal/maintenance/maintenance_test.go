@@ -0,0 +1,85 @@
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "maintenance.json")
+
+	s, err := Load(path)
+	require.NoError(t, err)
+	assert.False(t, s.Enabled)
+
+	_, err = Set(path, true, "migrating bucket")
+	require.NoError(t, err)
+	s, err = Load(path)
+	require.NoError(t, err)
+	assert.True(t, s.Enabled)
+	assert.Equal(t, "migrating bucket", s.Reason)
+	assert.True(t, Enabled(context.Background(), path))
+
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0600))
+	assert.False(t, Enabled(context.Background(), path))
+}
+
+func serve(h http.Handler, method, token, body string) (*httptest.ResponseRecorder, State) {
+	req := httptest.NewRequest(method, "/maintenance", strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var s State
+	_ = json.Unmarshal(rec.Body.Bytes(), &s)
+	return rec, s
+}
+
+func TestHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "maintenance.json")
+	h := NewHandler(path, "secret")
+
+	rec, s := serve(h, http.MethodGet, "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.False(t, s.Enabled)
+
+	rec, _ = serve(h, http.MethodPut, "", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec, _ = serve(h, http.MethodPut, "wrong", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec, s = serve(h, http.MethodPut, "secret", `{"reason":"storage migration"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, s.Enabled)
+	assert.True(t, Enabled(context.Background(), path))
+
+	rec, s = serve(h, http.MethodGet, "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "storage migration", s.Reason)
+
+	rec, s = serve(h, http.MethodDelete, "secret", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.False(t, s.Enabled)
+	assert.False(t, Enabled(context.Background(), path))
+
+	rec, _ = serve(h, http.MethodPost, "secret", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestHandler_NoToken(t *testing.T) {
+	h := NewHandler(filepath.Join(t.TempDir(), "maintenance.json"), "")
+
+	rec, _ := serve(h, http.MethodPut, "", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
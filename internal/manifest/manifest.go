@@ -0,0 +1,255 @@
+// Package manifest describes the metadata file stored alongside each backup archive.
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SnapshotLabel is the label holding the deploy ID of a snapshot.
+const SnapshotLabel = "deploy-id"
+
+// FileName is the name of the manifest object stored next to the archive.
+const FileName = "manifest.json"
+
+// SealedFileName is the name of the encrypted full manifest stored next to a plaintext index.
+const SealedFileName = FileName + ".gpg"
+
+// Version is the current manifest format version.
+const Version = 1
+
+var (
+	// ErrNotFound is returned when a backup has no manifest (e.g. it predates manifests).
+	ErrNotFound = errors.New("manifest not found")
+
+	// ErrInvalidLabel is returned when a label is not in key=value form.
+	ErrInvalidLabel = errors.New("invalid label, expected key=value")
+
+	// ErrChecksumMismatch is returned when a decrypted manifest doesn't match the checksum in its index.
+	ErrChecksumMismatch = errors.New("manifest checksum mismatch")
+)
+
+// DatabaseInfo records how a database was created, so a restore can recreate it the same way.
+type DatabaseInfo struct {
+	Owner    string `json:"owner,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Collate  string `json:"collate,omitempty"`
+	CType    string `json:"ctype,omitempty"`
+}
+
+// Import describes the external dump a backup was imported from with `stashly import`.
+type Import struct {
+	// Source is the file name of the imported dump or archive.
+	Source string `json:"source"`
+
+	// SHA256 is the hex-encoded SHA-256 of the imported file.
+	SHA256 string `json:"sha256"`
+}
+
+// Compression records how well a backup's dumps compressed. A sudden drop in ratio can mean data that
+// is already encrypted or compressed at the source, or corrupt.
+type Compression struct {
+	// RawSize is the size in bytes of the dumps before archiving and CompressedSize the size of the
+	// archive, before encryption.
+	RawSize        int64 `json:"raw_size"`
+	CompressedSize int64 `json:"compressed_size"`
+
+	// Ratio is RawSize divided by CompressedSize.
+	Ratio float64 `json:"ratio"`
+
+	// Databases records the same for each database. Tarballs are compressed as a whole, so only zip
+	// archives have compressed sizes per database.
+	Databases map[string]DatabaseCompression `json:"databases,omitempty"`
+}
+
+// DatabaseCompression records how well one database's dump compressed.
+type DatabaseCompression struct {
+	RawSize        int64   `json:"raw_size"`
+	CompressedSize int64   `json:"compressed_size,omitempty"`
+	Ratio          float64 `json:"ratio,omitempty"`
+}
+
+// Provenance records what produced a backup and where, so a restored dump's origin can be audited
+// long after it was taken.
+type Provenance struct {
+	// Stashly is the version of the binary that took the backup, Commit its source revision.
+	Stashly   string `json:"stashly"`
+	Commit    string `json:"commit,omitempty"`
+	GoVersion string `json:"go_version"`
+
+	// Tools maps the tools the backup went through (e.g. pg_dump, the compressor, the OpenPGP
+	// library) to the version they report.
+	Tools map[string]string `json:"tools,omitempty"`
+
+	Hostname string `json:"hostname,omitempty"`
+	OS       string `json:"os"`
+	Arch     string `json:"arch"`
+
+	// ConfigHash is the hex SHA-256 of the configuration with credentials removed; equal hashes mean
+	// two backups were taken with the same settings.
+	ConfigHash string `json:"config_hash,omitempty"`
+}
+
+// Sealed points a plaintext index to the encrypted full manifest.
+type Sealed struct {
+	// File is the name of the encrypted manifest.
+	File string `json:"file"`
+
+	// SHA256 is the hex-encoded SHA-256 of the full manifest before encryption.
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest holds metadata about a single backup.
+type Manifest struct {
+	Version    int               `json:"version"`
+	Engine     string            `json:"engine"`
+	Timestamp  string            `json:"timestamp"`
+	InstanceID string            `json:"instance_id"`
+	CreatedAt  time.Time         `json:"created_at"`
+	Archive    string            `json:"archive"`
+	Encrypted  bool              `json:"encrypted"`
+	Size       int64             `json:"size"`
+	Databases  []string          `json:"databases"`
+	Labels     map[string]string `json:"labels,omitempty"`
+
+	// Inventory maps databases to the settings they were created with.
+	Inventory map[string]DatabaseInfo `json:"inventory,omitempty"`
+
+	// Volumes lists, in order, the files the archive was split into; empty if it was uploaded whole.
+	Volumes []string `json:"volumes,omitempty"`
+
+	// SHA256 is the hex-encoded SHA-256 of the archive as uploaded, before it was split into volumes.
+	// Empty in manifests that predate it.
+	SHA256 string `json:"sha256,omitempty"`
+
+	// Compressor is the archive's compression format: "zip", or "gzip"/"zstd" for tarballs written by an
+	// external compressor. Empty in manifests that predate it, which are always zip archives.
+	Compressor string `json:"compressor,omitempty"`
+
+	// Framed is set when the archive is encrypted in authenticated frames rather than as one OpenPGP
+	// message.
+	Framed bool `json:"framed,omitempty"`
+
+	// Format is the engine's dump format (e.g. "plain"). Empty in manifests that predate it, which are
+	// always plain postgres dumps.
+	Format string `json:"format,omitempty"`
+
+	// Extensions maps databases to the extensions they use that need special handling on restore.
+	Extensions map[string][]string `json:"extensions,omitempty"`
+
+	// SnapshotLSN maps databases dumped from a replication slot snapshot to the LSN the dump is consistent with,
+	// where a CDC pipeline can start streaming from.
+	SnapshotLSN map[string]string `json:"snapshot_lsn,omitempty"`
+
+	// RestoreNotes are instructions for restoring the dumps by hand, e.g. with psql.
+	RestoreNotes []string `json:"restore_notes,omitempty"`
+
+	// Snapshot is set for on-demand snapshots, which expire on their own TTL rather than the retention count.
+	Snapshot bool `json:"snapshot,omitempty"`
+
+	// Uncovered maps databases that were found on the server but not backed up to the reason why.
+	Uncovered map[string]string `json:"uncovered,omitempty"`
+
+	// Import is set on backups imported from an external dump.
+	Import *Import `json:"import,omitempty"`
+
+	// FirstBackup is set on the first backup taken for the instance, when its coverage started.
+	FirstBackup bool `json:"first_backup,omitempty"`
+
+	// Provenance records the tools, host and configuration that produced the backup. Nil in manifests
+	// that predate it.
+	Provenance *Provenance `json:"provenance,omitempty"`
+
+	// Sealed is set on a plaintext index whose full manifest is encrypted.
+	Sealed *Sealed `json:"sealed,omitempty"`
+
+	// Compression records how well the dumps compressed. Nil in manifests that predate it.
+	Compression *Compression `json:"compression,omitempty"`
+}
+
+// Index returns the plaintext index stored in place of a manifest that is encrypted. It keeps what
+// listing, retention, tiering and downloading need, and drops what reveals the contents: database
+// names and settings, extensions, restore notes, import source and provenance.
+func (m *Manifest) Index(sealed Sealed) *Manifest {
+	return &Manifest{
+		Version:     m.Version,
+		Engine:      m.Engine,
+		Format:      m.Format,
+		Timestamp:   m.Timestamp,
+		InstanceID:  m.InstanceID,
+		CreatedAt:   m.CreatedAt,
+		Archive:     m.Archive,
+		Volumes:     m.Volumes,
+		SHA256:      m.SHA256,
+		Compressor:  m.Compressor,
+		Encrypted:   m.Encrypted,
+		Framed:      m.Framed,
+		Size:        m.Size,
+		Labels:      m.Labels,
+		Snapshot:    m.Snapshot,
+		FirstBackup: m.FirstBackup,
+		Sealed:      &sealed,
+	}
+}
+
+// MatchLabels reports whether the manifest carries every label in filter.
+func (m *Manifest) MatchLabels(filter map[string]string) bool {
+	for k, v := range filter {
+		if got, ok := m.Labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Write writes the manifest as JSON to path.
+func (m *Manifest) Write(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Read reads a manifest from path.
+func Read(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("error parsing manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// ParseLabels parses key=value pairs into a label map.
+func ParseLabels(pairs []string) (map[string]string, error) {
+	labels := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		k, v, ok := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidLabel, pair)
+		}
+		labels[k] = strings.TrimSpace(v)
+	}
+	return labels, nil
+}
+
+// FormatLabels formats labels as sorted, comma separated key=value pairs.
+func FormatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,53 @@
+package manifest
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels([]string{"release=v2.3", "env = prod", "empty="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"release": "v2.3", "env": "prod", "empty": ""}, labels)
+
+	_, err = ParseLabels([]string{"novalue"})
+	require.ErrorIs(t, err, ErrInvalidLabel)
+
+	_, err = ParseLabels([]string{"=v"})
+	require.ErrorIs(t, err, ErrInvalidLabel)
+}
+
+func TestFormatLabels(t *testing.T) {
+	assert.Equal(t, "a=1,b=2", FormatLabels(map[string]string{"b": "2", "a": "1"}))
+	assert.Empty(t, FormatLabels(nil))
+}
+
+func TestManifest_MatchLabels(t *testing.T) {
+	m := &Manifest{Labels: map[string]string{"release": "v2.3", "env": "prod"}}
+
+	assert.True(t, m.MatchLabels(nil))
+	assert.True(t, m.MatchLabels(map[string]string{"release": "v2.3"}))
+	assert.False(t, m.MatchLabels(map[string]string{"release": "v2.4"}))
+	assert.False(t, m.MatchLabels(map[string]string{"team": "db"}))
+}
+
+func TestManifest_WriteRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	m := &Manifest{
+		Version:   Version,
+		Timestamp: "20250101000000",
+		CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Archive:   "db_exports.zip",
+		Databases: []string{"db1"},
+		Labels:    map[string]string{"release": "v2.3"},
+	}
+
+	require.NoError(t, m.Write(path))
+	got, err := Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, m, got)
+}
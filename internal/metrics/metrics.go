@@ -0,0 +1,187 @@
+// Package metrics defines the Prometheus metrics exported by Stashly.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "stashly"
+
+// Registry holds all Stashly metrics.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// StorageOperationDuration observes the duration of storage backend calls.
+	StorageOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of storage backend operations.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600},
+	}, []string{"backend", "operation"})
+
+	// StorageOperationErrors counts failed storage backend calls.
+	StorageOperationErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "operation_errors_total",
+		Help:      "Number of failed storage backend operations.",
+	}, []string{"backend", "operation"})
+
+	// StorageRetries counts storage backend calls retried after a transient error.
+	StorageRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "retries_total",
+		Help:      "Number of storage backend operations retried after a transient error.",
+	}, []string{"backend", "operation", "class"})
+
+	// StorageFailovers counts uploads sent to the secondary backend after the primary kept failing.
+	StorageFailovers = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "failovers_total",
+		Help:      "Number of uploads that failed over to the secondary storage backend.",
+	}, []string{"backend", "secondary"})
+
+	// BackupDuration observes the duration of successful backup runs.
+	BackupDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "backup",
+		Name:      "duration_seconds",
+		Help:      "Duration of successful backup runs.",
+		Buckets:   []float64{10, 30, 60, 300, 600, 1800, 3600, 7200, 14400, 28800},
+	})
+
+	// BackupCompressionRatio is the compression ratio of the last backup's archive.
+	BackupCompressionRatio = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "backup",
+		Name:      "compression_ratio",
+		Help:      "Raw dump size divided by archive size for the last successful backup.",
+	})
+
+	// DatabaseCompressionRatio is the compression ratio of each database's dump in the last zip backup.
+	DatabaseCompressionRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "backup",
+		Name:      "database_compression_ratio",
+		Help:      "Raw dump size divided by compressed size per database for the last successful zip backup.",
+	}, []string{"database"})
+
+	// DatabaseRetries counts database dumps retried after a transient failure, by whether the retry
+	// succeeded.
+	DatabaseRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "backup",
+		Name:      "database_retries_total",
+		Help:      "Number of database dumps retried after a transient failure.",
+	}, []string{"outcome"})
+
+	// BackupSlowRuns counts successful backup runs that exceeded backup.duration-warning.
+	BackupSlowRuns = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "backup",
+		Name:      "slow_runs_total",
+		Help:      "Number of backup runs that exceeded the configured duration warning threshold.",
+	})
+
+	// BackupClockSkew counts backups whose timestamp had to be moved past the latest backup because
+	// the clock was behind it.
+	BackupClockSkew = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "backup",
+		Name:      "clock_skew_total",
+		Help:      "Number of backups whose timestamp was moved past the latest backup because the clock was behind it.",
+	})
+
+	// EncryptionKeyExpiry is when the GPG key backups are encrypted to expires, as a Unix timestamp.
+	EncryptionKeyExpiry = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "encryption",
+		Name:      "key_expiry_timestamp_seconds",
+		Help:      "Unix time the GPG key backups are encrypted to expires; 0 if it never expires.",
+	})
+
+	// BackupRPO is the age of each instance's newest backup in seconds.
+	BackupRPO = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "backup",
+		Name:      "rpo_seconds",
+		Help:      "Age of the newest backup, the recovery point a restore would achieve.",
+	}, []string{"instance"})
+
+	// RestoreRTO is how long each instance's last restore drill that passed took, in seconds.
+	RestoreRTO = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "restore",
+		Name:      "rto_seconds",
+		Help:      "Duration of the last restore drill that passed, the measured recovery time.",
+	}, []string{"instance"})
+
+	// DrillRuns counts restore drills by result, "passed" or "failed".
+	DrillRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "drill",
+		Name:      "runs_total",
+		Help:      "Number of restore drills by result.",
+	}, []string{"result"})
+
+	// DrillLastSuccess is when the last restore drill that passed started, as a Unix timestamp.
+	DrillLastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "drill",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time the last restore drill that passed started; 0 if none has.",
+	})
+
+	// WatchdogTrips counts backup runs that exceeded backup.max-run-duration.
+	WatchdogTrips = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "watchdog_trips_total",
+		Help:      "Number of backup runs that exceeded the configured max run duration.",
+	})
+
+	// CancelledJobs counts backup runs cancelled through the jobs API.
+	CancelledJobs = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "cancelled_jobs_total",
+		Help:      "Number of backup runs cancelled on request.",
+	})
+)
+
+// Handler returns an HTTP handler serving the registry in Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+func init() {
+	Registry.MustRegister(
+		StorageOperationDuration,
+		StorageOperationErrors,
+		StorageRetries,
+		StorageFailovers,
+		BackupDuration,
+		BackupSlowRuns,
+		BackupClockSkew,
+		BackupCompressionRatio,
+		DatabaseCompressionRatio,
+		DatabaseRetries,
+		EncryptionKeyExpiry,
+		BackupRPO,
+		RestoreRTO,
+		DrillRuns,
+		DrillLastSuccess,
+		WatchdogTrips,
+		CancelledJobs,
+		// Goroutines, heap, GC and process memory, to spot leaks in the long-running daemon.
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
@@ -0,0 +1,64 @@
+package notifiers
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var digitsRe = regexp.MustCompile(`[0-9]+`)
+
+// dedup suppresses repeated notifications for the same event and error class within a window.
+type dedup struct {
+	mu     sync.Mutex
+	window time.Duration
+	seen   map[string]time.Time
+	now    func() time.Time
+}
+
+// errorClass normalises an error message so that errors differing only in
+// volatile parts (timestamps, keys, counts) are treated as the same class.
+func errorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+	return digitsRe.ReplaceAllString(strings.TrimSpace(err.Error()), "#")
+}
+
+// allow reports whether a notification for the given event and error should be sent,
+// recording it as sent when it is.
+func (d *dedup) allow(event string, err error) bool {
+	if d == nil || d.window <= 0 {
+		return true
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	key := event + "|" + errorClass(err)
+
+	if last, ok := d.seen[key]; ok && now.Sub(last) < d.window {
+		return false
+	}
+
+	d.seen[key] = now
+
+	// Drop entries that have aged out so the map doesn't grow unbounded in long-running processes.
+	for k, t := range d.seen {
+		if now.Sub(t) >= d.window {
+			delete(d.seen, k)
+		}
+	}
+
+	return true
+}
+
+func newDedup(window time.Duration) *dedup {
+	return &dedup{
+		window: window,
+		seen:   map[string]time.Time{},
+		now:    time.Now,
+	}
+}
@@ -0,0 +1,45 @@
+package notifiers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorClass(t *testing.T) {
+	a := errorClass(errors.New("error deleting backup 20240101000000: timeout"))
+	b := errorClass(errors.New("error deleting backup 20240102000000: timeout"))
+	assert.Equal(t, a, b)
+	assert.NotEqual(t, a, errorClass(errors.New("access denied")))
+}
+
+func TestDedup_Allow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := newDedup(10 * time.Minute)
+	d.now = func() time.Time { return now }
+
+	err := errors.New("connection refused")
+
+	assert.True(t, d.allow("backup_failure", err))
+	assert.False(t, d.allow("backup_failure", err))
+
+	// A different event with the same error is not suppressed
+	assert.True(t, d.allow("backup_delete_failure", err))
+
+	// A different error class is not suppressed
+	assert.True(t, d.allow("backup_failure", errors.New("disk full")))
+
+	// Once the window elapses the notification is sent again
+	now = now.Add(10 * time.Minute)
+	assert.True(t, d.allow("backup_failure", err))
+}
+
+func TestDedup_Disabled(t *testing.T) {
+	d := newDedup(0)
+	err := errors.New("connection refused")
+
+	assert.True(t, d.allow("backup_failure", err))
+	assert.True(t, d.allow("backup_failure", err))
+}
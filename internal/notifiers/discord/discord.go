@@ -4,23 +4,43 @@ package discord
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
+	commonHTTP "github.com/hibare/GoCommon/v2/pkg/http"
 	"github.com/hibare/GoCommon/v2/pkg/notifiers/discord"
 	"github.com/hibare/stashly/internal/config"
 	"github.com/hibare/stashly/internal/constants"
+	"github.com/hibare/stashly/internal/httpclient"
+	"github.com/hibare/stashly/internal/notifiers/events"
+	"github.com/hibare/stashly/internal/remediation"
+	"github.com/hibare/stashly/internal/units"
 )
 
 const (
 	successColor         = 1498748
 	failureColor         = 14554702
 	deletionFailureColor = 14590998
+	warningColor         = 16763904
+	coverageColor        = 3447003
+	restoreColor         = 10181046
 )
 
 // Discord sends notifications to a Discord channel via webhook.
 type Discord struct {
 	Cfg    *config.Config
 	client discord.ClientIface
+
+	// failureClient posts to the configured failure thread, falling back to client.
+	failureClient discord.ClientIface
+}
+
+// Name returns the notifier name.
+func (d *Discord) Name() string {
+	return "discord"
 }
 
 // Enabled checks if the Discord notifier is enabled in the configuration.
@@ -28,21 +48,225 @@ func (d *Discord) Enabled() bool {
 	return d.Cfg.Notifiers.Discord.Enabled
 }
 
+// RateLimit returns the configured maximum number of messages per hour.
+func (d *Discord) RateLimit() int {
+	return d.Cfg.Notifiers.Discord.MaxPerHour
+}
+
 // NotifyBackupSuccess sends a success notification to the Discord channel.
-func (d *Discord) NotifyBackupSuccess(ctx context.Context, databases int, key string) error {
+func (d *Discord) NotifyBackupSuccess(ctx context.Context, evt events.BackupSuccess) error {
+	fields := []discord.EmbedField{
+		{
+			Name:   "Key",
+			Value:  evt.Key,
+			Inline: false,
+		},
+		{
+			Name:   "Databases",
+			Value:  strconv.Itoa(evt.Databases),
+			Inline: false,
+		},
+		{
+			Name:   "Size",
+			Value:  units.FormatBytes(evt.Size),
+			Inline: true,
+		},
+		{
+			Name:   "Duration",
+			Value:  evt.Duration.Round(time.Second).String(),
+			Inline: true,
+		},
+	}
+
+	switch {
+	case len(evt.Results) > 0:
+		fields = append(fields, discord.EmbedField{
+			Name:   "Breakdown",
+			Value:  formatResults(evt.Results),
+			Inline: false,
+		})
+	case len(evt.Skipped) > 0:
+		fields = append(fields, discord.EmbedField{
+			Name:   "Skipped",
+			Value:  formatSkipped(evt.Skipped),
+			Inline: false,
+		})
+	}
+
+	color := successColor
+	content := fmt.Sprintf("**PG-DB Backup Successful** - *%s*", d.Cfg.App.InstanceID)
+	if evt.FailedOver != "" {
+		fields = append(fields, discord.EmbedField{
+			Name:   "Failed Over",
+			Value:  fmt.Sprintf("Uploaded to %s because the primary storage failed", evt.FailedOver),
+			Inline: false,
+		})
+		color = warningColor
+		content = fmt.Sprintf("**PG-DB Backup Successful on Secondary Storage** - *%s*", d.Cfg.App.InstanceID)
+	}
+
 	message := discord.Message{
 		Embeds: []discord.Embed{
 			{
-				Color: successColor,
+				Color:  color,
+				Fields: fields,
+			},
+		},
+		Components: []discord.Component{},
+		Username:   constants.ProgramIdentifier,
+		Content:    content,
+	}
+
+	return d.send(ctx, d.client, &message)
+}
+
+// send stamps the message with the current time and sends it using client.
+func (d *Discord) send(ctx context.Context, client discord.ClientIface, message *discord.Message) error {
+	if err := message.AddFooter(events.FormatTime(time.Now(), d.Cfg.Notifiers)); err != nil {
+		return err
+	}
+	return client.Send(ctx, message)
+}
+
+// withMentions prefixes content with the configured role/user mentions.
+func (d *Discord) withMentions(content string) string {
+	var mentions []string
+	if role := d.Cfg.Notifiers.Discord.MentionRole; role != "" {
+		mentions = append(mentions, fmt.Sprintf("<@&%s>", role))
+	}
+	if user := d.Cfg.Notifiers.Discord.MentionUser; user != "" {
+		mentions = append(mentions, fmt.Sprintf("<@%s>", user))
+	}
+
+	if len(mentions) == 0 {
+		return content
+	}
+	return strings.Join(mentions, " ") + " " + content
+}
+
+// maxFieldLength is the longest value Discord accepts in an embed field.
+const maxFieldLength = 1024
+
+// formatResults renders per-database results as a monospaced table, dropping rows that don't fit in a field.
+func formatResults(results []events.DatabaseResult) string {
+	rows := make([][3]string, 0, len(results))
+	width := [2]int{}
+	for _, r := range results {
+		detail := r.Reason
+		if r.Status == events.DatabaseOK {
+			detail = units.FormatBytes(r.Size)
+		}
+		rows = append(rows, [3]string{r.Name, r.Status, detail})
+		width[0] = max(width[0], len(r.Name))
+		width[1] = max(width[1], len(r.Status))
+	}
+
+	const fence = "```"
+	var b strings.Builder
+	b.WriteString(fence + "\n")
+	for i, row := range rows {
+		line := fmt.Sprintf("%-*s  %-*s  %s\n", width[0], row[0], width[1], row[1], row[2])
+		more := fmt.Sprintf("... %d more\n", len(rows)-i)
+		if b.Len()+len(line)+len(more)+len(fence) > maxFieldLength {
+			b.WriteString(more)
+			break
+		}
+		b.WriteString(line)
+	}
+	b.WriteString(fence)
+	return b.String()
+}
+
+func formatSkipped(skipped map[string]string) string {
+	lines := make([]string, 0, len(skipped))
+	for db, reason := range skipped {
+		lines = append(lines, fmt.Sprintf("%s: %s", db, reason))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// errorEmbed describes a failure, with a remediation hint when its failure class is known.
+func errorEmbed(err error, color int) discord.Embed {
+	embed := discord.Embed{Title: "Error", Description: err.Error(), Color: color}
+	if hint, ok := remediation.For(err); ok {
+		embed.Fields = []discord.EmbedField{{Name: "Hint", Value: hint.Text, Inline: false}}
+	}
+	return embed
+}
+
+// NotifyBackupFailure sends a failure notification to the Discord channel.
+func (d *Discord) NotifyBackupFailure(ctx context.Context, err error) error {
+	message := discord.Message{
+		Embeds:     []discord.Embed{errorEmbed(err, failureColor)},
+		Components: []discord.Component{},
+		Username:   constants.ProgramIdentifier,
+		Content:    d.withMentions(fmt.Sprintf("**PG-DB Backup Failed** - *%s*", d.Cfg.App.InstanceID)),
+	}
+
+	return d.send(ctx, d.failureClient, &message)
+}
+
+// NotifyBackupDeleteFailure sends a deletion failure notification to the Discord channel.
+func (d *Discord) NotifyBackupDeleteFailure(ctx context.Context, err error) error {
+	message := discord.Message{
+		Embeds:     []discord.Embed{errorEmbed(err, deletionFailureColor)},
+		Components: []discord.Component{},
+		Username:   constants.ProgramIdentifier,
+		Content:    d.withMentions(fmt.Sprintf("**PG-DB Backup Deletion Failed** - *%s*", d.Cfg.App.InstanceID)),
+	}
+
+	return d.send(ctx, d.failureClient, &message)
+}
+
+// NotifyBackupSlow sends a warning that a backup exceeded the configured duration threshold.
+func (d *Discord) NotifyBackupSlow(ctx context.Context, evt events.BackupSlow) error {
+	message := discord.Message{
+		Embeds: []discord.Embed{
+			{
+				Color: warningColor,
 				Fields: []discord.EmbedField{
 					{
 						Name:   "Key",
-						Value:  key,
+						Value:  evt.Key,
+						Inline: false,
+					},
+					{
+						Name:   "Duration",
+						Value:  evt.Duration.Round(time.Second).String(),
+						Inline: true,
+					},
+					{
+						Name:   "Threshold",
+						Value:  evt.Threshold.String(),
+						Inline: true,
+					},
+				},
+			},
+		},
+		Components: []discord.Component{},
+		Username:   constants.ProgramIdentifier,
+		Content:    fmt.Sprintf("**PG-DB Backup Slow** - *%s*", d.Cfg.App.InstanceID),
+	}
+
+	return d.send(ctx, d.client, &message)
+}
+
+// NotifyCoverageStarted announces that a new instance produced its first backup.
+func (d *Discord) NotifyCoverageStarted(ctx context.Context, evt events.CoverageStarted) error {
+	message := discord.Message{
+		Embeds: []discord.Embed{
+			{
+				Color: coverageColor,
+				Fields: []discord.EmbedField{
+					{
+						Name:   "Key",
+						Value:  evt.Key,
 						Inline: false,
 					},
 					{
 						Name:   "Databases",
-						Value:  strconv.Itoa(databases),
+						Value:  strings.Join(evt.Databases, ", "),
 						Inline: false,
 					},
 				},
@@ -50,59 +274,252 @@ func (d *Discord) NotifyBackupSuccess(ctx context.Context, databases int, key st
 		},
 		Components: []discord.Component{},
 		Username:   constants.ProgramIdentifier,
-		Content:    fmt.Sprintf("**PG-DB Backup Successful** - *%s*", d.Cfg.App.InstanceID),
+		Content:    fmt.Sprintf("**PG-DB Backup Coverage Started** - *%s*", evt.InstanceID),
 	}
 
-	return d.client.Send(ctx, &message)
+	return d.send(ctx, d.client, &message)
 }
 
-// NotifyBackupFailure sends a failure notification to the Discord channel.
-func (d *Discord) NotifyBackupFailure(ctx context.Context, err error) error {
+// NotifyRestoreSuccess sends a restore success notification to the Discord channel.
+func (d *Discord) NotifyRestoreSuccess(ctx context.Context, evt events.RestoreSuccess) error {
 	message := discord.Message{
 		Embeds: []discord.Embed{
 			{
-				Title:       "Error",
-				Description: err.Error(),
-				Color:       failureColor,
+				Color: restoreColor,
+				Fields: []discord.EmbedField{
+					{
+						Name:   "Backup",
+						Value:  evt.Timestamp,
+						Inline: true,
+					},
+					{
+						Name:   "Duration",
+						Value:  evt.Duration.Round(time.Second).String(),
+						Inline: true,
+					},
+					{
+						Name:   "Key",
+						Value:  evt.Key,
+						Inline: false,
+					},
+					{
+						Name:   "Databases",
+						Value:  strings.Join(evt.Databases, ", "),
+						Inline: false,
+					},
+				},
 			},
 		},
 		Components: []discord.Component{},
 		Username:   constants.ProgramIdentifier,
-		Content:    fmt.Sprintf("**PG-DB Backup Failed** - *%s*", d.Cfg.App.InstanceID),
+		Content:    fmt.Sprintf("**PG-DB Restore Successful** - *%s*", d.Cfg.App.InstanceID),
 	}
 
-	return d.client.Send(ctx, &message)
+	return d.send(ctx, d.client, &message)
 }
 
-// NotifyBackupDeleteFailure sends a deletion failure notification to the Discord channel.
-func (d *Discord) NotifyBackupDeleteFailure(ctx context.Context, err error) error {
+// NotifyRestoreFailure sends a restore failure notification to the Discord channel.
+func (d *Discord) NotifyRestoreFailure(ctx context.Context, err error) error {
+	message := discord.Message{
+		Embeds:     []discord.Embed{errorEmbed(err, failureColor)},
+		Components: []discord.Component{},
+		Username:   constants.ProgramIdentifier,
+		Content:    d.withMentions(fmt.Sprintf("**PG-DB Restore Failed** - *%s*", d.Cfg.App.InstanceID)),
+	}
+
+	return d.send(ctx, d.failureClient, &message)
+}
+
+// NotifyKeyExpiring warns that the GPG key backups are encrypted to expires soon.
+func (d *Discord) NotifyKeyExpiring(ctx context.Context, evt events.KeyExpiring) error {
+	days := int(time.Until(evt.ExpiresAt).Hours() / 24)
+	message := discord.Message{
+		Embeds: []discord.Embed{
+			{
+				Color: warningColor,
+				Fields: []discord.EmbedField{
+					{
+						Name:   "Key ID",
+						Value:  evt.KeyID,
+						Inline: true,
+					},
+					{
+						Name:   "Expires",
+						Value:  events.FormatTime(evt.ExpiresAt, d.Cfg.Notifiers),
+						Inline: true,
+					},
+				},
+			},
+		},
+		Components: []discord.Component{},
+		Username:   constants.ProgramIdentifier,
+		Content: d.withMentions(fmt.Sprintf("**PG-DB Encryption Key Expiring** - *%s* - in %d days; extend or replace it before backups fail",
+			d.Cfg.App.InstanceID, days)),
+	}
+
+	return d.send(ctx, d.client, &message)
+}
+
+// NotifyDrillOverdue warns that no restore drill has passed within the configured window.
+func (d *Discord) NotifyDrillOverdue(ctx context.Context, evt events.DrillOverdue) error {
+	lastPassed := "never"
+	if !evt.LastPassed.IsZero() {
+		lastPassed = events.FormatTime(evt.LastPassed, d.Cfg.Notifiers)
+	}
+	fields := []discord.EmbedField{
+		{
+			Name:   "Last passed",
+			Value:  lastPassed,
+			Inline: true,
+		},
+		{
+			Name:   "Window",
+			Value:  evt.Window.String(),
+			Inline: true,
+		},
+	}
+	if evt.LastError != "" {
+		fields = append(fields, discord.EmbedField{
+			Name:   "Last error",
+			Value:  evt.LastError,
+			Inline: false,
+		})
+	}
+
+	message := discord.Message{
+		Embeds: []discord.Embed{
+			{
+				Color:  warningColor,
+				Fields: fields,
+			},
+		},
+		Components: []discord.Component{},
+		Username:   constants.ProgramIdentifier,
+		Content: d.withMentions(fmt.Sprintf("**PG-DB Restore Drill Overdue** - *%s* - no drill has passed within %s",
+			d.Cfg.App.InstanceID, evt.Window)),
+	}
+
+	return d.send(ctx, d.failureClient, &message)
+}
+
+// NotifyObjectiveViolated warns that the RPO or RTO of an instance exceeds its threshold.
+func (d *Discord) NotifyObjectiveViolated(ctx context.Context, evt events.ObjectiveViolated) error {
+	value := evt.Value.Round(time.Second).String()
+	if evt.Objective == events.ObjectiveRPO && evt.Value == 0 {
+		value = "no backups"
+	}
+
 	message := discord.Message{
 		Embeds: []discord.Embed{
 			{
-				Title:       "Error",
-				Description: err.Error(),
-				Color:       deletionFailureColor,
+				Color: warningColor,
+				Fields: []discord.EmbedField{
+					{
+						Name:   evt.Objective,
+						Value:  value,
+						Inline: true,
+					},
+					{
+						Name:   "Threshold",
+						Value:  evt.Threshold.String(),
+						Inline: true,
+					},
+				},
 			},
 		},
 		Components: []discord.Component{},
 		Username:   constants.ProgramIdentifier,
-		Content:    fmt.Sprintf("**PG-DB Backup Deletion Failed** - *%s*", d.Cfg.App.InstanceID),
+		Content:    d.withMentions(fmt.Sprintf("**PG-DB %s Exceeded** - *%s*", evt.Objective, evt.Instance)),
 	}
 
-	return d.client.Send(ctx, &message)
+	return d.send(ctx, d.failureClient, &message)
+}
+
+// NotifyThrottled summarises the notifications suppressed by the rate limit.
+func (d *Discord) NotifyThrottled(ctx context.Context, evt events.Throttled) error {
+	names := make([]string, 0, len(evt.Suppressed))
+	for name := range evt.Suppressed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := make([]discord.EmbedField, 0, len(names)+1)
+	for _, name := range names {
+		fields = append(fields, discord.EmbedField{
+			Name:   name,
+			Value:  strconv.Itoa(evt.Suppressed[name]),
+			Inline: true,
+		})
+	}
+	fields = append(fields, discord.EmbedField{
+		Name:   "Since",
+		Value:  events.FormatTime(evt.Since, d.Cfg.Notifiers),
+		Inline: false,
+	})
+
+	message := discord.Message{
+		Embeds: []discord.Embed{
+			{
+				Color:  warningColor,
+				Fields: fields,
+			},
+		},
+		Components: []discord.Component{},
+		Username:   constants.ProgramIdentifier,
+		Content: fmt.Sprintf("**PG-DB Notifications Throttled** - *%s* - more than %d messages per hour, suppressed:",
+			d.Cfg.App.InstanceID, evt.Limit),
+	}
+
+	return d.send(ctx, d.client, &message)
+}
+
+// threadWebhookURL returns the webhook URL that posts into the given thread.
+func threadWebhookURL(webhook, threadID string) (string, error) {
+	u, err := url.Parse(webhook)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	q.Set("thread_id", threadID)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
 }
 
 // NewDiscordNotifier creates a new Discord notifier instance.
 func NewDiscordNotifier(cfg *config.Config) (*Discord, error) {
+	httpClient, err := httpclient.New(cfg, commonHTTP.DefaultHTTPClientTimeout)
+	if err != nil {
+		return nil, err
+	}
+
 	client, err := discord.NewClient(discord.Options{
 		WebhookURL: cfg.Notifiers.Discord.Webhook,
+		HTTPClient: httpClient,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	failureClient := client
+	if threadID := cfg.Notifiers.Discord.FailureThreadID; threadID != "" {
+		webhook, uErr := threadWebhookURL(cfg.Notifiers.Discord.Webhook, threadID)
+		if uErr != nil {
+			return nil, uErr
+		}
+
+		failureClient, err = discord.NewClient(discord.Options{
+			WebhookURL: webhook,
+			HTTPClient: httpClient,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &Discord{
-		Cfg:    cfg,
-		client: client,
+		Cfg:           cfg,
+		client:        client,
+		failureClient: failureClient,
 	}, nil
 }
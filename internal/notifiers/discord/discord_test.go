@@ -0,0 +1,304 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hibare/GoCommon/v2/pkg/notifiers/discord"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/notifiers/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThreadWebhookURL(t *testing.T) {
+	u, err := threadWebhookURL("https://discord.com/api/webhooks/1/abc?wait=true", "42")
+	require.NoError(t, err)
+	assert.Equal(t, "https://discord.com/api/webhooks/1/abc?thread_id=42&wait=true", u)
+}
+
+func TestDiscord_withMentions(t *testing.T) {
+	d := &Discord{Cfg: &config.Config{}}
+	assert.Equal(t, "msg", d.withMentions("msg"))
+
+	d.Cfg.Notifiers.Discord.MentionRole = "123"
+	d.Cfg.Notifiers.Discord.MentionUser = "456"
+	assert.Equal(t, "<@&123> <@456> msg", d.withMentions("msg"))
+}
+
+func TestDiscord_NotifyBackupFailure_UsesFailureClient(t *testing.T) {
+	client := &discord.MockClient{}
+	failureClient := &discord.MockClient{}
+	d := &Discord{
+		Cfg: &config.Config{
+			Notifiers: config.NotifiersConfig{
+				Discord: config.DiscordNotifierConfig{MentionRole: "123"},
+			},
+		},
+		client:        client,
+		failureClient: failureClient,
+	}
+
+	failureClient.On("Send", mock.Anything, mock.MatchedBy(func(msg *discord.Message) bool {
+		return msg.Content == "<@&123> **PG-DB Backup Failed** - **"
+	})).Return(nil, nil)
+
+	err := d.NotifyBackupFailure(context.Background(), errors.New("boom"))
+
+	require.NoError(t, err)
+	failureClient.AssertExpectations(t)
+	client.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+}
+
+func TestDiscord_NotifyBackupSuccess_Breakdown(t *testing.T) {
+	client := &discord.MockClient{}
+	d := &Discord{Cfg: &config.Config{}, client: client, failureClient: client}
+
+	want := "```\n" +
+		"app     ok       2.0 KiB\n" +
+		"legacy  skipped  permission denied\n" +
+		"```"
+	client.On("Send", mock.Anything, mock.MatchedBy(func(msg *discord.Message) bool {
+		fields := msg.Embeds[0].Fields
+		return len(fields) == 5 && fields[4].Name == "Breakdown" && fields[4].Value == want
+	})).Return(nil, nil)
+
+	err := d.NotifyBackupSuccess(context.Background(), events.BackupSuccess{
+		Databases: 1,
+		Skipped:   map[string]string{"legacy": "permission denied"},
+		Results: []events.DatabaseResult{
+			{Name: "app", Status: events.DatabaseOK, Size: 2048},
+			{Name: "legacy", Status: events.DatabaseSkipped, Reason: "permission denied"},
+		},
+	})
+	require.NoError(t, err)
+	client.AssertExpectations(t)
+}
+
+func TestDiscord_NotifyBackupSuccess_FailedOver(t *testing.T) {
+	client := &discord.MockClient{}
+	d := &Discord{Cfg: &config.Config{App: config.AppConfig{InstanceID: "db1"}}, client: client, failureClient: client}
+
+	client.On("Send", mock.Anything, mock.MatchedBy(func(msg *discord.Message) bool {
+		fields := msg.Embeds[0].Fields
+		last := fields[len(fields)-1]
+		return msg.Embeds[0].Color == warningColor &&
+			strings.Contains(msg.Content, "Secondary Storage") &&
+			last.Name == "Failed Over" && strings.Contains(last.Value, "gcs (backups)")
+	})).Return(nil, nil)
+
+	err := d.NotifyBackupSuccess(context.Background(), events.BackupSuccess{Databases: 1, Key: "key", FailedOver: "gcs (backups)"})
+	require.NoError(t, err)
+	client.AssertExpectations(t)
+}
+
+func TestFormatResults_Truncates(t *testing.T) {
+	results := make([]events.DatabaseResult, 100)
+	for i := range results {
+		results[i] = events.DatabaseResult{Name: fmt.Sprintf("database_%03d", i), Status: events.DatabaseOK, Size: 1 << 20}
+	}
+
+	value := formatResults(results)
+	assert.LessOrEqual(t, len(value), maxFieldLength)
+	assert.Contains(t, value, "more\n```")
+}
+
+func TestDiscord_NotifyBackupFailure_Hint(t *testing.T) {
+	client := &discord.MockClient{}
+	d := &Discord{Cfg: &config.Config{}, client: client, failureClient: client}
+
+	client.On("Send", mock.Anything, mock.MatchedBy(func(msg *discord.Message) bool {
+		fields := msg.Embeds[0].Fields
+		return len(fields) == 1 && fields[0].Name == "Hint" && strings.Contains(fields[0].Value, "postgresql-client")
+	})).Return(nil, nil).Once()
+	client.On("Send", mock.Anything, mock.MatchedBy(func(msg *discord.Message) bool {
+		return len(msg.Embeds[0].Fields) == 0
+	})).Return(nil, nil).Once()
+
+	require.NoError(t, d.NotifyBackupFailure(context.Background(), errors.New("pg_dump: error: aborting because of server version mismatch")))
+	require.NoError(t, d.NotifyBackupFailure(context.Background(), errors.New("boom")))
+	client.AssertExpectations(t)
+}
+
+func TestDiscord_NotifyBackupSuccess_Fields(t *testing.T) {
+	client := &discord.MockClient{}
+	d := &Discord{Cfg: &config.Config{}, client: client, failureClient: client}
+
+	client.On("Send", mock.Anything, mock.MatchedBy(func(msg *discord.Message) bool {
+		fields := msg.Embeds[0].Fields
+		return len(fields) == 5 &&
+			fields[2].Value == "2.0 KiB" &&
+			fields[3].Value == "1m30s" &&
+			fields[4].Value == "db2: permission denied"
+	})).Return(nil, nil)
+
+	err := d.NotifyBackupSuccess(context.Background(), events.BackupSuccess{
+		Databases: 1,
+		Key:       "key",
+		Skipped:   map[string]string{"db2": "permission denied"},
+		Size:      2048,
+		Duration:  90 * time.Second,
+	})
+
+	require.NoError(t, err)
+	client.AssertExpectations(t)
+}
+
+func TestDiscord_NotifyBackupSlow(t *testing.T) {
+	client := &discord.MockClient{}
+	d := &Discord{Cfg: &config.Config{}, client: client, failureClient: client}
+
+	client.On("Send", mock.Anything, mock.MatchedBy(func(msg *discord.Message) bool {
+		fields := msg.Embeds[0].Fields
+		return msg.Content == "**PG-DB Backup Slow** - **" &&
+			fields[1].Value == "2h5m0s" &&
+			fields[2].Value == "2h0m0s"
+	})).Return(nil, nil)
+
+	err := d.NotifyBackupSlow(context.Background(), events.BackupSlow{
+		Key:       "key",
+		Duration:  2*time.Hour + 5*time.Minute,
+		Threshold: 2 * time.Hour,
+	})
+
+	require.NoError(t, err)
+	client.AssertExpectations(t)
+}
+
+func TestDiscord_NotifyCoverageStarted(t *testing.T) {
+	client := &discord.MockClient{}
+	d := &Discord{Cfg: &config.Config{}, client: client, failureClient: client}
+
+	client.On("Send", mock.Anything, mock.MatchedBy(func(msg *discord.Message) bool {
+		return msg.Content == "**PG-DB Backup Coverage Started** - *orders-db*" &&
+			msg.Embeds[0].Fields[1].Value == "orders, audit"
+	})).Return(nil, nil)
+
+	err := d.NotifyCoverageStarted(context.Background(), events.CoverageStarted{
+		InstanceID: "orders-db",
+		Key:        "key",
+		Databases:  []string{"orders", "audit"},
+	})
+
+	require.NoError(t, err)
+	client.AssertExpectations(t)
+}
+
+func TestDiscord_NotifyRestoreSuccess(t *testing.T) {
+	client := &discord.MockClient{}
+	d := &Discord{Cfg: &config.Config{}, client: client, failureClient: client}
+
+	client.On("Send", mock.Anything, mock.MatchedBy(func(msg *discord.Message) bool {
+		fields := msg.Embeds[0].Fields
+		return msg.Content == "**PG-DB Restore Successful** - **" &&
+			fields[0].Value == "20240101000000" &&
+			fields[1].Value == "1m30s" &&
+			fields[3].Value == "orders, audit"
+	})).Return(nil, nil)
+
+	err := d.NotifyRestoreSuccess(context.Background(), events.RestoreSuccess{
+		Timestamp: "20240101000000",
+		Key:       "key",
+		Databases: []string{"orders", "audit"},
+		Duration:  90 * time.Second,
+	})
+
+	require.NoError(t, err)
+	client.AssertExpectations(t)
+}
+
+func TestDiscord_NotifyRestoreFailure_UsesFailureClient(t *testing.T) {
+	client := &discord.MockClient{}
+	failureClient := &discord.MockClient{}
+	d := &Discord{Cfg: &config.Config{}, client: client, failureClient: failureClient}
+
+	failureClient.On("Send", mock.Anything, mock.MatchedBy(func(msg *discord.Message) bool {
+		return msg.Content == "**PG-DB Restore Failed** - **" && msg.Embeds[0].Description == "boom"
+	})).Return(nil, nil)
+
+	err := d.NotifyRestoreFailure(context.Background(), errors.New("boom"))
+
+	require.NoError(t, err)
+	failureClient.AssertExpectations(t)
+	client.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+}
+
+func TestDiscord_NotifyKeyExpiring(t *testing.T) {
+	client := &discord.MockClient{}
+	d := &Discord{Cfg: &config.Config{Notifiers: config.NotifiersConfig{Timezone: "UTC"}}, client: client, failureClient: client}
+
+	expires := time.Now().Add(10*24*time.Hour + time.Hour)
+	client.On("Send", mock.Anything, mock.MatchedBy(func(msg *discord.Message) bool {
+		return strings.HasPrefix(msg.Content, "**PG-DB Encryption Key Expiring** - ** - in 10 days") &&
+			msg.Embeds[0].Fields[0].Value == "ABCD"
+	})).Return(nil, nil)
+
+	err := d.NotifyKeyExpiring(context.Background(), events.KeyExpiring{KeyID: "ABCD", ExpiresAt: expires})
+
+	require.NoError(t, err)
+	client.AssertExpectations(t)
+}
+
+func TestDiscord_NotifyDrillOverdue(t *testing.T) {
+	client := &discord.MockClient{}
+	d := &Discord{Cfg: &config.Config{App: config.AppConfig{InstanceID: "orders-db"}}, client: client, failureClient: client}
+
+	client.On("Send", mock.Anything, mock.MatchedBy(func(msg *discord.Message) bool {
+		fields := msg.Embeds[0].Fields
+		return strings.HasPrefix(msg.Content, "**PG-DB Restore Drill Overdue** - *orders-db* - no drill has passed within 192h0m0s") &&
+			fields[0].Value == "never" && fields[2].Value == "no backups found"
+	})).Return(nil, nil)
+
+	err := d.NotifyDrillOverdue(context.Background(), events.DrillOverdue{Window: 192 * time.Hour, LastError: "no backups found"})
+
+	require.NoError(t, err)
+	client.AssertExpectations(t)
+}
+
+func TestDiscord_NotifyObjectiveViolated(t *testing.T) {
+	client := &discord.MockClient{}
+	d := &Discord{Cfg: &config.Config{}, client: client, failureClient: client}
+
+	client.On("Send", mock.Anything, mock.MatchedBy(func(msg *discord.Message) bool {
+		fields := msg.Embeds[0].Fields
+		return strings.HasPrefix(msg.Content, "**PG-DB RPO Exceeded** - *prod/eu*") &&
+			fields[0].Name == "RPO" && fields[0].Value == "no backups" && fields[1].Value == "26h0m0s"
+	})).Return(nil, nil)
+
+	err := d.NotifyObjectiveViolated(context.Background(), events.ObjectiveViolated{
+		Objective: events.ObjectiveRPO,
+		Instance:  "prod/eu",
+		Threshold: 26 * time.Hour,
+	})
+
+	require.NoError(t, err)
+	client.AssertExpectations(t)
+}
+
+func TestDiscord_NotifyThrottled(t *testing.T) {
+	client := &discord.MockClient{}
+	cfg := &config.Config{App: config.AppConfig{InstanceID: "orders-db"}}
+	d := &Discord{Cfg: cfg, client: client, failureClient: client}
+
+	client.On("Send", mock.Anything, mock.MatchedBy(func(msg *discord.Message) bool {
+		fields := msg.Embeds[0].Fields
+		return strings.Contains(msg.Content, "more than 5 messages per hour") &&
+			fields[0].Name == "backup_failure" && fields[0].Value == "12" &&
+			fields[1].Name == "backup_success" && fields[1].Value == "1" &&
+			fields[2].Name == "Since"
+	})).Return(nil, nil)
+
+	err := d.NotifyThrottled(context.Background(), events.Throttled{
+		Suppressed: map[string]int{"backup_success": 1, "backup_failure": 12},
+		Since:      time.Now(),
+		Limit:      5,
+	})
+
+	require.NoError(t, err)
+	client.AssertExpectations(t)
+}
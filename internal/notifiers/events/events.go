@@ -0,0 +1,144 @@
+// Package events defines the payloads passed from backup runs to notifiers.
+package events
+
+import "time"
+
+// BackupSuccess describes a completed backup run.
+type BackupSuccess struct {
+	// Databases is the number of databases exported.
+	Databases int
+
+	// Key is the storage key of the uploaded backup.
+	Key string
+
+	// Skipped maps databases that were skipped to the reason they were skipped.
+	Skipped map[string]string
+
+	// Size is the size in bytes of the uploaded artifact.
+	Size int64
+
+	// Duration is how long the backup took.
+	Duration time.Duration
+
+	// Results lists each database found on the server with the outcome of backing it up.
+	Results []DatabaseResult
+
+	// FailedOver names the secondary storage the backup was uploaded to because the primary failed;
+	// empty when it went to the primary.
+	FailedOver string
+}
+
+// Database outcomes in a DatabaseResult.
+const (
+	DatabaseOK      = "ok"
+	DatabaseSkipped = "skipped"
+	DatabaseFailed  = "failed"
+)
+
+// DatabaseResult is the outcome of backing up one database.
+type DatabaseResult struct {
+	Name string
+
+	// Status is DatabaseOK, DatabaseSkipped or DatabaseFailed.
+	Status string
+
+	// Size is the size in bytes of the database's uncompressed dump; 0 unless Status is DatabaseOK.
+	Size int64
+
+	// Reason explains why a database was skipped or failed.
+	Reason string
+}
+
+// RestoreSuccess describes a completed restore.
+type RestoreSuccess struct {
+	// Timestamp is the timestamp of the restored backup.
+	Timestamp string
+
+	// Key is the storage key of the restored backup.
+	Key string
+
+	// Databases lists the databases that were restored.
+	Databases []string
+
+	// Duration is how long the restore took.
+	Duration time.Duration
+}
+
+// CoverageStarted describes the first successful backup of an instance.
+type CoverageStarted struct {
+	// InstanceID is the instance that is now being backed up.
+	InstanceID string
+
+	// Key is the storage key of the uploaded backup.
+	Key string
+
+	// Databases lists the databases the backup covers.
+	Databases []string
+}
+
+// BackupSlow describes a backup run that succeeded but exceeded the configured duration threshold.
+type BackupSlow struct {
+	// Key is the storage key of the uploaded backup.
+	Key string
+
+	// Duration is how long the backup took.
+	Duration time.Duration
+
+	// Threshold is the configured soft duration limit.
+	Threshold time.Duration
+}
+
+// KeyExpiring describes a GPG encryption key that expires soon.
+type KeyExpiring struct {
+	// KeyID is the configured key ID.
+	KeyID string
+
+	// ExpiresAt is when the key expires.
+	ExpiresAt time.Time
+}
+
+// DrillOverdue describes restore drills that haven't passed within the configured window.
+type DrillOverdue struct {
+	// LastPassed is when the last drill that passed started; zero if none has.
+	LastPassed time.Time
+
+	// Window is the configured restore.drill.window.
+	Window time.Duration
+
+	// LastError is why the latest drill failed; empty if it didn't run.
+	LastError string
+}
+
+// Recovery objectives in an ObjectiveViolated.
+const (
+	ObjectiveRPO = "RPO"
+	ObjectiveRTO = "RTO"
+)
+
+// ObjectiveViolated describes a recovery objective that exceeds its configured threshold.
+type ObjectiveViolated struct {
+	// Objective is ObjectiveRPO or ObjectiveRTO.
+	Objective string
+
+	// Instance is the instance whose backups miss the objective.
+	Instance string
+
+	// Value is the measured objective: the newest backup's age, or the last passed drill's duration.
+	// It is 0 for an RPO when there is no backup at all.
+	Value time.Duration
+
+	// Threshold is the configured backup.rpo-threshold or restore.rto-threshold.
+	Threshold time.Duration
+}
+
+// Throttled summarises the events a notifier's rate limit suppressed.
+type Throttled struct {
+	// Suppressed maps event names to how many of them were not sent.
+	Suppressed map[string]int
+
+	// Since is when the first event was suppressed.
+	Since time.Time
+
+	// Limit is the notifier's maximum number of messages per hour.
+	Limit int
+}
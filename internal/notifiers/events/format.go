@@ -0,0 +1,101 @@
+package events
+
+import (
+	"strings"
+	"time"
+
+	"github.com/hibare/stashly/internal/config"
+)
+
+// Named time formats accepted by notifiers.time-format in addition to Go layouts.
+var timeFormatPresets = map[string]string{
+	"24h":     "2006-01-02 15:04:05 MST",
+	"12h":     "2006-01-02 03:04:05 PM MST",
+	"rfc3339": time.RFC3339,
+}
+
+// localeNames holds the month and weekday names of a language, full and abbreviated, January and
+// Sunday first.
+type localeNames struct {
+	months, shortMonths [12]string
+	days, shortDays     [7]string
+}
+
+// locales maps config.NotifierLocales other than English to their names.
+var locales = map[string]localeNames{
+	"de": {
+		months:      [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		shortMonths: [12]string{"Jan", "Feb", "Mär", "Apr", "Mai", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dez"},
+		days:        [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		shortDays:   [7]string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+	},
+	"es": {
+		months:      [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		shortMonths: [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		days:        [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		shortDays:   [7]string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+	},
+	"fr": {
+		months:      [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		shortMonths: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		days:        [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		shortDays:   [7]string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+	},
+	"it": {
+		months:      [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		shortMonths: [12]string{"gen", "feb", "mar", "apr", "mag", "giu", "lug", "ago", "set", "ott", "nov", "dic"},
+		days:        [7]string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+		shortDays:   [7]string{"dom", "lun", "mar", "mer", "gio", "ven", "sab"},
+	},
+	"nl": {
+		months:      [12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		shortMonths: [12]string{"jan", "feb", "mrt", "apr", "mei", "jun", "jul", "aug", "sep", "okt", "nov", "dec"},
+		days:        [7]string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+		shortDays:   [7]string{"zo", "ma", "di", "wo", "do", "vr", "za"},
+	},
+	"pt": {
+		months:      [12]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+		shortMonths: [12]string{"jan", "fev", "mar", "abr", "mai", "jun", "jul", "ago", "set", "out", "nov", "dez"},
+		days:        [7]string{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira", "sexta-feira", "sábado"},
+		shortDays:   [7]string{"dom", "seg", "ter", "qua", "qui", "sex", "sáb"},
+	},
+}
+
+// localize replaces the English month and weekday names of t in s, as written by Format, with those
+// of locale. Full names come first so an abbreviation never matches inside one.
+func localize(s string, t time.Time, locale string) string {
+	names, ok := locales[locale]
+	if !ok {
+		return s
+	}
+	month, day := t.Month().String(), t.Weekday().String()
+	return strings.NewReplacer(
+		month, names.months[t.Month()-1],
+		day, names.days[t.Weekday()],
+		month[:3], names.shortMonths[t.Month()-1],
+		day[:3], names.shortDays[t.Weekday()],
+	).Replace(s)
+}
+
+// FormatTime formats t for display in notifications using the configured format, timezone and
+// locale. An empty timezone means local time.
+func FormatTime(t time.Time, cfg config.NotifiersConfig) string {
+	layout := cfg.TimeFormat
+	if preset, ok := timeFormatPresets[layout]; ok {
+		layout = preset
+	}
+	if layout == "" {
+		layout = timeFormatPresets["24h"]
+	}
+
+	// LoadLocation("") is UTC, not local time.
+	loc := time.Local
+	if cfg.Timezone != "" {
+		if l, err := time.LoadLocation(cfg.Timezone); err == nil {
+			loc = l
+		}
+	}
+	t = t.In(loc)
+
+	return localize(t.Format(layout), t, cfg.Locale)
+}
@@ -0,0 +1,51 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatTime(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 13, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		cfg  config.NotifiersConfig
+		want string
+	}{
+		{"default", config.NotifiersConfig{Timezone: "UTC"}, "2024-03-01 13:04:05 UTC"},
+		{"12h", config.NotifiersConfig{TimeFormat: "12h", Timezone: "UTC"}, "2024-03-01 01:04:05 PM UTC"},
+		{"rfc3339", config.NotifiersConfig{TimeFormat: "rfc3339", Timezone: "UTC"}, "2024-03-01T13:04:05Z"},
+		{"custom layout", config.NotifiersConfig{TimeFormat: "02.01.2006 15:04", Timezone: "UTC"}, "01.03.2024 13:04"},
+		{"timezone", config.NotifiersConfig{TimeFormat: "24h", Timezone: "Asia/Kolkata"}, "2024-03-01 18:34:05 IST"},
+		{"local time", config.NotifiersConfig{}, "2024-03-01 15:04:05 TEST"},
+		{"locale", config.NotifiersConfig{TimeFormat: "Monday, 2 January 2006", Timezone: "UTC", Locale: "de"}, "Freitag, 1 März 2024"},
+		{"short names", config.NotifiersConfig{TimeFormat: "Mon 2 Jan", Timezone: "UTC", Locale: "fr"}, "ven. 1 mars"},
+		{"english", config.NotifiersConfig{TimeFormat: "Mon 2 Jan", Timezone: "UTC", Locale: "en"}, "Fri 1 Mar"},
+	}
+
+	// An empty timezone formats in local time.
+	local := time.Local
+	time.Local = time.FixedZone("TEST", 2*60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, FormatTime(ts, tt.cfg))
+		})
+	}
+}
+
+func TestLocales(t *testing.T) {
+	// Every locale the config accepts has names, English being the layout's own.
+	for _, l := range config.NotifierLocales {
+		if l == "en" {
+			continue
+		}
+		assert.Contains(t, locales, l)
+	}
+	assert.Len(t, locales, len(config.NotifierLocales)-1)
+}
@@ -4,11 +4,15 @@ package notifiers
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/hibare/stashly/internal/config"
 	"github.com/hibare/stashly/internal/notifiers/discord"
+	"github.com/hibare/stashly/internal/notifiers/events"
 )
 
 var (
@@ -17,23 +21,58 @@ var (
 
 	// ErrNotifierDisabled is returned when a specific notifier is disabled.
 	ErrNotifierDisabled = errors.New("notifier is disabled")
+
+	// ErrUnknownTestEvent is returned when a test notification is requested for an unknown event.
+	ErrUnknownTestEvent = errors.New("unknown test event")
 )
 
+// TestEvents lists the events a test notification can be sent for.
+var TestEvents = []string{"success", "failure", "delete-failure", "slow", "coverage-started", "restore-success", "restore-failure", "key-expiring", "drill-overdue", "objective-violated"}
+
+// TestResult is the outcome of sending a test notification through one notifier.
+type TestResult struct {
+	Notifier string
+	Enabled  bool
+
+	// Err is the delivery error; nil if the notifier is disabled or delivery succeeded.
+	Err error
+}
+
 // NotifiersIface defines the interface that all notifier implementations must satisfy.
 // revive:disable-next-line exported
 type NotifiersIface interface {
+	Name() string
 	Enabled() bool
-	NotifyBackupSuccess(ctx context.Context, databases int, key string) error
+	NotifyBackupSuccess(ctx context.Context, evt events.BackupSuccess) error
 	NotifyBackupFailure(ctx context.Context, err error) error
 	NotifyBackupDeleteFailure(ctx context.Context, err error) error
+	NotifyBackupSlow(ctx context.Context, evt events.BackupSlow) error
+	NotifyCoverageStarted(ctx context.Context, evt events.CoverageStarted) error
+	NotifyRestoreSuccess(ctx context.Context, evt events.RestoreSuccess) error
+	NotifyRestoreFailure(ctx context.Context, err error) error
+	NotifyKeyExpiring(ctx context.Context, evt events.KeyExpiring) error
+	NotifyDrillOverdue(ctx context.Context, evt events.DrillOverdue) error
+	NotifyObjectiveViolated(ctx context.Context, evt events.ObjectiveViolated) error
+	NotifyThrottled(ctx context.Context, evt events.Throttled) error
+
+	// RateLimit is the maximum number of messages sent per hour (0 is unlimited).
+	RateLimit() int
 }
 
 // NotifierStoreIface defines the interface for managing multiple notifiers.
 type NotifierStoreIface interface {
 	Enabled() bool
-	NotifyBackupSuccess(ctx context.Context, databases int, key string) error
+	NotifyBackupSuccess(ctx context.Context, evt events.BackupSuccess) error
 	NotifyBackupFailure(ctx context.Context, err error) error
 	NotifyBackupDeleteFailure(ctx context.Context, err error) error
+	NotifyBackupSlow(ctx context.Context, evt events.BackupSlow) error
+	NotifyCoverageStarted(ctx context.Context, evt events.CoverageStarted) error
+	NotifyRestoreSuccess(ctx context.Context, evt events.RestoreSuccess) error
+	NotifyRestoreFailure(ctx context.Context, err error) error
+	NotifyKeyExpiring(ctx context.Context, evt events.KeyExpiring) error
+	NotifyDrillOverdue(ctx context.Context, evt events.DrillOverdue) error
+	NotifyObjectiveViolated(ctx context.Context, evt events.ObjectiveViolated) error
+	Test(ctx context.Context, event string) ([]TestResult, error)
 	InitStore() error
 }
 
@@ -42,6 +81,13 @@ type Notifier struct {
 	cfg   *config.Config
 	mu    sync.RWMutex
 	store []NotifiersIface
+	dedup *dedup
+
+	// redact hides database names and storage keys; nil unless notifiers.redact is set.
+	redact *redactor
+
+	throttlesMu sync.Mutex
+	throttles   map[string]*throttle
 }
 
 func (n *Notifier) register(nf NotifiersIface) {
@@ -50,23 +96,53 @@ func (n *Notifier) register(nf NotifiersIface) {
 	n.store = append(n.store, nf)
 }
 
+// throttle returns the rate limiter of notifier.
+func (n *Notifier) throttle(notifier NotifiersIface) *throttle {
+	n.throttlesMu.Lock()
+	defer n.throttlesMu.Unlock()
+
+	t, ok := n.throttles[notifier.Name()]
+	if !ok {
+		t = newThrottle(notifier.RateLimit())
+		n.throttles[notifier.Name()] = t
+	}
+	return t
+}
+
+// send delivers event through notifier with fn, unless the notifier's rate limit is exhausted. A summary of
+// events suppressed earlier goes out first once the notifier has room again.
+func (n *Notifier) send(ctx context.Context, notifier NotifiersIface, event string, fn func() error) error {
+	allowed, summary := n.throttle(notifier).allow(event)
+	if summary != nil {
+		if err := notifier.NotifyThrottled(ctx, *summary); err != nil {
+			slog.ErrorContext(ctx, "Failed to send NotifyThrottled", "error", err)
+		}
+	}
+	if !allowed {
+		slog.InfoContext(ctx, "Notifier rate limit reached; suppressing notification", "notifier", notifier.Name(), "event", event)
+		return nil
+	}
+	return fn()
+}
+
 // Enabled checks if notifiers are globally enabled in the configuration.
 func (n *Notifier) Enabled() bool {
 	return n.cfg.Notifiers.Enabled
 }
 
 // NotifyBackupSuccess sends a backup success notification using all enabled notifiers.
-func (n *Notifier) NotifyBackupSuccess(ctx context.Context, databases int, key string) error {
+func (n *Notifier) NotifyBackupSuccess(ctx context.Context, evt events.BackupSuccess) error {
 	if !n.Enabled() {
 		return ErrNotifierDisabled
 	}
+	evt = n.redact.backupSuccess(evt)
 
 	for _, notifier := range n.store {
 		if !notifier.Enabled() {
 			slog.DebugContext(ctx, "Notifier disabled; skipping NotifyBackupSuccess")
 			continue
 		}
-		if err := notifier.NotifyBackupSuccess(ctx, databases, key); err != nil {
+		if err := n.send(ctx, notifier, "backup_success", func() error { return notifier.NotifyBackupSuccess(ctx, evt) }); err != nil {
 			slog.ErrorContext(ctx, "Failed to send NotifyBackupSuccess", "error", err)
 		}
 	}
@@ -80,12 +156,18 @@ func (n *Notifier) NotifyBackupFailure(ctx context.Context, nErr error) error {
 		return ErrNotifierDisabled
 	}
 
+	if !n.dedup.allow("backup_failure", nErr) {
+		slog.InfoContext(ctx, "Suppressing duplicate NotifyBackupFailure", "error", nErr)
+		return nil
+	}
+	nErr = n.redact.err(nErr)
+
 	for _, notifier := range n.store {
 		if !notifier.Enabled() {
 			slog.DebugContext(ctx, "Notifier disabled; skipping NotifyBackupFailure")
 			continue
 		}
-		if err := notifier.NotifyBackupFailure(ctx, nErr); err != nil {
+		if err := n.send(ctx, notifier, "backup_failure", func() error { return notifier.NotifyBackupFailure(ctx, nErr) }); err != nil {
 			slog.ErrorContext(ctx, "Failed to send NotifyBackupFailure", "error", err)
 		}
 	}
@@ -99,12 +181,18 @@ func (n *Notifier) NotifyBackupDeleteFailure(ctx context.Context, nErr error) er
 		return ErrNotifierDisabled
 	}
 
+	if !n.dedup.allow("backup_delete_failure", nErr) {
+		slog.InfoContext(ctx, "Suppressing duplicate NotifyBackupDeleteFailure", "error", nErr)
+		return nil
+	}
+	nErr = n.redact.err(nErr)
+
 	for _, notifier := range n.store {
 		if !notifier.Enabled() {
 			slog.DebugContext(ctx, "Notifier disabled; skipping NotifyBackupDeleteFailure")
 			continue
 		}
-		if err := notifier.NotifyBackupDeleteFailure(ctx, nErr); err != nil {
+		if err := n.send(ctx, notifier, "backup_delete_failure", func() error { return notifier.NotifyBackupDeleteFailure(ctx, nErr) }); err != nil {
 			slog.ErrorContext(ctx, "Failed to send NotifyBackupDeleteFailure", "error", err)
 		}
 	}
@@ -112,6 +200,235 @@ func (n *Notifier) NotifyBackupDeleteFailure(ctx context.Context, nErr error) er
 	return nil
 }
 
+// NotifyBackupSlow sends a slow backup warning using all enabled notifiers.
+func (n *Notifier) NotifyBackupSlow(ctx context.Context, evt events.BackupSlow) error {
+	if !n.Enabled() {
+		return ErrNotifierDisabled
+	}
+	evt = n.redact.backupSlow(evt)
+
+	for _, notifier := range n.store {
+		if !notifier.Enabled() {
+			slog.DebugContext(ctx, "Notifier disabled; skipping NotifyBackupSlow")
+			continue
+		}
+		if err := n.send(ctx, notifier, "backup_slow", func() error { return notifier.NotifyBackupSlow(ctx, evt) }); err != nil {
+			slog.ErrorContext(ctx, "Failed to send NotifyBackupSlow", "error", err)
+		}
+	}
+
+	return nil
+}
+
+// NotifyCoverageStarted announces an instance's first successful backup using all enabled notifiers.
+func (n *Notifier) NotifyCoverageStarted(ctx context.Context, evt events.CoverageStarted) error {
+	if !n.Enabled() {
+		return ErrNotifierDisabled
+	}
+	evt = n.redact.coverageStarted(evt)
+
+	for _, notifier := range n.store {
+		if !notifier.Enabled() {
+			slog.DebugContext(ctx, "Notifier disabled; skipping NotifyCoverageStarted")
+			continue
+		}
+		if err := n.send(ctx, notifier, "coverage_started", func() error { return notifier.NotifyCoverageStarted(ctx, evt) }); err != nil {
+			slog.ErrorContext(ctx, "Failed to send NotifyCoverageStarted", "error", err)
+		}
+	}
+
+	return nil
+}
+
+// NotifyRestoreSuccess sends a restore success notification using all enabled notifiers.
+func (n *Notifier) NotifyRestoreSuccess(ctx context.Context, evt events.RestoreSuccess) error {
+	if !n.Enabled() {
+		return ErrNotifierDisabled
+	}
+	evt = n.redact.restoreSuccess(evt)
+
+	for _, notifier := range n.store {
+		if !notifier.Enabled() {
+			slog.DebugContext(ctx, "Notifier disabled; skipping NotifyRestoreSuccess")
+			continue
+		}
+		if err := n.send(ctx, notifier, "restore_success", func() error { return notifier.NotifyRestoreSuccess(ctx, evt) }); err != nil {
+			slog.ErrorContext(ctx, "Failed to send NotifyRestoreSuccess", "error", err)
+		}
+	}
+
+	return nil
+}
+
+// NotifyRestoreFailure sends a restore failure notification using all enabled notifiers.
+func (n *Notifier) NotifyRestoreFailure(ctx context.Context, nErr error) error {
+	if !n.Enabled() {
+		return ErrNotifierDisabled
+	}
+
+	if !n.dedup.allow("restore_failure", nErr) {
+		slog.InfoContext(ctx, "Suppressing duplicate NotifyRestoreFailure", "error", nErr)
+		return nil
+	}
+	nErr = n.redact.err(nErr)
+
+	for _, notifier := range n.store {
+		if !notifier.Enabled() {
+			slog.DebugContext(ctx, "Notifier disabled; skipping NotifyRestoreFailure")
+			continue
+		}
+		if err := n.send(ctx, notifier, "restore_failure", func() error { return notifier.NotifyRestoreFailure(ctx, nErr) }); err != nil {
+			slog.ErrorContext(ctx, "Failed to send NotifyRestoreFailure", "error", err)
+		}
+	}
+
+	return nil
+}
+
+// NotifyKeyExpiring warns that the GPG encryption key expires soon using all enabled notifiers.
+func (n *Notifier) NotifyKeyExpiring(ctx context.Context, evt events.KeyExpiring) error {
+	if !n.Enabled() {
+		return ErrNotifierDisabled
+	}
+
+	for _, notifier := range n.store {
+		if !notifier.Enabled() {
+			slog.DebugContext(ctx, "Notifier disabled; skipping NotifyKeyExpiring")
+			continue
+		}
+		if err := n.send(ctx, notifier, "key_expiring", func() error { return notifier.NotifyKeyExpiring(ctx, evt) }); err != nil {
+			slog.ErrorContext(ctx, "Failed to send NotifyKeyExpiring", "error", err)
+		}
+	}
+
+	return nil
+}
+
+// NotifyDrillOverdue warns that restore drills haven't passed within the configured window using all
+// enabled notifiers.
+func (n *Notifier) NotifyDrillOverdue(ctx context.Context, evt events.DrillOverdue) error {
+	if !n.Enabled() {
+		return ErrNotifierDisabled
+	}
+	evt = n.redact.drillOverdue(evt)
+
+	for _, notifier := range n.store {
+		if !notifier.Enabled() {
+			slog.DebugContext(ctx, "Notifier disabled; skipping NotifyDrillOverdue")
+			continue
+		}
+		if err := n.send(ctx, notifier, "drill_overdue", func() error { return notifier.NotifyDrillOverdue(ctx, evt) }); err != nil {
+			slog.ErrorContext(ctx, "Failed to send NotifyDrillOverdue", "error", err)
+		}
+	}
+
+	return nil
+}
+
+// NotifyObjectiveViolated warns that a recovery objective exceeds its threshold using all enabled notifiers.
+func (n *Notifier) NotifyObjectiveViolated(ctx context.Context, evt events.ObjectiveViolated) error {
+	if !n.Enabled() {
+		return ErrNotifierDisabled
+	}
+
+	for _, notifier := range n.store {
+		if !notifier.Enabled() {
+			slog.DebugContext(ctx, "Notifier disabled; skipping NotifyObjectiveViolated")
+			continue
+		}
+		if err := n.send(ctx, notifier, "objective_violated", func() error { return notifier.NotifyObjectiveViolated(ctx, evt) }); err != nil {
+			slog.ErrorContext(ctx, "Failed to send NotifyObjectiveViolated", "error", err)
+		}
+	}
+
+	return nil
+}
+
+// sendTest sends a sample notification for event through notifier, redacted like real events.
+func sendTest(ctx context.Context, notifier NotifiersIface, r *redactor, event string) error {
+	testErr := errors.New("this is a test notification from stashly")
+	key := "stashly-test/notification"
+
+	switch event {
+	case "success":
+		return notifier.NotifyBackupSuccess(ctx, r.backupSuccess(events.BackupSuccess{
+			Databases: 1,
+			Key:       key,
+			Size:      1024,
+			Duration:  time.Second,
+			Results:   []events.DatabaseResult{{Name: "app", Status: events.DatabaseOK, Size: 4096}},
+		}))
+	case "failure":
+		return notifier.NotifyBackupFailure(ctx, r.err(testErr))
+	case "delete-failure":
+		return notifier.NotifyBackupDeleteFailure(ctx, r.err(testErr))
+	case "slow":
+		return notifier.NotifyBackupSlow(ctx, r.backupSlow(events.BackupSlow{
+			Key:       key,
+			Duration:  2 * time.Hour,
+			Threshold: time.Hour,
+		}))
+	case "coverage-started":
+		return notifier.NotifyCoverageStarted(ctx, r.coverageStarted(events.CoverageStarted{
+			InstanceID: "stashly-test",
+			Key:        key,
+			Databases:  []string{"app"},
+		}))
+	case "restore-success":
+		return notifier.NotifyRestoreSuccess(ctx, r.restoreSuccess(events.RestoreSuccess{
+			Timestamp: "20060102150405",
+			Key:       key,
+			Databases: []string{"app"},
+			Duration:  time.Second,
+		}))
+	case "restore-failure":
+		return notifier.NotifyRestoreFailure(ctx, r.err(testErr))
+	case "key-expiring":
+		return notifier.NotifyKeyExpiring(ctx, events.KeyExpiring{
+			KeyID:     "0123456789ABCDEF",
+			ExpiresAt: time.Now().Add(14 * 24 * time.Hour),
+		})
+	case "drill-overdue":
+		return notifier.NotifyDrillOverdue(ctx, r.drillOverdue(events.DrillOverdue{
+			LastPassed: time.Now().Add(-10 * 24 * time.Hour),
+			Window:     8 * 24 * time.Hour,
+			LastError:  testErr.Error(),
+		}))
+	case "objective-violated":
+		return notifier.NotifyObjectiveViolated(ctx, events.ObjectiveViolated{
+			Objective: events.ObjectiveRPO,
+			Instance:  "stashly-test",
+			Value:     30 * time.Hour,
+			Threshold: 26 * time.Hour,
+		})
+	}
+	return fmt.Errorf("%w %q (available: %v)", ErrUnknownTestEvent, event, TestEvents)
+}
+
+// Test sends a sample notification for event through every enabled notifier and reports each delivery.
+// Unlike real events it ignores deduplication and reports delivery errors instead of logging them.
+func (n *Notifier) Test(ctx context.Context, event string) ([]TestResult, error) {
+	if !slices.Contains(TestEvents, event) {
+		return nil, fmt.Errorf("%w %q (available: %v)", ErrUnknownTestEvent, event, TestEvents)
+	}
+	if !n.Enabled() {
+		return nil, ErrNotifiersDisabled
+	}
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	results := make([]TestResult, 0, len(n.store))
+	for _, notifier := range n.store {
+		result := TestResult{Notifier: notifier.Name(), Enabled: notifier.Enabled()}
+		if result.Enabled {
+			result.Err = sendTest(ctx, notifier, n.redact, event)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
 // InitStore initializes and registers all available notifiers.
 func (n *Notifier) InitStore() error {
 	d, err := discord.NewDiscordNotifier(n.cfg)
@@ -126,5 +443,10 @@ func (n *Notifier) InitStore() error {
 
 // NewNotifier creates a new Notifier instance with the provided configuration.
 func NewNotifier(cfg *config.Config) NotifierStoreIface {
-	return &Notifier{cfg: cfg}
+	return &Notifier{
+		cfg:       cfg,
+		dedup:     newDedup(cfg.Notifiers.DedupWindow),
+		redact:    newRedactor(cfg.Notifiers.Redact),
+		throttles: map[string]*throttle{},
+	}
 }
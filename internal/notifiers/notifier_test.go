@@ -0,0 +1,110 @@
+package notifiers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/notifiers/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNotifier records the events it is asked to send.
+type fakeNotifier struct {
+	name    string
+	enabled bool
+	err     error
+	sent    []string
+	limit   int
+}
+
+func (f *fakeNotifier) Name() string  { return f.name }
+func (f *fakeNotifier) Enabled() bool { return f.enabled }
+
+func (f *fakeNotifier) NotifyBackupSuccess(context.Context, events.BackupSuccess) error {
+	f.sent = append(f.sent, "success")
+	return f.err
+}
+
+func (f *fakeNotifier) NotifyBackupFailure(context.Context, error) error {
+	f.sent = append(f.sent, "failure")
+	return f.err
+}
+
+func (f *fakeNotifier) NotifyBackupDeleteFailure(context.Context, error) error {
+	f.sent = append(f.sent, "delete-failure")
+	return f.err
+}
+
+func (f *fakeNotifier) NotifyBackupSlow(context.Context, events.BackupSlow) error {
+	f.sent = append(f.sent, "slow")
+	return f.err
+}
+
+func (f *fakeNotifier) NotifyCoverageStarted(context.Context, events.CoverageStarted) error {
+	f.sent = append(f.sent, "coverage-started")
+	return f.err
+}
+
+func (f *fakeNotifier) NotifyRestoreSuccess(context.Context, events.RestoreSuccess) error {
+	f.sent = append(f.sent, "restore-success")
+	return f.err
+}
+
+func (f *fakeNotifier) NotifyRestoreFailure(context.Context, error) error {
+	f.sent = append(f.sent, "restore-failure")
+	return f.err
+}
+
+func (f *fakeNotifier) NotifyKeyExpiring(context.Context, events.KeyExpiring) error {
+	f.sent = append(f.sent, "key-expiring")
+	return f.err
+}
+
+func (f *fakeNotifier) NotifyDrillOverdue(context.Context, events.DrillOverdue) error {
+	f.sent = append(f.sent, "drill-overdue")
+	return f.err
+}
+
+func (f *fakeNotifier) NotifyObjectiveViolated(context.Context, events.ObjectiveViolated) error {
+	f.sent = append(f.sent, "objective-violated")
+	return f.err
+}
+
+func (f *fakeNotifier) NotifyThrottled(_ context.Context, evt events.Throttled) error {
+	f.sent = append(f.sent, fmt.Sprintf("throttled %v", evt.Suppressed))
+	return f.err
+}
+
+func (f *fakeNotifier) RateLimit() int { return f.limit }
+
+func TestNotifier_Test(t *testing.T) {
+	n := NewNotifier(&config.Config{Notifiers: config.NotifiersConfig{Enabled: true}}).(*Notifier) //nolint:errcheck // reason: NewNotifier always returns *Notifier
+
+	ok := &fakeNotifier{name: "ok", enabled: true}
+	broken := &fakeNotifier{name: "broken", enabled: true, err: errors.New("webhook returned 404")}
+	off := &fakeNotifier{name: "off"}
+	n.register(ok)
+	n.register(broken)
+	n.register(off)
+
+	results, err := n.Test(context.Background(), "failure")
+	require.NoError(t, err)
+	assert.Equal(t, []TestResult{
+		{Notifier: "ok", Enabled: true},
+		{Notifier: "broken", Enabled: true, Err: broken.err},
+		{Notifier: "off"},
+	}, results)
+	assert.Equal(t, []string{"failure"}, ok.sent)
+	assert.Empty(t, off.sent)
+}
+
+func TestNotifier_Test_UnknownEvent(t *testing.T) {
+	n := NewNotifier(&config.Config{Notifiers: config.NotifiersConfig{Enabled: true}})
+
+	_, err := n.Test(context.Background(), "explosion")
+	require.ErrorIs(t, err, ErrUnknownTestEvent)
+}
@@ -0,0 +1,174 @@
+package notifiers
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/notifiers/events"
+)
+
+var timestampRe = regexp.MustCompile(`^[0-9]+$`)
+
+// redactor hides database names and storage keys in notifications, for chat channels that reach
+// further than access to the data does. Manifests, logs and webhooks keep the real names.
+type redactor struct {
+	mode string
+
+	// seen holds the database names events have carried, to scrub them from error messages too.
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+// newRedactor returns the redactor for mode, or nil if notifications aren't redacted.
+func newRedactor(mode string) *redactor {
+	if mode == "" {
+		return nil
+	}
+	return &redactor{mode: mode, seen: map[string]struct{}{}}
+}
+
+// redact hides s: a short hash, stable across runs so repeated alerts can still be matched, or its
+// first character.
+func (r *redactor) redact(s string) string {
+	if s == "" {
+		return s
+	}
+	if r.mode == config.RedactMask {
+		first, _ := utf8.DecodeRuneInString(s)
+		return string(first) + "***"
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:4])
+}
+
+// name redacts a database name and remembers it for error messages.
+func (r *redactor) name(db string) string {
+	r.mu.Lock()
+	r.seen[db] = struct{}{}
+	r.mu.Unlock()
+	return r.redact(db)
+}
+
+func (r *redactor) names(dbs []string) []string {
+	if dbs == nil {
+		return nil
+	}
+	out := make([]string, len(dbs))
+	for i, db := range dbs {
+		out[i] = r.name(db)
+	}
+	return out
+}
+
+// key redacts a storage key segment by segment. Timestamps are kept, so the backup can still be
+// looked up by whoever has access.
+func (r *redactor) key(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		if !timestampRe.MatchString(s) {
+			segments[i] = r.redact(s)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// text replaces the database names seen so far in s. Names first seen in a failure can't be told
+// apart from the rest of its message and are left in place.
+func (r *redactor) text(s string) string {
+	r.mu.Lock()
+	seen := slices.Collect(maps.Keys(r.seen))
+	r.mu.Unlock()
+	if len(seen) == 0 {
+		return s
+	}
+
+	// Longer names first, so a name containing another is replaced whole.
+	slices.SortFunc(seen, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+	quoted := make([]string, len(seen))
+	for i, db := range seen {
+		quoted[i] = regexp.QuoteMeta(db)
+	}
+	re := regexp.MustCompile(`\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	return re.ReplaceAllStringFunc(s, r.redact)
+}
+
+func (r *redactor) err(err error) error {
+	if r == nil || err == nil {
+		return err
+	}
+	return errors.New(r.text(err.Error()))
+}
+
+func (r *redactor) backupSuccess(evt events.BackupSuccess) events.BackupSuccess {
+	if r == nil {
+		return evt
+	}
+	// Results carries every database on the server; learn them before scrubbing the reasons.
+	results := make([]events.DatabaseResult, len(evt.Results))
+	for i, res := range evt.Results {
+		res.Name = r.name(res.Name)
+		results[i] = res
+	}
+	for db := range evt.Skipped {
+		r.name(db)
+	}
+	for i := range results {
+		results[i].Reason = r.text(results[i].Reason)
+	}
+
+	if evt.Skipped != nil {
+		skipped := make(map[string]string, len(evt.Skipped))
+		for db, reason := range evt.Skipped {
+			skipped[r.redact(db)] = r.text(reason)
+		}
+		evt.Skipped = skipped
+	}
+	if evt.Results != nil {
+		evt.Results = results
+	}
+	evt.Key = r.key(evt.Key)
+	return evt
+}
+
+func (r *redactor) backupSlow(evt events.BackupSlow) events.BackupSlow {
+	if r == nil {
+		return evt
+	}
+	evt.Key = r.key(evt.Key)
+	return evt
+}
+
+func (r *redactor) coverageStarted(evt events.CoverageStarted) events.CoverageStarted {
+	if r == nil {
+		return evt
+	}
+	evt.Key = r.key(evt.Key)
+	evt.Databases = r.names(evt.Databases)
+	return evt
+}
+
+func (r *redactor) restoreSuccess(evt events.RestoreSuccess) events.RestoreSuccess {
+	if r == nil {
+		return evt
+	}
+	evt.Key = r.key(evt.Key)
+	evt.Databases = r.names(evt.Databases)
+	return evt
+}
+
+func (r *redactor) drillOverdue(evt events.DrillOverdue) events.DrillOverdue {
+	if r == nil {
+		return evt
+	}
+	evt.LastError = r.text(evt.LastError)
+	return evt
+}
@@ -0,0 +1,54 @@
+package notifiers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/notifiers/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactor_Off(t *testing.T) {
+	r := newRedactor("")
+	evt := events.BackupSuccess{Key: "db-1/20250101000000/postgres.zip", Results: []events.DatabaseResult{{Name: "payroll"}}}
+	assert.Equal(t, evt, r.backupSuccess(evt))
+
+	err := errors.New("error dumping payroll")
+	assert.Equal(t, err, r.err(err))
+}
+
+func TestRedactor_Hash(t *testing.T) {
+	r := newRedactor(config.RedactHash)
+
+	evt := r.backupSuccess(events.BackupSuccess{
+		Key:     "db-1/20250101000000/postgres.zip",
+		Skipped: map[string]string{"billing": "database billing is too large"},
+		Results: []events.DatabaseResult{
+			{Name: "payroll", Status: events.DatabaseOK},
+			{Name: "billing", Status: events.DatabaseSkipped, Reason: "database billing is too large"},
+		},
+	})
+
+	hashed := r.redact("payroll")
+	assert.Len(t, hashed, 8)
+	assert.Equal(t, hashed, evt.Results[0].Name)
+	assert.Equal(t, "database "+r.redact("billing")+" is too large", evt.Results[1].Reason)
+	assert.Contains(t, evt.Skipped, r.redact("billing"))
+	assert.Equal(t, r.redact("db-1")+"/20250101000000/"+r.redact("postgres.zip"), evt.Key)
+
+	// Names seen in earlier events are scrubbed from failures; other words are left alone.
+	err := r.err(errors.New("error dumping payroll: payroll_archive is locked"))
+	assert.Equal(t, "error dumping "+hashed+": payroll_archive is locked", err.Error())
+}
+
+func TestRedactor_Mask(t *testing.T) {
+	r := newRedactor(config.RedactMask)
+
+	evt := r.restoreSuccess(events.RestoreSuccess{Key: "20250101000000/app.zip", Databases: []string{"payroll", "app"}})
+	assert.Equal(t, []string{"p***", "a***"}, evt.Databases)
+	assert.Equal(t, "20250101000000/a***", evt.Key)
+
+	drill := r.drillOverdue(events.DrillOverdue{LastError: "error restoring database payroll"})
+	assert.Equal(t, "error restoring database p***", drill.LastError)
+}
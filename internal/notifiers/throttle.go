@@ -0,0 +1,65 @@
+package notifiers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hibare/stashly/internal/notifiers/events"
+)
+
+// throttleWindow is the period a notifier's rate limit applies to.
+const throttleWindow = time.Hour
+
+// throttle caps how many messages a notifier sends per window. Events over the cap are counted and
+// collapsed into a single summary, sent once the notifier has room again.
+type throttle struct {
+	mu         sync.Mutex
+	limit      int
+	sent       []time.Time
+	suppressed map[string]int
+	since      time.Time
+	now        func() time.Time
+}
+
+// allow reports whether event may be sent now, recording it as sent when it may. If events were
+// suppressed and there is room, it first returns their summary, which the caller must send and which
+// counts as a message itself.
+func (t *throttle) allow(event string) (bool, *events.Throttled) {
+	if t == nil || t.limit <= 0 {
+		return true, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	for len(t.sent) > 0 && now.Sub(t.sent[0]) >= throttleWindow {
+		t.sent = t.sent[1:]
+	}
+
+	var summary *events.Throttled
+	if len(t.suppressed) > 0 && len(t.sent) < t.limit {
+		summary = &events.Throttled{Suppressed: t.suppressed, Since: t.since, Limit: t.limit}
+		t.suppressed = map[string]int{}
+		t.sent = append(t.sent, now)
+	}
+
+	if len(t.sent) >= t.limit {
+		if len(t.suppressed) == 0 {
+			t.since = now
+		}
+		t.suppressed[event]++
+		return false, summary
+	}
+
+	t.sent = append(t.sent, now)
+	return true, summary
+}
+
+func newThrottle(limit int) *throttle {
+	return &throttle{
+		limit:      limit,
+		suppressed: map[string]int{},
+		now:        time.Now,
+	}
+}
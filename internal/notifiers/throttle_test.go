@@ -0,0 +1,71 @@
+package notifiers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/notifiers/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottle_Allow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	th := newThrottle(2)
+	th.now = func() time.Time { return now }
+
+	ok, summary := th.allow("backup_failure")
+	assert.True(t, ok)
+	assert.Nil(t, summary)
+	ok, _ = th.allow("backup_failure")
+	assert.True(t, ok)
+
+	// Over the limit: suppressed and counted.
+	ok, summary = th.allow("backup_failure")
+	assert.False(t, ok)
+	assert.Nil(t, summary)
+	now = now.Add(time.Minute)
+	ok, _ = th.allow("backup_success")
+	assert.False(t, ok)
+
+	// Once the window has room the summary goes out first, then the event.
+	now = now.Add(time.Hour)
+	ok, summary = th.allow("backup_failure")
+	assert.True(t, ok)
+	require.NotNil(t, summary)
+	assert.Equal(t, map[string]int{"backup_failure": 1, "backup_success": 1}, summary.Suppressed)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), summary.Since)
+	assert.Equal(t, 2, summary.Limit)
+
+	// The summary counted towards the limit.
+	ok, summary = th.allow("backup_failure")
+	assert.False(t, ok)
+	assert.Nil(t, summary)
+}
+
+func TestThrottle_Unlimited(t *testing.T) {
+	th := newThrottle(0)
+	for range 100 {
+		ok, summary := th.allow("backup_failure")
+		assert.True(t, ok)
+		assert.Nil(t, summary)
+	}
+}
+
+func TestNotifier_RateLimit(t *testing.T) {
+	n := NewNotifier(&config.Config{Notifiers: config.NotifiersConfig{Enabled: true}}).(*Notifier) //nolint:errcheck // reason: NewNotifier always returns *Notifier
+	limited := &fakeNotifier{name: "limited", enabled: true, limit: 1}
+	n.register(limited)
+
+	for range 3 {
+		require.NoError(t, n.NotifyBackupSlow(context.Background(), events.BackupSlow{}))
+	}
+	assert.Equal(t, []string{"slow"}, limited.sent)
+
+	// Test notifications bypass the rate limit.
+	_, err := n.Test(context.Background(), "slow")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"slow", "slow"}, limited.sent)
+}
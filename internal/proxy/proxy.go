@@ -0,0 +1,69 @@
+// Package proxy selects the proxy used for storage, key server and webhook requests.
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hibare/stashly/internal/config"
+)
+
+// ErrUnsupportedScheme is returned for proxy URLs other than http, https, socks5 and socks5h.
+var ErrUnsupportedScheme = errors.New("unsupported proxy scheme")
+
+// Func returns the proxy function for cfg. Without a configured URL it honors the HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY environment variables; otherwise every request uses the configured
+// proxy except those to hosts matching cfg.NoProxy.
+func Func(cfg config.ProxyConfig) (func(*http.Request) (*url.URL, error), error) {
+	if cfg.URL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	proxyURL, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedScheme, proxyURL.Scheme)
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		if bypass(cfg.NoProxy, req.URL.Hostname()) {
+			return nil, nil //nolint:nilnil // reason: a nil URL means no proxy to net/http
+		}
+		return proxyURL, nil
+	}, nil
+}
+
+// bypass reports whether host matches one of the comma-separated NO_PROXY style entries: "*",
+// a host name (also matching its subdomains, with or without a leading dot), an IP or a CIDR.
+func bypass(noProxy []string, host string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			return true
+		case strings.Contains(entry, "/"):
+			if _, network, err := net.ParseCIDR(entry); err == nil && ip != nil && network.Contains(ip) {
+				return true
+			}
+		default:
+			domain := strings.TrimPrefix(entry, ".")
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
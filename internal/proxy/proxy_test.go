@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFunc(t *testing.T) {
+	proxyFunc, err := Func(config.ProxyConfig{
+		URL:     "socks5://proxy.internal:1080",
+		NoProxy: []string{"localhost", ".svc.cluster.local", "10.0.0.0/8"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		url   string
+		proxy string
+	}{
+		{url: "https://s3.amazonaws.com/bucket", proxy: "socks5://proxy.internal:1080"},
+		{url: "http://localhost:9000/bucket"},
+		{url: "http://minio.backups.svc.cluster.local:9000"},
+		{url: "http://10.1.2.3:9000"},
+		{url: "http://192.168.1.1:9000", proxy: "socks5://proxy.internal:1080"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			req, rErr := http.NewRequestWithContext(context.Background(), http.MethodGet, tt.url, nil)
+			require.NoError(t, rErr)
+
+			got, pErr := proxyFunc(req)
+			require.NoError(t, pErr)
+			if tt.proxy == "" {
+				assert.Nil(t, got)
+				return
+			}
+			assert.Equal(t, tt.proxy, got.String())
+		})
+	}
+}
+
+func TestFunc_Environment(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://env-proxy:3128")
+
+	proxyFunc, err := Func(config.ProxyConfig{})
+	require.NoError(t, err)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "https://keyserver.ubuntu.com", nil)
+	require.NoError(t, err)
+	got, err := proxyFunc(req)
+	require.NoError(t, err)
+	assert.Equal(t, "http://env-proxy:3128", got.String())
+}
+
+func TestFunc_UnsupportedScheme(t *testing.T) {
+	_, err := Func(config.ProxyConfig{URL: "ftp://proxy:21"})
+	require.ErrorIs(t, err, ErrUnsupportedScheme)
+}
@@ -0,0 +1,129 @@
+// Package remediation maps common failure classes to short hints on how to fix them, for failure
+// notifications and diagnostic output.
+package remediation
+
+import (
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/aws/smithy-go"
+)
+
+// Class is a class of failure with a known remediation.
+type Class string
+
+// Failure classes.
+const (
+	ClassAuth            Class = "auth"
+	ClassDNS             Class = "dns"
+	ClassDiskFull        Class = "disk-full"
+	ClassVersionMismatch Class = "version-mismatch"
+)
+
+// Hint is the remediation for a failure class.
+type Hint struct {
+	Class Class
+	Text  string
+}
+
+// Error tags an error with its failure class, for code that knows why an operation failed.
+type Error struct {
+	Class Class
+	Err   error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Wrap tags err with class. A nil err stays nil.
+func Wrap(class Class, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Class: class, Err: err}
+}
+
+// authErrorCodes are storage API error codes caused by wrong or expired credentials.
+var authErrorCodes = []string{"InvalidAccessKeyId", "SignatureDoesNotMatch", "AccessDenied", "ExpiredToken", "InvalidToken"}
+
+// entry is one class in the catalog: its hint and how to recognise it.
+type entry struct {
+	class Class
+	text  string
+
+	// match recognises typed errors.
+	match func(err error) bool
+
+	// messages are substrings of error output (e.g. pg_dump's stderr) that identify the class.
+	messages []string
+}
+
+var catalog = []entry{
+	{
+		class: ClassAuth,
+		text: "Check the credentials: postgres.user/postgres.password (and pg_hba.conf) for the database, " +
+			"s3.access-key/s3.secret-key, the key files or the IAM role (s3.auth-mode) for storage.",
+		match: func(err error) bool {
+			var apiErr smithy.APIError
+			return errors.As(err, &apiErr) && slices.Contains(authErrorCodes, apiErr.ErrorCode())
+		},
+		messages: []string{"password authentication failed", "no pg_hba.conf entry", "authentication failed"},
+	},
+	{
+		class: ClassDNS,
+		text:  "A host name could not be resolved; check postgres.host, s3.endpoint and the DNS settings of the host or container.",
+		match: func(err error) bool {
+			var dnsErr *net.DNSError
+			return errors.As(err, &dnsErr)
+		},
+		messages: []string{"could not translate host name", "no such host"},
+	},
+	{
+		class: ClassDiskFull,
+		text: "The disk holding the temporary directory is full; free space, point TMPDIR at a larger volume " +
+			"or set backup.volume-size-mb.",
+		match: func(err error) bool {
+			return errors.Is(err, syscall.ENOSPC)
+		},
+		messages: []string{"no space left on device"},
+	},
+	{
+		class:    ClassVersionMismatch,
+		text:     "The PostgreSQL client tools are older than the server; install a postgresql-client matching the server's major version.",
+		messages: []string{"server version mismatch", "unsupported version"},
+	},
+}
+
+// For returns the remediation hint for err, if its failure class is known.
+func For(err error) (Hint, bool) {
+	if err == nil {
+		return Hint{}, false
+	}
+
+	class := Class("")
+	var tagged *Error
+	if errors.As(err, &tagged) {
+		class = tagged.Class
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, e := range catalog {
+		if e.class == class || (e.match != nil && e.match(err)) || containsAny(msg, e.messages) {
+			return Hint{Class: e.class, Text: e.text}, true
+		}
+	}
+	return Hint{}, false
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package remediation
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFor(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Class
+	}{
+		{name: "storage credentials", err: fmt.Errorf("upload: %w", &smithy.GenericAPIError{Code: "InvalidAccessKeyId"}), want: ClassAuth},
+		{name: "postgres password", err: errors.New(`pg_dump: error: FATAL:  password authentication failed for user "app"`), want: ClassAuth},
+		{name: "dns", err: &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "db"}}, want: ClassDNS},
+		{name: "disk full", err: &os.PathError{Op: "write", Path: "/tmp/x", Err: syscall.ENOSPC}, want: ClassDiskFull},
+		{name: "pg_dump too old", err: errors.New("pg_dump: error: aborting because of server version mismatch"), want: ClassVersionMismatch},
+		{name: "tagged", err: Wrap(ClassDiskFull, errors.New("volume quota exceeded")), want: ClassDiskFull},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hint, ok := For(tt.err)
+			assert.True(t, ok)
+			assert.Equal(t, tt.want, hint.Class)
+			assert.NotEmpty(t, hint.Text)
+		})
+	}
+
+	_, ok := For(errors.New("something else"))
+	assert.False(t, ok)
+	_, ok = For(nil)
+	assert.False(t, ok)
+	assert.NoError(t, Wrap(ClassAuth, nil))
+}
@@ -0,0 +1,292 @@
+// Package scheduler runs backups on a cron schedule and tracks their state.
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-co-op/gocron"
+	"github.com/hibare/stashly/internal/metrics"
+	robfigCron "github.com/robfig/cron/v3"
+)
+
+var (
+	// ErrJobAbandoned is recorded as the last error when the watchdog abandons a job that ran past its cap.
+	ErrJobAbandoned = errors.New("backup job exceeded max run duration and was abandoned")
+
+	// ErrJobCancelled is recorded as the last error of a job cancelled with Cancel. It is also the
+	// cause of the job's context, so the job can tell a cancellation from a daemon shutdown.
+	ErrJobCancelled = errors.New("backup job was cancelled")
+
+	// ErrNoSuchJob is returned by Cancel when no job with the given ID is running.
+	ErrNoSuchJob = errors.New("no such running backup job")
+)
+
+// JobFunc runs a single backup.
+type JobFunc func(ctx context.Context) error
+
+// Status is a point-in-time view of the scheduler.
+type Status struct {
+	Running     bool      `json:"running"`
+	Paused      bool      `json:"paused"`
+	JobRunning  bool      `json:"job_running"`
+	JobID       uint64    `json:"job_id,omitempty"`
+	Cron        string    `json:"cron"`
+	NextRun     time.Time `json:"next_run,omitzero"`
+	LastRun     time.Time `json:"last_run,omitzero"`
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// Scheduler wraps gocron with run-state tracking.
+type Scheduler struct {
+	cron  string
+	job   JobFunc
+	gocr  *gocron.Scheduler
+	entry *gocron.Job
+
+	// paused, when set, is checked before every run; runs are skipped while it returns true.
+	paused func(ctx context.Context) bool
+
+	mu          sync.RWMutex
+	jobRunning  bool
+	lastRun     time.Time
+	lastSuccess time.Time
+	lastErr     error
+
+	// runID identifies the current run; cancel cancels its context. flagged and abandoned
+	// hold the ID of the last run the watchdog reported and abandoned, cancelled that of the
+	// last run cancelled through Cancel.
+	runID     uint64
+	cancel    context.CancelCauseFunc
+	flagged   uint64
+	abandoned uint64
+	cancelled uint64
+}
+
+func (s *Scheduler) run(ctx context.Context) {
+	if s.paused != nil && s.paused(ctx) {
+		slog.InfoContext(ctx, "Skipping scheduled backup; maintenance mode is on")
+		return
+	}
+
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	s.mu.Lock()
+	s.runID++
+	id := s.runID
+	s.cancel = cancel
+	s.jobRunning = true
+	s.lastRun = time.Now()
+	s.mu.Unlock()
+
+	err := s.job(jobCtx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.abandoned == id {
+		slog.WarnContext(ctx, "Abandoned backup job finished", "error", err)
+		return
+	}
+	s.jobRunning = false
+	if s.cancelled == id {
+		s.lastErr = ErrJobCancelled
+		slog.WarnContext(ctx, "Scheduled backup cancelled", "job_id", id, "error", err)
+		return
+	}
+	s.lastErr = err
+	if err == nil {
+		s.lastSuccess = time.Now()
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Scheduled backup failed", "error", err)
+	} else {
+		slog.InfoContext(ctx, "Scheduled backup completed successfully")
+	}
+}
+
+// Status returns the current scheduler state.
+func (s *Scheduler) Status() Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st := Status{
+		Running:     s.gocr.IsRunning(),
+		Paused:      s.paused != nil && s.paused(context.Background()),
+		JobRunning:  s.jobRunning,
+		Cron:        s.cron,
+		LastRun:     s.lastRun,
+		LastSuccess: s.lastSuccess,
+	}
+	if s.jobRunning {
+		st.JobID = s.runID
+	}
+	if s.entry != nil {
+		st.NextRun = s.entry.NextRun()
+	}
+	if s.lastErr != nil {
+		st.LastError = s.lastErr.Error()
+	}
+	return st
+}
+
+// checkWatchdog reports the running job once it has run longer than maxRun, with the process'
+// goroutine and heap figures to help tell a leak from a slow backup. With abandon set it also
+// cancels the job and resets the run state so later runs start fresh. It returns whether the
+// job was over its cap.
+func (s *Scheduler) checkWatchdog(ctx context.Context, maxRun time.Duration, abandon bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.jobRunning || time.Since(s.lastRun) <= maxRun {
+		return false
+	}
+
+	if s.flagged != s.runID {
+		s.flagged = s.runID
+		metrics.WatchdogTrips.Inc()
+
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		slog.ErrorContext(ctx, "Backup job exceeded max run duration",
+			"started", s.lastRun, "max_run_duration", maxRun, "abandon", abandon,
+			"goroutines", runtime.NumGoroutine(), "heap_bytes", mem.HeapAlloc)
+	}
+
+	if abandon {
+		s.cancel(ErrJobAbandoned)
+		s.abandoned = s.runID
+		s.jobRunning = false
+		s.lastErr = ErrJobAbandoned
+	}
+	return true
+}
+
+// Watch runs the watchdog until ctx is done, checking the running job against maxRun.
+func (s *Scheduler) Watch(ctx context.Context, maxRun time.Duration, abandon bool) {
+	interval := min(max(maxRun/10, time.Second), time.Minute)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkWatchdog(ctx, maxRun, abandon)
+		}
+	}
+}
+
+// Cancel cancels the running job if its ID is id, as reported in Status. It doesn't wait for the
+// job to stop: the job's context is cancelled, which kills its pg_dump and psql processes and
+// aborts uploads, and the run is recorded with ErrJobCancelled once it returns.
+func (s *Scheduler) Cancel(id uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.jobRunning || s.runID != id {
+		return ErrNoSuchJob
+	}
+	if s.cancelled != id {
+		s.cancelled = id
+		s.cancel(ErrJobCancelled)
+		metrics.CancelledJobs.Inc()
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// NewCancelHandler returns a handler for DELETE /jobs/{id} that cancels the running job with that
+// ID. It answers 202 with the scheduler status once the job has been told to stop, and 404 if the
+// job isn't running. onCancel, if set, is called with each job cancelled and the caller's address,
+// e.g. to record it.
+func NewCancelHandler(s *Scheduler, onCancel func(ctx context.Context, id uint64, remoteAddr string)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid job id"})
+			return
+		}
+
+		if err := s.Cancel(id); err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		slog.WarnContext(r.Context(), "Backup job cancelled", "job_id", id, "remote_addr", r.RemoteAddr)
+		if onCancel != nil {
+			onCancel(r.Context(), id, r.RemoteAddr)
+		}
+		writeJSON(w, http.StatusAccepted, s.Status())
+	})
+}
+
+// PauseWhen makes the scheduler skip runs while paused returns true, e.g. in maintenance mode.
+func (s *Scheduler) PauseWhen(paused func(ctx context.Context) bool) {
+	s.paused = paused
+}
+
+// StartBlocking starts the scheduler and blocks until it is stopped.
+func (s *Scheduler) StartBlocking() {
+	s.gocr.StartBlocking()
+}
+
+// Stop stops the scheduler.
+func (s *Scheduler) Stop() {
+	s.gocr.Stop()
+}
+
+// New creates a scheduler that runs job according to the cron expression.
+func New(ctx context.Context, cron string, job JobFunc) (*Scheduler, error) {
+	s := &Scheduler{
+		cron: cron,
+		job:  job,
+		gocr: gocron.NewScheduler(time.UTC),
+	}
+
+	entry, err := s.gocr.Cron(cron).Do(func() { s.run(ctx) })
+	if err != nil {
+		return nil, err
+	}
+	s.entry = entry
+
+	return s, nil
+}
+
+// Next returns when the cron expression next fires after from, in from's location.
+func Next(cron string, from time.Time) (time.Time, error) {
+	schedule, err := robfigCron.ParseStandard(cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return schedule.Next(from), nil
+}
+
+// RunsPerMonth returns how many times the cron expression fires in the 30 days after from.
+func RunsPerMonth(cron string, from time.Time) (int, error) {
+	schedule, err := robfigCron.ParseStandard(cron)
+	if err != nil {
+		return 0, err
+	}
+
+	runs := 0
+	end := from.AddDate(0, 0, 30)
+	for next := schedule.Next(from); !next.After(end); next = schedule.Next(next) {
+		runs++
+	}
+	return runs, nil
+}
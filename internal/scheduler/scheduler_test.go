@@ -0,0 +1,201 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_InvalidCron(t *testing.T) {
+	_, err := New(context.Background(), "not a cron", func(context.Context) error { return nil })
+	require.Error(t, err)
+}
+
+func TestScheduler_Status(t *testing.T) {
+	fail := true
+	s, err := New(context.Background(), "0 0 * * *", func(context.Context) error {
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	st := s.Status()
+	assert.False(t, st.Running)
+	assert.Equal(t, "0 0 * * *", st.Cron)
+	assert.True(t, st.LastRun.IsZero())
+
+	s.run(context.Background())
+	st = s.Status()
+	assert.False(t, st.LastRun.IsZero())
+	assert.True(t, st.LastSuccess.IsZero())
+	assert.Equal(t, "boom", st.LastError)
+
+	fail = false
+	s.run(context.Background())
+	st = s.Status()
+	assert.False(t, st.LastSuccess.IsZero())
+	assert.Empty(t, st.LastError)
+	assert.False(t, st.JobRunning)
+}
+
+func TestScheduler_PauseWhen(t *testing.T) {
+	runs := 0
+	s, err := New(context.Background(), "0 0 * * *", func(context.Context) error {
+		runs++
+		return nil
+	})
+	require.NoError(t, err)
+
+	paused := true
+	s.PauseWhen(func(context.Context) bool { return paused })
+
+	s.run(context.Background())
+	assert.Equal(t, 0, runs)
+	assert.True(t, s.Status().Paused)
+	assert.True(t, s.Status().LastRun.IsZero())
+
+	paused = false
+	s.run(context.Background())
+	assert.Equal(t, 1, runs)
+	assert.False(t, s.Status().Paused)
+}
+
+func TestRunsPerMonth(t *testing.T) {
+	from := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	runs, err := RunsPerMonth("0 0 * * *", from)
+	require.NoError(t, err)
+	assert.Equal(t, 30, runs)
+
+	runs, err = RunsPerMonth("0 */6 * * *", from)
+	require.NoError(t, err)
+	assert.Equal(t, 120, runs)
+
+	_, err = RunsPerMonth("not a cron", from)
+	require.Error(t, err)
+}
+
+func TestNext(t *testing.T) {
+	next, err := Next("0 3 * * 0", time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 5, 3, 0, 0, 0, time.UTC), next)
+
+	_, err = Next("not a cron", time.Now())
+	require.Error(t, err)
+}
+
+func TestScheduler_checkWatchdog(t *testing.T) {
+	started := make(chan struct{})
+	finished := make(chan error, 1)
+	s, err := New(context.Background(), "0 0 * * *", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	require.NoError(t, err)
+
+	go func() {
+		s.run(context.Background())
+		finished <- nil
+	}()
+	<-started
+
+	// Within the cap nothing happens.
+	assert.False(t, s.checkWatchdog(context.Background(), time.Hour, true))
+	assert.True(t, s.Status().JobRunning)
+
+	// Over the cap without abandoning, the job is only reported.
+	s.mu.Lock()
+	s.lastRun = time.Now().Add(-2 * time.Hour)
+	s.mu.Unlock()
+	assert.True(t, s.checkWatchdog(context.Background(), time.Hour, false))
+	assert.True(t, s.Status().JobRunning)
+
+	// Abandoning cancels the job and resets the run state.
+	assert.True(t, s.checkWatchdog(context.Background(), time.Hour, true))
+	<-finished
+	st := s.Status()
+	assert.False(t, st.JobRunning)
+	assert.Equal(t, ErrJobAbandoned.Error(), st.LastError)
+}
+
+func TestScheduler_Cancel(t *testing.T) {
+	started := make(chan struct{})
+	finished := make(chan struct{})
+	var cause error
+	s, err := New(context.Background(), "0 0 * * *", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		cause = context.Cause(ctx)
+		return ctx.Err()
+	})
+	require.NoError(t, err)
+
+	require.ErrorIs(t, s.Cancel(1), ErrNoSuchJob)
+
+	go func() {
+		s.run(context.Background())
+		close(finished)
+	}()
+	<-started
+
+	st := s.Status()
+	assert.True(t, st.JobRunning)
+	assert.Equal(t, uint64(1), st.JobID)
+
+	require.ErrorIs(t, s.Cancel(2), ErrNoSuchJob)
+	require.NoError(t, s.Cancel(1))
+	<-finished
+
+	require.ErrorIs(t, cause, ErrJobCancelled)
+	st = s.Status()
+	assert.False(t, st.JobRunning)
+	assert.Zero(t, st.JobID)
+	assert.Equal(t, ErrJobCancelled.Error(), st.LastError)
+	require.ErrorIs(t, s.Cancel(1), ErrNoSuchJob)
+}
+
+func TestNewCancelHandler(t *testing.T) {
+	started := make(chan struct{})
+	finished := make(chan struct{})
+	s, err := New(context.Background(), "0 0 * * *", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	require.NoError(t, err)
+
+	var cancelled []uint64
+	mux := http.NewServeMux()
+	mux.Handle("DELETE /jobs/{id}", NewCancelHandler(s, func(_ context.Context, id uint64, remoteAddr string) {
+		assert.NotEmpty(t, remoteAddr)
+		cancelled = append(cancelled, id)
+	}))
+	cancelJob := func(id string) int {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/jobs/"+id, nil))
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusBadRequest, cancelJob("latest"))
+	assert.Equal(t, http.StatusNotFound, cancelJob("1"))
+
+	go func() {
+		s.run(context.Background())
+		close(finished)
+	}()
+	<-started
+
+	assert.Equal(t, http.StatusAccepted, cancelJob("1"))
+	<-finished
+	assert.Equal(t, ErrJobCancelled.Error(), s.Status().LastError)
+	assert.Equal(t, []uint64{1}, cancelled)
+}
@@ -0,0 +1,175 @@
+// Package server provides the HTTP endpoints exposed by the long-running daemon.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	checkTimeout      = 10 * time.Second
+	readHeaderTimeout = 5 * time.Second
+	shutdownTimeout   = 10 * time.Second
+
+	statusOK   = "ok"
+	statusFail = "fail"
+)
+
+// CheckFunc is a readiness check. It may return details to include in the response body.
+type CheckFunc func(ctx context.Context) (any, error)
+
+// CheckResult is the outcome of a single readiness check.
+type CheckResult struct {
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Details any    `json:"details,omitempty"`
+}
+
+type healthResponse struct {
+	Status  string `json:"status"`
+	Started string `json:"started"`
+	Uptime  string `json:"uptime"`
+}
+
+type readyResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Server serves health, readiness and other daemon endpoints.
+type Server struct {
+	addr    string
+	mux     *http.ServeMux
+	started time.Time
+
+	mu     sync.RWMutex
+	checks map[string]CheckFunc
+}
+
+// AddReadinessCheck registers a named check evaluated by /readyz.
+func (s *Server) AddReadinessCheck(name string, fn CheckFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks[name] = fn
+}
+
+// Handle registers an additional handler on the server.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// RequireToken wraps h so it is only served to requests carrying token as a bearer token. With an
+// empty token every request is refused, which keeps endpoints that change state off by default.
+func RequireToken(token string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "server.admin-token is not set"})
+			return
+		}
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid or missing admin token"})
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, healthResponse{
+		Status:  statusOK,
+		Started: s.started.UTC().Format(time.RFC3339),
+		Uptime:  time.Since(s.started).Round(time.Second).String(),
+	})
+}
+
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.checks))
+	for name := range s.checks {
+		names = append(names, name)
+	}
+	s.mu.RUnlock()
+	sort.Strings(names)
+
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	resp := readyResponse{Status: statusOK, Checks: map[string]CheckResult{}}
+	for _, name := range names {
+		s.mu.RLock()
+		fn := s.checks[name]
+		s.mu.RUnlock()
+
+		details, err := fn(ctx)
+		result := CheckResult{Status: statusOK, Details: details}
+		if err != nil {
+			result.Status = statusFail
+			result.Error = err.Error()
+			resp.Status = statusFail
+		}
+		resp.Checks[name] = result
+	}
+
+	code := http.StatusOK
+	if resp.Status != statusOK {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, resp)
+}
+
+// Handler returns the server's HTTP handler.
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// ListenAndServe serves until ctx is cancelled, then shuts down gracefully.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.addr,
+		Handler:           s.mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	slog.InfoContext(ctx, "Starting HTTP server", "addr", s.addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// New creates a server listening on addr with /healthz and /readyz registered.
+func New(addr string) *Server {
+	s := &Server{
+		addr:    addr,
+		mux:     http.NewServeMux(),
+		started: time.Now(),
+		checks:  map[string]CheckFunc{},
+	}
+
+	s.mux.HandleFunc("GET /healthz", s.handleHealthz)
+	s.mux.HandleFunc("GET /readyz", s.handleReadyz)
+
+	return s
+}
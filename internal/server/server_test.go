@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Healthz(t *testing.T) {
+	srv := New(":0")
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var body healthResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, statusOK, body.Status)
+}
+
+func TestServer_Readyz_AllChecksPass(t *testing.T) {
+	srv := New(":0")
+	srv.AddReadinessCheck("storage", func(context.Context) (any, error) {
+		return map[string]string{"backend": "s3"}, nil
+	})
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var body readyResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, statusOK, body.Status)
+	assert.Equal(t, statusOK, body.Checks["storage"].Status)
+	assert.Equal(t, map[string]any{"backend": "s3"}, body.Checks["storage"].Details)
+}
+
+func TestServer_Readyz_CheckFails(t *testing.T) {
+	srv := New(":0")
+	srv.AddReadinessCheck("config", func(context.Context) (any, error) { return nil, nil })
+	srv.AddReadinessCheck("storage", func(context.Context) (any, error) {
+		return nil, errors.New("access denied")
+	})
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	var body readyResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, statusFail, body.Status)
+	assert.Equal(t, statusOK, body.Checks["config"].Status)
+	assert.Equal(t, statusFail, body.Checks["storage"].Status)
+	assert.Equal(t, "access denied", body.Checks["storage"].Error)
+}
+
+func TestRequireToken(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	call := func(h http.Handler, auth string) int {
+		req := httptest.NewRequest(http.MethodDelete, "/jobs/1", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	h := RequireToken("secret", ok)
+	assert.Equal(t, http.StatusNoContent, call(h, "Bearer secret"))
+	assert.Equal(t, http.StatusUnauthorized, call(h, "Bearer wrong"))
+	assert.Equal(t, http.StatusUnauthorized, call(h, "secret"))
+	assert.Equal(t, http.StatusUnauthorized, call(h, ""))
+
+	assert.Equal(t, http.StatusForbidden, call(RequireToken("", ok), "Bearer "))
+}
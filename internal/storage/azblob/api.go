@@ -0,0 +1,173 @@
+package azblob
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/httpclient"
+)
+
+// listing is one page of a hierarchical blob listing.
+type listing struct {
+	// Blobs are the names of the blobs directly under the prefix.
+	Blobs []string
+
+	// Sizes are the sizes of Blobs, in the same order.
+	Sizes []int64
+
+	// Prefixes are the virtual directories under the prefix, ending in the delimiter.
+	Prefixes []string
+
+	// NextMarker continues the listing; empty on the last page.
+	NextMarker string
+}
+
+// apiIface is the subset of the Azure Blob Storage API used by this backend.
+type apiIface interface {
+	// UploadFile uploads f to the blob name, recording md5 as the blob's Content-MD5.
+	UploadFile(ctx context.Context, name string, f *os.File, md5 []byte) error
+
+	// DownloadFile downloads the blob name into f.
+	DownloadFile(ctx context.Context, name string, f *os.File) error
+
+	// List returns one page of the blobs under prefix; an empty delimiter lists flat.
+	List(ctx context.Context, prefix, delimiter, marker string, maxResults int32) (listing, error)
+
+	// Delete deletes the blob name.
+	Delete(ctx context.Context, name string) error
+
+	// SetTier moves the blob name to an access tier.
+	SetTier(ctx context.Context, name string, tier blob.AccessTier) error
+}
+
+// containerAPI implements apiIface with an Azure SDK container client.
+type containerAPI struct {
+	client *container.Client
+}
+
+func (c *containerAPI) UploadFile(ctx context.Context, name string, f *os.File, md5 []byte) error {
+	_, err := c.client.NewBlockBlobClient(name).UploadFile(ctx, f, &blockblob.UploadFileOptions{
+		HTTPHeaders:             &blob.HTTPHeaders{BlobContentMD5: md5},
+		TransactionalValidation: blob.TransferValidationTypeComputeCRC64(),
+	})
+	return err
+}
+
+func (c *containerAPI) DownloadFile(ctx context.Context, name string, f *os.File) error {
+	_, err := c.client.NewBlobClient(name).DownloadFile(ctx, f, nil)
+	return err
+}
+
+func (c *containerAPI) List(ctx context.Context, prefix, delimiter, marker string, maxResults int32) (listing, error) {
+	var out listing
+	var m *string
+	if marker != "" {
+		m = &marker
+	}
+	var limit *int32
+	if maxResults > 0 {
+		limit = &maxResults
+	}
+
+	if delimiter == "" {
+		resp, err := c.client.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{Prefix: &prefix, Marker: m, MaxResults: limit}).NextPage(ctx)
+		if err != nil {
+			return out, err
+		}
+		for _, item := range resp.Segment.BlobItems {
+			out.Blobs = append(out.Blobs, *item.Name)
+			out.Sizes = append(out.Sizes, contentLength(item.Properties))
+		}
+		if resp.NextMarker != nil {
+			out.NextMarker = *resp.NextMarker
+		}
+		return out, nil
+	}
+
+	resp, err := c.client.NewListBlobsHierarchyPager(delimiter, &container.ListBlobsHierarchyOptions{Prefix: &prefix, Marker: m, MaxResults: limit}).NextPage(ctx)
+	if err != nil {
+		return out, err
+	}
+	for _, item := range resp.Segment.BlobItems {
+		out.Blobs = append(out.Blobs, *item.Name)
+		out.Sizes = append(out.Sizes, contentLength(item.Properties))
+	}
+	for _, p := range resp.Segment.BlobPrefixes {
+		out.Prefixes = append(out.Prefixes, *p.Name)
+	}
+	if resp.NextMarker != nil {
+		out.NextMarker = *resp.NextMarker
+	}
+	return out, nil
+}
+
+// contentLength returns the size in the properties of a listed blob, or 0 if they don't report one.
+func contentLength(props *container.BlobProperties) int64 {
+	if props == nil || props.ContentLength == nil {
+		return 0
+	}
+	return *props.ContentLength
+}
+
+func (c *containerAPI) Delete(ctx context.Context, name string) error {
+	_, err := c.client.NewBlobClient(name).Delete(ctx, nil)
+	return err
+}
+
+func (c *containerAPI) SetTier(ctx context.Context, name string, tier blob.AccessTier) error {
+	_, err := c.client.NewBlobClient(name).SetTier(ctx, tier, nil)
+	return err
+}
+
+// containerURL returns the URL of the configured container, without credentials.
+func containerURL(cfg config.AzblobConfig) string {
+	endpoint := strings.TrimSuffix(cfg.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", cfg.Account)
+	}
+	return endpoint + "/" + url.PathEscape(cfg.Container)
+}
+
+// newAPIClient creates a container client authenticated with the SAS token, or else the managed
+// identity. Requests use the configured proxy and TLS settings.
+func newAPIClient(cfg *config.Config) (*containerAPI, error) {
+	transport, err := httpclient.NewTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	clientOpts := azcore.ClientOptions{Transport: &http.Client{Transport: transport}}
+	opts := &container.ClientOptions{ClientOptions: clientOpts}
+
+	u := containerURL(cfg.Azblob)
+	if token := strings.TrimPrefix(cfg.Azblob.SASToken, "?"); token != "" {
+		client, cErr := container.NewClientWithNoCredential(u+"?"+token, opts)
+		if cErr != nil {
+			return nil, cErr
+		}
+		return &containerAPI{client: client}, nil
+	}
+
+	miOpts := &azidentity.ManagedIdentityCredentialOptions{ClientOptions: clientOpts}
+	if id := cfg.Azblob.ManagedIdentityClientID; id != "" {
+		miOpts.ID = azidentity.ClientID(id)
+	}
+	cred, err := azidentity.NewManagedIdentityCredential(miOpts)
+	if err != nil {
+		return nil, err
+	}
+	client, err := container.NewClient(u, cred, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &containerAPI{client: client}, nil
+}
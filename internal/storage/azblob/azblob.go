@@ -0,0 +1,243 @@
+// Package azblob provides an implementation of storage interface for Azure Blob Storage.
+package azblob
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec // reason: Azure records blob integrity as Content-MD5
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/storage"
+)
+
+// maxListResults is the most blobs Azure returns per list call.
+const maxListResults = 5000
+
+// Azblob implements the StorageIface for Azure Blob Storage.
+type Azblob struct {
+	cfg *config.Config
+	api apiIface
+}
+
+// Init prepares the Azure storage by creating the container client.
+func (a *Azblob) Init(_ context.Context) error {
+	api, err := newAPIClient(a.cfg)
+	if err != nil {
+		return err
+	}
+	a.api = api
+	return nil
+}
+
+// Name returns the name of the storage backend (e.g., "azblob").
+func (a *Azblob) Name() string {
+	return fmt.Sprintf("azblob (%s/%s)", a.cfg.Azblob.Account, a.cfg.Azblob.Container)
+}
+
+// ErrorClass classifies transient errors, telling Azure's ServerBusy throttling apart from other
+// failed responses.
+func (a *Azblob) ErrorClass(err error) string {
+	if bloberror.HasCode(err, bloberror.ServerBusy) {
+		return storage.RetryThrottling
+	}
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		return storage.StatusClass(respErr.StatusCode)
+	}
+	return storage.ErrorClass(err)
+}
+
+// basePrefix returns the prefix all backups of this instance are stored under, ending in "/".
+func (a *Azblob) basePrefix() string {
+	return storage.BuildKey(a.cfg.Azblob.Prefix, a.cfg.App.InstanceID)
+}
+
+// md5File returns the MD5 digest of a file.
+func md5File(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	h := md5.New() //nolint:gosec // reason: Azure records blob integrity as Content-MD5
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// Upload uploads a local file into the backup at timestamp and returns the remote key. Blocks are
+// checked with CRC64 in transit and the file's MD5 is stored as the blob's Content-MD5.
+func (a *Azblob) Upload(ctx context.Context, timestamp, localPath string) (string, error) {
+	key := storage.BuildKey(a.cfg.Azblob.Prefix, a.cfg.App.InstanceID, timestamp) + path.Base(localPath)
+
+	checksum, err := md5File(localPath)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	slog.DebugContext(ctx, "Uploading file to Azure", "file", localPath, "container", a.cfg.Azblob.Container, "key", key)
+	if err := a.api.UploadFile(ctx, key, f, checksum); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// Download fetches the blob at key into localPath.
+func (a *Azblob) Download(ctx context.Context, key, localPath string) error {
+	f, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	if err := a.api.DownloadFile(ctx, key, f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// List returns the backup prefixes under the instance prefix.
+func (a *Azblob) List(ctx context.Context) ([]string, error) {
+	var keys []string
+	opts := storage.ListOptions{}
+	for {
+		page, err := a.ListPage(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, page.Keys...)
+		if page.NextToken == "" {
+			return keys, nil
+		}
+		opts.Token = page.NextToken
+	}
+}
+
+// ListPage returns one page of the backups under the instance prefix, narrowed by opts. Azure can't
+// start a listing at a name, so StartAfter is applied to each page instead.
+func (a *Azblob) ListPage(ctx context.Context, opts storage.ListOptions) (storage.Page, error) {
+	prefix := a.basePrefix()
+
+	limit := int32(0)
+	if opts.Limit > 0 {
+		limit = int32(min(opts.Limit, maxListResults)) //nolint:gosec // reason: bounded by maxListResults
+	}
+	out, err := a.api.List(ctx, prefix+opts.Prefix, "/", opts.Token, limit)
+	if err != nil {
+		return storage.Page{}, err
+	}
+
+	page := storage.Page{NextToken: out.NextMarker}
+	for _, key := range append(out.Blobs, out.Prefixes...) {
+		if key == prefix {
+			continue
+		}
+		if opts.StartAfter != "" && strings.TrimSuffix(strings.TrimPrefix(key, prefix), "/") <= opts.StartAfter {
+			continue
+		}
+		page.Keys = append(page.Keys, key)
+	}
+	return page, nil
+}
+
+// ListFiles returns the keys of all blobs stored under the backup at the given timestamp.
+func (a *Azblob) ListFiles(ctx context.Context, timestamp string) ([]string, error) {
+	prefix := storage.BuildKey(a.cfg.Azblob.Prefix, a.cfg.App.InstanceID, timestamp)
+
+	var keys []string
+	marker := ""
+	for {
+		out, err := a.api.List(ctx, prefix, "", marker, 0)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, out.Blobs...)
+		if out.NextMarker == "" {
+			return keys, nil
+		}
+		marker = out.NextMarker
+	}
+}
+
+// Stats sums the sizes of the blobs stored under the instance prefix per backup.
+func (a *Azblob) Stats(ctx context.Context) (storage.Usage, error) {
+	prefix := a.basePrefix()
+
+	sizes := map[string]int64{}
+	marker := ""
+	for {
+		out, err := a.api.List(ctx, prefix, "", marker, 0)
+		if err != nil {
+			return storage.Usage{}, err
+		}
+		for i, key := range out.Blobs {
+			sizes[key] = out.Sizes[i]
+		}
+		if out.NextMarker == "" {
+			return storage.UsageOf(prefix, sizes), nil
+		}
+		marker = out.NextMarker
+	}
+}
+
+// Delete deletes the backup at timestamp, i.e. every blob under its prefix, from Azure.
+func (a *Azblob) Delete(ctx context.Context, timestamp string) error {
+	keys, err := a.ListFiles(ctx, timestamp)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := a.api.Delete(ctx, key); err != nil && !bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetStorageClass moves the blob at key to an access tier such as "Cool", "Cold" or "Archive".
+func (a *Azblob) SetStorageClass(ctx context.Context, key, class string) error {
+	return a.api.SetTier(ctx, key, blob.AccessTier(class))
+}
+
+// TrimPrefix trims the instance prefix and trailing "/" from keys, leaving backup timestamps.
+func (a *Azblob) TrimPrefix(keys []string) []string {
+	prefix := a.basePrefix()
+	trimmed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		trimmed = append(trimmed, strings.TrimSuffix(strings.TrimPrefix(key, prefix), "/"))
+	}
+	return trimmed
+}
+
+// NewAzblobStorage creates a new Azure Blob Storage instance with the provided configuration.
+func NewAzblobStorage(cfg *config.Config) *Azblob {
+	return &Azblob{cfg: cfg}
+}
+
+func init() {
+	storage.Register("azblob", storage.Registration{
+		New:   func(cfg *config.Config) storage.StorageIface { return NewAzblobStorage(cfg) },
+		Retry: func(cfg *config.Config) config.RetryConfig { return cfg.Azblob.Retry },
+	})
+}
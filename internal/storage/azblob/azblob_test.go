@@ -0,0 +1,190 @@
+package azblob
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec // reason: Azure records blob integrity as Content-MD5
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAPI keeps blobs in memory and pages listings by index.
+type fakeAPI struct {
+	blobs   map[string][]byte
+	md5s    map[string][]byte
+	tiers   map[string]blob.AccessTier
+	missing map[string]bool
+}
+
+func newFakeAPI() *fakeAPI {
+	return &fakeAPI{
+		blobs:   map[string][]byte{},
+		md5s:    map[string][]byte{},
+		tiers:   map[string]blob.AccessTier{},
+		missing: map[string]bool{},
+	}
+}
+
+func (f *fakeAPI) UploadFile(_ context.Context, name string, file *os.File, md5 []byte) error {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	f.blobs[name] = data
+	f.md5s[name] = md5
+	return nil
+}
+
+func (f *fakeAPI) DownloadFile(_ context.Context, name string, file *os.File) error {
+	_, err := file.Write(f.blobs[name])
+	return err
+}
+
+func (f *fakeAPI) List(_ context.Context, prefix, delimiter, marker string, maxResults int32) (listing, error) {
+	seen := map[string]bool{}
+	var entries []string
+	for name := range f.blobs {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if delimiter != "" {
+			if i := strings.Index(name[len(prefix):], delimiter); i >= 0 {
+				p := name[:len(prefix)+i+1]
+				if !seen[p] {
+					seen[p] = true
+					entries = append(entries, p)
+				}
+				continue
+			}
+		}
+		entries = append(entries, name)
+	}
+	sort.Strings(entries)
+
+	offset, _ := strconv.Atoi(marker)
+	end := len(entries)
+	if maxResults > 0 {
+		end = min(offset+int(maxResults), len(entries))
+	}
+
+	var out listing
+	for _, e := range entries[offset:end] {
+		if seen[e] {
+			out.Prefixes = append(out.Prefixes, e)
+		} else {
+			out.Blobs = append(out.Blobs, e)
+			out.Sizes = append(out.Sizes, int64(len(f.blobs[e])))
+		}
+	}
+	if end < len(entries) {
+		out.NextMarker = strconv.Itoa(end)
+	}
+	return out, nil
+}
+
+func (f *fakeAPI) Delete(_ context.Context, name string) error {
+	if f.missing[name] {
+		return &azcore.ResponseError{ErrorCode: "BlobNotFound", StatusCode: 404}
+	}
+	delete(f.blobs, name)
+	return nil
+}
+
+func (f *fakeAPI) SetTier(_ context.Context, name string, tier blob.AccessTier) error {
+	f.tiers[name] = tier
+	return nil
+}
+
+func newTestAzblob() (*Azblob, *fakeAPI) {
+	cfg := &config.Config{
+		App:    config.AppConfig{InstanceID: "instance"},
+		Azblob: config.AzblobConfig{Account: "account", Container: "backups", Prefix: "prefix"},
+	}
+	api := newFakeAPI()
+	a := NewAzblobStorage(cfg)
+	a.api = api
+	return a, api
+}
+
+func TestAzblob_UploadDownloadDelete(t *testing.T) {
+	a, api := newTestAzblob()
+	ctx := t.Context()
+
+	dir := t.TempDir()
+	local := filepath.Join(dir, "postgres-plain.zip")
+	require.NoError(t, os.WriteFile(local, []byte("archive"), 0600))
+
+	key, err := a.Upload(ctx, "20250101000000", local)
+	require.NoError(t, err)
+	assert.Equal(t, "prefix/instance/20250101000000/postgres-plain.zip", key)
+	sum := md5.Sum([]byte("archive")) //nolint:gosec // reason: Azure records blob integrity as Content-MD5
+	assert.Equal(t, sum[:], api.md5s[key])
+
+	out := filepath.Join(dir, "download.zip")
+	require.NoError(t, a.Download(ctx, key, out))
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "archive", string(data))
+
+	require.NoError(t, a.SetStorageClass(ctx, key, "Cold"))
+	assert.Equal(t, blob.AccessTierCold, api.tiers[key])
+
+	api.blobs["prefix/instance/20250101000000/manifest.json"] = []byte("{}")
+	api.missing["prefix/instance/20250101000000/manifest.json"] = true
+	require.NoError(t, a.Delete(ctx, "20250101000000"))
+	assert.NotContains(t, api.blobs, key)
+}
+
+func TestAzblob_List(t *testing.T) {
+	a, api := newTestAzblob()
+	for _, ts := range []string{"20250101000000", "20250102000000", "20250201000000"} {
+		api.blobs["prefix/instance/"+ts+"/postgres-plain.zip"] = []byte("x")
+	}
+
+	keys, err := a.List(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20250101000000", "20250102000000", "20250201000000"}, a.TrimPrefix(keys))
+
+	page, err := a.ListPage(t.Context(), storage.ListOptions{Prefix: "2025", StartAfter: "20250101000000", Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prefix/instance/20250102000000/"}, page.Keys)
+	require.NotEmpty(t, page.NextToken)
+
+	page, err = a.ListPage(t.Context(), storage.ListOptions{Prefix: "2025", StartAfter: "20250101000000", Limit: 2, Token: page.NextToken})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prefix/instance/20250201000000/"}, page.Keys)
+	assert.Empty(t, page.NextToken)
+}
+
+func TestAzblob_Stats(t *testing.T) {
+	a, api := newTestAzblob()
+	api.blobs["prefix/instance/20250101000000/postgres-plain.zip"] = []byte("archive")
+	api.blobs["prefix/instance/20250101000000/manifest.json"] = []byte("{}")
+	api.blobs["prefix/instance/20250102000000/postgres-plain.zip"] = []byte("newer archive")
+
+	usage, err := a.Stats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(22), usage.Bytes)
+	assert.Equal(t, []storage.BackupUsage{
+		{Timestamp: "20250101000000", Bytes: 9, Files: 2},
+		{Timestamp: "20250102000000", Bytes: 13, Files: 1},
+	}, usage.Backups)
+}
+
+func TestContainerURL(t *testing.T) {
+	assert.Equal(t, "https://account.blob.core.windows.net/backups",
+		containerURL(config.AzblobConfig{Account: "account", Container: "backups"}))
+	assert.Equal(t, "http://127.0.0.1:10000/devstoreaccount1/backups",
+		containerURL(config.AzblobConfig{Container: "backups", Endpoint: "http://127.0.0.1:10000/devstoreaccount1/"}))
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// ErrCheckSkipped is reported for check steps that could not run because an earlier step failed.
+var ErrCheckSkipped = errors.New("skipped")
+
+// CheckStep is the outcome of one operation of a storage round-trip check.
+type CheckStep struct {
+	// Operation is the operation performed (upload, list, download, verify, delete).
+	Operation string
+
+	// Permission is the permission the operation exercises, empty for local-only steps.
+	Permission string
+
+	Latency time.Duration
+	Err     error
+}
+
+// Check uploads, lists, downloads, verifies and deletes a small test object in store,
+// timing each step. The test object is always deleted once it has been uploaded.
+func Check(ctx context.Context, store StorageIface) []CheckStep {
+	steps := []CheckStep{}
+	run := func(operation, permission string, fn func() error) error {
+		start := time.Now()
+		err := fn()
+		steps = append(steps, CheckStep{Operation: operation, Permission: permission, Latency: time.Since(start), Err: err})
+		return err
+	}
+	skip := func(operation, permission string) {
+		steps = append(steps, CheckStep{Operation: operation, Permission: permission, Err: ErrCheckSkipped})
+	}
+
+	tmp, err := os.MkdirTemp("", "stashly-storage-test-")
+	if err != nil {
+		return []CheckStep{{Operation: "prepare", Err: err}}
+	}
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	// A name that can't be mistaken for a backup timestamp.
+	dir := "stashly-storage-test-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	payload := []byte("stashly storage test " + dir + "\n")
+	localPath := filepath.Join(tmp, "probe.txt")
+	if err := os.WriteFile(localPath, payload, 0600); err != nil {
+		return []CheckStep{{Operation: "prepare", Err: err}}
+	}
+
+	var key string
+	if err := run("upload", "write", func() error {
+		var uErr error
+		key, uErr = store.Upload(ctx, dir, localPath)
+		return uErr
+	}); err != nil {
+		skip("list", "list")
+		skip("download", "read")
+		skip("verify", "")
+		skip("delete", "delete")
+		return steps
+	}
+
+	_ = run("list", "list", func() error {
+		files, lErr := store.ListFiles(ctx, dir)
+		if lErr != nil {
+			return lErr
+		}
+		if !slices.Contains(files, key) {
+			return fmt.Errorf("uploaded object %s not listed", key)
+		}
+		return nil
+	})
+
+	downloaded := filepath.Join(tmp, "probe.downloaded")
+	if err := run("download", "read", func() error {
+		return store.Download(ctx, key, downloaded)
+	}); err != nil {
+		skip("verify", "")
+	} else {
+		_ = run("verify", "", func() error {
+			got, rErr := os.ReadFile(downloaded)
+			if rErr != nil {
+				return rErr
+			}
+			if !bytes.Equal(got, payload) {
+				return errors.New("downloaded content does not match the uploaded object")
+			}
+			return nil
+		})
+	}
+
+	_ = run("delete", "delete", func() error {
+		return store.Delete(ctx, dir)
+	})
+	return steps
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// operations returns the operation names of steps in order and the errors keyed by operation.
+func operations(steps []CheckStep) ([]string, map[string]error) {
+	ops := []string{}
+	errs := map[string]error{}
+	for _, s := range steps {
+		ops = append(ops, s.Operation)
+		errs[s.Operation] = s.Err
+	}
+	return ops, errs
+}
+
+func TestCheck_Success(t *testing.T) {
+	store := NewMockStorageIface(t)
+
+	var uploaded []byte
+	store.On("Upload", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			var err error
+			uploaded, err = os.ReadFile(args.String(1))
+			require.NoError(t, err)
+		}).Return("p/i/test/probe.txt", nil)
+	store.On("ListFiles", mock.Anything).Return([]string{"p/i/test/probe.txt"}, nil)
+	store.On("Download", "p/i/test/probe.txt", mock.Anything).
+		Run(func(args mock.Arguments) {
+			require.NoError(t, os.WriteFile(args.String(1), uploaded, 0600))
+		}).Return(nil)
+	store.On("Delete", mock.Anything).Return(nil)
+
+	ops, errs := operations(Check(context.Background(), store))
+
+	assert.Equal(t, []string{"upload", "list", "download", "verify", "delete"}, ops)
+	for op, err := range errs {
+		assert.NoError(t, err, op)
+	}
+}
+
+func TestCheck_ReadDenied(t *testing.T) {
+	store := NewMockStorageIface(t)
+	store.On("Upload", mock.Anything, mock.Anything).Return("p/i/test/probe.txt", nil)
+	store.On("ListFiles", mock.Anything).Return([]string{"p/i/test/probe.txt"}, nil)
+	store.On("Download", mock.Anything, mock.Anything).Return(errors.New("AccessDenied"))
+	store.On("Delete", mock.Anything).Return(nil)
+
+	_, errs := operations(Check(context.Background(), store))
+
+	require.Error(t, errs["download"])
+	require.ErrorIs(t, errs["verify"], ErrCheckSkipped)
+	// The test object is cleaned up even though reading it failed.
+	assert.NoError(t, errs["delete"])
+}
+
+func TestCheck_UploadDenied(t *testing.T) {
+	store := NewMockStorageIface(t)
+	store.On("Upload", mock.Anything, mock.Anything).Return("", errors.New("AccessDenied"))
+
+	ops, errs := operations(Check(context.Background(), store))
+
+	assert.Equal(t, []string{"upload", "list", "download", "verify", "delete"}, ops)
+	require.ErrorIs(t, errs["delete"], ErrCheckSkipped)
+}
@@ -0,0 +1,359 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hibare/stashly/internal/metrics"
+)
+
+// Failover uploads to a secondary backend when uploads to the primary keep failing. Once an upload
+// of a backup has failed over, the rest of that backup goes to the secondary too, so its files stay
+// together. List also returns the backups only the secondary has, so retention, tiering, the catalog
+// and restores see them; their files are listed, downloaded, tiered and deleted on the secondary.
+// Paged listings return the primary's backups, then those only the secondary has, and usage adds up
+// both.
+type Failover struct {
+	StorageIface
+
+	secondary StorageIface
+	attempts  int
+	delay     time.Duration
+
+	mu    sync.Mutex
+	ready bool
+
+	// failedOver holds the timestamps of the backups on the secondary, uploaded there or found there
+	// by List, and secondaryKeys the keys listed from it.
+	failedOver    map[string]bool
+	secondaryKeys map[string]bool
+}
+
+// Init initialises the primary and, best effort, the secondary. A secondary that can't be reached
+// yet doesn't stop backups to the primary; it is initialised again when an upload fails over.
+func (f *Failover) Init(ctx context.Context) error {
+	if err := f.StorageIface.Init(ctx); err != nil {
+		return err
+	}
+	if err := f.initSecondary(ctx); err != nil {
+		slog.WarnContext(ctx, "Failed to initialize secondary storage", "storage", f.secondary.Name(), "error", err)
+	}
+	return nil
+}
+
+func (f *Failover) initSecondary(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.ready {
+		return nil
+	}
+	if err := f.secondary.Init(ctx); err != nil {
+		return err
+	}
+	f.ready = true
+	return nil
+}
+
+// FailedOver reports whether the backup at timestamp is on the secondary.
+func (f *Failover) FailedOver(timestamp string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.failedOver[timestamp]
+}
+
+// Upload uploads a local file to the primary, retrying with a growing delay, and to the secondary
+// once the attempts are used up or the backup has already failed over.
+func (f *Failover) Upload(ctx context.Context, timestamp, localPath string) (string, error) {
+	if f.FailedOver(timestamp) {
+		return f.secondary.Upload(ctx, timestamp, localPath)
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var key string
+		key, err = f.StorageIface.Upload(ctx, timestamp, localPath)
+		if err == nil {
+			return key, nil
+		}
+		if ctx.Err() != nil || attempt >= f.attempts {
+			break
+		}
+
+		wait := f.delay * time.Duration(attempt)
+		slog.WarnContext(ctx, "Upload failed, retrying", "storage", f.Name(), "file", localPath, "attempt", attempt, "retry_in", wait, "error", err)
+		select {
+		case <-ctx.Done():
+			return "", err
+		case <-time.After(wait):
+		}
+	}
+	if ctx.Err() != nil {
+		return "", err
+	}
+
+	slog.ErrorContext(ctx, "Upload to primary storage failed; failing over to secondary storage",
+		"storage", f.Name(), "secondary", f.secondary.Name(), "file", localPath, "error", err)
+	metrics.StorageFailovers.WithLabelValues(f.Name(), f.secondary.Name()).Inc()
+	if iErr := f.initSecondary(ctx); iErr != nil {
+		return "", fmt.Errorf("%w; initializing secondary storage: %w", err, iErr)
+	}
+
+	key, sErr := f.secondary.Upload(ctx, timestamp, localPath)
+	if sErr != nil {
+		return "", fmt.Errorf("%w; secondary storage: %w", err, sErr)
+	}
+	f.mu.Lock()
+	f.failedOver[timestamp] = true
+	f.mu.Unlock()
+	return key, nil
+}
+
+// List lists the primary's keys followed by those of the backups only the secondary has. A secondary
+// that can't be listed is logged and left out, so listing works as well as it does without one.
+func (f *Failover) List(ctx context.Context) ([]string, error) {
+	keys, err := f.StorageIface.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.initSecondary(ctx); err != nil {
+		slog.WarnContext(ctx, "Failed to initialize secondary storage; listing the primary only", "storage", f.secondary.Name(), "error", err)
+		return keys, nil
+	}
+	secondaryKeys, err := f.secondary.List(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to list secondary storage; listing the primary only", "storage", f.secondary.Name(), "error", err)
+		return keys, nil
+	}
+
+	onPrimary := map[string]bool{}
+	for _, ts := range f.StorageIface.TrimPrefix(keys) {
+		onPrimary[ts] = true
+	}
+	return append(keys, f.addSecondary(secondaryKeys, onPrimary)...), nil
+}
+
+// addSecondary records the backups listed from the secondary and returns the keys of those the
+// primary doesn't have. A backup on both, split by a failover, is listed once from the primary, but
+// its files are still read from both.
+func (f *Failover) addSecondary(secondaryKeys []string, onPrimary map[string]bool) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := []string{}
+	for _, key := range secondaryKeys {
+		ts := f.secondary.TrimPrefix([]string{key})[0]
+		f.failedOver[ts] = true
+		if onPrimary[ts] {
+			continue
+		}
+		keys = append(keys, key)
+		f.secondaryKeys[key] = true
+	}
+	return keys
+}
+
+// Paged listings continue on the backend their token names.
+const (
+	primaryToken   = "primary:"
+	secondaryToken = "secondary:"
+)
+
+// ListPage returns a page of the primary's keys and, once those run out, pages of the keys of the
+// backups only the secondary has. A secondary that can't be listed ends the listing after the primary,
+// as in List.
+func (f *Failover) ListPage(ctx context.Context, opts ListOptions) (Page, error) {
+	if token, ok := strings.CutPrefix(opts.Token, secondaryToken); ok {
+		opts.Token = token
+		return f.secondaryPage(ctx, opts)
+	}
+
+	opts.Token = strings.TrimPrefix(opts.Token, primaryToken)
+	page, err := f.StorageIface.ListPage(ctx, opts)
+	if err != nil {
+		return Page{}, err
+	}
+	if page.NextToken != "" {
+		page.NextToken = primaryToken + page.NextToken
+	} else {
+		page.NextToken = secondaryToken
+	}
+	return page, nil
+}
+
+// secondaryPage returns a page of the keys of the backups only the secondary has.
+func (f *Failover) secondaryPage(ctx context.Context, opts ListOptions) (Page, error) {
+	if err := f.initSecondary(ctx); err != nil {
+		slog.WarnContext(ctx, "Failed to initialize secondary storage; listing the primary only", "storage", f.secondary.Name(), "error", err)
+		return Page{}, nil
+	}
+	page, err := f.secondary.ListPage(ctx, opts)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to list secondary storage; listing the primary only", "storage", f.secondary.Name(), "error", err)
+		return Page{}, nil
+	}
+
+	// The secondary only holds failed-over backups, so checking each against the primary stays cheap.
+	onPrimary := map[string]bool{}
+	for _, ts := range f.secondary.TrimPrefix(page.Keys) {
+		files, err := f.StorageIface.ListFiles(ctx, ts)
+		if err != nil {
+			return Page{}, err
+		}
+		onPrimary[ts] = len(files) > 0
+	}
+	page.Keys = f.addSecondary(page.Keys, onPrimary)
+	if page.NextToken != "" {
+		page.NextToken = secondaryToken + page.NextToken
+	}
+	return page, nil
+}
+
+// TrimPrefix trims each key with the prefix of the backend it was listed from.
+func (f *Failover) TrimPrefix(keys []string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	trimmed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if f.secondaryKeys[key] {
+			trimmed = append(trimmed, f.secondary.TrimPrefix([]string{key})...)
+		} else {
+			trimmed = append(trimmed, f.StorageIface.TrimPrefix([]string{key})...)
+		}
+	}
+	return trimmed
+}
+
+// ListFiles lists the files of the backup at timestamp. Those of a backup on the secondary are listed
+// on both backends, since files uploaded before it failed over stay on the primary.
+func (f *Failover) ListFiles(ctx context.Context, timestamp string) ([]string, error) {
+	keys, err := f.StorageIface.ListFiles(ctx, timestamp)
+	if err != nil || !f.FailedOver(timestamp) {
+		return keys, err
+	}
+	secondaryKeys, err := f.secondary.ListFiles(ctx, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	for _, key := range secondaryKeys {
+		f.secondaryKeys[key] = true
+	}
+	f.mu.Unlock()
+	return append(keys, secondaryKeys...), nil
+}
+
+// Download downloads key from the backend it was listed from.
+func (f *Failover) Download(ctx context.Context, key, localPath string) error {
+	f.mu.Lock()
+	onSecondary := f.secondaryKeys[key]
+	f.mu.Unlock()
+	if onSecondary {
+		return f.secondary.Download(ctx, key, localPath)
+	}
+	return f.StorageIface.Download(ctx, key, localPath)
+}
+
+// SetStorageClass changes the storage class of key on the backend it was listed from.
+func (f *Failover) SetStorageClass(ctx context.Context, key, class string) error {
+	f.mu.Lock()
+	onSecondary := f.secondaryKeys[key]
+	f.mu.Unlock()
+	if onSecondary {
+		return f.secondary.SetStorageClass(ctx, key, class)
+	}
+	return f.StorageIface.SetStorageClass(ctx, key, class)
+}
+
+// Delete deletes the backup at timestamp from the backends that have its files. The secondary is
+// always checked, so a backup on both leaves no copy behind. While it can't be checked deletes fail,
+// and a later purge deletes the backup.
+func (f *Failover) Delete(ctx context.Context, timestamp string) error {
+	if err := f.initSecondary(ctx); err != nil {
+		return fmt.Errorf("initializing secondary storage: %w", err)
+	}
+	secondaryKeys, err := f.secondary.ListFiles(ctx, timestamp)
+	if err != nil {
+		return fmt.Errorf("secondary storage: %w", err)
+	}
+	if len(secondaryKeys) == 0 && !f.FailedOver(timestamp) {
+		return f.StorageIface.Delete(ctx, timestamp)
+	}
+
+	if len(secondaryKeys) > 0 {
+		if err := f.secondary.Delete(ctx, timestamp); err != nil {
+			return err
+		}
+	}
+	f.mu.Lock()
+	delete(f.failedOver, timestamp)
+	f.mu.Unlock()
+
+	keys, err := f.StorageIface.ListFiles(ctx, timestamp)
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+	return f.StorageIface.Delete(ctx, timestamp)
+}
+
+// Stats adds up the usage of both backends. The files of a backup on both count towards one backup. A
+// secondary that can't be read is logged and left out, as in List.
+func (f *Failover) Stats(ctx context.Context) (Usage, error) {
+	usage, err := f.StorageIface.Stats(ctx)
+	if err != nil {
+		return Usage{}, err
+	}
+	if err := f.initSecondary(ctx); err != nil {
+		slog.WarnContext(ctx, "Failed to initialize secondary storage; reporting the primary only", "storage", f.secondary.Name(), "error", err)
+		return usage, nil
+	}
+	secondary, err := f.secondary.Stats(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to read secondary storage usage; reporting the primary only", "storage", f.secondary.Name(), "error", err)
+		return usage, nil
+	}
+
+	backups := map[string]*BackupUsage{}
+	for _, b := range slices.Concat(usage.Backups, secondary.Backups) {
+		if sum, ok := backups[b.Timestamp]; ok {
+			sum.Bytes += b.Bytes
+			sum.Files += b.Files
+			continue
+		}
+		backups[b.Timestamp] = &b
+	}
+	merged := Usage{Count: len(backups), Backups: make([]BackupUsage, 0, len(backups))}
+	for _, b := range backups {
+		merged.Bytes += b.Bytes
+		merged.Backups = append(merged.Backups, *b)
+	}
+	sort.Slice(merged.Backups, func(i, j int) bool { return merged.Backups[i].Timestamp < merged.Backups[j].Timestamp })
+	return merged, nil
+}
+
+// FailedOverTo returns the name of the secondary backend store uploaded the backup at timestamp to,
+// or "" if it went to the primary.
+func FailedOverTo(store StorageIface, timestamp string) string {
+	if f, ok := store.(*Failover); ok && f.FailedOver(timestamp) {
+		return f.secondary.Name()
+	}
+	return ""
+}
+
+// NewFailover wraps primary so uploads fail over to secondary after attempts tries, waiting delay
+// before the second try and longer before each one after it.
+func NewFailover(primary, secondary StorageIface, attempts int, delay time.Duration) *Failover {
+	return &Failover{
+		StorageIface:  primary,
+		secondary:     secondary,
+		attempts:      max(attempts, 1),
+		delay:         delay,
+		failedOver:    map[string]bool{},
+		secondaryKeys: map[string]bool{},
+	}
+}
@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hibare/stashly/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailover_Upload(t *testing.T) {
+	ctx := context.Background()
+	primary := NewMockStorageIface(t)
+	secondary := NewMockStorageIface(t)
+	primary.On("Name").Return("s3")
+	secondary.On("Name").Return("gcs")
+	primary.On("Init").Return(nil).Once()
+	secondary.On("Init").Return(nil).Once()
+
+	store := NewFailover(primary, secondary, 2, 0)
+	require.NoError(t, store.Init(ctx))
+
+	primary.On("Upload", "20240101000000", "/tmp/a.zip").Return("p/a.zip", nil).Once()
+	key, err := store.Upload(ctx, "20240101000000", "/tmp/a.zip")
+	require.NoError(t, err)
+	assert.Equal(t, "p/a.zip", key)
+	assert.Empty(t, FailedOverTo(store, "20240101000000"))
+
+	// Both attempts on the primary fail, so the archive and the rest of its backup go to the secondary.
+	primary.On("Upload", "20240102000000", "/tmp/b.zip").Return("", errors.New("throttled")).Twice()
+	secondary.On("Upload", "20240102000000", "/tmp/b.zip").Return("s/b.zip", nil).Once()
+	secondary.On("Upload", "20240102000000", "/tmp/manifest.json").Return("s/manifest.json", nil).Once()
+
+	key, err = store.Upload(ctx, "20240102000000", "/tmp/b.zip")
+	require.NoError(t, err)
+	assert.Equal(t, "s/b.zip", key)
+	key, err = store.Upload(ctx, "20240102000000", "/tmp/manifest.json")
+	require.NoError(t, err)
+	assert.Equal(t, "s/manifest.json", key)
+
+	assert.Equal(t, "gcs", FailedOverTo(store, "20240102000000"))
+	assert.InDelta(t, 1, testutil.ToFloat64(metrics.StorageFailovers.WithLabelValues("s3", "gcs")), 0)
+}
+
+func TestFailover_BothFail(t *testing.T) {
+	ctx := context.Background()
+	primary := NewMockStorageIface(t)
+	secondary := NewMockStorageIface(t)
+	primary.On("Name").Return("s3")
+	secondary.On("Name").Return("gcs")
+	primary.On("Init").Return(nil).Once()
+	secondary.On("Init").Return(errors.New("unreachable")).Once()
+	secondary.On("Init").Return(nil).Once()
+
+	store := NewFailover(primary, secondary, 1, 0)
+	require.NoError(t, store.Init(ctx))
+
+	primaryErr := errors.New("primary down")
+	primary.On("Upload", "20240101000000", "/tmp/a.zip").Return("", primaryErr).Once()
+	secondary.On("Upload", "20240101000000", "/tmp/a.zip").Return("", errors.New("secondary down")).Once()
+
+	_, err := store.Upload(ctx, "20240101000000", "/tmp/a.zip")
+	require.ErrorIs(t, err, primaryErr)
+	assert.Contains(t, err.Error(), "secondary down")
+	assert.False(t, store.FailedOver("20240101000000"))
+}
+
+func TestFailover_SecondaryBackups(t *testing.T) {
+	ctx := context.Background()
+	primary := NewMockStorageIface(t)
+	secondary := NewMockStorageIface(t)
+	primary.On("Name").Return("s3").Maybe()
+	secondary.On("Name").Return("gcs").Maybe()
+	primary.On("Init").Return(nil).Once()
+	secondary.On("Init").Return(nil).Once()
+
+	store := NewFailover(primary, secondary, 1, 0)
+	require.NoError(t, store.Init(ctx))
+
+	// 20240101000000 is on both; only the secondary has 20240102000000.
+	primaryKeys := []string{"p/db-1/20240101000000/"}
+	primary.On("List").Return(primaryKeys, nil).Once()
+	primary.On("TrimPrefix", primaryKeys).Return([]string{"20240101000000"}).Twice()
+	secondary.On("List").Return([]string{"s/db-1/20240101000000/", "s/db-1/20240102000000/"}, nil).Once()
+	secondary.On("TrimPrefix", []string{"s/db-1/20240101000000/"}).Return([]string{"20240101000000"}).Once()
+	secondary.On("TrimPrefix", []string{"s/db-1/20240102000000/"}).Return([]string{"20240102000000"}).Twice()
+
+	keys, err := store.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p/db-1/20240101000000/", "s/db-1/20240102000000/"}, keys)
+	assert.Equal(t, []string{"20240101000000", "20240102000000"}, store.TrimPrefix(keys))
+	assert.Equal(t, "gcs", FailedOverTo(store, "20240102000000"))
+
+	// Its files are read from the secondary.
+	primary.On("ListFiles", "20240102000000").Return([]string{}, nil).Twice()
+	secondary.On("ListFiles", "20240102000000").Return([]string{"s/db-1/20240102000000/a.zip"}, nil).Twice()
+	files, err := store.ListFiles(ctx, "20240102000000")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"s/db-1/20240102000000/a.zip"}, files)
+	secondary.On("Download", "s/db-1/20240102000000/a.zip", "/tmp/a.zip").Return(nil).Once()
+	require.NoError(t, store.Download(ctx, "s/db-1/20240102000000/a.zip", "/tmp/a.zip"))
+
+	secondary.On("SetStorageClass", "s/db-1/20240102000000/a.zip", "COLDLINE").Return(nil).Once()
+	require.NoError(t, store.SetStorageClass(ctx, "s/db-1/20240102000000/a.zip", "COLDLINE"))
+
+	// The files of the backup on both are read from both.
+	primary.On("ListFiles", "20240101000000").Return([]string{"p/db-1/20240101000000/a.zip"}, nil).Twice()
+	secondary.On("ListFiles", "20240101000000").Return([]string{"s/db-1/20240101000000/manifest.json"}, nil).Twice()
+	files, err = store.ListFiles(ctx, "20240101000000")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p/db-1/20240101000000/a.zip", "s/db-1/20240101000000/manifest.json"}, files)
+
+	// Retention deletes each backup wherever it is, leaving no copy on either backend.
+	secondary.On("Delete", "20240102000000").Return(nil).Once()
+	require.NoError(t, store.Delete(ctx, "20240102000000"))
+	assert.False(t, store.FailedOver("20240102000000"))
+	secondary.On("Delete", "20240101000000").Return(nil).Once()
+	primary.On("Delete", "20240101000000").Return(nil).Once()
+	require.NoError(t, store.Delete(ctx, "20240101000000"))
+
+	// A backup only on the primary is deleted there.
+	secondary.On("ListFiles", "20240103000000").Return([]string{}, nil).Once()
+	primary.On("Delete", "20240103000000").Return(nil).Once()
+	require.NoError(t, store.Delete(ctx, "20240103000000"))
+}
+
+func TestFailover_DeleteSecondaryDown(t *testing.T) {
+	ctx := context.Background()
+	primary := NewMockStorageIface(t)
+	secondary := NewMockStorageIface(t)
+	secondary.On("Init").Return(errors.New("unreachable")).Once()
+
+	// The secondary might hold a copy, so the delete fails rather than leave it behind.
+	store := NewFailover(primary, secondary, 1, 0)
+	require.ErrorContains(t, store.Delete(ctx, "20240101000000"), "unreachable")
+	primary.AssertNotCalled(t, "Delete", "20240101000000")
+}
+
+func TestFailover_ListPage(t *testing.T) {
+	ctx := context.Background()
+	primary := NewMockStorageIface(t)
+	secondary := NewMockStorageIface(t)
+	secondary.On("Init").Return(nil).Once()
+	store := NewFailover(primary, secondary, 1, 0)
+
+	// The primary's pages come first, then those of the backups only the secondary has.
+	opts := ListOptions{Prefix: "2024", Limit: 2}
+	primary.On("ListPage", opts).Return(Page{Keys: []string{"p/20240101000000/", "p/20240102000000/"}, NextToken: "t1"}, nil).Once()
+	page, err := store.ListPage(ctx, opts)
+	require.NoError(t, err)
+	assert.Equal(t, Page{Keys: []string{"p/20240101000000/", "p/20240102000000/"}, NextToken: "primary:t1"}, page)
+
+	opts.Token = "t1"
+	primary.On("ListPage", opts).Return(Page{Keys: []string{"p/20240103000000/"}}, nil).Once()
+	opts.Token = page.NextToken
+	page, err = store.ListPage(ctx, opts)
+	require.NoError(t, err)
+	assert.Equal(t, Page{Keys: []string{"p/20240103000000/"}, NextToken: "secondary:"}, page)
+
+	// 20240103000000 is on both and was listed from the primary already.
+	secondaryKeys := []string{"s/20240103000000/", "s/20240104000000/"}
+	opts.Token = ""
+	secondary.On("ListPage", opts).Return(Page{Keys: secondaryKeys}, nil).Once()
+	secondary.On("TrimPrefix", secondaryKeys).Return([]string{"20240103000000", "20240104000000"}).Once()
+	secondary.On("TrimPrefix", []string{"s/20240103000000/"}).Return([]string{"20240103000000"}).Once()
+	secondary.On("TrimPrefix", []string{"s/20240104000000/"}).Return([]string{"20240104000000"}).Twice()
+	primary.On("ListFiles", "20240103000000").Return([]string{"p/20240103000000/a.zip"}, nil).Once()
+	primary.On("ListFiles", "20240104000000").Return([]string{}, nil).Once()
+	opts.Token = page.NextToken
+	page, err = store.ListPage(ctx, opts)
+	require.NoError(t, err)
+	assert.Equal(t, Page{Keys: []string{"s/20240104000000/"}}, page)
+	assert.Equal(t, []string{"20240104000000"}, store.TrimPrefix(page.Keys))
+	assert.True(t, store.FailedOver("20240103000000"))
+	assert.True(t, store.FailedOver("20240104000000"))
+}
+
+func TestFailover_Stats(t *testing.T) {
+	ctx := context.Background()
+	primary := NewMockStorageIface(t)
+	secondary := NewMockStorageIface(t)
+	secondary.On("Init").Return(nil).Once()
+	store := NewFailover(primary, secondary, 1, 0)
+
+	primary.On("Stats").Return(Usage{Bytes: 300, Count: 2, Backups: []BackupUsage{
+		{Timestamp: "20240101000000", Bytes: 100, Files: 2},
+		{Timestamp: "20240102000000", Bytes: 200, Files: 1},
+	}}, nil).Once()
+	secondary.On("Stats").Return(Usage{Bytes: 55, Count: 2, Backups: []BackupUsage{
+		{Timestamp: "20240102000000", Bytes: 5, Files: 1},
+		{Timestamp: "20240103000000", Bytes: 50, Files: 2},
+	}}, nil).Once()
+
+	usage, err := store.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Usage{Bytes: 355, Count: 3, Backups: []BackupUsage{
+		{Timestamp: "20240101000000", Bytes: 100, Files: 2},
+		{Timestamp: "20240102000000", Bytes: 205, Files: 2},
+		{Timestamp: "20240103000000", Bytes: 50, Files: 2},
+	}}, usage)
+
+	// Without the secondary, the primary's usage is still reported.
+	primary.On("Stats").Return(Usage{Bytes: 300, Count: 2}, nil).Once()
+	secondary.On("Name").Return("gcs")
+	secondary.On("Stats").Return(Usage{}, errors.New("unreachable")).Once()
+	usage, err = store.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Usage{Bytes: 300, Count: 2}, usage)
+}
+
+func TestFailover_ListSecondaryDown(t *testing.T) {
+	ctx := context.Background()
+	primary := NewMockStorageIface(t)
+	secondary := NewMockStorageIface(t)
+	secondary.On("Name").Return("gcs").Maybe()
+	secondary.On("Init").Return(nil).Once()
+
+	store := NewFailover(primary, secondary, 1, 0)
+
+	// The secondary being unreachable doesn't stop backups on the primary from being listed.
+	primary.On("List").Return([]string{"p/db-1/20240101000000/"}, nil).Once()
+	secondary.On("List").Return(nil, errors.New("unreachable")).Once()
+	keys, err := store.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p/db-1/20240101000000/"}, keys)
+}
+
+func TestFailedOverTo_PlainStore(t *testing.T) {
+	assert.Empty(t, FailedOverTo(NewMockStorageIface(t), "20240101000000"))
+}
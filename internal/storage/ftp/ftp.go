@@ -0,0 +1,357 @@
+// Package ftp provides an implementation of storage interface for FTP and FTPS servers.
+//
+// Backups are stored as one directory per timestamp below the login directory, following the same
+// <prefix>/<instance-id>/<timestamp>/ layout as the object storage backends. Data connections are
+// always passive, which is what servers behind NAT and firewalls expect.
+package ftp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/textproto"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/httpclient"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/jlaffaye/ftp"
+)
+
+// TLS modes for ftp.tls.
+const (
+	// TLSExplicit upgrades the connection with AUTH TLS (FTPES), usually on port 21.
+	TLSExplicit = "explicit"
+
+	// TLSImplicit speaks TLS from the start, usually on port 990.
+	TLSImplicit = "implicit"
+)
+
+var (
+	// ErrStorageClassUnsupported is returned when tiering is configured, since FTP has no storage classes.
+	ErrStorageClassUnsupported = errors.New("ftp does not support storage classes")
+
+	// ErrSizeMismatch is returned when the size the server reports for an upload differs from the local file.
+	ErrSizeMismatch = errors.New("size mismatch")
+
+	// ErrUnknownTLSMode is returned for an ftp.tls value other than "", "explicit" or "implicit".
+	ErrUnknownTLSMode = errors.New("unknown ftp tls mode")
+)
+
+// FTP implements the StorageIface for FTP and FTPS servers.
+type FTP struct {
+	cfg *config.Config
+	tls *tls.Config
+}
+
+// Init validates the configuration and prepares the TLS settings.
+func (f *FTP) Init(_ context.Context) error {
+	switch f.cfg.FTP.TLS {
+	case "":
+		return nil
+	case TLSExplicit, TLSImplicit:
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownTLSMode, f.cfg.FTP.TLS)
+	}
+
+	tlsCfg, err := httpclient.TLSConfig(f.cfg.TLS)
+	if err != nil {
+		return err
+	}
+	if tlsCfg == nil {
+		tlsCfg = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	host, _, err := net.SplitHostPort(f.address())
+	if err != nil {
+		return err
+	}
+	tlsCfg.ServerName = host
+	f.tls = tlsCfg
+	return nil
+}
+
+// address returns the server's host:port, adding the default port for the TLS mode if missing.
+func (f *FTP) address() string {
+	if _, _, err := net.SplitHostPort(f.cfg.FTP.Host); err == nil {
+		return f.cfg.FTP.Host
+	}
+	if f.cfg.FTP.TLS == TLSImplicit {
+		return net.JoinHostPort(f.cfg.FTP.Host, "990")
+	}
+	return net.JoinHostPort(f.cfg.FTP.Host, "21")
+}
+
+// connect opens and logs into a new control connection. FTP connections can't be shared between
+// concurrent transfers, so every operation uses its own; the caller quits it.
+func (f *FTP) connect(ctx context.Context) (*ftp.ServerConn, error) {
+	opts := []ftp.DialOption{
+		ftp.DialWithContext(ctx),
+		ftp.DialWithDisabledEPSV(f.cfg.FTP.DisableEPSV),
+	}
+	if f.cfg.FTP.Timeout > 0 {
+		opts = append(opts, ftp.DialWithTimeout(f.cfg.FTP.Timeout))
+	}
+	switch f.cfg.FTP.TLS {
+	case TLSExplicit:
+		opts = append(opts, ftp.DialWithExplicitTLS(f.tls))
+	case TLSImplicit:
+		opts = append(opts, ftp.DialWithTLS(f.tls))
+	}
+
+	conn, err := ftp.Dial(f.address(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Login(f.cfg.FTP.Username, f.cfg.FTP.Password); err != nil {
+		_ = conn.Quit()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// quit closes conn, logging rather than failing the operation if that goes wrong.
+func quit(ctx context.Context, conn *ftp.ServerConn) {
+	if err := conn.Quit(); err != nil {
+		slog.DebugContext(ctx, "Error closing FTP connection", "error", err)
+	}
+}
+
+// isNotFound reports whether err is the server saying a file or directory is unavailable.
+func isNotFound(err error) bool {
+	var tpErr *textproto.Error
+	return errors.As(err, &tpErr) && tpErr.Code == ftp.StatusFileUnavailable
+}
+
+// Name returns the name of the storage backend (e.g., "ftp").
+func (f *FTP) Name() string {
+	if f.cfg.FTP.TLS != "" {
+		return fmt.Sprintf("ftps (%s)", f.cfg.FTP.Host)
+	}
+	return fmt.Sprintf("ftp (%s)", f.cfg.FTP.Host)
+}
+
+// basePrefix returns the prefix all backups of this instance are stored under, ending in "/".
+func (f *FTP) basePrefix() string {
+	return storage.BuildKey(f.cfg.FTP.Prefix, f.cfg.App.InstanceID)
+}
+
+// mkdirAll creates dir and its parents. MKD fails for directories that already exist, so errors are
+// ignored here and surface as a failed upload instead.
+func mkdirAll(conn *ftp.ServerConn, dir string) {
+	parts := strings.Split(strings.Trim(dir, "/"), "/")
+	for i := range parts {
+		_ = conn.MakeDir(strings.Join(parts[:i+1], "/"))
+	}
+}
+
+// Upload uploads a local file into the backup at timestamp and returns the remote key. The size the
+// server reports for the stored file is checked against the local file.
+func (f *FTP) Upload(ctx context.Context, timestamp, localPath string) (string, error) {
+	dir := storage.BuildKey(f.cfg.FTP.Prefix, f.cfg.App.InstanceID, timestamp)
+	key := dir + path.Base(localPath)
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := f.connect(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer quit(ctx, conn)
+
+	mkdirAll(conn, dir)
+	slog.DebugContext(ctx, "Uploading file to FTP", "file", localPath, "key", key, "size", info.Size())
+	if err := conn.Stor(key, file); err != nil {
+		return "", err
+	}
+
+	// Not every server supports SIZE; only a size it does report has to match.
+	if size, sErr := conn.FileSize(key); sErr == nil && size != info.Size() {
+		return "", fmt.Errorf("%w for %s: local %d, remote %d", ErrSizeMismatch, key, info.Size(), size)
+	}
+	return key, nil
+}
+
+// Download fetches the file at key into localPath.
+func (f *FTP) Download(ctx context.Context, key, localPath string) error {
+	conn, err := f.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer quit(ctx, conn)
+
+	resp, err := conn.Retr(key)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(localPath)
+	if err != nil {
+		_ = resp.Close()
+		return err
+	}
+	if _, err := io.Copy(out, resp); err != nil {
+		_ = resp.Close()
+		_ = out.Close()
+		return err
+	}
+	if err := resp.Close(); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// entries lists dir, keeping directories or files. A missing directory has no entries.
+func (f *FTP) entries(ctx context.Context, dir string, dirs bool) ([]string, error) {
+	conn, err := f.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer quit(ctx, conn)
+
+	return list(conn, dir, dirs)
+}
+
+// list lists dir on conn, returning the keys of its directories (with a trailing "/") or its files.
+func list(conn *ftp.ServerConn, dir string, dirs bool) ([]string, error) {
+	entries, err := conn.List(dir)
+	if isNotFound(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for _, e := range entries {
+		name := path.Base(e.Name)
+		switch {
+		case name == "." || name == "..":
+		case dirs && e.Type == ftp.EntryTypeFolder:
+			keys = append(keys, dir+name+"/")
+		case !dirs && e.Type == ftp.EntryTypeFile:
+			keys = append(keys, dir+name)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// List returns the backup directories under the instance prefix.
+func (f *FTP) List(ctx context.Context) ([]string, error) {
+	return f.entries(ctx, f.basePrefix(), true)
+}
+
+// ListPage returns one page of the backups under the instance prefix, narrowed by opts. FTP can't
+// filter or page listings, so the whole directory is listed and the page cut from it.
+func (f *FTP) ListPage(ctx context.Context, opts storage.ListOptions) (storage.Page, error) {
+	keys, err := f.List(ctx)
+	if err != nil {
+		return storage.Page{}, err
+	}
+	return storage.PageKeys(keys, f.basePrefix(), opts), nil
+}
+
+// ListFiles returns the keys of all files stored under the backup at the given timestamp.
+func (f *FTP) ListFiles(ctx context.Context, timestamp string) ([]string, error) {
+	return f.entries(ctx, storage.BuildKey(f.cfg.FTP.Prefix, f.cfg.App.InstanceID, timestamp), false)
+}
+
+// Stats sums the sizes of the files in each backup directory under the instance prefix, listing
+// them all over one connection.
+func (f *FTP) Stats(ctx context.Context) (storage.Usage, error) {
+	conn, err := f.connect(ctx)
+	if err != nil {
+		return storage.Usage{}, err
+	}
+	defer quit(ctx, conn)
+
+	base := f.basePrefix()
+	dirs, err := list(conn, base, true)
+	if err != nil {
+		return storage.Usage{}, err
+	}
+
+	sizes := map[string]int64{}
+	for _, dir := range dirs {
+		entries, err := conn.List(dir)
+		if err != nil && !isNotFound(err) {
+			return storage.Usage{}, err
+		}
+		for _, e := range entries {
+			if e.Type == ftp.EntryTypeFile {
+				sizes[dir+path.Base(e.Name)] = int64(e.Size) //nolint:gosec // reason: file sizes fit in int64
+			}
+		}
+	}
+	return storage.UsageOf(base, sizes), nil
+}
+
+// Delete deletes the backup at timestamp: every file in its directory, then the directory.
+func (f *FTP) Delete(ctx context.Context, timestamp string) error {
+	dir := storage.BuildKey(f.cfg.FTP.Prefix, f.cfg.App.InstanceID, timestamp)
+
+	conn, err := f.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer quit(ctx, conn)
+
+	keys, err := list(conn, dir, false)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := conn.Delete(key); err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+	if err := conn.RemoveDir(strings.TrimSuffix(dir, "/")); err != nil && !isNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// SetStorageClass is not supported by FTP.
+func (f *FTP) SetStorageClass(_ context.Context, _, _ string) error {
+	return ErrStorageClassUnsupported
+}
+
+// TrimPrefix trims the instance prefix and trailing "/" from keys, leaving backup timestamps.
+func (f *FTP) TrimPrefix(keys []string) []string {
+	prefix := f.basePrefix()
+	trimmed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		trimmed = append(trimmed, strings.TrimSuffix(strings.TrimPrefix(key, prefix), "/"))
+	}
+	return trimmed
+}
+
+// NewFTPStorage creates a new FTP storage instance with the provided configuration.
+func NewFTPStorage(cfg *config.Config) *FTP {
+	return &FTP{cfg: cfg}
+}
+
+func init() {
+	storage.Register("ftp", storage.Registration{
+		New:   func(cfg *config.Config) storage.StorageIface { return NewFTPStorage(cfg) },
+		Retry: func(cfg *config.Config) config.RetryConfig { return cfg.FTP.Retry },
+	})
+}
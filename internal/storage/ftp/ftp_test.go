@@ -0,0 +1,285 @@
+package ftp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFTP is an in-memory FTP server speaking just enough of the protocol for the backend:
+// login, EPSV data connections, MLSD listings and file and directory commands.
+type fakeFTP struct {
+	mu    sync.Mutex
+	files map[string][]byte
+	dirs  map[string]bool
+	ln    net.Listener
+}
+
+func newFakeFTP(t *testing.T) *fakeFTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeFTP{files: map[string][]byte{}, dirs: map[string]bool{}, ln: ln}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, aErr := ln.Accept()
+			if aErr != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeFTP) serve(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...any) {
+		_, _ = fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+	reply("220 ready")
+
+	var data net.Listener
+	// transfer accepts the data connection opened after EPSV and runs fn on it.
+	transfer := func(fn func(net.Conn)) {
+		reply("150 opening data connection")
+		dc, err := data.Accept()
+		_ = data.Close()
+		if err != nil {
+			reply("425 no data connection")
+			return
+		}
+		fn(dc)
+		_ = dc.Close()
+		reply("226 transfer complete")
+	}
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+
+		s.mu.Lock()
+		switch strings.ToUpper(cmd) {
+		case "USER":
+			reply("331 password required")
+		case "PASS":
+			if arg != "secret" {
+				reply("530 login incorrect")
+				break
+			}
+			reply("230 logged in")
+		case "FEAT":
+			reply("211-Features:\r\n MLST type*;size*;modify*;\r\n SIZE\r\n211 End")
+		case "TYPE":
+			reply("200 ok")
+		case "EPSV":
+			data, _ = net.Listen("tcp", "127.0.0.1:0")
+			reply("229 Entering Extended Passive Mode (|||%d|)", data.Addr().(*net.TCPAddr).Port)
+		case "MKD":
+			if s.dirs[arg] {
+				reply("550 exists")
+				break
+			}
+			s.dirs[arg] = true
+			reply("257 created")
+		case "STOR":
+			if !s.dirs[path.Dir(arg)] {
+				reply("550 no such directory")
+				break
+			}
+			s.mu.Unlock()
+			var body []byte
+			transfer(func(dc net.Conn) { body, _ = io.ReadAll(dc) })
+			s.mu.Lock()
+			s.files[arg] = body
+		case "RETR":
+			body, ok := s.files[arg]
+			if !ok {
+				reply("550 not found")
+				break
+			}
+			transfer(func(dc net.Conn) { _, _ = dc.Write(body) })
+		case "SIZE":
+			body, ok := s.files[arg]
+			if !ok {
+				reply("550 not found")
+				break
+			}
+			reply("213 %d", len(body))
+		case "MLSD":
+			dir := strings.TrimSuffix(arg, "/")
+			if !s.dirs[dir] {
+				reply("550 not found")
+				break
+			}
+			lines := []string{"type=cdir;modify=20240101000000; ."}
+			for d := range s.dirs {
+				if path.Dir(d) == dir {
+					lines = append(lines, "type=dir;modify=20240101000000; "+path.Base(d))
+				}
+			}
+			for f, body := range s.files {
+				if path.Dir(f) == dir {
+					lines = append(lines, fmt.Sprintf("type=file;size=%d;modify=20240101000000; %s", len(body), path.Base(f)))
+				}
+			}
+			sort.Strings(lines)
+			transfer(func(dc net.Conn) { _, _ = io.WriteString(dc, strings.Join(lines, "\r\n")+"\r\n") })
+		case "DELE":
+			if _, ok := s.files[arg]; !ok {
+				reply("550 not found")
+				break
+			}
+			delete(s.files, arg)
+			reply("250 deleted")
+		case "RMD":
+			if !s.dirs[arg] {
+				reply("550 not found")
+				break
+			}
+			delete(s.dirs, arg)
+			reply("250 removed")
+		case "QUIT":
+			reply("221 bye")
+			s.mu.Unlock()
+			return
+		default:
+			reply("502 not implemented")
+		}
+		s.mu.Unlock()
+	}
+}
+
+func newTestFTP(t *testing.T, srv *fakeFTP) *FTP {
+	t.Helper()
+	cfg := &config.Config{
+		App: config.AppConfig{InstanceID: "instance"},
+		FTP: config.FTPConfig{Host: srv.ln.Addr().String(), Prefix: "backups", Username: "stashly", Password: "secret"},
+	}
+	f := NewFTPStorage(cfg)
+	require.NoError(t, f.Init(context.Background()))
+	return f
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(p, []byte(content), 0600))
+	return p
+}
+
+func TestFTP_UploadDownloadDelete(t *testing.T) {
+	ctx := context.Background()
+	srv := newFakeFTP(t)
+	f := newTestFTP(t, srv)
+
+	key, err := f.Upload(ctx, "20240101000000", writeFile(t, "postgres-plain.zip", "archive"))
+	require.NoError(t, err)
+	assert.Equal(t, "backups/instance/20240101000000/postgres-plain.zip", key)
+	_, err = f.Upload(ctx, "20240101000000", writeFile(t, "manifest.json", "{}"))
+	require.NoError(t, err)
+
+	files, err := f.ListFiles(ctx, "20240101000000")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backups/instance/20240101000000/manifest.json", key}, files)
+
+	dest := filepath.Join(t.TempDir(), "out.zip")
+	require.NoError(t, f.Download(ctx, key, dest))
+	data, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, "archive", string(data))
+
+	require.NoError(t, f.Delete(ctx, "20240101000000"))
+	require.NoError(t, f.Delete(ctx, "20240101000000"))
+	keys, err := f.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	require.ErrorIs(t, f.SetStorageClass(ctx, key, "cold"), ErrStorageClassUnsupported)
+}
+
+func TestFTP_List(t *testing.T) {
+	ctx := context.Background()
+	srv := newFakeFTP(t)
+	f := newTestFTP(t, srv)
+
+	keys, err := f.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	for _, ts := range []string{"20240301000000", "20240101000000", "20240201000000"} {
+		_, uErr := f.Upload(ctx, ts, writeFile(t, "manifest.json", "{}"))
+		require.NoError(t, uErr)
+	}
+
+	keys, err = f.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20240101000000", "20240201000000", "20240301000000"}, f.TrimPrefix(keys))
+
+	page, err := f.ListPage(ctx, storage.ListOptions{StartAfter: "20240101000000", Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20240201000000"}, f.TrimPrefix(page.Keys))
+	assert.NotEmpty(t, page.NextToken)
+}
+
+func TestFTP_Stats(t *testing.T) {
+	ctx := context.Background()
+	srv := newFakeFTP(t)
+	f := newTestFTP(t, srv)
+
+	for ts, content := range map[string]string{"20240101000000": "archive", "20240102000000": "newer archive"} {
+		_, uErr := f.Upload(ctx, ts, writeFile(t, "postgres-plain.zip", content))
+		require.NoError(t, uErr)
+	}
+	_, err := f.Upload(ctx, "20240101000000", writeFile(t, "manifest.json", "{}"))
+	require.NoError(t, err)
+
+	usage, err := f.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(22), usage.Bytes)
+	assert.Equal(t, []storage.BackupUsage{
+		{Timestamp: "20240101000000", Bytes: 9, Files: 2},
+		{Timestamp: "20240102000000", Bytes: 13, Files: 1},
+	}, usage.Backups)
+}
+
+func TestFTP_Init(t *testing.T) {
+	f := NewFTPStorage(&config.Config{FTP: config.FTPConfig{Host: "ftp.example.com", TLS: "sometimes"}})
+	require.ErrorIs(t, f.Init(context.Background()), ErrUnknownTLSMode)
+
+	f = NewFTPStorage(&config.Config{FTP: config.FTPConfig{Host: "ftp.example.com", TLS: TLSImplicit}})
+	require.NoError(t, f.Init(context.Background()))
+	assert.Equal(t, "ftp.example.com:990", f.address())
+	assert.Equal(t, "ftp.example.com", f.tls.ServerName)
+}
+
+func TestFTP_LoginFailure(t *testing.T) {
+	srv := newFakeFTP(t)
+	f := newTestFTP(t, srv)
+	f.cfg.FTP.Password = "wrong"
+
+	_, err := f.List(context.Background())
+	require.Error(t, err)
+}
@@ -0,0 +1,463 @@
+// Package gcs provides an implementation of storage interface for Google Cloud Storage.
+//
+// It talks to the GCS JSON API directly, authenticated with Application Default Credentials, so
+// GKE workload identity, the metadata server and workload identity federation work without keys.
+package gcs
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/md5" //nolint:gosec // reason: GCS reports object integrity as MD5
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/httpclient"
+	"github.com/hibare/stashly/internal/storage"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// DefaultEndpoint is the GCS JSON API endpoint.
+const DefaultEndpoint = "https://storage.googleapis.com"
+
+// scope grants read/write access to objects.
+const scope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// maxListResults is the most objects GCS returns per list call.
+const maxListResults = 1000
+
+var (
+	// ErrChecksumMismatch is returned when the MD5 reported by GCS differs from the local one.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+
+	// ErrUnsupportedCredentials is returned for credential files of a type Stashly doesn't accept.
+	ErrUnsupportedCredentials = errors.New("unsupported credentials type")
+)
+
+// APIError is an error response from the GCS API.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("gcs: %d %s", e.StatusCode, e.Message)
+}
+
+// HTTPStatusCode returns the response's status code, used to tell transient errors apart.
+func (e *APIError) HTTPStatusCode() int {
+	return e.StatusCode
+}
+
+// object is the subset of the GCS object resource Stashly uses.
+type object struct {
+	Name    string `json:"name"`
+	MD5Hash string `json:"md5Hash"`
+	Size    string `json:"size,omitempty"`
+}
+
+// listResponse is a page of the objects.list response.
+type listResponse struct {
+	Items         []object `json:"items"`
+	Prefixes      []string `json:"prefixes"`
+	NextPageToken string   `json:"nextPageToken"`
+}
+
+// rewriteResponse is the objects.rewrite response.
+type rewriteResponse struct {
+	Done         bool   `json:"done"`
+	RewriteToken string `json:"rewriteToken"`
+}
+
+// GCS implements the StorageIface for Google Cloud Storage.
+type GCS struct {
+	cfg      *config.Config
+	client   *http.Client
+	endpoint string
+}
+
+// Init prepares the GCS storage by loading credentials.
+func (g *GCS) Init(ctx context.Context) error {
+	// Requests use the configured proxy and TLS settings, token requests included.
+	base, err := httpclient.NewTransport(g.cfg)
+	if err != nil {
+		return err
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: base})
+
+	creds, err := g.credentials(ctx)
+	if err != nil {
+		return err
+	}
+	g.client = &http.Client{Transport: &oauth2.Transport{Source: creds.TokenSource, Base: base}}
+	return nil
+}
+
+// credentialTypes are the credential file types accepted in gcs.credentials-file.
+var credentialTypes = []google.CredentialsType{
+	google.ServiceAccount,
+	google.ExternalAccount,
+	google.ImpersonatedServiceAccount,
+	google.AuthorizedUser,
+}
+
+// credentials loads the configured credentials file, or Application Default Credentials.
+func (g *GCS) credentials(ctx context.Context) (*google.Credentials, error) {
+	if g.cfg.GCS.CredentialsFile == "" {
+		return google.FindDefaultCredentials(ctx, scope)
+	}
+
+	data, err := os.ReadFile(g.cfg.GCS.CredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Type google.CredentialsType `json:"type"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", g.cfg.GCS.CredentialsFile, err)
+	}
+	if !slices.Contains(credentialTypes, file.Type) {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedCredentials, file.Type)
+	}
+	return google.CredentialsFromJSONWithType(ctx, data, file.Type, scope)
+}
+
+// Name returns the name of the storage backend (e.g., "gcs").
+func (g *GCS) Name() string {
+	return fmt.Sprintf("gcs (%s)", g.cfg.GCS.Bucket)
+}
+
+// basePrefix returns the prefix all backups of this instance are stored under, ending in "/".
+func (g *GCS) basePrefix() string {
+	return storage.BuildKey(g.cfg.GCS.Prefix, g.cfg.App.InstanceID)
+}
+
+// objectURL returns the JSON API URL of the object named name.
+func (g *GCS) objectURL(name string) string {
+	return fmt.Sprintf("%s/storage/v1/b/%s/o/%s", g.endpoint, url.PathEscape(g.cfg.GCS.Bucket), url.PathEscape(name))
+}
+
+// do sends req and decodes a JSON response into out, if given.
+func (g *GCS) do(req *http.Request, out any) error {
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return decodeError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// decodeError turns a non-2xx response into an APIError.
+func decodeError(resp *http.Response) error {
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	msg := strings.TrimSpace(string(data))
+	if json.Unmarshal(data, &body) == nil && body.Error.Message != "" {
+		msg = body.Error.Message
+	}
+	if msg == "" {
+		msg = http.StatusText(resp.StatusCode)
+	}
+	return &APIError{StatusCode: resp.StatusCode, Message: msg}
+}
+
+// md5File returns the base64-encoded MD5 digest of a file, as reported by GCS.
+func md5File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	h := md5.New() //nolint:gosec // reason: GCS reports object integrity as MD5
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// Upload uploads a local file into the backup at timestamp and returns the remote key.
+// The MD5 GCS computes for the stored object is checked against the local file.
+func (g *GCS) Upload(ctx context.Context, timestamp, localPath string) (string, error) {
+	key := storage.BuildKey(g.cfg.GCS.Prefix, g.cfg.App.InstanceID, timestamp) + path.Base(localPath)
+
+	checksum, err := md5File(localPath)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	q := url.Values{"uploadType": {"media"}, "name": {key}}
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", g.endpoint, url.PathEscape(g.cfg.GCS.Bucket), q.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, f)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	slog.DebugContext(ctx, "Uploading file to GCS", "file", localPath, "bucket", g.cfg.GCS.Bucket, "key", key, "md5", checksum)
+	var obj object
+	if err := g.do(req, &obj); err != nil {
+		return "", err
+	}
+	if obj.MD5Hash != "" && obj.MD5Hash != checksum {
+		return "", fmt.Errorf("%w for %s: local %s, remote %s", ErrChecksumMismatch, key, checksum, obj.MD5Hash)
+	}
+	return key, nil
+}
+
+// Download fetches the object at key into localPath.
+func (g *GCS) Download(ctx context.Context, key, localPath string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.objectURL(key)+"?alt=media", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return decodeError(resp)
+	}
+
+	f, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// list returns one page of objects.list.
+func (g *GCS) list(ctx context.Context, q url.Values) (*listResponse, error) {
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o?%s", g.endpoint, url.PathEscape(g.cfg.GCS.Bucket), q.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var out listResponse
+	if err := g.do(req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// List returns the backup prefixes under the instance prefix.
+func (g *GCS) List(ctx context.Context) ([]string, error) {
+	var keys []string
+	opts := storage.ListOptions{}
+	for {
+		page, err := g.ListPage(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, page.Keys...)
+		if page.NextToken == "" {
+			return keys, nil
+		}
+		opts.Token = page.NextToken
+	}
+}
+
+// ListPage returns one page of the backups under the instance prefix, narrowed by opts.
+func (g *GCS) ListPage(ctx context.Context, opts storage.ListOptions) (storage.Page, error) {
+	prefix := g.basePrefix()
+
+	q := url.Values{"prefix": {prefix + opts.Prefix}, "delimiter": {"/"}}
+	if opts.StartAfter != "" {
+		// startOffset is inclusive; "0" sorts right after "/", so this skips the StartAfter backup itself.
+		q.Set("startOffset", prefix+opts.StartAfter+"0")
+	}
+	if opts.Limit > 0 {
+		q.Set("maxResults", strconv.Itoa(min(opts.Limit, maxListResults)))
+	}
+	if opts.Token != "" {
+		q.Set("pageToken", opts.Token)
+	}
+
+	out, err := g.list(ctx, q)
+	if err != nil {
+		return storage.Page{}, err
+	}
+
+	page := storage.Page{NextToken: out.NextPageToken}
+	for _, obj := range out.Items {
+		if obj.Name != prefix {
+			page.Keys = append(page.Keys, obj.Name)
+		}
+	}
+	page.Keys = append(page.Keys, out.Prefixes...)
+	return page, nil
+}
+
+// ListFiles returns the keys of all objects stored under the backup at the given timestamp.
+func (g *GCS) ListFiles(ctx context.Context, timestamp string) ([]string, error) {
+	q := url.Values{"prefix": {storage.BuildKey(g.cfg.GCS.Prefix, g.cfg.App.InstanceID, timestamp)}}
+
+	var keys []string
+	for {
+		out, err := g.list(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range out.Items {
+			keys = append(keys, obj.Name)
+		}
+		if out.NextPageToken == "" {
+			return keys, nil
+		}
+		q.Set("pageToken", out.NextPageToken)
+	}
+}
+
+// Stats sums the sizes of the objects stored under the instance prefix per backup.
+func (g *GCS) Stats(ctx context.Context) (storage.Usage, error) {
+	prefix := g.basePrefix()
+	q := url.Values{"prefix": {prefix}}
+
+	sizes := map[string]int64{}
+	for {
+		out, err := g.list(ctx, q)
+		if err != nil {
+			return storage.Usage{}, err
+		}
+		for _, obj := range out.Items {
+			// GCS reports sizes as decimal strings.
+			size, err := strconv.ParseInt(cmp.Or(obj.Size, "0"), 10, 64)
+			if err != nil {
+				return storage.Usage{}, fmt.Errorf("error parsing size of %s: %w", obj.Name, err)
+			}
+			sizes[obj.Name] = size
+		}
+		if out.NextPageToken == "" {
+			return storage.UsageOf(prefix, sizes), nil
+		}
+		q.Set("pageToken", out.NextPageToken)
+	}
+}
+
+// Delete deletes the backup at timestamp, i.e. every object under its prefix, from GCS.
+func (g *GCS) Delete(ctx context.Context, timestamp string) error {
+	keys, err := g.ListFiles(ctx, timestamp)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		req, rErr := http.NewRequestWithContext(ctx, http.MethodDelete, g.objectURL(key), nil)
+		if rErr != nil {
+			return rErr
+		}
+		var apiErr *APIError
+		if err := g.do(req, nil); err != nil && !(errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetStorageClass rewrites the object at key onto itself with the given storage class.
+func (g *GCS) SetStorageClass(ctx context.Context, key, class string) error {
+	body, err := json.Marshal(map[string]string{"storageClass": class})
+	if err != nil {
+		return err
+	}
+
+	u := g.objectURL(key) + "/rewriteTo/b/" + url.PathEscape(g.cfg.GCS.Bucket) + "/o/" + url.PathEscape(key)
+	token := ""
+	for {
+		target := u
+		if token != "" {
+			target += "?rewriteToken=" + url.QueryEscape(token)
+		}
+		req, rErr := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+		if rErr != nil {
+			return rErr
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		var out rewriteResponse
+		if err := g.do(req, &out); err != nil {
+			return err
+		}
+		if out.Done {
+			return nil
+		}
+		token = out.RewriteToken
+	}
+}
+
+// TrimPrefix trims the instance prefix and trailing "/" from keys, leaving backup timestamps.
+func (g *GCS) TrimPrefix(keys []string) []string {
+	prefix := g.basePrefix()
+	trimmed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		trimmed = append(trimmed, strings.TrimSuffix(strings.TrimPrefix(key, prefix), "/"))
+	}
+	return trimmed
+}
+
+// NewGCSStorage creates a new GCS storage instance with the provided configuration.
+func NewGCSStorage(cfg *config.Config) *GCS {
+	endpoint := strings.TrimSuffix(cfg.GCS.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	return &GCS{cfg: cfg, endpoint: endpoint}
+}
+
+func init() {
+	storage.Register("gcs", storage.Registration{
+		New:   func(cfg *config.Config) storage.StorageIface { return NewGCSStorage(cfg) },
+		Retry: func(cfg *config.Config) config.RetryConfig { return cfg.GCS.Retry },
+	})
+}
@@ -0,0 +1,219 @@
+package gcs
+
+import (
+	"crypto/md5" //nolint:gosec // reason: GCS reports object integrity as MD5
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGCS serves the subset of the GCS JSON API the backend uses, for bucket "bucket".
+type fakeGCS struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	classes map[string]string
+	badMD5  bool
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	const objects = "/storage/v1/b/bucket/o"
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/upload"+objects:
+		data, _ := io.ReadAll(r.Body)
+		name := r.URL.Query().Get("name")
+		f.objects[name] = data
+		sum := md5.Sum(data) //nolint:gosec // reason: GCS reports object integrity as MD5
+		hash := base64.StdEncoding.EncodeToString(sum[:])
+		if f.badMD5 {
+			hash = "bogus"
+		}
+		_ = json.NewEncoder(w).Encode(object{Name: name, MD5Hash: hash})
+	case r.Method == http.MethodGet && r.URL.Path == objects:
+		_ = json.NewEncoder(w).Encode(f.list(r))
+	case strings.HasPrefix(r.URL.Path, objects+"/"):
+		name, target, rewrite := strings.Cut(strings.TrimPrefix(r.URL.EscapedPath(), objects+"/"), "/rewriteTo/")
+		name = unescape(name)
+		data, ok := f.objects[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"code":404,"message":"No such object"}}`))
+			return
+		}
+		switch {
+		case rewrite && r.Method == http.MethodPost:
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			f.classes[unescape(strings.TrimPrefix(target, "b/bucket/o/"))] = body["storageClass"]
+			_ = json.NewEncoder(w).Encode(rewriteResponse{Done: true})
+		case r.Method == http.MethodGet:
+			_, _ = w.Write(data)
+		case r.Method == http.MethodDelete:
+			delete(f.objects, name)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func unescape(s string) string {
+	return strings.ReplaceAll(s, "%2F", "/")
+}
+
+// list implements objects.list with prefix, delimiter, startOffset, maxResults and pageToken.
+func (f *fakeGCS) list(r *http.Request) listResponse {
+	q := r.URL.Query()
+	prefix, delim, start := q.Get("prefix"), q.Get("delimiter"), q.Get("startOffset")
+
+	seen := map[string]bool{}
+	var entries []string
+	for name := range f.objects {
+		if !strings.HasPrefix(name, prefix) || name < start {
+			continue
+		}
+		if delim != "" {
+			if i := strings.Index(name[len(prefix):], delim); i >= 0 {
+				p := name[:len(prefix)+i+1]
+				if !seen[p] {
+					seen[p] = true
+					entries = append(entries, p)
+				}
+				continue
+			}
+		}
+		entries = append(entries, name)
+	}
+	sort.Strings(entries)
+
+	offset, _ := strconv.Atoi(q.Get("pageToken"))
+	limit := len(entries)
+	if m, err := strconv.Atoi(q.Get("maxResults")); err == nil {
+		limit = m
+	}
+	end := min(offset+limit, len(entries))
+
+	out := listResponse{}
+	for _, e := range entries[offset:end] {
+		if seen[e] {
+			out.Prefixes = append(out.Prefixes, e)
+		} else {
+			out.Items = append(out.Items, object{Name: e, Size: strconv.Itoa(len(f.objects[e]))})
+		}
+	}
+	if end < len(entries) {
+		out.NextPageToken = strconv.Itoa(end)
+	}
+	return out
+}
+
+func newTestGCS(t *testing.T) (*GCS, *fakeGCS) {
+	t.Helper()
+	fake := &fakeGCS{objects: map[string][]byte{}, classes: map[string]string{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	cfg := &config.Config{App: config.AppConfig{InstanceID: "instance"}, GCS: config.GCSConfig{Bucket: "bucket", Prefix: "prefix", Endpoint: srv.URL}}
+	g := NewGCSStorage(cfg)
+	g.client = srv.Client()
+	return g, fake
+}
+
+func TestGCS_UploadDownloadDelete(t *testing.T) {
+	g, fake := newTestGCS(t)
+	ctx := t.Context()
+
+	dir := t.TempDir()
+	local := filepath.Join(dir, "postgres-plain.zip")
+	require.NoError(t, os.WriteFile(local, []byte("archive"), 0600))
+
+	key, err := g.Upload(ctx, "20250101000000", local)
+	require.NoError(t, err)
+	assert.Equal(t, "prefix/instance/20250101000000/postgres-plain.zip", key)
+
+	files, err := g.ListFiles(ctx, "20250101000000")
+	require.NoError(t, err)
+	assert.Equal(t, []string{key}, files)
+
+	out := filepath.Join(dir, "download.zip")
+	require.NoError(t, g.Download(ctx, key, out))
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "archive", string(data))
+
+	require.NoError(t, g.SetStorageClass(ctx, key, "COLDLINE"))
+	assert.Equal(t, "COLDLINE", fake.classes[key])
+
+	require.NoError(t, g.Delete(ctx, "20250101000000"))
+	assert.Empty(t, fake.objects)
+
+	var apiErr *APIError
+	require.ErrorAs(t, g.Download(ctx, key, out), &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
+
+func TestGCS_Upload_ChecksumMismatch(t *testing.T) {
+	g, fake := newTestGCS(t)
+	fake.badMD5 = true
+
+	local := filepath.Join(t.TempDir(), "postgres-plain.zip")
+	require.NoError(t, os.WriteFile(local, []byte("archive"), 0600))
+
+	_, err := g.Upload(t.Context(), "20250101000000", local)
+	require.ErrorIs(t, err, ErrChecksumMismatch)
+}
+
+func TestGCS_List(t *testing.T) {
+	g, fake := newTestGCS(t)
+	for _, ts := range []string{"20250101000000", "20250102000000", "20250201000000"} {
+		fake.objects["prefix/instance/"+ts+"/postgres-plain.zip"] = []byte("x")
+		fake.objects["prefix/instance/"+ts+"/manifest.json"] = []byte("{}")
+	}
+
+	keys, err := g.List(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20250101000000", "20250102000000", "20250201000000"}, g.TrimPrefix(keys))
+
+	page, err := g.ListPage(t.Context(), storage.ListOptions{Prefix: "2025", StartAfter: "20250101000000", Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prefix/instance/20250102000000/"}, page.Keys)
+	require.NotEmpty(t, page.NextToken)
+
+	page, err = g.ListPage(t.Context(), storage.ListOptions{Prefix: "2025", StartAfter: "20250101000000", Limit: 1, Token: page.NextToken})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prefix/instance/20250201000000/"}, page.Keys)
+	assert.Empty(t, page.NextToken)
+}
+
+func TestGCS_Stats(t *testing.T) {
+	g, fake := newTestGCS(t)
+	fake.objects["prefix/instance/20250101000000/postgres-plain.zip"] = []byte("archive")
+	fake.objects["prefix/instance/20250101000000/manifest.json"] = []byte("{}")
+	fake.objects["prefix/instance/20250102000000/postgres-plain.zip"] = []byte("newer archive")
+	fake.objects["prefix/other/20250101000000/postgres-plain.zip"] = []byte("other instance")
+
+	usage, err := g.Stats(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, int64(22), usage.Bytes)
+	assert.Equal(t, 2, usage.Count)
+	assert.Equal(t, []storage.BackupUsage{
+		{Timestamp: "20250101000000", Bytes: 9, Files: 2},
+		{Timestamp: "20250102000000", Bytes: 13, Files: 1},
+	}, usage.Backups)
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hibare/stashly/internal/metrics"
+)
+
+// Instrumented wraps a StorageIface and records latency and error metrics for its calls.
+type Instrumented struct {
+	StorageIface
+}
+
+func (i *Instrumented) observe(operation string, start time.Time, err error) {
+	backend := i.Name()
+	metrics.StorageOperationDuration.WithLabelValues(backend, operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.StorageOperationErrors.WithLabelValues(backend, operation).Inc()
+	}
+}
+
+// Upload uploads a local file and records its duration and outcome.
+func (i *Instrumented) Upload(ctx context.Context, timestamp, localPath string) (string, error) {
+	start := time.Now()
+	key, err := i.StorageIface.Upload(ctx, timestamp, localPath)
+	i.observe("upload", start, err)
+	return key, err
+}
+
+// Download downloads a key and records its duration and outcome.
+func (i *Instrumented) Download(ctx context.Context, key, localPath string) error {
+	start := time.Now()
+	err := i.StorageIface.Download(ctx, key, localPath)
+	i.observe("download", start, err)
+	return err
+}
+
+// List lists keys and records its duration and outcome.
+func (i *Instrumented) List(ctx context.Context) ([]string, error) {
+	start := time.Now()
+	keys, err := i.StorageIface.List(ctx)
+	i.observe("list", start, err)
+	return keys, err
+}
+
+// ListPage lists a page of keys and records its duration and outcome.
+func (i *Instrumented) ListPage(ctx context.Context, opts ListOptions) (Page, error) {
+	start := time.Now()
+	page, err := i.StorageIface.ListPage(ctx, opts)
+	i.observe("list", start, err)
+	return page, err
+}
+
+// Stats sums the space backups take up and records its duration and outcome.
+func (i *Instrumented) Stats(ctx context.Context) (Usage, error) {
+	start := time.Now()
+	usage, err := i.StorageIface.Stats(ctx)
+	i.observe("list", start, err)
+	return usage, err
+}
+
+// Delete deletes a key and records its duration and outcome.
+func (i *Instrumented) Delete(ctx context.Context, key string) error {
+	start := time.Now()
+	err := i.StorageIface.Delete(ctx, key)
+	i.observe("delete", start, err)
+	return err
+}
+
+// SetStorageClass changes a key's storage class and records its duration and outcome.
+func (i *Instrumented) SetStorageClass(ctx context.Context, key, class string) error {
+	start := time.Now()
+	err := i.StorageIface.SetStorageClass(ctx, key, class)
+	i.observe("set-storage-class", start, err)
+	return err
+}
+
+// Copy copies a key server-side and records its duration and outcome.
+func (i *Instrumented) Copy(ctx context.Context, key, timestamp string) (string, error) {
+	start := time.Now()
+	newKey, err := Copy(ctx, i.StorageIface, key, timestamp)
+	if errors.Is(err, ErrCopyUnsupported) {
+		return "", err
+	}
+	i.observe("copy", start, err)
+	return newKey, err
+}
+
+// NewInstrumented wraps store with metrics instrumentation.
+func NewInstrumented(store StorageIface) StorageIface {
+	return &Instrumented{StorageIface: store}
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumented_RecordsErrors(t *testing.T) {
+	mockStore := NewMockStorageIface(t)
+	mockStore.On("Name").Return("test-backend")
+	mockStore.On("List").Return([]string{"a"}, nil).Once()
+	mockStore.On("Delete", "a").Return(errors.New("throttled")).Once()
+
+	store := NewInstrumented(mockStore)
+
+	keys, err := store.List(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, keys)
+
+	err = store.Delete(context.Background(), "a")
+	require.Error(t, err)
+
+	assert.InDelta(t, 0, testutil.ToFloat64(metrics.StorageOperationErrors.WithLabelValues("test-backend", "list")), 0)
+	assert.InDelta(t, 1, testutil.ToFloat64(metrics.StorageOperationErrors.WithLabelValues("test-backend", "delete")), 0)
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.StorageOperationDuration))
+}
+
+func TestInstrumented_CopyUnsupported(t *testing.T) {
+	// The mock backend has no server-side copy; the wrappers must not hide that.
+	retrying, err := NewRetrying(NewMockStorageIface(t), config.RetryConfig{MaxAttempts: 3})
+	require.NoError(t, err)
+
+	_, err = Copy(context.Background(), NewInstrumented(retrying), "old/20250101000000/backup.zip", "20250101000000")
+	require.ErrorIs(t, err, ErrCopyUnsupported)
+}
@@ -0,0 +1,292 @@
+// Package rclone provides an implementation of storage interface backed by an rclone remote, which
+// makes every provider rclone supports available as backup storage.
+//
+// Like the dump engines with their client tools, it shells out to the rclone binary: copyto to move
+// files, lsjson to list them and deletefile and rmdir to delete them. Backups follow the same
+// <prefix>/<instance-id>/<timestamp>/ layout as the other backends, below the configured remote.
+package rclone
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	osexec "os/exec"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/storage"
+)
+
+// binary is the rclone executable, looked up in PATH.
+const binary = "rclone"
+
+// Exit codes of rclone.
+const (
+	// exitDirNotFound is the exit code rclone uses when a directory doesn't exist.
+	exitDirNotFound = 3
+
+	// exitTemporary is the exit code rclone uses for errors that more retries might fix.
+	exitTemporary = 5
+)
+
+var (
+	// ErrNoRemote is returned when rclone.remote is not set.
+	ErrNoRemote = errors.New("rclone.remote is not set")
+
+	// ErrNotInstalled is returned when the rclone binary can't be found in PATH.
+	ErrNotInstalled = errors.New("rclone is not installed")
+)
+
+// object is the subset of an lsjson entry Stashly uses.
+type object struct {
+	Path  string `json:"Path"`
+	Name  string `json:"Name"`
+	Size  int64  `json:"Size"`
+	IsDir bool   `json:"IsDir"`
+}
+
+// exitCoder is implemented by errors of commands that ran and exited non-zero.
+type exitCoder interface {
+	ExitCode() int
+}
+
+// Rclone implements the StorageIface by running rclone against a configured remote.
+type Rclone struct {
+	cfg  *config.Config
+	exec exec.ExecIface
+}
+
+// Init checks the remote is configured and rclone is installed.
+func (r *Rclone) Init(_ context.Context) error {
+	if r.cfg.Rclone.Remote == "" {
+		return ErrNoRemote
+	}
+	if _, err := r.exec.LookPath(binary); err != nil {
+		return fmt.Errorf("%w: %w", ErrNotInstalled, err)
+	}
+	return nil
+}
+
+// Name returns the name of the storage backend (e.g., "rclone (b2)").
+func (r *Rclone) Name() string {
+	if name, _, ok := strings.Cut(r.cfg.Rclone.Remote, ":"); ok && name != "" {
+		return fmt.Sprintf("rclone (%s)", name)
+	}
+	return binary
+}
+
+// basePrefix returns the prefix all backups of this instance are stored under, ending in "/".
+func (r *Rclone) basePrefix() string {
+	return storage.BuildKey(r.cfg.Rclone.Prefix, r.cfg.App.InstanceID)
+}
+
+// remotePath returns the rclone path of key below the configured remote.
+func (r *Rclone) remotePath(key string) string {
+	remote := r.cfg.Rclone.Remote
+	key = strings.TrimSuffix(key, "/")
+	if strings.HasSuffix(remote, ":") {
+		return remote + key
+	}
+	return strings.TrimSuffix(remote, "/") + "/" + key
+}
+
+// run runs an rclone command with the configured flags and returns its standard output. Errors carry
+// whatever rclone printed to standard error.
+func (r *Rclone) run(ctx context.Context, command string, args ...string) ([]byte, error) {
+	argv := []string{command}
+	if r.cfg.Rclone.ConfigFile != "" {
+		argv = append(argv, "--config", r.cfg.Rclone.ConfigFile)
+	}
+	argv = append(argv, r.cfg.Rclone.Flags...)
+	argv = append(argv, args...)
+
+	out, err := r.exec.Command(ctx, binary, argv...).Output()
+	if err != nil {
+		var exitErr *osexec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("rclone %s: %w: %s", command, err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("rclone %s: %w", command, err)
+	}
+	return out, nil
+}
+
+// ErrorClass classifies rclone's temporary-error exit as a server error; rclone has already retried
+// the calls it made itself.
+func (r *Rclone) ErrorClass(err error) string {
+	var coder exitCoder
+	if errors.As(err, &coder) {
+		if coder.ExitCode() == exitTemporary {
+			return storage.RetryServer
+		}
+		return ""
+	}
+	return storage.ErrorClass(err)
+}
+
+// notFound reports whether err is rclone's exit for a missing directory.
+func notFound(err error) bool {
+	var coder exitCoder
+	return errors.As(err, &coder) && coder.ExitCode() == exitDirNotFound
+}
+
+// list returns the entries directly below dir. A missing directory has none.
+func (r *Rclone) list(ctx context.Context, dir string, dirs bool) ([]object, error) {
+	filter := "--files-only"
+	if dirs {
+		filter = "--dirs-only"
+	}
+	out, err := r.run(ctx, "lsjson", filter, r.remotePath(dir))
+	if err != nil {
+		if notFound(err) {
+			return []object{}, nil
+		}
+		return nil, err
+	}
+
+	var objects []object
+	if err := json.Unmarshal(out, &objects); err != nil {
+		return nil, fmt.Errorf("error parsing rclone lsjson output for %s: %w", dir, err)
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	return objects, nil
+}
+
+// Upload uploads a local file into the backup at timestamp and returns the remote key.
+func (r *Rclone) Upload(ctx context.Context, timestamp, localPath string) (string, error) {
+	key := storage.BuildKey(r.cfg.Rclone.Prefix, r.cfg.App.InstanceID, timestamp) + path.Base(localPath)
+
+	slog.DebugContext(ctx, "Uploading file with rclone", "file", localPath, "key", key)
+	if _, err := r.run(ctx, "copyto", localPath, r.remotePath(key)); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// Download fetches the file at key into localPath.
+func (r *Rclone) Download(ctx context.Context, key, localPath string) error {
+	_, err := r.run(ctx, "copyto", r.remotePath(key), localPath)
+	return err
+}
+
+// List returns the backup directories under the instance prefix.
+func (r *Rclone) List(ctx context.Context) ([]string, error) {
+	return r.backups(ctx)
+}
+
+// backups returns the keys of the backup directories under the instance prefix, sorted.
+func (r *Rclone) backups(ctx context.Context) ([]string, error) {
+	base := r.basePrefix()
+	objects, err := r.list(ctx, base, true)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(objects))
+	for _, o := range objects {
+		keys = append(keys, base+o.Name+"/")
+	}
+	return keys, nil
+}
+
+// ListPage returns one page of the backups under the instance prefix, narrowed by opts. rclone
+// can't filter or page listings, so the whole directory is listed and the page cut from it.
+func (r *Rclone) ListPage(ctx context.Context, opts storage.ListOptions) (storage.Page, error) {
+	keys, err := r.backups(ctx)
+	if err != nil {
+		return storage.Page{}, err
+	}
+
+	return storage.PageKeys(keys, r.basePrefix(), opts), nil
+}
+
+// ListFiles returns the keys of all files stored under the backup at the given timestamp.
+func (r *Rclone) ListFiles(ctx context.Context, timestamp string) ([]string, error) {
+	dir := storage.BuildKey(r.cfg.Rclone.Prefix, r.cfg.App.InstanceID, timestamp)
+	objects, err := r.list(ctx, dir, false)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(objects))
+	for _, o := range objects {
+		keys = append(keys, dir+o.Name)
+	}
+	return keys, nil
+}
+
+// Stats sums the sizes of the files under the instance prefix per backup, listing them recursively
+// with one lsjson call.
+func (r *Rclone) Stats(ctx context.Context) (storage.Usage, error) {
+	base := r.basePrefix()
+	out, err := r.run(ctx, "lsjson", "--files-only", "--recursive", r.remotePath(base))
+	if err != nil {
+		if notFound(err) {
+			return storage.UsageOf(base, nil), nil
+		}
+		return storage.Usage{}, err
+	}
+
+	var objects []object
+	if err := json.Unmarshal(out, &objects); err != nil {
+		return storage.Usage{}, fmt.Errorf("error parsing rclone lsjson output for %s: %w", base, err)
+	}
+	sizes := make(map[string]int64, len(objects))
+	for _, o := range objects {
+		sizes[base+o.Path] = o.Size
+	}
+	return storage.UsageOf(base, sizes), nil
+}
+
+// Delete deletes the files of the backup at timestamp, then its directory. Remotes without real
+// directories, like object stores, have none left to remove.
+func (r *Rclone) Delete(ctx context.Context, timestamp string) error {
+	keys, err := r.ListFiles(ctx, timestamp)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if _, err := r.run(ctx, "deletefile", r.remotePath(key)); err != nil {
+			return err
+		}
+	}
+
+	dir := storage.BuildKey(r.cfg.Rclone.Prefix, r.cfg.App.InstanceID, timestamp)
+	if _, err := r.run(ctx, "rmdir", r.remotePath(dir)); err != nil && !notFound(err) {
+		return err
+	}
+	return nil
+}
+
+// SetStorageClass moves the object at key to class with rclone settier. Only remotes with storage
+// tiers, such as S3, GCS and Azure Blob, support it; rclone fails for the others.
+func (r *Rclone) SetStorageClass(ctx context.Context, key, class string) error {
+	_, err := r.run(ctx, "settier", class, r.remotePath(key))
+	return err
+}
+
+// TrimPrefix trims the instance prefix and trailing "/" from keys, leaving backup timestamps.
+func (r *Rclone) TrimPrefix(keys []string) []string {
+	prefix := r.basePrefix()
+	trimmed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		trimmed = append(trimmed, strings.TrimSuffix(strings.TrimPrefix(key, prefix), "/"))
+	}
+	return trimmed
+}
+
+// NewRcloneStorage creates a new rclone storage instance with the provided configuration, running
+// rclone through e.
+func NewRcloneStorage(cfg *config.Config, e exec.ExecIface) *Rclone {
+	return &Rclone{cfg: cfg, exec: e}
+}
+
+func init() {
+	storage.Register("rclone", storage.Registration{
+		New:   func(cfg *config.Config) storage.StorageIface { return NewRcloneStorage(cfg, exec.NewExec()) },
+		Retry: func(cfg *config.Config) config.RetryConfig { return cfg.Rclone.Retry },
+	})
+}
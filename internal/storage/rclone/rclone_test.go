@@ -0,0 +1,282 @@
+package rclone
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hibare/GoCommon/v2/pkg/os/exec"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRemote = "remote:bucket"
+
+// exitError is a command exiting with code.
+type exitError int
+
+func (e exitError) Error() string { return fmt.Sprintf("exit status %d", int(e)) }
+func (e exitError) ExitCode() int { return int(e) }
+
+// fakeRclone runs the rclone commands the backend uses against a local directory standing in for
+// testRemote, and records every command line.
+type fakeRclone struct {
+	root  string
+	calls [][]string
+	tiers map[string]string
+}
+
+func newFakeRclone(t *testing.T) *fakeRclone {
+	t.Helper()
+	return &fakeRclone{root: t.TempDir(), tiers: map[string]string{}}
+}
+
+func (f *fakeRclone) Command(_ context.Context, name string, args ...string) exec.CmdIface {
+	f.calls = append(f.calls, append([]string{name}, args...))
+	return &fakeCmd{f: f, args: args}
+}
+
+func (f *fakeRclone) LookPath(file string) (string, error) {
+	return "/usr/bin/" + file, nil
+}
+
+// local maps a path on testRemote to the local directory, leaving local paths alone.
+func (f *fakeRclone) local(p string) string {
+	if rest, ok := strings.CutPrefix(p, testRemote); ok {
+		return filepath.Join(f.root, filepath.FromSlash(rest))
+	}
+	return p
+}
+
+func (f *fakeRclone) run(args []string) ([]byte, error) {
+	var positional []string
+	for i := 1; i < len(args); i++ {
+		switch {
+		case args[i] == "--config":
+			i++
+		case !strings.HasPrefix(args[i], "--"):
+			positional = append(positional, args[i])
+		}
+	}
+
+	switch args[0] {
+	case "copyto":
+		data, err := os.ReadFile(f.local(positional[0]))
+		if err != nil {
+			return nil, err
+		}
+		dst := f.local(positional[1])
+		if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+			return nil, err
+		}
+		return nil, os.WriteFile(dst, data, 0o600)
+	case "lsjson":
+		if slices.Contains(args, "--recursive") {
+			return f.lsjsonRecursive(positional[0])
+		}
+		entries, err := os.ReadDir(f.local(positional[0]))
+		if os.IsNotExist(err) {
+			return nil, exitError(exitDirNotFound)
+		} else if err != nil {
+			return nil, err
+		}
+		objects := []object{}
+		dirsOnly := slices.Contains(args, "--dirs-only")
+		for _, e := range entries {
+			if e.IsDir() == dirsOnly {
+				objects = append(objects, object{Name: e.Name(), IsDir: e.IsDir()})
+			}
+		}
+		return json.Marshal(objects)
+	case "deletefile":
+		return nil, os.Remove(f.local(positional[0]))
+	case "rmdir":
+		if err := os.Remove(f.local(positional[0])); os.IsNotExist(err) {
+			return nil, exitError(exitDirNotFound)
+		} else if err != nil {
+			return nil, err
+		}
+		return nil, nil
+	case "settier":
+		f.tiers[positional[1]] = positional[0]
+		return nil, nil
+	default:
+		return nil, exitError(1)
+	}
+}
+
+// lsjsonRecursive lists the files below dir with their paths relative to it and sizes.
+func (f *fakeRclone) lsjsonRecursive(dir string) ([]byte, error) {
+	root := f.local(dir)
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil, exitError(exitDirNotFound)
+	}
+	objects := []object{}
+	err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, object{Path: filepath.ToSlash(rel), Name: d.Name(), Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(objects)
+}
+
+type fakeCmd struct {
+	f    *fakeRclone
+	args []string
+}
+
+func (c *fakeCmd) WithEnv([]string) exec.CmdIface    { return c }
+func (c *fakeCmd) WithDir(string) exec.CmdIface      { return c }
+func (c *fakeCmd) WithStdout(*os.File) exec.CmdIface { return c }
+func (c *fakeCmd) WithStderr(*os.File) exec.CmdIface { return c }
+func (c *fakeCmd) Run() error                        { _, err := c.f.run(c.args); return err }
+func (c *fakeCmd) Output() ([]byte, error)           { return c.f.run(c.args) }
+func (c *fakeCmd) CombinedOutput() ([]byte, error)   { return c.f.run(c.args) }
+
+func newTestRclone(t *testing.T) (*Rclone, *fakeRclone) {
+	t.Helper()
+	f := newFakeRclone(t)
+	cfg := &config.Config{
+		App:    config.AppConfig{InstanceID: "instance"},
+		Rclone: config.RcloneConfig{Remote: testRemote, Prefix: "backups", ConfigFile: "/etc/rclone.conf", Flags: []string{"--fast-list"}},
+	}
+	r := NewRcloneStorage(cfg, f)
+	require.NoError(t, r.Init(context.Background()))
+	return r, f
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(p, []byte(content), 0o600))
+	return p
+}
+
+func TestRclone_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	r, f := newTestRclone(t)
+
+	keys, err := r.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	key, err := r.Upload(ctx, "20240101000000", writeFile(t, "dump.zip", "archive"))
+	require.NoError(t, err)
+	assert.Equal(t, "backups/instance/20240101000000/dump.zip", key)
+	call := f.calls[len(f.calls)-1]
+	assert.Equal(t, []string{"rclone", "copyto", "--config", "/etc/rclone.conf", "--fast-list"}, call[:5])
+	assert.Equal(t, "remote:bucket/backups/instance/20240101000000/dump.zip", call[len(call)-1])
+
+	_, err = r.Upload(ctx, "20240101000000", writeFile(t, "manifest.json", "{}"))
+	require.NoError(t, err)
+	_, err = r.Upload(ctx, "20240102000000", writeFile(t, "dump.zip", "newer"))
+	require.NoError(t, err)
+
+	keys, err = r.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backups/instance/20240101000000/", "backups/instance/20240102000000/"}, keys)
+	assert.Equal(t, []string{"20240101000000", "20240102000000"}, r.TrimPrefix(keys))
+
+	page, err := r.ListPage(ctx, storage.ListOptions{Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backups/instance/20240101000000/"}, page.Keys)
+	assert.Equal(t, "backups/instance/20240101000000/", page.NextToken)
+
+	files, err := r.ListFiles(ctx, "20240101000000")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backups/instance/20240101000000/dump.zip", "backups/instance/20240101000000/manifest.json"}, files)
+
+	local := filepath.Join(t.TempDir(), "dump.zip")
+	require.NoError(t, r.Download(ctx, key, local))
+	data, err := os.ReadFile(local)
+	require.NoError(t, err)
+	assert.Equal(t, "archive", string(data))
+
+	require.NoError(t, r.SetStorageClass(ctx, key, "GLACIER"))
+	assert.Equal(t, "GLACIER", f.tiers["remote:bucket/backups/instance/20240101000000/dump.zip"])
+
+	require.NoError(t, r.Delete(ctx, "20240101000000"))
+	keys, err = r.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backups/instance/20240102000000/"}, keys)
+
+	// Deleting a missing backup is not an error.
+	require.NoError(t, r.Delete(ctx, "20240101000000"))
+}
+
+func TestRclone_Stats(t *testing.T) {
+	ctx := context.Background()
+	r, f := newTestRclone(t)
+
+	usage, err := r.Stats(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, usage.Count)
+
+	_, err = r.Upload(ctx, "20240101000000", writeFile(t, "dump.zip", "archive"))
+	require.NoError(t, err)
+	_, err = r.Upload(ctx, "20240101000000", writeFile(t, "manifest.json", "{}"))
+	require.NoError(t, err)
+	_, err = r.Upload(ctx, "20240102000000", writeFile(t, "dump.zip", "newer archive"))
+	require.NoError(t, err)
+
+	usage, err = r.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"lsjson", "--config", "/etc/rclone.conf", "--fast-list", "--files-only", "--recursive",
+		"remote:bucket/backups/instance"}, f.calls[len(f.calls)-1][1:])
+	assert.Equal(t, int64(22), usage.Bytes)
+	assert.Equal(t, []storage.BackupUsage{
+		{Timestamp: "20240101000000", Bytes: 9, Files: 2},
+		{Timestamp: "20240102000000", Bytes: 13, Files: 1},
+	}, usage.Backups)
+}
+
+func TestRclone_CommandError(t *testing.T) {
+	r, _ := newTestRclone(t)
+	err := r.Download(context.Background(), "backups/instance/20240101000000/missing.zip", filepath.Join(t.TempDir(), "x"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rclone copyto")
+}
+
+func TestRclone_Init(t *testing.T) {
+	r := NewRcloneStorage(&config.Config{}, newFakeRclone(t))
+	require.ErrorIs(t, r.Init(context.Background()), ErrNoRemote)
+
+	m := exec.NewMockExecIface(t)
+	m.On("LookPath", "rclone").Return("", errors.New("not found"))
+	r = NewRcloneStorage(&config.Config{Rclone: config.RcloneConfig{Remote: testRemote}}, m)
+	require.ErrorIs(t, r.Init(context.Background()), ErrNotInstalled)
+}
+
+func TestRclone_Name(t *testing.T) {
+	assert.Equal(t, "rclone (b2)", NewRcloneStorage(&config.Config{Rclone: config.RcloneConfig{Remote: "b2:bucket"}}, nil).Name())
+	assert.Equal(t, "rclone", NewRcloneStorage(&config.Config{Rclone: config.RcloneConfig{Remote: "/mnt/backups"}}, nil).Name())
+}
+
+func TestRclone_RemotePath(t *testing.T) {
+	r := NewRcloneStorage(&config.Config{Rclone: config.RcloneConfig{Remote: "drive:"}}, nil)
+	assert.Equal(t, "drive:backups/instance", r.remotePath("backups/instance/"))
+
+	r = NewRcloneStorage(&config.Config{Rclone: config.RcloneConfig{Remote: "b2:bucket/stashly/"}}, nil)
+	assert.Equal(t, "b2:bucket/stashly/backups/instance/x.zip", r.remotePath("backups/instance/x.zip"))
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/constants"
+)
+
+// ErrUnknownBackend is returned when storage.backend or storage.secondary names no registered backend.
+var ErrUnknownBackend = errors.New("unknown storage backend")
+
+// Registration describes a storage backend to Register.
+type Registration struct {
+	// New creates the backend from the config, not yet initialised.
+	New func(cfg *config.Config) StorageIface
+
+	// Retry returns the backend's retry policy from the config.
+	Retry func(cfg *config.Config) config.RetryConfig
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Registration{}
+)
+
+// Register makes a backend available under name, the value storage.backend selects it with. Backends
+// register themselves from an init function. Registering a name twice panics.
+func Register(name string, r Registration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("storage: backend %q registered twice", name))
+	}
+	registry[name] = r
+}
+
+// Backends returns the names of the registered backends, sorted.
+func Backends() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// New creates the backend registered as name, or the default backend for an empty name. Its calls
+// are retried per its retry policy and instrumented. It isn't initialised yet.
+func New(cfg *config.Config, name string) (StorageIface, error) {
+	name = cmp.Or(name, constants.DefaultStorageBackend)
+	registryMu.RLock()
+	r, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q (available: %v)", ErrUnknownBackend, name, Backends())
+	}
+
+	retrying, err := NewRetrying(r.New(cfg), r.Retry(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("%s.retry: %w", name, err)
+	}
+	return NewInstrumented(retrying), nil
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	mock := NewMockStorageIface(t)
+	Register("registry-test", Registration{
+		New:   func(*config.Config) StorageIface { return mock },
+		Retry: func(*config.Config) config.RetryConfig { return config.RetryConfig{MaxAttempts: 1} },
+	})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "registry-test")
+		registryMu.Unlock()
+	})
+
+	assert.Contains(t, Backends(), "registry-test")
+	store, err := New(&config.Config{}, "registry-test")
+	require.NoError(t, err)
+	assert.IsType(t, &Instrumented{}, store)
+
+	assert.Panics(t, func() { Register("registry-test", Registration{}) })
+
+	_, err = New(&config.Config{}, "tape")
+	require.ErrorIs(t, err, ErrUnknownBackend)
+	assert.Contains(t, err.Error(), "registry-test")
+
+	Register("registry-bad-retry", Registration{
+		New:   func(*config.Config) StorageIface { return mock },
+		Retry: func(*config.Config) config.RetryConfig { return config.RetryConfig{RetryOn: []string{"weather"}} },
+	})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "registry-bad-retry")
+		registryMu.Unlock()
+	})
+	_, err = New(&config.Config{}, "registry-bad-retry")
+	require.ErrorIs(t, err, ErrUnknownRetryClass)
+	assert.Contains(t, err.Error(), "registry-bad-retry.retry")
+}
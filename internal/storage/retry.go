@@ -0,0 +1,266 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/metrics"
+)
+
+// Classes of transient errors, see config.RetryConfig.RetryOn.
+const (
+	// RetryThrottling covers requests rejected for exceeding the backend's rate limits, e.g. HTTP 429
+	// or S3's SlowDown.
+	RetryThrottling = "throttling"
+
+	// RetryServer covers failures on the backend's side, e.g. HTTP 5xx or FTP 4xx replies.
+	RetryServer = "server"
+
+	// RetryNetwork covers connections that couldn't be made, were reset or timed out.
+	RetryNetwork = "network"
+)
+
+// RetryClasses lists the error classes that can be retried.
+var RetryClasses = []string{RetryThrottling, RetryServer, RetryNetwork}
+
+// ErrUnknownRetryClass is returned when a retry policy names an error class that doesn't exist.
+var ErrUnknownRetryClass = errors.New("unknown retry class")
+
+// ClassifierIface is implemented by backends that recognise the transient errors of their SDKs.
+// revive:disable-next-line exported
+type ClassifierIface interface {
+	// ErrorClass returns the class of a transient error, or "" if retrying err won't help.
+	ErrorClass(err error) string
+}
+
+// StatusClass returns the class of an HTTP status code, or "" if it isn't transient.
+func StatusClass(code int) string {
+	switch {
+	case code == http.StatusTooManyRequests:
+		return RetryThrottling
+	case code == http.StatusRequestTimeout:
+		return RetryNetwork
+	case code >= 500 && code != http.StatusNotImplemented && code != http.StatusHTTPVersionNotSupported:
+		return RetryServer
+	default:
+		return ""
+	}
+}
+
+// ErrorClass returns the class of the transient errors common to backends: errors carrying an HTTP
+// status through an HTTPStatusCode method, FTP 4xx replies and network failures. It returns "" for
+// anything else.
+func ErrorClass(err error) string {
+	var status interface{ HTTPStatusCode() int }
+	if errors.As(err, &status) {
+		return StatusClass(status.HTTPStatusCode())
+	}
+
+	var reply *textproto.Error
+	if errors.As(err, &reply) {
+		if reply.Code >= 400 && reply.Code < 500 {
+			return RetryServer
+		}
+		return ""
+	}
+
+	var opErr *net.OpError
+	var netErr net.Error
+	var urlErr *url.Error
+	switch {
+	case errors.As(err, &opErr),
+		errors.As(err, &netErr) && netErr.Timeout(),
+		errors.As(err, &urlErr) && errors.Is(urlErr.Err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.EPIPE):
+		return RetryNetwork
+	}
+	return ""
+}
+
+type noRetriesKey struct{}
+
+// WithoutRetries returns a copy of ctx under which Retrying makes a single attempt, for callers such as
+// health probes that would rather fail fast than wait out the backoff.
+func WithoutRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetriesKey{}, true)
+}
+
+// Retrying wraps a StorageIface and retries calls that fail with transient errors, waiting longer
+// before each attempt. Init isn't retried.
+type Retrying struct {
+	StorageIface
+
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	retryOn    []string
+	classify   func(error) string
+}
+
+// do runs call until it succeeds, fails with an error its policy doesn't retry or runs out of attempts.
+func (r *Retrying) do(ctx context.Context, operation string, call func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || attempt >= r.attempts || ctx.Err() != nil || ctx.Value(noRetriesKey{}) != nil {
+			return err
+		}
+		class := r.classify(err)
+		if !slices.Contains(r.retryOn, class) {
+			return err
+		}
+
+		wait := r.wait(attempt)
+		slog.WarnContext(ctx, "Storage call failed, retrying", "storage", r.Name(), "operation", operation,
+			"class", class, "attempt", attempt, "retry_in", wait, "error", err)
+		metrics.StorageRetries.WithLabelValues(r.Name(), operation, class).Inc()
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// wait returns the wait after attempt: the backoff doubled for each earlier attempt, capped at the
+// maximum, less a random amount of up to half of it.
+func (r *Retrying) wait(attempt int) time.Duration {
+	d := r.backoff
+	for i := 1; i < attempt && d < r.maxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, r.maxBackoff)
+	if d <= 0 {
+		return 0
+	}
+	return d - rand.N(d/2+1) //nolint:gosec // reason: jitter doesn't need a secure source
+}
+
+// Upload uploads a local file, retrying transient failures.
+func (r *Retrying) Upload(ctx context.Context, timestamp, localPath string) (string, error) {
+	var key string
+	err := r.do(ctx, "upload", func() error {
+		var err error
+		key, err = r.StorageIface.Upload(ctx, timestamp, localPath)
+		return err
+	})
+	return key, err
+}
+
+// Download downloads a key, retrying transient failures.
+func (r *Retrying) Download(ctx context.Context, key, localPath string) error {
+	return r.do(ctx, "download", func() error {
+		return r.StorageIface.Download(ctx, key, localPath)
+	})
+}
+
+// List lists keys, retrying transient failures.
+func (r *Retrying) List(ctx context.Context) ([]string, error) {
+	var keys []string
+	err := r.do(ctx, "list", func() error {
+		var err error
+		keys, err = r.StorageIface.List(ctx)
+		return err
+	})
+	return keys, err
+}
+
+// ListPage lists a page of keys, retrying transient failures.
+func (r *Retrying) ListPage(ctx context.Context, opts ListOptions) (Page, error) {
+	var page Page
+	err := r.do(ctx, "list", func() error {
+		var err error
+		page, err = r.StorageIface.ListPage(ctx, opts)
+		return err
+	})
+	return page, err
+}
+
+// ListFiles lists a backup's files, retrying transient failures.
+func (r *Retrying) ListFiles(ctx context.Context, timestamp string) ([]string, error) {
+	var keys []string
+	err := r.do(ctx, "list", func() error {
+		var err error
+		keys, err = r.StorageIface.ListFiles(ctx, timestamp)
+		return err
+	})
+	return keys, err
+}
+
+// Stats sums the space backups take up, retrying transient failures.
+func (r *Retrying) Stats(ctx context.Context) (Usage, error) {
+	var usage Usage
+	err := r.do(ctx, "list", func() error {
+		var err error
+		usage, err = r.StorageIface.Stats(ctx)
+		return err
+	})
+	return usage, err
+}
+
+// Delete deletes a key, retrying transient failures.
+func (r *Retrying) Delete(ctx context.Context, key string) error {
+	return r.do(ctx, "delete", func() error {
+		return r.StorageIface.Delete(ctx, key)
+	})
+}
+
+// SetStorageClass changes a key's storage class, retrying transient failures.
+func (r *Retrying) SetStorageClass(ctx context.Context, key, class string) error {
+	return r.do(ctx, "set-storage-class", func() error {
+		return r.StorageIface.SetStorageClass(ctx, key, class)
+	})
+}
+
+// Copy copies a key server-side, retrying transient failures.
+func (r *Retrying) Copy(ctx context.Context, key, timestamp string) (string, error) {
+	var newKey string
+	err := r.do(ctx, "copy", func() error {
+		var err error
+		newKey, err = Copy(ctx, r.StorageIface, key, timestamp)
+		return err
+	})
+	return newKey, err
+}
+
+// NewRetrying wraps store so its calls are retried according to cfg. Errors are classified by store
+// if it implements ClassifierIface and by ErrorClass otherwise. It fails if cfg names an unknown
+// error class.
+func NewRetrying(store StorageIface, cfg config.RetryConfig) (StorageIface, error) {
+	retryOn := cfg.RetryOn
+	if len(retryOn) == 0 {
+		retryOn = RetryClasses
+	}
+	for _, class := range retryOn {
+		if !slices.Contains(RetryClasses, class) {
+			return nil, fmt.Errorf("%w %q (available: %v)", ErrUnknownRetryClass, class, RetryClasses)
+		}
+	}
+
+	classify := ErrorClass
+	if c, ok := store.(ClassifierIface); ok {
+		classify = c.ErrorClass
+	}
+	return &Retrying{
+		StorageIface: store,
+		attempts:     max(cfg.MaxAttempts, 1),
+		backoff:      cfg.Backoff,
+		maxBackoff:   max(cfg.MaxBackoff, cfg.Backoff),
+		retryOn:      retryOn,
+		classify:     classify,
+	}, nil
+}
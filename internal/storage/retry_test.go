@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type statusErr int
+
+func (e statusErr) Error() string       { return fmt.Sprintf("status %d", int(e)) }
+func (e statusErr) HTTPStatusCode() int { return int(e) }
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"throttled", fmt.Errorf("upload: %w", statusErr(429)), RetryThrottling},
+		{"unavailable", statusErr(503), RetryServer},
+		{"not implemented", statusErr(501), ""},
+		{"not found", statusErr(404), ""},
+		{"ftp transient", &textproto.Error{Code: 421, Msg: "service not available"}, RetryServer},
+		{"ftp permanent", &textproto.Error{Code: 550, Msg: "file unavailable"}, ""},
+		{"dial", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, RetryNetwork},
+		{"reset", fmt.Errorf("read: %w", syscall.ECONNRESET), RetryNetwork},
+		{"truncated", io.ErrUnexpectedEOF, RetryNetwork},
+		{"other", errors.New("access denied"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ErrorClass(tt.err))
+		})
+	}
+}
+
+func TestRetrying_RetriesTransientErrors(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockStorageIface(t)
+	mock.On("Name").Return("retry-test")
+
+	store, err := NewRetrying(mock, config.RetryConfig{MaxAttempts: 3, Backoff: time.Millisecond})
+	require.NoError(t, err)
+
+	mock.On("Upload", "20240101000000", "/tmp/a.zip").Return("", statusErr(503)).Twice()
+	mock.On("Upload", "20240101000000", "/tmp/a.zip").Return("p/a.zip", nil).Once()
+	key, err := store.Upload(ctx, "20240101000000", "/tmp/a.zip")
+	require.NoError(t, err)
+	assert.Equal(t, "p/a.zip", key)
+	assert.InDelta(t, 2, testutil.ToFloat64(metrics.StorageRetries.WithLabelValues("retry-test", "upload", RetryServer)), 0)
+
+	// Attempts run out.
+	mock.On("List").Return(nil, statusErr(429)).Times(3)
+	_, err = store.List(ctx)
+	require.Error(t, err)
+
+	// Errors that aren't transient aren't retried.
+	mock.On("Delete", "p/b.zip").Return(statusErr(403)).Once()
+	require.Error(t, store.Delete(ctx, "p/b.zip"))
+}
+
+func TestRetrying_RetryOn(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockStorageIface(t)
+	mock.On("Name").Return("retry-on-test").Maybe()
+
+	store, err := NewRetrying(mock, config.RetryConfig{MaxAttempts: 3, RetryOn: []string{RetryThrottling}})
+	require.NoError(t, err)
+
+	mock.On("ListFiles", "20240101000000").Return(nil, statusErr(500)).Once()
+	_, err = store.ListFiles(ctx, "20240101000000")
+	require.Error(t, err)
+
+	_, err = NewRetrying(mock, config.RetryConfig{RetryOn: []string{"timeouts"}})
+	require.ErrorIs(t, err, ErrUnknownRetryClass)
+}
+
+// classifyingStore classifies every error as throttling.
+type classifyingStore struct {
+	*MockStorageIface
+}
+
+func (classifyingStore) ErrorClass(error) string { return RetryThrottling }
+
+func TestRetrying_BackendClassifier(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockStorageIface(t)
+	mock.On("Name").Return("classifier-test")
+
+	store, err := NewRetrying(classifyingStore{mock}, config.RetryConfig{MaxAttempts: 2})
+	require.NoError(t, err)
+
+	mock.On("Download", "p/a.zip", "/tmp/a.zip").Return(errors.New("slow down")).Once()
+	mock.On("Download", "p/a.zip", "/tmp/a.zip").Return(nil).Once()
+	require.NoError(t, store.Download(ctx, "p/a.zip", "/tmp/a.zip"))
+}
+
+func TestRetrying_WithoutRetries(t *testing.T) {
+	mock := NewMockStorageIface(t)
+	mock.On("Name").Return("no-retries-test").Maybe()
+
+	store, err := NewRetrying(mock, config.RetryConfig{MaxAttempts: 3, Backoff: time.Millisecond})
+	require.NoError(t, err)
+
+	mock.On("List").Return(nil, statusErr(503)).Once()
+	_, err = store.List(WithoutRetries(context.Background()))
+	require.Error(t, err)
+}
+
+func TestRetrying_Wait(t *testing.T) {
+	r := &Retrying{backoff: time.Second, maxBackoff: 5 * time.Second}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 6: 5 * time.Second} {
+		wait := r.wait(attempt)
+		assert.LessOrEqual(t, wait, want, "attempt %d", attempt)
+		assert.GreaterOrEqual(t, wait, want/2, "attempt %d", attempt)
+	}
+}
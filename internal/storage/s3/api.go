@@ -0,0 +1,141 @@
+package s3
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsMiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	awsHTTP "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/httpclient"
+	"github.com/hibare/stashly/internal/proxy"
+	"github.com/hibare/stashly/internal/version"
+)
+
+// apiIface is the subset of the AWS S3 API used directly by this backend.
+type apiIface interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+	CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+
+	// Multipart uploads.
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+}
+
+// newAPIClient creates an AWS SDK S3 client from the configuration. Every request carries the
+// Stashly user agent and the configured extra headers, and uses the configured proxy and TLS settings.
+func newAPIClient(ctx context.Context, cfg *config.Config) (*s3.Client, error) {
+	proxyFunc, err := proxy.Func(cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	tlsCfg, err := httpclient.TLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []func(*s3.Options){
+		func(o *s3.Options) {
+			o.HTTPClient = awsHTTP.NewBuildableClient().WithTransportOptions(func(t *http.Transport) {
+				t.Proxy = proxyFunc
+				if tlsCfg != nil {
+					t.TLSClientConfig = tlsCfg
+				}
+			})
+		},
+	}
+
+	if cfg.S3.Region != "" {
+		opts = append(opts, func(o *s3.Options) {
+			o.Region = cfg.S3.Region
+		})
+	}
+
+	mode, err := authMode(&cfg.S3)
+	if err != nil {
+		return nil, err
+	}
+
+	// With the default chain, the credentials LoadDefaultConfig resolves are left in place.
+	switch {
+	case mode == AuthModeDefaultChain:
+	case cfg.S3.AccessKeyFile != "" && cfg.S3.SecretKeyFile != "":
+		provider := &fileCredentials{
+			accessKeyFile: cfg.S3.AccessKeyFile,
+			secretKeyFile: cfg.S3.SecretKeyFile,
+			refresh:       cfg.S3.CredentialsRefresh,
+			now:           time.Now,
+		}
+		opts = append(opts, func(o *s3.Options) {
+			o.Credentials = aws.NewCredentialsCache(provider)
+		})
+	case cfg.S3.AccessKey != "" && cfg.S3.SecretKey != "":
+		opts = append(opts, func(o *s3.Options) {
+			o.Credentials = credentials.NewStaticCredentialsProvider(cfg.S3.AccessKey, cfg.S3.SecretKey, "")
+		})
+	}
+
+	if cfg.S3.Endpoint != "" {
+		opts = append(opts, func(o *s3.Options) {
+			o.BaseEndpoint = aws.String(cfg.S3.Endpoint)
+		})
+	}
+
+	opts = append(opts, func(o *s3.Options) {
+		o.APIOptions = append(o.APIOptions, userAgentOptions(cfg)...)
+		// Sorted so requests are built the same way every time.
+		for _, name := range slices.Sorted(maps.Keys(cfg.S3.Headers)) {
+			o.APIOptions = append(o.APIOptions, smithyhttp.AddHeaderValue(name, cfg.S3.Headers[name]))
+		}
+	})
+
+	awsCfg, err := awsConfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s3.NewFromConfig(awsCfg, opts...), nil
+}
+
+// userAgentOptions appends the configured user agent, or stashly/<version> instance/<instance-id>,
+// to the SDK's user agent. The SDK replaces characters not allowed in user agent tokens.
+func userAgentOptions(cfg *config.Config) []func(*middleware.Stack) error {
+	if cfg.S3.UserAgent != "" {
+		return []func(*middleware.Stack) error{awsMiddleware.AddUserAgentKey(cfg.S3.UserAgent)}
+	}
+
+	opts := []func(*middleware.Stack) error{awsMiddleware.AddUserAgentKeyValue("stashly", version.Version)}
+	if cfg.App.InstanceID != "" {
+		opts = append(opts, awsMiddleware.AddUserAgentKeyValue("instance", cfg.App.InstanceID))
+	}
+	return opts
+}
+
+// objectTagging encodes tags as the query string expected by PutObjectInput.Tagging.
+func objectTagging(tags map[string]string) *string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	values := url.Values{}
+	for k, v := range tags {
+		values.Set(k, v)
+	}
+	return aws.String(values.Encode())
+}
@@ -0,0 +1,62 @@
+package s3
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAPIClient_UserAgentAndHeaders(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write([]byte(`<ListBucketResult></ListBucketResult>`))
+	}))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name      string
+		userAgent string
+		want      string
+	}{
+		{name: "default", want: "stashly/"},
+		{name: "custom", userAgent: "acme-backups", want: "acme-backups"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				S3: config.S3Config{
+					Endpoint:  srv.URL,
+					Region:    "us-east-1",
+					AccessKey: "key",
+					SecretKey: "secret",
+					UserAgent: tt.userAgent,
+					Headers:   map[string]string{"X-Cost-Center": "42"},
+				},
+				App: config.AppConfig{InstanceID: "node-1"},
+			}
+
+			client, err := newAPIClient(context.Background(), cfg)
+			require.NoError(t, err)
+
+			_, err = client.ListObjectsV2(context.Background(), &awsS3.ListObjectsV2Input{Bucket: aws.String("bucket")},
+				func(o *awsS3.Options) { o.UsePathStyle = true })
+			require.NoError(t, err)
+
+			assert.Contains(t, got.Get("User-Agent"), tt.want)
+			assert.Equal(t, "42", got.Get("X-Cost-Center"))
+			if tt.userAgent == "" {
+				assert.Contains(t, got.Get("User-Agent"), "instance/node-1")
+			}
+		})
+	}
+}
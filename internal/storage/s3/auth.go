@@ -0,0 +1,81 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/hibare/stashly/internal/config"
+)
+
+// Values of s3.auth-mode.
+const (
+	// AuthModeAuto uses static keys when they are configured and the default credential chain otherwise.
+	AuthModeAuto = "auto"
+
+	// AuthModeStatic only uses the configured access keys or key files.
+	AuthModeStatic = "static"
+
+	// AuthModeDefaultChain only uses the AWS SDK's default credential chain: environment variables,
+	// shared config and SSO profiles, IRSA web identity tokens, ECS task roles and instance profiles.
+	AuthModeDefaultChain = "default-chain"
+)
+
+var (
+	// ErrUnknownAuthMode is returned when s3.auth-mode isn't a known mode.
+	ErrUnknownAuthMode = errors.New("unknown s3.auth-mode; expected auto, static or default-chain")
+
+	// ErrNoStaticCredentials is returned when s3.auth-mode is static but no keys are configured.
+	ErrNoStaticCredentials = errors.New("s3.auth-mode static needs s3.access-key and s3.secret-key, or s3.access-key-file and s3.secret-key-file")
+
+	// ErrStaticCredentialsWithChain is returned when keys are configured along with s3.auth-mode default-chain.
+	ErrStaticCredentialsWithChain = errors.New("s3.auth-mode default-chain doesn't use static keys; unset s3.access-key, s3.secret-key and the key files")
+
+	// ErrNoCredentials is returned when the default credential chain finds no credentials.
+	ErrNoCredentials = errors.New("no AWS credentials found in the default credential chain")
+)
+
+// hasStaticKeys reports whether access keys or key files are configured.
+func hasStaticKeys(cfg *config.S3Config) bool {
+	return (cfg.AccessKeyFile != "" && cfg.SecretKeyFile != "") || (cfg.AccessKey != "" && cfg.SecretKey != "")
+}
+
+// authMode returns how requests are authenticated, AuthModeStatic or AuthModeDefaultChain, with auto
+// resolved by whether keys are configured.
+func authMode(cfg *config.S3Config) (string, error) {
+	switch cfg.AuthMode {
+	case "", AuthModeAuto:
+		if hasStaticKeys(cfg) {
+			return AuthModeStatic, nil
+		}
+		return AuthModeDefaultChain, nil
+	case AuthModeStatic:
+		if !hasStaticKeys(cfg) {
+			return "", ErrNoStaticCredentials
+		}
+		return AuthModeStatic, nil
+	case AuthModeDefaultChain:
+		if cfg.AccessKey != "" || cfg.SecretKey != "" || cfg.AccessKeyFile != "" || cfg.SecretKeyFile != "" {
+			return "", ErrStaticCredentialsWithChain
+		}
+		return AuthModeDefaultChain, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownAuthMode, cfg.AuthMode)
+	}
+}
+
+// checkCredentials resolves credentials from the default chain, so a missing role or profile fails
+// at startup instead of at the first upload.
+func checkCredentials(ctx context.Context, provider aws.CredentialsProvider) error {
+	if provider == nil {
+		return ErrNoCredentials
+	}
+	creds, err := provider.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNoCredentials, err)
+	}
+	slog.DebugContext(ctx, "Using AWS default credential chain", "source", creds.Source)
+	return nil
+}
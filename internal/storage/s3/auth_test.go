@@ -0,0 +1,54 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.S3Config
+		want    string
+		wantErr error
+	}{
+		{name: "auto with keys", cfg: config.S3Config{AccessKey: "key", SecretKey: "secret"}, want: AuthModeStatic},
+		{name: "auto with key files", cfg: config.S3Config{AuthMode: AuthModeAuto, AccessKeyFile: "/a", SecretKeyFile: "/s"}, want: AuthModeStatic},
+		{name: "auto without keys", cfg: config.S3Config{}, want: AuthModeDefaultChain},
+		{name: "static", cfg: config.S3Config{AuthMode: AuthModeStatic, AccessKey: "key", SecretKey: "secret"}, want: AuthModeStatic},
+		{name: "static without keys", cfg: config.S3Config{AuthMode: AuthModeStatic, AccessKey: "key"}, wantErr: ErrNoStaticCredentials},
+		{name: "default chain", cfg: config.S3Config{AuthMode: AuthModeDefaultChain}, want: AuthModeDefaultChain},
+		{name: "default chain with a key", cfg: config.S3Config{AuthMode: AuthModeDefaultChain, SecretKey: "secret"}, wantErr: ErrStaticCredentialsWithChain},
+		{name: "unknown", cfg: config.S3Config{AuthMode: "iam"}, wantErr: ErrUnknownAuthMode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := authMode(&tt.cfg)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCheckCredentials(t *testing.T) {
+	ok := aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "ASIA", SecretAccessKey: "secret", Source: "WebIdentityCredentials"}, nil
+	})
+	require.NoError(t, checkCredentials(context.Background(), ok))
+
+	missing := aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{}, errors.New("failed to refresh cached credentials, no EC2 IMDS role found")
+	})
+	require.ErrorIs(t, checkCredentials(context.Background(), missing), ErrNoCredentials)
+	require.ErrorIs(t, checkCredentials(context.Background(), nil), ErrNoCredentials)
+}
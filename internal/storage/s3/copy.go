@@ -0,0 +1,46 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/hibare/stashly/internal/storage"
+)
+
+// Copy copies the object at key, anywhere in the bucket, into the backup at timestamp without
+// downloading it. The copy gets the default storage class; tiering moves it again when it is due.
+func (s *S3) Copy(ctx context.Context, key, timestamp string) (string, error) {
+	head, err := s.api.HeadObject(ctx, &awsS3.HeadObjectInput{
+		Bucket: aws.String(s.cfg.S3.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", err
+	}
+	if aws.ToInt64(head.ContentLength) > maxCopySize {
+		return "", fmt.Errorf("%w: %s", ErrObjectTooLarge, key)
+	}
+
+	newKey := storage.BuildKey(s.cfg.S3.Prefix, s.cfg.App.InstanceID, timestamp) + path.Base(key)
+	_, err = s.api.CopyObject(ctx, &awsS3.CopyObjectInput{
+		Bucket:            aws.String(s.cfg.S3.Bucket),
+		Key:               aws.String(newKey),
+		CopySource:        aws.String((&url.URL{Path: s.cfg.S3.Bucket + "/" + key}).EscapedPath()),
+		MetadataDirective: types.MetadataDirectiveCopy,
+		TaggingDirective:  types.TaggingDirectiveCopy,
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+
+		// A copy is encrypted as a new object would be, not as its source was.
+		ServerSideEncryption: types.ServerSideEncryption(s.cfg.S3.ServerSideEncryption),
+		SSEKMSKeyId:          s.kmsKeyID(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return newKey, nil
+}
@@ -0,0 +1,35 @@
+package s3
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3_Copy(t *testing.T) {
+	s, _, api, _ := newTestS3(t)
+
+	api.On("HeadObject", mock.Anything, mock.Anything).Return(&awsS3.HeadObjectOutput{ContentLength: aws.Int64(5)}, nil).Once()
+	api.On("CopyObject", mock.Anything, mock.MatchedBy(func(in *awsS3.CopyObjectInput) bool {
+		return aws.ToString(in.CopySource) == "bucket/old/host-1/20250101000000/backup.zip" &&
+			aws.ToString(in.Key) == "prefix/instance/20250101000000/backup.zip"
+	})).Return(&awsS3.CopyObjectOutput{}, nil).Once()
+
+	key, err := s.Copy(context.Background(), "old/host-1/20250101000000/backup.zip", "20250101000000")
+	require.NoError(t, err)
+	assert.Equal(t, "prefix/instance/20250101000000/backup.zip", key)
+}
+
+func TestS3_Copy_TooLarge(t *testing.T) {
+	s, _, api, _ := newTestS3(t)
+
+	api.On("HeadObject", mock.Anything, mock.Anything).Return(&awsS3.HeadObjectOutput{ContentLength: aws.Int64(maxCopySize + 1)}, nil).Once()
+
+	_, err := s.Copy(context.Background(), "old/host-1/20250101000000/backup.zip", "20250101000000")
+	require.ErrorIs(t, err, ErrObjectTooLarge)
+}
@@ -0,0 +1,70 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// ErrEmptyCredentials is returned when a credentials file is empty.
+var ErrEmptyCredentials = errors.New("credentials file is empty")
+
+// fileCredentials reads the access and secret key from files, e.g. mounted secrets, so rotated keys
+// are picked up without restarting. The credentials it returns expire after refresh, which makes
+// the SDK's credentials cache read the files again.
+type fileCredentials struct {
+	accessKeyFile string
+	secretKeyFile string
+	refresh       time.Duration
+	now           func() time.Time
+
+	mu        sync.Mutex
+	accessKey string
+}
+
+// readSecret reads a single secret from path, ignoring surrounding whitespace.
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return "", fmt.Errorf("%w: %s", ErrEmptyCredentials, path)
+	}
+	return secret, nil
+}
+
+// Retrieve implements aws.CredentialsProvider.
+func (f *fileCredentials) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	accessKey, err := readSecret(f.accessKeyFile)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("error reading access key: %w", err)
+	}
+	secretKey, err := readSecret(f.secretKeyFile)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("error reading secret key: %w", err)
+	}
+
+	f.mu.Lock()
+	if f.accessKey != "" && f.accessKey != accessKey {
+		slog.InfoContext(ctx, "Storage credentials rotated")
+	}
+	f.accessKey = accessKey
+	f.mu.Unlock()
+
+	return aws.Credentials{
+		AccessKeyID:     accessKey,
+		SecretAccessKey: secretKey,
+		Source:          "StashlyFileCredentials",
+		CanExpire:       true,
+		Expires:         f.now().Add(f.refresh),
+	}, nil
+}
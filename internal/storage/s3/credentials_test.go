@@ -0,0 +1,62 @@
+package s3
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileCredentials_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	accessKeyFile := filepath.Join(dir, "access-key")
+	secretKeyFile := filepath.Join(dir, "secret-key")
+	require.NoError(t, os.WriteFile(accessKeyFile, []byte("AKIAOLD\n"), 0600))
+	require.NoError(t, os.WriteFile(secretKeyFile, []byte("old-secret\n"), 0600))
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	provider := &fileCredentials{
+		accessKeyFile: accessKeyFile,
+		secretKeyFile: secretKeyFile,
+		refresh:       5 * time.Minute,
+		now:           func() time.Time { return now },
+	}
+	cache := aws.NewCredentialsCache(provider, func(o *aws.CredentialsCacheOptions) { o.ExpiryWindow = 0 })
+
+	creds, err := provider.Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "AKIAOLD", creds.AccessKeyID)
+	assert.Equal(t, "old-secret", creds.SecretAccessKey)
+	assert.Equal(t, now.Add(5*time.Minute), creds.Expires)
+
+	cached, err := cache.Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "AKIAOLD", cached.AccessKeyID)
+
+	require.NoError(t, os.WriteFile(accessKeyFile, []byte("AKIANEW"), 0600))
+	require.NoError(t, os.WriteFile(secretKeyFile, []byte("new-secret"), 0600))
+
+	creds, err = provider.Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "AKIANEW", creds.AccessKeyID)
+	assert.Equal(t, "new-secret", creds.SecretAccessKey)
+}
+
+func TestFileCredentials_Errors(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty")
+	require.NoError(t, os.WriteFile(empty, []byte(" \n"), 0600))
+
+	provider := &fileCredentials{accessKeyFile: empty, secretKeyFile: empty, now: time.Now}
+	_, err := provider.Retrieve(context.Background())
+	require.ErrorIs(t, err, ErrEmptyCredentials)
+
+	provider = &fileCredentials{accessKeyFile: filepath.Join(dir, "missing"), secretKeyFile: empty, now: time.Now}
+	_, err = provider.Retrieve(context.Background())
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,206 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/hibare/stashly/internal/constants"
+)
+
+const mebibyte = 1 << 20
+
+// ErrShortRead is returned when a ranged GET returns fewer bytes than requested.
+var ErrShortRead = errors.New("short read")
+
+// downloadState is persisted next to a partial download so an interrupted download can resume.
+type downloadState struct {
+	ETag     string `json:"etag"`
+	Size     int64  `json:"size"`
+	PartSize int64  `json:"part_size"`
+	Done     []bool `json:"done"`
+}
+
+// matches reports whether a saved state belongs to the same object version and part layout.
+func (st *downloadState) matches(etag string, size, partSize int64) bool {
+	return st.ETag == etag && st.Size == size && st.PartSize == partSize &&
+		len(st.Done) == partCount(size, partSize)
+}
+
+func partCount(size, partSize int64) int {
+	return int((size + partSize - 1) / partSize)
+}
+
+func loadDownloadState(path string) *downloadState {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var st downloadState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil
+	}
+	return &st
+}
+
+func (st *downloadState) save(path string) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// downloadSettings returns the part size in bytes and the number of parallel GETs to use.
+func (s *S3) downloadSettings() (int64, int) {
+	partSize := s.cfg.Restore.DownloadPartSizeMB * mebibyte
+	if partSize <= 0 {
+		partSize = constants.DefaultDownloadPartSizeMB * mebibyte
+	}
+	concurrency := s.cfg.Restore.DownloadConcurrency
+	if concurrency <= 0 {
+		concurrency = constants.DefaultDownloadConcurrency
+	}
+	return partSize, concurrency
+}
+
+// Download fetches key into localPath using parallel ranged GETs.
+// Progress is checkpointed next to localPath so a retried download resumes where it stopped,
+// as long as the object hasn't changed in between.
+func (s *S3) Download(ctx context.Context, key, localPath string) error {
+	head, err := s.api.HeadObject(ctx, &awsS3.HeadObjectInput{
+		Bucket: aws.String(s.cfg.S3.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return err
+	}
+
+	size := aws.ToInt64(head.ContentLength)
+	etag := aws.ToString(head.ETag)
+	partSize, concurrency := s.downloadSettings()
+
+	partPath := localPath + ".part"
+	statePath := partPath + ".json"
+
+	state := loadDownloadState(statePath)
+	if state == nil || !state.matches(etag, size, partSize) {
+		state = &downloadState{ETag: etag, Size: size, PartSize: partSize, Done: make([]bool, partCount(size, partSize))}
+	} else {
+		slog.InfoContext(ctx, "Resuming interrupted download", "key", key, "file", localPath)
+	}
+
+	f, err := os.OpenFile(partPath, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	if err := f.Truncate(size); err != nil {
+		return err
+	}
+
+	pending := make(chan int)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	for range concurrency {
+		wg.Go(func() {
+			for part := range pending {
+				if pErr := s.downloadPart(ctx, key, etag, f, part, partSize, size); pErr != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = pErr
+						cancel()
+					}
+					mu.Unlock()
+					continue
+				}
+
+				mu.Lock()
+				state.Done[part] = true
+				if sErr := state.save(statePath); sErr != nil {
+					slog.WarnContext(ctx, "Failed to checkpoint download", "file", statePath, "error", sErr)
+				}
+				mu.Unlock()
+			}
+		})
+	}
+
+	slog.DebugContext(ctx, "Downloading object", "key", key, "size", size, "parts", len(state.Done), "concurrency", concurrency)
+	for part, done := range state.Done {
+		if done {
+			continue
+		}
+		select {
+		case pending <- part:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(pending)
+	wg.Wait()
+
+	if firstErr != nil {
+		return fmt.Errorf("error downloading %s: %w", key, firstErr)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(partPath, localPath); err != nil {
+		return err
+	}
+	return os.Remove(statePath)
+}
+
+// downloadPart fetches a single byte range and writes it at its offset in f.
+func (s *S3) downloadPart(ctx context.Context, key, etag string, f *os.File, part int, partSize, size int64) error {
+	start := int64(part) * partSize
+	end := min(start+partSize, size) - 1
+
+	out, err := s.api.GetObject(ctx, &awsS3.GetObjectInput{
+		Bucket:  aws.String(s.cfg.S3.Bucket),
+		Key:     aws.String(key),
+		Range:   aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+		IfMatch: aws.String(etag),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = out.Body.Close()
+	}()
+
+	n, err := io.Copy(io.NewOffsetWriter(f, start), out.Body)
+	if err != nil {
+		return err
+	}
+	if want := end - start + 1; n != want {
+		return fmt.Errorf("%w for part %d: got %d bytes, want %d", ErrShortRead, part, n, want)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// objectAPI serves a single in-memory object, honouring Range headers.
+type objectAPI struct {
+	mockAPI
+
+	data   []byte
+	etag   string
+	failAt int64 // range start that fails, -1 for none
+
+	mu     sync.Mutex
+	ranges []string
+}
+
+func (o *objectAPI) HeadObject(_ context.Context, _ *awsS3.HeadObjectInput, _ ...func(*awsS3.Options)) (*awsS3.HeadObjectOutput, error) {
+	return &awsS3.HeadObjectOutput{ContentLength: aws.Int64(int64(len(o.data))), ETag: aws.String(o.etag)}, nil
+}
+
+func (o *objectAPI) GetObject(_ context.Context, params *awsS3.GetObjectInput, _ ...func(*awsS3.Options)) (*awsS3.GetObjectOutput, error) {
+	var start, end int64
+	if _, err := fmt.Sscanf(aws.ToString(params.Range), "bytes=%d-%d", &start, &end); err != nil {
+		return nil, err
+	}
+	if aws.ToString(params.IfMatch) != o.etag {
+		return nil, errors.New("precondition failed")
+	}
+
+	o.mu.Lock()
+	o.ranges = append(o.ranges, aws.ToString(params.Range))
+	o.mu.Unlock()
+
+	if start == o.failAt {
+		return nil, errors.New("connection reset")
+	}
+	return &awsS3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(o.data[start : end+1]))}, nil
+}
+
+func newDownloadS3(api apiIface) *S3 {
+	return &S3{api: api, cfg: &config.Config{
+		S3:      config.S3Config{Bucket: "bucket"},
+		Restore: config.RestoreConfig{DownloadConcurrency: 3, DownloadPartSizeMB: 1},
+	}}
+}
+
+func testObject() []byte {
+	data := make([]byte, 3*mebibyte+123)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	return data
+}
+
+func TestS3_Download_Success(t *testing.T) {
+	api := &objectAPI{data: testObject(), etag: `"abc"`, failAt: -1}
+	s := newDownloadS3(api)
+	localPath := filepath.Join(t.TempDir(), "backup.zip")
+
+	require.NoError(t, s.Download(context.Background(), "key", localPath))
+
+	got, err := os.ReadFile(localPath)
+	require.NoError(t, err)
+	assert.Equal(t, api.data, got)
+	assert.Len(t, api.ranges, 4)
+	assert.NoFileExists(t, localPath+".part")
+	assert.NoFileExists(t, localPath+".part.json")
+}
+
+func TestS3_Download_Resume(t *testing.T) {
+	api := &objectAPI{data: testObject(), etag: `"abc"`, failAt: 2 * mebibyte}
+	s := newDownloadS3(api)
+	localPath := filepath.Join(t.TempDir(), "backup.zip")
+
+	err := s.Download(context.Background(), "key", localPath)
+	require.Error(t, err)
+	assert.FileExists(t, localPath+".part.json")
+
+	state := loadDownloadState(localPath + ".part.json")
+	require.NotNil(t, state)
+	assert.False(t, state.Done[2])
+
+	api.failAt = -1
+	api.ranges = nil
+	require.NoError(t, s.Download(context.Background(), "key", localPath))
+
+	got, err := os.ReadFile(localPath)
+	require.NoError(t, err)
+	assert.Equal(t, api.data, got)
+	assert.Contains(t, api.ranges, fmt.Sprintf("bytes=%d-%d", 2*mebibyte, 3*mebibyte-1))
+	assert.Less(t, len(api.ranges), 4)
+}
+
+func TestS3_Download_ObjectChanged(t *testing.T) {
+	api := &objectAPI{data: testObject(), etag: `"abc"`, failAt: 2 * mebibyte}
+	s := newDownloadS3(api)
+	localPath := filepath.Join(t.TempDir(), "backup.zip")
+
+	require.Error(t, s.Download(context.Background(), "key", localPath))
+
+	// A new object version must not be stitched onto the old partial download.
+	api.etag = `"def"`
+	api.failAt = -1
+	api.ranges = nil
+	require.NoError(t, s.Download(context.Background(), "key", localPath))
+	assert.Len(t, api.ranges, 4)
+}
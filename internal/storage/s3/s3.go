@@ -3,22 +3,57 @@ package s3
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"os"
 	"path/filepath"
+	"slices"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 	commonS3 "github.com/hibare/GoCommon/v2/pkg/aws/s3"
 	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/storage"
 )
 
+// ErrChecksumMismatch is returned when the checksum reported by S3 differs from the local one.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// throttlingCodes are the error codes S3 and S3-compatible stores use to ask clients to slow down.
+var throttlingCodes = []string{"SlowDown", "Throttling", "ThrottlingException", "RequestLimitExceeded", "TooManyRequestsException"}
+
+// maxListKeys is the most keys S3 returns per ListObjectsV2 call.
+const maxListKeys = 1000
+
+// maxDeleteKeys is the most keys S3 deletes per DeleteObjects call.
+const maxDeleteKeys = 1000
+
 // S3 implements the StorageIface for S3-compatible storage backends.
+// All requests go through api, which carries the user agent and headers;
+// the GoCommon client is only used for its key helpers.
 type S3 struct {
 	s3  commonS3.ClientIface
+	api apiIface
 	cfg *config.Config
 }
 
-// Init prepares the S3 storage by establishing a session.
+// Init prepares the S3 storage by establishing a session. Credentials from the default chain are
+// resolved right away, and with SSE-KMS it also checks the KMS key can be used.
 func (s *S3) Init(ctx context.Context) error {
+	if _, err := s.serverSideEncryption(); err != nil {
+		return err
+	}
+	mode, err := authMode(&s.cfg.S3)
+	if err != nil {
+		return err
+	}
+
 	s3, err := commonS3.NewClient(ctx, commonS3.Options{
 		Endpoint:  s.cfg.S3.Endpoint,
 		Region:    s.cfg.S3.Region,
@@ -31,6 +66,21 @@ func (s *S3) Init(ctx context.Context) error {
 
 	s.s3 = s3
 
+	api, err := newAPIClient(ctx, s.cfg)
+	if err != nil {
+		return err
+	}
+	s.api = api
+
+	if mode == AuthModeDefaultChain {
+		if err := checkCredentials(ctx, api.Options().Credentials); err != nil {
+			return err
+		}
+	}
+
+	if s.usesKMS() {
+		return s.checkKMSKey(ctx)
+	}
 	return nil
 }
 
@@ -39,34 +89,232 @@ func (s *S3) Name() string {
 	return fmt.Sprintf("s3 (%s)", s.cfg.S3.Bucket)
 }
 
-// Upload uploads a local file to S3 and returns the remote key/path.
-func (s *S3) Upload(ctx context.Context, localPath string) (string, error) {
-	prefix := s.s3.BuildTimestampedKey(s.cfg.S3.Prefix, s.cfg.App.InstanceID)
+// ErrorClass classifies transient errors, telling throttling apart from other failed responses by
+// its error code, as S3 answers both with 503.
+func (s *S3) ErrorClass(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && slices.Contains(throttlingCodes, apiErr.ErrorCode()) {
+		return storage.RetryThrottling
+	}
+	return storage.ErrorClass(err)
+}
 
-	slog.DebugContext(ctx, "Uploading file to S3", "file", localPath, "bucket", s.cfg.S3.Bucket, "key_prefix", prefix)
-	key, err := s.s3.UploadFile(ctx, s.cfg.S3.Bucket, prefix, localPath)
+// sha256File returns the base64-encoded SHA-256 digest of a file, as used by S3 checksum headers.
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
 	if err != nil {
 		return "", err
 	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// Upload uploads a local file into the backup at timestamp and returns the remote key/path.
+// The file's SHA-256 is sent with the request so S3 verifies integrity server-side. Files larger than
+// s3.part-size-mb are uploaded in parts instead (see uploadMultipart).
+func (s *S3) Upload(ctx context.Context, timestamp, localPath string) (string, error) {
+	prefix := s.s3.BuildKey(s.cfg.S3.Prefix, s.cfg.App.InstanceID, timestamp)
+	key := filepath.Join(prefix, filepath.Base(localPath))
+
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return "", err
+	}
+	if info.Size() > s.partSize() {
+		return key, s.uploadMultipart(ctx, key, localPath, info)
+	}
+
+	checksum, err := sha256File(localPath)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	slog.DebugContext(ctx, "Uploading file to S3", "file", localPath, "bucket", s.cfg.S3.Bucket, "key", key, "sha256", checksum)
+	out, err := s.api.PutObject(ctx, &awsS3.PutObjectInput{
+		Bucket:            aws.String(s.cfg.S3.Bucket),
+		Key:               aws.String(key),
+		Body:              f,
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+		ChecksumSHA256:    aws.String(checksum),
+		Tagging:           objectTagging(s.cfg.S3.Tags),
+
+		ServerSideEncryption: types.ServerSideEncryption(s.cfg.S3.ServerSideEncryption),
+		SSEKMSKeyId:          s.kmsKeyID(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// S3 rejects mismatching bodies itself; some S3-compatible servers don't, so double check what was stored.
+	if got := aws.ToString(out.ChecksumSHA256); got != "" && got != checksum {
+		return "", fmt.Errorf("%w for %s: local %s, remote %s", ErrChecksumMismatch, key, checksum, got)
+	}
+
 	return key, nil
 }
 
 // List returns keys/identifiers under the configured prefix.
 func (s *S3) List(ctx context.Context) ([]string, error) {
+	var keys []string
+	opts := storage.ListOptions{}
+	for {
+		page, err := s.ListPage(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, page.Keys...)
+		if page.NextToken == "" {
+			return keys, nil
+		}
+		opts.Token = page.NextToken
+	}
+}
+
+// ListPage returns one page of the backups under the instance prefix, narrowed by opts.
+func (s *S3) ListPage(ctx context.Context, opts storage.ListOptions) (storage.Page, error) {
 	// Prefix excluding timestamp to list all backups for this instance
 	prefix := s.s3.BuildKey(s.cfg.S3.Prefix, s.cfg.App.InstanceID)
-	keys, err := s.s3.ListObjectsAtPrefix(ctx, s.cfg.S3.Bucket, prefix)
+
+	in := &awsS3.ListObjectsV2Input{
+		Bucket:    aws.String(s.cfg.S3.Bucket),
+		Prefix:    aws.String(prefix + opts.Prefix),
+		Delimiter: aws.String("/"),
+	}
+	if opts.StartAfter != "" {
+		// "0" sorts right after "/", so this also skips the files under the StartAfter backup itself.
+		in.StartAfter = aws.String(prefix + opts.StartAfter + "0")
+	}
+	if opts.Limit > 0 {
+		in.MaxKeys = aws.Int32(int32(min(opts.Limit, maxListKeys))) //nolint:gosec // reason: bounded by maxListKeys
+	}
+	if opts.Token != "" {
+		in.ContinuationToken = aws.String(opts.Token)
+	}
+
+	out, err := s.api.ListObjectsV2(ctx, in)
 	if err != nil {
-		return nil, err
+		return storage.Page{}, err
+	}
+
+	page := storage.Page{}
+	for _, obj := range out.Contents {
+		if key := aws.ToString(obj.Key); key != prefix {
+			page.Keys = append(page.Keys, key)
+		}
+	}
+	for _, cp := range out.CommonPrefixes {
+		page.Keys = append(page.Keys, aws.ToString(cp.Prefix))
+	}
+	if aws.ToBool(out.IsTruncated) {
+		page.NextToken = aws.ToString(out.NextContinuationToken)
+	}
+	return page, nil
+}
+
+// ListFiles returns the keys of all objects stored under the backup at the given timestamp.
+func (s *S3) ListFiles(ctx context.Context, timestamp string) ([]string, error) {
+	prefix := s.s3.BuildKey(s.cfg.S3.Prefix, s.cfg.App.InstanceID, timestamp)
+
+	var keys []string
+	paginator := awsS3.NewListObjectsV2Paginator(s.api, &awsS3.ListObjectsV2Input{
+		Bucket: aws.String(s.cfg.S3.Bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
+		}
 	}
 	return keys, nil
 }
 
-// Delete deletes the provided key/path from S3 storage.
+// Stats sums the sizes of the objects stored under the instance prefix per backup.
+func (s *S3) Stats(ctx context.Context) (storage.Usage, error) {
+	prefix := s.s3.BuildKey(s.cfg.S3.Prefix, s.cfg.App.InstanceID)
+
+	sizes := map[string]int64{}
+	paginator := awsS3.NewListObjectsV2Paginator(s.api, &awsS3.ListObjectsV2Input{
+		Bucket: aws.String(s.cfg.S3.Bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return storage.Usage{}, err
+		}
+		for _, obj := range page.Contents {
+			sizes[aws.ToString(obj.Key)] = aws.ToInt64(obj.Size)
+		}
+	}
+	return storage.UsageOf(prefix, sizes), nil
+}
+
+// Delete deletes the backup at timestamp, i.e. every object under its prefix, from S3 storage.
 func (s *S3) Delete(ctx context.Context, timestamp string) error {
 	prefix := s.s3.BuildKey(s.cfg.S3.Prefix, s.cfg.App.InstanceID)
 	key := filepath.Join(prefix, timestamp)
-	return s.s3.DeleteObjects(ctx, s.cfg.S3.Bucket, key, true)
+
+	keys := []string{}
+	paginator := awsS3.NewListObjectsV2Paginator(s.api, &awsS3.ListObjectsV2Input{
+		Bucket: aws.String(s.cfg.S3.Bucket),
+		Prefix: aws.String(key),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
+		}
+	}
+
+	// Delete the prefix itself too, in case a "directory" marker object exists.
+	return s.deleteKeys(ctx, append(keys, key))
+}
+
+// deleteKeys deletes keys in batches of up to maxDeleteKeys. Every batch is attempted; the keys S3
+// failed to delete are reported together.
+func (s *S3) deleteKeys(ctx context.Context, keys []string) error {
+	errs := []error{}
+	for batch := range slices.Chunk(keys, maxDeleteKeys) {
+		objects := make([]types.ObjectIdentifier, 0, len(batch))
+		for _, k := range batch {
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(k)})
+		}
+
+		out, err := s.api.DeleteObjects(ctx, &awsS3.DeleteObjectsInput{
+			Bucket: aws.String(s.cfg.S3.Bucket),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, e := range out.Errors {
+			errs = append(errs, fmt.Errorf("%s: %s: %s", aws.ToString(e.Key), aws.ToString(e.Code), aws.ToString(e.Message)))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // TrimPrefix trims the configured prefix from a given key, if present.
@@ -81,3 +329,10 @@ func NewS3Storage(cfg *config.Config) *S3 {
 		cfg: cfg,
 	}
 }
+
+func init() {
+	storage.Register("s3", storage.Registration{
+		New:   func(cfg *config.Config) storage.StorageIface { return NewS3Storage(cfg) },
+		Retry: func(cfg *config.Config) config.RetryConfig { return cfg.S3.Retry },
+	})
+}
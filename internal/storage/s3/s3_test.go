@@ -0,0 +1,330 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	commonS3 "github.com/hibare/GoCommon/v2/pkg/aws/s3"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// mockAPI is a mock implementation of apiIface.
+type mockAPI struct{ mock.Mock }
+
+func (m *mockAPI) PutObject(ctx context.Context, params *awsS3.PutObjectInput, _ ...func(*awsS3.Options)) (*awsS3.PutObjectOutput, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*awsS3.PutObjectOutput), args.Error(1) //nolint:errcheck // reason: type assertion on mock
+}
+
+func (m *mockAPI) HeadObject(ctx context.Context, params *awsS3.HeadObjectInput, _ ...func(*awsS3.Options)) (*awsS3.HeadObjectOutput, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*awsS3.HeadObjectOutput), args.Error(1) //nolint:errcheck // reason: type assertion on mock
+}
+
+func (m *mockAPI) ListObjectsV2(ctx context.Context, params *awsS3.ListObjectsV2Input, _ ...func(*awsS3.Options)) (*awsS3.ListObjectsV2Output, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*awsS3.ListObjectsV2Output), args.Error(1) //nolint:errcheck // reason: type assertion on mock
+}
+
+func (m *mockAPI) DeleteObjects(ctx context.Context, params *awsS3.DeleteObjectsInput, _ ...func(*awsS3.Options)) (*awsS3.DeleteObjectsOutput, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*awsS3.DeleteObjectsOutput), args.Error(1) //nolint:errcheck // reason: type assertion on mock
+}
+
+func (m *mockAPI) CopyObject(ctx context.Context, params *awsS3.CopyObjectInput, _ ...func(*awsS3.Options)) (*awsS3.CopyObjectOutput, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*awsS3.CopyObjectOutput), args.Error(1) //nolint:errcheck // reason: type assertion on mock
+}
+
+func (m *mockAPI) GetObject(ctx context.Context, params *awsS3.GetObjectInput, _ ...func(*awsS3.Options)) (*awsS3.GetObjectOutput, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*awsS3.GetObjectOutput), args.Error(1) //nolint:errcheck // reason: type assertion on mock
+}
+
+func (m *mockAPI) CreateMultipartUpload(ctx context.Context, params *awsS3.CreateMultipartUploadInput, _ ...func(*awsS3.Options)) (*awsS3.CreateMultipartUploadOutput, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*awsS3.CreateMultipartUploadOutput), args.Error(1) //nolint:errcheck // reason: type assertion on mock
+}
+
+func (m *mockAPI) UploadPart(ctx context.Context, params *awsS3.UploadPartInput, _ ...func(*awsS3.Options)) (*awsS3.UploadPartOutput, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*awsS3.UploadPartOutput), args.Error(1) //nolint:errcheck // reason: type assertion on mock
+}
+
+func (m *mockAPI) CompleteMultipartUpload(ctx context.Context, params *awsS3.CompleteMultipartUploadInput, _ ...func(*awsS3.Options)) (*awsS3.CompleteMultipartUploadOutput, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*awsS3.CompleteMultipartUploadOutput), args.Error(1) //nolint:errcheck // reason: type assertion on mock
+}
+
+func (m *mockAPI) AbortMultipartUpload(ctx context.Context, params *awsS3.AbortMultipartUploadInput, _ ...func(*awsS3.Options)) (*awsS3.AbortMultipartUploadOutput, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*awsS3.AbortMultipartUploadOutput), args.Error(1) //nolint:errcheck // reason: type assertion on mock
+}
+
+func newTestS3(t *testing.T) (*S3, *commonS3.MockClient, *mockAPI, string) {
+	t.Helper()
+
+	localPath := filepath.Join(t.TempDir(), "backup.zip.gpg")
+	require.NoError(t, os.WriteFile(localPath, []byte("hello"), 0o600))
+
+	client := new(commonS3.MockClient)
+	api := new(mockAPI)
+	t.Cleanup(func() {
+		client.AssertExpectations(t)
+		api.AssertExpectations(t)
+	})
+
+	cfg := &config.Config{
+		S3:  config.S3Config{Bucket: "bucket", Prefix: "prefix"},
+		App: config.AppConfig{InstanceID: "instance"},
+	}
+	return &S3{s3: client, api: api, cfg: cfg}, client, api, localPath
+}
+
+// sha256("hello"), base64 encoded.
+const helloChecksum = "LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ="
+
+func TestS3_Upload_Success(t *testing.T) {
+	s, client, api, localPath := newTestS3(t)
+
+	client.On("BuildKey", []string{"prefix", "instance", "20250101000000"}).Return("prefix/instance/20250101000000/")
+	api.On("PutObject", mock.Anything, mock.MatchedBy(func(in *awsS3.PutObjectInput) bool {
+		return aws.ToString(in.Key) == "prefix/instance/20250101000000/backup.zip.gpg" &&
+			in.ChecksumAlgorithm == types.ChecksumAlgorithmSha256 &&
+			aws.ToString(in.ChecksumSHA256) == helloChecksum
+	})).Return(&awsS3.PutObjectOutput{ChecksumSHA256: aws.String(helloChecksum)}, nil)
+
+	key, err := s.Upload(context.Background(), "20250101000000", localPath)
+	require.NoError(t, err)
+	assert.Equal(t, "prefix/instance/20250101000000/backup.zip.gpg", key)
+}
+
+func TestS3_Upload_ChecksumMismatch(t *testing.T) {
+	s, client, api, localPath := newTestS3(t)
+
+	client.On("BuildKey", []string{"prefix", "instance", "20250101000000"}).Return("prefix/instance/20250101000000/")
+	api.On("PutObject", mock.Anything, mock.Anything).
+		Return(&awsS3.PutObjectOutput{ChecksumSHA256: aws.String("bogus")}, nil)
+
+	_, err := s.Upload(context.Background(), "20250101000000", localPath)
+	require.ErrorIs(t, err, ErrChecksumMismatch)
+}
+
+func TestS3_Upload_MissingFile(t *testing.T) {
+	s, client, _, _ := newTestS3(t)
+
+	client.On("BuildKey", []string{"prefix", "instance", "20250101000000"}).Return("prefix/instance/20250101000000/")
+
+	_, err := s.Upload(context.Background(), "20250101000000", filepath.Join(t.TempDir(), "missing"))
+	require.Error(t, err)
+}
+
+func TestS3_ListFiles_Paginated(t *testing.T) {
+	s, client, api, _ := newTestS3(t)
+
+	client.On("BuildKey", []string{"prefix", "instance", "20250101000000"}).Return("prefix/instance/20250101000000/")
+	api.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(in *awsS3.ListObjectsV2Input) bool {
+		return in.ContinuationToken == nil
+	})).Return(&awsS3.ListObjectsV2Output{
+		Contents:              []types.Object{{Key: aws.String("prefix/instance/20250101000000/a")}},
+		IsTruncated:           aws.Bool(true),
+		NextContinuationToken: aws.String("next"),
+	}, nil).Once()
+	api.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(in *awsS3.ListObjectsV2Input) bool {
+		return aws.ToString(in.ContinuationToken) == "next"
+	})).Return(&awsS3.ListObjectsV2Output{
+		Contents: []types.Object{{Key: aws.String("prefix/instance/20250101000000/b")}},
+	}, nil).Once()
+
+	keys, err := s.ListFiles(context.Background(), "20250101000000")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prefix/instance/20250101000000/a", "prefix/instance/20250101000000/b"}, keys)
+}
+
+func TestS3_Stats(t *testing.T) {
+	s, client, api, _ := newTestS3(t)
+
+	client.On("BuildKey", []string{"prefix", "instance"}).Return("prefix/instance/")
+	api.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(in *awsS3.ListObjectsV2Input) bool {
+		return aws.ToString(in.Prefix) == "prefix/instance/" && in.Delimiter == nil && in.ContinuationToken == nil
+	})).Return(&awsS3.ListObjectsV2Output{
+		Contents: []types.Object{
+			{Key: aws.String("prefix/instance/20250101000000/postgres-plain.zip"), Size: aws.Int64(100)},
+			{Key: aws.String("prefix/instance/20250101000000/manifest.json"), Size: aws.Int64(10)},
+		},
+		IsTruncated:           aws.Bool(true),
+		NextContinuationToken: aws.String("next"),
+	}, nil).Once()
+	api.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(in *awsS3.ListObjectsV2Input) bool {
+		return aws.ToString(in.ContinuationToken) == "next"
+	})).Return(&awsS3.ListObjectsV2Output{
+		Contents: []types.Object{{Key: aws.String("prefix/instance/20250102000000/postgres-plain.zip"), Size: aws.Int64(200)}},
+	}, nil).Once()
+
+	usage, err := s.Stats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(310), usage.Bytes)
+	assert.Equal(t, 2, usage.Count)
+	assert.Equal(t, []storage.BackupUsage{
+		{Timestamp: "20250101000000", Bytes: 110, Files: 2},
+		{Timestamp: "20250102000000", Bytes: 200, Files: 1},
+	}, usage.Backups)
+}
+
+func TestS3_Upload_Tags(t *testing.T) {
+	s, client, api, localPath := newTestS3(t)
+	s.cfg.S3.Tags = map[string]string{"team": "data", "cost-center": "42"}
+
+	client.On("BuildKey", []string{"prefix", "instance", "20250101000000"}).Return("prefix/instance/20250101000000/")
+	api.On("PutObject", mock.Anything, mock.MatchedBy(func(in *awsS3.PutObjectInput) bool {
+		return aws.ToString(in.Tagging) == "cost-center=42&team=data"
+	})).Return(&awsS3.PutObjectOutput{ChecksumSHA256: aws.String(helloChecksum)}, nil)
+
+	_, err := s.Upload(context.Background(), "20250101000000", localPath)
+	require.NoError(t, err)
+}
+
+func TestS3_List_Paginated(t *testing.T) {
+	s, client, api, _ := newTestS3(t)
+
+	client.On("BuildKey", []string{"prefix", "instance"}).Return("prefix/instance/")
+	api.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(in *awsS3.ListObjectsV2Input) bool {
+		return in.ContinuationToken == nil && aws.ToString(in.Delimiter) == "/"
+	})).Return(&awsS3.ListObjectsV2Output{
+		Contents:              []types.Object{{Key: aws.String("prefix/instance/")}},
+		CommonPrefixes:        []types.CommonPrefix{{Prefix: aws.String("prefix/instance/20250101000000/")}},
+		IsTruncated:           aws.Bool(true),
+		NextContinuationToken: aws.String("next"),
+	}, nil).Once()
+	api.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(in *awsS3.ListObjectsV2Input) bool {
+		return aws.ToString(in.ContinuationToken) == "next"
+	})).Return(&awsS3.ListObjectsV2Output{
+		CommonPrefixes: []types.CommonPrefix{{Prefix: aws.String("prefix/instance/20250102000000/")}},
+	}, nil).Once()
+
+	keys, err := s.List(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prefix/instance/20250101000000/", "prefix/instance/20250102000000/"}, keys)
+}
+
+func TestS3_ListPage_Filters(t *testing.T) {
+	s, client, api, _ := newTestS3(t)
+
+	client.On("BuildKey", []string{"prefix", "instance"}).Return("prefix/instance/")
+	api.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(in *awsS3.ListObjectsV2Input) bool {
+		return aws.ToString(in.Prefix) == "prefix/instance/2025" &&
+			aws.ToString(in.StartAfter) == "prefix/instance/202501010000000" &&
+			aws.ToInt32(in.MaxKeys) == 1 &&
+			aws.ToString(in.ContinuationToken) == "token"
+	})).Return(&awsS3.ListObjectsV2Output{
+		CommonPrefixes:        []types.CommonPrefix{{Prefix: aws.String("prefix/instance/20250102000000/")}},
+		IsTruncated:           aws.Bool(true),
+		NextContinuationToken: aws.String("next"),
+	}, nil).Once()
+
+	page, err := s.ListPage(context.Background(), storage.ListOptions{
+		Prefix:     "2025",
+		StartAfter: "20250101000000",
+		Limit:      1,
+		Token:      "token",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prefix/instance/20250102000000/"}, page.Keys)
+	assert.Equal(t, "next", page.NextToken)
+}
+
+func TestS3_Delete(t *testing.T) {
+	s, client, api, _ := newTestS3(t)
+
+	client.On("BuildKey", []string{"prefix", "instance"}).Return("prefix/instance/")
+	api.On("ListObjectsV2", mock.Anything, mock.Anything).Return(&awsS3.ListObjectsV2Output{
+		Contents: []types.Object{{Key: aws.String("prefix/instance/20250101000000/backup.zip")}},
+	}, nil).Once()
+	api.On("DeleteObjects", mock.Anything, mock.MatchedBy(func(in *awsS3.DeleteObjectsInput) bool {
+		return objectKeys(in) == "prefix/instance/20250101000000/backup.zip,prefix/instance/20250101000000"
+	})).Return(&awsS3.DeleteObjectsOutput{}, nil).Once()
+
+	require.NoError(t, s.Delete(context.Background(), "20250101000000"))
+}
+
+// objectKeys joins the keys a DeleteObjects call deletes.
+func objectKeys(in *awsS3.DeleteObjectsInput) string {
+	keys := []string{}
+	for _, o := range in.Delete.Objects {
+		keys = append(keys, aws.ToString(o.Key))
+	}
+	return strings.Join(keys, ",")
+}
+
+func TestS3_Delete_Batches(t *testing.T) {
+	s, client, api, _ := newTestS3(t)
+
+	client.On("BuildKey", []string{"prefix", "instance"}).Return("prefix/instance/")
+	contents := make([]types.Object, 0, maxDeleteKeys+1)
+	for i := range maxDeleteKeys + 1 {
+		contents = append(contents, types.Object{Key: aws.String(fmt.Sprintf("prefix/instance/20250101000000/part-%04d", i))})
+	}
+	api.On("ListObjectsV2", mock.Anything, mock.Anything).Return(&awsS3.ListObjectsV2Output{Contents: contents}, nil).Once()
+
+	// The first batch fails for one key; the second is still sent, and the failure is reported.
+	api.On("DeleteObjects", mock.Anything, mock.MatchedBy(func(in *awsS3.DeleteObjectsInput) bool {
+		return len(in.Delete.Objects) == maxDeleteKeys && aws.ToBool(in.Delete.Quiet)
+	})).Return(&awsS3.DeleteObjectsOutput{Errors: []types.Error{{
+		Key: aws.String("prefix/instance/20250101000000/part-0007"), Code: aws.String("AccessDenied"), Message: aws.String("Access Denied"),
+	}}}, nil).Once()
+	api.On("DeleteObjects", mock.Anything, mock.MatchedBy(func(in *awsS3.DeleteObjectsInput) bool {
+		return len(in.Delete.Objects) == 2
+	})).Return(&awsS3.DeleteObjectsOutput{}, nil).Once()
+
+	err := s.Delete(context.Background(), "20250101000000")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "part-0007: AccessDenied")
+}
+
+func TestS3_ErrorClass(t *testing.T) {
+	s := NewS3Storage(&config.Config{})
+	assert.Equal(t, storage.RetryThrottling, s.ErrorClass(fmt.Errorf("upload: %w", &smithy.GenericAPIError{Code: "SlowDown"})))
+	assert.Empty(t, s.ErrorClass(&smithy.GenericAPIError{Code: "AccessDenied"}))
+}
@@ -0,0 +1,92 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/hibare/stashly/internal/storage"
+)
+
+// sseCheckDir is where Init writes its SSE-KMS probe object, beside the instance directories rather
+// than in one, so listings never see it.
+const sseCheckDir = ".stashly-sse-check"
+
+var (
+	// ErrUnknownSSE is returned when s3.server-side-encryption isn't a known mode.
+	ErrUnknownSSE = errors.New("unknown s3.server-side-encryption; expected AES256 or aws:kms")
+
+	// ErrKMSKeyWithoutKMS is returned when s3.kms-key-id is set without aws:kms encryption.
+	ErrKMSKeyWithoutKMS = errors.New("s3.kms-key-id needs s3.server-side-encryption: aws:kms")
+
+	// ErrKMSKeyInaccessible is returned when objects can't be encrypted or decrypted with the KMS key.
+	ErrKMSKeyInaccessible = errors.New("kms key is not accessible")
+)
+
+// serverSideEncryption returns the configured encryption mode, checked against the ones S3 offers.
+func (s *S3) serverSideEncryption() (types.ServerSideEncryption, error) {
+	mode := types.ServerSideEncryption(s.cfg.S3.ServerSideEncryption)
+	switch mode {
+	case "", types.ServerSideEncryptionAes256:
+		if s.cfg.S3.KMSKeyID != "" {
+			return "", ErrKMSKeyWithoutKMS
+		}
+	case types.ServerSideEncryptionAwsKms, types.ServerSideEncryptionAwsKmsDsse:
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownSSE, mode)
+	}
+	return mode, nil
+}
+
+// usesKMS reports whether objects are encrypted with a KMS key.
+func (s *S3) usesKMS() bool {
+	mode := types.ServerSideEncryption(s.cfg.S3.ServerSideEncryption)
+	return mode == types.ServerSideEncryptionAwsKms || mode == types.ServerSideEncryptionAwsKmsDsse
+}
+
+// kmsKeyID returns the KMS key objects are encrypted with, or nil for S3's default key.
+func (s *S3) kmsKeyID() *string {
+	if s.cfg.S3.KMSKeyID == "" {
+		return nil
+	}
+	return aws.String(s.cfg.S3.KMSKeyID)
+}
+
+// checkKMSKey writes, reads back and deletes a small object encrypted with the KMS key, so a key
+// that is missing, disabled or not usable by these credentials fails Init rather than the first
+// upload or, worse, the first restore.
+func (s *S3) checkKMSKey(ctx context.Context) error {
+	key := storage.BuildKey(s.cfg.S3.Prefix, sseCheckDir) + s.cfg.App.InstanceID
+	if _, err := s.api.PutObject(ctx, &awsS3.PutObjectInput{
+		Bucket:               aws.String(s.cfg.S3.Bucket),
+		Key:                  aws.String(key),
+		Body:                 strings.NewReader("ok"),
+		ServerSideEncryption: types.ServerSideEncryption(s.cfg.S3.ServerSideEncryption),
+		SSEKMSKeyId:          s.kmsKeyID(),
+	}); err != nil {
+		return fmt.Errorf("%w: encrypting: %w", ErrKMSKeyInaccessible, err)
+	}
+	defer func() {
+		_ = s.deleteKeys(context.WithoutCancel(ctx), []string{key})
+	}()
+
+	out, err := s.api.GetObject(ctx, &awsS3.GetObjectInput{
+		Bucket: aws.String(s.cfg.S3.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("%w: decrypting: %w", ErrKMSKeyInaccessible, err)
+	}
+	defer func() {
+		_ = out.Body.Close()
+	}()
+	if _, err := io.Copy(io.Discard, out.Body); err != nil {
+		return fmt.Errorf("%w: decrypting: %w", ErrKMSKeyInaccessible, err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3_ServerSideEncryption(t *testing.T) {
+	tests := []struct {
+		mode, keyID string
+		err         error
+	}{
+		{mode: ""},
+		{mode: "AES256"},
+		{mode: "aws:kms"},
+		{mode: "aws:kms", keyID: "alias/backups"},
+		{mode: "aws:kms:dsse", keyID: "alias/backups"},
+		{mode: "AES256", keyID: "alias/backups", err: ErrKMSKeyWithoutKMS},
+		{mode: "kms", err: ErrUnknownSSE},
+	}
+	for _, tt := range tests {
+		s, _, _, _ := newTestS3(t)
+		s.cfg.S3.ServerSideEncryption, s.cfg.S3.KMSKeyID = tt.mode, tt.keyID
+		_, err := s.serverSideEncryption()
+		require.ErrorIs(t, err, tt.err, "%s %s", tt.mode, tt.keyID)
+	}
+}
+
+func TestS3_Upload_SSEKMS(t *testing.T) {
+	s, client, api, localPath := newTestS3(t)
+	s.cfg.S3.ServerSideEncryption, s.cfg.S3.KMSKeyID = "aws:kms", "alias/backups"
+
+	client.On("BuildKey", []string{"prefix", "instance", "20250101000000"}).Return("prefix/instance/20250101000000/")
+	api.On("PutObject", mock.Anything, mock.MatchedBy(func(in *awsS3.PutObjectInput) bool {
+		return in.ServerSideEncryption == types.ServerSideEncryptionAwsKms && aws.ToString(in.SSEKMSKeyId) == "alias/backups"
+	})).Return(&awsS3.PutObjectOutput{}, nil)
+
+	_, err := s.Upload(context.Background(), "20250101000000", localPath)
+	require.NoError(t, err)
+}
+
+func TestS3_CheckKMSKey(t *testing.T) {
+	s, _, api, _ := newTestS3(t)
+	s.cfg.S3.ServerSideEncryption, s.cfg.S3.KMSKeyID = "aws:kms", "alias/backups"
+
+	const probe = "prefix/.stashly-sse-check/instance"
+	api.On("PutObject", mock.Anything, mock.MatchedBy(func(in *awsS3.PutObjectInput) bool {
+		return aws.ToString(in.Key) == probe && aws.ToString(in.SSEKMSKeyId) == "alias/backups"
+	})).Return(&awsS3.PutObjectOutput{}, nil).Twice()
+	api.On("DeleteObjects", mock.Anything, mock.MatchedBy(func(in *awsS3.DeleteObjectsInput) bool {
+		return objectKeys(in) == probe
+	})).Return(&awsS3.DeleteObjectsOutput{}, nil).Twice()
+
+	api.On("GetObject", mock.Anything, mock.Anything).Return(&awsS3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("ok"))}, nil).Once()
+	require.NoError(t, s.checkKMSKey(context.Background()))
+
+	// Writing works but the credentials may not decrypt; the probe is removed either way.
+	api.On("GetObject", mock.Anything, mock.Anything).Return(nil, errors.New("AccessDenied: kms:Decrypt")).Once()
+	err := s.checkKMSKey(context.Background())
+	require.ErrorIs(t, err, ErrKMSKeyInaccessible)
+	assert.Contains(t, err.Error(), "decrypting")
+}
+
+func TestS3_CheckKMSKey_EncryptFails(t *testing.T) {
+	s, _, api, _ := newTestS3(t)
+	s.cfg.S3.ServerSideEncryption = "aws:kms"
+
+	api.On("PutObject", mock.Anything, mock.Anything).Return(nil, errors.New("KMS.DisabledException")).Once()
+	err := s.checkKMSKey(context.Background())
+	require.ErrorIs(t, err, ErrKMSKeyInaccessible)
+	assert.Contains(t, err.Error(), "encrypting")
+}
@@ -0,0 +1,58 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// maxCopySize is the largest object S3 copies in a single CopyObject request.
+const maxCopySize = 5 << 30
+
+// ErrObjectTooLarge is returned when an object is too large to change its storage class with a single copy.
+var ErrObjectTooLarge = errors.New("object too large to copy in one request")
+
+// SetStorageClass moves the object at key to class by copying it onto itself. Objects already in
+// class are left alone, so transitions are cheap to retry.
+func (s *S3) SetStorageClass(ctx context.Context, key, class string) error {
+	head, err := s.api.HeadObject(ctx, &awsS3.HeadObjectInput{
+		Bucket: aws.String(s.cfg.S3.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return err
+	}
+
+	// S3 omits the storage class for STANDARD objects.
+	current := head.StorageClass
+	if current == "" {
+		current = types.StorageClassStandard
+	}
+	if string(current) == class {
+		return nil
+	}
+
+	if aws.ToInt64(head.ContentLength) > maxCopySize {
+		return fmt.Errorf("%w: %s; set backup.volume-size-mb below 5120 to split archives", ErrObjectTooLarge, key)
+	}
+
+	_, err = s.api.CopyObject(ctx, &awsS3.CopyObjectInput{
+		Bucket:            aws.String(s.cfg.S3.Bucket),
+		Key:               aws.String(key),
+		CopySource:        aws.String((&url.URL{Path: s.cfg.S3.Bucket + "/" + key}).EscapedPath()),
+		StorageClass:      types.StorageClass(class),
+		MetadataDirective: types.MetadataDirectiveCopy,
+		TaggingDirective:  types.TaggingDirectiveCopy,
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+
+		// A copy is encrypted as a new object would be, not as its source was.
+		ServerSideEncryption: types.ServerSideEncryption(s.cfg.S3.ServerSideEncryption),
+		SSEKMSKeyId:          s.kmsKeyID(),
+	})
+	return err
+}
@@ -0,0 +1,42 @@
+package s3
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3_SetStorageClass(t *testing.T) {
+	s, _, api, _ := newTestS3(t)
+
+	api.On("HeadObject", mock.Anything, mock.Anything).Return(&awsS3.HeadObjectOutput{ContentLength: aws.Int64(5)}, nil).Once()
+	api.On("CopyObject", mock.Anything, mock.MatchedBy(func(in *awsS3.CopyObjectInput) bool {
+		return aws.ToString(in.CopySource) == "bucket/prefix/instance/20250101000000/backup%20file.zip" &&
+			in.StorageClass == types.StorageClassGlacierIr
+	})).Return(&awsS3.CopyObjectOutput{}, nil).Once()
+
+	require.NoError(t, s.SetStorageClass(context.Background(), "prefix/instance/20250101000000/backup file.zip", "GLACIER_IR"))
+}
+
+func TestS3_SetStorageClass_AlreadyInClass(t *testing.T) {
+	s, _, api, _ := newTestS3(t)
+
+	api.On("HeadObject", mock.Anything, mock.Anything).
+		Return(&awsS3.HeadObjectOutput{ContentLength: aws.Int64(5), StorageClass: types.StorageClassGlacierIr}, nil).Once()
+
+	require.NoError(t, s.SetStorageClass(context.Background(), "prefix/instance/20250101000000/backup.zip", "GLACIER_IR"))
+}
+
+func TestS3_SetStorageClass_TooLarge(t *testing.T) {
+	s, _, api, _ := newTestS3(t)
+
+	api.On("HeadObject", mock.Anything, mock.Anything).Return(&awsS3.HeadObjectOutput{ContentLength: aws.Int64(maxCopySize + 1)}, nil).Once()
+
+	err := s.SetStorageClass(context.Background(), "prefix/instance/20250101000000/backup.zip", "GLACIER_IR")
+	require.ErrorIs(t, err, ErrObjectTooLarge)
+}
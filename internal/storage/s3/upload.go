@@ -0,0 +1,275 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/hibare/stashly/internal/constants"
+)
+
+const (
+	// maxPutSize is the largest object S3 accepts in a single PutObject request.
+	maxPutSize = 5 << 30
+
+	// minPartSize is the smallest part S3 accepts, other than the last one.
+	minPartSize = 5 * mebibyte
+
+	// maxParts is the most parts a multipart upload can have.
+	maxParts = 10000
+)
+
+// uploadedPart is a part S3 has acknowledged.
+type uploadedPart struct {
+	Number         int32  `json:"number"`
+	ETag           string `json:"etag"`
+	ChecksumSHA256 string `json:"checksum_sha256"`
+}
+
+// uploadState is persisted next to a file being uploaded in parts so an interrupted upload can resume.
+type uploadState struct {
+	Key      string         `json:"key"`
+	UploadID string         `json:"upload_id"`
+	Size     int64          `json:"size"`
+	ModTime  time.Time      `json:"mod_time"`
+	PartSize int64          `json:"part_size"`
+	Parts    []uploadedPart `json:"parts"`
+}
+
+// matches reports whether a saved state belongs to the same file, key and part layout.
+func (st *uploadState) matches(key string, info os.FileInfo, partSize int64) bool {
+	return st.Key == key && st.Size == info.Size() && st.ModTime.Equal(info.ModTime()) && st.PartSize == partSize
+}
+
+func loadUploadState(path string) *uploadState {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var st uploadState
+	if err := json.Unmarshal(data, &st); err != nil || st.UploadID == "" {
+		return nil
+	}
+	return &st
+}
+
+func (st *uploadState) save(path string) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// partSize returns the configured multipart part size in bytes, within the sizes S3 accepts.
+func (s *S3) partSize() int64 {
+	size := s.cfg.S3.PartSizeMB * mebibyte
+	if size <= 0 {
+		size = constants.DefaultUploadPartSizeMB * mebibyte
+	}
+	return min(max(size, minPartSize), maxPutSize)
+}
+
+// uploadPartSize returns the part size for a file of size bytes: the configured one, grown so the
+// file fits in maxParts parts.
+func (s *S3) uploadPartSize(size int64) int64 {
+	return max(s.partSize(), (size+maxParts-1)/maxParts)
+}
+
+// uploadMultipart uploads a large local file to key in parts, s3.upload-concurrency at a time. Each
+// part carries its own SHA-256 for S3 to verify; a whole-object checksum can't be sent with multipart
+// uploads.
+//
+// Progress is checkpointed next to localPath, so uploading the same file again, in this process or
+// after a restart, resumes the upload and only sends the parts S3 doesn't have yet. Failed uploads
+// are therefore left for the retry rather than aborted.
+func (s *S3) uploadMultipart(ctx context.Context, key, localPath string, info os.FileInfo) error {
+	statePath := localPath + ".upload.json"
+	partSize := s.uploadPartSize(info.Size())
+
+	state := loadUploadState(statePath)
+	if state != nil && !state.matches(key, info, partSize) {
+		// The file or settings changed since; its parts are of no use.
+		s.abortUpload(ctx, state)
+		state = nil
+	}
+	if state != nil {
+		slog.InfoContext(ctx, "Resuming interrupted upload", "key", key, "file", localPath, "parts_done", len(state.Parts))
+		err := s.uploadParts(ctx, state, statePath, localPath)
+		var noSuchUpload *types.NoSuchUpload
+		if !errors.As(err, &noSuchUpload) {
+			return err
+		}
+		// Aborted or expired, for instance by a bucket lifecycle rule; start over.
+		slog.WarnContext(ctx, "Interrupted upload no longer exists, starting over", "key", key, "upload_id", state.UploadID)
+	}
+
+	out, err := s.api.CreateMultipartUpload(ctx, &awsS3.CreateMultipartUploadInput{
+		Bucket:            aws.String(s.cfg.S3.Bucket),
+		Key:               aws.String(key),
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+		Tagging:           objectTagging(s.cfg.S3.Tags),
+
+		ServerSideEncryption: types.ServerSideEncryption(s.cfg.S3.ServerSideEncryption),
+		SSEKMSKeyId:          s.kmsKeyID(),
+	})
+	if err != nil {
+		return err
+	}
+	state = &uploadState{
+		Key:      key,
+		UploadID: aws.ToString(out.UploadId),
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+		PartSize: partSize,
+	}
+	if err := state.save(statePath); err != nil {
+		slog.WarnContext(ctx, "Failed to checkpoint upload", "file", statePath, "error", err)
+	}
+	return s.uploadParts(ctx, state, statePath, localPath)
+}
+
+// uploadParts uploads the parts of localPath missing from state, checkpointing each one, then
+// completes the upload and removes the checkpoint.
+func (s *S3) uploadParts(ctx context.Context, state *uploadState, statePath, localPath string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	concurrency := s.cfg.S3.UploadConcurrency
+	if concurrency <= 0 {
+		concurrency = constants.DefaultUploadConcurrency
+	}
+
+	done := make(map[int32]bool, len(state.Parts))
+	for _, p := range state.Parts {
+		done[p.Number] = true
+	}
+
+	pending := make(chan int32)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	for range concurrency {
+		wg.Go(func() {
+			for number := range pending {
+				part, pErr := s.uploadPart(ctx, state, f, number)
+				mu.Lock()
+				if pErr != nil {
+					if firstErr == nil {
+						firstErr = pErr
+						cancel()
+					}
+					mu.Unlock()
+					continue
+				}
+				state.Parts = append(state.Parts, part)
+				if sErr := state.save(statePath); sErr != nil {
+					slog.WarnContext(ctx, "Failed to checkpoint upload", "file", statePath, "error", sErr)
+				}
+				mu.Unlock()
+			}
+		})
+	}
+
+	parts := int32(partCount(state.Size, state.PartSize)) //nolint:gosec // reason: at most maxParts
+	slog.DebugContext(ctx, "Uploading file to S3 in parts", "file", localPath, "bucket", s.cfg.S3.Bucket, "key", state.Key,
+		"part_size", state.PartSize, "parts", parts, "concurrency", concurrency)
+	for number := int32(1); number <= parts; number++ {
+		if done[number] {
+			continue
+		}
+		select {
+		case pending <- number:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(pending)
+	wg.Wait()
+
+	if firstErr != nil {
+		return fmt.Errorf("error uploading %s: %w", state.Key, firstErr)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	completed := make([]types.CompletedPart, 0, len(state.Parts))
+	for _, p := range state.Parts {
+		part := types.CompletedPart{PartNumber: aws.Int32(p.Number), ETag: aws.String(p.ETag)}
+		if p.ChecksumSHA256 != "" {
+			part.ChecksumSHA256 = aws.String(p.ChecksumSHA256)
+		}
+		completed = append(completed, part)
+	}
+	slices.SortFunc(completed, func(a, b types.CompletedPart) int {
+		return int(aws.ToInt32(a.PartNumber) - aws.ToInt32(b.PartNumber))
+	})
+
+	if _, err := s.api.CompleteMultipartUpload(ctx, &awsS3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.cfg.S3.Bucket),
+		Key:             aws.String(state.Key),
+		UploadId:        aws.String(state.UploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	}); err != nil {
+		return err
+	}
+	if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+		slog.WarnContext(ctx, "Failed to remove upload checkpoint", "file", statePath, "error", err)
+	}
+	return nil
+}
+
+// uploadPart uploads part number of f.
+func (s *S3) uploadPart(ctx context.Context, state *uploadState, f *os.File, number int32) (uploadedPart, error) {
+	start := int64(number-1) * state.PartSize
+	length := min(state.PartSize, state.Size-start)
+
+	out, err := s.api.UploadPart(ctx, &awsS3.UploadPartInput{
+		Bucket:            aws.String(s.cfg.S3.Bucket),
+		Key:               aws.String(state.Key),
+		UploadId:          aws.String(state.UploadID),
+		PartNumber:        aws.Int32(number),
+		Body:              io.NewSectionReader(f, start, length),
+		ContentLength:     aws.Int64(length),
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+	})
+	if err != nil {
+		return uploadedPart{}, err
+	}
+	return uploadedPart{Number: number, ETag: aws.ToString(out.ETag), ChecksumSHA256: aws.ToString(out.ChecksumSHA256)}, nil
+}
+
+// abortUpload aborts a stale upload, best effort, so its parts stop costing storage.
+func (s *S3) abortUpload(ctx context.Context, state *uploadState) {
+	if _, err := s.api.AbortMultipartUpload(ctx, &awsS3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.cfg.S3.Bucket),
+		Key:      aws.String(state.Key),
+		UploadId: aws.String(state.UploadID),
+	}); err != nil {
+		slog.WarnContext(ctx, "Failed to abort stale upload", "key", state.Key, "upload_id", state.UploadID, "error", err)
+	}
+}
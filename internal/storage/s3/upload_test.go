@@ -0,0 +1,159 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// writeLargeFile writes a file of size bytes and returns its path.
+func writeLargeFile(t *testing.T, size int64) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "backup.zip.gpg")
+	require.NoError(t, os.WriteFile(p, make([]byte, size), 0o600))
+	return p
+}
+
+func TestS3_PartSize(t *testing.T) {
+	s := &S3{cfg: &config.Config{}}
+	assert.Equal(t, int64(constants.DefaultUploadPartSizeMB*mebibyte), s.partSize())
+
+	s.cfg.S3.PartSizeMB = 1
+	assert.Equal(t, int64(minPartSize), s.partSize())
+
+	s.cfg.S3.PartSizeMB = 10 << 10
+	assert.Equal(t, int64(maxPutSize), s.partSize())
+}
+
+func TestS3_Upload_Multipart(t *testing.T) {
+	s, client, api, _ := newTestS3(t)
+	s.cfg.S3.PartSizeMB, s.cfg.S3.UploadConcurrency = 5, 2
+	s.cfg.S3.ServerSideEncryption = "AES256"
+	localPath := writeLargeFile(t, 11*mebibyte)
+
+	client.On("BuildKey", []string{"prefix", "instance", "20250101000000"}).Return("prefix/instance/20250101000000/")
+	api.On("CreateMultipartUpload", mock.Anything, mock.MatchedBy(func(in *awsS3.CreateMultipartUploadInput) bool {
+		return aws.ToString(in.Key) == "prefix/instance/20250101000000/backup.zip.gpg" &&
+			in.ChecksumAlgorithm == types.ChecksumAlgorithmSha256 &&
+			in.ServerSideEncryption == types.ServerSideEncryptionAes256
+	})).Return(&awsS3.CreateMultipartUploadOutput{UploadId: aws.String("upload")}, nil).Once()
+	api.On("UploadPart", mock.Anything, mock.MatchedBy(func(in *awsS3.UploadPartInput) bool {
+		return aws.ToString(in.UploadId) == "upload"
+	})).Return(&awsS3.UploadPartOutput{ETag: aws.String("etag")}, nil).Times(3)
+	api.On("CompleteMultipartUpload", mock.Anything, mock.MatchedBy(func(in *awsS3.CompleteMultipartUploadInput) bool {
+		return len(in.MultipartUpload.Parts) == 3 && in.ChecksumSHA256 == nil
+	})).Return(&awsS3.CompleteMultipartUploadOutput{}, nil).Once()
+
+	key, err := s.Upload(context.Background(), "20250101000000", localPath)
+	require.NoError(t, err)
+	assert.Equal(t, "prefix/instance/20250101000000/backup.zip.gpg", key)
+}
+
+// partNumber matches UploadPart calls for part n.
+func partNumber(n int32) any {
+	return mock.MatchedBy(func(in *awsS3.UploadPartInput) bool { return aws.ToInt32(in.PartNumber) == n })
+}
+
+func TestS3_UploadPartSize(t *testing.T) {
+	s := &S3{cfg: &config.Config{}}
+	assert.Equal(t, s.partSize(), s.uploadPartSize(mebibyte))
+	assert.Equal(t, int64(100*mebibyte), s.uploadPartSize(maxParts*100*mebibyte))
+}
+
+func TestS3_Upload_MultipartResumes(t *testing.T) {
+	s, client, api, _ := newTestS3(t)
+	s.cfg.S3.PartSizeMB, s.cfg.S3.UploadConcurrency = 5, 1
+	localPath := writeLargeFile(t, 11*mebibyte)
+	statePath := localPath + ".upload.json"
+
+	client.On("BuildKey", []string{"prefix", "instance", "20250101000000"}).Return("prefix/instance/20250101000000/")
+	api.On("CreateMultipartUpload", mock.Anything, mock.Anything).Return(&awsS3.CreateMultipartUploadOutput{UploadId: aws.String("upload")}, nil).Once()
+	api.On("UploadPart", mock.Anything, partNumber(1)).Return(&awsS3.UploadPartOutput{ETag: aws.String("etag1")}, nil).Once()
+	api.On("UploadPart", mock.Anything, partNumber(2)).Return(nil, errors.New("connection reset")).Once()
+
+	_, err := s.Upload(context.Background(), "20250101000000", localPath)
+	require.Error(t, err)
+
+	// The upload is kept, not aborted, with the part that made it checkpointed.
+	api.AssertNotCalled(t, "AbortMultipartUpload", mock.Anything, mock.Anything)
+	state := loadUploadState(statePath)
+	require.NotNil(t, state)
+	assert.Equal(t, "upload", state.UploadID)
+	assert.Equal(t, []uploadedPart{{Number: 1, ETag: "etag1"}}, state.Parts)
+
+	// Retrying sends only the missing parts to the same upload.
+	api.On("UploadPart", mock.Anything, partNumber(2)).Return(&awsS3.UploadPartOutput{ETag: aws.String("etag2")}, nil).Once()
+	api.On("UploadPart", mock.Anything, partNumber(3)).Return(&awsS3.UploadPartOutput{ETag: aws.String("etag3")}, nil).Once()
+	api.On("CompleteMultipartUpload", mock.Anything, mock.MatchedBy(func(in *awsS3.CompleteMultipartUploadInput) bool {
+		parts := in.MultipartUpload.Parts
+		return aws.ToString(in.UploadId) == "upload" && len(parts) == 3 &&
+			aws.ToString(parts[0].ETag) == "etag1" && aws.ToString(parts[2].ETag) == "etag3"
+	})).Return(&awsS3.CompleteMultipartUploadOutput{}, nil).Once()
+
+	_, err = s.Upload(context.Background(), "20250101000000", localPath)
+	require.NoError(t, err)
+	assert.NoFileExists(t, statePath)
+}
+
+func TestS3_Upload_MultipartStaleCheckpoint(t *testing.T) {
+	s, client, api, _ := newTestS3(t)
+	s.cfg.S3.PartSizeMB, s.cfg.S3.UploadConcurrency = 5, 1
+	localPath := writeLargeFile(t, 6*mebibyte)
+	info, err := os.Stat(localPath)
+	require.NoError(t, err)
+
+	// Checkpointed with a different part size, so the old upload is aborted and a new one started.
+	stale := &uploadState{Key: "prefix/instance/20250101000000/backup.zip.gpg", UploadID: "old", Size: info.Size(), ModTime: info.ModTime(), PartSize: 8 * mebibyte}
+	require.NoError(t, stale.save(localPath+".upload.json"))
+
+	client.On("BuildKey", []string{"prefix", "instance", "20250101000000"}).Return("prefix/instance/20250101000000/")
+	api.On("AbortMultipartUpload", mock.Anything, mock.MatchedBy(func(in *awsS3.AbortMultipartUploadInput) bool {
+		return aws.ToString(in.UploadId) == "old"
+	})).Return(&awsS3.AbortMultipartUploadOutput{}, nil).Once()
+	api.On("CreateMultipartUpload", mock.Anything, mock.Anything).Return(&awsS3.CreateMultipartUploadOutput{UploadId: aws.String("new")}, nil).Once()
+	api.On("UploadPart", mock.Anything, mock.Anything).Return(&awsS3.UploadPartOutput{ETag: aws.String("etag")}, nil).Times(2)
+	api.On("CompleteMultipartUpload", mock.Anything, mock.MatchedBy(func(in *awsS3.CompleteMultipartUploadInput) bool {
+		return aws.ToString(in.UploadId) == "new"
+	})).Return(&awsS3.CompleteMultipartUploadOutput{}, nil).Once()
+
+	_, err = s.Upload(context.Background(), "20250101000000", localPath)
+	require.NoError(t, err)
+}
+
+func TestS3_Upload_MultipartExpiredCheckpoint(t *testing.T) {
+	s, client, api, _ := newTestS3(t)
+	s.cfg.S3.PartSizeMB, s.cfg.S3.UploadConcurrency = 5, 1
+	localPath := writeLargeFile(t, 6*mebibyte)
+	info, err := os.Stat(localPath)
+	require.NoError(t, err)
+
+	expired := &uploadState{
+		Key: "prefix/instance/20250101000000/backup.zip.gpg", UploadID: "old", Size: info.Size(), ModTime: info.ModTime(), PartSize: 5 * mebibyte,
+		Parts: []uploadedPart{{Number: 1, ETag: "etag1"}},
+	}
+	require.NoError(t, expired.save(localPath+".upload.json"))
+
+	client.On("BuildKey", []string{"prefix", "instance", "20250101000000"}).Return("prefix/instance/20250101000000/")
+	api.On("UploadPart", mock.Anything, mock.MatchedBy(func(in *awsS3.UploadPartInput) bool {
+		return aws.ToString(in.UploadId) == "old"
+	})).Return(nil, &types.NoSuchUpload{}).Once()
+	api.On("CreateMultipartUpload", mock.Anything, mock.Anything).Return(&awsS3.CreateMultipartUploadOutput{UploadId: aws.String("new")}, nil).Once()
+	api.On("UploadPart", mock.Anything, mock.MatchedBy(func(in *awsS3.UploadPartInput) bool {
+		return aws.ToString(in.UploadId) == "new"
+	})).Return(&awsS3.UploadPartOutput{ETag: aws.String("etag")}, nil).Times(2)
+	api.On("CompleteMultipartUpload", mock.Anything, mock.Anything).Return(&awsS3.CompleteMultipartUploadOutput{}, nil).Once()
+
+	_, err = s.Upload(context.Background(), "20250101000000", localPath)
+	require.NoError(t, err)
+}
@@ -1,7 +1,118 @@
 // Package storage defines the interface for various storage backends.
 package storage
 
-import "context"
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ListOptions narrows and pages a ListPage call.
+type ListOptions struct {
+	// Prefix keeps only backups whose timestamp starts with it (e.g. "202401").
+	Prefix string
+
+	// StartAfter keeps only backups whose timestamp sorts after it.
+	StartAfter string
+
+	// Limit caps the keys returned per page; 0 uses the backend's maximum.
+	Limit int
+
+	// Token continues a listing from the previous page's NextToken.
+	Token string
+}
+
+// Page is one page of a listing.
+type Page struct {
+	Keys []string
+
+	// NextToken continues the listing; empty on the last page.
+	NextToken string
+}
+
+// Usage is the space the backups under the configured prefix take up in storage.
+type Usage struct {
+	// Bytes is the total size of all backups.
+	Bytes int64
+
+	// Count is the number of backups.
+	Count int
+
+	// Backups is the size of each backup, oldest first.
+	Backups []BackupUsage
+}
+
+// BackupUsage is the space one backup takes up in storage.
+type BackupUsage struct {
+	Timestamp string
+	Bytes     int64
+	Files     int
+}
+
+// UsageOf sums the sizes of the files under base, keyed by their keys, per backup. Files directly
+// under base, outside any backup, aren't counted.
+func UsageOf(base string, sizes map[string]int64) Usage {
+	backups := map[string]*BackupUsage{}
+	for key, size := range sizes {
+		timestamp, _, ok := strings.Cut(strings.TrimPrefix(key, base), "/")
+		if !ok || timestamp == "" {
+			continue
+		}
+		b, ok := backups[timestamp]
+		if !ok {
+			b = &BackupUsage{Timestamp: timestamp}
+			backups[timestamp] = b
+		}
+		b.Bytes += size
+		b.Files++
+	}
+
+	usage := Usage{Count: len(backups), Backups: make([]BackupUsage, 0, len(backups))}
+	for _, b := range backups {
+		usage.Bytes += b.Bytes
+		usage.Backups = append(usage.Backups, *b)
+	}
+	sort.Slice(usage.Backups, func(i, j int) bool { return usage.Backups[i].Timestamp < usage.Backups[j].Timestamp })
+	return usage
+}
+
+// BuildKey joins the non-empty parts with "/" and adds a trailing "/", giving the
+// <prefix>/<instance-id>/<timestamp>/ layout shared by all backends.
+func BuildKey(parts ...string) string {
+	nonEmpty := []string{}
+	for _, p := range parts {
+		if p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	key := path.Join(nonEmpty...)
+	if !strings.HasSuffix(key, "/") {
+		key += "/"
+	}
+	return key
+}
+
+// PageKeys cuts a page out of the sorted backup keys under base, for backends that can't filter or
+// page listings themselves. The token is the last key of the previous page.
+func PageKeys(keys []string, base string, opts ListOptions) Page {
+	page := Page{}
+	for _, key := range keys {
+		timestamp := strings.TrimSuffix(strings.TrimPrefix(key, base), "/")
+		if !strings.HasPrefix(timestamp, opts.Prefix) ||
+			(opts.StartAfter != "" && timestamp <= opts.StartAfter) ||
+			(opts.Token != "" && key <= opts.Token) {
+			continue
+		}
+		if opts.Limit > 0 && len(page.Keys) == opts.Limit {
+			page.NextToken = page.Keys[len(page.Keys)-1]
+			break
+		}
+		page.Keys = append(page.Keys, key)
+	}
+	return page
+}
 
 // StorageIface defines a generic storage backend used to upload and manage backups.
 // revive:disable-next-line exported
@@ -9,18 +120,55 @@ type StorageIface interface {
 	// Init prepares the storage (e.g., establishes session)
 	Init(context.Context) error
 
-	// Upload uploads a local file and returns the remote key/path
-	Upload(context.Context, string) (string, error)
+	// Upload uploads a local file into the backup at the given timestamp and returns the remote key/path
+	Upload(ctx context.Context, timestamp, localPath string) (string, error)
+
+	// Download fetches the object at key into localPath
+	Download(ctx context.Context, key, localPath string) error
 
 	// List returns keys/identifiers under configured prefix
 	List(context.Context) ([]string, error)
 
+	// ListPage returns one page of the keys List would return, filtered by opts
+	ListPage(ctx context.Context, opts ListOptions) (Page, error)
+
+	// ListFiles returns the keys of all files stored for the backup at the given timestamp
+	ListFiles(ctx context.Context, timestamp string) ([]string, error)
+
+	// Stats returns the space the backups under the configured prefix take up
+	Stats(context.Context) (Usage, error)
+
 	// Delete deletes the provided key/path from storage
 	Delete(context.Context, string) error
 
+	// SetStorageClass moves the object at key to a storage class (e.g. a colder, cheaper tier)
+	SetStorageClass(ctx context.Context, key, class string) error
+
 	// TrimPrefix trims the configured prefix from a given key, if present
 	TrimPrefix(keys []string) []string
 
 	// Name returns the name of the storage backend (e.g., "s3", "gcs")
 	Name() string
 }
+
+// ErrCopyUnsupported is returned by Copy for backends that can't copy objects server-side.
+var ErrCopyUnsupported = errors.New("storage backend can't copy objects server-side")
+
+// CopierIface is implemented by backends that copy objects within their bucket without downloading
+// them.
+// revive:disable-next-line exported
+type CopierIface interface {
+	// Copy copies the object at key, which may be outside the configured prefix, into the backup at
+	// timestamp and returns its new key
+	Copy(ctx context.Context, key, timestamp string) (string, error)
+}
+
+// Copy copies the object at key into the backup at timestamp of store server-side. It returns
+// ErrCopyUnsupported if store can't.
+func Copy(ctx context.Context, store StorageIface, key, timestamp string) (string, error) {
+	c, ok := store.(CopierIface)
+	if !ok {
+		return "", ErrCopyUnsupported
+	}
+	return c.Copy(ctx, key, timestamp)
+}
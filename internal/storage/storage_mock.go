@@ -25,12 +25,18 @@ func (_m *MockStorageIface) Name() string {
 	return _mockArgs.String(0)
 }
 
-// Upload provides a mock function with given fields: localPath
-func (_m *MockStorageIface) Upload(_ context.Context, localPath string) (string, error) {
-	_mockArgs := _m.Called(localPath)
+// Upload provides a mock function with given fields: timestamp, localPath
+func (_m *MockStorageIface) Upload(_ context.Context, timestamp, localPath string) (string, error) {
+	_mockArgs := _m.Called(timestamp, localPath)
 	return _mockArgs.String(0), _mockArgs.Error(1)
 }
 
+// Download provides a mock function with given fields: key, localPath
+func (_m *MockStorageIface) Download(_ context.Context, key, localPath string) error {
+	_mockArgs := _m.Called(key, localPath)
+	return _mockArgs.Error(0)
+}
+
 // List provides a mock function with given fields:
 func (_m *MockStorageIface) List(_ context.Context) ([]string, error) {
 	_mockArgs := _m.Called()
@@ -40,12 +46,39 @@ func (_m *MockStorageIface) List(_ context.Context) ([]string, error) {
 	return _mockArgs.Get(0).([]string), _mockArgs.Error(1)
 }
 
+// ListPage provides a mock function with given fields: opts
+func (_m *MockStorageIface) ListPage(_ context.Context, opts ListOptions) (Page, error) {
+	_mockArgs := _m.Called(opts)
+	return _mockArgs.Get(0).(Page), _mockArgs.Error(1) //nolint:errcheck // reason: type assertion on mock, error not possible/needed
+}
+
+// ListFiles provides a mock function with given fields: timestamp
+func (_m *MockStorageIface) ListFiles(_ context.Context, timestamp string) ([]string, error) {
+	_mockArgs := _m.Called(timestamp)
+	if _mockArgs.Get(0) == nil {
+		return nil, _mockArgs.Error(1)
+	}
+	return _mockArgs.Get(0).([]string), _mockArgs.Error(1)
+}
+
 // Delete provides a mock function with given fields: key
 func (_m *MockStorageIface) Delete(_ context.Context, key string) error {
 	_mockArgs := _m.Called(key)
 	return _mockArgs.Error(0)
 }
 
+// Stats provides a mock function with given fields:
+func (_m *MockStorageIface) Stats(_ context.Context) (Usage, error) {
+	_mockArgs := _m.Called()
+	return _mockArgs.Get(0).(Usage), _mockArgs.Error(1) //nolint:errcheck // reason: type assertion on mock, error not possible/needed
+}
+
+// SetStorageClass provides a mock function with given fields: key, class
+func (_m *MockStorageIface) SetStorageClass(_ context.Context, key, class string) error {
+	_mockArgs := _m.Called(key, class)
+	return _mockArgs.Error(0)
+}
+
 // TrimPrefix provides a mock function with given fields: keys
 func (_m *MockStorageIface) TrimPrefix(keys []string) []string {
 	_mockArgs := _m.Called(keys)
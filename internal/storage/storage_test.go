@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsageOf(t *testing.T) {
+	usage := UsageOf("prefix/instance/", map[string]int64{
+		"prefix/instance/20250102000000/postgres-plain.zip": 300,
+		"prefix/instance/20250101000000/postgres-plain.zip": 100,
+		"prefix/instance/20250101000000/manifest.json":      20,
+		"prefix/instance/stray":                             5,
+	})
+
+	assert.Equal(t, Usage{
+		Bytes: 420,
+		Count: 2,
+		Backups: []BackupUsage{
+			{Timestamp: "20250101000000", Bytes: 120, Files: 2},
+			{Timestamp: "20250102000000", Bytes: 300, Files: 1},
+		},
+	}, usage)
+	assert.Equal(t, Usage{Backups: []BackupUsage{}}, UsageOf("prefix/instance/", nil))
+}
@@ -0,0 +1,417 @@
+// Package webdav provides an implementation of storage interface for WebDAV servers such as Nextcloud.
+//
+// Backups are stored as collections (directories) below the configured URL, following the same
+// <prefix>/<instance-id>/<timestamp>/ layout as the object storage backends.
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/httpclient"
+	"github.com/hibare/stashly/internal/storage"
+)
+
+// methodPropfind and methodMkcol are the WebDAV methods Stashly uses on top of plain HTTP.
+const (
+	methodPropfind = "PROPFIND"
+	methodMkcol    = "MKCOL"
+)
+
+// propfindBody asks for the resource type, which tells collections from files, and the size of files.
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/></d:prop></d:propfind>`
+
+var (
+	// ErrStorageClassUnsupported is returned when tiering is configured, since WebDAV has no storage classes.
+	ErrStorageClassUnsupported = errors.New("webdav does not support storage classes")
+
+	// ErrNoURL is returned when webdav.url is not set.
+	ErrNoURL = errors.New("webdav.url is not set")
+)
+
+// StatusError is an unexpected response from the WebDAV server.
+type StatusError struct {
+	Method     string
+	Path       string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webdav: %s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// HTTPStatusCode returns the response's status code, used to tell transient errors apart.
+func (e *StatusError) HTTPStatusCode() int {
+	return e.StatusCode
+}
+
+// multistatus is the subset of a PROPFIND response Stashly uses.
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ContentLength int64 `xml:"getcontentlength"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// entry is a member of a collection, named by its key relative to the base URL.
+type entry struct {
+	key        string
+	collection bool
+	size       int64
+}
+
+// WebDAV implements the StorageIface for WebDAV servers.
+type WebDAV struct {
+	cfg    *config.Config
+	client *http.Client
+	base   *url.URL
+}
+
+// Init parses the base URL and prepares the HTTP client.
+func (w *WebDAV) Init(_ context.Context) error {
+	if w.cfg.WebDAV.URL == "" {
+		return ErrNoURL
+	}
+	base, err := url.Parse(strings.TrimSuffix(w.cfg.WebDAV.URL, "/") + "/")
+	if err != nil {
+		return fmt.Errorf("error parsing webdav.url: %w", err)
+	}
+
+	transport, err := httpclient.NewTransport(w.cfg)
+	if err != nil {
+		return err
+	}
+	w.base = base
+	w.client = &http.Client{Transport: transport}
+	return nil
+}
+
+// Name returns the name of the storage backend (e.g., "webdav").
+func (w *WebDAV) Name() string {
+	if u, err := url.Parse(w.cfg.WebDAV.URL); err == nil && u.Host != "" {
+		return fmt.Sprintf("webdav (%s)", u.Host)
+	}
+	return "webdav"
+}
+
+// basePrefix returns the prefix all backups of this instance are stored under, ending in "/".
+func (w *WebDAV) basePrefix() string {
+	return storage.BuildKey(w.cfg.WebDAV.Prefix, w.cfg.App.InstanceID)
+}
+
+// resolve returns the URL of key below the base URL.
+func (w *WebDAV) resolve(key string) string {
+	return w.base.JoinPath(strings.Split(key, "/")...).String()
+}
+
+// newRequest builds a request for key with the configured credentials.
+func (w *WebDAV) newRequest(ctx context.Context, method, key string, body io.Reader, header http.Header) (*http.Request, error) {
+	target := w.resolve(key)
+	if strings.HasSuffix(key, "/") && !strings.HasSuffix(target, "/") {
+		target += "/"
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	switch {
+	case w.cfg.WebDAV.Token != "":
+		req.Header.Set("Authorization", "Bearer "+w.cfg.WebDAV.Token)
+	case w.cfg.WebDAV.Username != "":
+		req.SetBasicAuth(w.cfg.WebDAV.Username, w.cfg.WebDAV.Password)
+	}
+	return req, nil
+}
+
+// request sends a request for key. The caller closes the body.
+func (w *WebDAV) request(ctx context.Context, method, key string, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := w.newRequest(ctx, method, key, body, header)
+	if err != nil {
+		return nil, err
+	}
+	return w.client.Do(req)
+}
+
+// do sends a request and fails unless the response status is one of ok.
+func (w *WebDAV) do(ctx context.Context, method, key string, body io.Reader, header http.Header, ok ...int) error {
+	resp, err := w.request(ctx, method, key, body, header)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	for _, code := range ok {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+	return &StatusError{Method: method, Path: key, StatusCode: resp.StatusCode}
+}
+
+// mkcolAll creates the collection dir and its parents. Existing collections answer 405, which is fine.
+func (w *WebDAV) mkcolAll(ctx context.Context, dir string) error {
+	parts := strings.Split(strings.Trim(dir, "/"), "/")
+	for i := range parts {
+		key := strings.Join(parts[:i+1], "/") + "/"
+		if err := w.do(ctx, methodMkcol, key, nil, nil, http.StatusCreated, http.StatusMethodNotAllowed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// members lists the direct members of the collection dir. A missing collection has none.
+func (w *WebDAV) members(ctx context.Context, dir string) ([]entry, error) {
+	header := http.Header{"Depth": {"1"}, "Content-Type": {"application/xml; charset=utf-8"}}
+	resp, err := w.request(ctx, methodPropfind, dir, strings.NewReader(propfindBody), header)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch resp.StatusCode {
+	case http.StatusMultiStatus:
+	case http.StatusNotFound:
+		return []entry{}, nil
+	default:
+		return nil, &StatusError{Method: methodPropfind, Path: dir, StatusCode: resp.StatusCode}
+	}
+
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("error parsing PROPFIND response for %s: %w", dir, err)
+	}
+
+	entries := []entry{}
+	for _, r := range ms.Responses {
+		key, ok := w.keyOf(r.Href)
+		if !ok || strings.Trim(key, "/") == strings.Trim(dir, "/") {
+			continue
+		}
+		collection := false
+		var size int64
+		for _, ps := range r.Propstat {
+			if ps.Prop.ResourceType.Collection != nil {
+				collection = true
+			}
+			size = max(size, ps.Prop.ContentLength)
+		}
+		key = strings.TrimSuffix(key, "/")
+		if collection {
+			key += "/"
+		}
+		entries = append(entries, entry{key: key, collection: collection, size: size})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	return entries, nil
+}
+
+// keyOf turns a PROPFIND href, an absolute URL or path, into a key relative to the base URL.
+func (w *WebDAV) keyOf(href string) (string, bool) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	p, err := url.PathUnescape(u.EscapedPath())
+	if err != nil {
+		return "", false
+	}
+	return strings.CutPrefix(p, w.base.Path)
+}
+
+// Upload uploads a local file into the backup at timestamp and returns the remote key.
+func (w *WebDAV) Upload(ctx context.Context, timestamp, localPath string) (string, error) {
+	dir := storage.BuildKey(w.cfg.WebDAV.Prefix, w.cfg.App.InstanceID, timestamp)
+	key := dir + path.Base(localPath)
+
+	if err := w.mkcolAll(ctx, dir); err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	slog.DebugContext(ctx, "Uploading file to WebDAV", "file", localPath, "key", key, "size", info.Size())
+	req, err := w.newRequest(ctx, http.MethodPut, key, f, http.Header{"Content-Type": {"application/octet-stream"}})
+	if err != nil {
+		return "", err
+	}
+	// Some servers reject chunked uploads; send the length up front.
+	req.ContentLength = info.Size()
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusNoContent, http.StatusOK:
+		return key, nil
+	default:
+		return "", &StatusError{Method: http.MethodPut, Path: key, StatusCode: resp.StatusCode}
+	}
+}
+
+// Download fetches the file at key into localPath.
+func (w *WebDAV) Download(ctx context.Context, key, localPath string) error {
+	resp, err := w.request(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{Method: http.MethodGet, Path: key, StatusCode: resp.StatusCode}
+	}
+
+	f, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// List returns the backup collections under the instance prefix.
+func (w *WebDAV) List(ctx context.Context) ([]string, error) {
+	return w.backups(ctx)
+}
+
+// backups returns the keys of the backup collections under the instance prefix, sorted.
+func (w *WebDAV) backups(ctx context.Context) ([]string, error) {
+	entries, err := w.members(ctx, w.basePrefix())
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for _, e := range entries {
+		if e.collection {
+			keys = append(keys, e.key)
+		}
+	}
+	return keys, nil
+}
+
+// ListPage returns one page of the backups under the instance prefix, narrowed by opts. WebDAV
+// can't filter or page listings, so the whole collection is listed and the page cut from it.
+func (w *WebDAV) ListPage(ctx context.Context, opts storage.ListOptions) (storage.Page, error) {
+	keys, err := w.backups(ctx)
+	if err != nil {
+		return storage.Page{}, err
+	}
+
+	return storage.PageKeys(keys, w.basePrefix(), opts), nil
+}
+
+// ListFiles returns the keys of all files stored under the backup at the given timestamp.
+func (w *WebDAV) ListFiles(ctx context.Context, timestamp string) ([]string, error) {
+	entries, err := w.members(ctx, storage.BuildKey(w.cfg.WebDAV.Prefix, w.cfg.App.InstanceID, timestamp))
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for _, e := range entries {
+		if !e.collection {
+			keys = append(keys, e.key)
+		}
+	}
+	return keys, nil
+}
+
+// Stats sums the sizes of the files in each backup collection under the instance prefix. WebDAV
+// servers often refuse to list a whole tree at once, so every backup is listed on its own.
+func (w *WebDAV) Stats(ctx context.Context) (storage.Usage, error) {
+	dirs, err := w.backups(ctx)
+	if err != nil {
+		return storage.Usage{}, err
+	}
+
+	sizes := map[string]int64{}
+	for _, dir := range dirs {
+		entries, err := w.members(ctx, dir)
+		if err != nil {
+			return storage.Usage{}, err
+		}
+		for _, e := range entries {
+			if !e.collection {
+				sizes[e.key] = e.size
+			}
+		}
+	}
+	return storage.UsageOf(w.basePrefix(), sizes), nil
+}
+
+// Delete deletes the backup at timestamp. WebDAV deletes a collection with everything in it.
+func (w *WebDAV) Delete(ctx context.Context, timestamp string) error {
+	dir := storage.BuildKey(w.cfg.WebDAV.Prefix, w.cfg.App.InstanceID, timestamp)
+	return w.do(ctx, http.MethodDelete, dir, nil, nil, http.StatusNoContent, http.StatusOK, http.StatusNotFound)
+}
+
+// SetStorageClass is not supported by WebDAV.
+func (w *WebDAV) SetStorageClass(_ context.Context, _, _ string) error {
+	return ErrStorageClassUnsupported
+}
+
+// TrimPrefix trims the instance prefix and trailing "/" from keys, leaving backup timestamps.
+func (w *WebDAV) TrimPrefix(keys []string) []string {
+	prefix := w.basePrefix()
+	trimmed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		trimmed = append(trimmed, strings.TrimSuffix(strings.TrimPrefix(key, prefix), "/"))
+	}
+	return trimmed
+}
+
+// NewWebDAVStorage creates a new WebDAV storage instance with the provided configuration.
+func NewWebDAVStorage(cfg *config.Config) *WebDAV {
+	return &WebDAV{cfg: cfg}
+}
+
+func init() {
+	storage.Register("webdav", storage.Registration{
+		New:   func(cfg *config.Config) storage.StorageIface { return NewWebDAVStorage(cfg) },
+		Retry: func(cfg *config.Config) config.RetryConfig { return cfg.WebDAV.Retry },
+	})
+}
@@ -0,0 +1,144 @@
+package webdav
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	xwebdav "golang.org/x/net/webdav"
+)
+
+// newTestWebDAV starts an in-memory WebDAV server below /dav/ that requires basic auth.
+func newTestWebDAV(t *testing.T) *WebDAV {
+	t.Helper()
+	handler := &xwebdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: xwebdav.NewMemFS(),
+		LockSystem: xwebdav.NewMemLS(),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "stashly" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := &config.Config{
+		App:    config.AppConfig{InstanceID: "instance"},
+		WebDAV: config.WebDAVConfig{URL: srv.URL + "/dav", Prefix: "backups", Username: "stashly", Password: "secret"},
+	}
+	w := NewWebDAVStorage(cfg)
+	require.NoError(t, w.Init(context.Background()))
+	return w
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestWebDAV_UploadDownloadDelete(t *testing.T) {
+	ctx := context.Background()
+	w := newTestWebDAV(t)
+
+	key, err := w.Upload(ctx, "20240101000000", writeFile(t, "postgres-plain.zip", "archive"))
+	require.NoError(t, err)
+	assert.Equal(t, "backups/instance/20240101000000/postgres-plain.zip", key)
+	_, err = w.Upload(ctx, "20240101000000", writeFile(t, "manifest.json", "{}"))
+	require.NoError(t, err)
+
+	files, err := w.ListFiles(ctx, "20240101000000")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backups/instance/20240101000000/manifest.json", key}, files)
+
+	dest := filepath.Join(t.TempDir(), "out.zip")
+	require.NoError(t, w.Download(ctx, key, dest))
+	data, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, "archive", string(data))
+
+	require.NoError(t, w.Delete(ctx, "20240101000000"))
+	require.NoError(t, w.Delete(ctx, "20240101000000"))
+	keys, err := w.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	require.ErrorIs(t, w.SetStorageClass(ctx, key, "cold"), ErrStorageClassUnsupported)
+}
+
+func TestWebDAV_List(t *testing.T) {
+	ctx := context.Background()
+	w := newTestWebDAV(t)
+
+	keys, err := w.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	for _, ts := range []string{"20240301000000", "20240101000000", "20240201000000"} {
+		_, uErr := w.Upload(ctx, ts, writeFile(t, "manifest.json", "{}"))
+		require.NoError(t, uErr)
+	}
+
+	keys, err = w.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20240101000000", "20240201000000", "20240301000000"}, w.TrimPrefix(keys))
+
+	page, err := w.ListPage(ctx, storage.ListOptions{StartAfter: "20240101000000", Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20240201000000"}, w.TrimPrefix(page.Keys))
+	require.NotEmpty(t, page.NextToken)
+
+	page, err = w.ListPage(ctx, storage.ListOptions{Limit: 1, Token: page.NextToken})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20240301000000"}, w.TrimPrefix(page.Keys))
+	assert.Empty(t, page.NextToken)
+
+	page, err = w.ListPage(ctx, storage.ListOptions{Prefix: "202402"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20240201000000"}, w.TrimPrefix(page.Keys))
+}
+
+func TestWebDAV_Stats(t *testing.T) {
+	ctx := context.Background()
+	w := newTestWebDAV(t)
+
+	usage, err := w.Stats(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, usage.Count)
+
+	for ts, content := range map[string]string{"20240101000000": "archive", "20240102000000": "newer archive"} {
+		_, uErr := w.Upload(ctx, ts, writeFile(t, "postgres-plain.zip", content))
+		require.NoError(t, uErr)
+	}
+	_, err = w.Upload(ctx, "20240101000000", writeFile(t, "manifest.json", "{}"))
+	require.NoError(t, err)
+
+	usage, err = w.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(22), usage.Bytes)
+	assert.Equal(t, []storage.BackupUsage{
+		{Timestamp: "20240101000000", Bytes: 9, Files: 2},
+		{Timestamp: "20240102000000", Bytes: 13, Files: 1},
+	}, usage.Backups)
+}
+
+func TestWebDAV_Unauthorized(t *testing.T) {
+	w := newTestWebDAV(t)
+	w.cfg.WebDAV.Password = "wrong"
+
+	_, err := w.List(context.Background())
+	var sErr *StatusError
+	require.ErrorAs(t, err, &sErr)
+	assert.Equal(t, http.StatusUnauthorized, sErr.StatusCode)
+}
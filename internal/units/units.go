@@ -0,0 +1,21 @@
+// Package units provides helpers for formatting sizes and durations for humans.
+package units
+
+import "fmt"
+
+const byteUnit = 1024
+
+// FormatBytes formats a byte count using binary prefixes (e.g. "1.5 GiB").
+func FormatBytes(b int64) string {
+	if b < byteUnit {
+		return fmt.Sprintf("%d B", b)
+	}
+
+	div, exp := int64(byteUnit), 0
+	for n := b / byteUnit; n >= byteUnit; n /= byteUnit {
+		div *= byteUnit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,99 @@
+package version
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ReleasesURL is the GitHub API endpoint for the latest Stashly release.
+const ReleasesURL = "https://api.github.com/repos/hibare/stashly/releases/latest"
+
+// ErrInvalidVersion is returned for versions that aren't in vMAJOR.MINOR.PATCH form.
+var ErrInvalidVersion = errors.New("invalid version")
+
+// Release is a published Stashly release.
+type Release struct {
+	Version string `json:"tag_name"`
+	URL     string `json:"html_url"`
+}
+
+// Latest fetches the latest published release from url (normally ReleasesURL).
+func Latest(ctx context.Context, client *http.Client, url string) (*Release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status from %s: %s", url, resp.Status)
+	}
+
+	var r Release
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("error parsing release: %w", err)
+	}
+	return &r, nil
+}
+
+// parse splits a version like "v1.2.3" or "1.2.3-rc1" into its major, minor and patch numbers.
+func parse(v string) ([3]int, error) {
+	var parts [3]int
+	core, _, _ := strings.Cut(strings.TrimPrefix(v, "v"), "-")
+	fields := strings.Split(core, ".")
+	if len(fields) != len(parts) {
+		return parts, fmt.Errorf("%w: %q", ErrInvalidVersion, v)
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return parts, fmt.Errorf("%w: %q", ErrInvalidVersion, v)
+		}
+		parts[i] = n
+	}
+	return parts, nil
+}
+
+// Newer reports whether latest is a newer release than current.
+func Newer(current, latest string) (bool, error) {
+	cur, err := parse(current)
+	if err != nil {
+		return false, err
+	}
+	lat, err := parse(latest)
+	if err != nil {
+		return false, err
+	}
+	for i := range cur {
+		if lat[i] != cur[i] {
+			return lat[i] > cur[i], nil
+		}
+	}
+	return false, nil
+}
+
+// Outdated reports whether current is significantly behind latest: a major or minor release behind.
+// Patch releases alone don't count.
+func Outdated(current, latest string) (bool, error) {
+	cur, err := parse(current)
+	if err != nil {
+		return false, err
+	}
+	lat, err := parse(latest)
+	if err != nil {
+		return false, err
+	}
+	return lat[0] > cur[0] || (lat[0] == cur[0] && lat[1] > cur[1]), nil
+}
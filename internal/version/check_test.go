@@ -0,0 +1,55 @@
+package version
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewerAndOutdated(t *testing.T) {
+	tests := []struct {
+		current, latest string
+		newer, outdated bool
+	}{
+		{current: "v1.2.3", latest: "v1.2.3"},
+		{current: "v1.2.3", latest: "v1.2.4", newer: true},
+		{current: "v1.2.3", latest: "v1.3.0", newer: true, outdated: true},
+		{current: "1.9.0", latest: "v2.0.0", newer: true, outdated: true},
+		{current: "v1.3.0-rc1", latest: "v1.2.9"},
+	}
+	for _, tt := range tests {
+		newer, err := Newer(tt.current, tt.latest)
+		require.NoError(t, err)
+		assert.Equal(t, tt.newer, newer, "%s -> %s", tt.current, tt.latest)
+
+		outdated, err := Outdated(tt.current, tt.latest)
+		require.NoError(t, err)
+		assert.Equal(t, tt.outdated, outdated, "%s -> %s", tt.current, tt.latest)
+	}
+
+	_, err := Newer("dev", "v1.0.0")
+	require.ErrorIs(t, err, ErrInvalidVersion)
+}
+
+func TestLatest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"tag_name":"v1.4.0","html_url":"https://github.com/hibare/stashly/releases/tag/v1.4.0"}`))
+	}))
+	defer srv.Close()
+
+	r, err := Latest(t.Context(), srv.Client(), srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.4.0", r.Version)
+	assert.Equal(t, "https://github.com/hibare/stashly/releases/tag/v1.4.0", r.URL)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer failing.Close()
+
+	_, err = Latest(t.Context(), failing.Client(), failing.URL)
+	require.Error(t, err)
+}
@@ -0,0 +1,42 @@
+// Package version holds the Stashly build version and checks for newer releases.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Version is the Stashly version, set at build time with
+// -ldflags "-X github.com/hibare/stashly/internal/version.Version=<version>".
+var Version = "dev"
+
+// Commit and Date are the source revision and build date, set at build time like Version. When unset they
+// fall back to the VCS information Go embeds in the binary.
+var (
+	Commit = ""
+	Date   = ""
+)
+
+// BuildInfo describes the running binary.
+type BuildInfo struct {
+	Version   string
+	Commit    string
+	Date      string
+	GoVersion string
+}
+
+// Info returns the build information of the running binary.
+func Info() BuildInfo {
+	info := BuildInfo{Version: Version, Commit: Commit, Date: Date, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.Date == "":
+				info.Date = s.Value
+			}
+		}
+	}
+	return info
+}
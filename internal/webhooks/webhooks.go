@@ -0,0 +1,207 @@
+// Package webhooks delivers backup lifecycle events to outbound webhooks, so external systems can
+// follow a backup's progress without polling.
+//
+// Each event is POSTed as JSON. With a secret configured the request carries an
+// X-Stashly-Signature header, "sha256=" followed by the hex HMAC-SHA256 of the X-Stashly-Timestamp
+// header, a ".", and the body; see Sign.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	commonHTTP "github.com/hibare/GoCommon/v2/pkg/http"
+	"github.com/hibare/stashly/internal/config"
+	"github.com/hibare/stashly/internal/httpclient"
+)
+
+// Event types.
+const (
+	// EventStarted is sent when a backup run starts.
+	EventStarted = "backup.started"
+
+	// EventUploaded is sent once the backup archive is in storage.
+	EventUploaded = "backup.uploaded"
+
+	// EventPurged is sent after old backups were deleted by the retention policy.
+	EventPurged = "backup.purged"
+
+	// EventFailed is sent when a backup run fails.
+	EventFailed = "backup.failed"
+)
+
+// Headers set on every delivery.
+const (
+	HeaderEvent     = "X-Stashly-Event"
+	HeaderDelivery  = "X-Stashly-Delivery"
+	HeaderTimestamp = "X-Stashly-Timestamp"
+	HeaderSignature = "X-Stashly-Signature"
+)
+
+// Events lists the event types webhooks can subscribe to.
+var Events = []string{EventStarted, EventUploaded, EventPurged, EventFailed}
+
+var (
+	// ErrUnknownEvent is returned when a webhook subscribes to an event that doesn't exist.
+	ErrUnknownEvent = errors.New("unknown webhook event")
+
+	// ErrDeliveryFailed is returned when a webhook answers with a non-2xx status.
+	ErrDeliveryFailed = errors.New("webhook delivery failed")
+)
+
+// Event is the payload delivered to webhooks. Fields that don't apply to the event type are omitted.
+type Event struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	InstanceID string    `json:"instance_id"`
+	Time       time.Time `json:"time"`
+
+	// Key and Timestamp identify the backup; set for uploaded events.
+	Key       string `json:"key,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+
+	// Databases lists the exported databases and Size is the size in bytes of the uploaded archive.
+	Databases []string `json:"databases,omitempty"`
+	Size      int64    `json:"size,omitempty"`
+
+	// DurationSeconds is how long the backup took from pre-checks to a completed upload.
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+
+	// FailedOver names the secondary storage the backup was uploaded to because the primary failed.
+	FailedOver string `json:"failed_over,omitempty"`
+
+	// Purged lists the timestamps of the backups deleted by the retention policy.
+	Purged []string `json:"purged,omitempty"`
+
+	// Stage is where a failed run stopped ("backup" or "purge") and Error why.
+	Stage string `json:"stage,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// DispatcherIface delivers lifecycle events to the configured webhooks.
+// revive:disable-next-line exported
+type DispatcherIface interface {
+	Send(ctx context.Context, evt Event) error
+}
+
+// Dispatcher delivers events to every webhook subscribed to them.
+type Dispatcher struct {
+	hooks      []config.WebhookConfig
+	instanceID string
+	client     *http.Client
+}
+
+// Sign returns the signature of body sent at timestamp (Unix seconds), as set in X-Stashly-Signature.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// subscribed reports whether hook receives events of type event.
+func subscribed(hook config.WebhookConfig, event string) bool {
+	return len(hook.Events) == 0 || slices.Contains(hook.Events, event)
+}
+
+// newID returns a random delivery ID.
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Send delivers evt to every webhook subscribed to its type, filling in its ID, instance and time.
+// Deliveries are attempted once each; failures are logged and returned joined, and never stop the
+// remaining deliveries.
+func (d *Dispatcher) Send(ctx context.Context, evt Event) error {
+	evt.ID = newID()
+	evt.InstanceID = d.instanceID
+	if evt.Time.IsZero() {
+		evt.Time = time.Now().UTC()
+	}
+
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, hook := range d.hooks {
+		if !subscribed(hook, evt.Type) {
+			continue
+		}
+		if dErr := d.deliver(ctx, hook, evt, body); dErr != nil {
+			slog.WarnContext(ctx, "Failed to deliver webhook", "event", evt.Type, "url", redactURL(hook.URL), "error", dErr)
+			errs = append(errs, dErr)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, hook config.WebhookConfig, evt Event, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, evt.Type)
+	req.Header.Set(HeaderDelivery, evt.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if hook.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%w: %s answered %d", ErrDeliveryFailed, redactURL(hook.URL), resp.StatusCode)
+	}
+	slog.DebugContext(ctx, "Delivered webhook", "event", evt.Type, "url", redactURL(hook.URL))
+	return nil
+}
+
+// redactURL drops the query string, which often carries a token, from url for logging.
+func redactURL(url string) string {
+	base, _, _ := strings.Cut(url, "?")
+	return base
+}
+
+// New creates a dispatcher for the webhooks in cfg. It fails if a webhook subscribes to an unknown event.
+func New(cfg *config.Config) (*Dispatcher, error) {
+	for _, hook := range cfg.Webhooks {
+		for _, event := range hook.Events {
+			if !slices.Contains(Events, event) {
+				return nil, fmt.Errorf("%w %q for %s (available: %v)", ErrUnknownEvent, event, redactURL(hook.URL), Events)
+			}
+		}
+	}
+
+	client, err := httpclient.New(cfg, commonHTTP.DefaultHTTPClientTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Dispatcher{hooks: cfg.Webhooks, instanceID: cfg.App.InstanceID, client: client}, nil
+}
@@ -0,0 +1,93 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/hibare/stashly/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+// newReceiver starts a webhook receiver recording deliveries and answering with status.
+func newReceiver(t *testing.T, status int) (*httptest.Server, func() []delivery) {
+	t.Helper()
+	var mu sync.Mutex
+	var got []delivery
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		got = append(got, delivery{header: r.Header.Clone(), body: body})
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []delivery {
+		mu.Lock()
+		defer mu.Unlock()
+		return got
+	}
+}
+
+func TestDispatcher_Send(t *testing.T) {
+	all, allDeliveries := newReceiver(t, http.StatusNoContent)
+	failures, failureDeliveries := newReceiver(t, http.StatusOK)
+
+	d, err := New(&config.Config{
+		App: config.AppConfig{InstanceID: "instance"},
+		Webhooks: []config.WebhookConfig{
+			{URL: all.URL, Secret: "secret"},
+			{URL: failures.URL, Events: []string{EventFailed}},
+		},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, d.Send(context.Background(), Event{Type: EventUploaded, Key: "backups/instance/20240101000000/", Size: 42}))
+	require.NoError(t, d.Send(context.Background(), Event{Type: EventFailed, Stage: "backup", Error: "boom"}))
+
+	got := allDeliveries()
+	require.Len(t, got, 2)
+	first := got[0]
+	assert.Equal(t, EventUploaded, first.header.Get(HeaderEvent))
+	assert.Equal(t, Sign("secret", first.header.Get(HeaderTimestamp), first.body), first.header.Get(HeaderSignature))
+
+	var evt Event
+	require.NoError(t, json.Unmarshal(first.body, &evt))
+	assert.Equal(t, EventUploaded, evt.Type)
+	assert.Equal(t, "instance", evt.InstanceID)
+	assert.Equal(t, int64(42), evt.Size)
+	assert.Equal(t, first.header.Get(HeaderDelivery), evt.ID)
+	assert.False(t, evt.Time.IsZero())
+
+	got = failureDeliveries()
+	require.Len(t, got, 1)
+	assert.Equal(t, EventFailed, got[0].header.Get(HeaderEvent))
+	assert.Empty(t, got[0].header.Get(HeaderSignature))
+}
+
+func TestDispatcher_Send_DeliveryFailure(t *testing.T) {
+	broken, _ := newReceiver(t, http.StatusInternalServerError)
+	working, deliveries := newReceiver(t, http.StatusOK)
+
+	d, err := New(&config.Config{Webhooks: []config.WebhookConfig{{URL: broken.URL}, {URL: working.URL}}})
+	require.NoError(t, err)
+
+	err = d.Send(context.Background(), Event{Type: EventStarted})
+	require.ErrorIs(t, err, ErrDeliveryFailed)
+	assert.Len(t, deliveries(), 1)
+}
+
+func TestNew_UnknownEvent(t *testing.T) {
+	_, err := New(&config.Config{Webhooks: []config.WebhookConfig{{URL: "https://example.com/hook", Events: []string{"backup.done"}}}})
+	require.ErrorIs(t, err, ErrUnknownEvent)
+}